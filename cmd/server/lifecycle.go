@@ -0,0 +1,294 @@
+package main
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/backup"
+	"HibiscusIM/pkg/config"
+	"HibiscusIM/pkg/lifecycle"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/metrics"
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/queue"
+	"HibiscusIM/pkg/search"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// migrateModels 启动时自动迁移的模型
+var migrateModels = []any{
+	&util.Config{},
+	&models.User{},
+	&models.Group{},
+	&models.GroupMember{},
+	&models.GroupModeration{},
+	&models.GroupModerationEvent{},
+	&models.EmailAbuseReport{},
+	&models.Question{},
+	&models.Answer{},
+	&models.Questionnaire{},
+	&models.QuestionnaireResponse{},
+	&models.RecordingPrompt{},
+	&models.VoiceJob{},
+	&models.Recording{},
+	&models.Attachment{},
+	&models.StorageUsage{},
+	&models.WSDeadLetter{},
+	&models.SurveyExport{},
+	&models.ConversationCursor{},
+	&models.ConversationSequence{},
+	&models.ConversationSetting{},
+	&models.MessageReaction{},
+	&models.MessageReactionCount{},
+	&models.LLMUsageEvent{},
+	&models.LLMUsageDaily{},
+	&models.AdminImport{},
+	&models.ClientEvent{},
+	&models.SystemEvent{},
+	&notification.InternalNotification{},
+	&notification.NotificationPreference{},
+	&notification.ScheduledNotification{},
+	&notification.NotificationOutbox{},
+	&models.Announcement{},
+	&models.AnnouncementReceipt{},
+	&backup.BackupRun{},
+	&search.SearchImpression{},
+	&search.SearchClick{},
+	&search.SearchDictionaryEntry{},
+	&search.SearchIndexVersion{},
+	&middleware.OperationLog{},
+	&middleware.AuditLog{},
+	&metrics.MonitorData{},
+}
+
+// 生命周期组件名称
+const (
+	componentDatabase = "database"
+	componentCache    = "cache"
+	componentQueue    = "queue"
+	componentWorkers  = "workers"
+	componentStorage  = "storage"
+	componentMonitor  = "monitor"
+	componentHandlers = "handlers"
+	componentSearch   = "search"
+	componentBackup   = "backup"
+)
+
+// services 由生命周期管理器启动的子系统
+type services struct {
+	db         *gorm.DB
+	app        *HibiscusIMApp
+	taskQueue  *queue.Queue
+	monitor    *metrics.Monitor
+	persister  *metrics.Persister
+	stopBackup func()
+}
+
+// register 按依赖关系注册子系统：搜索与备份为可选组件，失败时降级运行，
+// SEARCH_REQUIRED 为 true 时搜索失败终止启动
+func (s *services) register(lc *lifecycle.Manager) {
+	lc.MustRegister(lifecycle.Component{
+		Name:   componentDatabase,
+		Start:  s.startDatabase,
+		Health: s.pingDatabase,
+		Stop:   s.closeDatabase,
+	})
+	lc.MustRegister(lifecycle.Component{
+		Name: componentCache,
+		Start: func(ctx context.Context) error {
+			util.InitGlobalCache(1024, 5*time.Minute)
+			return nil
+		},
+	})
+	lc.MustRegister(lifecycle.Component{
+		Name:  componentQueue,
+		Start: s.startQueue,
+	})
+	// 按 STORAGE_DRIVER 选择文件存储，配置错误时终止启动
+	lc.MustRegister(lifecycle.Component{
+		Name: componentStorage,
+		Start: func(ctx context.Context) error {
+			store, err := stores.NewStoreFromEnv()
+			if err != nil {
+				return err
+			}
+			stores.SetDefault(store)
+			return nil
+		},
+	})
+	lc.MustRegister(lifecycle.Component{
+		Name:      componentMonitor,
+		DependsOn: []string{componentDatabase},
+		Start:     s.startMonitor,
+		Stop: func(ctx context.Context) error {
+			s.monitor.Stop()
+			s.persister.Stop()
+			return nil
+		},
+	})
+	lc.MustRegister(lifecycle.Component{
+		Name:      componentHandlers,
+		DependsOn: []string{componentDatabase, componentCache, componentQueue, componentStorage},
+		Start: func(ctx context.Context) error {
+			s.app = NewHibiscusIMApp(s.db)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			s.app.handlers.Close()
+			return nil
+		},
+	})
+	// 任务处理器在 handlers 中注册，worker 需在其后启动，
+	// 否则重启后恢复的任务会因找不到处理器直接进入死信
+	lc.MustRegister(lifecycle.Component{
+		Name:      componentWorkers,
+		DependsOn: []string{componentQueue, componentHandlers},
+		Start: func(ctx context.Context) error {
+			s.taskQueue.Start(context.Background())
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			s.taskQueue.Stop()
+			return nil
+		},
+	})
+	lc.MustRegister(lifecycle.Component{
+		Name:      componentSearch,
+		DependsOn: []string{componentHandlers},
+		Optional:  !config.GlobalConfig.SearchRequired,
+		Start:     func(ctx context.Context) error { return s.app.handlers.StartSearch(ctx) },
+		Health:    func(ctx context.Context) error { return s.app.handlers.SearchHealth(ctx) },
+		Stop:      func(ctx context.Context) error { return s.app.handlers.StopSearch(ctx) },
+	})
+	// 备份结果回调在 handlers 中注册，需先于调度器启动
+	lc.MustRegister(lifecycle.Component{
+		Name:      componentBackup,
+		DependsOn: []string{componentDatabase, componentHandlers},
+		Optional:  true,
+		Start: func(ctx context.Context) error {
+			if config.GlobalConfig.BackupEnabled {
+				s.stopBackup = backup.StartBackupScheduler()
+			}
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			if s.stopBackup != nil {
+				s.stopBackup()
+			}
+			return nil
+		},
+	})
+}
+
+// startDatabase 连接数据库并迁移模型，非生产环境写入默认配置
+func (s *services) startDatabase(ctx context.Context) error {
+	dbDriver := config.GlobalConfig.DBDriver
+	dsn := config.GlobalConfig.DSN
+	db, err := util.InitDatabase(os.Stdout, dbDriver, dsn)
+	if err != nil {
+		return err
+	}
+	s.db = db
+
+	if err := util.MakeMigrates(db, migrateModels); err != nil {
+		logger.Error("migration failed: ", zap.Error(err))
+	} else {
+		logger.Info("migration success", zap.String("database", dbDriver), zap.String("dsn", dsn))
+	}
+	if os.Getenv("APP_ENV") != "production" {
+		if err := initDefaultConfigs(db); err != nil {
+			logger.Error("init default config failed: ", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (s *services) pingDatabase(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (s *services) closeDatabase(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// startQueue 创建后台任务队列，后端不可用时回退为内存队列，worker 由 workers 组件启动
+func (s *services) startQueue(ctx context.Context) error {
+	broker, err := queue.NewBroker(config.GlobalConfig.QueueBackend, queue.RedisConfig{
+		Addr:     config.GlobalConfig.QueueRedisAddr,
+		Password: config.GlobalConfig.QueueRedisPass,
+		DB:       config.GlobalConfig.QueueRedisDB,
+	})
+	if err != nil {
+		logger.Error("init task queue failed, fallback to memory", zap.Error(err))
+		broker = queue.NewMemoryBroker()
+	}
+	s.taskQueue = queue.NewQueue(broker, queue.Config{
+		Name:        "default",
+		Concurrency: config.GlobalConfig.QueueConcurrency,
+	})
+	queue.SetGlobalQueue(s.taskQueue)
+	return nil
+}
+
+// startMonitor 启动监控、告警与链路导出
+func (s *services) startMonitor(ctx context.Context) error {
+	monitor := metrics.NewMonitor(&metrics.MonitorConfig{
+		EnableMetrics:       true,
+		EnableTracing:       true,
+		MaxSpans:            10000,
+		EnableSQLAnalysis:   true,
+		MaxQueries:          10000,
+		SlowThreshold:       100 * time.Millisecond,
+		EnableSystemMonitor: true,
+		MaxStats:            1000,
+		MonitorInterval:     30 * time.Second,
+		EnableAlerting:      true,
+		AlertInterval:       30 * time.Second,
+		SlowHTTPThreshold:   time.Duration(config.GlobalConfig.MonitorSlowHTTP) * time.Millisecond,
+	})
+	initAlerting(monitor.GetAlertEngine())
+	if err := s.db.Use(metrics.NewGormPlugin(monitor)); err != nil {
+		logger.Warn("sql metrics plugin disabled", zap.Error(err))
+	}
+	if config.GlobalConfig.MonitorExplain {
+		if err := monitor.EnableExplain(s.db, 0); err != nil {
+			logger.Warn("slow query explain disabled", zap.Error(err))
+		}
+	}
+	if config.GlobalConfig.BackupEnabled {
+		backup.RegisterAlerts(monitor, time.Duration(config.GlobalConfig.BackupMaxAge)*time.Hour)
+	}
+
+	traceExporter, err := metrics.NewTraceExporter(config.GlobalConfig.TraceExporter, config.GlobalConfig.TraceEndpoint, config.GlobalConfig.TraceServiceName)
+	if err != nil {
+		logger.Warn("trace exporter disabled", zap.Error(err))
+	} else if traceExporter != nil {
+		monitor.SetTraceExporter(traceExporter, metrics.DefaultBatchOptions())
+	}
+
+	// 系统快照、慢查询与告警事件写入 monitor_data，重启后历史仍可查询
+	s.persister = metrics.NewPersister(s.db, monitor, metrics.PersistOptions{
+		Interval:  time.Duration(config.GlobalConfig.MonitorPersist) * time.Second,
+		Retention: time.Duration(config.GlobalConfig.MonitorRetention) * 24 * time.Hour,
+	})
+
+	metrics.SetGlobalMonitor(monitor)
+	monitor.Start()
+	s.persister.Start()
+	s.monitor = monitor
+	return nil
+}
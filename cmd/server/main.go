@@ -9,17 +9,27 @@ import (
 	"HibiscusIM/pkg/backup"
 	"HibiscusIM/pkg/config"
 	constants "HibiscusIM/pkg/constant"
+	"HibiscusIM/pkg/geoip"
 	"HibiscusIM/pkg/i18n"
+	"HibiscusIM/pkg/lifecycle"
 	"HibiscusIM/pkg/logger"
 	"HibiscusIM/pkg/metrics"
 	"HibiscusIM/pkg/middleware"
 	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/redact"
+	"HibiscusIM/pkg/scanner"
 	"HibiscusIM/pkg/util"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -106,15 +116,31 @@ func main() {
 
 	// 1. parse command line parameters
 	mode := flag.String("mode", "test", "running environment (development, test, production)")
+	configFile := flag.String("config", "", "YAML config file (defaults to CONFIG_FILE)")
+	addrFlag := flag.String("addr", "", "HTTP Serve address")
+	dbDriverFlag := flag.String("db-driver", "", "database driver")
+	dsnFlag := flag.String("dsn", "", "database source name")
+	reindexFlag := flag.Bool("reindex", false, "rebuild the search index from the database and exit")
+	fixturesFlag := flag.String("fixtures", "", "load seed data from a YAML/JSON file or directory and exit")
+	restoreFlag := flag.String("restore-sqlite", "", "restore the SQLite backups in BACKUP_PATH into a new database file and exit")
+	restoreAtFlag := flag.String("restore-at", "", "restore point for -restore-sqlite in RFC 3339 (defaults to the latest backup)")
+	overrides := config.OverrideFlags{}
+	flag.Var(overrides, "set", "override config value, KEY=VALUE (repeatable)")
 	flag.Parse()
 
+	for key, v := range map[string]string{"ADDR": *addrFlag, "DB_DRIVER": *dbDriverFlag, "DSN": *dsnFlag} {
+		if v != "" {
+			overrides[key] = v
+		}
+	}
+
 	// 2. set environment variables
 	if *mode != "" {
 		os.Setenv("APP_ENV", *mode)
 	}
 
 	// 3. load global configuration
-	if err := config.Load(); err != nil {
+	if err := config.LoadWithOptions(config.LoadOptions{ConfigFile: *configFile, Overrides: overrides}); err != nil {
 		panic("config load failed: " + err.Error())
 	}
 
@@ -134,7 +160,7 @@ func main() {
 		zap.String("mode", config.GlobalConfig.Mode),
 		zap.String("monitor_prefix", config.GlobalConfig.MonitorPrefix),
 		zap.Bool("language_enabled", config.GlobalConfig.LanguageEnabled),
-		zap.String("api_secret_key", config.GlobalConfig.APISecretKey),
+		zap.String("api_secret_key", config.MaskValue("API_SECRET_KEY", config.GlobalConfig.APISecretKey)),
 	)
 
 	logger.Info("api config",
@@ -172,40 +198,51 @@ func main() {
 		zap.String("search engine path", config.GlobalConfig.SearchPath),
 	)
 
-	// 6. load data source
-	logWriter := os.Stdout
-	dbDriver := config.GlobalConfig.DBDriver
-	dsn := config.GlobalConfig.DSN
-	db, err := util.InitDatabase(logWriter, dbDriver, dsn)
-	if err != nil {
-		logger.Error("init database failed: ", zap.Error(err))
+	// 5.1 --restore-sqlite: rebuild a database file from the backup chain and exit, the live database is not opened
+	if *restoreFlag != "" {
+		var at time.Time
+		if *restoreAtFlag != "" {
+			if at, err = time.Parse(time.RFC3339, *restoreAtFlag); err != nil {
+				logger.Error("invalid restore point", zap.String("restore-at", *restoreAtFlag), zap.Error(err))
+				os.Exit(1)
+			}
+		}
+		art, err := backup.RestoreSQLite(config.GlobalConfig.BackupPath, at, *restoreFlag)
+		if err != nil {
+			logger.Error("sqlite restore failed", zap.String("path", *restoreFlag), zap.Error(err))
+			os.Exit(1)
+		}
+		logger.Info("sqlite restore finished", zap.String("path", *restoreFlag), zap.String("backup", art.File), zap.Time("taken_at", art.TakenAt))
+		return
 	}
 
-	// 7. load models
-	err = util.MakeMigrates(db, []any{
-		&util.Config{},
-		&models.User{},
-		&models.Group{},
-		&models.GroupMember{},
-		&models.Question{},
-		&models.Answer{},
-		&models.Questionnaire{},
-		&models.QuestionnaireResponse{},
-		&models.RecordingPrompt{},
-		&models.VoiceJob{},
-		&models.Recording{},
-		&notification.InternalNotification{},
-		&middleware.OperationLog{},
-	})
-	if err != nil {
-		logger.Error("migration failed: ", zap.Error(err))
-	} else {
-		logger.Info("migration success", zap.String("database", dbDriver), zap.String("dsn", dsn))
+	// 6. register subsystems, started in dependency order and stopped in reverse
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	lc := lifecycle.NewManager(lifecycle.Config{})
+	svc := &services{}
+	svc.register(lc)
+
+	// 7. load data source and models
+	if err := lc.Start(ctx, componentDatabase); err != nil {
+		logger.Error("init database failed: ", zap.Error(err))
+		os.Exit(1)
 	}
+	db := svc.db
 
-	if os.Getenv("APP_ENV") != "production" {
-		if err := initDefaultConfigs(db); err != nil {
-			logger.Error("init default config failed: ", zap.Error(err))
+	// 7.1 load seed data, only in environments listed in FIXTURES_ENVIRONMENTS
+	if *fixturesFlag != "" {
+		err := loadFixtures(db, *fixturesFlag)
+		_ = lc.Stop(context.Background())
+		if err != nil {
+			logger.Error("load fixtures failed", zap.String("path", *fixturesFlag), zap.Error(err))
+			os.Exit(1)
+		}
+		return
+	}
+	if path := config.GlobalConfig.FixturesPath; path != "" {
+		if err := loadFixtures(db, path); err != nil {
+			logger.Warn("load fixtures failed", zap.String("path", path), zap.Error(err))
 		}
 	}
 
@@ -224,55 +261,84 @@ func main() {
 	if DSN == "" {
 		DSN = "file::memory:?cache=shared"
 	}
-	flag.StringVar(&addr, "addr", addr, "HTTP Serve address")
-	flag.StringVar(&DBDriver, "db-driver", DBDriver, "database driver")
-	flag.StringVar(&DSN, "dsn", DSN, "database source name")
 
 	logger.Info("checked config -- addr: ", zap.String("addr", addr))
 	logger.Info("checked config -- db-driver: ", zap.String("db-driver", DBDriver), zap.String("dsn", DSN))
 	logger.Info("checked config -- mode: ", zap.String("mode", config.GlobalConfig.Mode))
 
-	// 9. Load Global Cache
-	util.InitGlobalCache(1024, 5*time.Minute)
-
-	// 10. New App
-	app := NewHibiscusIMApp(db)
-
-	// 11. Initialize monitoring system
-	monitor := metrics.NewMonitor(&metrics.MonitorConfig{
-		EnableMetrics:       true,
-		EnableTracing:       true,
-		MaxSpans:            10000,
-		EnableSQLAnalysis:   true,
-		MaxQueries:          10000,
-		SlowThreshold:       100 * time.Millisecond,
-		EnableSystemMonitor: true,
-		MaxStats:            1000,
-		MonitorInterval:     30 * time.Second,
-	})
+	// 9. --reindex: start search and its dependencies, rebuild the index and exit
+	scanner.SetGlobalScanner(scanner.LoadFromEnv())
+	if *reindexFlag {
+		if err := lc.Start(ctx, componentSearch); err != nil {
+			logger.Error("search start failed", zap.Error(err))
+			os.Exit(1)
+		}
+		result, err := svc.app.handlers.ReindexSearch(ctx)
+		_ = lc.Stop(context.Background())
+		if err != nil {
+			logger.Error("search reindex failed", zap.Error(err))
+			os.Exit(1)
+		}
+		logger.Info("search reindex finished", zap.Any("indexed", result.Indexed), zap.Duration("duration", result.Duration))
+		return
+	}
+
+	// Redact PII and credentials in monitor and log outputs
+	redactor, err := redact.New(redact.ParseConfig(config.GlobalConfig.RedactEnabled, config.GlobalConfig.RedactFields, config.GlobalConfig.RedactPatterns))
+	if err != nil {
+		logger.Warn("invalid redact config, using built-in rules", zap.Error(err))
+	} else {
+		redact.SetDefault(redactor)
+	}
 
-	// 12. Set Global Monitor
-	metrics.SetGlobalMonitor(monitor)
+	// GeoIP lookups for operation logs; a missing database degrades to "unknown"
+	geo, err := geoip.New(geoip.Config{DBPath: config.GlobalConfig.GeoIPDBPath, CacheSize: config.GlobalConfig.GeoIPCacheSize})
+	if err != nil {
+		logger.Warn("geoip database unavailable, locations will be unknown", zap.String("path", config.GlobalConfig.GeoIPDBPath), zap.Error(err))
+	}
+	geoip.SetDefault(geo)
+	defer geo.Close()
 
-	monitor.Start()
-	defer monitor.Stop()
+	// 10. Start remaining subsystems: monitoring, handlers, search and backup scheduler
+	if err := lc.Start(ctx); err != nil {
+		logger.Error("server start failed", zap.Error(err))
+		os.Exit(1)
+	}
+	degraded := lc.Degraded()
+	if len(degraded) > 0 {
+		logger.Warn("server running in degraded mode", zap.Strings("components", degraded))
+	}
+	app := svc.app
+	monitor := svc.monitor
 
-	// 13. Start timed task
+	// 11. Start timed task
 	go task.StartOfflineChecker(db)
-	// Start Backup Data
-	if config.GlobalConfig.BackupEnabled {
-		backup.StartBackupScheduler()
-	}
 
-	// 14. Initialize gin routing
+	// 12. Initialize gin routing
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
+	// 处理器以 *gin.Context 作为 context.Context 传递时，可取到请求上下文中的链路跨度
+	r.ContextWithFallback = true
 	r.LoadHTMLGlob("templates/**/**")
 
-	// 15. use middleware
+	// 13. use middleware
+
+	// Compression Middleware
+	// 注册在最外层，慢请求样本、日志与指标记录的都是压缩前的响应
+	if !util.GetBoolEnv(constants.ENV_COMPRESSION_DISABLED) {
+		r.Use(middleware.Compression(middleware.CompressionConfig{
+			Level:   int(util.GetIntEnv(constants.ENV_COMPRESSION_LEVEL)),
+			MinSize: int(util.GetIntEnv(constants.ENV_COMPRESSION_MIN_SIZE)),
+		}))
+	}
 
-	// Monitoring Middleware
+	// Tracing and Monitoring Middleware
+	r.Use(metrics.TracingMiddleware(monitor, metrics.TracingConfig{UserID: spanUserID}))
 	r.Use(metrics.MonitorMiddleware(monitor))
+	// HTTP 指标通过监控路由组的 /metric 暴露，配置了 MonitorPrefix 时才记录
+	if config.GlobalConfig.MonitorPrefix != "" {
+		r.Use(metrics.HTTPMetricsMiddleware(monitor.GetMetrics()))
+	}
 
 	// Cookie Register
 	secret := util.GetEnv(constants.ENV_SESSION_SECRET)
@@ -293,12 +359,35 @@ func main() {
 	r.Use(middleware.LoggerMiddleware(zap.L()))
 
 	// RateLimit Middleware
+	if mode := util.GetEnv(constants.ENV_RATE_LIMIT_FAILURE_MODE); mode != "" {
+		rlConfig := middleware.GetRateLimiterConfig()
+		rlConfig.FailureMode = mode
+		middleware.SetRateLimiterConfig(rlConfig)
+	}
+	if algorithm := util.GetEnv(constants.ENV_RATE_LIMIT_ALGORITHM); algorithm != "" {
+		rlConfig := middleware.GetRateLimiterConfig()
+		rlConfig.Algorithm = algorithm
+		middleware.SetRateLimiterConfig(rlConfig)
+	}
+	if addr := util.GetEnv(constants.ENV_RATE_LIMIT_REDIS_ADDR); addr != "" {
+		factory, err := middleware.NewRedisStoreFactory(middleware.RedisStoreConfig{
+			Addr:     addr,
+			Password: util.GetEnv(constants.ENV_RATE_LIMIT_REDIS_PASSWORD),
+			DB:       int(util.GetIntEnv(constants.ENV_RATE_LIMIT_REDIS_DB)),
+			Prefix:   util.GetEnv(constants.ENV_RATE_LIMIT_REDIS_PREFIX),
+		})
+		if err != nil {
+			logger.Warn("rate limiter redis store unavailable, using memory store", zap.Error(err))
+		} else {
+			middleware.SetRateLimiterStore(factory.Create())
+		}
+	}
 	r.Use(middleware.RateLimiterMiddleware())
 
 	// Assets Middleware
 	r.Use(hibiscusIM.WithStaticAssets(r, util.GetEnv(constants.ENV_STATIC_PREFIX), util.GetEnv(constants.ENV_STATIC_ROOT)))
 
-	// 16 Init I18n Support
+	// 14 Init I18n Support
 	if config.GlobalConfig.LanguageEnabled {
 		i18nSupport, err := i18n.NewI18nSupport("en") // 默认是英文
 		if err != nil {
@@ -307,21 +396,47 @@ func main() {
 		r.Use(middleware.LanguageMiddleware(i18nSupport))
 	}
 
-	// 17. Register Routes
+	// 15. Register Routes
 	app.RegisterRoutes(r)
 
-	// 18. Register Monitoring API Routes
+	// 16. Register Monitoring API Routes
 	monitorAPI := metrics.NewMonitorAPI(monitor)
 	monitorGroup := r.Group(config.GlobalConfig.MonitorPrefix)
-	monitorAPI.RegisterRoutes(monitorGroup)
+	monitorAPI.RegisterRoutes(monitorGroup, models.AuthRequired, models.WithAdminAuth())
+	backup.RegisterMonitorRoutes(monitorGroup)
 
-	// 19. Initialize User Listener
+	// 17. Initialize User Listener
 	listeners.InitUserListeners()
 
-	logger.Info("server run success", zap.String("addr", addr))
-	// 20. Start HTTP Server
-	if err := r.Run(addr); err != nil {
-		logger.Error("server run failed", zap.Error(err))
+	models.RecordSystemEvent(models.SystemEventInfo, models.SystemEventStartup, "start", "server started", map[string]any{
+		"addr":       addr,
+		"mode":       config.GlobalConfig.Mode,
+		"dbDriver":   DBDriver,
+		"configFile": config.ConfigFile(),
+		"degraded":   degraded,
+	})
+	// 18. Start HTTP Server, shut down gracefully on SIGINT/SIGTERM
+	srv := &http.Server{Addr: addr, Handler: r}
+	go func() {
+		logger.Info("server run success", zap.String("addr", addr))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server run failed", zap.Error(err))
+			stop()
+		}
+	}()
+	<-ctx.Done()
+
+	logger.Info("server shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := app.handlers.DrainWebSockets(shutdownCtx); err != nil {
+		logger.Warn("websocket drain incomplete", zap.Error(err))
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("http server shutdown failed", zap.Error(err))
+	}
+	if err := lc.Stop(shutdownCtx); err != nil {
+		logger.Warn("subsystem shutdown failed", zap.Error(err))
 	}
 }
 
@@ -348,3 +463,66 @@ func printBannerFromFile(filename string) error {
 	}
 	return nil
 }
+
+// spanUserID 请求处理过程中已加载的当前用户 ID，未登录或未加载时返回空串
+func spanUserID(c *gin.Context) string {
+	if user, ok := c.Get(constants.UserField); ok {
+		if u, ok := user.(*models.User); ok && u != nil {
+			return strconv.FormatUint(uint64(u.ID), 10)
+		}
+	}
+	return ""
+}
+
+// initAlerting 加载告警规则并配置通知渠道
+func initAlerting(engine *metrics.AlertEngine) {
+	if engine == nil {
+		return
+	}
+	if path := util.GetEnv("ALERT_RULES_FILE"); path != "" {
+		n, err := engine.LoadRulesFile(path)
+		if err != nil {
+			logger.Warn("load alert rules failed", zap.String("path", path), zap.Error(err))
+		} else {
+			logger.Info("alert rules loaded", zap.Int("count", n))
+		}
+	}
+	if url := util.GetEnv("ALERT_WEBHOOK_URL"); url != "" {
+		engine.AddNotifier(metrics.NewWebhookNotifier(url, nil))
+	}
+	if to := util.GetEnv("ALERT_EMAIL_TO"); to != "" {
+		engine.AddNotifier(metrics.NewEmailNotifier(notification.NewMailNotification(config.GlobalConfig.Mail), strings.Split(to, ",")...))
+	}
+	// 告警状态变化写入系统事件日志
+	engine.AddNotifier(metrics.NotifierFunc(func(ctx context.Context, alert *metrics.Alert) error {
+		level := models.SystemEventWarn
+		if alert.State == metrics.AlertStateResolved {
+			level = models.SystemEventInfo
+		}
+		models.RecordSystemEvent(level, models.SystemEventAlert, alert.State, fmt.Sprintf("alert %s %s", alert.Rule, alert.State), map[string]any{
+			"rule":     alert.Rule,
+			"severity": alert.Severity,
+			"value":    alert.Value,
+			"labels":   alert.Labels,
+		})
+		return nil
+	}))
+}
+
+// loadFixtures 读取并写入种子数据，运行环境不在 FIXTURES_ENVIRONMENTS 中时拒绝加载
+func loadFixtures(db *gorm.DB, path string) error {
+	env := os.Getenv("APP_ENV")
+	if !models.FixturesAllowed(env, config.GlobalConfig.FixturesEnvs) {
+		return fmt.Errorf("%w: %s", models.ErrFixturesNotAllowed, env)
+	}
+	fixtures, err := models.ReadFixtures(path)
+	if err != nil {
+		return err
+	}
+	result, err := models.LoadFixtures(db, env, fixtures...)
+	if err != nil {
+		return err
+	}
+	logger.Info("fixtures loaded", zap.String("path", path), zap.Any("created", result.Created), zap.Any("skipped", result.Skipped))
+	return nil
+}
@@ -0,0 +1,162 @@
+package hibiscusIM
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded,
+// does not match the requested order, or the order cannot be paged by cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type keysetColumn struct {
+	field *schema.Field
+	desc  bool
+}
+
+// Keyset paginates a query by the values of its order columns instead of OFFSET.
+// The primary key is always appended as the last order column so that cursors
+// are stable when the other columns contain duplicates.
+type Keyset struct {
+	table   string
+	columns []keysetColumn
+}
+
+// NewKeyset builds a keyset for model ordered by orders. Every order column
+// must be the primary key or the leading column of an index, and the model
+// must have a single primary key.
+func NewKeyset(db *gorm.DB, model any, orders []Order) (*Keyset, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	sch := stmt.Schema
+	pk := sch.PrioritizedPrimaryField
+	if pk == nil || len(sch.PrimaryFields) != 1 {
+		return nil, fmt.Errorf("cursor pagination requires a single primary key on %s", sch.Table)
+	}
+
+	indexed := map[string]bool{pk.DBName: true}
+	for _, idx := range sch.ParseIndexes() {
+		if len(idx.Fields) > 0 && idx.Fields[0].Field != nil {
+			indexed[idx.Fields[0].DBName] = true
+		}
+	}
+
+	k := &Keyset{table: sch.Table}
+	for _, o := range orders {
+		field := sch.LookUpField(o.Name)
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("%w: unknown order field %s", ErrInvalidCursor, o.Name)
+		}
+		if !indexed[field.DBName] {
+			return nil, fmt.Errorf("%w: order field %s is not indexed", ErrInvalidCursor, o.Name)
+		}
+		if field == pk {
+			// ordering by the primary key already makes the order unique
+			k.columns = append(k.columns, keysetColumn{field: pk, desc: o.Op == OrderOpDesc})
+			return k, nil
+		}
+		k.columns = append(k.columns, keysetColumn{field: field, desc: o.Op == OrderOpDesc})
+	}
+	// the tie breaker follows the direction of the last order column
+	desc := len(k.columns) > 0 && k.columns[len(k.columns)-1].desc
+	k.columns = append(k.columns, keysetColumn{field: pk, desc: desc})
+	return k, nil
+}
+
+// Columns returns the column names the keyset orders by, they must be selected
+// for Cursor to read them back.
+func (k *Keyset) Columns() []string {
+	cols := make([]string, len(k.columns))
+	for i, c := range k.columns {
+		cols[i] = c.field.DBName
+	}
+	return cols
+}
+
+// Apply adds the keyset order to db and, when cursor is not empty, the
+// condition selecting the rows after it.
+func (k *Keyset) Apply(db *gorm.DB, cursor string) (*gorm.DB, error) {
+	for _, c := range k.columns {
+		dir := "ASC"
+		if c.desc {
+			dir = "DESC"
+		}
+		db = db.Order(fmt.Sprintf("`%s`.`%s` %s", k.table, c.field.DBName, dir))
+	}
+	if cursor == "" {
+		return db, nil
+	}
+	values, err := k.decode(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// (a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND id > ?)
+	var clauses []string
+	var args []any
+	for i, c := range k.columns {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("`%s`.`%s` = ?", k.table, k.columns[j].field.DBName))
+			args = append(args, values[j])
+		}
+		op := ">"
+		if c.desc {
+			op = "<"
+		}
+		parts = append(parts, fmt.Sprintf("`%s`.`%s` %s ?", k.table, c.field.DBName, op))
+		args = append(args, values[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return db.Where(strings.Join(clauses, " OR "), args...), nil
+}
+
+// Cursor encodes the order column values of row, which must be a pointer to
+// (or value of) the model, as an opaque cursor.
+func (k *Keyset) Cursor(db *gorm.DB, row any) (string, error) {
+	rv := reflect.Indirect(reflect.ValueOf(row))
+	values := make([]any, len(k.columns))
+	for i, c := range k.columns {
+		v, zero := c.field.ValueOf(db.Statement.Context, rv)
+		if zero && c.field.FieldType.Kind() == reflect.Ptr {
+			return "", fmt.Errorf("cannot build cursor from null %s", c.field.DBName)
+		}
+		values[i] = v
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decode restores cursor values with the Go types of the order columns, so
+// that times and numbers compare the same way as the stored values.
+func (k *Keyset) decode(cursor string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw) != len(k.columns) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(raw))
+	for i, c := range k.columns {
+		ptr := reflect.New(c.field.FieldType)
+		if err := json.Unmarshal(raw[i], ptr.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = ptr.Elem().Interface()
+	}
+	return values, nil
+}
@@ -0,0 +1,140 @@
+package hibiscusIM
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type cursorTestRow struct {
+	ID        uint      `gorm:"primaryKey"`
+	Score     int       `gorm:"index"`
+	CreatedAt time.Time `gorm:"index"`
+	Name      string
+}
+
+func newCursorTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cursor.db")), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&cursorTestRow{}))
+	return db
+}
+
+// pageAll 按 keyset 逐页读取全部记录，返回读取顺序的主键
+func pageAll(t *testing.T, db *gorm.DB, k *Keyset, limit int) []uint {
+	var ids []uint
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 100, "pagination does not terminate")
+		q, err := k.Apply(db.Model(&cursorTestRow{}), cursor)
+		require.NoError(t, err)
+		var rows []cursorTestRow
+		require.NoError(t, q.Limit(limit).Find(&rows).Error)
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		if len(rows) < limit {
+			return ids
+		}
+		cursor, err = k.Cursor(db, &rows[len(rows)-1])
+		require.NoError(t, err)
+	}
+}
+
+func TestKeysetDuplicateOrderValues(t *testing.T) {
+	db := newCursorTestDB(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rows []cursorTestRow
+	for i := 0; i < 23; i++ {
+		// 分数与创建时间大量重复，分页边界会落在重复值中间
+		rows = append(rows, cursorTestRow{Score: i % 3, CreatedAt: base.Add(time.Duration(i%4) * time.Minute)})
+	}
+	require.NoError(t, db.Create(&rows).Error)
+
+	cases := []struct {
+		name   string
+		orders []Order
+		less   func(a, b cursorTestRow) bool
+	}{
+		{"score desc", []Order{{"Score", OrderOpDesc}}, func(a, b cursorTestRow) bool {
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+			return a.ID > b.ID
+		}},
+		{"score asc", []Order{{"Score", OrderOpAsc}}, func(a, b cursorTestRow) bool {
+			if a.Score != b.Score {
+				return a.Score < b.Score
+			}
+			return a.ID < b.ID
+		}},
+		{"created desc, score asc", []Order{{"CreatedAt", OrderOpDesc}, {"Score", OrderOpAsc}}, func(a, b cursorTestRow) bool {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			if a.Score != b.Score {
+				return a.Score < b.Score
+			}
+			return a.ID < b.ID
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			k, err := NewKeyset(db, &cursorTestRow{}, tc.orders)
+			require.NoError(t, err)
+			want := append([]cursorTestRow(nil), rows...)
+			sort.Slice(want, func(i, j int) bool { return tc.less(want[i], want[j]) })
+			var wantIDs []uint
+			for _, row := range want {
+				wantIDs = append(wantIDs, row.ID)
+			}
+			for _, limit := range []int{1, 2, 5, 23} {
+				assert.Equal(t, wantIDs, pageAll(t, db, k, limit), "limit %d", limit)
+			}
+		})
+	}
+}
+
+func TestKeysetPrimaryKeyOrder(t *testing.T) {
+	db := newCursorTestDB(t)
+	k, err := NewKeyset(db, &cursorTestRow{}, []Order{{"ID", OrderOpDesc}, {"Score", OrderOpAsc}})
+	require.NoError(t, err)
+	// 按主键排序后的排序列不再需要
+	assert.Equal(t, []string{"id"}, k.Columns())
+}
+
+func TestKeysetInvalidCursor(t *testing.T) {
+	db := newCursorTestDB(t)
+	k, err := NewKeyset(db, &cursorTestRow{}, []Order{{"Score", OrderOpDesc}})
+	require.NoError(t, err)
+	other, err := NewKeyset(db, &cursorTestRow{}, []Order{{"CreatedAt", OrderOpDesc}, {"Score", OrderOpDesc}})
+	require.NoError(t, err)
+	fromOther, err := other.Cursor(db, &cursorTestRow{ID: 1, Score: 2, CreatedAt: time.Now()})
+	require.NoError(t, err)
+
+	for name, cursor := range map[string]string{
+		"not base64":      "!!!",
+		"not json":        base64.RawURLEncoding.EncodeToString([]byte("nope")),
+		"not an array":    base64.RawURLEncoding.EncodeToString([]byte(`{"score":1}`)),
+		"too few values":  base64.RawURLEncoding.EncodeToString([]byte(`[1]`)),
+		"wrong type":      base64.RawURLEncoding.EncodeToString([]byte(`["high",1]`)),
+		"other order":     fromOther,
+		"padded encoding": base64.URLEncoding.EncodeToString([]byte(`[1,1]`)),
+	} {
+		_, err := k.Apply(db.Model(&cursorTestRow{}), cursor)
+		assert.ErrorIs(t, err, ErrInvalidCursor, name)
+	}
+
+	_, err = NewKeyset(db, &cursorTestRow{}, []Order{{"Name", OrderOpAsc}})
+	assert.ErrorIs(t, err, ErrInvalidCursor, "unindexed column")
+	_, err = NewKeyset(db, &cursorTestRow{}, []Order{{"Missing", OrderOpAsc}})
+	assert.ErrorIs(t, err, ErrInvalidCursor, "unknown column")
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	constants "HibiscusIM/pkg/constant"
+	"HibiscusIM/pkg/middleware"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestQuestionnaireImportRequiresTitle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "admin.db")), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Questionnaire{}, &models.AdminImport{}, &models.User{}, &models.Group{}, &models.GroupMember{}, &util.Config{}))
+	stores.SetDefault(&stores.LocalStore{Root: t.TempDir(), NewDirPerm: 0755})
+	t.Cleanup(func() { stores.SetDefault(nil) })
+
+	r := gin.New()
+	r.Use(middleware.WithMemSession("admin-test"), func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		c.Set(constants.UserField, &models.User{ID: 1, IsStaff: true, IsSuperUser: true})
+		c.Next()
+	})
+	h := &Handlers{db: db}
+	h.RegisterAdmin(r.Group("/admin"))
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "questionnaires.csv")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte("title,description\nWeekly check-in,ok\n,missing title\n"))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/admin/questionnaire/_import", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var uploaded struct {
+		Import models.AdminImport `json:"import"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &uploaded))
+
+	req = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/admin/questionnaire/_import/%d/preview", uploaded.Import.ID), bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var preview models.AdminImportPreview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, 1, preview.Import.ValidRows)
+	require.Len(t, preview.Errors, 1)
+	assert.Equal(t, models.AdminImportRowError{Row: 3, Field: "title", Message: "required"}, preview.Errors[0])
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/scheduler"
+	"HibiscusIM/pkg/sse"
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// announcementDeliverBatch 每批投递的接收人数
+	announcementDeliverBatch = 500
+	// maxActiveAnnouncements 用户端一次返回的未关闭公告数
+	maxActiveAnnouncements = 20
+)
+
+// announcementPayload WS/SSE 下发给客户端的公告内容
+type announcementPayload struct {
+	ID       uint   `json:"id"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	Severity string `json:"severity"`
+	CTAText  string `json:"ctaText,omitempty"`
+	CTAURL   string `json:"ctaUrl,omitempty"`
+}
+
+// announcementDeliverer 按接收范围分批投递公告：写入收到记录与站内通知，并通过 WS/SSE 推送给在线用户
+type announcementDeliverer struct {
+	db     *gorm.DB
+	wsHub  *websocket.Hub
+	sseHub *sse.Hub
+}
+
+// deliver 认领并投递公告，已被认领或已取消的公告直接跳过；投递失败时退回排期状态由下次调度重试，
+// 收到记录按用户去重，重试不会重复通知已收到的用户
+func (d *announcementDeliverer) deliver(ctx context.Context, id uint) error {
+	claimed, err := models.ClaimAnnouncement(d.db, id)
+	if err != nil || !claimed {
+		return err
+	}
+	if err := d.send(ctx, id); err != nil {
+		d.db.Model(&models.Announcement{}).Where("id = ?", id).
+			Updates(map[string]any{"status": models.AnnouncementScheduled, "scheduled_at": time.Now().UTC()})
+		return err
+	}
+	return nil
+}
+
+// send 解析接收人并分批投递已认领的公告
+func (d *announcementDeliverer) send(ctx context.Context, id uint) error {
+	var a models.Announcement
+	if err := d.db.WithContext(ctx).First(&a, id).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	recipients, err := models.ResolveAnnouncementRecipients(ctx, d.db, a.Target, now)
+	if err != nil {
+		return err
+	}
+	payload := announcementPayload{ID: a.ID, Title: a.Title, Body: a.Body, Severity: a.Severity, CTAText: a.CTAText, CTAURL: a.CTAURL}
+	for start := 0; start < len(recipients); start += announcementDeliverBatch {
+		batch := recipients[start:min(start+announcementDeliverBatch, len(recipients))]
+		created, err := models.CreateAnnouncementReceipts(d.db.WithContext(ctx), a.ID, batch, now)
+		if err != nil {
+			return err
+		}
+		d.notify(ctx, &a, payload, created, now)
+	}
+	return models.FinishAnnouncement(d.db, id, len(recipients), now)
+}
+
+// notify 为新收到公告的用户写入站内通知，并通过 WS/SSE 推送
+func (d *announcementDeliverer) notify(ctx context.Context, a *models.Announcement, payload announcementPayload, userIDs []uint, now time.Time) {
+	if len(userIDs) == 0 {
+		return
+	}
+	items := make([]notification.InternalNotification, len(userIDs))
+	for i, uid := range userIDs {
+		items[i] = notification.InternalNotification{UserID: uid, Title: a.Title, Content: a.Body, CreatedAt: now}
+	}
+	if err := d.db.WithContext(ctx).CreateInBatches(items, announcementDeliverBatch).Error; err != nil {
+		logger.Warn("create announcement notifications failed", zap.Uint("id", a.ID), zap.Error(err))
+	} else if counter := notification.GetUnreadCounter(); counter != nil {
+		for _, uid := range userIDs {
+			counter.Incr(ctx, uid, 1)
+		}
+	}
+
+	data, _ := json.Marshal(gin.H{"type": websocket.MessageTypeAnnouncement, "data": payload})
+	for _, uid := range userIDs {
+		d.wsHub.SendToUser(strconv.FormatUint(uint64(uid), 10), &websocket.Message{
+			Type:      websocket.MessageTypeAnnouncement,
+			Data:      payload,
+			Timestamp: now.Unix(),
+		})
+		d.sseHub.SendToGroup(unreadStreamGroup(uid), string(data))
+	}
+}
+
+// deliverDue 投递已到排期时间的公告
+func (d *announcementDeliverer) deliverDue(ctx context.Context, now time.Time) {
+	ids, err := models.DueAnnouncements(d.db.WithContext(ctx), now)
+	if err != nil {
+		logger.Warn("load due announcements failed", zap.Error(err))
+		return
+	}
+	for _, id := range ids {
+		if err := d.deliver(ctx, id); err != nil {
+			logger.Warn("deliver announcement failed", zap.Uint("id", id), zap.Error(err))
+		}
+	}
+}
+
+// startAnnouncementJobs 每 30 秒投递到期的排期公告
+func startAnnouncementJobs(d *announcementDeliverer) *scheduler.Scheduler {
+	s := scheduler.New()
+	s.Every(30*time.Second, scheduler.FuncJob(func(ctx context.Context) {
+		d.deliverDue(ctx, time.Now())
+	}))
+	return s
+}
+
+// deliverAsync 在后台立即投递公告
+func (d *announcementDeliverer) deliverAsync(id uint) {
+	go func() {
+		if err := d.deliver(context.Background(), id); err != nil {
+			logger.Warn("deliver announcement failed", zap.Uint("id", id), zap.Error(err))
+		}
+	}()
+}
+
+// announcementID 解析路径中的公告 ID
+func announcementID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "Invalid request", "invalid id")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// handleCreateAnnouncement 创建公告：指定 scheduledAt 时按时投递，draft 为 true 时只保存草稿，否则立即投递
+func (h *Handlers) handleCreateAnnouncement(c *gin.Context) {
+	var req struct {
+		Title       string                    `json:"title" binding:"required"`
+		Body        string                    `json:"body"`
+		Severity    string                    `json:"severity"`
+		CTAText     string                    `json:"ctaText"`
+		CTAURL      string                    `json:"ctaUrl"`
+		Target      models.AnnouncementTarget `json:"target"`
+		ScheduledAt *time.Time                `json:"scheduledAt"`
+		Draft       bool                      `json:"draft"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+	a := &models.Announcement{
+		Title:     req.Title,
+		Body:      req.Body,
+		Severity:  req.Severity,
+		CTAText:   req.CTAText,
+		CTAURL:    req.CTAURL,
+		Target:    req.Target,
+		Status:    models.AnnouncementDraft,
+		CreatedBy: models.CurrentUser(c).ID,
+	}
+	if err := a.Validate(); err != nil {
+		response.Fail(c, "Invalid request", err.Error())
+		return
+	}
+	if req.ScheduledAt != nil && !req.Draft {
+		if !req.ScheduledAt.After(time.Now()) {
+			response.Fail(c, "Invalid request", "scheduledAt must be in the future")
+			return
+		}
+		// 排期时间统一按 UTC 保存与比较
+		at := req.ScheduledAt.UTC()
+		a.ScheduledAt = &at
+		a.Status = models.AnnouncementScheduled
+	}
+	if err := h.db.Create(a).Error; err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	if a.Status == models.AnnouncementDraft && !req.Draft {
+		h.announcements.deliverAsync(a.ID)
+	}
+	response.Success(c, "success", a)
+}
+
+// handleListAnnouncements 公告列表，新建的在前，query: status 按状态过滤
+func (h *Handlers) handleListAnnouncements(c *gin.Context) {
+	q := h.db.Model(&models.Announcement{}).Order("id DESC").Limit(200)
+	if status := c.Query("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var list []models.Announcement
+	if err := q.Find(&list).Error; err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", list)
+}
+
+// handleSendAnnouncement 立即投递草稿或排期中的公告
+func (h *Handlers) handleSendAnnouncement(c *gin.Context) {
+	id, ok := announcementID(c)
+	if !ok {
+		return
+	}
+	var a models.Announcement
+	if err := h.db.First(&a, id).Error; err != nil {
+		response.AbortWithStatusJSON(c, http.StatusNotFound, err)
+		return
+	}
+	if a.Status != models.AnnouncementDraft && a.Status != models.AnnouncementScheduled {
+		response.Fail(c, "Invalid request", "announcement is "+a.Status)
+		return
+	}
+	h.announcements.deliverAsync(id)
+	response.Success(c, "success", nil)
+}
+
+// handleCancelAnnouncement 取消尚未投递的公告
+func (h *Handlers) handleCancelAnnouncement(c *gin.Context) {
+	id, ok := announcementID(c)
+	if !ok {
+		return
+	}
+	cancelled, err := models.CancelAnnouncement(h.db, id)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !cancelled {
+		response.Fail(c, "Invalid request", "announcement not found or already delivered")
+		return
+	}
+	response.Success(c, "success", nil)
+}
+
+// handleAnnouncementReach 公告的接收、查看与关闭人数
+func (h *Handlers) handleAnnouncementReach(c *gin.Context) {
+	id, ok := announcementID(c)
+	if !ok {
+		return
+	}
+	reach, err := models.GetAnnouncementReach(h.db, id)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", reach)
+}
+
+// handleActiveAnnouncements 当前用户收到且尚未关闭的公告
+func (h *Handlers) handleActiveAnnouncements(c *gin.Context) {
+	user := models.CurrentUser(c)
+	items, err := models.ActiveAnnouncements(h.db, user.ID, maxActiveAnnouncements)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", items)
+}
+
+// handleMarkAnnouncement 标记当前用户已查看公告，dismiss 为 true 时同时关闭
+func (h *Handlers) handleMarkAnnouncement(dismiss bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := announcementID(c)
+		if !ok {
+			return
+		}
+		user := models.CurrentUser(c)
+		err := models.MarkAnnouncementSeen(h.db, id, user.ID, dismiss, time.Now())
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.AbortWithStatusJSON(c, http.StatusNotFound, errors.New("announcement not found"))
+			return
+		}
+		if err != nil {
+			response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+			return
+		}
+		response.Success(c, "success", nil)
+	}
+}
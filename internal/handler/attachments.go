@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	hibiscusIM "HibiscusIM"
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/queue"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/scanner"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"HibiscusIM/pkg/websocket"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// taskTypeFileScan 文件内容扫描任务
+const taskTypeFileScan = "file.scan"
+
+// maxAttachmentSize 单个附件大小上限
+const maxAttachmentSize = 50 << 20
+
+// maxImageSize 图片附件大小上限，图片需要解码生成缩略图
+const maxImageSize = 20 << 20
+
+// fileScanTask 扫描已上传的附件或录音
+type fileScanTask struct {
+	Kind string `json:"kind"`
+	ID   uint   `json:"id"`
+}
+
+// Type 实现 queue.Task
+func (fileScanTask) Type() string {
+	return taskTypeFileScan
+}
+
+// initFileScan 注册文件扫描任务处理器，扫描未通过时的站内通知，以及附件扫描通过后向会话推送
+func initFileScan(db *gorm.DB, wsHub *websocket.Hub) {
+	if q := queue.GetGlobalQueue(); q != nil {
+		q.Register(taskTypeFileScan, func(ctx context.Context, msg *queue.Message) error {
+			var task fileScanTask
+			if err := msg.Decode(&task); err != nil {
+				return err
+			}
+			err := scanFile(ctx, db, task.Kind, task.ID)
+			if err != nil && msg.Attempt >= msg.MaxRetry {
+				// 最后一次重试仍失败，标记失败并保持不可下载
+				if mErr := models.MarkScanFailed(db, task.Kind, task.ID, err.Error()); mErr != nil {
+					logger.Warn("mark scan failed error", zap.Error(mErr))
+				}
+			}
+			return err
+		})
+	}
+
+	util.Sig().Connect(models.SigFileQuarantined, func(sender any, params ...any) {
+		if len(params) < 3 {
+			return
+		}
+		kind, _ := params[0].(string)
+		userID, _ := params[1].(uint)
+		res, _ := params[2].(scanner.Result)
+		name, _ := sender.(string)
+		content := fmt.Sprintf("您上传的%s「%s」未通过安全检查（%s），已被隔离，无法下载。", scanTargetName(kind), name, res.Signature)
+		if err := notification.NewInternalNotificationService(db).Send(userID, "文件已被隔离", content); err != nil {
+			logger.Warn("send quarantine notification failed", zap.Error(err))
+		}
+	})
+
+	util.Sig().Connect(models.SigFileClean, func(sender any, params ...any) {
+		if len(params) < 2 || params[0] != models.ScanTargetAttachment {
+			return
+		}
+		id, _ := params[1].(uint)
+		var attachment models.Attachment
+		if err := db.First(&attachment, id).Error; err != nil || attachment.Conversation == "" {
+			return
+		}
+		withAttachmentURLs(&attachment)
+		wsHub.NotifyAttachment(attachment.Conversation, strconv.FormatUint(uint64(attachment.UserID), 10), attachment)
+	})
+}
+
+func scanTargetName(kind string) string {
+	if kind == models.ScanTargetRecording {
+		return "录音"
+	}
+	return "附件"
+}
+
+// enqueueFileScan 投递扫描任务，未启用任务队列时直接异步执行
+func enqueueFileScan(db *gorm.DB, kind string, id uint) {
+	task := fileScanTask{Kind: kind, ID: id}
+	if q := queue.GetGlobalQueue(); q != nil {
+		_, err := q.Enqueue(context.Background(), task)
+		if err == nil {
+			return
+		}
+		logger.Warn("enqueue file scan failed, scanning inline", zap.Error(err))
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		if err := scanFile(ctx, db, kind, id); err != nil {
+			logger.Warn("scan file failed", zap.String("kind", kind), zap.Uint("id", id), zap.Error(err))
+			models.MarkScanFailed(db, kind, id, err.Error())
+		}
+	}()
+}
+
+// scanFile 读取对象存储中的文件并扫描，返回错误时由队列重试
+func scanFile(ctx context.Context, db *gorm.DB, kind string, id uint) error {
+	var key, name string
+	var userID uint
+	switch kind {
+	case models.ScanTargetAttachment:
+		var a models.Attachment
+		if err := db.First(&a, id).Error; err != nil {
+			return err
+		}
+		key, name, userID = a.ObjectKey, a.Name, a.UserID
+	case models.ScanTargetRecording:
+		var r models.Recording
+		if err := db.First(&r, id).Error; err != nil {
+			return err
+		}
+		key, name, userID = r.ObjectKey, r.FileURL, r.UserID
+	default:
+		return fmt.Errorf("unknown scan target: %s", kind)
+	}
+
+	rc, _, err := stores.Default().Read(key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	res, err := scanner.GetGlobalScanner().Scan(ctx, rc)
+	if err != nil {
+		return err
+	}
+	state, err := models.UpdateScanResult(db, kind, id, res)
+	if err != nil {
+		return err
+	}
+	switch state.ScanStatus {
+	case models.ScanStatusQuarantined:
+		logger.Warn("file quarantined", zap.String("kind", kind), zap.Uint("id", id), zap.String("signature", res.Signature))
+		util.Sig().Emit(models.SigFileQuarantined, name, kind, userID, res)
+	case models.ScanStatusClean:
+		if kind == models.ScanTargetAttachment {
+			// 缩略图失败不影响下载，客户端回退为展示原图
+			if err := generateAttachmentThumbnail(db, id); err != nil {
+				logger.Warn("generate attachment thumbnail failed", zap.Uint("id", id), zap.Error(err))
+			}
+		}
+		util.Sig().Emit(models.SigFileClean, name, kind, id)
+	}
+	return nil
+}
+
+// handleUploadAttachment 上传附件，扫描通过前不可下载也不返回访问地址。类型按文件内容识别，图片在扫描通过后生成缩略图；
+// 指定 conversation 时需有该会话的发言权限，扫描通过后向会话成员推送 attachment 消息
+func (h *Handlers) handleUploadAttachment(c *gin.Context) {
+	user := models.CurrentUser(c)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if file.Size > maxAttachmentSize {
+		hibiscusIM.AbortWithJSONError(c, http.StatusRequestEntityTooLarge, errors.New("attachment too large"))
+		return
+	}
+	conversation := c.PostForm("conversation")
+	if conversation != "" {
+		if err := h.authorizeConversation(conversation, strconv.FormatUint(uint64(user.ID), 10), true); err != nil {
+			hibiscusIM.AbortWithJSONError(c, http.StatusForbidden, err)
+			return
+		}
+	}
+	f, err := file.Open()
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	defer f.Close()
+
+	contentType, err := detectContentType(f, file.Filename)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	_, isImage := thumbnailTypes[contentType]
+	if isImage && file.Size > maxImageSize {
+		hibiscusIM.AbortWithJSONError(c, http.StatusRequestEntityTooLarge, errors.New("image too large"))
+		return
+	}
+
+	before, err := h.storageQuota.Check(h.db, user.ID, models.StorageAttachments, file.Size)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, storageQuotaStatus(err), err)
+		return
+	}
+
+	store := stores.Default()
+	ext := filepath.Ext(file.Filename)
+	base := fmt.Sprintf("attachments/%d/%d_%s", user.ID, time.Now().UnixNano(), util.RandText(8))
+	if err := store.Write(base+ext, f); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	attachment := models.Attachment{
+		UserID:       user.ID,
+		Conversation: conversation,
+		Name:         filepath.Base(file.Filename),
+		ObjectKey:    base + ext,
+		ContentType:  contentType,
+		SizeBytes:    file.Size,
+		ScanState:    models.ScanState{ScanStatus: models.ScanStatusPending},
+	}
+	if err := h.db.Create(&attachment).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	enqueueFileScan(h.db, models.ScanTargetAttachment, attachment.ID)
+	h.notifyStorageQuota(user.ID, before, models.StorageAttachments, attachment.SizeBytes)
+	withAttachmentURLs(&attachment)
+	response.Success(c, "attachment uploaded, scanning", attachment)
+}
+
+// handleDeleteAttachment 删除附件及其缩略图，释放的空间从上传者的用量中扣减
+func (h *Handlers) handleDeleteAttachment(c *gin.Context) {
+	attachment, ok := h.loadOwnedAttachment(c)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(attachment).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	store := stores.Default()
+	for _, key := range []string{attachment.ObjectKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := store.Delete(key); err != nil {
+			logger.Warn("delete attachment object failed", zap.String("key", key), zap.Error(err))
+		}
+	}
+	response.Success(c, "attachment deleted", nil)
+}
+
+// detectContentType 按文件头识别类型，无法识别时按扩展名判断；扩展名不能把文件声明为图片或网页
+func detectContentType(f io.ReadSeeker, filename string) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	contentType := http.DetectContentType(head[:n])
+	if contentType != "application/octet-stream" {
+		return contentType, nil
+	}
+	byExt, _, _ := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(filename)))
+	if byExt == "" || strings.HasPrefix(byExt, "image/") || byExt == "text/html" {
+		return contentType, nil
+	}
+	return byExt, nil
+}
+
+// generateAttachmentThumbnail 读取扫描通过的图片附件的尺寸并生成缩略图，未扫描通过的文件不解码
+func generateAttachmentThumbnail(db *gorm.DB, id uint) error {
+	var attachment models.Attachment
+	if err := db.First(&attachment, id).Error; err != nil {
+		return err
+	}
+	if _, ok := thumbnailTypes[attachment.ContentType]; !ok || !attachment.Downloadable() || attachment.ThumbnailKey != "" {
+		return nil
+	}
+	store := stores.Default()
+	rc, _, err := store.Read(attachment.ObjectKey)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxImageSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxImageSize {
+		return errImageTooLarge
+	}
+	key := strings.TrimSuffix(attachment.ObjectKey, filepath.Ext(attachment.ObjectKey)) + "_thumb.jpg"
+	if err := writeThumbnail(store, bytes.NewReader(data), &attachment, key); err != nil {
+		return err
+	}
+	return db.Model(&attachment).Updates(map[string]any{
+		"width":         attachment.Width,
+		"height":        attachment.Height,
+		"thumbnail_key": attachment.ThumbnailKey,
+	}).Error
+}
+
+// writeThumbnail 读取图片尺寸并生成缩略图写入 key
+func writeThumbnail(store stores.Store, f io.ReadSeeker, attachment *models.Attachment, key string) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	width, height, err := imageSize(f)
+	if err != nil {
+		return err
+	}
+	attachment.Width, attachment.Height = width, height
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	thumb, err := makeThumbnail(f, attachment.ContentType, width, height)
+	if err != nil {
+		return err
+	}
+	if err := store.Write(key, bytes.NewReader(thumb)); err != nil {
+		return err
+	}
+	attachment.ThumbnailKey = key
+	return nil
+}
+
+// withAttachmentURLs 填充附件及缩略图在对象存储中的访问地址，扫描通过前不返回地址
+func withAttachmentURLs(attachment *models.Attachment) {
+	if !attachment.Downloadable() {
+		return
+	}
+	store := stores.Default()
+	attachment.URL = store.PublicURL(attachment.ObjectKey)
+	if attachment.ThumbnailKey != "" {
+		attachment.ThumbnailURL = store.PublicURL(attachment.ThumbnailKey)
+	}
+}
+
+// handleGetAttachment 获取附件信息及扫描状态
+func (h *Handlers) handleGetAttachment(c *gin.Context) {
+	attachment, ok := h.loadOwnedAttachment(c)
+	if !ok {
+		return
+	}
+	withAttachmentURLs(attachment)
+	response.Success(c, "success", attachment)
+}
+
+// handleDownloadAttachment 下载附件，仅扫描通过后允许
+func (h *Handlers) handleDownloadAttachment(c *gin.Context) {
+	attachment, ok := h.loadOwnedAttachment(c)
+	if !ok {
+		return
+	}
+	if !attachment.Downloadable() {
+		hibiscusIM.AbortWithJSONError(c, scanStatusCode(attachment.ScanStatus), fmt.Errorf("attachment not downloadable: %s", attachment.ScanStatus))
+		return
+	}
+	rc, size, err := stores.Default().Read(attachment.ObjectKey)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusNotFound, util.ErrAttachmentNotExist)
+		return
+	}
+	defer rc.Close()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.Name))
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, io.LimitReader(rc, size), nil)
+}
+
+// loadOwnedAttachment 按ID读取附件，仅上传者或管理员可访问
+func (h *Handlers) loadOwnedAttachment(c *gin.Context) (*models.Attachment, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return nil, false
+	}
+	var attachment models.Attachment
+	if err := h.db.First(&attachment, id).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusNotFound, util.ErrAttachmentNotExist)
+		return nil, false
+	}
+	user := models.CurrentUser(c)
+	if attachment.UserID != user.ID && !user.IsStaff && !user.IsSuperUser {
+		hibiscusIM.AbortWithJSONError(c, http.StatusForbidden, util.ErrNotAttachmentOwner)
+		return nil, false
+	}
+	return &attachment, true
+}
+
+// scanStatusCode 不可下载时的响应状态码：扫描中 409，已隔离 403
+func scanStatusCode(status string) int {
+	switch status {
+	case models.ScanStatusQuarantined, models.ScanStatusFailed:
+		return http.StatusForbidden
+	default:
+		return http.StatusConflict
+	}
+}
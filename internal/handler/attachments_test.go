@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	stores "HibiscusIM/pkg/storage"
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestDetectContentType(t *testing.T) {
+	pngData := encodeTestPNG(t, 2, 2)
+	cases := []struct {
+		name     string
+		data     []byte
+		filename string
+		want     string
+	}{
+		{"png by content", pngData, "photo.bin", "image/png"},
+		{"png with wrong extension", pngData, "report.pdf", "image/png"},
+		{"html disguised as image", []byte("<html><script>alert(1)</script></html>"), "cat.png", "text/html; charset=utf-8"},
+		{"plain text", []byte("hello world"), "notes.txt", "text/plain; charset=utf-8"},
+		{"unknown binary by extension", []byte{0x00, 0x01, 0x02, 0xfe}, "archive.7z", "application/x-7z-compressed"},
+		{"extension cannot claim image", []byte{0x00, 0x01, 0x02, 0xfe}, "fake.png", "application/octet-stream"},
+		{"extension cannot claim html", []byte{0x00, 0x01, 0x02, 0xfe}, "page.html", "application/octet-stream"},
+		{"unknown extension", []byte{0x00, 0x01, 0x02, 0xfe}, "blob", "application/octet-stream"},
+		{"empty file", nil, "empty.txt", "text/plain; charset=utf-8"},
+	}
+	for _, tc := range cases {
+		r := bytes.NewReader(tc.data)
+		got, err := detectContentType(r, tc.filename)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+		// 识别后回到文件开头，后续写入完整内容
+		pos, _ := r.Seek(0, io.SeekCurrent)
+		assert.Zero(t, pos, tc.name)
+	}
+}
+
+func newAttachmentTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "attachments.db")), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Attachment{}, &models.StorageUsage{}))
+	stores.SetDefault(&stores.LocalStore{Root: t.TempDir(), NewDirPerm: 0755})
+	t.Cleanup(func() { stores.SetDefault(nil) })
+	return db
+}
+
+func createTestAttachment(t *testing.T, db *gorm.DB, key, contentType string, data []byte, status string) *models.Attachment {
+	require.NoError(t, stores.Default().Write(key, bytes.NewReader(data)))
+	a := &models.Attachment{
+		UserID:      1,
+		Name:        filepath.Base(key),
+		ObjectKey:   key,
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+		ScanState:   models.ScanState{ScanStatus: status},
+	}
+	require.NoError(t, db.Create(a).Error)
+	return a
+}
+
+func TestGenerateAttachmentThumbnail(t *testing.T) {
+	db := newAttachmentTestDB(t)
+
+	// 扫描通过前不解码也不生成缩略图
+	pending := createTestAttachment(t, db, "attachments/1/pending.png", "image/png", encodeTestPNG(t, 640, 480), models.ScanStatusPending)
+	require.NoError(t, generateAttachmentThumbnail(db, pending.ID))
+	require.NoError(t, db.First(pending, pending.ID).Error)
+	assert.Empty(t, pending.ThumbnailKey)
+	assert.Zero(t, pending.Width)
+
+	clean := createTestAttachment(t, db, "attachments/1/clean.png", "image/png", encodeTestPNG(t, 640, 480), models.ScanStatusClean)
+	require.NoError(t, generateAttachmentThumbnail(db, clean.ID))
+	require.NoError(t, db.First(clean, clean.ID).Error)
+	assert.Equal(t, "attachments/1/clean_thumb.jpg", clean.ThumbnailKey)
+	assert.Equal(t, 640, clean.Width)
+	assert.Equal(t, 480, clean.Height)
+
+	rc, _, err := stores.Default().Read(clean.ThumbnailKey)
+	require.NoError(t, err)
+	defer rc.Close()
+	thumb, err := jpeg.Decode(rc)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, thumbnailSize, thumbnailSize*480/640), thumb.Bounds())
+
+	// 小图不放大
+	small := createTestAttachment(t, db, "attachments/1/small.png", "image/png", encodeTestPNG(t, 40, 20), models.ScanStatusClean)
+	require.NoError(t, generateAttachmentThumbnail(db, small.ID))
+	require.NoError(t, db.First(small, small.ID).Error)
+	rc2, _, err := stores.Default().Read(small.ThumbnailKey)
+	require.NoError(t, err)
+	defer rc2.Close()
+	cfg, _, err := image.DecodeConfig(rc2)
+	require.NoError(t, err)
+	assert.Equal(t, 40, cfg.Width)
+	assert.Equal(t, 20, cfg.Height)
+
+	// 非图片不生成缩略图，损坏的图片返回错误
+	doc := createTestAttachment(t, db, "attachments/1/doc.pdf", "application/pdf", []byte("%PDF-1.4"), models.ScanStatusClean)
+	require.NoError(t, generateAttachmentThumbnail(db, doc.ID))
+	broken := createTestAttachment(t, db, "attachments/1/broken.png", "image/png", []byte("\x89PNG\r\n\x1a\nbroken"), models.ScanStatusClean)
+	assert.Error(t, generateAttachmentThumbnail(db, broken.ID))
+}
+
+func TestWithAttachmentURLsRequiresCleanScan(t *testing.T) {
+	newAttachmentTestDB(t)
+	for _, status := range []string{models.ScanStatusPending, models.ScanStatusQuarantined, models.ScanStatusFailed} {
+		a := &models.Attachment{ObjectKey: "attachments/1/a.png", ThumbnailKey: "attachments/1/a_thumb.jpg", ScanState: models.ScanState{ScanStatus: status}}
+		withAttachmentURLs(a)
+		assert.Empty(t, a.URL, status)
+		assert.Empty(t, a.ThumbnailURL, status)
+	}
+	a := &models.Attachment{ObjectKey: "attachments/1/a.png", ThumbnailKey: "attachments/1/a_thumb.jpg", ScanState: models.ScanState{ScanStatus: models.ScanStatusClean}}
+	withAttachmentURLs(a)
+	assert.Contains(t, a.URL, "attachments/1/a.png")
+	assert.Contains(t, a.ThumbnailURL, "attachments/1/a_thumb.jpg")
+}
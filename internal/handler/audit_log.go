@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/util"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errAuditLogReadOnly = errors.New("audit logs are read-only")
+
+// initAuditLog 按 AUDIT_LOG_ENABLED 启用合规审计日志，只记录通过 h.audit 开启审计的路由。
+// AUDIT_LOG_BODY_LIMIT 为请求体与响应体的最大记录字节数（默认 8KB），
+// AUDIT_LOG_REDACT_FIELDS 为逗号分隔的额外脱敏字段，password、token 等凭证字段始终脱敏
+func initAuditLog(db *gorm.DB) *middleware.AuditLogger {
+	if !util.GetBoolEnv("AUDIT_LOG_ENABLED") {
+		return nil
+	}
+	var fields []string
+	for _, f := range strings.Split(util.GetEnv("AUDIT_LOG_REDACT_FIELDS"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return middleware.NewAuditLogger(db, middleware.AuditLogConfig{
+		FlushInterval: time.Second,
+		BodyLimit:     int(util.GetIntEnv("AUDIT_LOG_BODY_LIMIT")),
+		RedactFields:  fields,
+		User:          operationLogUser,
+	})
+}
+
+// audit 为路由开启审计，未启用审计日志时直接放行
+func (h *Handlers) audit(action string) gin.HandlerFunc {
+	return h.auditLog.Handler(action)
+}
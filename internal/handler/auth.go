@@ -10,7 +10,9 @@ import (
 	"HibiscusIM/pkg/response"
 	"HibiscusIM/pkg/util"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -103,16 +105,12 @@ func (h *Handlers) handleUserSigninByEmail(c *gin.Context) {
 		return
 	}
 
-	// 从缓存中获取验证码（假设你使用的是 util.GlobalCache）
-	cachedCode, ok := util.GlobalCache.Get(form.Email)
-	if !ok || cachedCode != form.Code {
-		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, errors.New("invalid verification code"))
+	// 校验验证码，成功后验证码立即失效
+	if err := h.emailCodes.Verify(form.Email, form.Code); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	// 清除已用验证码
-	util.GlobalCache.Remove(form.Email)
-
 	// 检查用户是否允许登录（激活、启用等）
 	err = models.CheckUserAllowLogin(db, user)
 	if err != nil {
@@ -226,6 +224,10 @@ func (h *Handlers) handleUserSignup(c *gin.Context) {
 		return
 	}
 
+	// 客户端上报的时区无效时不保存，按服务器时区处理
+	if form.Timezone != "" && !models.ValidTimezone(form.Timezone) {
+		form.Timezone = ""
+	}
 	vals := util.StructAsMap(form, []string{
 		"DisplayName",
 		"FirstName",
@@ -279,22 +281,22 @@ func (h *Handlers) handleUserSignupByEmail(c *gin.Context) {
 		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, errors.New("email has exists"))
 		return
 	}
-	// 从缓存中获取验证码（假设你使用的是 util.GlobalCache）
-	cachedCode, ok := util.GlobalCache.Get(form.Email)
-	if !ok || cachedCode != form.Code {
-		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, errors.New("invalid verification code"))
+	// 校验验证码，成功后验证码立即失效
+	if err := h.emailCodes.Verify(form.Email, form.Code); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	// 清除已用验证码
-	util.GlobalCache.Remove(form.Email)
-
 	user, err := models.CreateUser(db, form.Email, "123456789")
 	if err != nil {
 		logger.Warn("create user failed", zap.Any("email", form.Email), zap.Error(err))
 		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
 		return
 	}
+	// 客户端上报的时区无效时不保存，按服务器时区处理
+	if form.Timezone != "" && !models.ValidTimezone(form.Timezone) {
+		form.Timezone = ""
+	}
 	vals := util.StructAsMap(form, []string{
 		"DisplayName",
 		"FirstName",
@@ -358,6 +360,10 @@ func (h *Handlers) handleUserUpdate(c *gin.Context) {
 		vals["locale"] = req.Locale
 	}
 	if req.Timezone != "" {
+		if !models.ValidTimezone(req.Timezone) {
+			response.Fail(c, "Invalid timezone", models.ErrInvalidTimezone)
+			return
+		}
 		vals["timezone"] = req.Timezone
 	}
 	if req.Gender != "" {
@@ -461,15 +467,60 @@ func (h *Handlers) handleSendEmailCode(context *gin.Context) {
 	}
 	req.UserAgent = context.Request.UserAgent()
 	req.ClientIp = context.ClientIP()
-	text := util.RandNumberText(6)
-	util.GlobalCache.Add(req.Email, text)
+	text, reportToken, err := h.emailCodes.Issue(req.Email, req.ClientIp)
+	if err != nil {
+		logger.Warn("email code throttled", zap.String("email", req.Email), zap.String("ip", req.ClientIp), zap.Error(err))
+		hibiscusIM.AbortWithJSONError(context, http.StatusTooManyRequests, err)
+		return
+	}
 	go func() {
-		err := notification.NewMailNotification(config.GlobalConfig.Mail).SendVerificationCode(req.Email, text)
+		err := notification.NewMailNotification(config.GlobalConfig.Mail).SendVerificationCodeWithReport(req.Email, text, reportToken)
 		if err != nil {
-			hibiscusIM.AbortWithJSONError(context, http.StatusBadRequest, err)
-			return
+			logger.Warn("send verification code failed", zap.String("email", req.Email), zap.Error(err))
 		}
 	}()
-	response.Success(context, "success", "Send Email Successful, Must be verified within the valid time [5 minutes]")
-	return
+	response.Success(context, "success", fmt.Sprintf("Send Email Successful, Must be verified within the valid time [%s]", h.emailCodes.TTL()))
+}
+
+// handleReportEmailAbuse 收件人凭验证码邮件中的一次性令牌举报未请求的邮件，举报后暂停向该邮箱发送验证码
+func (h *Handlers) handleReportEmailAbuse(c *gin.Context) {
+	var req struct {
+		Email  string `json:"email" binding:"required"`
+		Token  string `json:"token" binding:"required"`
+		Reason string `json:"reason" binding:"max=500"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	report, err := h.emailCodes.Report(h.db, req.Email, req.Token, req.Reason, c.ClientIP())
+	if errors.Is(err, models.ErrEmailReportInvalid) {
+		hibiscusIM.AbortWithJSONError(c, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		logger.Warn("save email abuse report failed", zap.String("email", req.Email), zap.Error(err))
+		response.Fail(c, "report failed", nil)
+		return
+	}
+	logger.Warn("email code abuse reported", zap.String("email", report.Email), zap.String("requester_ips", report.RequesterIPs))
+	response.Success(c, "report received", nil)
+}
+
+// handleListEmailAbuse 查看滥用举报记录与当前限流状态（管理员）
+func (h *Handlers) handleListEmailAbuse(c *gin.Context) {
+	pos, _ := strconv.Atoi(c.DefaultQuery("pos", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	reports, err := models.ListEmailAbuseReports(h.db, pos, limit)
+	if err != nil {
+		response.Fail(c, "list reports failed", nil)
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"reports": reports,
+		"stats":   h.emailCodes.AbuseStats(10),
+	})
 }
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"HibiscusIM/pkg/config"
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/util"
+)
+
+const (
+	// defaultBodyLimit 普通 JSON 接口的请求体上限
+	defaultBodyLimit = 8 << 20
+	// adminBodyLimit 管理后台的请求体上限，CSV 导入文件最大 10MB
+	adminBodyLimit = 16 << 20
+	// searchBodyLimit 搜索接口的请求体上限，批量导入文档时请求体较大
+	searchBodyLimit = 64 << 20
+)
+
+// bodyLimitConfig 各路由组的请求体上限，MAX_REQUEST_BODY_MB 覆盖普通接口默认的 8MB；
+// 附件上传、管理后台导入与搜索批量导入使用各自的上限
+func bodyLimitConfig() middleware.BodyLimitConfig {
+	cfg := middleware.BodyLimitConfig{
+		Default: defaultBodyLimit,
+		Groups: map[string]int64{
+			config.GlobalConfig.APIPrefix + "/attachments": maxAttachmentSize + 1<<20,
+			config.GlobalConfig.APIPrefix + "/search":      searchBodyLimit,
+		},
+	}
+	if mb := util.GetIntEnv("MAX_REQUEST_BODY_MB"); mb > 0 {
+		cfg.Default = mb << 20
+	}
+	if config.GlobalConfig.AdminPrefix != "" {
+		cfg.Groups[config.GlobalConfig.APIPrefix+config.GlobalConfig.AdminPrefix] = adminBodyLimit
+	}
+	return cfg
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/util"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+var errCircuitBreakerNotFound = errors.New("circuit breaker not found")
+
+// initCircuitBreakers 创建下游依赖的熔断器注册表：CIRCUIT_BREAKER_FAILURE_RATE 为触发熔断的失败率（默认 0.5），
+// CIRCUIT_BREAKER_MIN_REQUESTS 为 10 秒窗口内开始判断的最少请求数（默认 20），
+// CIRCUIT_BREAKER_OPEN_SECONDS 为熔断后进入半开试探的等待秒数（默认 30）
+func initCircuitBreakers() *middleware.CircuitBreakerRegistry {
+	return middleware.NewCircuitBreakerRegistry(middleware.CircuitBreakerConfig{
+		FailureRate: cast.ToFloat64(util.GetEnv("CIRCUIT_BREAKER_FAILURE_RATE")),
+		MinRequests: int(util.GetIntEnv("CIRCUIT_BREAKER_MIN_REQUESTS")),
+		OpenTimeout: time.Duration(util.GetIntEnv("CIRCUIT_BREAKER_OPEN_SECONDS")) * time.Second,
+	})
+}
+
+// breaker 按下游依赖名称熔断路由
+func (h *Handlers) breaker(dependency string) gin.HandlerFunc {
+	return h.breakers.Handler(dependency)
+}
+
+// handleListCircuitBreakers 全部熔断器的状态与窗口内失败率
+func (h *Handlers) handleListCircuitBreakers(c *gin.Context) {
+	response.Success(c, "success", gin.H{"breakers": h.breakers.List()})
+}
+
+// handleResetCircuitBreaker 手动恢复熔断器，name 可能是包含斜杠的路由模板，因此放在请求体中
+func (h *Handlers) handleResetCircuitBreaker(c *gin.Context) {
+	var req struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+	if !h.breakers.Reset(req.Name) {
+		response.AbortWithStatusJSON(c, http.StatusNotFound, errCircuitBreakerNotFound)
+		return
+	}
+	models.RecordSystemEvent(models.SystemEventInfo, models.SystemEventAdmin, "circuit_breaker", "circuit breaker reset", map[string]any{"name": req.Name})
+	response.Success(c, "circuit breaker reset", h.breakers.Get(req.Name).Status())
+}
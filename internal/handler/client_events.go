@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/redact"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/websocket"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 批量上报的限制
+const (
+	maxClientEventBatch      = 200
+	maxClientEventProperties = 4 << 10
+	// 离线积攒的在线状态超过该时长不再生效
+	clientPresenceMaxAge = 2 * time.Minute
+	// 允许的客户端时钟超前
+	clientEventClockSkew = 5 * time.Minute
+)
+
+// 单个事件的处理结果
+const (
+	ClientEventAccepted  = "accepted"
+	ClientEventDuplicate = "duplicate"
+	ClientEventInvalid   = "invalid"
+	// 服务端处理失败，客户端可使用同一 ID 重新上报
+	ClientEventFailed = "failed"
+)
+
+// ClientEventForm 单个事件，ID 由客户端生成，同一用户的 ID 只处理一次
+type ClientEventForm struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// analytics：事件名与属性
+	Name       string         `json:"name,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+	// read：会话与已读到的消息ID
+	Conversation string `json:"conversation,omitempty"`
+	MessageID    int64  `json:"messageId,omitempty"`
+	// presence：online 或 away
+	Status string `json:"status,omitempty"`
+	// 事件发生时间（Unix 秒），为空时取服务端接收时间
+	OccurredAt int64 `json:"occurredAt,omitempty"`
+}
+
+// ClientEventBatchForm 客户端离线积攒后批量上报的事件
+type ClientEventBatchForm struct {
+	Platform string            `json:"platform"`
+	Events   []ClientEventForm `json:"events" binding:"required"`
+}
+
+// ClientEventResult 单个事件的处理结果
+type ClientEventResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// registerClientEventRoutes 注册客户端事件上报路由
+func (h *Handlers) registerClientEventRoutes(r *gin.RouterGroup) {
+	r.POST("/events/batch", models.AuthRequired, h.handleClientEventBatch)
+}
+
+// handleClientEventBatch 批量接收统计、已读回执与在线状态事件。每个事件单独校验并按 ID 去重，
+// 同一会话的已读回执只提交最大的消息ID，在线状态只应用最近一条且未过期的事件
+func (h *Handlers) handleClientEventBatch(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	var form ClientEventBatchForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.Fail(c, "invalid request", nil)
+		return
+	}
+	if len(form.Events) > maxClientEventBatch {
+		response.Fail(c, "too many events, max "+strconv.Itoa(maxClientEventBatch), nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	now := time.Now()
+	platform := form.Platform
+	if len(platform) > 32 {
+		platform = platform[:32]
+	}
+	results := make([]ClientEventResult, len(form.Events))
+	// 每个会话已读到的最大消息所在的事件下标，以及该会话被接受的全部事件
+	reads := make(map[string]int)
+	readEvents := make(map[string][]int)
+	presence, presenceAt := -1, time.Time{}
+	seen := make(map[string]bool, len(form.Events))
+
+	for i, ev := range form.Events {
+		results[i] = ClientEventResult{ID: ev.ID, Status: ClientEventInvalid}
+		if seen[ev.ID] {
+			results[i].Status = ClientEventDuplicate
+			continue
+		}
+		record, err := h.validateClientEvent(userID, &ev, now)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		seen[ev.ID] = true
+		record.UserID = user.ID
+		record.Platform = platform
+		claimed, err := models.ClaimClientEvent(ctx, h.db, record)
+		if err != nil {
+			logger.Warn("claim client event failed", zap.String("id", ev.ID), zap.Error(err))
+			results[i].Status = ClientEventFailed
+			continue
+		}
+		if !claimed {
+			results[i].Status = ClientEventDuplicate
+			continue
+		}
+		results[i].Status = ClientEventAccepted
+
+		switch ev.Type {
+		case models.ClientEventRead:
+			readEvents[ev.Conversation] = append(readEvents[ev.Conversation], i)
+			if j, ok := reads[ev.Conversation]; !ok || ev.MessageID > form.Events[j].MessageID {
+				reads[ev.Conversation] = i
+			}
+		case models.ClientEventPresence:
+			if presence < 0 || !record.OccurredAt.Before(presenceAt) {
+				presence, presenceAt = i, record.OccurredAt
+			}
+		}
+	}
+
+	for conversation, i := range reads {
+		if _, err := h.wsHub.MarkRead(conversation, userID, form.Events[i].MessageID); err != nil {
+			logger.Warn("apply read receipt failed", zap.String("conversation", conversation), zap.Error(err))
+			h.releaseClientEvents(c, user.ID, form.Events, results, readEvents[conversation])
+		}
+	}
+	if presence >= 0 && now.Sub(presenceAt) <= clientPresenceMaxAge {
+		h.wsHub.SetPresence(userID, form.Events[presence].Status)
+	}
+
+	accepted := 0
+	for _, r := range results {
+		if r.Status == ClientEventAccepted {
+			accepted++
+		}
+	}
+	response.Success(c, "success", gin.H{
+		"accepted": accepted,
+		"results":  results,
+	})
+}
+
+// validateClientEvent 校验事件并转换为待保存的记录
+func (h *Handlers) validateClientEvent(userID string, ev *ClientEventForm, now time.Time) (*models.ClientEvent, error) {
+	if ev.ID == "" || len(ev.ID) > 64 {
+		return nil, errors.New("id is required and must be at most 64 characters")
+	}
+	occurredAt := now
+	if ev.OccurredAt != 0 {
+		occurredAt = time.Unix(ev.OccurredAt, 0)
+		if occurredAt.After(now.Add(clientEventClockSkew)) {
+			return nil, errors.New("occurredAt is in the future")
+		}
+	}
+	record := &models.ClientEvent{EventID: ev.ID, Type: ev.Type, OccurredAt: occurredAt}
+
+	var properties map[string]any
+	switch ev.Type {
+	case models.ClientEventAnalytics:
+		name := strings.TrimSpace(ev.Name)
+		if name == "" || len(name) > 64 {
+			return nil, errors.New("name is required and must be at most 64 characters")
+		}
+		record.Name = name
+		properties = redact.Default().Map(ev.Properties)
+	case models.ClientEventRead:
+		if ev.MessageID <= 0 {
+			return nil, errors.New(websocket.ErrInvalidReadReceipt)
+		}
+		if err := h.authorizeConversation(ev.Conversation, userID, false); err != nil {
+			return nil, err
+		}
+		record.Name = ev.Conversation
+		properties = map[string]any{"messageId": ev.MessageID}
+	case models.ClientEventPresence:
+		if ev.Status != websocket.PresenceOnline && ev.Status != websocket.PresenceAway {
+			return nil, errors.New("status must be online or away")
+		}
+		record.Name = ev.Status
+	default:
+		return nil, errors.New("unknown event type")
+	}
+
+	if len(properties) > 0 {
+		data, err := json.Marshal(properties)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxClientEventProperties {
+			return nil, errors.New("properties too large")
+		}
+		record.Properties = string(data)
+	}
+	return record, nil
+}
+
+// releaseClientEvents 处理失败时删除幂等记录并标记为 failed，客户端可重新上报
+func (h *Handlers) releaseClientEvents(c *gin.Context, userID uint, events []ClientEventForm, results []ClientEventResult, indexes []int) {
+	ids := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		ids = append(ids, events[i].ID)
+		results[i].Status = ClientEventFailed
+	}
+	if err := models.ReleaseClientEvents(c.Request.Context(), h.db, userID, ids); err != nil {
+		logger.Warn("release client events failed", zap.Error(err))
+	}
+}
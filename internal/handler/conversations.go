@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/util"
+	"HibiscusIM/pkg/websocket"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+)
+
+// initConversations 配置会话已读游标存储，WS聊天消息分配ID并维护未读数
+func initConversations(db *gorm.DB, hub *websocket.Hub) *models.ConversationStore {
+	store := models.NewConversationStore(db, newCounterCache("CONVERSATION_COUNTER_CACHE"), time.Hour)
+	hub.SetConversationTracker(store)
+	return store
+}
+
+// initReactions 配置表情回应存储，WS react/unreact 与 REST 接口共用
+func initReactions(db *gorm.DB, hub *websocket.Hub) *models.ReactionStore {
+	store := models.NewReactionStore(db, int(util.GetIntEnv("MESSAGE_REACTION_SAMPLE_USERS")))
+	hub.SetReactionStore(store)
+	return store
+}
+
+// registerConversationRoutes 注册会话路由
+func (h *Handlers) registerConversationRoutes(r *gin.RouterGroup) {
+	conversations := r.Group("/conversations")
+	{
+		conversations.GET("", models.AuthRequired, h.handleListConversations)
+
+		conversations.GET("/unread", models.AuthRequired, h.handleConversationUnread)
+
+		conversations.GET("/reactions", models.AuthRequired, h.handleListReactions)
+
+		conversations.POST("/reactions", models.AuthRequired, h.handleReact)
+
+		conversations.DELETE("/reactions", models.AuthRequired, h.handleUnreact)
+
+		conversations.GET("/:id/search", models.AuthRequired, h.handleSearchConversationMessages)
+
+		conversations.GET("/:id/messages", models.AuthRequired, h.handleConversationHistory)
+
+		conversations.POST("/:id/read", models.AuthRequired, h.handleMarkConversationRead)
+
+		conversations.GET("/:id/settings", models.AuthRequired, h.handleGetConversationSettings)
+
+		conversations.PUT("/:id/settings", models.AuthRequired, h.audit("conversation.settings.update"), h.handleUpdateConversationSettings)
+	}
+}
+
+// handleConversationUnread 返回当前用户各会话未读数及总未读数
+func (h *Handlers) handleConversationUnread(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	items, total, err := h.conversations.UnreadCounts(c.Request.Context(), strconv.FormatUint(uint64(user.ID), 10))
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"conversations": items,
+		"total":         total,
+	})
+}
+
+// ConversationItem 会话列表项，私聊时 Peer 为对方用户ID，组会话时 Group 为组名
+type ConversationItem struct {
+	models.ConversationSummary
+	Group       string               `json:"group,omitempty"`
+	Peer        string               `json:"peer,omitempty"`
+	LastMessage *ConversationMessage `json:"lastMessage,omitempty"`
+}
+
+// handleListConversations 当前用户参与的会话，按最近活动倒序，附未读数与最后一条消息，
+// query: page（从 1 开始），size 每页条数（默认 20，最多 100）
+func (h *Handlers) handleListConversations(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	page := max(cast.ToInt(c.DefaultQuery("page", "1")), 1)
+	size := cast.ToInt(c.DefaultQuery("size", "20"))
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	summaries, total, err := h.conversations.ListConversations(c.Request.Context(), userID, (page-1)*size, size)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	var last map[string]models.ChatMessage
+	if h.messages != nil && len(summaries) > 0 {
+		ids := make([]string, 0, len(summaries))
+		for _, s := range summaries {
+			ids = append(ids, s.Conversation)
+		}
+		if last, err = h.messages.LastMessages(c.Request.Context(), ids); err != nil {
+			response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	items := make([]ConversationItem, 0, len(summaries))
+	for _, s := range summaries {
+		item := ConversationItem{ConversationSummary: s}
+		item.Group, item.Peer, _ = websocket.ParseConversation(s.Conversation, userID)
+		if row, ok := last[s.Conversation]; ok {
+			msg := newConversationMessage(row)
+			item.LastMessage = &msg
+		}
+		items = append(items, item)
+	}
+	response.Success(c, "success", gin.H{
+		"list":  items,
+		"total": total,
+		"page":  page,
+		"size":  size,
+	})
+}
+
+// MarkReadForm 标记会话已读到的消息
+type MarkReadForm struct {
+	MessageID int64 `json:"messageId" binding:"required"`
+}
+
+// handleMarkConversationRead 推进已读游标，与 WS read 消息相同，会话其他成员与本人的其他设备收到回执
+func (h *Handlers) handleMarkConversationRead(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	var form MarkReadForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.Fail(c, "invalid request", nil)
+		return
+	}
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	conversation := c.Param("id")
+	if err := h.authorizeConversation(conversation, userID, false); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+		return
+	}
+	receipt, err := h.wsHub.MarkRead(conversation, userID, form.MessageID)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	response.Success(c, "success", receipt)
+}
+
+// ReactionForm 添加或取消表情回应
+type ReactionForm struct {
+	Conversation string `json:"conversation" binding:"required"`
+	MessageID    int64  `json:"messageId" binding:"required"`
+	Emoji        string `json:"emoji" binding:"required"`
+}
+
+// authorizeConversation 校验用户属于会话，write 为 true 时组会话还需通过禁言检查
+func (h *Handlers) authorizeConversation(conversation, userID string, write bool) error {
+	group, _, ok := websocket.ParseConversation(conversation, userID)
+	if !ok {
+		return errors.New("invalid conversation")
+	}
+	if group == "" {
+		return nil
+	}
+	authorizer := &groupAuthorizer{db: h.db, allowAdhoc: util.GetBoolEnv(websocket.EnvWebSocketAllowAdhocGroups)}
+	if err := authorizer.AuthorizeJoin(group, userID); err != nil {
+		return err
+	}
+	if write {
+		return h.moderator.CheckSend(group, userID)
+	}
+	return nil
+}
+
+// handleListReactions 批量返回会话中消息的表情聚合与当前用户的回应，
+// query: conversation，message_ids 为逗号分隔的消息ID（最多 200 个）
+func (h *Handlers) handleListReactions(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	conversation := c.Query("conversation")
+	if err := h.authorizeConversation(conversation, userID, false); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+		return
+	}
+	var ids []int64
+	for _, part := range strings.Split(c.Query("message_ids"), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || id <= 0 {
+			response.Fail(c, "invalid message_ids", nil)
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 || len(ids) > 200 {
+		response.Fail(c, "message_ids must contain 1 to 200 ids", nil)
+		return
+	}
+
+	reactions, err := h.reactions.Reactions(c.Request.Context(), conversation, ids)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	mine, err := h.reactions.UserReactions(c.Request.Context(), conversation, userID, ids)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"conversation": conversation,
+		"reactions":    reactions,
+		"mine":         mine,
+	})
+}
+
+// handleReact 添加表情回应
+func (h *Handlers) handleReact(c *gin.Context) {
+	h.handleReaction(c, true)
+}
+
+// handleUnreact 取消表情回应
+func (h *Handlers) handleUnreact(c *gin.Context) {
+	h.handleReaction(c, false)
+}
+
+func (h *Handlers) handleReaction(c *gin.Context, add bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	var form ReactionForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		response.Fail(c, "invalid request", nil)
+		return
+	}
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	if err := h.authorizeConversation(form.Conversation, userID, add); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+		return
+	}
+	event, err := h.wsHub.React(form.Conversation, form.MessageID, userID, form.Emoji, add)
+	if errors.Is(err, models.ErrReactionMessageNotFound) {
+		response.AbortWithStatusJSON(c, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	response.Success(c, "success", event)
+}
@@ -4,7 +4,9 @@ import (
 	"HibiscusIM/internal/apidocs"
 	"HibiscusIM/internal/models"
 	"HibiscusIM/pkg/config"
+	"HibiscusIM/pkg/middleware"
 	"HibiscusIM/pkg/search"
+	stores "HibiscusIM/pkg/storage"
 	"net/http"
 )
 
@@ -104,19 +106,477 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 			Group:        "System Module",
 			Path:         "/api/system/health",
 			Method:       http.MethodGet,
-			Summary:      "数据库健康状态",
+			Summary:      "服务健康状态",
 			AuthRequired: false,
-			Desc:         `检查数据库健康状态`,
+			Desc:         `检查数据库与限流存储健康状态；限流存储异常时 status 为 degraded，fail-closed 模式下返回 503`,
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/rate-limit/status",
+			Method:       http.MethodGet,
+			Summary:      "限流配额状态",
+			AuthRequired: false,
+			Desc:         `返回调用方在各限流桶（global 默认速率、route 路由覆盖速率、key 限流键覆盖速率）中的上限、剩余配额与重置时间；query: route 可重复，查询指定路由的桶；exempt/blocked 表示命中白名单/黑名单。本次请求也计入配额`,
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "identifier", Type: apidocs.TYPE_STRING},
+					{Name: "exempt", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "blocked", Type: apidocs.TYPE_BOOLEAN},
+					{Name: "buckets", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(middleware.RateLimitBucket{}).Fields},
+				},
+			},
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/system/llm/usage",
+			Method:       http.MethodGet,
+			Summary:      "LLM 用量报表",
+			AuthRequired: true,
+			Desc:         `管理员查看 LLM 调用次数、失败数、token 用量与平均延迟；query: group_by=day|user|model，from/to 为 2006-01-02（默认最近 7 天），可按 user_id、model 过滤`,
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "rows", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(models.LLMUsageReportRow{}).Fields},
+					{Name: "total", Type: apidocs.TYPE_OBJECT, Fields: apidocs.GetDocDefine(models.LLMUsageReportRow{}).Fields},
+				},
+			},
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/system/search/progress/stream",
+			Method:       http.MethodGet,
+			Summary:      "订阅索引任务进度",
+			AuthRequired: true,
+			Desc:         "管理员通过 SSE 接收索引重建与导入的进度事件（type: search_progress），运行中每秒最多推送一次，开始与结束时总是推送",
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/system/events",
+			Method:       http.MethodGet,
+			Summary:      "系统事件日志",
+			AuthRequired: true,
+			Desc:         `管理员按时间倒序查看系统事件（启动、配置变更、备份、索引重建、告警状态变化、后台操作）；query: level=info|warn|error 为最低级别，category=startup|config|backup|search|alert|admin，since/until 为 RFC3339，before_id 翻页，limit 默认 100、最大 500`,
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "events", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(models.SystemEvent{}).Fields},
+					{Name: "nextBeforeId", Type: apidocs.TYPE_INT},
+				},
+			},
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/system/events/stream",
+			Method:       http.MethodGet,
+			Summary:      "订阅系统事件",
+			AuthRequired: true,
+			Desc:         "SSE 推送新的系统事件（event: system_event，id 为事件ID），支持与列表相同的 level/category 过滤；重连时按 Last-Event-ID 头或 last_event_id 参数补发错过的事件（最多 500 条）",
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/system/rate-limiter/groups",
+			Method:       http.MethodGet,
+			Summary:      "配额组用量",
+			AuthRequired: true,
+			Desc:         `管理员查看各共享配额组（如组织）及其成员的放行数、被成员配额与组配额拒绝的次数；query: reset=true 时读取后清零重新统计，便于按账期核对`,
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "groups", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(middleware.QuotaGroupUsage{}).Fields},
+				},
+			},
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/system/circuit-breakers",
+			Method:       http.MethodGet,
+			Summary:      "熔断器状态",
+			AuthRequired: true,
+			Desc:         "管理员查看各下游依赖熔断器的状态（closed/open/half_open）、10 秒窗口内的请求数与失败率、累计拒绝数，以及熔断后进入半开试探的时间",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "breakers", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(middleware.CircuitBreakerStatus{}).Fields},
+				},
+			},
+		},
+		{
+			Group:        "System Module",
+			Path:         config.GlobalConfig.APIPrefix + "/system/circuit-breakers/reset",
+			Method:       http.MethodPost,
+			Summary:      "恢复熔断器",
+			AuthRequired: true,
+			Desc:         "管理员手动将熔断器恢复为关闭状态并清空统计，name 为熔断器名称（依赖名或路由模板）",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "name", Type: apidocs.TYPE_STRING, Required: true},
+				},
+			},
+			Response: apidocs.GetDocDefine(middleware.CircuitBreakerStatus{}),
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc: "Create a system announcement (admin only). target selects recipients: all enabled users, or the union of groupIds, userIds and segments " +
+				"(staff, locales, countries, sources, activeWithinDays, joinedAfter). With scheduledAt (RFC3339, stored as UTC) it is delivered when due; draft saves it without sending; " +
+				"otherwise it is delivered immediately over WebSocket and SSE (type announcement) and as an internal notification",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "title", Type: apidocs.TYPE_STRING, Required: true},
+					{Name: "body", Type: apidocs.TYPE_STRING},
+					{Name: "severity", Type: apidocs.TYPE_STRING, Default: "info"},
+					{Name: "ctaText", Type: apidocs.TYPE_STRING},
+					{Name: "ctaUrl", Type: apidocs.TYPE_STRING},
+					{Name: "target", Type: apidocs.TYPE_OBJECT, Required: true},
+					{Name: "scheduledAt", Type: apidocs.TYPE_DATE},
+					{Name: "draft", Type: apidocs.TYPE_BOOLEAN},
+				},
+			},
+			Response: apidocs.GetDocDefine(models.Announcement{}),
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "List the latest 200 announcements (admin only); query: status=draft|scheduled|sending|sent|cancelled",
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements/:id/send",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Deliver a draft or scheduled announcement now (admin only)",
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Cancel an announcement that has not been delivered yet (admin only)",
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements/:id/reach",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Reach of an announcement: recipients, how many have seen and dismissed it, and the seen rate (admin only)",
+			Response:     apidocs.GetDocDefine(models.AnnouncementReach{}),
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements/active",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Announcements delivered to the current user that have not been dismissed, newest first (at most 20)",
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements/:id/seen",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Mark an announcement as seen by the current user",
+		},
+		{
+			Group:        "Announcement",
+			Path:         config.GlobalConfig.APIPrefix + "/announcements/:id/dismiss",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Dismiss an announcement for the current user; it is also marked as seen",
+		},
+		{
+			Group:        "Voice",
+			Path:         config.GlobalConfig.APIPrefix + "/voices/recordings/presign",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc: "Get a presigned form to upload a recording (wav, mp3, m4a, ogg, opus, webm or flac, max 50MB) straight to MinIO/S3 within 15 minutes. " +
+				"POST multipart/form-data to upload.url with every upload.fields entry followed by the `file` field; the content type and size are enforced by the signature. " +
+				"Then confirm with POST /voices/recordings using objectKey; the confirmed size counts toward the storage quota and `maxSize` is capped by the remaining quota. " +
+				"Returns 501 when the storage driver does not support presigning and 413 when the quota is used up",
+			Request: apidocs.GetDocDefine(RecordingUploadForm{}),
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "upload", Type: apidocs.TYPE_OBJECT, Fields: apidocs.GetDocDefine(stores.PresignedUpload{}).Fields},
+					{Name: "objectKey", Type: apidocs.TYPE_STRING},
+					{Name: "fileUrl", Type: apidocs.TYPE_STRING},
+					{Name: "contentType", Type: apidocs.TYPE_STRING},
+					{Name: "maxSize", Type: apidocs.TYPE_INT},
+				},
+			},
+		},
+		{
+			Group:        "Attachment",
+			Path:         config.GlobalConfig.APIPrefix + "/attachments/",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc: "Upload an attachment as multipart field `file` (max 50MB, images max 20MB). The content type is detected from the file content; JPEG, PNG and GIF images also get a thumbnail " +
+				"(`thumbnailUrl`, longest side 320px) and their `width`/`height`. The file is scanned asynchronously and can only be downloaded once scanStatus is clean. " +
+				"Uploads over the storage quota fail with 413 (see /storage/usage). " +
+				"Pass form field `conversation` to share it: an `attachment` WebSocket message with the attachment and its public `url` is sent to the conversation members once the scan passes",
+			Response: apidocs.GetDocDefine(models.Attachment{}),
+		},
+		{
+			Group:        "Attachment",
+			Path:         config.GlobalConfig.APIPrefix + "/attachments/:id/download",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Download an attachment. Returns 409 while scanning is pending and 403 if the file was quarantined",
+		},
+		{
+			Group:        "Attachment",
+			Path:         config.GlobalConfig.APIPrefix + "/attachments/:id",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Delete an attachment and its thumbnail, releasing its size from the uploader's storage usage",
+		},
+		{
+			Group:        "Attachment",
+			Path:         config.GlobalConfig.APIPrefix + config.GlobalConfig.AuthPrefix + "/update/avatar",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Upload an avatar image as multipart field `file` (max 5MB). The previous uploaded avatar is deleted and only the size difference counts toward the storage quota",
+		},
+		{
+			Group:        "Attachment",
+			Path:         config.GlobalConfig.APIPrefix + "/storage/usage",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc: "Storage used by the current user across recordings, avatars and attachments, with the configured quotas. " +
+				"STORAGE_QUOTA_BYTES limits the total and STORAGE_QUOTA_RECORDINGS_BYTES / _AVATARS_BYTES / _ATTACHMENTS_BYTES each category; uploads over a quota fail with 413. " +
+				"`warning` is set from STORAGE_QUOTA_WARN_PERCENT (default 80), when the user also gets a notification. Admins may pass `userId`",
+			Response: apidocs.GetDocDefine(models.StorageUsageSummary{}),
+		},
+		{
+			Group:        "Survey",
+			Path:         config.GlobalConfig.APIPrefix + "/question/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc: "Questionnaire definition with its questions. Like the recording prompts and group endpoints, responses are cached for RESPONSE_CACHE_TTL seconds when set " +
+				"(X-Cache: HIT/MISS) and invalidated when the underlying rows change; send `Cache-Control: no-cache` to revalidate, `no-store` to bypass, `max-age=N` to limit staleness or `only-if-cached` to get 504 on a miss",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "questionnaire", Type: apidocs.TYPE_OBJECT},
+					{Name: "questions", Type: apidocs.TYPE_OBJECT, IsArray: true},
+				},
+			},
+		},
+		{
+			Group:        "Survey",
+			Path:         config.GlobalConfig.APIPrefix + "/question/exports",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Export questionnaire responses as csv or xlsx in the background (staff only). includePII requires super user; otherwise respondents are pseudonymized. The requester is notified with a signed download link when done",
+			Request: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "questionnaireId", Type: apidocs.TYPE_INT},
+					{Name: "format", Type: apidocs.TYPE_STRING, Default: "csv"},
+					{Name: "includePII", Type: apidocs.TYPE_BOOLEAN},
+				},
+			},
+			Response: apidocs.GetDocDefine(models.SurveyExport{}),
+		},
+		{
+			Group:        "Survey",
+			Path:         config.GlobalConfig.APIPrefix + "/question/exports/:id",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Get export status; downloadUrl is returned once the export is done",
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Conversations the caller takes part in, most recent activity first, with unread count, `group` or direct `peer`, and the last message. query: page (from 1), size (default 20, max 100)",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "list", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(models.ConversationSummary{}).Fields},
+					{Name: "total", Type: apidocs.TYPE_INT},
+					{Name: "page", Type: apidocs.TYPE_INT},
+					{Name: "size", Type: apidocs.TYPE_INT},
+				},
+			},
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/:id/read",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "Advance the caller's read cursor; same as the WS `read` frame. Other members and the caller's other devices receive the `read` event",
+			Request:      apidocs.GetDocDefine(MarkReadForm{}),
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/unread",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Unread counts per conversation plus the total badge. Read cursors advance when the client sends a WS `read` frame with the conversation and message id",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "conversations", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(models.ConversationUnread{}).Fields},
+					{Name: "total", Type: apidocs.TYPE_INT},
+				},
+			},
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/reactions",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Reaction aggregates (emoji, count, recent users) for up to 200 messages of a conversation, keyed by message id, plus the emojis the caller reacted with. query: conversation, message_ids=1,2,3",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "conversation", Type: apidocs.TYPE_STRING},
+					{Name: "reactions", Type: apidocs.TYPE_MAP},
+					{Name: "mine", Type: apidocs.TYPE_MAP},
+				},
+			},
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/reactions",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc:         "React to a message; same as the WS `react` frame. Members of the conversation receive a `reaction_changed` event",
+			Request:      apidocs.GetDocDefine(ReactionForm{}),
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/reactions",
+			Method:       http.MethodDelete,
+			AuthRequired: true,
+			Desc:         "Remove a reaction; same as the WS `unreact` frame",
+			Request:      apidocs.GetDocDefine(ReactionForm{}),
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/events/batch",
+			Method:       http.MethodPost,
+			AuthRequired: true,
+			Desc: "Upload up to 200 events queued by the client while offline. type is `analytics` (name, properties), `read` (conversation, messageId) or `presence` (status online/away); " +
+				"id is client generated and each id is applied once per user. Only the highest read per conversation and the latest presence (if under 2 minutes old) are applied. " +
+				"Each result is accepted, duplicate, invalid (with error) or failed; failed events may be retried with the same id",
+			Request: apidocs.GetDocDefine(ClientEventBatchForm{}),
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "accepted", Type: apidocs.TYPE_INT},
+					{Name: "results", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(ClientEventResult{}).Fields},
+				},
+			},
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/:id/messages",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc: "Message history of a conversation the caller belongs to, newest first; id is `group:<groupId>` or `dm:<userId>:<userId>`. " +
+				"query: before=<messageId> for older messages, size (default 50, max 200). Pass `nextBefore` as `before` to load the next page, 0 means no more messages",
+			Response: &apidocs.DocField{
+				Type: "object",
+				Fields: []apidocs.DocField{
+					{Name: "conversation", Type: apidocs.TYPE_STRING},
+					{Name: "list", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: apidocs.GetDocDefine(ConversationMessage{}).Fields},
+					{Name: "nextBefore", Type: apidocs.TYPE_INT},
+				},
+			},
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/:id/settings",
+			Method:       http.MethodGet,
+			AuthRequired: true,
+			Desc:         "Settings of a conversation the caller belongs to. messageTtl is the default lifetime in seconds of new messages, 0 means messages do not expire",
+			Response:     &apidocs.DocField{Type: "object", Fields: apidocs.GetDocDefine(models.ConversationSetting{}).Fields},
+		},
+		{
+			Group:        "Conversation",
+			Path:         config.GlobalConfig.APIPrefix + "/conversations/:id/settings",
+			Method:       http.MethodPut,
+			AuthRequired: true,
+			Desc: "Set the default message lifetime (disappearing messages) of a conversation, up to 30 days; only group admins may change group conversations. " +
+				"Applies to messages sent afterwards, members are notified with a `conversation_ttl_changed` WebSocket message. " +
+				"Expired messages are never delivered, are hidden from history and are purged in the background with a `messages_expired` notice",
+			Request:  apidocs.GetDocDefine(ConversationSettingsRequest{}),
+			Response: &apidocs.DocField{Type: "object", Fields: apidocs.GetDocDefine(models.ConversationSetting{}).Fields},
+		},
+		{
+			Group:  "WebSocket",
+			Path:   config.GlobalConfig.APIPrefix + "/ws",
+			Method: http.MethodGet,
+			Desc: "WebSocket upgrade. Uses the session if present, otherwise a token from `Authorization: Bearer`, `?token=`/`?access_token=` " +
+				"or `Sec-WebSocket-Protocol: access_token, <token>`. Rejected handshakes are closed with 4401 (unauthorized) or 4403 (origin not allowed)",
 		},
 	}
 
-	if config.GlobalConfig.SearchEnabled {
+	if h.searchHandler != nil {
 		uriDocs = append(uriDocs, []apidocs.UriDoc{
+			{
+				Group:        "Conversation",
+				Path:         config.GlobalConfig.APIPrefix + "/conversations/:id/search",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc: "Search chat messages of a conversation the caller belongs to; id is `group:<groupId>` or `dm:<userId>:<userId>`. " +
+					"query: q (all words must match), sort=recent (default, newest first) or relevance, before=<messageId> for older messages, page, size (max 100). " +
+					"Chat messages with a `text` field are indexed a moment after they are sent; with Elasticsearch `conversation` and `type` must be mapped as keyword",
+				Response: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "conversation", Type: apidocs.TYPE_STRING},
+						{Name: "list", Type: apidocs.TYPE_OBJECT, IsArray: true},
+						{Name: "total", Type: apidocs.TYPE_INT},
+						{Name: "page", Type: apidocs.TYPE_INT},
+						{Name: "size", Type: apidocs.TYPE_INT},
+					},
+				},
+			},
+			{
+				Group:        "Conversation",
+				Path:         config.GlobalConfig.APIPrefix + "/group/:id/search",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc:         "Search chat messages of a group the caller is a member of; same as /conversations/group:<id>/search",
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/users",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc: "Typeahead for users. query: q (prefix of any word of the name), limit (default 10, max 50). " +
+					"Only users visible to the caller are returned: the caller, members of shared groups and direct message contacts; admins see everyone. " +
+					"Short prefixes are cached for 30 seconds (SEARCH_TYPEAHEAD_CACHE selects the cache, like the other counter caches)",
+				Response: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "id", Type: apidocs.TYPE_STRING},
+						{Name: "name", Type: apidocs.TYPE_STRING},
+						{Name: "avatar", Type: apidocs.TYPE_STRING},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/groups",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc:         "Typeahead for the groups the caller has joined, same query and response as /search/users; admins see every group",
+			},
 			{
 				Group:   "Search",
 				Path:    config.GlobalConfig.APIPrefix + "/search",
 				Method:  http.MethodPost,
-				Desc:    "Execute a search query",
+				Desc:    "Execute a search query. When SEARCH_ACL_FIELD is set, hits and facet counts only include documents visible to the caller (public, user:<id> or group:<id> in that field; admins are unrestricted). Chat messages are never returned, search them with the conversation and group message search APIs",
 				Request: apidocs.GetDocDefine(search.SearchRequest{}),
 				Response: &apidocs.DocField{
 					Type: "object",
@@ -136,19 +596,47 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 				Path:         config.GlobalConfig.APIPrefix + "/search/index",
 				Method:       http.MethodPost,
 				AuthRequired: true,
-				Desc:         "Index a new document",
+				Desc:         "Index a new document (admin only). Chat message documents are written by the server and are rejected",
 				Request:      apidocs.GetDocDefine(search.Doc{}),
 				Response: &apidocs.DocField{
 					Type: apidocs.TYPE_BOOLEAN,
 					Desc: "true if document is indexed successfully",
 				},
 			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/import",
+				Method:       http.MethodPost,
+				AuthRequired: true,
+				Desc:         "Bulk import newline-delimited JSON documents, one Doc per line (admin only). Optional query batch_size",
+				Response: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "total", Type: apidocs.TYPE_INT},
+						{Name: "indexed", Type: apidocs.TYPE_INT},
+						{Name: "failed", Type: apidocs.TYPE_INT},
+						{Name: "errors", Type: "array", Fields: []apidocs.DocField{
+							{Name: "line", Type: apidocs.TYPE_INT},
+							{Name: "id", Type: apidocs.TYPE_STRING},
+							{Name: "error", Type: apidocs.TYPE_STRING},
+						}},
+						{Name: "truncated", Type: apidocs.TYPE_BOOLEAN},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/progress",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc:         "Running and recently finished reindex and import tasks, newest first (admin only). Each task reports processed/failed/total documents, percent, rate (docs per second) and eta (seconds); imports have no total and estimate percent from the bytes read. The `X-Progress-Id` header of POST /search/import carries the task ID and GET /search/progress/:id returns a single task. Progress is also pushed as `search_progress` messages to online admins over WebSocket and over SSE at /system/search/progress/stream",
+			},
 			{
 				Group:        "Search",
 				Path:         config.GlobalConfig.APIPrefix + "/search/delete",
 				Method:       http.MethodPost,
 				AuthRequired: true,
-				Desc:         "Delete a document by its ID",
+				Desc:         "Delete a document by its ID (admin only)",
 				Request: &apidocs.DocField{
 					Type: "object",
 					Fields: []apidocs.DocField{
@@ -202,6 +690,199 @@ func (h *Handlers) GetDocs() []apidocs.UriDoc {
 					},
 				},
 			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/scroll",
+				Method:       http.MethodPost,
+				AuthRequired: false,
+				Desc:         "Cursor-based pagination for deep result sets. Accepts the same body as /search plus `cursor`; omit it for the first page and pass back the returned cursor with the same query to get the next page. From is ignored and an empty cursor means no more results",
+				Request: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "Keyword", Type: apidocs.TYPE_STRING},
+						{Name: "Size", Type: apidocs.TYPE_INT, Default: "10"},
+						{Name: "cursor", Type: apidocs.TYPE_STRING},
+					},
+				},
+				Response: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "Total", Type: apidocs.TYPE_INT},
+						{Name: "Hits", Type: apidocs.TYPE_OBJECT, IsArray: true},
+						{Name: "cursor", Type: apidocs.TYPE_STRING},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/reindex",
+				Method:       http.MethodPost,
+				AuthRequired: true,
+				Desc:         "Rebuild the index of database-backed documents (groups, questionnaires) from the database (admin only). Creates, updates and deletes are synced automatically; use this after bulk writes that bypass GORM hooks, or run the server with -reindex. With `?async=true` the rebuild runs in the background and the response is the task progress; the `X-Progress-Id` header carries the task ID in both modes",
+				Request: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "types", Type: apidocs.TYPE_STRING, IsArray: true},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/index/stats",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc:         "Index statistics (admin only): document count and disk size, plus result cache hit counts when SEARCH_CACHE_TTL is set and indexer queue stats",
+				Response: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "index", Type: apidocs.TYPE_OBJECT},
+						{Name: "cache", Type: apidocs.TYPE_OBJECT},
+						{Name: "indexer", Type: apidocs.TYPE_OBJECT},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/index/optimize",
+				Method:       http.MethodPost,
+				AuthRequired: true,
+				Desc:         "Merge index segments and purge deleted documents to reclaim disk space (admin only). Same as /search/compact",
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/index",
+				Method:       http.MethodDelete,
+				AuthRequired: true,
+				Desc:         "Drop and recreate the index (admin only). `mapping` replaces the index mapping: bleve mapping JSON, or the index creation body (settings and mappings) for Elasticsearch; omit it to keep the current mapping. With `reindex` the database-backed documents are imported again afterwards; other documents must be re-indexed by their owners",
+				Request: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "mapping", Type: apidocs.TYPE_OBJECT},
+						{Name: "reindex", Type: apidocs.TYPE_BOOLEAN, Default: "false"},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/click",
+				Method:       http.MethodPost,
+				AuthRequired: false,
+				Desc:         "Report that a search hit was clicked or acted on. `position` is the 1-based position of the hit in the results and `action` is click (default) or act. Available when SEARCH_FEEDBACK_ENABLED is set; with SEARCH_CLICK_BOOST > 0 frequently clicked documents rank higher for the same query",
+				Request: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "query", Type: apidocs.TYPE_STRING},
+						{Name: "id", Type: apidocs.TYPE_STRING},
+						{Name: "position", Type: apidocs.TYPE_INT},
+						{Name: "action", Type: apidocs.TYPE_STRING, Default: "click"},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/ctr",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc:         "Click-through rate by result position over the last `days` days (admin only). Pass `query` to also list the most clicked documents for that query",
+				Request: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "days", Type: apidocs.TYPE_INT, Default: "7"},
+						{Name: "positions", Type: apidocs.TYPE_INT, Default: "10"},
+						{Name: "query", Type: apidocs.TYPE_STRING},
+					},
+				},
+				Response: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "positions", Type: apidocs.TYPE_OBJECT, IsArray: true, Fields: []apidocs.DocField{
+							{Name: "position", Type: apidocs.TYPE_INT},
+							{Name: "impressions", Type: apidocs.TYPE_INT},
+							{Name: "clicks", Type: apidocs.TYPE_INT},
+							{Name: "ctr", Type: apidocs.TYPE_FLOAT},
+						}},
+						{Name: "documents", Type: apidocs.TYPE_OBJECT, IsArray: true},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/dictionaries",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc:         "Number of synonym terms and stopwords currently applied to search queries and when they were loaded (admin only)",
+				Response:     apidocs.GetDocDefine(search.DictionaryStats{}),
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/dictionaries/reload",
+				Method:       http.MethodPost,
+				AuthRequired: true,
+				Desc: "Reload synonyms and stopwords from SEARCH_SYNONYMS_PATH, SEARCH_STOPWORDS_PATH and the search dictionary table without restarting the engine (admin only). " +
+					"Keyword and match clauses are expanded with synonyms and stripped of stopwords at query time; on failure the previous dictionaries stay in use",
+				Response: apidocs.GetDocDefine(search.DictionaryStats{}),
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/migrations",
+				Method:       http.MethodGet,
+				AuthRequired: true,
+				Desc:         "Progress of the running index mapping migration, the index currently serving reads and the most recent index versions (admin only)",
+				Response: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "location", Type: apidocs.TYPE_STRING},
+						{Name: "migration", Type: apidocs.TYPE_OBJECT},
+						{Name: "versions", Type: apidocs.TYPE_OBJECT, IsArray: true},
+					},
+				},
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/migrations",
+				Method:       http.MethodPost,
+				AuthRequired: true,
+				Desc: "Start a zero-downtime mapping migration (admin only): a new index is created with `mapping` (the built-in mapping when omitted) and backfilled from the database in the background, " +
+					"while writes go to both indexes and reads stay on the current one. Once backfilled, doc counts and a sample of documents are compared and the phase becomes `ready` or `mismatch`",
+				Request: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "mapping", Type: apidocs.TYPE_OBJECT},
+					},
+				},
+				Response: apidocs.GetDocDefine(search.MigrationProgress{}),
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/migrations/verify",
+				Method:       http.MethodPost,
+				AuthRequired: true,
+				Desc:         "Re-run doc count and sampled parity checks between the current and the new index after backfill (admin only)",
+				Response:     apidocs.GetDocDefine(search.MigrationProgress{}),
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/migrations/switch",
+				Method:       http.MethodPost,
+				AuthRequired: true,
+				Desc: "Atomically switch reads and writes to the new index and persist it as the active version (admin only). " +
+					"Rejected unless verification passed; pass `force` to switch despite a mismatch. The previous index is kept on disk for rollback",
+				Request: &apidocs.DocField{
+					Type: "object",
+					Fields: []apidocs.DocField{
+						{Name: "force", Type: apidocs.TYPE_BOOLEAN, Default: "false"},
+					},
+				},
+				Response: apidocs.GetDocDefine(search.MigrationProgress{}),
+			},
+			{
+				Group:        "Search",
+				Path:         config.GlobalConfig.APIPrefix + "/search/migrations",
+				Method:       http.MethodDelete,
+				AuthRequired: true,
+				Desc:         "Abort the running migration, stop dual writes and drop the new index; the current index keeps serving (admin only)",
+				Response:     apidocs.GetDocDefine(search.MigrationProgress{}),
+			},
 		}...)
 	}
 	return uriDocs
@@ -21,6 +21,11 @@ func (h *Handlers) CreateGroup(c *gin.Context) {
 		return
 	}
 
+	if !canAssignAdminScopes(c, req.Permission) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only superuser can assign admin scopes"})
+		return
+	}
+
 	group := models.Group{
 		Name:       req.Name,
 		Type:       req.Type,
@@ -40,6 +45,11 @@ func (h *Handlers) DeleteGroup(c *gin.Context) {
 	id := c.Param("id")
 	var group models.Group
 
+	if err := h.db.First(&group, id).Error; err == nil && !canAssignAdminScopes(c, group.Permission) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only superuser can assign admin scopes"})
+		return
+	}
+
 	if err := h.db.Delete(&group, id).Error; err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -63,6 +73,11 @@ func (h *Handlers) UpdateGroup(c *gin.Context) {
 		return
 	}
 
+	if !canAssignAdminScopes(c, group.Permission) || !canAssignAdminScopes(c, req.Permission) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "only superuser can assign admin scopes"})
+		return
+	}
+
 	group.Name = req.Name
 	group.Type = req.Type
 	group.Extra = req.Extra
@@ -97,3 +112,12 @@ func (h *Handlers) ListGroups(c *gin.Context) {
 
 	c.JSON(http.StatusOK, groups)
 }
+
+// canAssignAdminScopes 后台授权决定管理员可访问的后台对象，只有超级用户可以分配或修改
+func canAssignAdminScopes(c *gin.Context, permission models.GroupPermission) bool {
+	if !models.HasAdminScopes(permission.Permissions) {
+		return true
+	}
+	user := models.CurrentUser(c)
+	return user != nil && user.IsSuperUser
+}
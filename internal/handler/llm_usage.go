@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/llm"
+	"HibiscusIM/pkg/response"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// initLLMUsage 将 LLM 调用用量持久化并汇总为日报表
+func initLLMUsage(db *gorm.DB) *models.LLMUsageStore {
+	store := models.NewLLMUsageStore(db, 0)
+	store.Start()
+	llm.SetUsageRecorder(store)
+	return store
+}
+
+// handleLLMUsageReport LLM 用量报表，group_by 为 day/user/model，日期格式 2006-01-02，默认最近 7 天
+func (h *Handlers) handleLLMUsageReport(c *gin.Context) {
+	to := time.Now()
+	from := to.AddDate(0, 0, -6)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			response.Fail(c, "invalid from date", nil)
+			return
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			response.Fail(c, "invalid to date", nil)
+			return
+		}
+		to = t
+	}
+	groupBy := c.DefaultQuery("group_by", models.LLMUsageGroupByDay)
+	if groupBy != models.LLMUsageGroupByDay && groupBy != models.LLMUsageGroupByUser && groupBy != models.LLMUsageGroupByModel {
+		response.Fail(c, "group_by must be day, user or model", nil)
+		return
+	}
+
+	rows, err := models.LLMUsageReport(h.db, groupBy, from, to, c.Query("user_id"), c.Query("model"))
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	var total models.LLMUsageReportRow
+	for _, r := range rows {
+		total.Calls += r.Calls
+		total.Errors += r.Errors
+		total.PromptTokens += r.PromptTokens
+		total.CompletionTokens += r.CompletionTokens
+		total.TotalTokens += r.TotalTokens
+	}
+	result := gin.H{
+		"groupBy": groupBy,
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"rows":    rows,
+		"total":   total,
+	}
+	if h.llmUsage != nil {
+		result["dropped"] = h.llmUsage.Dropped()
+	}
+	response.Success(c, "success", result)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/util"
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// 过期消息清理的默认间隔与每个分片单次最多删除的条数
+	defaultMessageExpirySweepInterval = 30 * time.Second
+	messageExpirySweepBatch           = 500
+)
+
+// messageExpirySweeper 定期删除已过期的阅后即焚消息与超过保留期限的消息，同步清理搜索索引与表情回应，并通知会话成员
+type messageExpirySweeper struct {
+	repo      *models.MessageRepository
+	reactions *models.ReactionStore
+	hub       *websocket.Hub
+	interval  time.Duration
+	// 消息保留期限，0 表示永久保留
+	retention time.Duration
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// initMessageExpiry 配置会话默认消息存活时间，消息历史启用时启动过期消息清理，
+// 清理间隔由 MESSAGE_EXPIRY_SWEEP_INTERVAL（秒）配置，消息保留天数由 MESSAGE_RETENTION_DAYS 配置
+func initMessageExpiry(hub *websocket.Hub, conversations *models.ConversationStore, repo *models.MessageRepository, reactions *models.ReactionStore) *messageExpirySweeper {
+	hub.SetExpiryPolicy(conversations)
+	if repo == nil {
+		return nil
+	}
+	interval := time.Duration(util.GetIntEnv("MESSAGE_EXPIRY_SWEEP_INTERVAL")) * time.Second
+	if interval <= 0 {
+		interval = defaultMessageExpirySweepInterval
+	}
+	s := &messageExpirySweeper{
+		repo:      repo,
+		reactions: reactions,
+		hub:       hub,
+		interval:  interval,
+		retention: time.Duration(util.GetIntEnv("MESSAGE_RETENTION_DAYS")) * 24 * time.Hour,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// close 停止清理
+func (s *messageExpirySweeper) close() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *messageExpirySweeper) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep(context.Background(), time.Now())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sweep 分批删除已过期与超过保留期限的消息，直到没有更多可删除的消息或收到停止信号
+func (s *messageExpirySweeper) sweep(ctx context.Context, now time.Time) {
+	s.purge(ctx, func() ([]models.ChatMessage, error) {
+		return s.repo.PurgeExpired(ctx, now, messageExpirySweepBatch)
+	})
+	if s.retention > 0 {
+		s.purge(ctx, func() ([]models.ChatMessage, error) {
+			return s.repo.PurgeBefore(ctx, now.Add(-s.retention), messageExpirySweepBatch)
+		})
+	}
+}
+
+// purge 重复执行一批删除并清理关联数据
+func (s *messageExpirySweeper) purge(ctx context.Context, batch func() ([]models.ChatMessage, error)) {
+	for {
+		purged, err := batch()
+		if err != nil {
+			logger.Warn("purge messages failed", zap.Error(err))
+		}
+		byConversation := make(map[string][]int64)
+		for _, m := range purged {
+			byConversation[m.Conversation] = append(byConversation[m.Conversation], m.MessageID)
+		}
+		indexer, _ := s.hub.MessageArchiver().(*messageIndexer)
+		for conversation, ids := range byConversation {
+			if indexer != nil {
+				indexer.forget(ctx, conversation, ids)
+			}
+			if s.reactions != nil {
+				if err := s.reactions.DeleteMessages(ctx, conversation, ids); err != nil {
+					logger.Warn("delete reactions of expired messages failed", zap.String("conversation", conversation), zap.Error(err))
+				}
+			}
+			s.hub.NotifyMessagesExpired(conversation, ids)
+		}
+		if err != nil || len(purged) < messageExpirySweepBatch {
+			return
+		}
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+	}
+}
+
+// ConversationSettingsRequest 会话设置
+type ConversationSettingsRequest struct {
+	// 新消息默认存活秒数，0 表示关闭阅后即焚
+	MessageTTL int64 `json:"messageTtl"`
+}
+
+// handleGetConversationSettings 会话设置
+func (h *Handlers) handleGetConversationSettings(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	conversation := c.Param("id")
+	if err := h.authorizeConversation(conversation, strconv.FormatUint(uint64(user.ID), 10), false); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+		return
+	}
+	setting, err := h.conversations.Settings(c.Request.Context(), conversation)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", setting)
+}
+
+// handleUpdateConversationSettings 修改会话设置，群组会话仅群管理员可修改，私聊双方均可修改；
+// 修改后通知会话成员，只影响之后发送的消息
+func (h *Handlers) handleUpdateConversationSettings(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	var req ConversationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request", nil)
+		return
+	}
+	ttl := time.Duration(req.MessageTTL) * time.Second
+	if req.MessageTTL < 0 || ttl > websocket.MaxMessageTTL {
+		response.Fail(c, "messageTtl must be between 0 and "+strconv.Itoa(int(websocket.MaxMessageTTL/time.Second))+" seconds", nil)
+		return
+	}
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	conversation := c.Param("id")
+	if err := h.authorizeConversation(conversation, userID, false); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+		return
+	}
+	if group, _, _ := websocket.ParseConversation(conversation, userID); group != "" {
+		if gid, err := strconv.ParseUint(group, 10, 64); err == nil && !models.IsGroupAdmin(h.db, uint(gid), user.ID) {
+			response.AbortWithStatusJSON(c, http.StatusForbidden, errors.New("only group admins can change conversation settings"))
+			return
+		}
+	}
+	setting, err := h.conversations.SetMessageTTL(c.Request.Context(), conversation, ttl, userID)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	h.wsHub.NotifyConversationTTL(conversation, ttl, userID)
+	response.Success(c, "success", setting)
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// 消息持久化的批量大小与最长等待时间
+	messagePersistBatch    = 200
+	messagePersistInterval = 500 * time.Millisecond
+	// 历史消息每页最大条数
+	maxMessageHistorySize = 200
+)
+
+// messagePersister 将 WebSocket 聊天消息批量写入分片消息表，队列满时丢弃并记录日志；
+// 按用户的已读游标补发离线期间的消息
+type messagePersister struct {
+	repo          *models.MessageRepository
+	conversations *models.ConversationStore
+	queue         chan models.ChatMessage
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// initMessageHistory 创建分片消息仓库并持久化已分配会话消息ID的聊天消息，分片配置错误时不持久化
+func initMessageHistory(db *gorm.DB, hub *websocket.Hub, conversations *models.ConversationStore) (*models.MessageRepository, *messagePersister) {
+	repo, err := models.NewMessageRepository(db, models.LoadMessageShardConfig())
+	if err != nil {
+		logger.Error("message history disabled", zap.Error(err))
+		return nil, nil
+	}
+	if err := repo.Migrate(); err != nil {
+		logger.Error("migrate message tables failed, message history disabled", zap.Error(err))
+		return nil, nil
+	}
+	mp := &messagePersister{
+		repo:          repo,
+		conversations: conversations,
+		queue:         make(chan models.ChatMessage, 10*messagePersistBatch),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	hub.SetMessageStore(mp)
+	go mp.run()
+	return repo, mp
+}
+
+// PersistMessage 序列化消息内容入队
+func (mp *messagePersister) PersistMessage(msg websocket.Message) {
+	if msg.Conversation == "" {
+		return
+	}
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		logger.Warn("encode chat message failed", zap.String("conversation", msg.Conversation), zap.Error(err))
+		return
+	}
+	row := models.ChatMessage{
+		Conversation: msg.Conversation,
+		MessageID:    msg.ID,
+		Sender:       msg.From,
+		Type:         msg.Type,
+		Data:         string(data),
+		CreatedAt:    time.Now(),
+	}
+	if msg.ExpiresAt > 0 {
+		expiresAt := time.Unix(msg.ExpiresAt, 0)
+		row.ExpiresAt = &expiresAt
+	}
+	select {
+	case mp.queue <- row:
+	default:
+		logger.Warn("message persist queue full, dropping message",
+			zap.String("conversation", row.Conversation), zap.Int64("messageId", row.MessageID))
+	}
+}
+
+// MissedMessages 按已读游标读取用户各会话的未读消息，尚未落库的消息不包含在内
+func (mp *messagePersister) MissedMessages(userID string, limit int) ([]websocket.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	unread, _, err := mp.conversations.UnreadCounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []websocket.Message
+	for _, u := range unread {
+		if u.Unread <= 0 {
+			continue
+		}
+		rows, err := mp.repo.After(ctx, u.Conversation, u.LastReadMessageID, limit-len(msgs))
+		if err != nil {
+			return msgs, err
+		}
+		for _, row := range rows {
+			msgs = append(msgs, newWebSocketMessage(row, userID))
+		}
+		if len(msgs) >= limit {
+			break
+		}
+	}
+	return msgs, nil
+}
+
+// newWebSocketMessage 持久化的消息还原为下发给 userID 的 WS 消息
+func newWebSocketMessage(row models.ChatMessage, userID string) websocket.Message {
+	msg := websocket.Message{
+		Type:         row.Type,
+		Data:         json.RawMessage(row.Data),
+		Timestamp:    row.CreatedAt.Unix(),
+		From:         row.Sender,
+		ID:           row.MessageID,
+		Conversation: row.Conversation,
+	}
+	if group, _, _ := websocket.ParseConversation(row.Conversation, userID); group != "" {
+		msg.Group = group
+	} else {
+		msg.To = userID
+	}
+	if row.ExpiresAt != nil {
+		msg.ExpiresAt = row.ExpiresAt.Unix()
+	}
+	return msg
+}
+
+// close 停止后台写入，提交已入队的消息
+func (mp *messagePersister) close() {
+	close(mp.stop)
+	<-mp.done
+}
+
+// run 攒批写入消息表，达到批量大小或等待超时后提交
+func (mp *messagePersister) run() {
+	defer close(mp.done)
+	ticker := time.NewTicker(messagePersistInterval)
+	defer ticker.Stop()
+	batch := make([]models.ChatMessage, 0, messagePersistBatch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := mp.repo.Save(context.Background(), batch); err != nil {
+			logger.Warn("persist chat messages failed", zap.Int("count", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case row := <-mp.queue:
+			if batch = append(batch, row); len(batch) >= messagePersistBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-mp.stop:
+			for {
+				select {
+				case row := <-mp.queue:
+					batch = append(batch, row)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// ConversationMessage 历史消息
+type ConversationMessage struct {
+	Conversation string          `json:"conversation"`
+	MessageID    int64           `json:"messageId"`
+	Sender       string          `json:"sender"`
+	Type         string          `json:"type"`
+	Data         json.RawMessage `json:"data"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	ExpiresAt    *time.Time      `json:"expiresAt,omitempty"`
+}
+
+func newConversationMessage(row models.ChatMessage) ConversationMessage {
+	return ConversationMessage{
+		Conversation: row.Conversation,
+		MessageID:    row.MessageID,
+		Sender:       row.Sender,
+		Type:         row.Type,
+		Data:         json.RawMessage(row.Data),
+		CreatedAt:    row.CreatedAt,
+		ExpiresAt:    row.ExpiresAt,
+	}
+}
+
+// handleConversationHistory 会话历史消息，按消息ID倒序，
+// query: before 只返回该消息ID之前的消息，size 每页条数（默认 50，最多 200）
+func (h *Handlers) handleConversationHistory(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	if h.messages == nil {
+		response.AbortWithStatusJSON(c, http.StatusServiceUnavailable, errors.New("message history is disabled"))
+		return
+	}
+	conversation := c.Param("id")
+	if err := h.authorizeConversation(conversation, strconv.FormatUint(uint64(user.ID), 10), false); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+		return
+	}
+	size := cast.ToInt(c.DefaultQuery("size", "50"))
+	if size <= 0 || size > maxMessageHistorySize {
+		size = 50
+	}
+	rows, err := h.messages.History(c.Request.Context(), conversation, cast.ToInt64(c.Query("before")), size)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	list := make([]ConversationMessage, 0, len(rows))
+	for _, row := range rows {
+		list = append(list, newConversationMessage(row))
+	}
+	var next int64
+	if len(rows) == size {
+		next = rows[len(rows)-1].MessageID
+	}
+	response.Success(c, "success", gin.H{
+		"conversation": conversation,
+		"list":         list,
+		// 下一页的 before 参数，为 0 时没有更多消息
+		"nextBefore": next,
+	})
+}
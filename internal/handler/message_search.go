@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/search"
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+)
+
+const (
+	// messageDocType 聊天消息在搜索索引中的文档类型
+	messageDocType = "message"
+	// 消息写入索引的批量大小与最长等待时间
+	messageIndexBatch    = 100
+	messageIndexInterval = time.Second
+	// 会话内搜索每页最大条数
+	maxMessageSearchSize = 100
+)
+
+// messageIndexer 将 WebSocket 聊天消息批量写入搜索索引，队列满时丢弃并记录日志
+type messageIndexer struct {
+	engine search.Engine
+	queue  chan search.Doc
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// initMessageSearch 将已分配会话消息ID的聊天消息同步到搜索索引，供会话内搜索使用
+func initMessageSearch(engine search.Engine, hub *websocket.Hub) *messageIndexer {
+	mi := &messageIndexer{
+		engine: engine,
+		queue:  make(chan search.Doc, 10*messageIndexBatch),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	hub.SetMessageArchiver(mi)
+	go mi.run()
+	return mi
+}
+
+// messageDocID 消息文档ID，会话内消息ID唯一
+func messageDocID(conversation string, messageID int64) string {
+	return conversation + "#" + strconv.FormatInt(messageID, 10)
+}
+
+// messageDoc 聊天消息的搜索文档
+func messageDoc(conversation string, messageID int64, sender, text string, createdAt time.Time) search.Doc {
+	return search.Doc{
+		ID:   messageDocID(conversation, messageID),
+		Type: messageDocType,
+		Fields: map[string]any{
+			"type":         messageDocType,
+			"conversation": conversation,
+			"sender":       sender,
+			"text":         text,
+			"messageId":    messageID,
+			"createdAt":    createdAt,
+		},
+	}
+}
+
+// ArchiveMessage 提取消息文本入队，没有文本的消息不索引
+func (mi *messageIndexer) ArchiveMessage(msg websocket.Message) {
+	data, _ := msg.Data.(map[string]interface{})
+	text := strings.TrimSpace(cast.ToString(data["text"]))
+	if text == "" || msg.Conversation == "" {
+		return
+	}
+	doc := messageDoc(msg.Conversation, msg.ID, msg.From, text, time.Now())
+	select {
+	case mi.queue <- doc:
+	default:
+		logger.Warn("message search queue full, dropping message", zap.String("id", doc.ID))
+	}
+}
+
+// forget 从索引中删除会话的消息，用于清理过期消息
+func (mi *messageIndexer) forget(ctx context.Context, conversation string, messageIDs []int64) {
+	for _, id := range messageIDs {
+		if err := mi.engine.Delete(ctx, messageDocID(conversation, id)); err != nil {
+			logger.Warn("delete expired message from index failed", zap.String("conversation", conversation), zap.Int64("messageId", id), zap.Error(err))
+		}
+	}
+}
+
+// messageSnippetSource 按文档ID从消息表读取消息原文，消息文本未存储在索引中时用于生成片段
+func messageSnippetSource(repo *models.MessageRepository) search.SnippetSource {
+	return func(ctx context.Context, ids []string) (map[string]map[string]string, error) {
+		out := make(map[string]map[string]string, len(ids))
+		for _, id := range ids {
+			i := strings.LastIndex(id, "#")
+			if i < 0 {
+				continue
+			}
+			messageID, err := strconv.ParseInt(id[i+1:], 10, 64)
+			if err != nil {
+				continue
+			}
+			msg, err := repo.Get(ctx, id[:i], messageID)
+			if errors.Is(err, models.ErrMessageNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(msg.Data), &data); err != nil {
+				continue
+			}
+			out[id] = map[string]string{"text": strings.TrimSpace(cast.ToString(data["text"]))}
+		}
+		return out, nil
+	}
+}
+
+// messageBackfill 索引映射迁移时从消息表回填聊天消息
+type messageBackfill struct {
+	repo *models.MessageRepository
+}
+
+func (b messageBackfill) Count(ctx context.Context) (map[string]int64, error) {
+	n, err := b.repo.Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{messageDocType: n}, nil
+}
+
+// Backfill 写入有文本的消息，进度按已读取的消息数计算
+func (b messageBackfill) Backfill(ctx context.Context, target search.Engine, progress func(docType string, n int)) error {
+	return b.repo.Scan(ctx, messageIndexBatch, func(msgs []models.ChatMessage) error {
+		docs := make([]search.Doc, 0, len(msgs))
+		for _, m := range msgs {
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(m.Data), &data); err != nil {
+				continue
+			}
+			if text := strings.TrimSpace(cast.ToString(data["text"])); text != "" {
+				docs = append(docs, messageDoc(m.Conversation, m.MessageID, m.Sender, text, m.CreatedAt))
+			}
+		}
+		if len(docs) > 0 {
+			if err := target.IndexBatch(ctx, docs); err != nil {
+				return err
+			}
+		}
+		progress(messageDocType, len(msgs))
+		return nil
+	})
+}
+
+// close 停止后台写入，提交已入队的消息
+func (mi *messageIndexer) close() {
+	close(mi.stop)
+	<-mi.done
+}
+
+// run 攒批写入索引，达到批量大小或等待超时后提交
+func (mi *messageIndexer) run() {
+	defer close(mi.done)
+	ticker := time.NewTicker(messageIndexInterval)
+	defer ticker.Stop()
+	batch := make([]search.Doc, 0, messageIndexBatch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := mi.engine.IndexBatch(context.Background(), batch); err != nil {
+			logger.Warn("index chat messages failed", zap.Int("count", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case doc := <-mi.queue:
+			if batch = append(batch, doc); len(batch) >= messageIndexBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-mi.stop:
+			for {
+				select {
+				case doc := <-mi.queue:
+					batch = append(batch, doc)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// ConversationMessageHit 会话内搜索命中的消息
+type ConversationMessageHit struct {
+	Conversation string    `json:"conversation"`
+	MessageID    int64     `json:"messageId"`
+	Sender       string    `json:"sender"`
+	Text         string    `json:"text"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Score        float64   `json:"score"`
+	// 高亮后的文本片段
+	Highlights []string `json:"highlights,omitempty"`
+}
+
+// handleSearchGroupMessages 在群组会话中搜索消息
+func (h *Handlers) handleSearchGroupMessages(c *gin.Context) {
+	h.searchConversationMessages(c, websocket.GroupConversation(c.Param("id")))
+}
+
+// handleSearchConversationMessages 在会话中搜索消息，id 为会话ID（group:<群组ID> 或 dm:<用户ID>:<用户ID>）
+func (h *Handlers) handleSearchConversationMessages(c *gin.Context) {
+	h.searchConversationMessages(c, c.Param("id"))
+}
+
+// searchConversationMessages 校验会话成员关系后在该会话的消息中搜索，
+// query: q 关键词，sort 为 recent（默认，新消息在前）或 relevance，before 只返回该消息ID之前的消息，page/size 分页
+func (h *Handlers) searchConversationMessages(c *gin.Context, conversation string) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	if h.messageSearch == nil {
+		response.AbortWithStatusJSON(c, http.StatusServiceUnavailable, errors.New("search is disabled"))
+		return
+	}
+	if err := h.authorizeConversation(conversation, strconv.FormatUint(uint64(user.ID), 10), false); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusForbidden, err)
+		return
+	}
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		response.Fail(c, "q is required", nil)
+		return
+	}
+	page := max(cast.ToInt(c.DefaultQuery("page", "1")), 1)
+	size := cast.ToInt(c.DefaultQuery("size", "20"))
+	if size <= 0 || size > maxMessageSearchSize {
+		size = 20
+	}
+
+	req := search.SearchRequest{
+		MustTerms: map[string][]string{
+			"type":         {messageDocType},
+			"conversation": {conversation},
+		},
+		Matches:         []search.ClauseMatch{{Field: "text", Query: keyword, Operator: "and"}},
+		MinShould:       1,
+		IncludeFields:   []string{"conversation", "sender", "text", "messageId", "createdAt"},
+		Highlight:       true,
+		HighlightFields: []string{"text"},
+		From:            (page - 1) * size,
+		Size:            size,
+	}
+	switch sort := c.DefaultQuery("sort", "recent"); sort {
+	case "recent":
+		req.Sort = []search.SortField{{Field: "messageId", Desc: true}}
+	case "relevance":
+	default:
+		response.Fail(c, "sort must be recent or relevance", nil)
+		return
+	}
+	if before := cast.ToInt64(c.Query("before")); before > 0 {
+		lt := float64(before)
+		req.NumericRanges = []search.NumericRangeFilter{{Field: "messageId", LT: &lt}}
+	}
+
+	result, err := h.messageSearch.engine.Search(c.Request.Context(), req)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	hits := make([]ConversationMessageHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		hits = append(hits, ConversationMessageHit{
+			Conversation: cast.ToString(hit.Fields["conversation"]),
+			MessageID:    cast.ToInt64(hit.Fields["messageId"]),
+			Sender:       cast.ToString(hit.Fields["sender"]),
+			Text:         cast.ToString(hit.Fields["text"]),
+			CreatedAt:    cast.ToTime(hit.Fields["createdAt"]),
+			Score:        hit.Score,
+			Highlights:   hit.Fragments["text"],
+		})
+	}
+	response.Success(c, "success", gin.H{
+		"conversation": conversation,
+		"list":         hits,
+		"total":        result.Total,
+		"page":         page,
+		"size":         size,
+	})
+}
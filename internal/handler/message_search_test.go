@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/config"
+	constants "HibiscusIM/pkg/constant"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/search"
+	"HibiscusIM/pkg/util"
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGenericSearchHidesMessages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	require.NoError(t, logger.Init(&logger.LogConfig{Level: "error", Filename: filepath.Join(dir, "app.log")}, ""))
+	prev := config.GlobalConfig
+	config.GlobalConfig = &config.Config{SearchEnabled: true, SearchPath: filepath.Join(dir, "search.bleve"), SearchBatchSize: 10}
+	t.Cleanup(func() { config.GlobalConfig = prev })
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "search.db")), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}, &models.Group{}, &models.GroupMember{}, &models.Questionnaire{}, &util.Config{},
+		&search.SearchIndexVersion{}, &search.SearchDictionaryEntry{}))
+
+	hub := websocket.NewHub(nil)
+	t.Cleanup(hub.Close)
+	h := &Handlers{db: db, wsHub: hub}
+	ctx := context.Background()
+	require.NoError(t, h.StartSearch(ctx))
+	t.Cleanup(func() { _ = h.StopSearch(ctx) })
+
+	require.NoError(t, h.searchEngine.IndexBatch(ctx, []search.Doc{
+		messageDoc("dm:1:2", 1, "1", "secret launch plans", time.Now()),
+		{ID: "a1", Type: "article", Fields: map[string]any{"title": "secret recipes"}},
+	}))
+
+	users := map[string]*models.User{"1": {ID: 1}, "3": {ID: 3}}
+	r := gin.New()
+	r.Use(middleware.WithMemSession("search-test"), func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		if user := users[c.GetHeader("X-User")]; user != nil {
+			c.Set(constants.UserField, user)
+		}
+		c.Next()
+	})
+	api := r.Group("/api")
+	h.searchHandler.RegisterSearchRoutes(api)
+	api.GET("/conversations/:id/messages/search", h.handleSearchConversationMessages)
+
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	hitIDs := func(w *httptest.ResponseRecorder) []string {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Code int `json:"code"`
+			Data struct {
+				Hits []search.Hit `json:"Hits"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Equal(t, http.StatusOK, resp.Code, w.Body.String())
+		ids := []string{}
+		for _, hit := range resp.Data.Hits {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	// 匿名调用通用搜索与游标分页都不返回消息文档，即使显式指定消息类型
+	assert.Equal(t, []string{"a1"}, hitIDs(do(http.MethodPost, "/api/search/", "", `{"Keyword":"secret"}`)))
+	assert.Empty(t, hitIDs(do(http.MethodPost, "/api/search/", "", `{"Keyword":"secret","MustTerms":{"type":["message"]}}`)))
+	assert.Empty(t, hitIDs(do(http.MethodPost, "/api/search/", "1", `{"MustTerms":{"conversation":["dm:1:2"]}}`)))
+	assert.Equal(t, []string{"a1"}, hitIDs(do(http.MethodPost, "/api/search/scroll", "", `{"Keyword":"secret"}`)))
+
+	// 通用写入与删除接口不能伪造或删除消息文档
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/search/index", "", `{"id":"dm:1:2#2","type":"message","fields":{"text":"forged"}}`).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "/api/search/delete", "", `{"id":"dm:1:2#1"}`).Code)
+
+	// 会话成员仍可通过会话内搜索找到消息，非成员不行
+	w := do(http.MethodGet, "/api/conversations/dm:1:2/messages/search?q=secret", "1", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "secret launch plans")
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/conversations/dm:1:2/messages/search?q=secret", "3", "").Code)
+}
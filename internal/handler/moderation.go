@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/util"
+	"HibiscusIM/pkg/websocket"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ModerationRequest struct {
+	UserID   uint   `json:"userId" binding:"required"`
+	Duration int64  `json:"duration"` // 禁言时长（秒），0 表示解除禁言
+	Reason   string `json:"reason"`
+}
+
+// moderationCacheTTL 成员禁言/封禁状态的本地缓存时长，本节点的管理操作会立即失效缓存，
+// 其他节点或直接改库的变更最多延迟该时长生效
+const moderationCacheTTL = 30 * time.Second
+
+// groupModerator 基于数据库的WebSocket组管理检查器，组名即群组ID。
+// 成员状态按 群组:用户 缓存，每条消息的发言检查不再回表
+type groupModerator struct {
+	db     *gorm.DB
+	states sync.Map // "gid:uid" -> moderationState
+}
+
+type moderationState struct {
+	m        *models.GroupModeration // 无管理记录时为 nil
+	loadedAt time.Time
+}
+
+// initGroupModeration 创建组管理检查器，并在群组管理事件发生时失效对应成员的缓存
+func initGroupModeration(db *gorm.DB) *groupModerator {
+	m := &groupModerator{db: db}
+	util.Sig().Connect(models.SigGroupModeration, func(sender any, params ...any) {
+		if event, ok := sender.(*models.GroupModerationEvent); ok {
+			m.Invalidate(event.GroupID, event.UserID)
+		}
+	})
+	return m
+}
+
+// CheckSend 检查用户是否允许在组内发言
+func (m *groupModerator) CheckSend(group, userID string) error {
+	gid, uid, ok := parseModerationIDs(group, userID)
+	if !ok {
+		return nil
+	}
+	state, err := m.state(gid, uid)
+	if err != nil {
+		return err
+	}
+	return state.CheckSend(time.Now())
+}
+
+// CheckJoin 检查用户是否允许加入组
+func (m *groupModerator) CheckJoin(group, userID string) error {
+	gid, uid, ok := parseModerationIDs(group, userID)
+	if !ok {
+		return nil
+	}
+	state, err := m.state(gid, uid)
+	if err != nil {
+		return err
+	}
+	return state.CheckJoin()
+}
+
+// Invalidate 删除成员管理状态的缓存
+func (m *groupModerator) Invalidate(groupID, userID uint) {
+	m.states.Delete(moderationKey(groupID, userID))
+}
+
+// state 读取成员管理状态，缓存过期或不存在时回表
+func (m *groupModerator) state(groupID, userID uint) (*models.GroupModeration, error) {
+	key := moderationKey(groupID, userID)
+	if v, ok := m.states.Load(key); ok {
+		if s := v.(moderationState); time.Since(s.loadedAt) < moderationCacheTTL {
+			return s.m, nil
+		}
+	}
+	gm, err := models.GetGroupModeration(m.db, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	m.states.Store(key, moderationState{m: gm, loadedAt: time.Now()})
+	return gm, nil
+}
+
+func moderationKey(groupID, userID uint) string {
+	return strconv.FormatUint(uint64(groupID), 10) + ":" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// groupAuthorizer 基于群组成员表的WebSocket组成员关系检查，组名即群组ID
+type groupAuthorizer struct {
+	db *gorm.DB
+	// allowAdhoc 是否允许加入非群组ID命名的临时组
+	allowAdhoc bool
+}
+
+// AuthorizeJoin 检查用户是否为群组成员
+func (a *groupAuthorizer) AuthorizeJoin(group, userID string) error {
+	gid, err := strconv.ParseUint(group, 10, 64)
+	if err != nil {
+		if a.allowAdhoc {
+			return nil
+		}
+		return websocket.ErrNotGroupMember
+	}
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil || !models.IsGroupMember(a.db, uint(gid), uint(uid)) {
+		return websocket.ErrNotGroupMember
+	}
+	return nil
+}
+
+// Permit WebSocket 组权限钩子，发布时重新检查成员关系，
+// 被移出群组的用户无法通过已建立的连接继续在组内发言
+func (a *groupAuthorizer) Permit(userID, group string) error {
+	return a.AuthorizeJoin(group, userID)
+}
+
+// UserGroups 用户所在的全部群组
+func (a *groupAuthorizer) UserGroups(userID string) ([]string, error) {
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	ids, err := models.UserGroupIDs(a.db, uint(uid))
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]string, 0, len(ids))
+	for _, id := range ids {
+		groups = append(groups, strconv.FormatUint(uint64(id), 10))
+	}
+	return groups, nil
+}
+
+func parseModerationIDs(group, userID string) (uint, uint, bool) {
+	gid, err := strconv.ParseUint(group, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	uid, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(gid), uint(uid), true
+}
+
+// requireGroupAdmin 解析群组ID并校验当前用户为群组管理员
+func (h *Handlers) requireGroupAdmin(c *gin.Context) (uint, *models.User, bool) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return 0, nil, false
+	}
+	gid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid group id"})
+		return 0, nil, false
+	}
+	if !models.GroupExists(h.db, uint(gid)) {
+		response.AbortWithStatusJSON(c, http.StatusNotFound, models.ErrGroupNotFound)
+		return 0, nil, false
+	}
+	if !user.IsSuperUser && !models.IsGroupAdmin(h.db, uint(gid), user.ID) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "group admin required"})
+		return 0, nil, false
+	}
+	return uint(gid), user, true
+}
+
+// handleGroupModeration 处理禁言/踢出/封禁/解封请求
+func (h *Handlers) handleGroupModeration(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		groupID, operator, ok := h.requireGroupAdmin(c)
+		if !ok {
+			return
+		}
+		var req ModerationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.UserID == operator.ID {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "cannot moderate yourself"})
+			return
+		}
+		// 群组管理员之间不能互相处置，只有超级用户可以
+		if !operator.IsSuperUser && models.IsGroupAdmin(h.db, groupID, req.UserID) {
+			response.AbortWithStatusJSON(c, http.StatusForbidden, models.ErrModerateGroupAdmin)
+			return
+		}
+
+		var (
+			event *models.GroupModerationEvent
+			err   error
+		)
+		switch action {
+		case models.ModerationActionMute:
+			event, err = models.MuteGroupMember(h.db, groupID, req.UserID, operator.ID, time.Duration(req.Duration)*time.Second, req.Reason)
+		case models.ModerationActionKick:
+			event, err = models.KickGroupMember(h.db, groupID, req.UserID, operator.ID, req.Reason)
+		case models.ModerationActionBan:
+			event, err = models.BanGroupMember(h.db, groupID, req.UserID, operator.ID, req.Reason)
+		case models.ModerationActionUnban:
+			event, err = models.UnbanGroupMember(h.db, groupID, req.UserID, operator.ID, req.Reason)
+		default:
+			err = errors.New("unknown moderation action")
+		}
+		if err != nil {
+			response.AbortWithStatusJSON(c, moderationErrorStatus(err), err)
+			return
+		}
+
+		wsEvent := websocket.ModerationEvent{
+			Action:   event.Action,
+			Group:    strconv.FormatUint(uint64(groupID), 10),
+			UserID:   strconv.FormatUint(uint64(req.UserID), 10),
+			Operator: strconv.FormatUint(uint64(operator.ID), 10),
+			Reason:   event.Reason,
+		}
+		if event.ExpiresAt != nil {
+			wsEvent.ExpiresAt = event.ExpiresAt.Unix()
+		}
+		if event.Action == models.ModerationActionKick || event.Action == models.ModerationActionBan {
+			h.wsHub.KickFromGroup(wsEvent.Group, wsEvent.UserID, wsEvent)
+		} else {
+			h.wsHub.NotifyModeration(wsEvent)
+		}
+
+		util.Sig().Emit(models.SigGroupModeration, event, c)
+		response.Success(c, "success", event)
+	}
+}
+
+// moderationErrorStatus 管理操作错误对应的 HTTP 状态码
+func moderationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrGroupMemberNotFound), errors.Is(err, models.ErrMemberNotBanned):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrModerateGroupAdmin):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// handleListGroupModerationEvents 获取群组管理历史
+func (h *Handlers) handleListGroupModerationEvents(c *gin.Context) {
+	groupID, _, ok := h.requireGroupAdmin(c)
+	if !ok {
+		return
+	}
+	pos, _ := strconv.Atoi(c.DefaultQuery("pos", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	events, total, err := models.ListGroupModerationEvents(h.db, groupID, pos, limit)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", gin.H{
+		"list":  events,
+		"total": total,
+	})
+}
@@ -2,13 +2,26 @@ package handlers
 
 import (
 	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/cache"
+	"HibiscusIM/pkg/config"
+	"HibiscusIM/pkg/logger"
 	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/queue"
 	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/scheduler"
+	"HibiscusIM/pkg/sse"
+	"HibiscusIM/pkg/util"
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // GetUnReadNotificationCount get user unread notification count
@@ -149,3 +162,250 @@ func (h *Handlers) handleDeleteNotification(c *gin.Context) {
 	}
 	response.Success(c, "Notification deleted", nil)
 }
+
+// handleNotificationStream 通过SSE推送未读数变化
+func (h *Handlers) handleNotificationStream(c *gin.Context) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	group := unreadStreamGroup(user.ID)
+	clientID := fmt.Sprintf("%s:%d", group, time.Now().UnixNano())
+	h.sseHub.ServeWithGroups(c, clientID, group)
+}
+
+func unreadStreamGroup(userID uint) string {
+	return fmt.Sprintf("notification:%d", userID)
+}
+
+// initUnreadCounter 初始化未读计数器，并通过 WS/SSE 推送计数变化
+func initUnreadCounter(db *gorm.DB, wsHub *websocket.Hub, sseHub *sse.Hub) {
+	counter := notification.NewUnreadCounter(db, newCounterCache("NOTIFICATION_COUNTER_CACHE"), time.Hour)
+	counter.OnChange(func(userID uint, count int64) {
+		payload := gin.H{"unread": count}
+		wsHub.SendToUser(strconv.FormatUint(uint64(userID), 10), &websocket.Message{
+			Type: websocket.MessageTypeUnreadCount,
+			Data: payload,
+		})
+		sseHub.SendToGroup(unreadStreamGroup(userID), fmt.Sprintf(`{"type":%q,"data":{"unread":%d}}`, websocket.MessageTypeUnreadCount, count))
+	})
+	counter.StartReconciler(context.Background(), 10*time.Minute)
+	notification.SetUnreadCounter(counter)
+}
+
+// initNotificationDispatcher 初始化站内通知分发：在线用户通过 WS/SSE 推送，离线时回退为邮件
+func initNotificationDispatcher(db *gorm.DB, wsHub *websocket.Hub, sseHub *sse.Hub) *notification.Dispatcher {
+	d := notification.NewDispatcher(db)
+	d.AddPusher(notification.ChannelWebSocket, wsNotificationPusher{hub: wsHub})
+	d.AddPusher(notification.ChannelSSE, sseNotificationPusher{hub: sseHub})
+	if config.GlobalConfig.Mail.Host != "" {
+		d.SetMailFallback(notification.NewMailNotification(config.GlobalConfig.Mail), func(userID uint) (string, error) {
+			var user models.User
+			if err := db.Select("email", "email_notifications").First(&user, userID).Error; err != nil {
+				return "", err
+			}
+			// 用户在账号设置中关闭了邮件通知
+			if !user.EmailNotifications {
+				return "", nil
+			}
+			return user.Email, nil
+		})
+	}
+	notification.SetDispatcher(d)
+	notification.SetOutboxRelay(notification.NewOutboxRelay(db, queue.GetGlobalQueue()))
+	return d
+}
+
+// wsNotificationPusher 通过 WebSocket 推送站内通知
+type wsNotificationPusher struct {
+	hub *websocket.Hub
+}
+
+func (p wsNotificationPusher) Push(userID uint, n *notification.InternalNotification) bool {
+	uid := strconv.FormatUint(uint64(userID), 10)
+	if p.hub.GetUserConnections(uid) == 0 {
+		return false
+	}
+	p.hub.SendToUser(uid, &websocket.Message{
+		Type:      websocket.MessageTypeNotification,
+		Data:      n,
+		Timestamp: n.CreatedAt.Unix(),
+	})
+	return true
+}
+
+// sseNotificationPusher 通过通知 SSE 流推送站内通知
+type sseNotificationPusher struct {
+	hub *sse.Hub
+}
+
+func (p sseNotificationPusher) Push(userID uint, n *notification.InternalNotification) bool {
+	group := unreadStreamGroup(userID)
+	if p.hub.GroupSize(group) == 0 {
+		return false
+	}
+	data, err := json.Marshal(gin.H{"type": websocket.MessageTypeNotification, "data": n})
+	if err != nil {
+		return false
+	}
+	p.hub.SendToGroup(group, string(data))
+	return true
+}
+
+// handleGetNotificationPreferences 获取当前用户的通知渠道偏好
+func (h *Handlers) handleGetNotificationPreferences(c *gin.Context) {
+	user := models.CurrentUser(c)
+	pref, err := h.notifications.Preference(c.Request.Context(), user.ID)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", pref)
+}
+
+// handleUpdateNotificationPreferences 更新当前用户的通知渠道偏好，未提供的字段保持不变
+func (h *Handlers) handleUpdateNotificationPreferences(c *gin.Context) {
+	var req struct {
+		WebSocket  *bool `json:"websocket"`
+		SSE        *bool `json:"sse"`
+		Email      *bool `json:"email"`
+		Digest     *bool `json:"digest"`
+		DigestHour *int  `json:"digest_hour"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+	if req.DigestHour != nil && (*req.DigestHour < 0 || *req.DigestHour > 23) {
+		response.Fail(c, "Invalid request", "digest_hour must be between 0 and 23")
+		return
+	}
+	user := models.CurrentUser(c)
+	pref, err := h.notifications.Preference(c.Request.Context(), user.ID)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	if req.WebSocket != nil {
+		pref.WebSocket = *req.WebSocket
+	}
+	if req.SSE != nil {
+		pref.SSE = *req.SSE
+	}
+	if req.Email != nil {
+		pref.Email = *req.Email
+	}
+	if req.Digest != nil {
+		pref.Digest = *req.Digest
+	}
+	if req.DigestHour != nil {
+		pref.DigestHour = *req.DigestHour
+	}
+	if err := h.notifications.SetPreference(c.Request.Context(), pref); err != nil {
+		response.Fail(c, "update notification preferences failed", err)
+		return
+	}
+	response.Success(c, "success", pref)
+}
+
+// startNotificationJobs 每分钟发送到期的定时通知，并按各用户当地时间发送每日未读摘要；
+// 每 5 秒投递发件箱中提交后未能立即投递的通知
+func startNotificationJobs(db *gorm.DB, d *notification.Dispatcher) *scheduler.Scheduler {
+	s := scheduler.New()
+	if relay := notification.GetOutboxRelay(); relay != nil {
+		s.Every(5*time.Second, scheduler.FuncJob(func(ctx context.Context) {
+			if _, err := relay.Poll(ctx); err != nil {
+				logger.Warn("poll notification outbox failed", zap.Error(err))
+			}
+		}))
+	}
+	service := notification.NewInternalNotificationService(db)
+	locate := func(userIDs []uint) map[uint]*time.Location { return models.UserLocations(db, userIDs) }
+	s.Every(time.Minute, scheduler.FuncJob(func(ctx context.Context) {
+		now := time.Now()
+		if _, err := service.DeliverDue(ctx, now); err != nil {
+			logger.Warn("deliver scheduled notifications failed", zap.Error(err))
+		}
+		if _, err := d.SendDigests(ctx, now, locate); err != nil {
+			logger.Warn("send notification digests failed", zap.Error(err))
+		}
+	}))
+	return s
+}
+
+// handleListScheduledNotifications 当前用户尚未发送的定时通知
+func (h *Handlers) handleListScheduledNotifications(c *gin.Context) {
+	user := models.CurrentUser(c)
+	var items []notification.ScheduledNotification
+	if err := h.db.Where("user_id = ? AND sent_at IS NULL", user.ID).Order("deliver_at").Find(&items).Error; err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", items)
+}
+
+// handleScheduleNotification 按当前用户时区的当地时间预约一条提醒通知
+func (h *Handlers) handleScheduleNotification(c *gin.Context) {
+	var req struct {
+		Title     string `json:"title" binding:"required"`
+		Content   string `json:"content"`
+		LocalTime string `json:"local_time" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+	user := models.CurrentUser(c)
+	loc := user.Location()
+	at, err := notification.ParseLocalTime(req.LocalTime, loc)
+	if err != nil {
+		response.Fail(c, "Invalid request", err)
+		return
+	}
+	if !at.After(time.Now()) {
+		response.Fail(c, "Invalid request", "local_time must be in the future")
+		return
+	}
+	scheduled, err := notification.NewInternalNotificationService(h.db).ScheduleLocal(user.ID, req.Title, req.Content, req.LocalTime, loc)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", scheduled)
+}
+
+// handleCancelScheduledNotification 取消当前用户尚未发送的定时通知
+func (h *Handlers) handleCancelScheduledNotification(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "Invalid request", "invalid id")
+		return
+	}
+	user := models.CurrentUser(c)
+	if err := notification.NewInternalNotificationService(h.db).CancelScheduled(user.ID, uint(id)); err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", nil)
+}
+
+// newCounterCache 按 typeEnv 指定的类型创建计数缓存，Redis 不可用时退回本地缓存
+func newCounterCache(typeEnv string) cache.Cache {
+	c, err := cache.NewCache(cache.Config{
+		Type: util.GetEnv(typeEnv),
+		Redis: cache.RedisConfig{
+			Addr:     util.GetEnv("REDIS_ADDR"),
+			Password: util.GetEnv("REDIS_PASSWORD"),
+			DB:       int(util.GetIntEnv("REDIS_DB")),
+		},
+	})
+	if err != nil {
+		c = cache.NewLocalCache(cache.LocalConfig{
+			MaxSize:           10000,
+			DefaultExpiration: time.Hour,
+			CleanupInterval:   10 * time.Minute,
+		})
+	}
+	return c
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/geoip"
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/util"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+)
+
+// initOperationLog 按 OPERATION_LOG_ENABLED 启用接口操作日志，异步批量写入 operation_logs 表。
+// OPERATION_LOG_SAMPLE_RATE 为采样率（0~1，默认全部记录），OPERATION_LOG_EXCLUDE_PATHS 为逗号分隔的不记录路径前缀，
+// OPERATION_LOG_BUFFER 为队列长度
+func initOperationLog(db *gorm.DB) *middleware.OperationLogger {
+	if !util.GetBoolEnv("OPERATION_LOG_ENABLED") {
+		return nil
+	}
+	var exclude []string
+	for _, p := range strings.Split(util.GetEnv("OPERATION_LOG_EXCLUDE_PATHS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			exclude = append(exclude, p)
+		}
+	}
+	return middleware.NewOperationLogger(db, middleware.OperationLogConfig{
+		BufferSize:    int(util.GetIntEnv("OPERATION_LOG_BUFFER")),
+		FlushInterval: time.Second,
+		SampleRate:    cast.ToFloat64(util.GetEnv("OPERATION_LOG_SAMPLE_RATE")),
+		ExcludePaths:  exclude,
+		User:          operationLogUser,
+		Locate:        geoip.Default().City,
+	})
+}
+
+// operationLogUser 当前登录用户，未登录时为匿名
+func operationLogUser(c *gin.Context) (*int64, string) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		return nil, ""
+	}
+	id := int64(user.ID)
+	return &id, user.Email
+}
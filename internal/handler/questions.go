@@ -5,6 +5,7 @@ import (
 	"HibiscusIM/pkg/response"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +17,16 @@ func (h *Handlers) handleWriteQuestionnaire(context *gin.Context) {
 		return
 	}
 	user := models.CurrentUser(context)
+	questionnaire, err := models.GetQuestionnaire(h.db, req.QuestionnaireID)
+	if err != nil {
+		response.AbortWithStatusJSON(context, http.StatusNotFound, err)
+		return
+	}
+	// 开放日期与每日时段按答题用户的时区判断
+	if !questionnaire.AvailableAt(time.Now(), user.Location()) {
+		response.AbortWithStatusJSON(context, http.StatusForbidden, models.ErrQuestionnaireUnavailable)
+		return
+	}
 	res, err := models.SubmitUserResponse(h.db, user.ID, req.QuestionnaireID, req.Answers)
 	if err != nil {
 		response.Fail(context, "error", gin.H{"error": err.Error()})
@@ -23,6 +34,7 @@ func (h *Handlers) handleWriteQuestionnaire(context *gin.Context) {
 	}
 	if res != nil {
 		response.Success(context, "success", gin.H{"data": res})
+		return
 	}
 	response.Fail(context, "failed", gin.H{})
 }
@@ -46,3 +58,23 @@ func (h *Handlers) handleGetQuestionResponseById(context *gin.Context) {
 	}
 	response.Success(context, "success", gin.H{"responses": responses})
 }
+
+// handleGetQuestionnaire 问卷定义及其问题
+func (h *Handlers) handleGetQuestionnaire(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.Fail(c, "error", "Invalid questionnaire ID")
+		return
+	}
+	questionnaire, err := models.GetQuestionnaire(h.db, uint(id))
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusNotFound, err)
+		return
+	}
+	questions, err := models.GetQuestionsByQuestionnaire(h.db, questionnaire.ID)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", gin.H{"questionnaire": questionnaire, "questions": questions})
+}
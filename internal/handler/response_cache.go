@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/util"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// initResponseCache 按 RESPONSE_CACHE_TTL（秒）启用只读接口的响应缓存，为 0 时不缓存；
+// 缓存类型由 RESPONSE_CACHE 指定（redis 或本地），依赖的表写入后自动失效
+func initResponseCache(db *gorm.DB) *middleware.ResponseCache {
+	ttl := util.GetIntEnv("RESPONSE_CACHE_TTL")
+	if ttl <= 0 {
+		return nil
+	}
+	rc := middleware.NewResponseCache(db, middleware.ResponseCacheConfig{
+		Cache: newCounterCache("RESPONSE_CACHE"),
+		TTL:   time.Duration(ttl) * time.Second,
+	})
+	if err := rc.RegisterHooks(db); err != nil {
+		logger.Warn("response cache disabled, register hooks failed", zap.Error(err))
+		return nil
+	}
+	return rc
+}
+
+// cacheResponse 缓存依赖 models 的 GET 响应，未启用响应缓存时直接放行
+func (h *Handlers) cacheResponse(models ...interface{}) gin.HandlerFunc {
+	if h.responseCache == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return h.responseCache.Handler(models...)
+}
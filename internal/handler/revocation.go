@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/util"
+	"HibiscusIM/pkg/websocket"
+	"strconv"
+)
+
+// initAuthRevocation 用户登出、被禁用或删除时立即断开其 WebSocket 连接
+func initAuthRevocation(hub *websocket.Hub) {
+	revoke := func(sender any, reason string) {
+		user, ok := sender.(*models.User)
+		if !ok || user == nil {
+			return
+		}
+		hub.RevokeUser(strconv.FormatUint(uint64(user.ID), 10), reason)
+	}
+
+	util.Sig().Connect(models.SigUserLogout, func(sender any, params ...any) {
+		revoke(sender, "logout")
+	})
+	util.Sig().Connect(models.SigUserAuthRevoked, func(sender any, params ...any) {
+		reason := "revoked"
+		if len(params) > 0 {
+			if r, ok := params[0].(string); ok && r != "" {
+				reason = r
+			}
+		}
+		revoke(sender, reason)
+	})
+}
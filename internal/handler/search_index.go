@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/config"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/search"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// initSearchIndexer 注册需要自动同步到搜索索引的模型
+func initSearchIndexer(db *gorm.DB, engine search.Engine) (*search.Indexer, error) {
+	indexer := search.NewIndexer(engine, search.DefaultIndexerOptions())
+	err := indexer.Register(db,
+		search.IndexedModel{
+			Model: &models.Group{},
+			Type:  "group",
+			Fields: func(obj interface{}) map[string]interface{} {
+				g := obj.(*models.Group)
+				return map[string]interface{}{
+					"name":              g.Name,
+					"groupType":         g.Type,
+					"createdAt":         g.CreatedAt,
+					"groupId":           strconv.FormatUint(uint64(g.ID), 10),
+					search.FieldSuggest: search.SuggestKeys(g.Name),
+				}
+			},
+		},
+		search.IndexedModel{
+			Model:  &models.User{},
+			Type:   "user",
+			Fields: userSearchFields,
+		},
+		search.IndexedModel{
+			Model: &models.Questionnaire{},
+			Type:  "questionnaire",
+			Fields: func(obj interface{}) map[string]interface{} {
+				q := obj.(*models.Questionnaire)
+				return map[string]interface{}{
+					"title":       q.Title,
+					"description": q.Description,
+					"createdAt":   q.CreatedAt,
+				}
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	indexer.Start()
+	return indexer, nil
+}
+
+// initSearchDictionaries 从配置的文件与 search_dictionary_entries 表加载同义词和停用词，
+// 加载失败时以空词典启动，修正后可通过 /search/dictionaries/reload 重载
+func initSearchDictionaries(ctx context.Context, db *gorm.DB) *search.Dictionaries {
+	dictionaries := search.NewDictionaries(
+		search.FileDictionarySource{
+			SynonymsPath:  config.GlobalConfig.SearchSynonyms,
+			StopwordsPath: config.GlobalConfig.SearchStopwords,
+		},
+		search.DBDictionarySource{DB: db},
+	)
+	if stats, err := dictionaries.Reload(ctx); err != nil {
+		logger.Warn("load search dictionaries failed", zap.Error(err))
+	} else {
+		logger.Info("search dictionaries loaded", zap.Int("synonyms", stats.Synonyms), zap.Int("stopwords", stats.Stopwords))
+	}
+	return dictionaries
+}
+
+// ReindexSearch 从数据库全量重建模型索引
+func (h *Handlers) ReindexSearch(ctx context.Context) (search.ReindexResult, error) {
+	if h.searchIndexer == nil {
+		return search.ReindexResult{}, errors.New("search is disabled")
+	}
+	return h.searchIndexer.Reindex(ctx)
+}
+
+// StartSearch 打开搜索引擎并启动模型索引同步与聊天消息索引，未启用搜索时不做任何事；
+// 失败时已打开的资源会被关闭，调用方可据此降级为不提供搜索
+func (h *Handlers) StartSearch(ctx context.Context) error {
+	if !config.GlobalConfig.SearchEnabled || h.searchEngine != nil {
+		return nil
+	}
+	dictionaries := initSearchDictionaries(ctx, h.db)
+	cfg := search.Config{
+		Driver:       config.GlobalConfig.SearchDriver,
+		IndexPath:    config.GlobalConfig.SearchPath,
+		IndexDir:     config.GlobalConfig.SearchIndexDir,
+		QueryTimeout: 5 * time.Second,
+		OpenTimeout:  10 * time.Second,
+		BatchSize:    config.GlobalConfig.SearchBatchSize,
+		Elasticsearch: search.ElasticsearchConfig{
+			Addresses: strings.Split(config.GlobalConfig.SearchESAddrs, ","),
+			Index:     config.GlobalConfig.SearchESIndex,
+			Username:  config.GlobalConfig.SearchESUser,
+			Password:  config.GlobalConfig.SearchESPass,
+			APIKey:    config.GlobalConfig.SearchESAPIKey,
+		},
+		Dictionaries: dictionaries,
+	}
+	// 映射迁移切换过的索引优先于配置；多索引模式下命名索引共用目录，不支持迁移
+	var migration *searchMigration
+	if cfg.IndexDir == "" {
+		migration = &searchMigration{cfg: cfg}
+		if location, err := search.ActiveIndexLocation(ctx, h.db); err != nil {
+			logger.Warn("load active search index failed, using configured index", zap.Error(err))
+		} else if location != "" {
+			cfg = migration.at(location, nil)
+		}
+	}
+	engine, err := search.New(cfg, search.BuildIndexMapping("", searchUnstoredFields()...))
+	if err != nil {
+		return err
+	}
+	pipeline := searchEnrichPipeline()
+	var migrator *search.Migrator
+	if migration != nil {
+		migrator = search.NewMigrator(engine, h.db, migration.location(cfg), search.MigrationOptions{
+			Open:     migration.open,
+			Location: migration.versionLocation,
+			// 消息文档的 createdAt 为写入索引的时间，回填时只能使用入库时间
+			IgnoreFields: []string{"createdAt"},
+			Enrich:       pipeline,
+		})
+		engine = migrator
+	}
+	engine = search.NewEnrichedEngine(engine, pipeline)
+	// 未存储的正文字段回源补齐，放在缓存内侧，缓存的结果已带片段
+	snippets := search.NewSnippetEngine(engine, pipeline)
+	if h.messages != nil {
+		snippets.Register(messageDocType, messageSnippetSource(h.messages), "text")
+	}
+	engine = snippets
+	if ttl := config.GlobalConfig.SearchCacheTTL; ttl > 0 {
+		engine = search.NewCachedEngine(engine, newCounterCache("SEARCH_CACHE"), search.CacheConfig{TTL: time.Duration(ttl) * time.Second})
+	}
+	indexer, err := initSearchIndexer(h.db, engine)
+	if err != nil {
+		_ = engine.Close()
+		return err
+	}
+	recordReindexEvents(indexer)
+	snippets.Register("questionnaire", indexer.SnippetSource("questionnaire"), "title", "description")
+	if migrator != nil {
+		migrator.AddSource(indexer)
+		if h.messages != nil {
+			migrator.AddSource(messageBackfill{repo: h.messages})
+		}
+	}
+
+	handler := search.NewSearchHandlers(engine)
+	handler.SetIndexer(indexer)
+	progress := search.NewProgressTracker(time.Second, 20)
+	progress.OnUpdate(h.publishSearchProgress)
+	handler.SetProgressTracker(progress)
+	handler.SetDictionaries(dictionaries)
+	if migrator != nil {
+		handler.SetMigrator(migrator)
+	}
+	if config.GlobalConfig.SearchFeedback {
+		handler.SetFeedback(search.NewFeedback(h.db, float64(config.GlobalConfig.SearchClickBoost)/100))
+	}
+	handler.SetAdminAuthorizer(func(c *gin.Context) bool {
+		user := models.CurrentUser(c)
+		return user != nil && (user.IsStaff || user.IsSuperUser)
+	})
+	if field := config.GlobalConfig.SearchACLField; field != "" {
+		handler.SetAccessResolver(searchAccessResolver(h.db, field))
+	}
+	// 聊天消息只能通过校验会话成员关系的会话内搜索查询
+	handler.SetPrivateTypes(messageDocType)
+
+	h.searchEngine = engine
+	h.searchIndexer = indexer
+	h.searchHandler = handler
+	typeaheadCache := newCounterCache("SEARCH_TYPEAHEAD_CACHE")
+	h.searchTypeahead = search.NewTypeahead(engine, search.TypeaheadConfig{Cache: typeaheadCache})
+	h.typeaheadVisibility = &typeaheadVisibility{db: h.db, cache: typeaheadCache}
+	h.messageSearch = initMessageSearch(engine, h.wsHub)
+	return nil
+}
+
+// searchUnstoredFields SEARCH_UNSTORED_FIELDS 配置的不存储原文的字段，如 message.text
+func searchUnstoredFields() []string {
+	var fields []string
+	for _, f := range strings.Split(config.GlobalConfig.SearchUnstored, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// searchEnrichPipeline 写入索引前统一处理原始内容：去掉 HTML、识别语言、计算字数与阅读时长，
+// 开启 SEARCH_MASK_PII 时对正文字段脱敏，会话、发送者等标识字段不处理
+func searchEnrichPipeline() *search.EnrichPipeline {
+	p := search.NewEnrichPipeline()
+	p.Register("article",
+		search.StripHTML("title", "body"),
+		search.DetectLanguage("title", "body"),
+		search.DerivedFields("body"),
+	)
+	p.Register("questionnaire",
+		search.StripHTML("description"),
+		search.DetectLanguage("title", "description"),
+	)
+	p.Register(messageDocType, search.DetectLanguage("text"))
+	if config.GlobalConfig.SearchMaskPII {
+		p.Register("article", search.MaskPII(nil, "title", "body"))
+		p.Register("questionnaire", search.MaskPII(nil, "description"))
+		p.Register(messageDocType, search.MaskPII(nil, "text"))
+	}
+	return p
+}
+
+// searchAccessResolver 按文档的 field 字段做访问控制：管理员不受限，其余用户可见 public、
+// user:<用户ID> 以及所在群组 group:<群组ID> 的文档，未登录时只可见 public
+func searchAccessResolver(db *gorm.DB, field string) func(c *gin.Context) *search.AccessFilter {
+	return func(c *gin.Context) *search.AccessFilter {
+		access := &search.AccessFilter{Field: field, Principals: []string{"public"}}
+		user := models.CurrentUser(c)
+		if user == nil {
+			return access
+		}
+		if user.IsStaff || user.IsSuperUser {
+			return nil
+		}
+		access.Principals = append(access.Principals, fmt.Sprintf("user:%d", user.ID))
+		groupIDs, err := models.UserGroupIDs(db, user.ID)
+		if err != nil {
+			logger.Warn("load search access groups failed", zap.Uint("user_id", user.ID), zap.Error(err))
+		}
+		for _, id := range groupIDs {
+			access.Principals = append(access.Principals, fmt.Sprintf("group:%d", id))
+		}
+		return access
+	}
+}
+
+// searchMigration 按驱动生成映射迁移的新索引：bleve 为配置路径加版本后缀的目录，
+// Elasticsearch 为配置索引名加版本后缀的索引
+type searchMigration struct {
+	cfg search.Config
+}
+
+// elastic 是否使用 Elasticsearch/OpenSearch 驱动
+func (sm *searchMigration) elastic() bool {
+	driver := strings.ToLower(strings.TrimSpace(sm.cfg.Driver))
+	return driver == search.DriverElasticsearch || driver == search.DriverOpenSearch
+}
+
+// versionLocation 迁移版本的索引位置
+func (sm *searchMigration) versionLocation(version uint) string {
+	if sm.elastic() {
+		return fmt.Sprintf("%s_v%d", sm.cfg.Elasticsearch.Index, version)
+	}
+	return fmt.Sprintf("%s.v%d", sm.cfg.IndexPath, version)
+}
+
+// location 配置对应的索引位置
+func (sm *searchMigration) location(cfg search.Config) string {
+	if sm.elastic() {
+		return cfg.Elasticsearch.Index
+	}
+	return cfg.IndexPath
+}
+
+// at 位于 location 的索引配置，mapping 为 Elasticsearch 的索引创建请求体
+func (sm *searchMigration) at(location string, mapping []byte) search.Config {
+	cfg := sm.cfg
+	if sm.elastic() {
+		cfg.Elasticsearch.Index = location
+		if len(mapping) > 0 {
+			cfg.Elasticsearch.IndexBody = mapping
+		}
+	} else {
+		cfg.IndexPath = location
+	}
+	return cfg
+}
+
+// open 在 location 创建新索引，bleve 的 mapping 为空时使用内置映射
+func (sm *searchMigration) open(ctx context.Context, location string, mapping []byte) (search.Engine, error) {
+	m := search.BuildIndexMapping("", searchUnstoredFields()...)
+	if len(mapping) > 0 && !sm.elastic() {
+		parsed, err := search.ParseIndexMapping(mapping)
+		if err != nil {
+			return nil, err
+		}
+		m = parsed
+	}
+	return search.New(sm.at(location, mapping), m)
+}
+
+// SearchHealth 搜索引擎可用时返回 nil，未启动时返回错误
+func (h *Handlers) SearchHealth(ctx context.Context) error {
+	if h.searchEngine == nil {
+		if !config.GlobalConfig.SearchEnabled {
+			return nil
+		}
+		return errors.New("search is not started")
+	}
+	_, err := h.searchEngine.IndexStats(ctx)
+	return err
+}
+
+// StopSearch 停止消息与模型索引同步，提交剩余变更后关闭搜索引擎
+func (h *Handlers) StopSearch(ctx context.Context) error {
+	if h.searchEngine == nil {
+		return nil
+	}
+	h.wsHub.SetMessageArchiver(nil)
+	h.messageSearch.close()
+	h.searchIndexer.Close()
+	return h.searchEngine.Close()
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/search"
+	"HibiscusIM/pkg/websocket"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// searchProgressGroup 索引任务进度的 SSE 分组
+	searchProgressGroup = "search:progress"
+	// searchProgressMessageType 索引任务进度的推送消息类型
+	searchProgressMessageType = "search_progress"
+)
+
+// publishSearchProgress 推送索引任务进度：WebSocket 发给在线的管理员，
+// SSE 发给订阅了 /system/search/progress/stream 的客户端
+func (h *Handlers) publishSearchProgress(p search.Progress) {
+	if data, err := json.Marshal(gin.H{"type": searchProgressMessageType, "data": p}); err == nil {
+		h.sseHub.SendToGroup(searchProgressGroup, string(data))
+	}
+
+	var admins []uint
+	if err := h.db.Model(&models.User{}).Where("(is_staff = ? OR is_super_user = ?) AND enabled = ?", true, true, true).Pluck("id", &admins).Error; err != nil {
+		logger.Warn("load search progress subscribers failed", zap.Error(err))
+		return
+	}
+	for _, id := range admins {
+		uid := strconv.FormatUint(uint64(id), 10)
+		if h.wsHub.GetUserConnections(uid) == 0 {
+			continue
+		}
+		h.wsHub.SendToUser(uid, &websocket.Message{
+			Type:      searchProgressMessageType,
+			Data:      p,
+			Timestamp: p.UpdatedAt.Unix(),
+		})
+	}
+}
+
+// handleSearchProgressStream 通过 SSE 推送索引任务进度
+func (h *Handlers) handleSearchProgressStream(c *gin.Context) {
+	clientID := fmt.Sprintf("%s:%d", searchProgressGroup, time.Now().UnixNano())
+	h.sseHub.ServeWithGroups(c, clientID, searchProgressGroup)
+}
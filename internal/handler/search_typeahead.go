@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/cache"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/search"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+)
+
+const (
+	// typeaheadVisibleTTL 用户可见范围的缓存时长，输入联想每次按键都会请求
+	typeaheadVisibleTTL = 30 * time.Second
+	// typeaheadMaxPeers 可见用户数上限，超出的用户不出现在联想中
+	typeaheadMaxPeers = 5000
+)
+
+// TypeaheadHit 输入联想结果
+type TypeaheadHit struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// userSearchFields 同步到搜索索引的用户字段，联想只使用公开资料，不索引邮箱与手机号
+func userSearchFields(obj interface{}) map[string]interface{} {
+	u := obj.(*models.User)
+	name := userDisplayName(u)
+	return map[string]interface{}{
+		"name":              name,
+		"avatar":            u.Avatar,
+		"userId":            strconv.FormatUint(uint64(u.ID), 10),
+		search.FieldSuggest: search.SuggestKeys(name, u.FirstName, u.LastName),
+	}
+}
+
+// userDisplayName 显示名，未设置时使用姓名
+func userDisplayName(u *models.User) string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// typeaheadVisibility 按当前用户计算联想的可见范围：管理员不受限；
+// 用户可见自己、同群组成员与私聊过的用户，群组只可见已加入的群组
+type typeaheadVisibility struct {
+	db    *gorm.DB
+	cache cache.Cache
+}
+
+// users 可见用户的过滤条件
+func (v *typeaheadVisibility) users(ctx context.Context, user *models.User) (*search.AccessFilter, error) {
+	if user.IsStaff || user.IsSuperUser {
+		return nil, nil
+	}
+	ids, err := v.cached(ctx, "typeahead:users:"+strconv.FormatUint(uint64(user.ID), 10), func() ([]string, error) {
+		self := strconv.FormatUint(uint64(user.ID), 10)
+		peers, err := models.GroupPeerIDs(v.db, user.ID, typeaheadMaxPeers)
+		if err != nil {
+			return nil, err
+		}
+		contacts, err := models.DirectPeerIDs(v.db, self, typeaheadMaxPeers)
+		if err != nil {
+			return nil, err
+		}
+		ids := []string{self}
+		seen := map[string]bool{self: true}
+		add := func(id string) {
+			if !seen[id] && len(ids) <= typeaheadMaxPeers {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		// 私聊过的用户优先，超出上限时丢弃的是较远的群组成员
+		for _, id := range contacts {
+			add(id)
+		}
+		for _, id := range peers {
+			add(strconv.FormatUint(uint64(id), 10))
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &search.AccessFilter{Field: "userId", Principals: ids}, nil
+}
+
+// groups 可见群组的过滤条件
+func (v *typeaheadVisibility) groups(ctx context.Context, user *models.User) (*search.AccessFilter, error) {
+	if user.IsStaff || user.IsSuperUser {
+		return nil, nil
+	}
+	ids, err := v.cached(ctx, "typeahead:groups:"+strconv.FormatUint(uint64(user.ID), 10), func() ([]string, error) {
+		groupIDs, err := models.UserGroupIDs(v.db, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(groupIDs))
+		for _, id := range groupIDs {
+			ids = append(ids, strconv.FormatUint(uint64(id), 10))
+		}
+		return ids, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &search.AccessFilter{Field: "groupId", Principals: ids}, nil
+}
+
+// cached 读取缓存的ID列表，未命中时调用 load 并以逗号分隔写入缓存
+func (v *typeaheadVisibility) cached(ctx context.Context, key string, load func() ([]string, error)) ([]string, error) {
+	if raw, ok := v.cache.Get(ctx, key); ok {
+		if s := cast.ToString(raw); s != "" {
+			return strings.Split(s, ","), nil
+		}
+	}
+	ids, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		_ = v.cache.Set(ctx, key, strings.Join(ids, ","), typeaheadVisibleTTL)
+	}
+	return ids, nil
+}
+
+// handleSearchUsers 用户输入联想，query: q 前缀，limit 条数（默认 10，上限 50）；
+// 只返回当前用户可见的用户
+func (h *Handlers) handleSearchUsers(c *gin.Context) {
+	h.typeahead(c, "user", h.typeaheadVisibility.users)
+}
+
+// handleSearchGroups 群组输入联想，只返回当前用户已加入的群组
+func (h *Handlers) handleSearchGroups(c *gin.Context) {
+	h.typeahead(c, "group", h.typeaheadVisibility.groups)
+}
+
+func (h *Handlers) typeahead(c *gin.Context, docType string, visible func(ctx context.Context, user *models.User) (*search.AccessFilter, error)) {
+	user := models.CurrentUser(c)
+	if user == nil {
+		response.AbortWithStatus(c, http.StatusUnauthorized)
+		return
+	}
+	if h.searchTypeahead == nil {
+		response.AbortWithStatusJSON(c, http.StatusServiceUnavailable, errors.New("search is disabled"))
+		return
+	}
+	access, err := visible(c.Request.Context(), user)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	hits, err := h.searchTypeahead.Suggest(c.Request.Context(), search.TypeaheadRequest{
+		Type:          docType,
+		Prefix:        c.Query("q"),
+		Limit:         cast.ToInt(c.Query("limit")),
+		IncludeFields: []string{"name", "avatar"},
+		Access:        access,
+	})
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	items := make([]TypeaheadHit, 0, len(hits))
+	for _, hit := range hits {
+		_, id, _ := strings.Cut(hit.ID, ":")
+		items = append(items, TypeaheadHit{
+			ID:     id,
+			Name:   cast.ToString(hit.Fields["name"]),
+			Avatar: cast.ToString(hit.Fields["avatar"]),
+		})
+	}
+	response.Success(c, "success", items)
+}
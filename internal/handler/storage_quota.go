@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	hibiscusIM "HibiscusIM"
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/response"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxAvatarSize 头像大小上限
+const maxAvatarSize = 5 << 20
+
+// storageQuotaStatus 配额检查失败时的响应状态码：超出配额 413，其余 500
+func storageQuotaStatus(err error) int {
+	var quotaErr *models.StorageQuotaError
+	if errors.As(err, &quotaErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// notifyStorageQuota 上传使用量首次达到提醒阈值时发送站内通知，before 为上传前的用量
+func (h *Handlers) notifyStorageQuota(userID uint, before *models.StorageUsageSummary, category string, size int64) {
+	if before == nil || !h.storageQuota.CrossesWarning(before, category, size) {
+		return
+	}
+	usage, err := h.storageQuota.Usage(h.db, userID)
+	if err != nil {
+		logger.Warn("load storage usage failed", zap.Uint("userId", userID), zap.Error(err))
+		return
+	}
+	content := fmt.Sprintf("您的存储空间已使用 %.0f%%，达到上限后将无法继续上传录音、头像和附件，请及时清理不需要的文件。", usage.Percent)
+	if err := notification.NewInternalNotificationService(h.db).Send(userID, "存储空间即将用尽", content); err != nil {
+		logger.Warn("send storage quota notification failed", zap.Error(err))
+	}
+}
+
+// handleStorageUsage 当前用户的存储用量与配额，管理员可通过 userId 查询其他用户
+func (h *Handlers) handleStorageUsage(c *gin.Context) {
+	user := models.CurrentUser(c)
+	userID := user.ID
+	if v := c.Query("userId"); v != "" && (user.IsStaff || user.IsSuperUser) {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			response.AbortWithStatusJSON(c, http.StatusBadRequest, err)
+			return
+		}
+		userID = uint(id)
+	}
+	usage, err := h.storageQuota.Usage(h.db, userID)
+	if err != nil {
+		response.AbortWithStatusJSON(c, http.StatusInternalServerError, err)
+		return
+	}
+	response.Success(c, "success", usage)
+}
+
+// handleUploadAvatar 上传头像，替换后删除旧头像，用量按新旧头像大小之差计算
+func (h *Handlers) handleUploadAvatar(c *gin.Context) {
+	user := models.CurrentUser(c)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAvatarSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if file.Size > maxAvatarSize {
+		hibiscusIM.AbortWithJSONError(c, http.StatusRequestEntityTooLarge, errors.New("avatar too large"))
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	defer f.Close()
+	contentType, err := detectContentType(f, file.Filename)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, errors.New("avatar must be an image"))
+		return
+	}
+
+	delta := file.Size - user.AvatarSize
+	before, err := h.storageQuota.Check(h.db, user.ID, models.StorageAvatars, max(delta, 0))
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, storageQuotaStatus(err), err)
+		return
+	}
+
+	store := stores.Default()
+	key := fmt.Sprintf("avatars/%d/%d_%s%s", user.ID, time.Now().UnixNano(), util.RandText(8), filepath.Ext(file.Filename))
+	if err := store.Write(key, f); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	oldKey := user.AvatarKey
+	var objects int64
+	if oldKey == "" {
+		objects = 1
+	}
+	url := store.PublicURL(key)
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := models.UpdateUser(tx, user, map[string]any{"avatar": url, "avatar_key": key, "avatar_size": file.Size}); err != nil {
+			return err
+		}
+		return models.AddStorageUsage(tx, user.ID, models.StorageAvatars, delta, objects)
+	})
+	if err != nil {
+		if dErr := store.Delete(key); dErr != nil {
+			logger.Warn("delete avatar failed", zap.String("key", key), zap.Error(dErr))
+		}
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if oldKey != "" {
+		if err := store.Delete(oldKey); err != nil {
+			logger.Warn("delete old avatar failed", zap.String("key", oldKey), zap.Error(err))
+		}
+	}
+	h.notifyStorageQuota(user.ID, before, models.StorageAvatars, delta)
+	response.Success(c, "avatar uploaded", gin.H{"avatar": url})
+}
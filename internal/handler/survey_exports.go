@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	hibiscusIM "HibiscusIM"
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/config"
+	"HibiscusIM/pkg/export"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/queue"
+	"HibiscusIM/pkg/response"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// taskTypeSurveyExport 问卷结果导出任务
+const taskTypeSurveyExport = "survey.export"
+
+// defaultSurveyExportLinkTTL 下载链接默认有效期
+const defaultSurveyExportLinkTTL = 24 * time.Hour
+
+// surveyExportTask 生成问卷结果导出文件
+type surveyExportTask struct {
+	ExportID uint `json:"exportId"`
+}
+
+// Type 实现 queue.Task
+func (surveyExportTask) Type() string {
+	return taskTypeSurveyExport
+}
+
+// surveyExportSecret 导出签名与匿名标识使用的密钥
+func surveyExportSecret() string {
+	if config.GlobalConfig.SessionSecret != "" {
+		return config.GlobalConfig.SessionSecret
+	}
+	return config.GlobalConfig.APISecretKey
+}
+
+// surveyExportLinkTTL 下载链接有效期，SURVEY_EXPORT_LINK_TTL_HOURS 可覆盖
+func surveyExportLinkTTL() time.Duration {
+	if hours := util.GetIntEnv("SURVEY_EXPORT_LINK_TTL_HOURS"); hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultSurveyExportLinkTTL
+}
+
+// initSurveyExport 注册问卷导出任务处理器
+func initSurveyExport(db *gorm.DB) {
+	if q := queue.GetGlobalQueue(); q != nil {
+		q.Register(taskTypeSurveyExport, func(ctx context.Context, msg *queue.Message) error {
+			var task surveyExportTask
+			if err := msg.Decode(&task); err != nil {
+				return err
+			}
+			err := runSurveyExport(ctx, db, task.ExportID)
+			if err != nil && msg.Attempt >= msg.MaxRetry {
+				failSurveyExport(db, task.ExportID, err)
+			}
+			return err
+		})
+	}
+}
+
+// enqueueSurveyExport 投递导出任务，未启用任务队列时直接异步执行
+func enqueueSurveyExport(db *gorm.DB, id uint) {
+	if q := queue.GetGlobalQueue(); q != nil {
+		_, err := q.Enqueue(context.Background(), surveyExportTask{ExportID: id})
+		if err == nil {
+			return
+		}
+		logger.Warn("enqueue survey export failed, exporting inline", zap.Error(err))
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+		if err := runSurveyExport(ctx, db, id); err != nil {
+			failSurveyExport(db, id, err)
+		}
+	}()
+}
+
+// runSurveyExport 边查询边写入存储，生成完成后通知发起人
+func runSurveyExport(ctx context.Context, db *gorm.DB, id uint) error {
+	var exp models.SurveyExport
+	if err := db.First(&exp, id).Error; err != nil {
+		return err
+	}
+	if exp.Status == models.SurveyExportDone {
+		return nil
+	}
+	if err := models.MarkSurveyExport(db, exp.ID, models.SurveyExportRunning, nil); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("exports/surveys/%d/%d_%s.%s", exp.QuestionnaireID, exp.ID, util.RandText(8), exp.Format)
+	pr, pw := io.Pipe()
+	rowsCh := make(chan int, 1)
+	go func() {
+		w, err := export.NewWriter(exp.Format, pw)
+		if err != nil {
+			rowsCh <- 0
+			pw.CloseWithError(err)
+			return
+		}
+		rows, err := models.WriteSurveyExport(ctx, db, &exp, surveyExportSecret(), w)
+		if err == nil {
+			err = w.Close()
+		}
+		rowsCh <- rows
+		pw.CloseWithError(err)
+	}()
+	err := stores.Default().Write(key, pr)
+	// 存储写入提前失败时让生成端退出
+	pr.CloseWithError(err)
+	rows := <-rowsCh
+	if err != nil {
+		return err
+	}
+
+	if err := models.MarkSurveyExport(db, exp.ID, models.SurveyExportDone, map[string]any{
+		"object_key": key,
+		"rows":       rows,
+		"error":      "",
+	}); err != nil {
+		return err
+	}
+
+	link := surveyExportDownloadURL(exp.ID, time.Now().Add(surveyExportLinkTTL()))
+	content := fmt.Sprintf("问卷 #%d 的结果导出已完成，共 %d 份回答。下载链接（%s 内有效）：%s",
+		exp.QuestionnaireID, rows, surveyExportLinkTTL(), link)
+	if err := notification.NewInternalNotificationService(db).Send(exp.RequesterID, "问卷结果导出完成", content); err != nil {
+		logger.Warn("send survey export notification failed", zap.Error(err))
+	}
+	return nil
+}
+
+// failSurveyExport 标记导出失败并通知发起人
+func failSurveyExport(db *gorm.DB, id uint, cause error) {
+	logger.Warn("survey export failed", zap.Uint("id", id), zap.Error(cause))
+	if err := models.MarkSurveyExport(db, id, models.SurveyExportFailed, map[string]any{"error": cause.Error()}); err != nil {
+		logger.Warn("mark survey export failed error", zap.Error(err))
+		return
+	}
+	var exp models.SurveyExport
+	if err := db.First(&exp, id).Error; err != nil {
+		return
+	}
+	content := fmt.Sprintf("问卷 #%d 的结果导出失败：%s", exp.QuestionnaireID, cause.Error())
+	if err := notification.NewInternalNotificationService(db).Send(exp.RequesterID, "问卷结果导出失败", content); err != nil {
+		logger.Warn("send survey export notification failed", zap.Error(err))
+	}
+}
+
+// surveyExportDownloadURL 带签名与过期时间的下载地址
+func surveyExportDownloadURL(id uint, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	sig := models.SurveyExportSignature(surveyExportSecret(), id, expires)
+	return fmt.Sprintf("%s/question/exports/%d/download?expires=%d&sig=%s", config.GlobalConfig.APIPrefix, id, expires, sig)
+}
+
+// handleCreateSurveyExport 发起问卷结果导出，导出个人信息需要超级管理员权限
+func (h *Handlers) handleCreateSurveyExport(c *gin.Context) {
+	var req struct {
+		QuestionnaireID uint   `json:"questionnaireId" binding:"required"`
+		Format          string `json:"format"`
+		IncludePII      bool   `json:"includePII"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Format == "" {
+		req.Format = export.FormatCSV
+	}
+	if req.Format != export.FormatCSV && req.Format != export.FormatXLSX {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("unsupported format: %s", req.Format))
+		return
+	}
+	user := models.CurrentUser(c)
+	if req.IncludePII && !user.IsSuperUser {
+		hibiscusIM.AbortWithJSONError(c, http.StatusForbidden, errors.New("exporting personal information requires super user"))
+		return
+	}
+	if surveyExportSecret() == "" {
+		hibiscusIM.AbortWithJSONError(c, http.StatusServiceUnavailable, errors.New("export signing secret is not configured"))
+		return
+	}
+	if _, err := models.GetQuestionnaire(h.db, req.QuestionnaireID); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusNotFound, errors.New("questionnaire not found"))
+		return
+	}
+
+	exp := models.SurveyExport{
+		QuestionnaireID: req.QuestionnaireID,
+		RequesterID:     user.ID,
+		Format:          req.Format,
+		IncludePII:      req.IncludePII,
+		Status:          models.SurveyExportPending,
+	}
+	if err := h.db.Create(&exp).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	enqueueSurveyExport(h.db, exp.ID)
+	c.JSON(http.StatusAccepted, exp)
+}
+
+// handleGetSurveyExport 查询导出任务状态，完成后返回签名下载链接
+func (h *Handlers) handleGetSurveyExport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	var exp models.SurveyExport
+	if err := h.db.First(&exp, id).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusNotFound, errors.New("export not found"))
+		return
+	}
+	user := models.CurrentUser(c)
+	if exp.RequesterID != user.ID && !user.IsSuperUser {
+		hibiscusIM.AbortWithJSONError(c, http.StatusForbidden, errors.New("forbidden"))
+		return
+	}
+	data := gin.H{"export": exp}
+	if exp.Status == models.SurveyExportDone {
+		data["downloadUrl"] = surveyExportDownloadURL(exp.ID, time.Now().Add(surveyExportLinkTTL()))
+	}
+	response.Success(c, "success", data)
+}
+
+// handleDownloadSurveyExport 通过签名链接下载导出文件，无需登录
+func (h *Handlers) handleDownloadSurveyExport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	secret := surveyExportSecret()
+	if secret == "" || !models.VerifySurveyExportSignature(secret, uint(id), expires, c.Query("sig")) {
+		hibiscusIM.AbortWithJSONError(c, http.StatusForbidden, errors.New("invalid or expired download link"))
+		return
+	}
+	var exp models.SurveyExport
+	if err := h.db.First(&exp, id).Error; err != nil || exp.Status != models.SurveyExportDone {
+		hibiscusIM.AbortWithJSONError(c, http.StatusNotFound, errors.New("export not found"))
+		return
+	}
+	rc, size, err := stores.Default().Read(exp.ObjectKey)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusNotFound, errors.New("export file not found"))
+		return
+	}
+	defer rc.Close()
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exp.FileName()))
+	c.Header("Cache-Control", "no-store")
+	c.DataFromReader(http.StatusOK, size, export.ContentType(exp.Format), io.LimitReader(rc, size), nil)
+}
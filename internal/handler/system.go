@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/config"
 	"HibiscusIM/pkg/middleware"
 	"HibiscusIM/pkg/response"
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -15,12 +19,51 @@ func (h *Handlers) UpdateRateLimiterConfig(c *gin.Context) {
 		response.Fail(c, "invalid request", nil)
 		return
 	}
+	if config.FailureMode != "" && config.FailureMode != middleware.FailureModeOpen && config.FailureMode != middleware.FailureModeClosed {
+		response.Fail(c, "invalid failure_mode", nil)
+		return
+	}
+	validAlgorithm := func(a string) bool {
+		return a == "" || a == middleware.AlgorithmFixedWindow || a == middleware.AlgorithmSlidingWindow
+	}
+	if !validAlgorithm(config.Algorithm) {
+		response.Fail(c, "invalid algorithm", nil)
+		return
+	}
+	for _, a := range config.PerRouteAlgorithms {
+		if !validAlgorithm(a) {
+			response.Fail(c, "invalid per_route_algorithms", nil)
+			return
+		}
+	}
 
 	// 更新限流配置
 	middleware.SetRateLimiterConfig(config)
+	models.RecordSystemEvent(models.SystemEventInfo, models.SystemEventConfig, "rate_limiter", "rate limiter config updated", map[string]any{"config": config})
 	response.Success(c, "rate limiter config updated", nil)
 }
 
+// handleRateLimitStatus 返回调用方各限流桶的上限、剩余配额与重置时间，供客户端提前退避；
+// 可用 route 参数（可重复）查询指定路由，本次查询请求本身也计入配额
+func (h *Handlers) handleRateLimitStatus(c *gin.Context) {
+	status, err := middleware.RateLimitStatusFor(c, c.QueryArray("route")...)
+	if err != nil {
+		response.Fail(c, "rate limiter unavailable", nil)
+		return
+	}
+	response.Success(c, "success", status)
+}
+
+// handleRateLimitGroupUsage 返回各共享配额组及其成员的请求用量，reset=true 时读取后清零，便于按账期核对
+func (h *Handlers) handleRateLimitGroupUsage(c *gin.Context) {
+	reset := c.Query("reset") == "true"
+	usage := middleware.RateLimiterGroupUsage(reset)
+	if reset {
+		models.RecordSystemEvent(models.SystemEventInfo, models.SystemEventConfig, "rate_limiter", "rate limiter group usage reset", map[string]any{"groups": len(usage)})
+	}
+	response.Success(c, "success", gin.H{"groups": usage})
+}
+
 // HealthCheck 健康检查接口
 func (h *Handlers) HealthCheck(c *gin.Context) {
 	// 检查数据库连接
@@ -34,6 +77,27 @@ func (h *Handlers) HealthCheck(c *gin.Context) {
 		return
 	}
 
+	// 检查限流存储，fail-closed 时存储故障会拒绝所有请求
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second)
+	defer cancel()
+	limiterHealth := middleware.RateLimiterStoreHealth(ctx)
+	if !limiterHealth.Healthy {
+		status := http.StatusOK
+		if limiterHealth.FailureMode == middleware.FailureModeClosed {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"status": "degraded", "rateLimiter": limiterHealth})
+		return
+	}
+
 	// 返回健康状态
-	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+	c.JSON(http.StatusOK, gin.H{"status": "healthy", "rateLimiter": limiterHealth})
+}
+
+// handleEffectiveConfig 查看当前生效配置及其来源，敏感值已脱敏
+func (h *Handlers) handleEffectiveConfig(c *gin.Context) {
+	response.Success(c, "success", gin.H{
+		"file":  config.ConfigFile(),
+		"items": config.Effective(),
+	})
 }
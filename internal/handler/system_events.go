@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/backup"
+	"HibiscusIM/pkg/config"
+	"HibiscusIM/pkg/response"
+	"HibiscusIM/pkg/search"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// systemEventStreamPing SSE 保活间隔
+const systemEventStreamPing = 30 * time.Second
+
+// initSystemEvents 初始化系统事件日志，并记录定时备份的结果；备份执行记录按 BACKUP_MAX_AGE_HOURS 判断是否超时未成功
+func initSystemEvents(db *gorm.DB) *models.SystemEventJournal {
+	journal := models.NewSystemEventJournal(db)
+	models.SetSystemEventJournal(journal)
+
+	backup.SetRecorder(backup.NewRecorder(db, time.Duration(config.GlobalConfig.BackupMaxAge)*time.Hour))
+	backup.OnResult(func(res backup.Result) {
+		meta := map[string]any{
+			"durationMs":  res.Duration.Milliseconds(),
+			"destination": res.Destination,
+			"size":        res.Size,
+			"checksum":    res.Checksum,
+		}
+		if res.Err != nil {
+			meta["error"] = res.Err.Error()
+			models.RecordSystemEvent(models.SystemEventError, models.SystemEventBackup, "backup", "scheduled backup failed", meta)
+			return
+		}
+		models.RecordSystemEvent(models.SystemEventInfo, models.SystemEventBackup, "backup", "scheduled backup completed", meta)
+	})
+	return journal
+}
+
+// recordReindexEvents 将索引重建的结果写入系统事件日志
+func recordReindexEvents(indexer *search.Indexer) {
+	indexer.OnReindex(func(types []string, res search.ReindexResult, err error) {
+		meta := map[string]any{"types": types, "indexed": res.Indexed, "durationMs": res.Duration.Milliseconds()}
+		if err != nil {
+			meta["error"] = err.Error()
+			models.RecordSystemEvent(models.SystemEventError, models.SystemEventSearch, "reindex", "search index rebuild failed", meta)
+			return
+		}
+		models.RecordSystemEvent(models.SystemEventInfo, models.SystemEventSearch, "reindex", "search index rebuilt", meta)
+	})
+}
+
+// parseSystemEventFilter 解析 level/category/since/until 查询参数，时间格式为 RFC3339
+func parseSystemEventFilter(c *gin.Context) (models.SystemEventFilter, error) {
+	filter := models.SystemEventFilter{
+		Level:    c.Query("level"),
+		Category: c.Query("category"),
+	}
+	if filter.Level != "" && !models.ValidSystemEventLevel(filter.Level) {
+		return filter, fmt.Errorf("level must be info, warn or error")
+	}
+	for key, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := c.Query(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s", key)
+			}
+			*dst = t
+		}
+	}
+	return filter, nil
+}
+
+// handleListSystemEvents 倒序分页查询系统事件，before_id 传上一页最后一条的 ID
+func (h *Handlers) handleListSystemEvents(c *gin.Context) {
+	filter, err := parseSystemEventFilter(c)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	if v := c.Query("before_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			response.Fail(c, "invalid before_id", nil)
+			return
+		}
+		filter.BeforeID = uint(id)
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	events, err := h.systemEvents.Query(c.Request.Context(), filter)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	result := gin.H{"events": events}
+	if len(events) > 0 {
+		result["nextBeforeId"] = events[len(events)-1].ID
+	}
+	response.Success(c, "success", result)
+}
+
+// handleSystemEventStream 通过 SSE 推送新的系统事件，支持 level/category 过滤；
+// 断线重连时按 Last-Event-ID（或 last_event_id 参数）补发错过的事件
+func (h *Handlers) handleSystemEventStream(c *gin.Context) {
+	filter, err := parseSystemEventFilter(c)
+	if err != nil {
+		response.Fail(c, err.Error(), nil)
+		return
+	}
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	// 先订阅再补发，避免两者之间产生的事件丢失
+	events, cancel := h.systemEvents.Subscribe(64)
+	defer cancel()
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprintf(c.Writer, "retry: 5000\n\n")
+
+	var lastID uint
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	if id, err := strconv.ParseUint(strings.TrimSpace(lastEventID), 10, 64); err == nil && id > 0 {
+		lastID = uint(id)
+		missed, err := h.systemEvents.Query(c.Request.Context(), models.SystemEventFilter{
+			Level:    filter.Level,
+			Category: filter.Category,
+			AfterID:  lastID,
+			Limit:    500,
+		})
+		if err == nil {
+			for n := range missed {
+				writeSystemEvent(c, &missed[n])
+				lastID = missed[n].ID
+			}
+		}
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(systemEventStreamPing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if ev.ID <= lastID || !filter.Match(&ev) {
+				continue
+			}
+			writeSystemEvent(c, &ev)
+			lastID = ev.ID
+			flusher.Flush()
+		case <-ping.C:
+			fmt.Fprintf(c.Writer, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSystemEvent(c *gin.Context, ev *models.SystemEvent) {
+	data, _ := json.Marshal(ev)
+	fmt.Fprintf(c.Writer, "id: %d\nevent: system_event\ndata: %s\n\n", ev.ID, data)
+}
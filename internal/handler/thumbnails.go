@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+const (
+	// thumbnailSize 缩略图最长边
+	thumbnailSize = 320
+	// maxThumbnailPixels 生成缩略图的原图像素上限，避免解码超大图片耗尽内存
+	maxThumbnailPixels = 25_000_000
+)
+
+// thumbnailTypes 可以生成缩略图的图片类型
+var thumbnailTypes = map[string]func(io.Reader) (image.Image, error){
+	"image/jpeg": jpeg.Decode,
+	"image/png":  png.Decode,
+	"image/gif":  gif.Decode,
+}
+
+var errImageTooLarge = errors.New("image too large for thumbnail")
+
+// imageSize 读取图片头部获取尺寸，不解码像素
+func imageSize(r io.Reader) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// makeThumbnail 按最长边 thumbnailSize 等比缩小图片并编码为 JPEG，透明区域填充白色。
+// 小于该尺寸的图片不放大
+func makeThumbnail(r io.Reader, contentType string, width, height int) ([]byte, error) {
+	decode, ok := thumbnailTypes[contentType]
+	if !ok {
+		return nil, errors.New("unsupported image type: " + contentType)
+	}
+	if width <= 0 || height <= 0 || width*height > maxThumbnailPixels {
+		return nil, errImageTooLarge
+	}
+	src, err := decode(r)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleImage(src, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleImage 区域平均缩小到最长边不超过 maxSide
+func scaleImage(src image.Image, maxSide int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			tw, th = maxSide, max(1, h*maxSide/w)
+		} else {
+			tw, th = max(1, w*maxSide/h), maxSide
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := b.Min.Y + y*h/th
+		y1 := max(b.Min.Y+(y+1)*h/th, y0+1)
+		for x := 0; x < tw; x++ {
+			x0 := b.Min.X + x*w/tw
+			x1 := max(b.Min.X+(x+1)*w/tw, x0+1)
+			var sr, sg, sb, sa, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, a := src.At(sx, sy).RGBA()
+					sr, sg, sb, sa = sr+uint64(r), sg+uint64(g), sb+uint64(b), sa+uint64(a)
+					n++
+				}
+			}
+			// 预乘 alpha 的颜色叠加到白色背景上
+			bg := 0xffff - sa/n
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(sr/n + bg),
+				G: uint16(sg/n + bg),
+				B: uint16(sb/n + bg),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
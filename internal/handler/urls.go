@@ -4,13 +4,20 @@ import (
 	hibiscusIM "HibiscusIM"
 	"HibiscusIM/internal/apidocs"
 	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/backup"
 	"HibiscusIM/pkg/config"
 	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/metrics"
 	"HibiscusIM/pkg/middleware"
 	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/scheduler"
 	"HibiscusIM/pkg/search"
+	"HibiscusIM/pkg/sse"
+	"HibiscusIM/pkg/util"
 	"HibiscusIM/pkg/websocket"
-	"log"
+	"context"
+	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -20,42 +27,121 @@ import (
 type Handlers struct {
 	db            *gorm.DB
 	wsHub         *websocket.Hub
+	moderator     *groupModerator
+	sseHub        *sse.Hub
+	searchEngine  search.Engine
 	searchHandler *search.SearchHandlers
+	searchIndexer *search.Indexer
+	emailCodes    *models.EmailCodeIssuer
+	conversations *models.ConversationStore
+	reactions     *models.ReactionStore
+	llmUsage      *models.LLMUsageStore
+	storageQuota  models.StorageQuota
+	systemEvents  *models.SystemEventJournal
+	notifications *notification.Dispatcher
+	notifyJobs    *scheduler.Scheduler
+	announcements *announcementDeliverer
+	announceJobs  *scheduler.Scheduler
+	messageSearch *messageIndexer
+	messages      *models.MessageRepository
+	messageWriter *messagePersister
+	messageExpiry *messageExpirySweeper
+	responseCache *middleware.ResponseCache
+	operationLog  *middleware.OperationLogger
+	auditLog      *middleware.AuditLogger
+	breakers      *middleware.CircuitBreakerRegistry
+
+	// 用户与群组输入联想，未启用搜索时为 nil
+	searchTypeahead     *search.Typeahead
+	typeaheadVisibility *typeaheadVisibility
 }
 
 func NewHandlers(db *gorm.DB) *Handlers {
 	wsConfig := websocket.LoadConfigFromEnv()
 	wsHub := websocket.NewHub(wsConfig)
-	var searchHandler *search.SearchHandlers
-	if config.GlobalConfig.SearchEnabled {
-		engine, err := search.New(
-			search.Config{
-				IndexPath:    config.GlobalConfig.SearchPath,
-				QueryTimeout: 5 * time.Second,
-				BatchSize:    config.GlobalConfig.SearchBatchSize,
-			},
-			search.BuildIndexMapping(""),
-		)
-		if err != nil {
-			log.Fatalf("Failed to initialize search engine: %v", err)
-		}
-		searchHandler = search.NewSearchHandlers(engine)
-	}
+	moderator := initGroupModeration(db)
+	wsHub.SetModerator(moderator)
+	authorizer := &groupAuthorizer{db: db, allowAdhoc: util.GetBoolEnv(websocket.EnvWebSocketAllowAdhocGroups)}
+	wsHub.SetGroupAuthorizer(authorizer)
+	wsHub.SetGroupPermission(authorizer.Permit)
+	initAuthRevocation(wsHub)
+	initDeadLetters(db, wsHub, wsConfig)
+	initCluster(wsHub, wsConfig)
+	initFileScan(db, wsHub)
+	initSurveyExport(db)
+	conversations := initConversations(db, wsHub)
+	reactions := initReactions(db, wsHub)
+	messages, messageWriter := initMessageHistory(db, wsHub, conversations)
+	messageExpiry := initMessageExpiry(wsHub, conversations, messages, reactions)
+	llmUsage := initLLMUsage(db)
+	sseHub := sse.NewHub(30 * time.Second)
+	initUnreadCounter(db, wsHub, sseHub)
+	notifications := initNotificationDispatcher(db, wsHub, sseHub)
+	systemEvents := initSystemEvents(db)
+	announcements := &announcementDeliverer{db: db, wsHub: wsHub, sseHub: sseHub}
 
 	return &Handlers{
 		db:            db,
 		wsHub:         wsHub,
-		searchHandler: searchHandler,
+		moderator:     moderator,
+		sseHub:        sseHub,
+		emailCodes:    models.NewEmailCodeIssuer(models.LoadEmailCodeLimits()),
+		conversations: conversations,
+		reactions:     reactions,
+		messages:      messages,
+		messageWriter: messageWriter,
+		messageExpiry: messageExpiry,
+		llmUsage:      llmUsage,
+		storageQuota:  models.LoadStorageQuota(),
+		systemEvents:  systemEvents,
+		notifications: notifications,
+		notifyJobs:    startNotificationJobs(db, notifications),
+		announcements: announcements,
+		announceJobs:  startAnnouncementJobs(announcements),
+		responseCache: initResponseCache(db),
+		operationLog:  initOperationLog(db),
+		auditLog:      initAuditLog(db),
+		breakers:      initCircuitBreakers(),
+	}
+}
+
+// DrainWebSockets 通知 WebSocket 客户端服务即将关闭并等待发送缓冲区清空后断开，需在 HTTP 服务关闭前调用：
+// 升级后的连接不受 http.Server.Shutdown 管理
+func (h *Handlers) DrainWebSockets(ctx context.Context) error {
+	return h.wsHub.Shutdown(ctx, "server shutting down")
+}
+
+// Close 关闭 WebSocket Hub 并断开全部连接，提交未写入的聊天消息、操作日志与审计日志，停止过期消息清理、定时通知与公告投递
+func (h *Handlers) Close() {
+	h.wsHub.Close()
+	if h.messageWriter != nil {
+		h.messageWriter.close()
+	}
+	if h.messageExpiry != nil {
+		h.messageExpiry.close()
+	}
+	if h.operationLog != nil {
+		h.operationLog.Close()
+	}
+	if h.auditLog != nil {
+		h.auditLog.Close()
 	}
+	h.notifyJobs.Stop()
+	h.announceJobs.Stop()
 }
 
 func (h *Handlers) Register(engine *gin.Engine) {
 	r := engine.Group(config.GlobalConfig.APIPrefix)
 
 	// Register Global Singleton DB
-	r.Use(middleware.InjectDB(h.db))
-	if config.GlobalConfig.SearchEnabled {
+	r.Use(middleware.InjectDB(h.db), middleware.BodyLimit(bodyLimitConfig()))
+	if h.operationLog != nil {
+		r.Use(h.operationLog.Handler())
+	}
+	if h.searchHandler != nil {
 		h.searchHandler.RegisterSearchRoutes(r)
+		r.GET("/search/users", models.AuthRequired, h.handleSearchUsers)
+		r.GET("/search/groups", models.AuthRequired, h.handleSearchGroups)
 	} else {
 		logger.Info("Search API is disabled")
 	}
@@ -65,9 +151,13 @@ func (h *Handlers) Register(engine *gin.Engine) {
 	// Register Business Module Routes
 	h.registerAuthRoutes(r)
 	h.registerNotificationRoutes(r)
+	h.registerAnnouncementRoutes(r)
 	h.registerGroupRoutes(r)
 	h.registerWebSocketRoutes(r)
+	h.registerConversationRoutes(r)
+	h.registerClientEventRoutes(r)
 	h.registerVoicesRoutes(r)
+	h.registerAttachmentRoutes(r)
 	h.registerQuestionRoutes(r)
 
 	objs := h.GetObjs()
@@ -81,7 +171,7 @@ func (h *Handlers) Register(engine *gin.Engine) {
 	}
 	if config.GlobalConfig.AdminPrefix != "" {
 		admin := r.Group(config.GlobalConfig.AdminPrefix)
-		h.RegisterAdmin(admin)
+		models.RegisterAdminImports(r, h.db, h.RegisterAdmin(admin))
 	}
 }
 
@@ -92,18 +182,20 @@ func (h *Handlers) registerAuthRoutes(r *gin.RouterGroup) {
 		// register
 		auth.GET("/register", h.handleUserSignupPage)
 
-		auth.POST("/register", h.handleUserSignup)
+		auth.POST("/register", h.audit("user.register"), h.handleUserSignup)
 
-		auth.POST("/register/email", h.handleUserSignupByEmail)
+		auth.POST("/register/email", h.audit("user.register"), h.handleUserSignupByEmail)
 
 		auth.POST("/send/email", h.handleSendEmailCode)
+		auth.POST("/send/email/report", h.handleReportEmailAbuse)
+		auth.GET("/send/email/abuse", models.AuthRequired, models.WithAdminAuth(), h.handleListEmailAbuse)
 
 		// login
 		auth.GET("/login", h.handleUserSigninPage)
 
-		auth.POST("/login", h.handleUserSignin)
+		auth.POST("/login", h.audit("user.login"), h.handleUserSignin)
 
-		auth.POST("/login/email", h.handleUserSigninByEmail)
+		auth.POST("/login/email", h.audit("user.login"), h.handleUserSigninByEmail)
 
 		// logout
 		auth.GET("/logout", models.AuthRequired, h.handleUserLogout)
@@ -113,11 +205,13 @@ func (h *Handlers) registerAuthRoutes(r *gin.RouterGroup) {
 		auth.GET("/reset-password", h.handleUserResetPasswordPage)
 
 		// update
-		auth.PUT("/update", models.AuthRequired, h.handleUserUpdate)
+		auth.PUT("/update", models.AuthRequired, h.audit("user.update"), h.handleUserUpdate)
 
 		auth.PUT("/update/preferences", models.AuthRequired, h.handleUserUpdatePreferences)
 
-		auth.POST("/update/basic/info", models.AuthRequired, h.handleUserUpdateBasicInfo)
+		auth.POST("/update/basic/info", models.AuthRequired, h.audit("user.update"), h.handleUserUpdateBasicInfo)
+
+		auth.POST("/update/avatar", models.AuthRequired, h.audit("user.update"), h.breaker("storage"), h.handleUploadAvatar)
 	}
 }
 
@@ -128,6 +222,18 @@ func (h *Handlers) registerNotificationRoutes(r *gin.RouterGroup) {
 
 		notificationGroup.GET("", models.AuthRequired, h.handleListNotifications)
 
+		notificationGroup.GET("stream", models.AuthRequired, h.handleNotificationStream)
+
+		notificationGroup.GET("preferences", models.AuthRequired, h.handleGetNotificationPreferences)
+
+		notificationGroup.PUT("preferences", models.AuthRequired, h.handleUpdateNotificationPreferences)
+
+		notificationGroup.GET("scheduled", models.AuthRequired, h.handleListScheduledNotifications)
+
+		notificationGroup.POST("scheduled", models.AuthRequired, h.handleScheduleNotification)
+
+		notificationGroup.DELETE("scheduled/:id", models.AuthRequired, h.handleCancelScheduledNotification)
+
 		notificationGroup.POST("readAll", models.AuthRequired, h.handleAllNotifications)
 
 		notificationGroup.PUT("/read/:id", models.AuthRequired, h.handleMarkNotificationAsRead)
@@ -136,12 +242,52 @@ func (h *Handlers) registerNotificationRoutes(r *gin.RouterGroup) {
 	}
 }
 
+func (h *Handlers) registerAnnouncementRoutes(r *gin.RouterGroup) {
+	announcements := r.Group("announcements")
+	announcements.Use(models.AuthRequired)
+	{
+		announcements.GET("/active", h.handleActiveAnnouncements)
+
+		announcements.POST("/:id/seen", h.handleMarkAnnouncement(false))
+
+		announcements.POST("/:id/dismiss", h.handleMarkAnnouncement(true))
+
+		announcements.POST("", models.WithAdminAuth(), h.audit("announcement.create"), h.handleCreateAnnouncement)
+
+		announcements.GET("", models.WithAdminAuth(), h.handleListAnnouncements)
+
+		announcements.POST("/:id/send", models.WithAdminAuth(), h.audit("announcement.send"), h.handleSendAnnouncement)
+
+		announcements.DELETE("/:id", models.WithAdminAuth(), h.audit("announcement.cancel"), h.handleCancelAnnouncement)
+
+		announcements.GET("/:id/reach", models.WithAdminAuth(), h.handleAnnouncementReach)
+	}
+}
+
 func (h *Handlers) registerSystemRoutes(r *gin.RouterGroup) {
+	r.GET("/rate-limit/status", h.handleRateLimitStatus)
+
 	system := r.Group("system")
 	{
-		system.POST("/rate-limiter/config", h.UpdateRateLimiterConfig)
+		system.POST("/rate-limiter/config", models.AuthRequired, models.WithAdminAuth(), h.audit("system.rate_limiter.update"), h.UpdateRateLimiterConfig)
+
+		system.GET("/rate-limiter/groups", models.AuthRequired, models.WithAdminAuth(), h.handleRateLimitGroupUsage)
 
 		system.GET("/health", h.HealthCheck)
+
+		system.GET("/config", models.AuthRequired, models.WithAdminAuth(), h.handleEffectiveConfig)
+
+		system.GET("/llm/usage", models.AuthRequired, models.WithAdminAuth(), h.handleLLMUsageReport)
+
+		system.GET("/search/progress/stream", models.AuthRequired, models.WithAdminAuth(), h.handleSearchProgressStream)
+
+		system.GET("/events", models.AuthRequired, models.WithAdminAuth(), h.handleListSystemEvents)
+
+		system.GET("/events/stream", models.AuthRequired, models.WithAdminAuth(), h.handleSystemEventStream)
+
+		system.GET("/circuit-breakers", models.AuthRequired, models.WithAdminAuth(), h.handleListCircuitBreakers)
+
+		system.POST("/circuit-breakers/reset", models.AuthRequired, models.WithAdminAuth(), h.audit("system.circuit_breaker.reset"), h.handleResetCircuitBreaker)
 	}
 }
 
@@ -157,13 +303,26 @@ func (h *Handlers) registerGroupRoutes(r *gin.RouterGroup) {
 	{
 		group.POST("/", h.CreateGroup)
 
-		group.GET("/", h.ListGroups)
+		group.GET("/", h.cacheResponse(&models.Group{}), h.ListGroups)
+
+		group.GET("/:id", h.cacheResponse(&models.Group{}), h.GetGroup)
+
+		group.PUT("/:id", h.audit("group.update"), h.UpdateGroup)
+
+		group.DELETE("/:id", h.audit("group.delete"), h.DeleteGroup)
 
-		group.GET("/:id", h.GetGroup)
+		// moderation
+		group.POST("/:id/mute", h.audit("group.mute"), h.handleGroupModeration(models.ModerationActionMute))
 
-		group.PUT("/:id", h.UpdateGroup)
+		group.POST("/:id/kick", h.audit("group.kick"), h.handleGroupModeration(models.ModerationActionKick))
 
-		group.DELETE("/:id", h.DeleteGroup)
+		group.POST("/:id/ban", h.audit("group.ban"), h.handleGroupModeration(models.ModerationActionBan))
+
+		group.POST("/:id/unban", h.audit("group.unban"), h.handleGroupModeration(models.ModerationActionUnban))
+
+		group.GET("/:id/moderation", h.handleListGroupModerationEvents)
+
+		group.GET("/:id/search", h.handleSearchGroupMessages)
 	}
 }
 
@@ -174,16 +333,39 @@ func (h *Handlers) registerQuestionRoutes(r *gin.RouterGroup) {
 		question.POST("/", h.handleWriteQuestionnaire)
 
 		question.GET("/responses", h.handleGetQuestionResponseById)
+
+		question.GET("/:id", h.cacheResponse(&models.Questionnaire{}, &models.Question{}), h.handleGetQuestionnaire)
+
+		question.POST("/exports", models.WithAdminAuth(), h.audit("survey.export"), h.handleCreateSurveyExport)
+
+		question.GET("/exports/:id", h.handleGetSurveyExport)
 	}
+	// 导出文件通过签名链接下载，无需登录
+	r.GET("/question/exports/:id/download", h.handleDownloadSurveyExport)
 }
 
 func (h *Handlers) registerVoicesRoutes(r *gin.RouterGroup) {
 	voices := r.Group("voices")
 	{
-		voices.GET("/", h.handleGetRecordingPrompts)
+		voices.GET("/", h.cacheResponse(&models.RecordingPrompt{}), h.handleGetRecordingPrompts)
+		voices.POST("/recordings/presign", models.AuthRequired, h.breaker("storage"), h.PresignRecordingUpload)
+		voices.POST("/recordings", models.AuthRequired, h.ConfirmRecordingUpload)
+		voices.GET("/recordings/:id", models.AuthRequired, h.GetRecording)
 	}
 }
 
+func (h *Handlers) registerAttachmentRoutes(r *gin.RouterGroup) {
+	attachments := r.Group("attachments")
+	attachments.Use(models.AuthRequired)
+	{
+		attachments.POST("/", h.breaker("storage"), h.handleUploadAttachment)
+		attachments.GET("/:id", h.handleGetAttachment)
+		attachments.GET("/:id/download", h.breaker("storage"), h.handleDownloadAttachment)
+		attachments.DELETE("/:id", h.breaker("storage"), h.handleDeleteAttachment)
+	}
+	r.GET("/storage/usage", models.AuthRequired, h.handleStorageUsage)
+}
+
 func (h *Handlers) GetObjs() []hibiscusIM.WebObject {
 	return []hibiscusIM.WebObject{
 		{
@@ -208,7 +390,7 @@ func (h *Handlers) GetObjs() []hibiscusIM.WebObject {
 	}
 }
 
-func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
+func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) []*models.AdminObject {
 	adminObjs := models.GetHibiscusAdminObjects()
 	iconInternalNotification, _ := hibiscusIM.EmbedStaticAssets.ReadFile("static/img/icon_internal_notification.svg")
 	iconOperatorLog, _ := hibiscusIM.EmbedStaticAssets.ReadFile("static/img/icon_operator_log.svg")
@@ -232,37 +414,65 @@ func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
 			Icon:        &models.AdminIcon{SVG: string(iconInternalNotification)},
 		},
 		{
-			Model:       &middleware.OperationLog{},                                  // 关联模型 OperationLog
-			Group:       "System",                                                    // 业务组
-			Name:        "Operation Log",                                             // 管理员后台展示的名称
-			Desc:        "Logs the operations performed by users in the system.",     // 描述
-			Shows:       []string{"ID", "Username", "Action", "Target", "CreatedAt"}, // 显示的字段
-			Editables:   []string{"Action", "Target", "Details"},                     // 可编辑字段
-			Orderables:  []string{"CreatedAt"},                                       // 可排序字段
-			Searchables: []string{"Username", "Action", "Target"},                    // 可搜索字段
-			Icon:        &models.AdminIcon{SVG: string(iconOperatorLog)},             // 图标
+			Model:       &middleware.OperationLog{},                                            // 关联模型 OperationLog
+			Group:       "System",                                                              // 业务组
+			Name:        "Operation Log",                                                       // 管理员后台展示的名称
+			Desc:        "Logs the operations performed by users in the system.",               // 描述
+			Shows:       []string{"ID", "Username", "Action", "Target", "Status", "CreatedAt"}, // 显示的字段
+			Editables:   []string{"Action", "Target", "Details"},                               // 可编辑字段
+			Orderables:  []string{"CreatedAt"},                                                 // 可排序字段
+			Searchables: []string{"Username", "Action", "Target"},                              // 可搜索字段
+			Icon:        &models.AdminIcon{SVG: string(iconOperatorLog)},                       // 图标
+			BeforeRender: func(db *gorm.DB, c *gin.Context, obj any) (any, error) { // 输出前脱敏
+				return obj.(*middleware.OperationLog).Redacted(), nil
+			},
 		},
 		{
-			Model:       &models.Question{},                           // 关联 Question 模型
-			Group:       "Survey",                                     // 业务组
-			Name:        "Question",                                   // 管理员后台展示的名称
-			Desc:        "This is the question in a questionnaire.",   // 描述
-			Shows:       []string{"ID", "Text", "Type", "Options"},    // 显示的字段
-			Editables:   []string{"Text", "Type", "Options"},          // 可编辑字段
-			Orderables:  []string{"CreatedAt"},                        // 可排序字段
-			Searchables: []string{"Text", "Type"},                     // 可搜索字段
-			Icon:        &models.AdminIcon{SVG: string(iconQuestion)}, // 图标
+			Model:       &middleware.AuditLog{},                                                          // 关联模型 AuditLog
+			Group:       "System",                                                                        // 业务组
+			Name:        "Audit Log",                                                                     // 管理员后台展示的名称
+			Desc:        "Request and response records of audited routes for compliance investigations.", // 描述
+			Shows:       []string{"ID", "Username", "Action", "Route", "Status", "CreatedAt"},            // 显示的字段
+			Filterables: []string{"Action", "Status"},                                                    // 可过滤字段
+			Orderables:  []string{"CreatedAt"},                                                           // 可排序字段
+			Searchables: []string{"Username", "Action", "Path"},                                          // 可搜索字段
+			Icon:        &models.AdminIcon{SVG: string(iconOperatorLog)},                                 // 图标
+			BeforeCreate: func(db *gorm.DB, c *gin.Context, obj any) error { // 审计日志只读
+				return errAuditLogReadOnly
+			},
+			BeforeUpdate: func(db *gorm.DB, c *gin.Context, obj any, vals map[string]any) error {
+				return errAuditLogReadOnly
+			},
+			BeforeDelete: func(db *gorm.DB, c *gin.Context, obj any) error {
+				return errAuditLogReadOnly
+			},
+		},
+		{
+			Model:        &models.Question{},                           // 关联 Question 模型
+			Group:        "Survey",                                     // 业务组
+			Name:         "Question",                                   // 管理员后台展示的名称
+			Desc:         "This is the question in a questionnaire.",   // 描述
+			Shows:        []string{"ID", "Text", "Type", "Options"},    // 显示的字段
+			Editables:    []string{"Text", "Type", "Options"},          // 可编辑字段
+			Orderables:   []string{"CreatedAt"},                        // 可排序字段
+			Searchables:  []string{"Text", "Type"},                     // 可搜索字段
+			Icon:         &models.AdminIcon{SVG: string(iconQuestion)}, // 图标
+			Importable:   true,                                         // 支持 CSV 批量导入
+			ImportFields: []string{"QuestionnaireID", "Text", "Type"},  // 导入时需指定所属问卷
+			Requireds:    []string{"QuestionnaireID", "Text"},          // 导入校验的必填字段
 		},
 		{
-			Model:       &models.Questionnaire{},                               // 关联 Questionnaire 模型
-			Group:       "Survey",                                              // 业务组
-			Name:        "Questionnaire",                                       // 管理员后台展示的名称
-			Desc:        "This is a questionnaire, a collection of questions.", // 描述
-			Shows:       []string{"ID", "Title", "Description", "CreatedAt"},   // 显示的字段
-			Editables:   []string{"Title", "Description"},                      // 可编辑字段
-			Orderables:  []string{"CreatedAt"},                                 // 可排序字段
-			Searchables: []string{"Title", "Description"},                      // 可搜索字段
-			Icon:        &models.AdminIcon{SVG: string(iconQuestionnaire)},     // 图标
+			Model:       &models.Questionnaire{},                                                                       // 关联 Questionnaire 模型
+			Group:       "Survey",                                                                                      // 业务组
+			Name:        "Questionnaire",                                                                               // 管理员后台展示的名称
+			Desc:        "This is a questionnaire, a collection of questions.",                                         // 描述
+			Shows:       []string{"ID", "Title", "Description", "CreatedAt"},                                           // 显示的字段
+			Editables:   []string{"Title", "Description", "AvailableFrom", "AvailableUntil", "DailyStart", "DailyEnd"}, // 可编辑字段
+			Orderables:  []string{"CreatedAt"},                                                                         // 可排序字段
+			Searchables: []string{"Title", "Description"},                                                              // 可搜索字段
+			Icon:        &models.AdminIcon{SVG: string(iconQuestionnaire)},                                             // 图标
+			Importable:  true,                                                                                          // 支持 CSV 批量导入
+			Requireds:   []string{"Title"},                                                                             // 导入校验的必填字段
 		},
 		{
 			Model:       &models.Answer{},                                                         // 关联 Answer 模型
@@ -296,13 +506,15 @@ func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
 			Orderables:  []string{"CreatedAt"},
 			Searchables: []string{"Text"},
 			Icon:        &models.AdminIcon{SVG: string(iconRecordingPrompt)}, // 图标
+			Requireds:   []string{"Text"},
+			Importable:  true, // 支持 CSV 批量导入
 		},
 		{
 			Model:       &models.Recording{},                                    // 关联 Recording 模型
 			Group:       "Recording",                                            // 业务组
 			Name:        "Recording",                                            // 管理员后台展示名称
 			Desc:        "This records the user’s voice for a specific prompt.", // 描述
-			Shows:       []string{"ID", "UserID", "PromptID", "FileURL", "Format", "DurationMs", "Status", "CreatedAt"},
+			Shows:       []string{"ID", "UserID", "PromptID", "FileURL", "Format", "DurationMs", "Status", "ScanStatus", "CreatedAt"},
 			Editables:   []string{"UserID", "PromptID", "FileURL", "Format", "DurationMs", "Status"},
 			Orderables:  []string{"CreatedAt"},
 			Searchables: []string{"FileURL", "Status"},
@@ -319,16 +531,103 @@ func (h *Handlers) RegisterAdmin(router *gin.RouterGroup) {
 			Searchables: []string{"Status", "Progress"},
 			Icon:        &models.AdminIcon{SVG: string(iconVoiceJob)}, // 图标
 		},
+		{
+			Model:       &models.LLMUsageDaily{},                                          // 关联 LLMUsageDaily 模型
+			Group:       "System",                                                         // 业务组
+			Name:        "LLM Usage",                                                      // 管理员后台展示名称
+			Desc:        "Daily LLM calls and token usage aggregated per user and model.", // 描述
+			Shows:       []string{"Day", "UserID", "Provider", "Model", "Calls", "Errors", "TotalTokens"},
+			Editables:   []string{},
+			Orderables:  []string{"Day", "TotalTokens"},
+			Searchables: []string{"UserID", "Model"},
+		},
+		{
+			Model:       &models.AdminImport{},                                              // 关联 AdminImport 模型
+			Group:       "System",                                                           // 业务组
+			Name:        "Import History",                                                   // 管理员后台展示名称
+			Desc:        "CSV imports of admin objects, failed commits can be rolled back.", // 描述
+			Shows:       []string{"ID", "ObjectName", "FileName", "Status", "TotalRows", "Imported", "CreatedAt"},
+			Editables:   []string{},
+			Orderables:  []string{"CreatedAt"},
+			Searchables: []string{"ObjectName", "FileName", "Status"},
+		},
+		{
+			Model:       &models.ClientEvent{},                                                     // 关联 ClientEvent 模型
+			Group:       "System",                                                                  // 业务组
+			Name:        "Client Events",                                                           // 管理员后台展示名称
+			Desc:        "Analytics, read receipt and presence events uploaded in client batches.", // 描述
+			Shows:       []string{"ID", "UserID", "Type", "Name", "Platform", "OccurredAt", "CreatedAt"},
+			Editables:   []string{},
+			Filterables: []string{"Type", "Platform"},
+			Orderables:  []string{"OccurredAt", "CreatedAt"},
+			Searchables: []string{"Name", "EventID"},
+		},
+		{
+			Model:       &backup.BackupRun{},                                                         // 关联 BackupRun 模型
+			Group:       "System",                                                                    // 业务组
+			Name:        "Backup History",                                                            // 管理员后台展示名称
+			Desc:        "Database backup runs with destination, size, checksum and failure reason.", // 描述
+			Shows:       []string{"ID", "Target", "Status", "Size", "DurationMs", "Destination", "StartedAt"},
+			Editables:   []string{},
+			Filterables: []string{"Status", "Target"},
+			Orderables:  []string{"StartedAt"},
+			Searchables: []string{"Destination", "Checksum", "Error"},
+		},
+		{
+			Model:       &metrics.MonitorData{},                                                        // 关联 MonitorData 模型
+			Group:       "System",                                                                      // 业务组
+			Name:        "Monitor Data",                                                                // 管理员后台展示名称
+			Desc:        "Persisted system snapshots, slow queries and alert events from the monitor.", // 描述
+			Shows:       []string{"ID", "Kind", "Name", "Value", "Summary", "Timestamp"},
+			Editables:   []string{},
+			Filterables: []string{"Kind", "Name"},
+			Orderables:  []string{"Timestamp"},
+			Searchables: []string{"Name", "Summary"},
+		},
+		{
+			Model:       &search.SearchDictionaryEntry{}, // 关联 SearchDictionaryEntry 模型
+			Group:       "System",                        // 业务组
+			Name:        "Search Dictionary",             // 管理员后台展示名称
+			Desc:        "Synonym rules (`a, b, c` or `a => b`) and stopwords for search queries, reload dictionaries to apply changes.",
+			Shows:       []string{"ID", "Kind", "Value", "UpdatedAt"},
+			Editables:   []string{"Kind", "Value"},
+			Orderables:  []string{"UpdatedAt"},
+			Searchables: []string{"Kind", "Value"},
+			Requireds:   []string{"Kind", "Value"},
+		},
 	}
-	models.RegisterAdmins(router, h.db, append(adminObjs, admins...))
+	return models.RegisterAdmins(router, h.db, append(adminObjs, admins...))
 }
 
 // registerWebSocketRoutes 注册WebSocket路由
 func (h *Handlers) registerWebSocketRoutes(r *gin.RouterGroup) {
 	wsHandler := websocket.NewHandler(h.wsHub)
-
-	// WebSocket连接端点
-	r.GET("/ws", models.AuthRequired, wsHandler.HandleWebSocket)
+	wsHandler.SetAdminAuthorizer(func(c *gin.Context) bool {
+		user := models.CurrentUser(c)
+		return user != nil && (user.IsStaff || user.IsSuperUser)
+	})
+	wsHandler.SetSessionResolver(func(c *gin.Context) string {
+		if user := models.CurrentUser(c); user != nil {
+			return strconv.FormatUint(uint64(user.ID), 10)
+		}
+		return ""
+	})
+	// 先尝试配置的 JWT，再兼容登录接口签发的 token
+	wsHandler.SetAuthenticator(websocket.ChainAuthenticators(
+		wsHandler.Authenticator(),
+		websocket.AuthenticatorFunc(func(_ *http.Request, token string) (string, error) {
+			user, err := models.DecodeHashToken(h.db, token, false)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatUint(uint64(user.ID), 10), nil
+		}),
+	))
+
+	// WebSocket连接端点，握手阶段自行完成认证，失败时以关闭码通知客户端
+	r.GET("/ws", wsHandler.HandleWebSocket)
+	// 节点测速无需认证，供客户端与其他节点探测
+	r.GET("/ws/endpoints/ping", wsHandler.PingEndpoint)
 
 	// WebSocket管理API端点
 	wsGroup := r.Group("/ws")
@@ -336,11 +635,55 @@ func (h *Handlers) registerWebSocketRoutes(r *gin.RouterGroup) {
 	{
 		wsGroup.GET("/stats", wsHandler.GetStats)
 		wsGroup.GET("/health", wsHandler.HealthCheck)
+		wsGroup.GET("/endpoints", wsHandler.GetEndpoints)
+		wsGroup.POST("/endpoints/latency", wsHandler.ReportEndpointLatency)
 		wsGroup.GET("/user/:user_id", wsHandler.GetUserStats)
 		wsGroup.GET("/group/:group", wsHandler.GetGroupStats)
+		wsGroup.GET("/presence/:user_id", wsHandler.GetPresence)
+		wsGroup.GET("/presence/group/:group", wsHandler.GetGroupPresence)
+		wsGroup.POST("/presence/query", wsHandler.QueryPresence)
 		wsGroup.POST("/message", wsHandler.SendMessage)
 		wsGroup.POST("/broadcast", wsHandler.BroadcastMessage)
 		wsGroup.DELETE("/user/:user_id", wsHandler.DisconnectUser)
 		wsGroup.DELETE("/group/:group", wsHandler.DisconnectGroup)
 	}
+
+	// 连接统计历史写入系统监控时间序列，提供容量规划与历史导出，仅管理员可用
+	if monitor := metrics.GetGlobalMonitor(); monitor != nil && monitor.GetSystemMonitor() != nil {
+		sm := monitor.GetSystemMonitor()
+		h.wsHub.RecordStats(sm, 0)
+		wsHandler.SetStatsHistory(func(metric string, since time.Time) []websocket.StatsPoint {
+			series, ok := sm.GetCustomMetricHistory(metric, since, 0)
+			if !ok {
+				return nil
+			}
+			points := make([]websocket.StatsPoint, len(series.Points))
+			for i, p := range series.Points {
+				points[i] = websocket.StatsPoint{Timestamp: p.Timestamp, Value: p.Value}
+			}
+			return points
+		})
+	}
+	wsGroup.GET("/capacity", wsHandler.RequireAdmin, wsHandler.GetCapacity)
+	wsGroup.GET("/stats/history", wsHandler.RequireAdmin, wsHandler.ExportStatsHistory)
+	// 握手结果统计与最近失败记录，排查客户端无法连接的问题
+	wsGroup.GET("/handshakes", wsHandler.RequireAdmin, wsHandler.GetHandshakeStats)
+	wsGroup.GET("/connections", wsHandler.RequireAdmin, wsHandler.ListConnections)
+
+	// 慢消费者排查与强制断开，仅管理员可用
+	slowConsumers := wsGroup.Group("/slow-consumers", wsHandler.RequireAdmin)
+	{
+		slowConsumers.GET("", wsHandler.ListSlowConsumers)
+		slowConsumers.POST("/close", h.audit("websocket.slow_consumers.close"), wsHandler.CloseSlowConsumers)
+		slowConsumers.DELETE("/:id", h.audit("websocket.slow_consumer.close"), wsHandler.CloseSlowConsumer)
+	}
+
+	// 死信查询与重放，仅管理员可用
+	deadLetters := wsGroup.Group("/deadletters", wsHandler.RequireAdmin)
+	{
+		deadLetters.GET("", wsHandler.ListDeadLetters)
+		deadLetters.POST("/replay", wsHandler.ReplayDeadLetters)
+		deadLetters.POST("/:id/replay", wsHandler.ReplayDeadLetter)
+		deadLetters.DELETE("/:id", wsHandler.DeleteDeadLetter)
+	}
 }
@@ -2,12 +2,47 @@ package handlers
 
 import (
 	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
 	"HibiscusIM/pkg/response"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"errors"
+	"fmt"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
+// 录音直传的签名有效期、大小上限与下载地址有效期
+const (
+	recordingUploadTTL   = 15 * time.Minute
+	maxRecordingSize     = 50 << 20
+	recordingDownloadTTL = time.Hour
+)
+
+// recordingKeyPrefix 直传录音的对象键前缀，其后为用户ID
+const recordingKeyPrefix = "recordings/"
+
+// recordingContentTypes 允许直传的录音格式
+var recordingContentTypes = map[string]string{
+	"wav":  "audio/wav",
+	"mp3":  "audio/mpeg",
+	"m4a":  "audio/mp4",
+	"ogg":  "audio/ogg",
+	"opus": "audio/ogg",
+	"webm": "audio/webm",
+	"flac": "audio/flac",
+}
+
+// RecordingUploadForm 申请录音直传
+type RecordingUploadForm struct {
+	Format string `json:"format" binding:"required"`
+}
+
 // 获取所有录音提示（每个待录音的句子）
 func (h *Handlers) handleGetRecordingPrompts(c *gin.Context) {
 	var prompts []models.RecordingPrompt
@@ -18,11 +53,58 @@ func (h *Handlers) handleGetRecordingPrompts(c *gin.Context) {
 	response.Success(c, "get recording prompts", prompts)
 }
 
+// 签发录音直传地址，客户端直接上传到对象存储，完成后调用 ConfirmRecordingUpload 确认
+func (h *Handlers) PresignRecordingUpload(c *gin.Context) {
+	var req RecordingUploadForm
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	contentType, ok := recordingContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported recording format"})
+		return
+	}
+
+	user := models.CurrentUser(c)
+	// 直传的大小在确认前未知，签名的大小上限不超过剩余配额
+	usage, err := h.storageQuota.Check(h.db, user.ID, models.StorageRecordings, 0)
+	if err != nil {
+		c.JSON(storageQuotaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	maxSize := int64(maxRecordingSize)
+	if remaining := h.storageQuota.Remaining(usage, models.StorageRecordings); remaining >= 0 {
+		maxSize = min(maxSize, remaining)
+	}
+
+	key := fmt.Sprintf("%s%d/%d_%s.%s", recordingKeyPrefix, user.ID, time.Now().UnixNano(), util.RandText(8), format)
+	store := stores.Default()
+	upload, err := store.PresignPut(key, recordingUploadTTL, stores.UploadPolicy{ContentType: contentType, MaxSize: maxSize})
+	if errors.Is(err, stores.ErrPresignNotSupported) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"upload":      upload,
+		"objectKey":   key,
+		"fileUrl":     store.PublicURL(key),
+		"contentType": contentType,
+		"maxSize":     maxSize,
+	})
+}
+
 // 确认上传并保存录音信息
 func (h *Handlers) ConfirmRecordingUpload(c *gin.Context) {
 	var req struct {
 		PromptID   uint   `json:"promptId"`
 		FileUrl    string `json:"fileUrl"`
+		ObjectKey  string `json:"objectKey"`
 		Format     string `json:"format"`
 		DurationMs int    `json:"durationMs"`
 		Checksum   string `json:"checksum"`
@@ -32,18 +114,60 @@ func (h *Handlers) ConfirmRecordingUpload(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	store := stores.Default()
+	// 只带 fileUrl 的旧客户端：由服务端从地址推导对象键，地址必须是本存储生成的
+	if req.ObjectKey == "" {
+		key, ok := recordingObjectKey(store, req.FileUrl)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "objectKey is required for content scanning"})
+			return
+		}
+		req.ObjectKey = key
+	} else if req.FileUrl != "" {
+		if key, ok := recordingObjectKey(store, req.FileUrl); !ok || key != req.ObjectKey {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "fileUrl does not match objectKey"})
+			return
+		}
+	}
 
 	// 获取当前用户
 	user := models.CurrentUser(c)
+	ownPrefix := fmt.Sprintf("%s%d/", recordingKeyPrefix, user.ID)
+	// 只能确认签发给当前用户的直传对象，不能借确认登记其他用途或他人的文件
+	if !strings.HasPrefix(req.ObjectKey, ownPrefix) || path.Clean(req.ObjectKey) != req.ObjectKey {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not recording owner"})
+		return
+	}
+
+	// 按对象存储中的实际大小计入配额，超出时删除已上传的录音
+	rc, size, err := store.Read(req.ObjectKey)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "recording object not found"})
+		return
+	}
+	rc.Close()
+	before, err := h.storageQuota.Check(h.db, user.ID, models.StorageRecordings, size)
+	if err != nil {
+		if errors.As(err, new(*models.StorageQuotaError)) {
+			if dErr := store.Delete(req.ObjectKey); dErr != nil {
+				logger.Warn("delete recording over quota failed", zap.String("key", req.ObjectKey), zap.Error(dErr))
+			}
+		}
+		c.JSON(storageQuotaStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	recording := models.Recording{
 		UserID:     user.ID,
 		PromptID:   req.PromptID,
-		FileURL:    req.FileUrl,
+		FileURL:    store.PublicURL(req.ObjectKey),
 		Format:     req.Format,
 		DurationMs: req.DurationMs,
 		Checksum:   req.Checksum,
 		Status:     "uploaded",
+		ObjectKey:  req.ObjectKey,
+		SizeBytes:  size,
+		ScanState:  models.ScanState{ScanStatus: models.ScanStatusPending},
 	}
 
 	if err := h.db.Create(&recording).Error; err != nil {
@@ -51,7 +175,63 @@ func (h *Handlers) ConfirmRecordingUpload(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"recordingId": recording.ID})
+	enqueueFileScan(h.db, models.ScanTargetRecording, recording.ID)
+	h.notifyStorageQuota(user.ID, before, models.StorageRecordings, size)
+	c.JSON(http.StatusCreated, gin.H{"recordingId": recording.ID, "scanStatus": recording.ScanStatus})
+}
+
+// recordingObjectKey 从文件地址推导对象键，并以存储生成的地址校验，地址不属于当前存储时返回 false
+func recordingObjectKey(store stores.Store, fileURL string) (key string, ok bool) {
+	if fileURL == "" {
+		return "", false
+	}
+	// 部分存储未实现 PublicURL
+	defer func() {
+		if recover() != nil {
+			key, ok = "", false
+		}
+	}()
+	base := strings.TrimRight(store.PublicURL(""), "/")
+	if !strings.HasPrefix(fileURL, base+"/") {
+		return "", false
+	}
+	key = strings.TrimPrefix(fileURL, base+"/")
+	if key == "" || strings.Contains(key, "..") || store.PublicURL(key) != fileURL {
+		return "", false
+	}
+	return key, true
+}
+
+// 获取录音信息，扫描通过前不返回文件地址
+func (h *Handlers) GetRecording(c *gin.Context) {
+	var recording models.Recording
+	if err := h.db.First(&recording, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recording not found"})
+		return
+	}
+	user := models.CurrentUser(c)
+	if recording.UserID != user.ID && !user.IsStaff && !user.IsSuperUser {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not recording owner"})
+		return
+	}
+
+	// 存储支持时返回临时下载地址，私有桶也可直接访问
+	fileURL := ""
+	if recording.Downloadable() {
+		fileURL = recording.FileURL
+		if u, err := stores.Default().PresignGet(recording.ObjectKey, recordingDownloadTTL); err == nil {
+			fileURL = u
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"recordingId":   recording.ID,
+		"fileUrl":       fileURL,
+		"format":        recording.Format,
+		"durationMs":    recording.DurationMs,
+		"status":        recording.Status,
+		"scanStatus":    recording.ScanStatus,
+		"scanSignature": recording.ScanSignature,
+	})
 }
 
 // 获取生成的音频或合成结果
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	constants "HibiscusIM/pkg/constant"
+	stores "HibiscusIM/pkg/storage"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestConfirmRecordingUploadRequiresOwnPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "voices.db")), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Recording{}, &models.StorageUsage{}))
+	t.Setenv("MEDIA_PREFIX", "/media")
+	store := &stores.LocalStore{Root: t.TempDir(), NewDirPerm: 0755}
+	stores.SetDefault(store)
+	t.Cleanup(func() { stores.SetDefault(nil) })
+
+	user := &models.User{ID: 1}
+	h := &Handlers{db: db}
+	r := gin.New()
+	r.POST("/recordings", func(c *gin.Context) {
+		c.Set(constants.UserField, user)
+		c.Next()
+	}, h.ConfirmRecordingUpload)
+
+	keys := []string{"attachments/1/report.pdf", "avatars/2.png", "recordings/2/1_a.wav", "recordings/1/../2/1_a.wav"}
+	for _, key := range keys {
+		require.NoError(t, store.Write(key, bytes.NewReader([]byte("RIFF"))))
+	}
+
+	confirm := func(body gin.H) int {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/recordings", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 当前用户前缀以外的对象键与文件地址都拒绝，对象保持不变
+	for _, key := range keys {
+		assert.Equal(t, http.StatusForbidden, confirm(gin.H{"objectKey": key, "format": "wav"}), key)
+		assert.Equal(t, http.StatusForbidden, confirm(gin.H{"fileUrl": store.PublicURL(key), "format": "wav"}), key)
+		_, _, err := store.Read(key)
+		assert.NoError(t, err, key)
+	}
+	// 文件地址与对象键不一致
+	assert.Equal(t, http.StatusBadRequest, confirm(gin.H{"objectKey": "recordings/1/1_a.wav", "fileUrl": "https://evil.example.com/a.wav"}))
+
+	var count int64
+	require.NoError(t, db.Model(&models.Recording{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/websocket"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// initCluster 启用集群时通过 Redis pub/sub 在节点间转发消息，连接失败时只在本节点投递
+func initCluster(hub *websocket.Hub, cfg *websocket.Config) {
+	if !cfg.EnableCluster {
+		return
+	}
+	if cfg.ClusterRedisAddr == "" {
+		logger.Warn("websocket cluster enabled without redis address, messages stay on this node")
+		return
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.ClusterRedisAddr,
+		Password: cfg.ClusterRedisPassword,
+		DB:       cfg.ClusterRedisDB,
+	})
+	if err := hub.StartCluster(websocket.NewRedisClusterTransport(client)); err != nil {
+		logger.Warn("start websocket cluster failed", zap.String("addr", cfg.ClusterRedisAddr), zap.Error(err))
+		_ = client.Close()
+	}
+}
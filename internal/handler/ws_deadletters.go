@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"HibiscusIM/internal/models"
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/notification"
+	"HibiscusIM/pkg/websocket"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// initDeadLetters 配置数据库死信存储，并在消息丢弃率过高时通知超级管理员
+func initDeadLetters(db *gorm.DB, hub *websocket.Hub, cfg *websocket.Config) {
+	if cfg.DeadLetterSink == websocket.DeadLetterSinkDB {
+		hub.SetDeadLetterSink(models.NewDeadLetterStore(db))
+	}
+	hub.SetDropAlertHandler(func(alert websocket.DropAlert) {
+		logger.Warn("websocket drop rate too high",
+			zap.String("node", alert.NodeID),
+			zap.String("region", alert.Region),
+			zap.Int64("drops", alert.Drops),
+			zap.Int("threshold", alert.Threshold),
+			zap.Any("byReason", alert.ByReason))
+
+		var admins []uint
+		if err := db.Model(&models.User{}).Where("is_super_user = ? AND enabled = ?", true, true).Pluck("id", &admins).Error; err != nil {
+			logger.Warn("load super users failed", zap.Error(err))
+			return
+		}
+		content := fmt.Sprintf("节点 %s 最近 %s 丢弃 %d 条WS消息（阈值 %d），原因分布: %v",
+			alert.NodeID, alert.Window, alert.Drops, alert.Threshold, alert.ByReason)
+		svc := notification.NewInternalNotificationService(db)
+		for _, id := range admins {
+			if err := svc.Send(id, "WS消息丢弃率过高", content); err != nil {
+				logger.Warn("send drop alert notification failed", zap.Error(err))
+			}
+		}
+	})
+}
@@ -0,0 +1,664 @@
+package models
+
+import (
+	hibiscusIM "HibiscusIM"
+	constants "HibiscusIM/pkg/constant"
+	"HibiscusIM/pkg/middleware"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 导入任务状态
+const (
+	AdminImportUploaded   = "uploaded"
+	AdminImportValidated  = "validated"
+	AdminImportCommitting = "committing"
+	AdminImportCommitted  = "committed"
+	AdminImportFailed     = "failed"
+	AdminImportRolledBack = "rolled_back"
+)
+
+const (
+	// maxImportFileSize 单个 CSV 文件大小上限
+	maxImportFileSize = 10 << 20
+	// maxImportRows 单次导入的最大行数
+	maxImportRows = 50000
+	// adminImportBatchSize 每批写入行数，每批一个事务
+	adminImportBatchSize = 200
+	// maxImportErrors 预览与记录中保留的行错误数
+	maxImportErrors = 200
+	// importSampleRows 预览返回的样例行数
+	importSampleRows = 5
+)
+
+// AdminImport 后台 CSV 导入记录
+type AdminImport struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	ObjectName  string     `json:"objectName" gorm:"size:128;index"`
+	FileName    string     `json:"fileName" gorm:"size:255"`
+	ObjectKey   string     `json:"-" gorm:"size:512"`
+	Status      string     `json:"status" gorm:"size:16;index"`
+	Mapping     string     `json:"mapping" gorm:"type:text"`
+	TotalRows   int        `json:"totalRows"`
+	ValidRows   int        `json:"validRows"`
+	ErrorRows   int        `json:"errorRows"`
+	Imported    int        `json:"imported"`
+	Errors      string     `json:"errors,omitempty" gorm:"type:text"`
+	CreatedKeys string     `json:"-" gorm:"type:text"`
+	Message     string     `json:"message,omitempty" gorm:"size:512"`
+	CreatedBy   uint       `json:"createdBy" gorm:"index"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	CommittedAt *time.Time `json:"committedAt,omitempty"`
+}
+
+// AdminImportRowError 单行校验错误，Row 为 CSV 中的行号（表头为第 1 行）
+type AdminImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// AdminImportPreview 校验预览结果
+type AdminImportPreview struct {
+	Import  *AdminImport          `json:"import"`
+	Headers []string              `json:"headers"`
+	Mapping map[string]string     `json:"mapping"`
+	Fields  []string              `json:"fields"`
+	Errors  []AdminImportRowError `json:"errors"`
+	Sample  []map[string]any      `json:"sample"`
+}
+
+type adminImportForm struct {
+	Mapping     map[string]string `json:"mapping"`
+	SkipInvalid bool              `json:"skipInvalid"`
+}
+
+// importRow 通过校验的行
+type importRow struct {
+	row  int
+	elem any
+}
+
+// registerImport registers CSV import routes
+//
+//   - POST /admin/{objectslug}/_import -> Upload CSV, returns headers and suggested mapping
+//   - POST /admin/{objectslug}/_import/:id/preview -> Validate with column mapping
+//   - POST /admin/{objectslug}/_import/:id/commit -> Create rows in batches
+//   - POST /admin/{objectslug}/_import/:id/rollback -> Delete rows created by a failed commit
+//
+// The import history is served by RegisterAdminImports.
+func (obj *AdminObject) registerImport(r gin.IRoutes) {
+	r.POST("/_import", obj.requireScope(AdminScopeCreate), obj.handleImportUpload)
+	r.POST("/_import/:id/preview", obj.requireScope(AdminScopeCreate), obj.handleImportPreview)
+	r.POST("/_import/:id/commit", obj.requireScope(AdminScopeCreate), obj.handleImportCommit)
+	r.POST("/_import/:id/rollback", obj.requireScope(AdminScopeDelete), obj.handleImportRollback)
+}
+
+// RegisterAdminImports registers the import history of importable admin objects
+//
+//   - GET /_imports/{objectslug} -> Import history
+//
+// The route lives outside the admin group, where any GET route conflicts with the static catch-all.
+func RegisterAdminImports(r *gin.RouterGroup, db *gorm.DB, objs []*AdminObject) {
+	g := r.Group("/_imports", middleware.InjectDB(db), WithAdminAuth())
+	for _, obj := range objs {
+		if !obj.Importable {
+			continue
+		}
+		g.GET("/"+path.Base(obj.Path), obj.checkAccess, obj.requireScope(AdminScopeView), obj.handleImportHistory)
+	}
+}
+
+// importFields 可导入的字段（列名）
+func (obj *AdminObject) importFields() []string {
+	if len(obj.ImportFields) > 0 {
+		return obj.ImportFields
+	}
+	if len(obj.Editables) > 0 {
+		return obj.Editables
+	}
+	var fields []string
+	for _, f := range obj.Fields {
+		if !f.IsAutoID && !f.NotColumn {
+			fields = append(fields, f.Name)
+		}
+	}
+	return fields
+}
+
+func (obj *AdminObject) fieldByName(name string) (AdminField, bool) {
+	for _, f := range obj.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return AdminField{}, false
+}
+
+// SuggestImportMapping 按列名、字段名或标签（忽略大小写、空格与下划线）匹配表头
+func (obj *AdminObject) SuggestImportMapping(headers []string) map[string]string {
+	normalize := func(s string) string {
+		s = strings.ToLower(strings.TrimSpace(s))
+		return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(s)
+	}
+	candidates := map[string]string{}
+	for _, name := range obj.importFields() {
+		f, ok := obj.fieldByName(name)
+		if !ok {
+			continue
+		}
+		for _, alias := range []string{f.Name, f.fieldName, f.Label} {
+			if alias != "" {
+				candidates[normalize(alias)] = f.Name
+			}
+		}
+	}
+	mapping := map[string]string{}
+	for _, h := range headers {
+		if name, ok := candidates[normalize(h)]; ok {
+			mapping[h] = name
+		}
+	}
+	return mapping
+}
+
+// parseImportValue 将 CSV 单元格转换为字段值，空单元格返回 nil
+func (obj *AdminObject) parseImportValue(field AdminField, raw string) (any, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	t := field.elemType
+	if field.Foreign != nil {
+		if sf, ok := obj.modelElem.FieldByName(field.Foreign.foreignKey); ok {
+			t = sf.Type
+		}
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a non-negative integer", raw)
+		}
+		return int64(v), nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		return v, nil
+	case reflect.Bool:
+		switch strings.ToLower(raw) {
+		case "1", "true", "yes", "y", "on":
+			return true, nil
+		case "0", "false", "no", "n", "off":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", raw)
+	case reflect.Slice:
+		// JSON 数组或以 | 分隔的列表
+		var items []any
+		if strings.HasPrefix(raw, "[") {
+			if err := json.Unmarshal([]byte(raw), &items); err != nil {
+				return nil, fmt.Errorf("invalid list: %v", err)
+			}
+			return items, nil
+		}
+		for _, item := range strings.Split(raw, "|") {
+			items = append(items, strings.TrimSpace(item))
+		}
+		return items, nil
+	}
+	return raw, nil
+}
+
+// validateImport 按映射校验全部数据行，返回可写入的对象与行错误
+func (obj *AdminObject) validateImport(records [][]string, mapping map[string]string) ([]importRow, []AdminImportRowError, error) {
+	if len(records) == 0 {
+		return nil, nil, errors.New("empty csv")
+	}
+	allowed := obj.importFields()
+	allowedSet := map[string]bool{}
+	for _, name := range allowed {
+		allowedSet[name] = true
+	}
+
+	headers := records[0]
+	columns := map[int]AdminField{}
+	mapped := map[string]bool{}
+	for idx, h := range headers {
+		name, ok := mapping[h]
+		if !ok || name == "" {
+			continue
+		}
+		if !allowedSet[name] {
+			return nil, nil, fmt.Errorf("field %s is not importable", name)
+		}
+		if mapped[name] {
+			return nil, nil, fmt.Errorf("field %s is mapped more than once", name)
+		}
+		f, ok := obj.fieldByName(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown field %s", name)
+		}
+		columns[idx] = f
+		mapped[name] = true
+	}
+	if len(columns) == 0 {
+		return nil, nil, errors.New("no column is mapped")
+	}
+
+	// 必填字段必须可导入，否则无法校验，不能静默忽略
+	required := obj.Requireds
+	for _, name := range required {
+		if !allowedSet[name] {
+			return nil, nil, fmt.Errorf("required field %s is not importable", name)
+		}
+	}
+
+	var rows []importRow
+	var rowErrors []AdminImportRowError
+	for i, record := range records[1:] {
+		rowNo := i + 2
+		vals := map[string]any{}
+		var errs []AdminImportRowError
+		for idx := range headers {
+			f, ok := columns[idx]
+			if !ok || idx >= len(record) {
+				continue
+			}
+			v, err := obj.parseImportValue(f, record[idx])
+			if err != nil {
+				errs = append(errs, AdminImportRowError{Row: rowNo, Column: headers[idx], Field: f.Name, Message: err.Error()})
+				continue
+			}
+			if v != nil {
+				vals[f.Name] = v
+			}
+		}
+		for _, name := range required {
+			if _, ok := vals[name]; !ok {
+				errs = append(errs, AdminImportRowError{Row: rowNo, Field: name, Message: "required"})
+			}
+		}
+		if len(errs) == 0 {
+			elem, err := obj.unmarshalFields(reflect.New(obj.modelElem), nil, vals, allowed)
+			if err != nil {
+				errs = append(errs, AdminImportRowError{Row: rowNo, Message: err.Error()})
+			} else {
+				rows = append(rows, importRow{row: rowNo, elem: elem})
+			}
+		}
+		rowErrors = append(rowErrors, errs...)
+	}
+	return rows, rowErrors, nil
+}
+
+// readImportCSV 读取导入文件的全部记录，去除 UTF-8 BOM
+func readImportCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImportFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImportFileSize {
+		return nil, errors.New("csv file too large")
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty csv")
+	}
+	if len(records)-1 > maxImportRows {
+		return nil, fmt.Errorf("too many rows, at most %d", maxImportRows)
+	}
+	return records, nil
+}
+
+func loadImportRecords(imp *AdminImport) ([][]string, error) {
+	rc, _, err := stores.Default().Read(imp.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return readImportCSV(rc)
+}
+
+// importDB 导入记录所在的库，与对象自身的 GetDB 无关
+func importDB(c *gin.Context) *gorm.DB {
+	return c.MustGet(constants.DbField).(*gorm.DB).Session(&gorm.Session{})
+}
+
+func (obj *AdminObject) loadImport(c *gin.Context) (*AdminImport, bool) {
+	var imp AdminImport
+	err := importDB(c).Where("id = ? AND object_name = ?", c.Param("id"), obj.Name).Take(&imp).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			hibiscusIM.AbortWithJSONError(c, http.StatusNotFound, errors.New("import not found"))
+		} else {
+			hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		}
+		return nil, false
+	}
+	return &imp, true
+}
+
+func encodeImportErrors(errs []AdminImportRowError) string {
+	if len(errs) > maxImportErrors {
+		errs = errs[:maxImportErrors]
+	}
+	data, _ := json.Marshal(errs)
+	return string(data)
+}
+
+func (obj *AdminObject) handleImportHistory(c *gin.Context) {
+	var imports []AdminImport
+	err := importDB(c).Where("object_name = ?", obj.Name).Order("id DESC").Limit(50).Find(&imports).Error
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, imports)
+}
+
+func (obj *AdminObject) handleImportUpload(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImportFileSize+1))
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	records, err := readImportCSV(bytes.NewReader(data))
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	key := fmt.Sprintf("imports/%s/%d_%s.csv", obj.tableName, time.Now().UnixNano(), util.RandText(8))
+	if err := stores.Default().Write(key, bytes.NewReader(data)); err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	mapping := obj.SuggestImportMapping(records[0])
+	mappingData, _ := json.Marshal(mapping)
+	imp := AdminImport{
+		ObjectName: obj.Name,
+		FileName:   filepath.Base(file.Filename),
+		ObjectKey:  key,
+		Status:     AdminImportUploaded,
+		Mapping:    string(mappingData),
+		TotalRows:  len(records) - 1,
+	}
+	if user := CurrentUser(c); user != nil {
+		imp.CreatedBy = user.ID
+	}
+	if err := importDB(c).Create(&imp).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	sample := records[1:]
+	if len(sample) > importSampleRows {
+		sample = sample[:importSampleRows]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"import":  imp,
+		"headers": records[0],
+		"mapping": mapping,
+		"fields":  obj.importFields(),
+		"sample":  sample,
+	})
+}
+
+func (obj *AdminObject) handleImportPreview(c *gin.Context) {
+	imp, ok := obj.loadImport(c)
+	if !ok {
+		return
+	}
+	if imp.Status != AdminImportUploaded && imp.Status != AdminImportValidated && imp.Status != AdminImportRolledBack {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("import is %s", imp.Status))
+		return
+	}
+	var form adminImportForm
+	if err := c.ShouldBindJSON(&form); err != nil && !errors.Is(err, io.EOF) {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if form.Mapping == nil {
+		_ = json.Unmarshal([]byte(imp.Mapping), &form.Mapping)
+	}
+
+	records, err := loadImportRecords(imp)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	rows, rowErrors, err := obj.validateImport(records, form.Mapping)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	mappingData, _ := json.Marshal(form.Mapping)
+	imp.Mapping = string(mappingData)
+	imp.Status = AdminImportValidated
+	imp.ValidRows = len(rows)
+	imp.ErrorRows = len(records) - 1 - len(rows)
+	imp.Errors = encodeImportErrors(rowErrors)
+	if err := importDB(c).Save(imp).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	preview := AdminImportPreview{
+		Import:  imp,
+		Headers: records[0],
+		Mapping: form.Mapping,
+		Fields:  obj.importFields(),
+		Errors:  rowErrors,
+		Sample:  []map[string]any{},
+	}
+	if len(preview.Errors) > maxImportErrors {
+		preview.Errors = preview.Errors[:maxImportErrors]
+	}
+	for i := 0; i < len(rows) && i < importSampleRows; i++ {
+		if m, err := obj.MarshalOne(c, rows[i].elem); err == nil {
+			preview.Sample = append(preview.Sample, m)
+		}
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+func (obj *AdminObject) handleImportCommit(c *gin.Context) {
+	imp, ok := obj.loadImport(c)
+	if !ok {
+		return
+	}
+	if imp.Status != AdminImportValidated {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, errors.New("import must be validated before commit"))
+		return
+	}
+	var form adminImportForm
+	if err := c.ShouldBindJSON(&form); err != nil && !errors.Is(err, io.EOF) {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	var mapping map[string]string
+	_ = json.Unmarshal([]byte(imp.Mapping), &mapping)
+
+	records, err := loadImportRecords(imp)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	rows, rowErrors, err := obj.validateImport(records, mapping)
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(rowErrors) > 0 && !form.SkipInvalid {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("%d rows have errors, fix them or commit with skipInvalid", len(records)-1-len(rows)))
+		return
+	}
+
+	idb := importDB(c)
+	imp.Status = AdminImportCommitting
+	imp.Imported = 0
+	imp.CreatedKeys = ""
+	imp.Message = ""
+	if err := idb.Save(imp).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	db := hibiscusIM.GetDbConnection(c, obj.GetDB, true)
+	commitErr := obj.commitImport(db, c, idb, imp, rows)
+	now := time.Now()
+	imp.CommittedAt = &now
+	if commitErr != nil {
+		imp.Status = AdminImportFailed
+		imp.Message = truncate(commitErr.Error(), 512)
+	} else {
+		imp.Status = AdminImportCommitted
+	}
+	if err := idb.Save(imp).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	RecordAdminEvent(c, "import", fmt.Sprintf("import %s: %s", obj.Name, imp.Status), map[string]any{"object": obj.Name, "import": imp.ID, "imported": imp.Imported})
+	if commitErr != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, commitErr)
+		return
+	}
+	c.JSON(http.StatusOK, imp)
+}
+
+// commitImport 分批写入，每批一个事务，成功的批次主键记录在导入记录中以便回滚
+func (obj *AdminObject) commitImport(db *gorm.DB, c *gin.Context, idb *gorm.DB, imp *AdminImport, rows []importRow) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(obj.Model); err != nil {
+		return err
+	}
+	var createdKeys []map[string]any
+	for start := 0; start < len(rows); start += adminImportBatchSize {
+		end := min(start+adminImportBatchSize, len(rows))
+		batch := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(obj.modelElem)), 0, end-start)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows[start:end] {
+				if obj.BeforeCreate != nil {
+					if err := obj.BeforeCreate(tx, c, row.elem); err != nil {
+						return fmt.Errorf("row %d: %w", row.row, err)
+					}
+				}
+				batch = reflect.Append(batch, reflect.ValueOf(row.elem))
+			}
+			return tx.Create(batch.Interface()).Error
+		})
+		if err != nil {
+			return fmt.Errorf("rows %d-%d: %w", rows[start].row, rows[end-1].row, err)
+		}
+
+		for i := 0; i < batch.Len(); i++ {
+			rv := batch.Index(i).Elem()
+			keys := map[string]any{}
+			for _, f := range stmt.Schema.PrimaryFields {
+				keys[f.DBName], _ = f.ValueOf(c, rv)
+			}
+			createdKeys = append(createdKeys, keys)
+		}
+		data, _ := json.Marshal(createdKeys)
+		imp.CreatedKeys = string(data)
+		imp.Imported = len(createdKeys)
+		if err := idb.Model(imp).Updates(map[string]any{"created_keys": imp.CreatedKeys, "imported": imp.Imported}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (obj *AdminObject) handleImportRollback(c *gin.Context) {
+	imp, ok := obj.loadImport(c)
+	if !ok {
+		return
+	}
+	if imp.Status != AdminImportFailed {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, errors.New("only failed imports can be rolled back"))
+		return
+	}
+	var createdKeys []map[string]any
+	if imp.CreatedKeys != "" {
+		if err := json.Unmarshal([]byte(imp.CreatedKeys), &createdKeys); err != nil {
+			hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	db := hibiscusIM.GetDbConnection(c, obj.GetDB, false)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, keys := range createdKeys {
+			if err := tx.Where(keys).Delete(reflect.New(obj.modelElem).Interface()).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	imp.Status = AdminImportRolledBack
+	imp.Message = fmt.Sprintf("rolled back %d rows", len(createdKeys))
+	imp.Imported = 0
+	imp.CreatedKeys = ""
+	if err := importDB(c).Save(imp).Error; err != nil {
+		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+	RecordAdminEvent(c, "import_rollback", fmt.Sprintf("import_rollback %s", obj.Name), map[string]any{"object": obj.Name, "import": imp.ID, "rows": len(createdKeys)})
+	c.JSON(http.StatusOK, imp)
+}
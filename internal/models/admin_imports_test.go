@@ -0,0 +1,219 @@
+package models
+
+import (
+	hibiscusIM "HibiscusIM"
+	constants "HibiscusIM/pkg/constant"
+	"HibiscusIM/pkg/middleware"
+	stores "HibiscusIM/pkg/storage"
+	"HibiscusIM/pkg/util"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type importTestItem struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Name  string `json:"name" gorm:"size:64"`
+	Count int    `json:"count"`
+}
+
+type importTestEnv struct {
+	db     *gorm.DB
+	router *gin.Engine
+}
+
+func newImportTestEnv(t *testing.T, user *User, beforeCreate hibiscusIM.BeforeCreateFunc) *importTestEnv {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "import.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&importTestItem{}, &AdminImport{}, &User{}, &Group{}, &GroupMember{}, &util.Config{}))
+
+	stores.SetDefault(&stores.LocalStore{Root: t.TempDir(), NewDirPerm: 0755})
+	t.Cleanup(func() { stores.SetDefault(nil) })
+
+	r := gin.New()
+	r.Use(middleware.WithMemSession("import-test"), func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		if user != nil {
+			c.Set(constants.UserField, user)
+		}
+		c.Next()
+	})
+	api := r.Group("/api")
+	objs := RegisterAdmins(api.Group("/admin"), db, []AdminObject{{
+		Model:        &importTestItem{},
+		Group:        "Test",
+		Name:         "Item",
+		Editables:    []string{"Name", "Count"},
+		Requireds:    []string{"Name"},
+		Importable:   true,
+		BeforeCreate: beforeCreate,
+	}})
+	RegisterAdminImports(api, db, objs)
+	return &importTestEnv{db: db, router: r}
+}
+
+func (env *importTestEnv) do(t *testing.T, method, url string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+	req := httptest.NewRequest(method, url, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func (env *importTestEnv) upload(t *testing.T, content string) AdminImport {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	fw, err := mw.CreateFormFile("file", "items.csv")
+	require.NoError(t, err)
+	_, err = fw.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	w := env.do(t, http.MethodPost, "/api/admin/item/_import", body, mw.FormDataContentType())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Import  AdminImport       `json:"import"`
+		Mapping map[string]string `json:"mapping"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, map[string]string{"name": "name", "Count": "count"}, resp.Mapping)
+	return resp.Import
+}
+
+func (env *importTestEnv) post(t *testing.T, url string, form any) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(form)
+	return env.do(t, http.MethodPost, url, bytes.NewBuffer(data), "application/json")
+}
+
+func TestAdminImportPreviewCommit(t *testing.T) {
+	env := newImportTestEnv(t, &User{ID: 1, IsStaff: true, IsSuperUser: true}, nil)
+	imp := env.upload(t, "\xef\xbb\xbfname,Count\nalice,1\n,2\nbob,x\ncarol,3\n")
+	assert.Equal(t, AdminImportUploaded, imp.Status)
+	assert.Equal(t, 4, imp.TotalRows)
+
+	// 未校验不能提交
+	w := env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/commit", imp.ID), gin.H{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/preview", imp.ID), gin.H{})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var preview AdminImportPreview
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, 2, preview.Import.ValidRows)
+	assert.Equal(t, 2, preview.Import.ErrorRows)
+	require.Len(t, preview.Errors, 2)
+	assert.Equal(t, AdminImportRowError{Row: 3, Field: "name", Message: "required"}, preview.Errors[0])
+	assert.Equal(t, 4, preview.Errors[1].Row)
+	assert.Equal(t, "count", preview.Errors[1].Field)
+	assert.Len(t, preview.Sample, 2)
+
+	// 有错误行时需要 skipInvalid
+	w = env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/commit", imp.ID), gin.H{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/commit", imp.ID), gin.H{"skipInvalid": true})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var committed AdminImport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &committed))
+	assert.Equal(t, AdminImportCommitted, committed.Status)
+	assert.Equal(t, 2, committed.Imported)
+
+	var items []importTestItem
+	require.NoError(t, env.db.Order("id").Find(&items).Error)
+	require.Len(t, items, 2)
+	assert.Equal(t, "alice", items[0].Name)
+	assert.Equal(t, 3, items[1].Count)
+
+	// 已提交的导入不能回滚
+	w = env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/rollback", imp.ID), gin.H{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdminImportRollbackFailedCommit(t *testing.T) {
+	failed := errors.New("row rejected")
+	env := newImportTestEnv(t, &User{ID: 1, IsStaff: true, IsSuperUser: true}, func(db *gorm.DB, c *gin.Context, obj any) error {
+		if obj.(*importTestItem).Name == "bad" {
+			return failed
+		}
+		return nil
+	})
+
+	// 第一批写入成功，第二批失败
+	var csv strings.Builder
+	csv.WriteString("name,Count\n")
+	for i := 0; i < adminImportBatchSize; i++ {
+		fmt.Fprintf(&csv, "item%d,%d\n", i, i)
+	}
+	csv.WriteString("bad,0\n")
+	imp := env.upload(t, csv.String())
+
+	w := env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/preview", imp.ID), gin.H{})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/commit", imp.ID), gin.H{})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var stored AdminImport
+	require.NoError(t, env.db.First(&stored, imp.ID).Error)
+	assert.Equal(t, AdminImportFailed, stored.Status)
+	assert.Equal(t, adminImportBatchSize, stored.Imported)
+	var count int64
+	require.NoError(t, env.db.Model(&importTestItem{}).Count(&count).Error)
+	assert.Equal(t, int64(adminImportBatchSize), count)
+
+	w = env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/rollback", imp.ID), gin.H{})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, env.db.Model(&importTestItem{}).Count(&count).Error)
+	assert.Equal(t, int64(0), count)
+	require.NoError(t, env.db.First(&stored, imp.ID).Error)
+	assert.Equal(t, AdminImportRolledBack, stored.Status)
+
+	// 回滚后可重新校验
+	w = env.post(t, fmt.Sprintf("/api/admin/item/_import/%d/preview", imp.ID), gin.H{})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAdminImportHistory(t *testing.T) {
+	env := newImportTestEnv(t, &User{ID: 1, IsStaff: true, IsSuperUser: true}, nil)
+	first := env.upload(t, "name,Count\nalice,1\n")
+	second := env.upload(t, "name,Count\nbob,2\n")
+
+	w := env.do(t, http.MethodGet, "/api/_imports/item", nil, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var imports []AdminImport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imports))
+	require.Len(t, imports, 2)
+	assert.Equal(t, second.ID, imports[0].ID)
+	assert.Equal(t, first.ID, imports[1].ID)
+
+	// 历史记录不再挂在 POST 路由上
+	w = env.do(t, http.MethodPost, "/api/admin/item/_import/history", nil, "")
+	assert.NotEqual(t, http.StatusOK, w.Code)
+}
+
+func TestAdminImportHistoryRequiresAdmin(t *testing.T) {
+	env := newImportTestEnv(t, nil, nil)
+	w := env.do(t, http.MethodGet, "/api/_imports/item", nil, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	env = newImportTestEnv(t, &User{ID: 2}, nil)
+	w = env.do(t, http.MethodGet, "/api/_imports/item", nil, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	hibiscusIM "HibiscusIM"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 后台对象的操作，对应 AdminObject.Permissions 中的 can_<操作>
+const (
+	AdminScopeView   = "view"
+	AdminScopeCreate = "create"
+	AdminScopeUpdate = "update"
+	AdminScopeDelete = "delete"
+	AdminScopeAction = "action"
+)
+
+// AdminScopePrefix 群组权限中后台授权的前缀，格式为 admin:<范围>:<操作>，范围为业务组（如 Survey）、
+// 业务组/对象名（如 Survey/Questionnaire）或 *，操作为 view、create、update、delete、action 或 *
+const AdminScopePrefix = "admin:"
+
+// adminScopesField 请求内缓存的后台授权
+const adminScopesField = "_hibiscus_admin_scopes"
+
+var ErrAdminScopeDenied = errors.New("admin scope denied")
+
+// AdminScopes 管理员通过所在群组获得的后台授权，未授权即拒绝：超级用户拥有全部权限，
+// 其他管理员只能看到并操作授权的对象，需要全部权限时授予 admin:*:*。
+// 授权每个请求按当前所在的群组重新汇总，退出或被踢出群组后随即失效
+type AdminScopes struct {
+	// all 超级用户，不受范围限制
+	all bool
+	// grants 范围 -> 操作
+	grants map[string]map[string]bool
+}
+
+// ParseAdminScope 解析群组权限中的后台授权，不是后台授权或格式错误时返回 false
+func ParseAdminScope(permission string) (scope, action string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(permission), AdminScopePrefix)
+	if !found {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", false
+	}
+	scope, action = rest[:i], rest[i+1:]
+	switch action {
+	case AdminScopeView, AdminScopeCreate, AdminScopeUpdate, AdminScopeDelete, AdminScopeAction, "*":
+		return scope, action, true
+	}
+	return "", "", false
+}
+
+// LoadAdminScopes 汇总用户所在群组的后台授权
+func LoadAdminScopes(db *gorm.DB, user *User) (*AdminScopes, error) {
+	if user == nil {
+		return &AdminScopes{}, nil
+	}
+	if user.IsSuperUser {
+		return &AdminScopes{all: true}, nil
+	}
+	var groups []Group
+	err := db.Model(&Group{}).
+		Where("id IN (?)", db.Model(&GroupMember{}).Select("group_id").Where("user_id = ?", user.ID)).
+		Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	scopes := &AdminScopes{grants: make(map[string]map[string]bool)}
+	for _, g := range groups {
+		for _, p := range g.Permission.Permissions {
+			scope, action, ok := ParseAdminScope(p)
+			if !ok {
+				continue
+			}
+			if scopes.grants[scope] == nil {
+				scopes.grants[scope] = make(map[string]bool)
+			}
+			scopes.grants[scope][action] = true
+		}
+	}
+	return scopes, nil
+}
+
+// Restricted 是否只能访问授权的对象，超级用户与被授予 admin:*:* 的管理员不受限
+func (s *AdminScopes) Restricted() bool {
+	return !s.all && !s.grants["*"]["*"]
+}
+
+// Allow 是否允许对 obj 执行 action，被授予任一操作时同时允许查看
+func (s *AdminScopes) Allow(obj *AdminObject, action string) bool {
+	if !s.Restricted() {
+		return true
+	}
+	for _, scope := range []string{"*", obj.Group, obj.Group + "/" + obj.Name} {
+		actions := s.grants[scope]
+		if actions["*"] || actions[action] || (action == AdminScopeView && len(actions) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentAdminScopes 当前用户的后台授权，同一请求内只查询一次
+func CurrentAdminScopes(c *gin.Context, db *gorm.DB) (*AdminScopes, error) {
+	if cached, ok := c.Get(adminScopesField); ok {
+		return cached.(*AdminScopes), nil
+	}
+	scopes, err := LoadAdminScopes(db, CurrentUser(c))
+	if err != nil {
+		return nil, err
+	}
+	c.Set(adminScopesField, scopes)
+	return scopes, nil
+}
+
+// requireScope 检查当前用户对该对象的操作授权
+func (obj *AdminObject) requireScope(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := hibiscusIM.GetDbConnection(c, obj.GetDB, false)
+		scopes, err := CurrentAdminScopes(c, db)
+		if err != nil {
+			hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !scopes.Allow(obj, action) {
+			hibiscusIM.AbortWithJSONError(c, http.StatusForbidden, ErrAdminScopeDenied)
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasAdminScopes 权限列表中是否包含后台授权，用于限制只有超级用户可以分配
+func HasAdminScopes(permissions []string) bool {
+	for _, p := range permissions {
+		if strings.HasPrefix(strings.TrimSpace(p), AdminScopePrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	constants "HibiscusIM/pkg/constant"
+	"HibiscusIM/pkg/middleware"
+	"HibiscusIM/pkg/util"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseAdminScope(t *testing.T) {
+	cases := map[string][3]any{
+		"admin:Survey:view":              {"Survey", "view", true},
+		" admin:Survey/Questionnaire:* ": {"Survey/Questionnaire", "*", true},
+		"admin:*:*":                      {"*", "*", true},
+		"admin:Survey:publish":           {"", "", false},
+		"admin::view":                    {"", "", false},
+		"admin:Survey:":                  {"", "", false},
+		"chat:send":                      {"", "", false},
+	}
+	for permission, want := range cases {
+		scope, action, ok := ParseAdminScope(permission)
+		assert.Equal(t, want, [3]any{scope, action, ok}, permission)
+	}
+}
+
+func newAdminScopeTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "scopes.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&User{}, &Group{}, &GroupMember{}, &GroupModerationEvent{}, &util.Config{}, &importTestItem{}))
+	return db
+}
+
+// joinAdminScopeGroup 创建带有后台授权的群组并加入 user
+func joinAdminScopeGroup(t *testing.T, db *gorm.DB, user *User, permissions ...string) *Group {
+	group := &Group{Name: "staff", Permission: GroupPermission{Permissions: permissions}}
+	require.NoError(t, db.Create(group).Error)
+	require.NoError(t, db.Create(&GroupMember{GroupID: group.ID, UserID: user.ID, Role: GroupRoleMember}).Error)
+	return group
+}
+
+func TestAdminScopesFailClosed(t *testing.T) {
+	db := newAdminScopeTestDB(t)
+	survey := &AdminObject{Group: "Survey", Name: "Questionnaire"}
+	settings := &AdminObject{Group: "Settings", Name: "Config"}
+
+	allow := func(user *User, obj *AdminObject, action string) bool {
+		scopes, err := LoadAdminScopes(db, user)
+		require.NoError(t, err)
+		return scopes.Allow(obj, action)
+	}
+
+	// 超级用户不受限
+	super := &User{Email: "root@example.com", IsStaff: true, IsSuperUser: true}
+	require.NoError(t, db.Create(super).Error)
+	assert.True(t, allow(super, settings, AdminScopeDelete))
+
+	// 没有任何授权的管理员与未登录用户全部拒绝
+	staff := &User{Email: "staff@example.com", IsStaff: true}
+	require.NoError(t, db.Create(staff).Error)
+	for _, action := range []string{AdminScopeView, AdminScopeCreate, AdminScopeUpdate, AdminScopeDelete, AdminScopeAction} {
+		assert.False(t, allow(staff, survey, action), action)
+		assert.False(t, allow(nil, survey, action), action)
+	}
+
+	// 非后台授权不影响
+	joinAdminScopeGroup(t, db, staff, "chat:send")
+	assert.False(t, allow(staff, survey, AdminScopeView))
+
+	// 按业务组、对象授权，授予任一操作时允许查看
+	group := joinAdminScopeGroup(t, db, staff, "admin:Survey:view", "admin:Survey/Questionnaire:update")
+	assert.True(t, allow(staff, survey, AdminScopeView))
+	assert.True(t, allow(staff, survey, AdminScopeUpdate))
+	assert.False(t, allow(staff, survey, AdminScopeDelete))
+	assert.False(t, allow(staff, settings, AdminScopeView))
+
+	// 被踢出群组后授权随即失效，不会退回到全部权限
+	_, err := KickGroupMember(db, group.ID, staff.ID, super.ID, "")
+	require.NoError(t, err)
+	assert.False(t, allow(staff, survey, AdminScopeView))
+	assert.False(t, allow(staff, settings, AdminScopeView))
+
+	// 全部权限需要显式授予 admin:*:*，退出群组后同样失效
+	all := joinAdminScopeGroup(t, db, staff, "admin:*:*")
+	assert.True(t, allow(staff, settings, AdminScopeDelete))
+	require.NoError(t, db.Where("group_id = ? AND user_id = ?", all.ID, staff.ID).Delete(&GroupMember{}).Error)
+	assert.False(t, allow(staff, settings, AdminScopeView))
+
+	// 群组删除后同样失效
+	all = joinAdminScopeGroup(t, db, staff, "admin:*:*")
+	require.NoError(t, db.Delete(all).Error)
+	assert.False(t, allow(staff, settings, AdminScopeView))
+}
+
+func TestHandleAdminJsonPermissionsPerResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newAdminScopeTestDB(t)
+	super := &User{Email: "root@example.com", IsStaff: true, IsSuperUser: true}
+	viewer := &User{Email: "viewer@example.com", IsStaff: true}
+	require.NoError(t, db.Create(super).Error)
+	require.NoError(t, db.Create(viewer).Error)
+	joinAdminScopeGroup(t, db, viewer, "admin:Test/Item:view")
+	users := map[string]*User{"root": super, "viewer": viewer}
+
+	r := gin.New()
+	r.Use(middleware.WithMemSession("scope-test"), func(c *gin.Context) {
+		c.Set(constants.DbField, db)
+		if user := users[c.GetHeader("X-User")]; user != nil {
+			c.Set(constants.UserField, user)
+		}
+		c.Next()
+	})
+	objs := RegisterAdmins(r.Group("/admin"), db, []AdminObject{
+		{Model: &importTestItem{}, Group: "Test", Name: "Item"},
+		{Model: &Group{}, Group: "Settings", Name: "Group"},
+	})
+
+	type viewObject struct {
+		Name        string          `json:"name"`
+		Permissions map[string]bool `json:"permissions"`
+	}
+	fetch := func(user string) []viewObject {
+		req := httptest.NewRequest(http.MethodPost, "/admin/admin.json", nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Objects []viewObject `json:"objects"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Objects
+	}
+
+	root := fetch("root")
+	require.Len(t, root, 2)
+	assert.True(t, root[0].Permissions["can_delete"])
+
+	scoped := fetch("viewer")
+	require.Len(t, scoped, 1, "only granted objects are listed")
+	assert.Equal(t, "Item", scoped[0].Name)
+	assert.Empty(t, scoped[0].Permissions)
+
+	// 不同用户的权限互不影响，共享的对象本身不被修改
+	assert.True(t, fetch("root")[0].Permissions["can_delete"])
+	for _, obj := range objs {
+		assert.Nil(t, obj.Permissions, obj.Name)
+	}
+
+	// 只有查看权限时不能修改
+	req := httptest.NewRequest(http.MethodDelete, "/admin/item/?id=1", nil)
+	req.Header.Set("X-User", "viewer")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
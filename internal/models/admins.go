@@ -15,6 +15,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -34,6 +35,7 @@ type AdminBuildContext func(*gin.Context, map[string]any) map[string]any
 type AdminQueryResult struct {
 	TotalCount int              `json:"total,omitempty"`
 	Pos        int              `json:"pos,omitempty"`
+	NextCursor string           `json:"nextCursor,omitempty"`
 	Limit      int              `json:"limit,omitempty"`
 	Keyword    string           `json:"keyword,omitempty"`
 	Items      []map[string]any `json:"items"`
@@ -132,6 +134,9 @@ type AdminObject struct {
 	Icon        *AdminIcon         `json:"icon,omitempty"`
 	Invisible   bool               `json:"invisible,omitempty"`
 	ViewOnSite  AdminViewOnSite    `json:"-"`
+	// Importable enables CSV import, ImportFields limits the importable fields (defaults to Editables)
+	Importable   bool     `json:"importable,omitempty"`
+	ImportFields []string `json:"importFields,omitempty"`
 
 	Attributes       map[string]AdminAttribute   `json:"-"` // Field's extra attributes
 	AccessCheck      AdminAccessCheck            `json:"-"` // Access control function
@@ -189,9 +194,16 @@ func GetHibiscusAdminObjects() []AdminObject {
 					if dbUser.Password != user.Password {
 						user.Password = HashPassword(user.Password)
 					}
+					if dbUser.Enabled && !user.Enabled {
+						util.Sig().Emit(SigUserAuthRevoked, user, "disabled")
+					}
 				}
 				return nil
 			},
+			BeforeDelete: func(db *gorm.DB, c *gin.Context, obj any) error {
+				util.Sig().Emit(SigUserAuthRevoked, obj.(*User), "deleted")
+				return nil
+			},
 			Actions: []AdminAction{
 				{
 					Path:  "toggle_enabled",
@@ -199,8 +211,12 @@ func GetHibiscusAdminObjects() []AdminObject {
 					Label: "Toggle user enabled/disabled",
 					Handler: func(db *gorm.DB, c *gin.Context, obj any) (bool, any, error) {
 						user := obj.(*User)
-						err := UpdateUserFields(db, user, map[string]any{"Enabled": !user.Enabled})
-						return false, user.Enabled, err
+						enabled := !user.Enabled
+						err := UpdateUserFields(db, user, map[string]any{"Enabled": enabled})
+						if err == nil && !enabled {
+							util.Sig().Emit(SigUserAuthRevoked, user, "disabled")
+						}
+						return false, enabled, err
 					},
 				},
 				{
@@ -325,8 +341,8 @@ func BuildAdminObjects(r *gin.RouterGroup, db *gorm.DB, objs []AdminObject) []*A
 	return handledObjects
 }
 
-// RegisterAdmins registers admin routes
-func RegisterAdmins(r *gin.RouterGroup, db *gorm.DB, objs []AdminObject) {
+// RegisterAdmins registers admin routes and returns the registered objects
+func RegisterAdmins(r *gin.RouterGroup, db *gorm.DB, objs []AdminObject) []*AdminObject {
 	r.Use(middleware.InjectDB(db))
 	r.Use(WithAdminAuth())
 
@@ -368,6 +384,7 @@ func RegisterAdmins(r *gin.RouterGroup, db *gorm.DB, objs []AdminObject) {
 		}
 		ctx.FileFromFS(filepath.Join("admin", name), http.FS(staticAssets))
 	})
+	return handledObjects
 }
 
 func HandleAdminJson(c *gin.Context, objects []*AdminObject, buildContext AdminBuildContext) {
@@ -380,8 +397,17 @@ func HandleAdminJson(c *gin.Context, objects []*AdminObject, buildContext AdminB
 			}
 		}
 		db := hibiscusIM.GetDbConnection(c, obj.GetDB, false)
+		scopes, err := CurrentAdminScopes(c, db)
+		if err != nil {
+			hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !scopes.Allow(obj, AdminScopeView) {
+			continue
+		}
+		// 权限按当前用户计算，只写入本次响应的副本
 		val := *obj
-		val.BuildPermissions(db, CurrentUser(c))
+		val.Permissions = obj.scopedPermissions(scopes)
 		viewObjects = append(viewObjects, val)
 	}
 
@@ -397,21 +423,25 @@ func HandleAdminJson(c *gin.Context, objects []*AdminObject, buildContext AdminB
 	})
 }
 
-func (obj *AdminObject) BuildPermissions(db *gorm.DB, user *User) {
-	obj.Permissions = map[string]bool{}
-	if user.IsSuperUser {
-		obj.Permissions["can_create"] = true
-		obj.Permissions["can_update"] = true
-		obj.Permissions["can_delete"] = true
-		obj.Permissions["can_action"] = true
-		return
+// BuildPermissions returns the permissions granted to user through group settings,
+// obj itself is shared by all requests and is not modified
+func (obj *AdminObject) BuildPermissions(db *gorm.DB, user *User) map[string]bool {
+	scopes, err := LoadAdminScopes(db, user)
+	if err != nil {
+		logger.Warn("load admin scopes fail, deny all: " + err.Error())
+		scopes = &AdminScopes{}
 	}
+	return obj.scopedPermissions(scopes)
+}
 
-	//TODO: build permissions with group settings
-	obj.Permissions["can_create"] = true
-	obj.Permissions["can_update"] = true
-	obj.Permissions["can_delete"] = true
-	obj.Permissions["can_action"] = true
+func (obj *AdminObject) scopedPermissions(scopes *AdminScopes) map[string]bool {
+	permissions := map[string]bool{}
+	for _, action := range []string{AdminScopeCreate, AdminScopeUpdate, AdminScopeDelete, AdminScopeAction} {
+		if scopes.Allow(obj, action) {
+			permissions["can_"+action] = true
+		}
+	}
+	return permissions
 }
 
 // RegisterAdmin registers admin routes
@@ -421,23 +451,31 @@ func (obj *AdminObject) BuildPermissions(db *gorm.DB, user *User) {
 //   - PATCH /admin/{objectslug}} -> Update One
 //   - DELETE /admin/{objectslug} -> Delete One
 //   - POST /admin/{objectslug}/:name -> Action
+//   - POST /admin/{objectslug}/_import... -> CSV import, see registerImport and RegisterAdminImports
+//
+// Besides AccessCheck, each route requires the matching admin scope, see AdminScopes
 func (obj *AdminObject) RegisterAdmin(r gin.IRoutes) {
-	r = r.Use(func(ctx *gin.Context) {
-		if obj.AccessCheck != nil {
-			err := obj.AccessCheck(ctx, obj)
-			if err != nil {
-				hibiscusIM.AbortWithJSONError(ctx, http.StatusForbidden, err)
-				return
-			}
-		}
-		ctx.Next()
-	})
+	r = r.Use(obj.checkAccess)
 
-	r.POST("/", obj.handleQueryOrGetOne)
-	r.PUT("/", obj.handleCreate)
-	r.PATCH("/", obj.handleUpdate)
-	r.DELETE("/", obj.handleDelete)
-	r.POST("/:name", obj.handleAction)
+	r.POST("/", obj.requireScope(AdminScopeView), obj.handleQueryOrGetOne)
+	r.PUT("/", obj.requireScope(AdminScopeCreate), obj.handleCreate)
+	r.PATCH("/", obj.requireScope(AdminScopeUpdate), obj.handleUpdate)
+	r.DELETE("/", obj.requireScope(AdminScopeDelete), obj.handleDelete)
+	r.POST("/:name", obj.requireScope(AdminScopeAction), obj.handleAction)
+	if obj.Importable {
+		obj.registerImport(r)
+	}
+}
+
+// checkAccess runs the object's AccessCheck
+func (obj *AdminObject) checkAccess(ctx *gin.Context) {
+	if obj.AccessCheck != nil {
+		if err := obj.AccessCheck(ctx, obj); err != nil {
+			hibiscusIM.AbortWithJSONError(ctx, http.StatusForbidden, err)
+			return
+		}
+	}
+	ctx.Next()
 }
 
 func (obj *AdminObject) asColNames(db *gorm.DB, fields []string) []string {
@@ -471,6 +509,7 @@ func (obj *AdminObject) Build(db *gorm.DB) error {
 	obj.Searchables = obj.asColNames(db, obj.Searchables)
 	obj.Filterables = obj.asColNames(db, obj.Filterables)
 	obj.Requireds = obj.asColNames(db, obj.Requireds)
+	obj.ImportFields = obj.asColNames(db, obj.ImportFields)
 	obj.primaryKeyMaping = map[string]string{}
 
 	for idx := range obj.Orders {
@@ -729,9 +768,14 @@ func convertValue(elemType reflect.Type, source any, targetIsPtr bool) (any, err
 }
 
 func (obj *AdminObject) UnmarshalFrom(elemObj reflect.Value, keys, vals map[string]any) (any, error) {
-	if len(obj.Editables) > 0 {
+	return obj.unmarshalFields(elemObj, keys, vals, obj.Editables)
+}
+
+// unmarshalFields fills elemObj with vals, only fields in allowed are accepted when allowed is not empty
+func (obj *AdminObject) unmarshalFields(elemObj reflect.Value, keys, vals map[string]any, allowed []string) (any, error) {
+	if len(allowed) > 0 {
 		editables := make(map[string]bool)
-		for _, v := range obj.Editables {
+		for _, v := range allowed {
 			editables[v] = true
 		}
 		for k := range vals {
@@ -930,9 +974,11 @@ func (obj *AdminObject) QueryObjects(session *gorm.DB, form *hibiscusIM.QueryFor
 		orders = obj.Orders
 	}
 
-	for _, v := range orders {
-		if q := v.GetQuery(); q != "" && v.Op != "" {
-			session = session.Order(fmt.Sprintf("`%s`.%s", obj.tableName, q))
+	if form.Cursor == nil {
+		for _, v := range orders {
+			if q := v.GetQuery(); q != "" && v.Op != "" {
+				session = session.Order(fmt.Sprintf("`%s`.%s", obj.tableName, q))
+			}
 		}
 	}
 
@@ -945,9 +991,12 @@ func (obj *AdminObject) QueryObjects(session *gorm.DB, form *hibiscusIM.QueryFor
 		session = session.Where(searchKey, sql.Named("keyword", "%"+form.Keyword+"%"))
 	}
 
-	r.Pos = form.Pos
 	r.Limit = form.Limit
 	r.Keyword = form.Keyword
+	if form.Cursor != nil {
+		return obj.queryObjectsByCursor(session, form, orders, ctx, r)
+	}
+	r.Pos = form.Pos
 
 	session = session.Model(obj.Model)
 
@@ -960,6 +1009,63 @@ func (obj *AdminObject) QueryObjects(session *gorm.DB, form *hibiscusIM.QueryFor
 	}
 	r.TotalCount = int(c)
 
+	vals := reflect.New(reflect.SliceOf(obj.modelElem))
+	tx := session.Preload(clause.Associations).Select(obj.selectedColumns()).Offset(form.Pos)
+	if form.Limit > 0 {
+		tx = tx.Limit(form.Limit)
+	}
+	result := tx.Find(vals.Interface())
+	if result.Error != nil {
+		return r, result.Error
+	}
+	err = obj.renderItems(ctx, vals.Elem(), &r)
+	return r, err
+}
+
+// queryObjectsByCursor 按游标（keyset）分页查询，不统计总数，排序字段须为主键或索引列
+func (obj *AdminObject) queryObjectsByCursor(session *gorm.DB, form *hibiscusIM.QueryForm, orders []hibiscusIM.Order, ctx *gin.Context, r AdminQueryResult) (AdminQueryResult, error) {
+	var keysetOrders []hibiscusIM.Order
+	for _, v := range orders {
+		if v.Op != "" {
+			keysetOrders = append(keysetOrders, v)
+		}
+	}
+	keyset, err := hibiscusIM.NewKeyset(session, obj.Model, keysetOrders)
+	if err != nil {
+		return r, err
+	}
+	selected := obj.selectedColumns()
+	for _, col := range keyset.Columns() {
+		if !slices.Contains(selected, col) {
+			selected = append(selected, col)
+		}
+	}
+	session, err = keyset.Apply(session.Model(obj.Model), *form.Cursor)
+	if err != nil {
+		return r, err
+	}
+
+	limit := form.Limit
+	if limit <= 0 {
+		limit = hibiscusIM.DefaultQueryLimit
+	}
+	vals := reflect.New(reflect.SliceOf(obj.modelElem))
+	if err := session.Preload(clause.Associations).Select(selected).Limit(limit + 1).Find(vals.Interface()).Error; err != nil {
+		return r, err
+	}
+	rows := vals.Elem()
+	if rows.Len() > limit {
+		rows = rows.Slice(0, limit)
+		if r.NextCursor, err = keyset.Cursor(session, rows.Index(rows.Len()-1).Addr().Interface()); err != nil {
+			return r, err
+		}
+	}
+	err = obj.renderItems(ctx, rows, &r)
+	return r, err
+}
+
+// selectedColumns 查询列表时需要读取的列
+func (obj *AdminObject) selectedColumns() []string {
 	selected := []string{}
 	for _, v := range obj.Fields {
 		if v.NotColumn {
@@ -971,25 +1077,19 @@ func (obj *AdminObject) QueryObjects(session *gorm.DB, form *hibiscusIM.QueryFor
 			selected = append(selected, v.Name)
 		}
 	}
+	return selected
+}
 
-	vals := reflect.New(reflect.SliceOf(obj.modelElem))
-	tx := session.Preload(clause.Associations).Select(selected).Offset(form.Pos)
-	if form.Limit > 0 {
-		tx = tx.Limit(form.Limit)
-	}
-	result := tx.Find(vals.Interface())
-	if result.Error != nil {
-		return r, result.Error
-	}
-
-	for i := 0; i < vals.Elem().Len(); i++ {
-		modelObj := vals.Elem().Index(i).Addr().Interface()
+// renderItems 对查询结果执行 BeforeRender 并序列化到 r.Items
+func (obj *AdminObject) renderItems(ctx *gin.Context, rows reflect.Value, r *AdminQueryResult) error {
+	for i := 0; i < rows.Len(); i++ {
+		modelObj := rows.Index(i).Addr().Interface()
 		r.objects = append(r.objects, modelObj)
 		if obj.BeforeRender != nil {
 			db := hibiscusIM.GetDbConnection(ctx, obj.GetDB, false)
 			rr, err := obj.BeforeRender(db, ctx, modelObj)
 			if err != nil {
-				return r, err
+				return err
 			}
 			if rr != nil {
 				// if BeforeRender return not nil, then use it as result
@@ -998,11 +1098,11 @@ func (obj *AdminObject) QueryObjects(session *gorm.DB, form *hibiscusIM.QueryFor
 		}
 		item, err := obj.MarshalOne(ctx, modelObj)
 		if err != nil {
-			return r, err
+			return err
 		}
 		r.Items = append(r.Items, item)
 	}
-	return r, nil
+	return nil
 }
 
 // Query many objects with filter/limit/offset/order/search
@@ -1023,7 +1123,10 @@ func (obj *AdminObject) handleQueryOrGetOne(c *gin.Context) {
 	}
 
 	r, err := obj.QueryObjects(db, form, c)
-
+	if errors.Is(err, hibiscusIM.ErrInvalidCursor) {
+		hibiscusIM.AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
 	if err != nil {
 		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
 		return
@@ -1081,6 +1184,7 @@ func (obj *AdminObject) handleCreate(c *gin.Context) {
 		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, result.Error)
 		return
 	}
+	obj.recordAdminEvent(c, "create", keys)
 	if obj.BeforeRender != nil {
 		rr, err := obj.BeforeRender(db, c, elm)
 		if err != nil {
@@ -1156,6 +1260,7 @@ func (obj *AdminObject) handleUpdate(c *gin.Context) {
 		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, result.Error)
 		return
 	}
+	obj.recordAdminEvent(c, "update", keys)
 	c.JSON(http.StatusOK, true)
 }
 
@@ -1191,6 +1296,7 @@ func (obj *AdminObject) handleDelete(c *gin.Context) {
 		hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, r.Error)
 		return
 	}
+	obj.recordAdminEvent(c, "delete", keys)
 	c.JSON(http.StatusOK, true)
 }
 
@@ -1207,6 +1313,7 @@ func (obj *AdminObject) handleAction(c *gin.Context) {
 				hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
 				return
 			}
+			obj.recordAdminEvent(c, action.Path, nil)
 			if !handled {
 				c.JSON(http.StatusOK, r)
 			}
@@ -1224,6 +1331,7 @@ func (obj *AdminObject) handleAction(c *gin.Context) {
 				hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
 				return
 			}
+			obj.recordAdminEvent(c, action.Path, keys)
 			if !handled {
 				c.JSON(http.StatusOK, r)
 			}
@@ -1251,6 +1359,7 @@ func (obj *AdminObject) handleAction(c *gin.Context) {
 			hibiscusIM.AbortWithJSONError(c, http.StatusInternalServerError, err)
 			return
 		}
+		obj.recordAdminEvent(c, action.Path, keys)
 
 		if !handled {
 			c.JSON(http.StatusOK, r)
@@ -1259,3 +1368,12 @@ func (obj *AdminObject) handleAction(c *gin.Context) {
 	}
 	c.AbortWithStatus(http.StatusBadRequest)
 }
+
+// recordAdminEvent records a successful admin write in the system event journal.
+func (obj *AdminObject) recordAdminEvent(c *gin.Context, action string, keys any) {
+	metadata := map[string]any{"object": obj.Name}
+	if keys != nil {
+		metadata["keys"] = keys
+	}
+	RecordAdminEvent(c, action, fmt.Sprintf("%s %s", action, obj.Name), metadata)
+}
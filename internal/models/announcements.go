@@ -0,0 +1,347 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 公告级别
+const (
+	AnnouncementInfo     = "info"
+	AnnouncementWarning  = "warning"
+	AnnouncementCritical = "critical"
+)
+
+// 公告状态
+const (
+	AnnouncementDraft     = "draft"     // 未排期
+	AnnouncementScheduled = "scheduled" // 等待到期发送
+	AnnouncementSending   = "sending"   // 正在投递
+	AnnouncementSent      = "sent"      // 已投递
+	AnnouncementCancelled = "cancelled" // 已取消
+)
+
+// announcementBatchSize 每批解析与投递的接收人数
+const announcementBatchSize = 500
+
+var ErrInvalidAnnouncement = errors.New("invalid announcement")
+
+// UserSegment 按用户属性圈选的用户群，多个条件同时满足，列表条件命中任一取值即可
+type UserSegment struct {
+	Staff            *bool      `json:"staff,omitempty"`
+	Locales          []string   `json:"locales,omitempty"`
+	Countries        []string   `json:"countries,omitempty"`
+	Sources          []string   `json:"sources,omitempty"`
+	ActiveWithinDays int        `json:"activeWithinDays,omitempty"` // 最近 N 天内登录过
+	JoinedAfter      *time.Time `json:"joinedAfter,omitempty"`
+}
+
+// AnnouncementTarget 公告的接收范围，All 为全部启用的用户，否则为群组成员、指定用户与用户群的并集
+type AnnouncementTarget struct {
+	All      bool          `json:"all,omitempty"`
+	GroupIDs []uint        `json:"groupIds,omitempty"`
+	UserIDs  []uint        `json:"userIds,omitempty"`
+	Segments []UserSegment `json:"segments,omitempty"`
+}
+
+// Empty 是否未指定任何接收人
+func (t AnnouncementTarget) Empty() bool {
+	return !t.All && len(t.GroupIDs) == 0 && len(t.UserIDs) == 0 && len(t.Segments) == 0
+}
+
+// 实现 driver.Valuer 接口
+func (t AnnouncementTarget) Value() (driver.Value, error) {
+	data, err := json.Marshal(t)
+	return string(data), err
+}
+
+// 实现 sql.Scanner 接口
+func (t *AnnouncementTarget) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	case nil:
+		*t = AnnouncementTarget{}
+		return nil
+	}
+	return fmt.Errorf("failed to convert %T to AnnouncementTarget", value)
+}
+
+// Announcement 系统公告，同时通过 WebSocket、SSE 与站内通知投递
+type Announcement struct {
+	ID          uint               `json:"id" gorm:"primaryKey"`
+	Title       string             `json:"title" gorm:"size:200"`
+	Body        string             `json:"body"`
+	Severity    string             `json:"severity" gorm:"size:16"`
+	CTAText     string             `json:"ctaText,omitempty" gorm:"size:64"`
+	CTAURL      string             `json:"ctaUrl,omitempty" gorm:"size:512"`
+	Target      AnnouncementTarget `json:"target" gorm:"type:text"`
+	Status      string             `json:"status" gorm:"size:16;index"`
+	ScheduledAt *time.Time         `json:"scheduledAt,omitempty" gorm:"index"`
+	SentAt      *time.Time         `json:"sentAt,omitempty"`
+	Recipients  int                `json:"recipients"` // 投递时的接收人数
+	CreatedBy   uint               `json:"createdBy"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// Validate 校验公告内容与接收范围
+func (a *Announcement) Validate() error {
+	if a.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidAnnouncement)
+	}
+	switch a.Severity {
+	case "":
+		a.Severity = AnnouncementInfo
+	case AnnouncementInfo, AnnouncementWarning, AnnouncementCritical:
+	default:
+		return fmt.Errorf("%w: unknown severity %q", ErrInvalidAnnouncement, a.Severity)
+	}
+	if a.Target.Empty() {
+		return fmt.Errorf("%w: target is required", ErrInvalidAnnouncement)
+	}
+	return nil
+}
+
+// AnnouncementReceipt 用户收到公告的记录，跟踪查看与关闭状态
+type AnnouncementReceipt struct {
+	ID             uint       `json:"-" gorm:"primaryKey"`
+	AnnouncementID uint       `json:"announcementId" gorm:"uniqueIndex:idx_announcement_user"`
+	UserID         uint       `json:"userId" gorm:"uniqueIndex:idx_announcement_user;index"`
+	DeliveredAt    time.Time  `json:"deliveredAt"`
+	SeenAt         *time.Time `json:"seenAt,omitempty"`
+	DismissedAt    *time.Time `json:"dismissedAt,omitempty"`
+}
+
+// AnnouncementReach 公告触达统计
+type AnnouncementReach struct {
+	AnnouncementID uint    `json:"announcementId"`
+	Recipients     int64   `json:"recipients"`
+	Seen           int64   `json:"seen"`
+	Dismissed      int64   `json:"dismissed"`
+	SeenRate       float64 `json:"seenRate"`
+}
+
+// segmentQuery 用户群的查询条件
+func segmentQuery(db *gorm.DB, s UserSegment, now time.Time) *gorm.DB {
+	q := db.Model(&User{}).Where("enabled = ? AND deleted_at IS NULL", true)
+	if s.Staff != nil {
+		q = q.Where("is_staff = ?", *s.Staff)
+	}
+	if len(s.Locales) > 0 {
+		q = q.Where("locale IN ?", s.Locales)
+	}
+	if len(s.Countries) > 0 {
+		q = q.Where("country IN ?", s.Countries)
+	}
+	if len(s.Sources) > 0 {
+		q = q.Where("source IN ?", s.Sources)
+	}
+	if s.ActiveWithinDays > 0 {
+		q = q.Where("last_login >= ?", now.AddDate(0, 0, -s.ActiveWithinDays))
+	}
+	if s.JoinedAfter != nil {
+		q = q.Where("created_at >= ?", *s.JoinedAfter)
+	}
+	return q
+}
+
+// ResolveAnnouncementRecipients 解析接收范围内全部启用用户的 ID，按 ID 升序去重
+func ResolveAnnouncementRecipients(ctx context.Context, db *gorm.DB, target AnnouncementTarget, now time.Time) ([]uint, error) {
+	db = db.WithContext(ctx)
+	enabled := db.Model(&User{}).Where("enabled = ? AND deleted_at IS NULL", true)
+	if target.All {
+		var ids []uint
+		err := enabled.Order("id").Pluck("id", &ids).Error
+		return ids, err
+	}
+
+	seen := make(map[uint]bool)
+	var ids []uint
+	add := func(list []uint) {
+		for _, id := range list {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(target.GroupIDs) > 0 {
+		var members []uint
+		if err := db.Model(&GroupMember{}).Where("group_id IN ?", target.GroupIDs).
+			Where("user_id IN (?)", enabled.Select("id")).Distinct().Pluck("user_id", &members).Error; err != nil {
+			return nil, err
+		}
+		add(members)
+	}
+	if len(target.UserIDs) > 0 {
+		var users []uint
+		if err := db.Model(&User{}).Where("enabled = ? AND deleted_at IS NULL", true).
+			Where("id IN ?", target.UserIDs).Pluck("id", &users).Error; err != nil {
+			return nil, err
+		}
+		add(users)
+	}
+	for _, s := range target.Segments {
+		var users []uint
+		if err := segmentQuery(db, s, now).Pluck("id", &users).Error; err != nil {
+			return nil, err
+		}
+		add(users)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// ClaimAnnouncement 将草稿或到期的排期公告标记为投递中，已被其他实例认领时返回 false
+func ClaimAnnouncement(db *gorm.DB, id uint) (bool, error) {
+	res := db.Model(&Announcement{}).
+		Where("id = ? AND status IN ?", id, []string{AnnouncementDraft, AnnouncementScheduled}).
+		Update("status", AnnouncementSending)
+	return res.RowsAffected > 0, res.Error
+}
+
+// DueAnnouncements 已到排期时间、尚未投递的公告 ID
+func DueAnnouncements(db *gorm.DB, now time.Time) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&Announcement{}).
+		Where("status = ? AND scheduled_at <= ?", AnnouncementScheduled, now.UTC()).
+		Order("scheduled_at").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// CreateAnnouncementReceipts 为一批接收人写入收到记录，已存在的记录保持不变，返回新写入的用户
+func CreateAnnouncementReceipts(db *gorm.DB, announcementID uint, userIDs []uint, now time.Time) ([]uint, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	var existing []uint
+	if err := db.Model(&AnnouncementReceipt{}).Where("announcement_id = ? AND user_id IN ?", announcementID, userIDs).
+		Pluck("user_id", &existing).Error; err != nil {
+		return nil, err
+	}
+	skip := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		skip[id] = true
+	}
+	receipts := make([]AnnouncementReceipt, 0, len(userIDs))
+	created := make([]uint, 0, len(userIDs))
+	for _, uid := range userIDs {
+		if skip[uid] {
+			continue
+		}
+		receipts = append(receipts, AnnouncementReceipt{AnnouncementID: announcementID, UserID: uid, DeliveredAt: now})
+		created = append(created, uid)
+	}
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(receipts, announcementBatchSize).Error; err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// FinishAnnouncement 记录投递完成
+func FinishAnnouncement(db *gorm.DB, id uint, recipients int, now time.Time) error {
+	return db.Model(&Announcement{}).Where("id = ?", id).Updates(map[string]any{
+		"status":     AnnouncementSent,
+		"sent_at":    now,
+		"recipients": recipients,
+	}).Error
+}
+
+// CancelAnnouncement 取消尚未投递的公告
+func CancelAnnouncement(db *gorm.DB, id uint) (bool, error) {
+	res := db.Model(&Announcement{}).
+		Where("id = ? AND status IN ?", id, []string{AnnouncementDraft, AnnouncementScheduled}).
+		Update("status", AnnouncementCancelled)
+	return res.RowsAffected > 0, res.Error
+}
+
+// MarkAnnouncementSeen 标记用户已查看公告，dismiss 为 true 时同时标记为已关闭；用户未收到该公告时返回 gorm.ErrRecordNotFound
+func MarkAnnouncementSeen(db *gorm.DB, announcementID, userID uint, dismiss bool, now time.Time) error {
+	var receipt AnnouncementReceipt
+	if err := db.Where("announcement_id = ? AND user_id = ?", announcementID, userID).First(&receipt).Error; err != nil {
+		return err
+	}
+	updates := map[string]any{}
+	if receipt.SeenAt == nil {
+		updates["seen_at"] = now
+	}
+	if dismiss && receipt.DismissedAt == nil {
+		updates["dismissed_at"] = now
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return db.Model(&AnnouncementReceipt{}).Where("id = ?", receipt.ID).Updates(updates).Error
+}
+
+// ActiveAnnouncementItem 用户尚未关闭的公告
+type ActiveAnnouncementItem struct {
+	Announcement
+	SeenAt *time.Time `json:"seenAt,omitempty"`
+}
+
+// ActiveAnnouncements 用户收到且尚未关闭的公告，新公告在前
+func ActiveAnnouncements(db *gorm.DB, userID uint, limit int) ([]ActiveAnnouncementItem, error) {
+	var receipts []AnnouncementReceipt
+	if err := db.Where("user_id = ? AND dismissed_at IS NULL", userID).
+		Order("delivered_at DESC").Limit(limit).Find(&receipts).Error; err != nil {
+		return nil, err
+	}
+	if len(receipts) == 0 {
+		return []ActiveAnnouncementItem{}, nil
+	}
+	ids := make([]uint, len(receipts))
+	for i, r := range receipts {
+		ids[i] = r.AnnouncementID
+	}
+	var list []Announcement
+	if err := db.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]Announcement, len(list))
+	for _, a := range list {
+		byID[a.ID] = a
+	}
+	items := make([]ActiveAnnouncementItem, 0, len(receipts))
+	for _, r := range receipts {
+		if a, ok := byID[r.AnnouncementID]; ok {
+			items = append(items, ActiveAnnouncementItem{Announcement: a, SeenAt: r.SeenAt})
+		}
+	}
+	return items, nil
+}
+
+// GetAnnouncementReach 公告的接收、查看与关闭人数
+func GetAnnouncementReach(db *gorm.DB, announcementID uint) (AnnouncementReach, error) {
+	reach := AnnouncementReach{AnnouncementID: announcementID}
+	q := func() *gorm.DB {
+		return db.Model(&AnnouncementReceipt{}).Where("announcement_id = ?", announcementID)
+	}
+	if err := q().Count(&reach.Recipients).Error; err != nil {
+		return reach, err
+	}
+	if err := q().Where("seen_at IS NOT NULL").Count(&reach.Seen).Error; err != nil {
+		return reach, err
+	}
+	if err := q().Where("dismissed_at IS NOT NULL").Count(&reach.Dismissed).Error; err != nil {
+		return reach, err
+	}
+	if reach.Recipients > 0 {
+		reach.SeenRate = float64(reach.Seen) / float64(reach.Recipients)
+	}
+	return reach, nil
+}
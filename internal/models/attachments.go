@@ -0,0 +1,104 @@
+package models
+
+import (
+	"HibiscusIM/pkg/scanner"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 文件扫描状态，只有 clean 状态的文件允许下载
+const (
+	ScanStatusPending     = "pending"
+	ScanStatusClean       = "clean"
+	ScanStatusQuarantined = "quarantined"
+	ScanStatusFailed      = "failed"
+)
+
+// 需要扫描的文件类型
+const (
+	ScanTargetAttachment = "attachment"
+	ScanTargetRecording  = "recording"
+)
+
+// SigFileQuarantined 文件扫描未通过被隔离 (kind string, id uint, userID uint, result scanner.Result)
+const SigFileQuarantined = "file.quarantined"
+
+// SigFileClean 文件扫描通过 (kind string, id uint)
+const SigFileClean = "file.clean"
+
+// ScanState 文件扫描状态，嵌入到附件与录音记录中
+type ScanState struct {
+	ScanStatus    string     `json:"scanStatus" gorm:"size:32;index;default:pending"`
+	ScanSignature string     `json:"scanSignature,omitempty" gorm:"size:256"`
+	ScanEngine    string     `json:"scanEngine,omitempty" gorm:"size:64"`
+	ScannedAt     *time.Time `json:"scannedAt,omitempty"`
+}
+
+// Downloadable 是否已通过扫描可以下载
+func (s ScanState) Downloadable() bool {
+	return s.ScanStatus == ScanStatusClean
+}
+
+// Attachment 用户上传的附件，Conversation 不为空时扫描通过后推送给会话成员
+type Attachment struct {
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	UserID       uint   `json:"userId" gorm:"index"`
+	Conversation string `json:"conversation,omitempty" gorm:"size:128;index"`
+	Name         string `json:"name" gorm:"size:256"`
+	ObjectKey    string `json:"-" gorm:"size:512"`
+	ThumbnailKey string `json:"-" gorm:"size:512"`
+	ContentType  string `json:"contentType" gorm:"size:128"`
+	SizeBytes    int64  `json:"sizeBytes"`
+	// 图片的原始尺寸
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// 对象存储的访问地址，不入库
+	URL          string `json:"url,omitempty" gorm:"-"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty" gorm:"-"`
+	ScanState
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// UpdateScanResult 将扫描结果写回对应记录，返回更新后的扫描状态
+func UpdateScanResult(db *gorm.DB, kind string, id uint, res scanner.Result) (ScanState, error) {
+	state := ScanState{
+		ScanStatus:    ScanStatusClean,
+		ScanSignature: res.Signature,
+		ScanEngine:    res.Engine,
+		ScannedAt:     &res.ScannedAt,
+	}
+	if !res.Clean() {
+		state.ScanStatus = ScanStatusQuarantined
+	}
+	return state, updateScanState(db, kind, id, state)
+}
+
+// MarkScanFailed 扫描多次失败后标记为失败，文件仍不可下载
+func MarkScanFailed(db *gorm.DB, kind string, id uint, reason string) error {
+	now := time.Now()
+	return updateScanState(db, kind, id, ScanState{
+		ScanStatus:    ScanStatusFailed,
+		ScanSignature: reason,
+		ScannedAt:     &now,
+	})
+}
+
+func updateScanState(db *gorm.DB, kind string, id uint, state ScanState) error {
+	var model any
+	switch kind {
+	case ScanTargetAttachment:
+		model = &Attachment{}
+	case ScanTargetRecording:
+		model = &Recording{}
+	default:
+		return gorm.ErrRecordNotFound
+	}
+	return db.Model(model).Where("id = ?", id).Updates(map[string]any{
+		"scan_status":    state.ScanStatus,
+		"scan_signature": state.ScanSignature,
+		"scan_engine":    state.ScanEngine,
+		"scanned_at":     state.ScannedAt,
+	}).Error
+}
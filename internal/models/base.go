@@ -57,6 +57,8 @@ type User struct {
 	AuthToken string `json:"token,omitempty" gorm:"-"`
 
 	Avatar       string `json:"avatar,omitempty"`
+	AvatarKey    string `json:"-" gorm:"size:512"` // 上传的头像在对象存储中的键
+	AvatarSize   int64  `json:"-"`
 	Gender       string `json:"gender,omitempty"`
 	City         string `json:"city,omitempty"`
 	Region       string `json:"region,omitempty"`
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 客户端批量上报的事件类型
+const (
+	ClientEventAnalytics = "analytics"
+	ClientEventRead      = "read"
+	ClientEventPresence  = "presence"
+)
+
+// ClientEvent 客户端（主要是移动端离线积攒后）上报的事件，同一用户的 EventID 只处理一次
+type ClientEvent struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	UserID  uint   `json:"userId" gorm:"uniqueIndex:,composite:user_event"`
+	EventID string `json:"eventId" gorm:"size:64;uniqueIndex:,composite:user_event"`
+	Type    string `json:"type" gorm:"size:16;index"`
+	// 统计事件名；已读回执为会话ID，在线状态为状态值
+	Name       string    `json:"name" gorm:"size:256;index"`
+	Properties string    `json:"properties,omitempty" gorm:"type:text"`
+	Platform   string    `json:"platform,omitempty" gorm:"size:32"`
+	OccurredAt time.Time `json:"occurredAt" gorm:"index"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index"`
+}
+
+// ClaimClientEvent 写入事件作为幂等记录，同一用户的 EventID 已存在时返回 false
+func ClaimClientEvent(ctx context.Context, db *gorm.DB, ev *ClientEvent) (bool, error) {
+	result := db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(ev)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseClientEvents 删除事件的幂等记录，事件处理失败时允许客户端重新上报
+func ReleaseClientEvents(ctx context.Context, db *gorm.DB, userID uint, eventIDs []string) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+	return db.WithContext(ctx).Where("user_id = ? AND event_id IN ?", userID, eventIDs).Delete(&ClientEvent{}).Error
+}
@@ -0,0 +1,344 @@
+package models
+
+import (
+	"HibiscusIM/pkg/cache"
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"time"
+
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConversationCursor 用户在会话中的已读游标
+type ConversationCursor struct {
+	ID                uint      `json:"-" gorm:"primaryKey"`
+	UserID            string    `json:"userId" gorm:"size:128;uniqueIndex:idx_conversation_cursor"`
+	Conversation      string    `json:"conversation" gorm:"size:256;uniqueIndex:idx_conversation_cursor"`
+	LastReadMessageID int64     `json:"lastReadMessageId"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// ConversationSequence 会话最新消息ID
+type ConversationSequence struct {
+	Conversation  string    `json:"conversation" gorm:"primaryKey;size:256"`
+	LastMessageID int64     `json:"lastMessageId"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// ConversationUnread 单个会话的未读统计
+type ConversationUnread struct {
+	Conversation      string `json:"conversation"`
+	Unread            int64  `json:"unread"`
+	LastMessageID     int64  `json:"lastMessageId"`
+	LastReadMessageID int64  `json:"lastReadMessageId"`
+}
+
+// ConversationSummary 会话列表项，LastActivityAt 为会话最新消息时间，没有消息时为加入时间
+type ConversationSummary struct {
+	Conversation      string    `json:"conversation"`
+	Unread            int64     `json:"unread"`
+	LastMessageID     int64     `json:"lastMessageId"`
+	LastReadMessageID int64     `json:"lastReadMessageId"`
+	LastActivityAt    time.Time `json:"lastActivityAt"`
+}
+
+// ConversationSetting 会话级设置
+type ConversationSetting struct {
+	Conversation string `json:"conversation" gorm:"primaryKey;size:256"`
+	// 新消息默认存活秒数（阅后即焚），0 表示不过期
+	MessageTTL int64     `json:"messageTtl"`
+	UpdatedBy  string    `json:"updatedBy" gorm:"size:128"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+const (
+	conversationSeqKeyPrefix = "conversation:seq:"
+	conversationTTLKeyPrefix = "conversation:ttl:"
+)
+
+// DirectPeerIDs 与用户有过私聊的其他用户ID，按最近活跃排序，最多返回 limit 个
+func DirectPeerIDs(db *gorm.DB, userID string, limit int) ([]string, error) {
+	var conversations []string
+	err := db.Model(&ConversationCursor{}).
+		Where("user_id = ? AND conversation LIKE ?", userID, websocket.ConversationDirectPrefix+"%").
+		Order("updated_at DESC").
+		Limit(limit).
+		Pluck("conversation", &conversations).Error
+	if err != nil {
+		return nil, err
+	}
+	peers := make([]string, 0, len(conversations))
+	for _, conversation := range conversations {
+		if _, peer, ok := websocket.ParseConversation(conversation, userID); ok && peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return peers, nil
+}
+
+// ConversationStore 维护会话消息序号与已读游标，最新消息ID同时写入缓存，未读统计不扫描消息表
+type ConversationStore struct {
+	db    *gorm.DB
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// NewConversationStore 创建会话存储，c 为 nil 时每次统计都回源数据库
+func NewConversationStore(db *gorm.DB, c cache.Cache, ttl time.Duration) *ConversationStore {
+	return &ConversationStore{db: db, cache: c, ttl: ttl}
+}
+
+func conversationSeqKey(conversation string) string {
+	return conversationSeqKeyPrefix + conversation
+}
+
+func conversationTTLKey(conversation string) string {
+	return conversationTTLKeyPrefix + conversation
+}
+
+// Settings 读取会话设置，未设置过时返回默认值
+func (s *ConversationStore) Settings(ctx context.Context, conversation string) (ConversationSetting, error) {
+	setting := ConversationSetting{Conversation: conversation}
+	err := s.db.WithContext(ctx).Where("conversation = ?", conversation).Limit(1).Find(&setting).Error
+	return setting, err
+}
+
+// SetMessageTTL 设置会话新消息的默认存活时间，ttl 为 0 时关闭
+func (s *ConversationStore) SetMessageTTL(ctx context.Context, conversation string, ttl time.Duration, userID string) (ConversationSetting, error) {
+	setting := ConversationSetting{
+		Conversation: conversation,
+		MessageTTL:   int64(ttl / time.Second),
+		UpdatedBy:    userID,
+		UpdatedAt:    time.Now(),
+	}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "conversation"}},
+		DoUpdates: clause.AssignmentColumns([]string{"message_ttl", "updated_by", "updated_at"}),
+	}).Create(&setting).Error; err != nil {
+		return setting, err
+	}
+	if s.cache != nil {
+		_ = s.cache.Set(ctx, conversationTTLKey(conversation), setting.MessageTTL, s.ttl)
+	}
+	return setting, nil
+}
+
+// MessageTTL 会话默认的消息存活时间，每条聊天消息都会调用，优先读缓存；读取失败时视为不过期
+func (s *ConversationStore) MessageTTL(conversation string) time.Duration {
+	ctx := context.Background()
+	if s.cache != nil {
+		if v, ok := s.cache.Get(ctx, conversationTTLKey(conversation)); ok {
+			if n, err := cast.ToInt64E(v); err == nil {
+				return time.Duration(n) * time.Second
+			}
+		}
+	}
+	setting, err := s.Settings(ctx, conversation)
+	if err != nil {
+		return 0
+	}
+	if s.cache != nil {
+		_ = s.cache.Set(ctx, conversationTTLKey(conversation), setting.MessageTTL, s.ttl)
+	}
+	return time.Duration(setting.MessageTTL) * time.Second
+}
+
+// OnMessage 分配下一条消息ID，推进发送者游标，私聊时为接收者创建游标
+func (s *ConversationStore) OnMessage(conversation, senderID, recipientID string) (int64, error) {
+	var seq int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&ConversationSequence{Conversation: conversation}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ConversationSequence{}).
+			Where("conversation = ?", conversation).
+			Update("last_message_id", gorm.Expr("last_message_id + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ConversationSequence{}).
+			Where("conversation = ?", conversation).
+			Pluck("last_message_id", &seq).Error; err != nil {
+			return err
+		}
+		if err := advanceCursor(tx, conversation, senderID, seq); err != nil {
+			return err
+		}
+		if recipientID != "" && recipientID != senderID {
+			return ensureCursor(tx, conversation, recipientID, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if s.cache != nil {
+		_ = s.cache.Set(context.Background(), conversationSeqKey(conversation), seq, s.ttl)
+	}
+	return seq, nil
+}
+
+// OnJoin 首次加入组会话时以当前最新消息ID初始化游标，已有游标保持不变
+func (s *ConversationStore) OnJoin(conversation, userID string) error {
+	var seq int64
+	if err := s.db.Model(&ConversationSequence{}).
+		Where("conversation = ?", conversation).
+		Pluck("last_message_id", &seq).Error; err != nil {
+		return err
+	}
+	return ensureCursor(s.db, conversation, userID, seq)
+}
+
+// MarkRead 推进已读游标，messageID 不超过会话最新消息ID
+func (s *ConversationStore) MarkRead(conversation, userID string, messageID int64) error {
+	var seq int64
+	if err := s.db.Model(&ConversationSequence{}).
+		Where("conversation = ?", conversation).
+		Pluck("last_message_id", &seq).Error; err != nil {
+		return err
+	}
+	if messageID > seq {
+		messageID = seq
+	}
+	if messageID <= 0 {
+		return nil
+	}
+	return advanceCursor(s.db, conversation, userID, messageID)
+}
+
+// UnreadCounts 返回用户各会话未读数及总数，最新消息ID优先读缓存，缺失时批量回源并回填
+func (s *ConversationStore) UnreadCounts(ctx context.Context, userID string) ([]ConversationUnread, int64, error) {
+	var cursors []ConversationCursor
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).
+		Order("conversation").Find(&cursors).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(cursors) == 0 {
+		return []ConversationUnread{}, 0, nil
+	}
+
+	seqs := make(map[string]int64, len(cursors))
+	var missing []string
+	if s.cache != nil {
+		keys := make([]string, 0, len(cursors))
+		for _, cur := range cursors {
+			keys = append(keys, conversationSeqKey(cur.Conversation))
+		}
+		cached := s.cache.GetMulti(ctx, keys...)
+		for _, cur := range cursors {
+			v, ok := cached[conversationSeqKey(cur.Conversation)]
+			if !ok {
+				missing = append(missing, cur.Conversation)
+				continue
+			}
+			n, err := cast.ToInt64E(v)
+			if err != nil {
+				missing = append(missing, cur.Conversation)
+				continue
+			}
+			seqs[cur.Conversation] = n
+		}
+	} else {
+		for _, cur := range cursors {
+			missing = append(missing, cur.Conversation)
+		}
+	}
+
+	if len(missing) > 0 {
+		var rows []ConversationSequence
+		if err := s.db.WithContext(ctx).Where("conversation IN ?", missing).Find(&rows).Error; err != nil {
+			return nil, 0, err
+		}
+		backfill := make(map[string]interface{}, len(rows))
+		for _, row := range rows {
+			seqs[row.Conversation] = row.LastMessageID
+			backfill[conversationSeqKey(row.Conversation)] = row.LastMessageID
+		}
+		if s.cache != nil && len(backfill) > 0 {
+			_ = s.cache.SetMulti(ctx, backfill, s.ttl)
+		}
+	}
+
+	items := make([]ConversationUnread, 0, len(cursors))
+	var total int64
+	for _, cur := range cursors {
+		last := seqs[cur.Conversation]
+		unread := last - cur.LastReadMessageID
+		if unread < 0 {
+			unread = 0
+		}
+		total += unread
+		items = append(items, ConversationUnread{
+			Conversation:      cur.Conversation,
+			Unread:            unread,
+			LastMessageID:     last,
+			LastReadMessageID: cur.LastReadMessageID,
+		})
+	}
+	return items, total, nil
+}
+
+// ListConversations 按最近活动倒序分页列出用户参与的会话及未读数
+func (s *ConversationStore) ListConversations(ctx context.Context, userID string, offset, limit int) ([]ConversationSummary, int64, error) {
+	var total int64
+	if err := s.db.WithContext(ctx).Model(&ConversationCursor{}).
+		Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []struct {
+		Conversation      string
+		LastReadMessageID int64
+		CursorUpdatedAt   time.Time
+		LastMessageID     *int64
+		SequenceUpdatedAt *time.Time
+	}
+	err := s.db.WithContext(ctx).Table("conversation_cursors AS c").
+		Select("c.conversation, c.last_read_message_id, c.updated_at AS cursor_updated_at, "+
+			"s.last_message_id, s.updated_at AS sequence_updated_at").
+		Joins("LEFT JOIN conversation_sequences AS s ON s.conversation = c.conversation").
+		Where("c.user_id = ?", userID).
+		Order("COALESCE(s.updated_at, c.updated_at) DESC").Order("c.conversation").
+		Offset(offset).Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	items := make([]ConversationSummary, 0, len(rows))
+	for _, row := range rows {
+		item := ConversationSummary{
+			Conversation:      row.Conversation,
+			LastReadMessageID: row.LastReadMessageID,
+			LastActivityAt:    row.CursorUpdatedAt,
+		}
+		if row.LastMessageID != nil {
+			item.LastMessageID = *row.LastMessageID
+		}
+		if row.SequenceUpdatedAt != nil {
+			item.LastActivityAt = *row.SequenceUpdatedAt
+		}
+		item.Unread = max(item.LastMessageID-item.LastReadMessageID, 0)
+		items = append(items, item)
+	}
+	return items, total, nil
+}
+
+// ensureCursor 游标不存在时创建
+func ensureCursor(db *gorm.DB, conversation, userID string, messageID int64) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&ConversationCursor{
+		UserID:            userID,
+		Conversation:      conversation,
+		LastReadMessageID: messageID,
+	}).Error
+}
+
+// advanceCursor 将游标推进到 messageID，不会回退
+func advanceCursor(db *gorm.DB, conversation, userID string, messageID int64) error {
+	if err := ensureCursor(db, conversation, userID, messageID); err != nil {
+		return err
+	}
+	return db.Model(&ConversationCursor{}).
+		Where("user_id = ? AND conversation = ? AND last_read_message_id < ?", userID, conversation, messageID).
+		Updates(map[string]interface{}{"last_read_message_id": messageID, "updated_at": time.Now()}).Error
+}
@@ -0,0 +1,380 @@
+package models
+
+import (
+	"HibiscusIM/pkg/util"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmailCodeTooFrequent   = errors.New("verification code requested too frequently, please retry later")
+	ErrEmailCodeEmailLimit    = errors.New("too many verification codes sent to this email")
+	ErrEmailCodeIPLimit       = errors.New("too many verification code requests from this ip")
+	ErrEmailCodeBlocked       = errors.New("this email has been reported and is temporarily blocked")
+	ErrEmailCodeInvalid       = errors.New("invalid verification code")
+	ErrEmailCodeExpired       = errors.New("verification code expired")
+	ErrEmailCodeTooManyTrials = errors.New("too many failed attempts, please request a new code")
+	ErrEmailReportInvalid     = errors.New("invalid or expired report token")
+)
+
+// 验证码发放/校验结果，用于指标标签
+const (
+	emailCodeResultOK        = "ok"
+	emailCodeResultThrottled = "throttled"
+	emailCodeResultBlocked   = "blocked"
+	emailCodeResultInvalid   = "invalid"
+	emailCodeResultExpired   = "expired"
+	emailCodeResultExhausted = "exhausted"
+)
+
+var (
+	emailCodeIssued = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "email_code_issued_total",
+			Help: "Total number of email verification code issuance attempts",
+		},
+		[]string{"result"},
+	)
+	emailCodeVerified = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "email_code_verified_total",
+			Help: "Total number of email verification code validations",
+		},
+		[]string{"result"},
+	)
+	emailCodeAbuseReports = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "email_code_abuse_reports_total",
+			Help: "Total number of email verification abuse reports",
+		},
+	)
+)
+
+// EmailCodeLimits 邮箱验证码发放与校验限制
+type EmailCodeLimits struct {
+	// 验证码有效期
+	TTL time.Duration
+	// 单个验证码允许的最大错误次数
+	MaxAttempts int
+	// 同一邮箱两次发放的最小间隔
+	Cooldown time.Duration
+	// 同一邮箱每小时最多发放次数
+	PerEmailHourly int
+	// 同一IP每小时最多发放次数
+	PerIPHourly int
+	// 被举报后禁止发放的时长
+	BlockDuration time.Duration
+	// 验证码邮件中举报令牌的有效期，每个令牌只能使用一次
+	ReportTTL time.Duration
+}
+
+// LoadEmailCodeLimits 从环境变量加载验证码限制，未配置时使用默认值
+func LoadEmailCodeLimits() EmailCodeLimits {
+	limits := EmailCodeLimits{
+		TTL:            5 * time.Minute,
+		MaxAttempts:    5,
+		Cooldown:       time.Minute,
+		PerEmailHourly: 5,
+		PerIPHourly:    20,
+		BlockDuration:  24 * time.Hour,
+		ReportTTL:      24 * time.Hour,
+	}
+	if v := util.GetIntEnv("EMAIL_CODE_TTL_SECONDS"); v > 0 {
+		limits.TTL = time.Duration(v) * time.Second
+	}
+	if v := util.GetIntEnv("EMAIL_CODE_MAX_ATTEMPTS"); v > 0 {
+		limits.MaxAttempts = int(v)
+	}
+	if v := util.GetIntEnv("EMAIL_CODE_COOLDOWN_SECONDS"); v > 0 {
+		limits.Cooldown = time.Duration(v) * time.Second
+	}
+	if v := util.GetIntEnv("EMAIL_CODE_PER_EMAIL_HOURLY"); v > 0 {
+		limits.PerEmailHourly = int(v)
+	}
+	if v := util.GetIntEnv("EMAIL_CODE_PER_IP_HOURLY"); v > 0 {
+		limits.PerIPHourly = int(v)
+	}
+	return limits
+}
+
+// EmailAbuseReport 邮箱验证码滥用举报记录
+type EmailAbuseReport struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Email        string    `json:"email" gorm:"size:128;index"`
+	Reason       string    `json:"reason" gorm:"size:500"`
+	ReporterIP   string    `json:"reporterIp" gorm:"size:64"`
+	RequesterIPs string    `json:"requesterIps" gorm:"size:500"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"index"`
+}
+
+type emailCode struct {
+	code      string
+	expiresAt time.Time
+	attempts  int
+}
+
+// emailReportToken 随验证码邮件下发的一次性举报令牌，只有收到邮件的人才能举报
+type emailReportToken struct {
+	email     string
+	expiresAt time.Time
+}
+
+// EmailCodeIssuer 邮箱验证码发放器，按邮箱与IP限流并限制校验次数
+type EmailCodeIssuer struct {
+	limits EmailCodeLimits
+	mu     sync.Mutex
+	codes  map[string]*emailCode
+	// 最近一小时的发放记录
+	emailHits map[string][]time.Time
+	ipHits    map[string][]time.Time
+	// 最近请求该邮箱验证码的IP，用于举报溯源
+	requesters map[string][]string
+	blocked    map[string]time.Time
+	// 举报令牌 -> 邮箱，数量受发放限流约束
+	reportTokens map[string]emailReportToken
+	lastPrune    time.Time
+	now          func() time.Time
+}
+
+// NewEmailCodeIssuer 创建邮箱验证码发放器
+func NewEmailCodeIssuer(limits EmailCodeLimits) *EmailCodeIssuer {
+	return &EmailCodeIssuer{
+		limits:       limits,
+		codes:        make(map[string]*emailCode),
+		emailHits:    make(map[string][]time.Time),
+		ipHits:       make(map[string][]time.Time),
+		requesters:   make(map[string][]string),
+		blocked:      make(map[string]time.Time),
+		reportTokens: make(map[string]emailReportToken),
+		now:          time.Now,
+	}
+}
+
+// TTL 验证码有效期
+func (i *EmailCodeIssuer) TTL() time.Duration {
+	return i.limits.TTL
+}
+
+// recentHits 清理一小时前的记录并返回剩余记录
+func recentHits(hits []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-time.Hour)
+	n := 0
+	for _, t := range hits {
+		if t.After(cutoff) {
+			hits[n] = t
+			n++
+		}
+	}
+	return hits[:n]
+}
+
+// newEmailReportToken 生成不可预测的举报令牌
+func newEmailReportToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Issue 为邮箱生成新验证码与一次性举报令牌，超出限制时返回错误
+func (i *EmailCodeIssuer) Issue(email, ip string) (code, reportToken string, err error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	if now.Sub(i.lastPrune) > 10*time.Minute {
+		i.prune(now)
+	}
+	if until, ok := i.blocked[email]; ok {
+		if now.Before(until) {
+			emailCodeIssued.WithLabelValues(emailCodeResultBlocked).Inc()
+			return "", "", ErrEmailCodeBlocked
+		}
+		delete(i.blocked, email)
+	}
+
+	emailHits := recentHits(i.emailHits[email], now)
+	ipHits := recentHits(i.ipHits[ip], now)
+	i.emailHits[email], i.ipHits[ip] = emailHits, ipHits
+
+	switch {
+	case len(emailHits) > 0 && now.Sub(emailHits[len(emailHits)-1]) < i.limits.Cooldown:
+		emailCodeIssued.WithLabelValues(emailCodeResultThrottled).Inc()
+		return "", "", ErrEmailCodeTooFrequent
+	case i.limits.PerEmailHourly > 0 && len(emailHits) >= i.limits.PerEmailHourly:
+		emailCodeIssued.WithLabelValues(emailCodeResultThrottled).Inc()
+		return "", "", ErrEmailCodeEmailLimit
+	case i.limits.PerIPHourly > 0 && len(ipHits) >= i.limits.PerIPHourly:
+		emailCodeIssued.WithLabelValues(emailCodeResultThrottled).Inc()
+		return "", "", ErrEmailCodeIPLimit
+	}
+
+	code = util.RandNumberText(6)
+	reportToken = newEmailReportToken()
+	i.codes[email] = &emailCode{code: code, expiresAt: now.Add(i.limits.TTL)}
+	i.reportTokens[reportToken] = emailReportToken{email: email, expiresAt: now.Add(i.limits.ReportTTL)}
+	i.emailHits[email] = append(emailHits, now)
+	i.ipHits[ip] = append(ipHits, now)
+	i.addRequester(email, ip)
+	emailCodeIssued.WithLabelValues(emailCodeResultOK).Inc()
+	return code, reportToken, nil
+}
+
+// prune 清理过期的验证码与限流记录，调用方需持有锁
+func (i *EmailCodeIssuer) prune(now time.Time) {
+	i.lastPrune = now
+	for email, c := range i.codes {
+		if now.After(c.expiresAt) {
+			delete(i.codes, email)
+		}
+	}
+	for email, hits := range i.emailHits {
+		if len(recentHits(hits, now)) == 0 {
+			delete(i.emailHits, email)
+			delete(i.requesters, email)
+		}
+	}
+	for ip, hits := range i.ipHits {
+		if len(recentHits(hits, now)) == 0 {
+			delete(i.ipHits, ip)
+		}
+	}
+	for email, until := range i.blocked {
+		if !now.Before(until) {
+			delete(i.blocked, email)
+		}
+	}
+	for token, t := range i.reportTokens {
+		if !now.Before(t.expiresAt) {
+			delete(i.reportTokens, token)
+		}
+	}
+}
+
+// addRequester 记录请求IP，最多保留10个，调用方需持有锁
+func (i *EmailCodeIssuer) addRequester(email, ip string) {
+	ips := i.requesters[email]
+	for _, v := range ips {
+		if v == ip {
+			return
+		}
+	}
+	ips = append(ips, ip)
+	if len(ips) > 10 {
+		ips = ips[len(ips)-10:]
+	}
+	i.requesters[email] = ips
+}
+
+// Verify 校验验证码，成功后验证码立即失效
+func (i *EmailCodeIssuer) Verify(email, code string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	c, ok := i.codes[email]
+	if !ok {
+		emailCodeVerified.WithLabelValues(emailCodeResultInvalid).Inc()
+		return ErrEmailCodeInvalid
+	}
+	if i.now().After(c.expiresAt) {
+		delete(i.codes, email)
+		emailCodeVerified.WithLabelValues(emailCodeResultExpired).Inc()
+		return ErrEmailCodeExpired
+	}
+	if subtle.ConstantTimeCompare([]byte(c.code), []byte(code)) != 1 {
+		c.attempts++
+		if c.attempts >= i.limits.MaxAttempts {
+			delete(i.codes, email)
+			emailCodeVerified.WithLabelValues(emailCodeResultExhausted).Inc()
+			return ErrEmailCodeTooManyTrials
+		}
+		emailCodeVerified.WithLabelValues(emailCodeResultInvalid).Inc()
+		return ErrEmailCodeInvalid
+	}
+	delete(i.codes, email)
+	emailCodeVerified.WithLabelValues(emailCodeResultOK).Inc()
+	return nil
+}
+
+// Report 处理滥用举报：校验并消费验证码邮件中的一次性举报令牌，作废当前验证码、
+// 临时禁止向该邮箱发放并记录请求来源；令牌无效、过期或已使用时返回 ErrEmailReportInvalid
+func (i *EmailCodeIssuer) Report(db *gorm.DB, email, token, reason, reporterIP string) (*EmailAbuseReport, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	i.mu.Lock()
+	t, ok := i.reportTokens[token]
+	if !ok || t.email != email || !i.now().Before(t.expiresAt) {
+		i.mu.Unlock()
+		return nil, ErrEmailReportInvalid
+	}
+	// 同一邮箱的令牌全部作废，一次举报只记录一条
+	for k, v := range i.reportTokens {
+		if v.email == email {
+			delete(i.reportTokens, k)
+		}
+	}
+	delete(i.codes, email)
+	i.blocked[email] = i.now().Add(i.limits.BlockDuration)
+	requesters := append([]string(nil), i.requesters[email]...)
+	i.mu.Unlock()
+
+	emailCodeAbuseReports.Inc()
+	report := &EmailAbuseReport{
+		Email:        email,
+		Reason:       reason,
+		ReporterIP:   reporterIP,
+		RequesterIPs: strings.Join(requesters, ","),
+	}
+	if err := db.Create(report).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// AbuseStats 当前限流状态概要：被禁止的邮箱以及最近一小时请求最多的IP
+func (i *EmailCodeIssuer) AbuseStats(top int) map[string]any {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	type ipCount struct {
+		IP    string `json:"ip"`
+		Count int    `json:"count"`
+	}
+	ips := make([]ipCount, 0, len(i.ipHits))
+	for ip, hits := range i.ipHits {
+		hits = recentHits(hits, now)
+		i.ipHits[ip] = hits
+		if len(hits) > 0 {
+			ips = append(ips, ipCount{IP: ip, Count: len(hits)})
+		}
+	}
+	sort.Slice(ips, func(a, b int) bool { return ips[a].Count > ips[b].Count })
+	if top > 0 && len(ips) > top {
+		ips = ips[:top]
+	}
+	blocked := make(map[string]time.Time)
+	for email, until := range i.blocked {
+		if now.Before(until) {
+			blocked[email] = until
+		}
+	}
+	return map[string]any{"topIps": ips, "blocked": blocked}
+}
+
+// ListEmailAbuseReports 分页获取滥用举报记录
+func ListEmailAbuseReports(db *gorm.DB, pos, limit int) ([]EmailAbuseReport, error) {
+	var reports []EmailAbuseReport
+	err := db.Order("id DESC").Offset(pos).Limit(limit).Find(&reports).Error
+	return reports, err
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newEmailReportTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&EmailAbuseReport{}))
+	return db
+}
+
+func TestEmailCodeReportRequiresToken(t *testing.T) {
+	db := newEmailReportTestDB(t)
+	issuer := NewEmailCodeIssuer(EmailCodeLimits{TTL: time.Minute, MaxAttempts: 3, BlockDuration: time.Hour, ReportTTL: time.Hour})
+
+	_, token, err := issuer.Issue("Alice@Example.com", "10.0.0.1")
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	_, other, err := issuer.Issue("bob@example.com", "10.0.0.2")
+	require.NoError(t, err)
+
+	// 没有令牌、令牌不匹配邮箱时不封禁也不记录
+	_, err = issuer.Report(db, "alice@example.com", "", "spam", "10.0.0.9")
+	assert.ErrorIs(t, err, ErrEmailReportInvalid)
+	_, err = issuer.Report(db, "alice@example.com", other, "spam", "10.0.0.9")
+	assert.ErrorIs(t, err, ErrEmailReportInvalid)
+	_, _, err = issuer.Issue("alice@example.com", "10.0.0.1")
+	require.NoError(t, err)
+
+	report, err := issuer.Report(db, "alice@example.com", token, "spam", "10.0.0.9")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", report.Email)
+	assert.Equal(t, "10.0.0.1", report.RequesterIPs)
+	_, _, err = issuer.Issue("alice@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrEmailCodeBlocked)
+
+	// 令牌只能使用一次，同一邮箱的其他令牌一并作废
+	_, err = issuer.Report(db, "alice@example.com", token, "spam", "10.0.0.9")
+	assert.ErrorIs(t, err, ErrEmailReportInvalid)
+	var count int64
+	require.NoError(t, db.Model(&EmailAbuseReport{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestEmailCodeReportTokenExpires(t *testing.T) {
+	db := newEmailReportTestDB(t)
+	issuer := NewEmailCodeIssuer(EmailCodeLimits{TTL: time.Minute, MaxAttempts: 3, BlockDuration: time.Hour, ReportTTL: time.Hour})
+	now := time.Now()
+	issuer.now = func() time.Time { return now }
+
+	_, token, err := issuer.Issue("carol@example.com", "10.0.0.3")
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Hour)
+	_, err = issuer.Report(db, "carol@example.com", token, "", "10.0.0.9")
+	assert.ErrorIs(t, err, ErrEmailReportInvalid)
+	_, _, err = issuer.Issue("carol@example.com", "10.0.0.3")
+	assert.NoError(t, err)
+}
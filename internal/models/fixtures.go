@@ -0,0 +1,317 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFixturesNotAllowed 当前运行环境不允许加载种子数据
+var ErrFixturesNotAllowed = errors.New("fixtures are not allowed in this environment")
+
+// Fixtures 声明式种子数据，按自然键（邮箱、组名、问卷标题、录音句序号）判断是否已存在，
+// 已存在的记录保持不变，重复加载不会产生重复数据
+type Fixtures struct {
+	// Environments 非空时只允许在列出的环境中加载
+	Environments     []string                 `json:"environments,omitempty" yaml:"environments"`
+	Users            []FixtureUser            `json:"users,omitempty" yaml:"users"`
+	Groups           []FixtureGroup           `json:"groups,omitempty" yaml:"groups"`
+	Questionnaires   []FixtureQuestionnaire   `json:"questionnaires,omitempty" yaml:"questionnaires"`
+	RecordingPrompts []FixtureRecordingPrompt `json:"recordingPrompts,omitempty" yaml:"recordingPrompts"`
+}
+
+// FixtureUser 种子用户，密码为明文，写入时加密
+type FixtureUser struct {
+	Email       string `json:"email" yaml:"email"`
+	Password    string `json:"password" yaml:"password"`
+	DisplayName string `json:"displayName,omitempty" yaml:"displayName"`
+	FirstName   string `json:"firstName,omitempty" yaml:"firstName"`
+	LastName    string `json:"lastName,omitempty" yaml:"lastName"`
+	Phone       string `json:"phone,omitempty" yaml:"phone"`
+	Locale      string `json:"locale,omitempty" yaml:"locale"`
+	Timezone    string `json:"timezone,omitempty" yaml:"timezone"`
+	IsStaff     bool   `json:"isStaff,omitempty" yaml:"isStaff"`
+	IsSuperUser bool   `json:"isSuperUser,omitempty" yaml:"isSuperUser"`
+}
+
+// FixtureGroup 种子组及成员，成员按邮箱引用用户
+type FixtureGroup struct {
+	Name    string               `json:"name" yaml:"name"`
+	Type    string               `json:"type,omitempty" yaml:"type"`
+	Extra   string               `json:"extra,omitempty" yaml:"extra"`
+	Members []FixtureGroupMember `json:"members,omitempty" yaml:"members"`
+}
+
+// FixtureGroupMember 组成员，Role 为空时为普通成员
+type FixtureGroupMember struct {
+	Email string `json:"email" yaml:"email"`
+	Role  string `json:"role,omitempty" yaml:"role"`
+}
+
+// FixtureQuestionnaire 种子问卷及问题
+type FixtureQuestionnaire struct {
+	Title       string            `json:"title" yaml:"title"`
+	Description string            `json:"description,omitempty" yaml:"description"`
+	Questions   []FixtureQuestion `json:"questions,omitempty" yaml:"questions"`
+}
+
+// FixtureQuestion 问卷问题
+type FixtureQuestion struct {
+	Text string `json:"text" yaml:"text"`
+	Type string `json:"type,omitempty" yaml:"type"`
+}
+
+// FixtureRecordingPrompt 录音句，Order 从 1 开始
+type FixtureRecordingPrompt struct {
+	Order int    `json:"order" yaml:"order"`
+	Text  string `json:"text" yaml:"text"`
+}
+
+// FixtureResult 加载结果，按类型统计新建与跳过（已存在）的记录数
+type FixtureResult struct {
+	Created map[string]int `json:"created"`
+	Skipped map[string]int `json:"skipped"`
+}
+
+func (r *FixtureResult) count(kind string, created bool) {
+	if created {
+		r.Created[kind]++
+	} else {
+		r.Skipped[kind]++
+	}
+}
+
+// FixturesAllowed 判断运行环境是否允许加载种子数据，allowed 为逗号分隔的环境列表
+func FixturesAllowed(env, allowed string) bool {
+	for _, e := range strings.Split(allowed, ",") {
+		if strings.EqualFold(strings.TrimSpace(e), env) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFixtures 读取种子数据文件（.yaml/.yml/.json），path 为目录时按文件名顺序读取其中所有种子文件
+func ReadFixtures(path string) ([]*Fixtures, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if !entry.IsDir() && isFixtureFile(entry.Name()) {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	var result []*Fixtures
+	for _, file := range files {
+		fx, err := readFixtureFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		result = append(result, fx)
+	}
+	return result, nil
+}
+
+func isFixtureFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func readFixtureFile(file string) (*Fixtures, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var fx Fixtures
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&fx)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&fx)
+	default:
+		return nil, fmt.Errorf("unsupported fixture format %q", filepath.Ext(file))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fx, fx.Validate()
+}
+
+// Validate 校验必填字段，组成员引用的用户可以来自其他文件或已有数据，写入时再检查
+func (fx *Fixtures) Validate() error {
+	for i, u := range fx.Users {
+		if u.Email == "" || u.Password == "" {
+			return fmt.Errorf("users[%d]: email and password are required", i)
+		}
+	}
+	for i, g := range fx.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("groups[%d]: name is required", i)
+		}
+		for j, m := range g.Members {
+			if m.Email == "" {
+				return fmt.Errorf("groups[%d].members[%d]: email is required", i, j)
+			}
+			if m.Role != "" && m.Role != GroupRoleAdmin && m.Role != GroupRoleMember {
+				return fmt.Errorf("groups[%d].members[%d]: role must be %s or %s", i, j, GroupRoleAdmin, GroupRoleMember)
+			}
+		}
+	}
+	for i, q := range fx.Questionnaires {
+		if q.Title == "" {
+			return fmt.Errorf("questionnaires[%d]: title is required", i)
+		}
+		for j, question := range q.Questions {
+			if question.Text == "" {
+				return fmt.Errorf("questionnaires[%d].questions[%d]: text is required", i, j)
+			}
+		}
+	}
+	for i, p := range fx.RecordingPrompts {
+		if p.Order <= 0 || p.Text == "" {
+			return fmt.Errorf("recordingPrompts[%d]: order and text are required", i)
+		}
+	}
+	return nil
+}
+
+// LoadFixtures 在一个事务中写入种子数据，任一记录失败时全部回滚；
+// 声明了 Environments 且不包含 env 的文件返回 ErrFixturesNotAllowed
+func LoadFixtures(db *gorm.DB, env string, fixtures ...*Fixtures) (FixtureResult, error) {
+	result := FixtureResult{Created: map[string]int{}, Skipped: map[string]int{}}
+	for _, fx := range fixtures {
+		if len(fx.Environments) > 0 && !slices.Contains(fx.Environments, env) {
+			return result, fmt.Errorf("%w: %s", ErrFixturesNotAllowed, env)
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, fx := range fixtures {
+			if err := fx.apply(tx, &result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return FixtureResult{Created: map[string]int{}, Skipped: map[string]int{}}, err
+	}
+	return result, nil
+}
+
+func (fx *Fixtures) apply(tx *gorm.DB, result *FixtureResult) error {
+	for _, u := range fx.Users {
+		created, err := firstOrCreate(tx, &User{}, &User{
+			Email:       u.Email,
+			Password:    HashPassword(u.Password),
+			DisplayName: u.DisplayName,
+			FirstName:   u.FirstName,
+			LastName:    u.LastName,
+			Phone:       u.Phone,
+			Locale:      u.Locale,
+			Timezone:    u.Timezone,
+			IsStaff:     u.IsStaff || u.IsSuperUser,
+			IsSuperUser: u.IsSuperUser,
+			Enabled:     true,
+			Activated:   true,
+			Source:      "fixtures",
+		}, "email = ?", u.Email)
+		if err != nil {
+			return fmt.Errorf("user %s: %w", u.Email, err)
+		}
+		result.count("users", created)
+	}
+
+	for _, g := range fx.Groups {
+		var group Group
+		created, err := firstOrCreate(tx, &group, &Group{Name: g.Name, Type: g.Type, Extra: g.Extra}, "name = ? AND type = ?", g.Name, g.Type)
+		if err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+		result.count("groups", created)
+		for _, m := range g.Members {
+			var user User
+			if err := tx.Where("email = ?", m.Email).First(&user).Error; err != nil {
+				return fmt.Errorf("group %s member %s: %w", g.Name, m.Email, err)
+			}
+			role := m.Role
+			if role == "" {
+				role = GroupRoleMember
+			}
+			created, err := firstOrCreate(tx, &GroupMember{}, &GroupMember{GroupID: group.ID, UserID: user.ID, Role: role}, "group_id = ? AND user_id = ?", group.ID, user.ID)
+			if err != nil {
+				return fmt.Errorf("group %s member %s: %w", g.Name, m.Email, err)
+			}
+			result.count("groupMembers", created)
+		}
+	}
+
+	for _, q := range fx.Questionnaires {
+		var questionnaire Questionnaire
+		created, err := firstOrCreate(tx, &questionnaire, &Questionnaire{Title: q.Title, Description: q.Description}, "title = ?", q.Title)
+		if err != nil {
+			return fmt.Errorf("questionnaire %s: %w", q.Title, err)
+		}
+		result.count("questionnaires", created)
+		for _, question := range q.Questions {
+			created, err := firstOrCreate(tx, &Question{}, &Question{QuestionnaireID: questionnaire.ID, Text: question.Text, Type: question.Type}, "questionnaire_id = ? AND text = ?", questionnaire.ID, question.Text)
+			if err != nil {
+				return fmt.Errorf("questionnaire %s question: %w", q.Title, err)
+			}
+			result.count("questions", created)
+		}
+	}
+
+	for _, p := range fx.RecordingPrompts {
+		created, err := firstOrCreate(tx, &RecordingPrompt{}, &RecordingPrompt{Order: p.Order, Text: p.Text},
+			clause.Eq{Column: clause.Column{Name: "order"}, Value: p.Order})
+		if err != nil {
+			return fmt.Errorf("recording prompt %d: %w", p.Order, err)
+		}
+		result.count("recordingPrompts", created)
+	}
+	return nil
+}
+
+// firstOrCreate 按条件查找记录写入 dst，不存在时创建 record 并复制到 dst，返回是否新建
+func firstOrCreate[T any](tx *gorm.DB, dst *T, record *T, query any, args ...any) (bool, error) {
+	err := tx.Where(query, args...).First(dst).Error
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return false, err
+	}
+	*dst = *record
+	return true, nil
+}
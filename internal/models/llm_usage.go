@@ -0,0 +1,284 @@
+package models
+
+import (
+	"HibiscusIM/pkg/llm"
+	"HibiscusIM/pkg/logger"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LLMUsageEvent 单次 LLM 调用记录
+type LLMUsageEvent struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	UserID           string    `json:"userId" gorm:"size:128;index"`
+	Provider         string    `json:"provider" gorm:"size:32"`
+	Model            string    `json:"model" gorm:"size:128;index"`
+	Stream           bool      `json:"stream"`
+	LatencyMs        int64     `json:"latencyMs"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	TotalTokens      int64     `json:"totalTokens"`
+	Outcome          string    `json:"outcome" gorm:"size:16"`
+	Error            string    `json:"error" gorm:"size:512"`
+	CreatedAt        time.Time `json:"createdAt" gorm:"index"`
+}
+
+// LLMUsageDaily 按天、用户、模型聚合的 LLM 用量
+type LLMUsageDaily struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Day              string    `json:"day" gorm:"size:10;uniqueIndex:idx_llm_usage_daily"`
+	UserID           string    `json:"userId" gorm:"size:128;uniqueIndex:idx_llm_usage_daily"`
+	Provider         string    `json:"provider" gorm:"size:32;uniqueIndex:idx_llm_usage_daily"`
+	Model            string    `json:"model" gorm:"size:128;uniqueIndex:idx_llm_usage_daily"`
+	Calls            int64     `json:"calls"`
+	Errors           int64     `json:"errors"`
+	PromptTokens     int64     `json:"promptTokens"`
+	CompletionTokens int64     `json:"completionTokens"`
+	TotalTokens      int64     `json:"totalTokens"`
+	LatencyMs        int64     `json:"latencyMs"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// LLMUsageReportRow 用量报表行
+type LLMUsageReportRow struct {
+	Key              string  `json:"key"`
+	Calls            int64   `json:"calls"`
+	Errors           int64   `json:"errors"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	TotalTokens      int64   `json:"totalTokens"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"`
+}
+
+// 报表分组维度
+const (
+	LLMUsageGroupByDay   = "day"
+	LLMUsageGroupByUser  = "user"
+	LLMUsageGroupByModel = "model"
+)
+
+var llmUsageGroupColumns = map[string]string{
+	LLMUsageGroupByDay:   "day",
+	LLMUsageGroupByUser:  "user_id",
+	LLMUsageGroupByModel: "model",
+}
+
+const llmUsageDayLayout = "2006-01-02"
+
+// LLMUsageStore 异步持久化 LLM 用量事件并累加到日报表，实现 llm.UsageRecorder
+type LLMUsageStore struct {
+	db        *gorm.DB
+	events    chan llm.UsageEvent
+	batchSize int
+	interval  time.Duration
+	dropped   atomic.Int64
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+// NewLLMUsageStore 创建用量存储，queueSize 为待写入事件上限，满时丢弃并计数
+func NewLLMUsageStore(db *gorm.DB, queueSize int) *LLMUsageStore {
+	if queueSize <= 0 {
+		queueSize = 10000
+	}
+	return &LLMUsageStore{
+		db:        db,
+		events:    make(chan llm.UsageEvent, queueSize),
+		batchSize: 200,
+		interval:  time.Second,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// RecordUsage 入队用量事件，不阻塞调用方
+func (s *LLMUsageStore) RecordUsage(event llm.UsageEvent) {
+	select {
+	case s.events <- event:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dropped 因队列已满丢弃的事件数
+func (s *LLMUsageStore) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Start 启动后台写入
+func (s *LLMUsageStore) Start() {
+	go s.run()
+}
+
+// Close 停止后台写入并落盘剩余事件
+func (s *LLMUsageStore) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		<-s.done
+	})
+}
+
+func (s *LLMUsageStore) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	batch := make([]llm.UsageEvent, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.Save(context.Background(), batch); err != nil {
+			logger.Warn("save llm usage failed", zap.Int("events", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case ev := <-s.events:
+			batch = append(batch, ev)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stop:
+			for {
+				select {
+				case ev := <-s.events:
+					batch = append(batch, ev)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Save 写入事件明细并在同一事务中累加日报表
+func (s *LLMUsageStore) Save(ctx context.Context, events []llm.UsageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	rows := make([]LLMUsageEvent, 0, len(events))
+	daily := make(map[[4]string]*LLMUsageDaily)
+	for _, ev := range events {
+		row := LLMUsageEvent{
+			UserID:           ev.UserID,
+			Provider:         ev.Provider,
+			Model:            ev.Model,
+			Stream:           ev.Stream,
+			LatencyMs:        ev.Latency.Milliseconds(),
+			PromptTokens:     int64(ev.PromptTokens),
+			CompletionTokens: int64(ev.CompletionTokens),
+			TotalTokens:      int64(ev.TotalTokens),
+			Outcome:          ev.Outcome,
+			Error:            truncate(ev.Error, 512),
+			CreatedAt:        ev.Time,
+		}
+		rows = append(rows, row)
+
+		key := [4]string{ev.Time.Format(llmUsageDayLayout), ev.UserID, ev.Provider, ev.Model}
+		d, ok := daily[key]
+		if !ok {
+			d = &LLMUsageDaily{Day: key[0], UserID: key[1], Provider: key[2], Model: key[3]}
+			daily[key] = d
+		}
+		d.Calls++
+		if ev.Outcome == llm.UsageOutcomeError {
+			d.Errors++
+		}
+		d.PromptTokens += row.PromptTokens
+		d.CompletionTokens += row.CompletionTokens
+		d.TotalTokens += row.TotalTokens
+		d.LatencyMs += row.LatencyMs
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(rows, 200).Error; err != nil {
+			return err
+		}
+		table := tx.NamingStrategy.TableName("LLMUsageDaily")
+		for _, d := range daily {
+			d.UpdatedAt = time.Now()
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "day"}, {Name: "user_id"}, {Name: "provider"}, {Name: "model"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"calls":             gorm.Expr(table+".calls + ?", d.Calls),
+					"errors":            gorm.Expr(table+".errors + ?", d.Errors),
+					"prompt_tokens":     gorm.Expr(table+".prompt_tokens + ?", d.PromptTokens),
+					"completion_tokens": gorm.Expr(table+".completion_tokens + ?", d.CompletionTokens),
+					"total_tokens":      gorm.Expr(table+".total_tokens + ?", d.TotalTokens),
+					"latency_ms":        gorm.Expr(table+".latency_ms + ?", d.LatencyMs),
+					"updated_at":        d.UpdatedAt,
+				}),
+			}).Create(d).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LLMUsageReport 按 groupBy 汇总 [from, to] 日期范围内的用量，userID/model 非空时过滤
+func LLMUsageReport(db *gorm.DB, groupBy string, from, to time.Time, userID, model string) ([]LLMUsageReportRow, error) {
+	col, ok := llmUsageGroupColumns[groupBy]
+	if !ok {
+		col = llmUsageGroupColumns[LLMUsageGroupByDay]
+	}
+	tx := db.Model(&LLMUsageDaily{}).
+		Select(col+" AS `key`, SUM(calls) AS calls, SUM(errors) AS errors, SUM(prompt_tokens) AS prompt_tokens, "+
+			"SUM(completion_tokens) AS completion_tokens, SUM(total_tokens) AS total_tokens, SUM(latency_ms) AS latency_ms").
+		Where("day >= ? AND day <= ?", from.Format(llmUsageDayLayout), to.Format(llmUsageDayLayout))
+	if userID != "" {
+		tx = tx.Where("user_id = ?", userID)
+	}
+	if model != "" {
+		tx = tx.Where("model = ?", model)
+	}
+
+	var rows []struct {
+		Key              string
+		Calls            int64
+		Errors           int64
+		PromptTokens     int64
+		CompletionTokens int64
+		TotalTokens      int64
+		LatencyMs        int64
+	}
+	if err := tx.Group(col).Order("total_tokens DESC").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]LLMUsageReportRow, 0, len(rows))
+	for _, r := range rows {
+		row := LLMUsageReportRow{
+			Key:              r.Key,
+			Calls:            r.Calls,
+			Errors:           r.Errors,
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+			TotalTokens:      r.TotalTokens,
+		}
+		if r.Calls > 0 {
+			row.AvgLatencyMs = float64(r.LatencyMs) / float64(r.Calls)
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
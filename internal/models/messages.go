@@ -0,0 +1,298 @@
+package models
+
+import (
+	"HibiscusIM/pkg/util"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 未分片时的消息表名
+const chatMessageTable = "chat_messages"
+
+var ErrMessageNotFound = errors.New("message not found")
+
+// ChatMessage 持久化的聊天消息，启用分片时按会话哈希写入不同的物理表
+type ChatMessage struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	Conversation string    `json:"conversation" gorm:"size:256;uniqueIndex:,composite:conversation_message"`
+	MessageID    int64     `json:"messageId" gorm:"uniqueIndex:,composite:conversation_message"`
+	Sender       string    `json:"sender" gorm:"size:128;index"`
+	Type         string    `json:"type" gorm:"size:32"`
+	Data         string    `json:"data" gorm:"type:text"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"index"`
+	// 阅后即焚消息的过期时间，过期后不再返回并由清理任务删除
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" gorm:"index"`
+}
+
+// notExpired 过滤已过期的消息
+func notExpired(tx *gorm.DB, now time.Time) *gorm.DB {
+	return tx.Where("expires_at IS NULL OR expires_at > ?", now)
+}
+
+func (ChatMessage) TableName() string {
+	return chatMessageTable
+}
+
+// MessageShardConfig 消息表分片配置
+type MessageShardConfig struct {
+	// 分片数，小于等于 1 时使用 chat_messages 单表
+	Shards int
+	// 分片表名格式，必须包含一个 %d（分片序号），写成 schema.table 形式时按 schema 分片
+	TableFormat string
+}
+
+// LoadMessageShardConfig 从环境变量加载消息分片配置，未配置时不分片
+func LoadMessageShardConfig() MessageShardConfig {
+	cfg := MessageShardConfig{
+		Shards:      int(util.GetIntEnv("MESSAGE_SHARDS")),
+		TableFormat: util.GetEnv("MESSAGE_SHARD_TABLE_FORMAT"),
+	}
+	if cfg.TableFormat == "" {
+		cfg.TableFormat = chatMessageTable + "_%d"
+	}
+	return cfg
+}
+
+// MessageRepository 聊天消息仓库，按会话ID哈希路由到分片表，同一会话的消息总在同一分片；
+// 分片数变更需要离线迁移数据
+type MessageRepository struct {
+	db     *gorm.DB
+	tables []string
+}
+
+// NewMessageRepository 创建消息仓库
+func NewMessageRepository(db *gorm.DB, cfg MessageShardConfig) (*MessageRepository, error) {
+	if cfg.Shards <= 1 {
+		return &MessageRepository{db: db, tables: []string{chatMessageTable}}, nil
+	}
+	if strings.Count(cfg.TableFormat, "%") != 1 || !strings.Contains(cfg.TableFormat, "%d") {
+		return nil, fmt.Errorf("message shard table format %q must contain exactly one %%d", cfg.TableFormat)
+	}
+	tables := make([]string, cfg.Shards)
+	for i := range tables {
+		tables[i] = fmt.Sprintf(cfg.TableFormat, i)
+	}
+	return &MessageRepository{db: db, tables: tables}, nil
+}
+
+// Shards 分片数
+func (r *MessageRepository) Shards() int {
+	return len(r.tables)
+}
+
+// ShardOf 会话所在分片，使用 FNV-1a 哈希
+func (r *MessageRepository) ShardOf(conversation string) int {
+	if len(r.tables) == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(conversation))
+	return int(h.Sum32() % uint32(len(r.tables)))
+}
+
+// TableOf 会话消息所在的物理表
+func (r *MessageRepository) TableOf(conversation string) string {
+	return r.tables[r.ShardOf(conversation)]
+}
+
+// Tables 全部分片表
+func (r *MessageRepository) Tables() []string {
+	return append([]string(nil), r.tables...)
+}
+
+// Migrate 创建或更新全部分片表
+func (r *MessageRepository) Migrate() error {
+	for _, table := range r.tables {
+		if err := r.db.Table(table).AutoMigrate(&ChatMessage{}); err != nil {
+			return fmt.Errorf("migrate %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// Save 按分片批量写入消息，重复的会话消息ID忽略
+func (r *MessageRepository) Save(ctx context.Context, msgs []ChatMessage) error {
+	byTable := make(map[string][]ChatMessage)
+	for _, m := range msgs {
+		table := r.TableOf(m.Conversation)
+		byTable[table] = append(byTable[table], m)
+	}
+	for _, table := range r.tables {
+		rows := byTable[table]
+		if len(rows) == 0 {
+			continue
+		}
+		if err := r.db.WithContext(ctx).Table(table).
+			Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(rows, 100).Error; err != nil {
+			return fmt.Errorf("save messages to %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// History 会话历史消息，按消息ID倒序，不含已过期的消息；before 大于 0 时只返回该消息ID之前的消息
+func (r *MessageRepository) History(ctx context.Context, conversation string, before int64, limit int) ([]ChatMessage, error) {
+	tx := notExpired(r.db.WithContext(ctx).Table(r.TableOf(conversation)), time.Now()).Where("conversation = ?", conversation)
+	if before > 0 {
+		tx = tx.Where("message_id < ?", before)
+	}
+	var msgs []ChatMessage
+	if err := tx.Order("message_id DESC").Limit(limit).Find(&msgs).Error; err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// After 会话中 after 之后的消息，按消息ID升序，不含已过期的消息，用于离线消息补发
+func (r *MessageRepository) After(ctx context.Context, conversation string, after int64, limit int) ([]ChatMessage, error) {
+	var msgs []ChatMessage
+	err := notExpired(r.db.WithContext(ctx).Table(r.TableOf(conversation)), time.Now()).
+		Where("conversation = ? AND message_id > ?", conversation, after).
+		Order("message_id").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+// Get 读取会话中的单条消息，已过期的消息视为不存在
+func (r *MessageRepository) Get(ctx context.Context, conversation string, messageID int64) (*ChatMessage, error) {
+	var msg ChatMessage
+	err := notExpired(r.db.WithContext(ctx).Table(r.TableOf(conversation)), time.Now()).
+		Where("conversation = ? AND message_id = ?", conversation, messageID).
+		Take(&msg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// LastMessages 多个会话的最新一条未过期消息，按分片分组查询，每个分片一次查询
+func (r *MessageRepository) LastMessages(ctx context.Context, conversations []string) (map[string]ChatMessage, error) {
+	byTable := make(map[string][]string)
+	for _, c := range conversations {
+		table := r.TableOf(c)
+		byTable[table] = append(byTable[table], c)
+	}
+	out := make(map[string]ChatMessage, len(conversations))
+	now := time.Now()
+	for table, convs := range byTable {
+		latest := notExpired(r.db.Table(table), now).Select("conversation, MAX(message_id) AS message_id").
+			Where("conversation IN ?", convs).Group("conversation")
+		var msgs []ChatMessage
+		if err := r.db.WithContext(ctx).Table(table+" AS m").Select("m.*").
+			Joins("JOIN (?) AS latest ON latest.conversation = m.conversation AND latest.message_id = m.message_id", latest).
+			Find(&msgs).Error; err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			out[m.Conversation] = m
+		}
+	}
+	return out, nil
+}
+
+// SenderMessages 用户发送的未过期消息，需要扫描全部分片，按发送时间倒序合并
+func (r *MessageRepository) SenderMessages(ctx context.Context, sender string, since time.Time, limit int) ([]ChatMessage, error) {
+	var all []ChatMessage
+	now := time.Now()
+	for _, table := range r.tables {
+		tx := notExpired(r.db.WithContext(ctx).Table(table), now).Where("sender = ?", sender)
+		if !since.IsZero() {
+			tx = tx.Where("created_at >= ?", since)
+		}
+		var msgs []ChatMessage
+		if err := tx.Order("created_at DESC").Limit(limit).Find(&msgs).Error; err != nil {
+			return nil, err
+		}
+		all = append(all, msgs...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].CreatedAt.After(all[j].CreatedAt)
+	})
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+// Count 全部分片中未过期的消息数
+func (r *MessageRepository) Count(ctx context.Context) (int64, error) {
+	now := time.Now()
+	var total int64
+	for _, table := range r.tables {
+		var n int64
+		if err := notExpired(r.db.WithContext(ctx).Table(table), now).Count(&n).Error; err != nil {
+			return total, fmt.Errorf("count messages in %s: %w", table, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Scan 依次按主键分批读取各分片中未过期的消息
+func (r *MessageRepository) Scan(ctx context.Context, batch int, fn func([]ChatMessage) error) error {
+	now := time.Now()
+	for _, table := range r.tables {
+		var msgs []ChatMessage
+		err := notExpired(r.db.WithContext(ctx).Table(table), now).
+			FindInBatches(&msgs, batch, func(tx *gorm.DB, _ int) error {
+				return fn(msgs)
+			}).Error
+		if err != nil {
+			return fmt.Errorf("scan messages in %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// DeleteConversation 删除会话的全部消息
+func (r *MessageRepository) DeleteConversation(ctx context.Context, conversation string) (int64, error) {
+	result := r.db.WithContext(ctx).Table(r.TableOf(conversation)).
+		Where("conversation = ?", conversation).Delete(&ChatMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeExpired 删除在 now 之前过期的消息，每个分片最多删除 limit 条，返回被删除消息的会话与消息ID
+func (r *MessageRepository) PurgeExpired(ctx context.Context, now time.Time, limit int) ([]ChatMessage, error) {
+	return r.purge(ctx, "expired", "expires_at", limit, "expires_at IS NOT NULL AND expires_at <= ?", now)
+}
+
+// PurgeBefore 删除 cutoff 之前发送的消息，用于按保留期限清理历史，每个分片最多删除 limit 条
+func (r *MessageRepository) PurgeBefore(ctx context.Context, cutoff time.Time, limit int) ([]ChatMessage, error) {
+	return r.purge(ctx, "retained", "created_at", limit, "created_at < ?", cutoff)
+}
+
+// purge 在每个分片按 order 顺序删除至多 limit 条满足条件的消息
+func (r *MessageRepository) purge(ctx context.Context, kind, order string, limit int, query string, args ...interface{}) ([]ChatMessage, error) {
+	var purged []ChatMessage
+	for _, table := range r.tables {
+		var msgs []ChatMessage
+		if err := r.db.WithContext(ctx).Table(table).Select("id, conversation, message_id").
+			Where(query, args...).
+			Order(order).Limit(limit).Find(&msgs).Error; err != nil {
+			return purged, fmt.Errorf("find %s messages in %s: %w", kind, table, err)
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		ids := make([]uint, len(msgs))
+		for i, m := range msgs {
+			ids[i] = m.ID
+		}
+		if err := r.db.WithContext(ctx).Table(table).Where("id IN ?", ids).Delete(&ChatMessage{}).Error; err != nil {
+			return purged, fmt.Errorf("purge %s messages in %s: %w", kind, table, err)
+		}
+		purged = append(purged, msgs...)
+	}
+	return purged, nil
+}
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ModerationActionMute   = "mute"
+	ModerationActionUnmute = "unmute"
+	ModerationActionKick   = "kick"
+	ModerationActionBan    = "ban"
+	ModerationActionUnban  = "unban"
+
+	SigGroupModeration = "group.moderation"
+)
+
+var (
+	ErrMemberMuted  = errors.New("您已被禁言")
+	ErrMemberBanned = errors.New("您已被禁止进入该群组")
+
+	ErrGroupNotFound       = errors.New("群组不存在")
+	ErrGroupMemberNotFound = errors.New("该用户不是群组成员")
+	ErrMemberNotBanned     = errors.New("该用户未被封禁")
+	ErrModerateGroupAdmin  = errors.New("不能对群组管理员执行该操作")
+)
+
+// GroupModeration 群组成员当前的禁言/封禁状态
+type GroupModeration struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	GroupID    uint       `json:"groupId" gorm:"uniqueIndex:idx_group_moderation_member"`
+	UserID     uint       `json:"userId" gorm:"uniqueIndex:idx_group_moderation_member"`
+	MutedUntil *time.Time `json:"mutedUntil,omitempty"`
+	Banned     bool       `json:"banned"`
+	Reason     string     `json:"reason,omitempty" gorm:"size:512"`
+	OperatorID uint       `json:"operatorId"`
+}
+
+// GroupModerationEvent 群组管理操作历史记录
+type GroupModerationEvent struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"autoCreateTime;index"`
+	GroupID    uint       `json:"groupId" gorm:"index"`
+	UserID     uint       `json:"userId" gorm:"index"`
+	OperatorID uint       `json:"operatorId"`
+	Action     string     `json:"action" gorm:"size:24"`
+	Reason     string     `json:"reason,omitempty" gorm:"size:512"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// IsMuted 判断当前是否处于禁言状态
+func (m *GroupModeration) IsMuted(now time.Time) bool {
+	return m.MutedUntil != nil && m.MutedUntil.After(now)
+}
+
+// GroupExists 判断群组是否存在
+func GroupExists(db *gorm.DB, groupID uint) bool {
+	var count int64
+	db.Model(&Group{}).Where("id = ?", groupID).Count(&count)
+	return count > 0
+}
+
+// IsGroupAdmin 判断用户是否为群组管理员
+func IsGroupAdmin(db *gorm.DB, groupID, userID uint) bool {
+	var count int64
+	db.Model(&GroupMember{}).
+		Where("group_id = ? AND user_id = ? AND role = ?", groupID, userID, GroupRoleAdmin).
+		Count(&count)
+	return count > 0
+}
+
+// IsGroupMember 判断用户是否为群组成员
+func IsGroupMember(db *gorm.DB, groupID, userID uint) bool {
+	var count int64
+	db.Model(&GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// UserGroupIDs 用户所在的全部群组ID
+func UserGroupIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Model(&GroupMember{}).Where("user_id = ?", userID).Order("group_id").Pluck("group_id", &ids).Error
+	return ids, err
+}
+
+// GroupPeerIDs 与用户同在任一群组的其他用户ID，最多返回 limit 个
+func GroupPeerIDs(db *gorm.DB, userID uint, limit int) ([]uint, error) {
+	var ids []uint
+	mine := db.Model(&GroupMember{}).Select("group_id").Where("user_id = ?", userID)
+	err := db.Model(&GroupMember{}).
+		Distinct("user_id").
+		Where("group_id IN (?) AND user_id <> ?", mine, userID).
+		Order("user_id").
+		Limit(limit).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// GetGroupModeration 获取成员的管理状态，不存在时返回nil
+func GetGroupModeration(db *gorm.DB, groupID, userID uint) (*GroupModeration, error) {
+	var m GroupModeration
+	err := db.Where("group_id = ? AND user_id = ?", groupID, userID).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// CheckSend 检查处于该状态的成员是否允许发言，m 为 nil 表示无管理记录
+func (m *GroupModeration) CheckSend(now time.Time) error {
+	if m == nil {
+		return nil
+	}
+	if m.Banned {
+		return ErrMemberBanned
+	}
+	if m.IsMuted(now) {
+		return ErrMemberMuted
+	}
+	return nil
+}
+
+// CheckJoin 检查处于该状态的成员是否允许加入群组，m 为 nil 表示无管理记录
+func (m *GroupModeration) CheckJoin() error {
+	if m != nil && m.Banned {
+		return ErrMemberBanned
+	}
+	return nil
+}
+
+// MuteGroupMember 禁言成员，duration<=0 表示解除禁言；用户不在群组内时返回 ErrGroupMemberNotFound
+func MuteGroupMember(db *gorm.DB, groupID, userID, operatorID uint, duration time.Duration, reason string) (*GroupModerationEvent, error) {
+	if !IsGroupMember(db, groupID, userID) {
+		return nil, ErrGroupMemberNotFound
+	}
+	var until *time.Time
+	action := ModerationActionUnmute
+	if duration > 0 {
+		t := time.Now().Add(duration)
+		until = &t
+		action = ModerationActionMute
+	}
+	return applyGroupModeration(db, groupID, userID, operatorID, action, reason, until, func(m *GroupModeration) {
+		m.MutedUntil = until
+	})
+}
+
+// BanGroupMember 封禁成员并移出群组
+func BanGroupMember(db *gorm.DB, groupID, userID, operatorID uint, reason string) (*GroupModerationEvent, error) {
+	return applyGroupModeration(db, groupID, userID, operatorID, ModerationActionBan, reason, nil, func(m *GroupModeration) {
+		m.Banned = true
+	})
+}
+
+// UnbanGroupMember 解除封禁，用户未被封禁时返回 ErrMemberNotBanned
+func UnbanGroupMember(db *gorm.DB, groupID, userID, operatorID uint, reason string) (*GroupModerationEvent, error) {
+	m, err := GetGroupModeration(db, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil || !m.Banned {
+		return nil, ErrMemberNotBanned
+	}
+	return applyGroupModeration(db, groupID, userID, operatorID, ModerationActionUnban, reason, nil, func(m *GroupModeration) {
+		m.Banned = false
+	})
+}
+
+// KickGroupMember 将成员踢出群组，成员之后仍可重新加入；用户不在群组内时返回 ErrGroupMemberNotFound
+func KickGroupMember(db *gorm.DB, groupID, userID, operatorID uint, reason string) (*GroupModerationEvent, error) {
+	if !IsGroupMember(db, groupID, userID) {
+		return nil, ErrGroupMemberNotFound
+	}
+	event := &GroupModerationEvent{
+		GroupID:    groupID,
+		UserID:     userID,
+		OperatorID: operatorID,
+		Action:     ModerationActionKick,
+		Reason:     reason,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&GroupMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// ListGroupModerationEvents 分页获取群组管理历史
+func ListGroupModerationEvents(db *gorm.DB, groupID uint, pos, limit int) ([]GroupModerationEvent, int64, error) {
+	var total int64
+	var events []GroupModerationEvent
+	tx := db.Model(&GroupModerationEvent{}).Where("group_id = ?", groupID)
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Order("id DESC").Offset(pos).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+func applyGroupModeration(db *gorm.DB, groupID, userID, operatorID uint, action, reason string, expiresAt *time.Time, mutate func(m *GroupModeration)) (*GroupModerationEvent, error) {
+	event := &GroupModerationEvent{
+		GroupID:    groupID,
+		UserID:     userID,
+		OperatorID: operatorID,
+		Action:     action,
+		Reason:     reason,
+		ExpiresAt:  expiresAt,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var m GroupModeration
+		err := tx.Where("group_id = ? AND user_id = ?", groupID, userID).First(&m).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		m.GroupID = groupID
+		m.UserID = userID
+		m.OperatorID = operatorID
+		m.Reason = reason
+		mutate(&m)
+		if err := tx.Save(&m).Error; err != nil {
+			return err
+		}
+		if action == ModerationActionBan {
+			if err := tx.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&GroupMember{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(event).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
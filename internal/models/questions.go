@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -17,11 +18,41 @@ type Question struct {
 }
 
 type Questionnaire struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Title       string    `json:"title" gorm:"size:255"`       // 问卷标题
-	Description string    `json:"description" gorm:"size:255"` // 问卷描述
-	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	Title          string    `json:"title" gorm:"size:255"`                   // 问卷标题
+	Description    string    `json:"description" gorm:"size:255"`             // 问卷描述
+	AvailableFrom  string    `json:"availableFrom,omitempty" gorm:"size:10"`  // 开放日期（含），答题用户的当地日期，格式 2006-01-02
+	AvailableUntil string    `json:"availableUntil,omitempty" gorm:"size:10"` // 截止日期（含），答题用户的当地日期
+	DailyStart     string    `json:"dailyStart,omitempty" gorm:"size:5"`      // 每天开放时段的开始，答题用户的当地时间，格式 15:04
+	DailyEnd       string    `json:"dailyEnd,omitempty" gorm:"size:5"`        // 每天开放时段的结束（不含），早于开始时跨越午夜
+	CreatedAt      time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+var ErrQuestionnaireUnavailable = errors.New("questionnaire is not open for responses at this time")
+
+// AvailableAt 问卷在时刻 t 是否对 loc 时区的用户开放作答。开放日期与每日时段均按用户当地时间解释，
+// 不同时区的用户各自在当地的开放日零点开始作答；未设置或格式无效的条件不做限制
+func (q *Questionnaire) AvailableAt(t time.Time, loc *time.Location) bool {
+	local := t.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	if from, err := time.Parse(time.DateOnly, q.AvailableFrom); err == nil && today.Before(from) {
+		return false
+	}
+	if until, err := time.Parse(time.DateOnly, q.AvailableUntil); err == nil && today.After(until) {
+		return false
+	}
+	start, err1 := time.Parse("15:04", q.DailyStart)
+	end, err2 := time.Parse("15:04", q.DailyEnd)
+	if err1 != nil || err2 != nil || start.Equal(end) {
+		return true
+	}
+	now := local.Hour()*60 + local.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if from < to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
 }
 
 type QuestionnaireResponse struct {
@@ -0,0 +1,249 @@
+package models
+
+import (
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReactionMessageNotFound 回应的消息不存在
+var ErrReactionMessageNotFound = errors.New("message not found")
+
+// defaultReactionSampleSize 每个表情保留的最近回应用户数
+const defaultReactionSampleSize = 5
+
+// MessageReaction 用户对会话消息的表情回应
+type MessageReaction struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	Conversation string    `json:"conversation" gorm:"size:256;uniqueIndex:idx_message_reaction"`
+	MessageID    int64     `json:"messageId" gorm:"uniqueIndex:idx_message_reaction"`
+	Emoji        string    `json:"emoji" gorm:"size:64;uniqueIndex:idx_message_reaction"`
+	UserID       string    `json:"userId" gorm:"size:128;uniqueIndex:idx_message_reaction"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// MessageReactionCount 消息上单个表情的聚合，随回应增删同步维护，读取聚合时不扫描回应表
+type MessageReactionCount struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	Conversation string    `json:"conversation" gorm:"size:256;uniqueIndex:idx_message_reaction_count"`
+	MessageID    int64     `json:"messageId" gorm:"uniqueIndex:idx_message_reaction_count"`
+	Emoji        string    `json:"emoji" gorm:"size:64;uniqueIndex:idx_message_reaction_count"`
+	Count        int64     `json:"count"`
+	SampleUsers  string    `json:"-" gorm:"size:1024"` // 最近回应的用户ID，JSON 数组，新的在前
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// ReactionStore 表情回应存储，实现 websocket.ReactionStore
+type ReactionStore struct {
+	db         *gorm.DB
+	sampleSize int
+}
+
+// NewReactionStore 创建表情回应存储，sampleSize 为每个表情保留的最近回应用户数
+func NewReactionStore(db *gorm.DB, sampleSize int) *ReactionStore {
+	if sampleSize <= 0 {
+		sampleSize = defaultReactionSampleSize
+	}
+	return &ReactionStore{db: db, sampleSize: sampleSize}
+}
+
+// AddReaction 添加回应，同一用户重复回应同一表情时不变
+func (s *ReactionStore) AddReaction(conversation string, messageID int64, userID, emoji string) ([]websocket.ReactionAggregate, bool, error) {
+	var changed bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkReactionMessage(tx, conversation, messageID); err != nil {
+			return err
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&MessageReaction{
+			Conversation: conversation,
+			MessageID:    messageID,
+			Emoji:        emoji,
+			UserID:       userID,
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&MessageReactionCount{
+			Conversation: conversation,
+			MessageID:    messageID,
+			Emoji:        emoji,
+		}).Error; err != nil {
+			return err
+		}
+		var row MessageReactionCount
+		if err := tx.Where("conversation = ? AND message_id = ? AND emoji = ?", conversation, messageID, emoji).
+			First(&row).Error; err != nil {
+			return err
+		}
+		users := append([]string{userID}, removeUser(decodeSampleUsers(row.SampleUsers), userID)...)
+		if len(users) > s.sampleSize {
+			users = users[:s.sampleSize]
+		}
+		return tx.Model(&MessageReactionCount{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+			"count":        gorm.Expr("count + 1"),
+			"sample_users": encodeSampleUsers(users),
+			"updated_at":   time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	reactions, err := s.messageReactions(s.db, conversation, messageID)
+	return reactions, changed, err
+}
+
+// RemoveReaction 取消回应，计数归零时删除聚合；被移除的用户在样本中时从回应表补齐样本
+func (s *ReactionStore) RemoveReaction(conversation string, messageID int64, userID, emoji string) ([]websocket.ReactionAggregate, bool, error) {
+	var changed bool
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("conversation = ? AND message_id = ? AND emoji = ? AND user_id = ?", conversation, messageID, emoji, userID).
+			Delete(&MessageReaction{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		changed = true
+
+		var row MessageReactionCount
+		err := tx.Where("conversation = ? AND message_id = ? AND emoji = ?", conversation, messageID, emoji).First(&row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if row.Count <= 1 {
+			return tx.Delete(&MessageReactionCount{}, row.ID).Error
+		}
+		updates := map[string]interface{}{
+			"count":      gorm.Expr("count - 1"),
+			"updated_at": time.Now(),
+		}
+		if users := decodeSampleUsers(row.SampleUsers); len(users) != len(removeUser(users, userID)) {
+			var latest []string
+			if err := tx.Model(&MessageReaction{}).
+				Where("conversation = ? AND message_id = ? AND emoji = ?", conversation, messageID, emoji).
+				Order("id DESC").Limit(s.sampleSize).Pluck("user_id", &latest).Error; err != nil {
+				return err
+			}
+			updates["sample_users"] = encodeSampleUsers(latest)
+		}
+		return tx.Model(&MessageReactionCount{}).Where("id = ?", row.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	reactions, err := s.messageReactions(s.db, conversation, messageID)
+	return reactions, changed, err
+}
+
+// Reactions 批量读取会话中多条消息的表情聚合，表情按首次回应顺序排列，没有回应的消息不出现在结果中
+func (s *ReactionStore) Reactions(ctx context.Context, conversation string, messageIDs []int64) (map[int64][]websocket.ReactionAggregate, error) {
+	result := make(map[int64][]websocket.ReactionAggregate)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	var rows []MessageReactionCount
+	if err := s.db.WithContext(ctx).
+		Where("conversation = ? AND message_id IN ?", conversation, messageIDs).
+		Order("message_id, id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.MessageID] = append(result[row.MessageID], row.aggregate())
+	}
+	return result, nil
+}
+
+// DeleteMessages 删除会话中多条消息的全部回应与聚合，用于清理过期消息
+func (s *ReactionStore) DeleteMessages(ctx context.Context, conversation string, messageIDs []int64) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation = ? AND message_id IN ?", conversation, messageIDs).
+			Delete(&MessageReaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("conversation = ? AND message_id IN ?", conversation, messageIDs).
+			Delete(&MessageReactionCount{}).Error
+	})
+}
+
+// UserReactions 用户在会话中多条消息上回应过的表情，供客户端高亮自己的回应
+func (s *ReactionStore) UserReactions(ctx context.Context, conversation, userID string, messageIDs []int64) (map[int64][]string, error) {
+	result := make(map[int64][]string)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	var rows []MessageReaction
+	if err := s.db.WithContext(ctx).
+		Where("conversation = ? AND user_id = ? AND message_id IN ?", conversation, userID, messageIDs).
+		Order("message_id, id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.MessageID] = append(result[row.MessageID], row.Emoji)
+	}
+	return result, nil
+}
+
+func (s *ReactionStore) messageReactions(db *gorm.DB, conversation string, messageID int64) ([]websocket.ReactionAggregate, error) {
+	var rows []MessageReactionCount
+	if err := db.Where("conversation = ? AND message_id = ?", conversation, messageID).
+		Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	reactions := make([]websocket.ReactionAggregate, 0, len(rows))
+	for _, row := range rows {
+		reactions = append(reactions, row.aggregate())
+	}
+	return reactions, nil
+}
+
+func (r *MessageReactionCount) aggregate() websocket.ReactionAggregate {
+	return websocket.ReactionAggregate{Emoji: r.Emoji, Count: r.Count, Users: decodeSampleUsers(r.SampleUsers)}
+}
+
+// checkReactionMessage 消息ID不能超过会话最新消息ID
+func checkReactionMessage(db *gorm.DB, conversation string, messageID int64) error {
+	var seq int64
+	if err := db.Model(&ConversationSequence{}).
+		Where("conversation = ?", conversation).
+		Pluck("last_message_id", &seq).Error; err != nil {
+		return err
+	}
+	if messageID <= 0 || messageID > seq {
+		return ErrReactionMessageNotFound
+	}
+	return nil
+}
+
+func decodeSampleUsers(s string) []string {
+	users := []string{}
+	if s != "" {
+		_ = json.Unmarshal([]byte(s), &users)
+	}
+	return users
+}
+
+func encodeSampleUsers(users []string) string {
+	data, _ := json.Marshal(users)
+	return string(data)
+}
+
+func removeUser(users []string, userID string) []string {
+	out := make([]string, 0, len(users))
+	for _, u := range users {
+		if u != userID {
+			out = append(out, u)
+		}
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"HibiscusIM/pkg/util"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 计入存储配额的对象类别
+const (
+	StorageRecordings  = "recordings"
+	StorageAvatars     = "avatars"
+	StorageAttachments = "attachments"
+)
+
+// StorageCategories 全部对象类别
+var StorageCategories = []string{StorageRecordings, StorageAvatars, StorageAttachments}
+
+// StorageUsage 用户每个类别已占用的存储，由对象创建与删除时增减
+type StorageUsage struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	UserID    uint      `json:"userId" gorm:"uniqueIndex:idx_storage_usage"`
+	Category  string    `json:"category" gorm:"size:32;uniqueIndex:idx_storage_usage"`
+	Bytes     int64     `json:"bytes"`
+	Objects   int64     `json:"objects"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// StorageQuota 每个用户的存储配额，不大于 0 表示不限制
+type StorageQuota struct {
+	// 全部类别合计的上限
+	Total int64
+	// 单个类别的上限
+	Categories map[string]int64
+	// 用量达到上限的该百分比时发送提醒
+	WarnPercent int64
+}
+
+// LoadStorageQuota 从环境变量加载存储配额：STORAGE_QUOTA_BYTES 为合计上限，
+// STORAGE_QUOTA_<CATEGORY>_BYTES 为单个类别上限，STORAGE_QUOTA_WARN_PERCENT 为提醒阈值（默认 80）
+func LoadStorageQuota() StorageQuota {
+	quota := StorageQuota{
+		Total:       util.GetIntEnv("STORAGE_QUOTA_BYTES"),
+		Categories:  make(map[string]int64),
+		WarnPercent: 80,
+	}
+	for _, category := range StorageCategories {
+		if v := util.GetIntEnv("STORAGE_QUOTA_" + strings.ToUpper(category) + "_BYTES"); v > 0 {
+			quota.Categories[category] = v
+		}
+	}
+	if v := util.GetIntEnv("STORAGE_QUOTA_WARN_PERCENT"); v > 0 && v < 100 {
+		quota.WarnPercent = v
+	}
+	return quota
+}
+
+// StorageQuotaError 上传超出存储配额
+type StorageQuotaError struct {
+	// 超出的类别，合计超出时为空
+	Category string `json:"category,omitempty"`
+	Used     int64  `json:"used"`
+	Quota    int64  `json:"quota"`
+	Size     int64  `json:"size"`
+}
+
+func (e *StorageQuotaError) Error() string {
+	scope := "total"
+	if e.Category != "" {
+		scope = e.Category
+	}
+	msg := fmt.Sprintf("storage quota exceeded for %s: %s used of %s", scope, formatBytes(e.Used), formatBytes(e.Quota))
+	if e.Size > 0 {
+		msg += ", upload needs " + formatBytes(e.Size)
+	}
+	return msg
+}
+
+// StorageCategoryUsage 单个类别的用量
+type StorageCategoryUsage struct {
+	Category string `json:"category"`
+	Bytes    int64  `json:"bytes"`
+	Objects  int64  `json:"objects"`
+	Quota    int64  `json:"quota,omitempty"`
+}
+
+// StorageUsageSummary 用户的存储用量，Percent 为合计或任一类别相对上限的最高百分比
+type StorageUsageSummary struct {
+	UserID     uint                   `json:"userId"`
+	Bytes      int64                  `json:"bytes"`
+	Quota      int64                  `json:"quota,omitempty"`
+	Percent    float64                `json:"percent"`
+	Warning    bool                   `json:"warning"`
+	Categories []StorageCategoryUsage `json:"categories"`
+}
+
+// Usage 统计用户的存储用量
+func (q StorageQuota) Usage(db *gorm.DB, userID uint) (*StorageUsageSummary, error) {
+	var rows []StorageUsage
+	if err := db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	byCategory := make(map[string]StorageUsage, len(rows))
+	for _, row := range rows {
+		byCategory[row.Category] = row
+	}
+	summary := &StorageUsageSummary{UserID: userID, Quota: max(q.Total, 0)}
+	for _, category := range StorageCategories {
+		row := byCategory[category]
+		summary.Bytes += row.Bytes
+		summary.Categories = append(summary.Categories, StorageCategoryUsage{
+			Category: category,
+			Bytes:    row.Bytes,
+			Objects:  row.Objects,
+			Quota:    q.Categories[category],
+		})
+		if quota := q.Categories[category]; quota > 0 {
+			summary.Percent = max(summary.Percent, percentOf(row.Bytes, quota))
+		}
+	}
+	if q.Total > 0 {
+		summary.Percent = max(summary.Percent, percentOf(summary.Bytes, q.Total))
+	}
+	summary.Warning = summary.Percent >= float64(q.WarnPercent)
+	return summary, nil
+}
+
+// Check 检查上传 size 字节后是否超出配额，超出时返回 *StorageQuotaError；同时返回上传前的用量。
+// size 为 0 时检查是否还有剩余空间，用于大小未知的直传签名
+func (q StorageQuota) Check(db *gorm.DB, userID uint, category string, size int64) (*StorageUsageSummary, error) {
+	summary, err := q.Usage(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	need := max(size, 1)
+	for _, c := range summary.Categories {
+		if c.Category == category && c.Quota > 0 && c.Bytes+need > c.Quota {
+			return summary, &StorageQuotaError{Category: category, Used: c.Bytes, Quota: c.Quota, Size: size}
+		}
+	}
+	if q.Total > 0 && summary.Bytes+need > q.Total {
+		return summary, &StorageQuotaError{Used: summary.Bytes, Quota: q.Total, Size: size}
+	}
+	return summary, nil
+}
+
+// Remaining 类别还可使用的字节数，合计与类别上限取较小者，不限制时返回 -1
+func (q StorageQuota) Remaining(summary *StorageUsageSummary, category string) int64 {
+	remaining := int64(-1)
+	if q.Total > 0 {
+		remaining = max(q.Total-summary.Bytes, 0)
+	}
+	for _, c := range summary.Categories {
+		if c.Category == category && c.Quota > 0 {
+			left := max(c.Quota-c.Bytes, 0)
+			if remaining < 0 || left < remaining {
+				remaining = left
+			}
+		}
+	}
+	return remaining
+}
+
+// CrossesWarning 上传 size 字节是否使合计或该类别的用量首次达到提醒阈值
+func (q StorageQuota) CrossesWarning(before *StorageUsageSummary, category string, size int64) bool {
+	crosses := func(used, quota int64) bool {
+		if quota <= 0 {
+			return false
+		}
+		warn := float64(q.WarnPercent)
+		return percentOf(used, quota) < warn && percentOf(used+size, quota) >= warn
+	}
+	for _, c := range before.Categories {
+		if c.Category == category && crosses(c.Bytes, c.Quota) {
+			return true
+		}
+	}
+	return crosses(before.Bytes, q.Total)
+}
+
+// AddStorageUsage 增减用户某类别的用量，用量不会低于 0
+func AddStorageUsage(db *gorm.DB, userID uint, category string, bytes, objects int64) error {
+	if userID == 0 || (bytes == 0 && objects == 0) {
+		return nil
+	}
+	table := db.NamingStrategy.TableName("StorageUsage")
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "category"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"bytes":      gorm.Expr("CASE WHEN "+table+".bytes + ? < 0 THEN 0 ELSE "+table+".bytes + ? END", bytes, bytes),
+			"objects":    gorm.Expr("CASE WHEN "+table+".objects + ? < 0 THEN 0 ELSE "+table+".objects + ? END", objects, objects),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&StorageUsage{
+		UserID:   userID,
+		Category: category,
+		Bytes:    max(bytes, 0),
+		Objects:  max(objects, 0),
+	}).Error
+}
+
+// AfterCreate 附件入库后计入上传者的用量
+func (a *Attachment) AfterCreate(tx *gorm.DB) error {
+	return AddStorageUsage(tx.Session(&gorm.Session{NewDB: true}), a.UserID, StorageAttachments, a.SizeBytes, 1)
+}
+
+// AfterDelete 附件删除后扣减用量，需按已加载的记录删除
+func (a *Attachment) AfterDelete(tx *gorm.DB) error {
+	return AddStorageUsage(tx.Session(&gorm.Session{NewDB: true}), a.UserID, StorageAttachments, -a.SizeBytes, -1)
+}
+
+// AfterCreate 录音入库后计入录音者的用量
+func (r *Recording) AfterCreate(tx *gorm.DB) error {
+	return AddStorageUsage(tx.Session(&gorm.Session{NewDB: true}), r.UserID, StorageRecordings, r.SizeBytes, 1)
+}
+
+// AfterDelete 录音删除后扣减用量，需按已加载的记录删除
+func (r *Recording) AfterDelete(tx *gorm.DB) error {
+	return AddStorageUsage(tx.Session(&gorm.Session{NewDB: true}), r.UserID, StorageRecordings, -r.SizeBytes, -1)
+}
+
+func percentOf(used, quota int64) float64 {
+	return float64(used) * 100 / float64(quota)
+}
+
+// formatBytes 以 KiB/MiB/GiB 显示字节数
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGT"[exp])
+}
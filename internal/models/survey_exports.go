@@ -0,0 +1,185 @@
+package models
+
+import (
+	"HibiscusIM/pkg/export"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 问卷导出任务状态
+const (
+	SurveyExportPending = "pending"
+	SurveyExportRunning = "running"
+	SurveyExportDone    = "done"
+	SurveyExportFailed  = "failed"
+)
+
+// surveyExportBatchSize 每批读取的问卷回答数
+const surveyExportBatchSize = 500
+
+// SurveyExport 问卷结果导出任务
+type SurveyExport struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	QuestionnaireID uint       `json:"questionnaireId" gorm:"index"`
+	RequesterID     uint       `json:"requesterId" gorm:"index"`
+	Format          string     `json:"format" gorm:"size:16"`
+	IncludePII      bool       `json:"includePII"`
+	Status          string     `json:"status" gorm:"size:16;index;default:pending"`
+	ObjectKey       string     `json:"-" gorm:"size:512"`
+	Rows            int        `json:"rows"`
+	Error           string     `json:"error,omitempty" gorm:"size:512"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+}
+
+// FileName 下载文件名
+func (e *SurveyExport) FileName() string {
+	return "questionnaire-" + strconv.FormatUint(uint64(e.QuestionnaireID), 10) + "-responses." + e.Format
+}
+
+// MarkSurveyExport 更新导出任务状态
+func MarkSurveyExport(db *gorm.DB, id uint, status string, values map[string]any) error {
+	if values == nil {
+		values = map[string]any{}
+	}
+	values["status"] = status
+	if status == SurveyExportDone || status == SurveyExportFailed {
+		values["finished_at"] = time.Now()
+	}
+	return db.Model(&SurveyExport{}).Where("id = ?", id).Updates(values).Error
+}
+
+// surveyUserColumns 非敏感的用户属性列，始终导出
+var surveyUserColumns = []string{"Locale", "Timezone", "Gender", "City", "Region", "Country"}
+
+// surveyPIIColumns 个人身份信息列，仅 IncludePII 时导出
+var surveyPIIColumns = []string{"Email", "Phone", "DisplayName", "FirstName", "LastName"}
+
+// pseudonymizeUser 未导出个人信息时使用不可逆的用户标识，同一用户在不同导出中保持一致以便关联分析
+func pseudonymizeUser(secret string, userID uint) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatUint(uint64(userID), 10)))
+	return "u_" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// WriteSurveyExport 分批读取问卷回答并逐行写出，每行一份回答，每个问题一列，同一问题的多个答案以 "; " 连接；
+// secret 用于生成匿名用户标识，返回写出的回答数
+func WriteSurveyExport(ctx context.Context, db *gorm.DB, exp *SurveyExport, secret string, w export.RowWriter) (int, error) {
+	db = db.WithContext(ctx)
+	questions, err := GetQuestionsByQuestionnaire(db, exp.QuestionnaireID)
+	if err != nil {
+		return 0, err
+	}
+	header := []string{"ResponseID", "SubmittedAt", "Respondent"}
+	header = append(header, surveyUserColumns...)
+	if exp.IncludePII {
+		header = append(header, surveyPIIColumns...)
+	}
+	columns := make(map[uint]int, len(questions))
+	for i, q := range questions {
+		columns[q.ID] = len(header) + i
+		header = append(header, q.Text)
+	}
+	if err := w.WriteRow(header); err != nil {
+		return 0, err
+	}
+
+	rows := 0
+	var lastID uint
+	for {
+		var responses []QuestionnaireResponse
+		if err := db.Where("questionnaire_id = ? AND id > ?", exp.QuestionnaireID, lastID).
+			Order("id").Limit(surveyExportBatchSize).Find(&responses).Error; err != nil {
+			return rows, err
+		}
+		if len(responses) == 0 {
+			return rows, nil
+		}
+		lastID = responses[len(responses)-1].ID
+
+		responseIDs := make([]uint, 0, len(responses))
+		userIDs := make([]uint, 0, len(responses))
+		for _, r := range responses {
+			responseIDs = append(responseIDs, r.ID)
+			userIDs = append(userIDs, r.UserID)
+		}
+		var answers []Answer
+		if err := db.Where("response_id IN ?", responseIDs).Order("id").Find(&answers).Error; err != nil {
+			return rows, err
+		}
+		answersByResponse := make(map[uint][]Answer, len(responses))
+		for _, a := range answers {
+			answersByResponse[a.ResponseID] = append(answersByResponse[a.ResponseID], a)
+		}
+		var users []User
+		if err := db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return rows, err
+		}
+		usersByID := make(map[uint]*User, len(users))
+		for i := range users {
+			usersByID[users[i].ID] = &users[i]
+		}
+
+		for _, r := range responses {
+			row := make([]string, len(header))
+			row[0] = strconv.FormatUint(uint64(r.ID), 10)
+			row[1] = r.CreatedAt.Format(time.RFC3339)
+			if exp.IncludePII {
+				row[2] = strconv.FormatUint(uint64(r.UserID), 10)
+			} else {
+				row[2] = pseudonymizeUser(secret, r.UserID)
+			}
+			if u := usersByID[r.UserID]; u != nil {
+				attrs := []string{u.Locale, u.Timezone, u.Gender, u.City, u.Region, u.Country}
+				if exp.IncludePII {
+					attrs = append(attrs, u.Email, u.Phone, u.DisplayName, u.FirstName, u.LastName)
+				}
+				copy(row[3:], attrs)
+			}
+			for _, a := range answersByResponse[r.ID] {
+				col, ok := columns[a.QuestionID]
+				if !ok {
+					continue
+				}
+				value := a.AnswerOption
+				if value == "" {
+					value = a.AnswerText
+				}
+				if row[col] != "" {
+					value = row[col] + "; " + value
+				}
+				row[col] = value
+			}
+			if err := w.WriteRow(row); err != nil {
+				return rows, err
+			}
+			rows++
+		}
+		if err := ctx.Err(); err != nil {
+			return rows, err
+		}
+	}
+}
+
+// SurveyExportSignature 下载链接签名
+func SurveyExportSignature(secret string, id uint, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("survey-export:" + strconv.FormatUint(uint64(id), 10) + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySurveyExportSignature 校验下载链接签名与有效期
+func VerifySurveyExportSignature(secret string, id uint, expires int64, sig string) bool {
+	if time.Now().Unix() > expires {
+		return false
+	}
+	expected := SurveyExportSignature(secret, id, expires)
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(sig)))
+}
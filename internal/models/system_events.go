@@ -0,0 +1,242 @@
+package models
+
+import (
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/redact"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 系统事件级别
+const (
+	SystemEventInfo  = "info"
+	SystemEventWarn  = "warn"
+	SystemEventError = "error"
+)
+
+// 系统事件分类
+const (
+	SystemEventStartup = "startup"
+	SystemEventConfig  = "config"
+	SystemEventBackup  = "backup"
+	SystemEventSearch  = "search"
+	SystemEventAlert   = "alert"
+	SystemEventAdmin   = "admin"
+)
+
+var systemEventLevelRank = map[string]int{
+	SystemEventInfo:  0,
+	SystemEventWarn:  1,
+	SystemEventError: 2,
+}
+
+// SystemEvent 系统事件日志，用于管理后台的动态流
+type SystemEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Level     string    `json:"level" gorm:"size:16;index"`
+	Category  string    `json:"category" gorm:"size:32;index"`
+	Action    string    `json:"action" gorm:"size:64"`
+	Message   string    `json:"message" gorm:"size:512"`
+	Metadata  string    `json:"metadata,omitempty" gorm:"type:text"`
+	ActorID   uint      `json:"actorId,omitempty" gorm:"index"`
+	Actor     string    `json:"actor,omitempty" gorm:"size:128"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+}
+
+// SystemEventFilter 系统事件查询条件，Level 为最低级别
+type SystemEventFilter struct {
+	Level    string
+	Category string
+	Since    time.Time
+	Until    time.Time
+	// BeforeID 向前翻页，结果按 ID 倒序
+	BeforeID uint
+	// AfterID 补发某条之后的事件，结果按 ID 正序
+	AfterID uint
+	Limit   int
+}
+
+// Match 判断事件是否满足过滤条件，供订阅方在内存中过滤
+func (f SystemEventFilter) Match(ev *SystemEvent) bool {
+	if f.Level != "" && systemEventLevelRank[ev.Level] < systemEventLevelRank[f.Level] {
+		return false
+	}
+	if f.Category != "" && ev.Category != f.Category {
+		return false
+	}
+	return true
+}
+
+// ValidSystemEventLevel 级别是否合法
+func ValidSystemEventLevel(level string) bool {
+	_, ok := systemEventLevelRank[level]
+	return ok
+}
+
+// SystemEventJournal 持久化系统事件并推送给订阅者
+type SystemEventJournal struct {
+	db     *gorm.DB
+	mu     sync.RWMutex
+	subs   map[int]chan SystemEvent
+	nextID int
+}
+
+// NewSystemEventJournal 创建系统事件日志
+func NewSystemEventJournal(db *gorm.DB) *SystemEventJournal {
+	return &SystemEventJournal{db: db, subs: make(map[int]chan SystemEvent)}
+}
+
+// Record 写入事件并通知订阅者，消息与 metadata 脱敏后以 JSON 保存
+func (j *SystemEventJournal) Record(ctx context.Context, ev *SystemEvent, metadata map[string]any) error {
+	if !ValidSystemEventLevel(ev.Level) {
+		ev.Level = SystemEventInfo
+	}
+	r := redact.Default()
+	ev.Message = truncate(r.String(ev.Message), 512)
+	metadata = r.Map(metadata)
+	if ev.CreatedAt.IsZero() {
+		ev.CreatedAt = time.Now()
+	}
+	if len(metadata) > 0 {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		ev.Metadata = string(data)
+	}
+	if err := j.db.WithContext(ctx).Create(ev).Error; err != nil {
+		return err
+	}
+	j.publish(*ev)
+	return nil
+}
+
+// Subscribe 订阅新事件，buffer 为订阅通道容量，消费过慢时丢弃事件；调用返回的函数取消订阅
+func (j *SystemEventJournal) Subscribe(buffer int) (<-chan SystemEvent, func()) {
+	if buffer <= 0 {
+		buffer = 64
+	}
+	ch := make(chan SystemEvent, buffer)
+	j.mu.Lock()
+	id := j.nextID
+	j.nextID++
+	j.subs[id] = ch
+	j.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			j.mu.Lock()
+			delete(j.subs, id)
+			j.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (j *SystemEventJournal) publish(ev SystemEvent) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	for _, ch := range j.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Query 按条件查询事件，默认按 ID 倒序，指定 AfterID 时正序
+func (j *SystemEventJournal) Query(ctx context.Context, filter SystemEventFilter) ([]SystemEvent, error) {
+	tx := j.db.WithContext(ctx).Model(&SystemEvent{})
+	if filter.Level != "" {
+		var levels []string
+		for level, rank := range systemEventLevelRank {
+			if rank >= systemEventLevelRank[filter.Level] {
+				levels = append(levels, level)
+			}
+		}
+		tx = tx.Where("level IN ?", levels)
+	}
+	if filter.Category != "" {
+		tx = tx.Where("category = ?", filter.Category)
+	}
+	if !filter.Since.IsZero() {
+		tx = tx.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		tx = tx.Where("created_at < ?", filter.Until)
+	}
+	if filter.BeforeID > 0 {
+		tx = tx.Where("id < ?", filter.BeforeID)
+	}
+	order := "id DESC"
+	if filter.AfterID > 0 {
+		tx = tx.Where("id > ?", filter.AfterID)
+		order = "id ASC"
+	}
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var events []SystemEvent
+	if err := tx.Order(order).Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	// 规则可能在写入后调整，返回前按当前规则再次脱敏
+	r := redact.Default()
+	for i := range events {
+		events[i].Message = r.String(events[i].Message)
+		events[i].Metadata = r.JSON(events[i].Metadata)
+	}
+	return events, nil
+}
+
+var (
+	systemEventsMu sync.RWMutex
+	systemEvents   *SystemEventJournal
+)
+
+// SetSystemEventJournal 设置全局系统事件日志
+func SetSystemEventJournal(j *SystemEventJournal) {
+	systemEventsMu.Lock()
+	defer systemEventsMu.Unlock()
+	systemEvents = j
+}
+
+// GetSystemEventJournal 获取全局系统事件日志，未设置时返回 nil
+func GetSystemEventJournal() *SystemEventJournal {
+	systemEventsMu.RLock()
+	defer systemEventsMu.RUnlock()
+	return systemEvents
+}
+
+// RecordSystemEvent 写入全局系统事件日志，未设置时忽略，写入失败只记录日志不影响调用方
+func RecordSystemEvent(level, category, action, message string, metadata map[string]any) {
+	recordSystemEvent(&SystemEvent{Level: level, Category: category, Action: action, Message: message}, metadata)
+}
+
+// RecordAdminEvent 记录管理员操作，操作人取自当前登录用户
+func RecordAdminEvent(c *gin.Context, action, message string, metadata map[string]any) {
+	ev := &SystemEvent{Level: SystemEventInfo, Category: SystemEventAdmin, Action: action, Message: message}
+	if user := CurrentUser(c); user != nil {
+		ev.ActorID = user.ID
+		ev.Actor = user.Email
+	}
+	recordSystemEvent(ev, metadata)
+}
+
+func recordSystemEvent(ev *SystemEvent, metadata map[string]any) {
+	j := GetSystemEventJournal()
+	if j == nil {
+		return
+	}
+	if err := j.Record(context.Background(), ev, metadata); err != nil {
+		logger.Warn("record system event failed", zap.String("category", ev.Category), zap.String("action", ev.Action), zap.Error(err))
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTimezone = errors.New("invalid timezone")
+
+// timezoneCache 已加载的时区，避免每次读取 tzdata
+var timezoneCache sync.Map
+
+// LoadTimezone 按 IANA 名称（如 Asia/Shanghai）加载时区，结果会被缓存
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidTimezone
+	}
+	if loc, ok := timezoneCache.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	timezoneCache.Store(name, loc)
+	return loc, nil
+}
+
+// ValidTimezone 时区名称是否有效
+func ValidTimezone(name string) bool {
+	_, err := LoadTimezone(name)
+	return err == nil
+}
+
+// Location 用户设置的时区，未设置或无效时使用服务器时区
+func (u *User) Location() *time.Location {
+	if u == nil || u.Timezone == "" {
+		return time.Local
+	}
+	loc, err := LoadTimezone(u.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// LocalTime 用户当地时间
+func (u *User) LocalTime(t time.Time) time.Time {
+	return t.In(u.Location())
+}
+
+// UserLocations 批量读取用户时区，查不到的用户使用服务器时区
+func UserLocations(db *gorm.DB, userIDs []uint) map[uint]*time.Location {
+	locs := make(map[uint]*time.Location, len(userIDs))
+	for _, id := range userIDs {
+		locs[id] = time.Local
+	}
+	if len(userIDs) == 0 {
+		return locs
+	}
+	var users []User
+	if err := db.Select("id", "timezone").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return locs
+	}
+	for i := range users {
+		locs[users[i].ID] = users[i].Location()
+	}
+	return locs
+}
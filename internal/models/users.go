@@ -31,6 +31,8 @@ const (
 	SigUserVerifyEmail = "user.verifyemail"
 	//SigUserResetPassword: user *User, hash, clientIp, userAgent string
 	SigUserResetPassword = "user.resetpassword"
+	//SigUserAuthRevoked: user *User, reason string
+	SigUserAuthRevoked = "user.authrevoked"
 )
 
 type SendEmailVerifyEmail struct {
@@ -16,12 +16,14 @@ type Recording struct {
 	PromptID      uint   // 对应哪一句录音
 	SentenceIndex int    // 句子编号（冗余）
 	FileURL       string `gorm:"size:1024"` // 存储到对象存储后的 URL
+	ObjectKey     string `gorm:"size:512"`  // 对象存储中的键，用于内容扫描
 	Format        string `gorm:"size:32"`   // e.g. "wav", "opus"
 	DurationMs    int    // 毫秒
 	SizeBytes     int64
 	Checksum      string `gorm:"size:128"`
 	Status        string `gorm:"size:32"`   // uploaded / processing / ready / failed
 	Transcription string `gorm:"type:text"` // 可选：自动语音识别结果
+	ScanState            // 内容扫描状态，通过前不可下载
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 }
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"HibiscusIM/pkg/websocket"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WSDeadLetter 被丢弃的WS消息
+type WSDeadLetter struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:64"`
+	Reason     string     `json:"reason" gorm:"size:64;index"`
+	TargetType string     `json:"targetType" gorm:"size:32"`
+	Target     string     `json:"target" gorm:"size:128;index"`
+	UserID     string     `json:"userId" gorm:"size:128;index"`
+	NodeID     string     `json:"nodeId" gorm:"size:128"`
+	Payload    string     `json:"payload" gorm:"type:text"`
+	DroppedAt  time.Time  `json:"droppedAt" gorm:"index"`
+	ReplayedAt *time.Time `json:"replayedAt,omitempty"`
+}
+
+func (d *WSDeadLetter) toDeadLetter() websocket.DeadLetter {
+	return websocket.DeadLetter{
+		ID:         d.ID,
+		Reason:     d.Reason,
+		TargetType: d.TargetType,
+		Target:     d.Target,
+		UserID:     d.UserID,
+		NodeID:     d.NodeID,
+		Payload:    []byte(d.Payload),
+		DroppedAt:  d.DroppedAt,
+		ReplayedAt: d.ReplayedAt,
+	}
+}
+
+// DeadLetterStore 基于数据库的WS死信存储，多个节点共享
+type DeadLetterStore struct {
+	db *gorm.DB
+}
+
+// NewDeadLetterStore 创建数据库死信存储
+func NewDeadLetterStore(db *gorm.DB) *DeadLetterStore {
+	return &DeadLetterStore{db: db}
+}
+
+func (s *DeadLetterStore) Save(ctx context.Context, letters []websocket.DeadLetter) error {
+	if len(letters) == 0 {
+		return nil
+	}
+	rows := make([]WSDeadLetter, 0, len(letters))
+	for _, dl := range letters {
+		rows = append(rows, WSDeadLetter{
+			ID:         dl.ID,
+			Reason:     dl.Reason,
+			TargetType: dl.TargetType,
+			Target:     dl.Target,
+			UserID:     dl.UserID,
+			NodeID:     dl.NodeID,
+			Payload:    string(dl.Payload),
+			DroppedAt:  dl.DroppedAt,
+			ReplayedAt: dl.ReplayedAt,
+		})
+	}
+	return s.db.WithContext(ctx).CreateInBatches(rows, 100).Error
+}
+
+func (s *DeadLetterStore) List(ctx context.Context, filter websocket.DeadLetterFilter) ([]websocket.DeadLetter, error) {
+	tx := s.db.WithContext(ctx).Model(&WSDeadLetter{})
+	if filter.Reason != "" {
+		tx = tx.Where("reason = ?", filter.Reason)
+	}
+	if filter.TargetType != "" {
+		tx = tx.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.Target != "" {
+		tx = tx.Where("target = ? OR user_id = ?", filter.Target, filter.Target)
+	}
+	if !filter.Since.IsZero() {
+		tx = tx.Where("dropped_at >= ?", filter.Since)
+	}
+	if !filter.IncludeReplayed {
+		tx = tx.Where("replayed_at IS NULL")
+	}
+	if filter.Limit > 0 {
+		tx = tx.Limit(filter.Limit)
+	}
+	var rows []WSDeadLetter
+	if err := tx.Order("dropped_at DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	letters := make([]websocket.DeadLetter, 0, len(rows))
+	for i := range rows {
+		letters = append(letters, rows[i].toDeadLetter())
+	}
+	return letters, nil
+}
+
+func (s *DeadLetterStore) Get(ctx context.Context, id string) (*websocket.DeadLetter, error) {
+	var row WSDeadLetter
+	err := s.db.WithContext(ctx).Where("id = ?", id).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, websocket.ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	dl := row.toDeadLetter()
+	return &dl, nil
+}
+
+func (s *DeadLetterStore) MarkReplayed(ctx context.Context, id string, at time.Time) error {
+	result := s.db.WithContext(ctx).Model(&WSDeadLetter{}).Where("id = ?", id).Update("replayed_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return websocket.ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+func (s *DeadLetterStore) Delete(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Where("id = ?", id).Delete(&WSDeadLetter{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return websocket.ErrDeadLetterNotFound
+	}
+	return nil
+}
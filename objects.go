@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -124,6 +125,7 @@ type Order struct {
 type QueryForm struct {
 	Pos          int      `json:"pos"`
 	Limit        int      `json:"limit"`
+	Cursor       *string  `json:"cursor,omitempty"` // keyset pagination, "" for the first page, replaces pos
 	Keyword      string   `json:"keyword,omitempty"`
 	Filters      []Filter `json:"filters,omitempty"`
 	Orders       []Order  `json:"orders,omitempty"`
@@ -137,6 +139,7 @@ type QueryResult struct {
 	Pos        int    `json:"pos,omitempty"`
 	Limit      int    `json:"limit,omitempty"`
 	Keyword    string `json:"keyword,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"` // cursor of the next page, empty on the last page
 	Items      []any  `json:"items"`
 }
 
@@ -762,9 +765,11 @@ func (obj *WebObject) queryObjects(db *gorm.DB, ctx *gin.Context, form *QueryFor
 		}
 	}
 
-	for _, v := range form.Orders {
-		if q := v.GetQuery(); q != "" {
-			db = db.Order(fmt.Sprintf("%s.%s", tblName, q))
+	if form.Cursor == nil {
+		for _, v := range form.Orders {
+			if q := v.GetQuery(); q != "" {
+				db = db.Order(fmt.Sprintf("%s.%s", tblName, q))
+			}
 		}
 	}
 
@@ -777,13 +782,16 @@ func (obj *WebObject) queryObjects(db *gorm.DB, ctx *gin.Context, form *QueryFor
 		db = db.Where(searchKey, sql.Named("keyword", "%"+form.Keyword+"%"))
 	}
 
+	r.Limit = form.Limit
+	r.Keyword = form.Keyword
+	if form.Cursor != nil {
+		return obj.queryObjectsByCursor(db, ctx, form, r)
+	}
+
 	if len(form.ViewFields) > 0 {
 		db = db.Select(form.ViewFields)
 	}
-
 	r.Pos = form.Pos
-	r.Limit = form.Limit
-	r.Keyword = form.Keyword
 
 	var c int64
 	if err := db.Model(obj.Model).Count(&c).Error; err != nil {
@@ -800,23 +808,69 @@ func (obj *WebObject) queryObjects(db *gorm.DB, ctx *gin.Context, form *QueryFor
 		return r, result.Error
 	}
 
-	r.Items = make([]any, 0, vals.Elem().Len())
-	for i := 0; i < vals.Elem().Len(); i++ {
-		modelObj := vals.Elem().Index(i).Addr().Interface()
+	if r.Items, err = obj.renderItems(db, ctx, vals.Elem()); err != nil {
+		return r, err
+	}
+	r.Pos += int(len(r.Items))
+	return r, nil
+}
+
+// queryObjectsByCursor pages with a keyset cursor instead of OFFSET, so deep pages
+// cost the same as the first one. The total count is skipped for the same reason,
+// and the orders must be on indexed columns.
+func (obj *WebObject) queryObjectsByCursor(db *gorm.DB, ctx *gin.Context, form *QueryForm, r QueryResult) (QueryResult, error) {
+	keyset, err := NewKeyset(db, obj.Model, form.Orders)
+	if err != nil {
+		return r, err
+	}
+	if len(form.ViewFields) > 0 {
+		// the cursor is read back from the order columns
+		fields := append([]string{}, form.ViewFields...)
+		for _, col := range keyset.Columns() {
+			if !slices.Contains(fields, col) {
+				fields = append(fields, col)
+			}
+		}
+		db = db.Select(fields)
+	}
+	db, err = keyset.Apply(db.Model(obj.Model), *form.Cursor)
+	if err != nil {
+		return r, err
+	}
+
+	vals := reflect.New(reflect.SliceOf(obj.modelElem))
+	if err := db.Limit(form.Limit + 1).Find(vals.Interface()).Error; err != nil {
+		return r, err
+	}
+	rows := vals.Elem()
+	if rows.Len() > form.Limit {
+		rows = rows.Slice(0, form.Limit)
+		if r.NextCursor, err = keyset.Cursor(db, rows.Index(rows.Len()-1).Addr().Interface()); err != nil {
+			return r, err
+		}
+	}
+	r.Items, err = obj.renderItems(db, ctx, rows)
+	return r, err
+}
+
+// renderItems applies BeforeRender to the queried rows.
+func (obj *WebObject) renderItems(db *gorm.DB, ctx *gin.Context, rows reflect.Value) ([]any, error) {
+	items := make([]any, 0, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		modelObj := rows.Index(i).Addr().Interface()
 		if obj.BeforeRender != nil {
 			rr, err := obj.BeforeRender(db, ctx, modelObj)
 			if err != nil {
-				return r, err
+				return nil, err
 			}
 			if rr != nil {
 				// if BeforeRender return not nil, then use it as result
 				modelObj = rr
 			}
 		}
-		r.Items = append(r.Items, modelObj)
+		items = append(items, modelObj)
 	}
-	r.Pos += int(len(r.Items))
-	return r, nil
+	return items, nil
 }
 
 // DefaultPrepareQuery return default QueryForm.
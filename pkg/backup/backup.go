@@ -3,6 +3,8 @@ package backup
 import (
 	"HibiscusIM/pkg/config"
 	"HibiscusIM/pkg/logger"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -15,8 +17,29 @@ import (
 	"go.uber.org/zap"
 )
 
-// StartBackupScheduler 启动备份调度器
-func StartBackupScheduler() {
+// Result 一次备份的执行结果
+type Result struct {
+	Target      string        // 备份的数据库类型
+	Kind        string        // SQLite 备份的类型：full 或 incremental
+	Destination string        // 备份文件路径
+	Size        int64         // 备份文件大小（字节）
+	Checksum    string        // 备份文件的 SHA-256
+	StartedAt   time.Time     // 开始时间
+	Duration    time.Duration // 耗时
+	Err         error         // 失败原因，成功时为 nil
+}
+
+// resultHook 定时备份完成后的回调
+var resultHook func(res Result)
+
+// OnResult 设置定时备份完成后的回调，需在 StartBackupScheduler 之前调用
+func OnResult(fn func(res Result)) {
+	resultHook = fn
+}
+
+// StartBackupScheduler 启动备份调度器，返回的函数停止调度并等待执行中的备份完成；
+// 设置了执行记录时每次备份的结果都会写入记录
+func StartBackupScheduler() (stop func()) {
 	c := cron.New()
 
 	// 使用配置中的 Cron 表达式
@@ -24,67 +47,72 @@ func StartBackupScheduler() {
 
 	// 添加定时任务
 	c.AddFunc(schedule, func() {
-		err := ExecuteBackup()
-		if err != nil {
-			logger.Warn("Backup failed: %v", zap.Error(err))
+		res := Run()
+		if recorder := GetRecorder(); recorder != nil {
+			if _, err := recorder.Record(res); err != nil {
+				logger.Warn("record backup run failed", zap.Error(err))
+			}
+		}
+		if hook := resultHook; hook != nil {
+			hook(res)
+		}
+		if res.Err != nil {
+			logger.Warn("Backup failed: %v", zap.Error(res.Err))
 		} else {
-			logger.Info("Backup completed successfully")
+			logger.Info("Backup completed successfully", zap.String("destination", res.Destination), zap.Int64("size", res.Size))
 		}
 	})
 
 	// 启动调度器
 	c.Start()
+	return func() { <-c.Stop().Done() }
 }
 
 // ExecuteBackup 根据配置执行数据库备份
 func ExecuteBackup() error {
-	switch config.GlobalConfig.DBDriver {
+	return Run().Err
+}
+
+// Run 根据配置执行数据库备份，成功时记录备份文件的大小与校验和
+func Run() Result {
+	res := Result{Target: config.GlobalConfig.DBDriver, StartedAt: time.Now()}
+	stamp := res.StartedAt.Format("20060102_150405")
+	switch res.Target {
 	case "sqlite":
-		// 执行 SQLite 备份
-		dst := filepath.Join(config.GlobalConfig.BackupPath, fmt.Sprintf("sys_backup_%s.db", time.Now().Format("20060102_150405")))
-		return BackupSQLiteDatabase(config.GlobalConfig.DSN, dst)
+		// 执行 SQLite 在线备份，备份链未满时只保存变化的页
+		var art *SQLiteArtifact
+		art, res.Err = BackupSQLite(config.GlobalConfig.DSN, config.GlobalConfig.BackupPath, res.StartedAt, config.GlobalConfig.BackupFullEvery)
+		if res.Err == nil {
+			res.Destination = filepath.Join(config.GlobalConfig.BackupPath, art.File)
+			res.Kind = art.Kind
+		}
 	case "mysql":
 		// 执行 MySQL 备份
-		dst := filepath.Join(config.GlobalConfig.BackupPath, fmt.Sprintf("sys_backup_%s.sql", time.Now().Format("20060102_150405")))
-		return BackupMySQLDatabase(config.GlobalConfig.DSN, dst)
+		res.Destination = filepath.Join(config.GlobalConfig.BackupPath, fmt.Sprintf("sys_backup_%s.sql", stamp))
+		res.Err = BackupMySQLDatabase(config.GlobalConfig.DSN, res.Destination)
 	default:
-		return fmt.Errorf("unsupported DB_DRIVER: %s", config.GlobalConfig.DBDriver)
+		res.Err = fmt.Errorf("unsupported DB_DRIVER: %s", res.Target)
 	}
-}
-
-// BackupSQLiteDatabase 执行 SQLite 数据库的备份
-func BackupSQLiteDatabase(src string, dst string) error {
-	// 确保目标路径存在
-	backupDir := filepath.Dir(dst)
-	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
-		err := os.MkdirAll(backupDir, os.ModePerm)
-		if err != nil {
-			return fmt.Errorf("failed to create backup directory: %v", err)
-		}
+	if res.Err == nil {
+		res.Size, res.Checksum, res.Err = fileChecksum(res.Destination)
 	}
+	res.Duration = time.Since(res.StartedAt)
+	return res
+}
 
-	// 打开源文件
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("error opening source file: %v", err)
-	}
-	defer sourceFile.Close()
-
-	// 创建备份文件
-	destFile, err := os.Create(dst)
+// fileChecksum 计算文件大小与 SHA-256
+func fileChecksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("error creating destination file: %v", err)
+		return 0, "", fmt.Errorf("error opening backup file: %v", err)
 	}
-	defer destFile.Close()
-
-	// 拷贝数据
-	_, err = io.Copy(destFile, sourceFile)
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
 	if err != nil {
-		return fmt.Errorf("error copying data: %v", err)
+		return 0, "", fmt.Errorf("error reading backup file: %v", err)
 	}
-
-	log.Printf("SQLite database backup completed: %s", dst)
-	return nil
+	return n, hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // BackupMySQLDatabase 执行 MySQL 数据库的备份
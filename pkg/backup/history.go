@@ -0,0 +1,240 @@
+package backup
+
+import (
+	"HibiscusIM/pkg/metrics"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 备份执行状态
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// DefaultMaxAge 未设置时，距上次成功备份超过该时长即告警，按每日备份留出两小时余量
+const DefaultMaxAge = 26 * time.Hour
+
+// 备份告警规则，规则文件中的同名规则优先
+const (
+	RuleBackupFailed  = "backup_failed"
+	RuleBackupOverdue = "backup_overdue"
+)
+
+// BackupRun 备份执行记录
+type BackupRun struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Target      string    `json:"target" gorm:"size:32"`
+	Kind        string    `json:"kind,omitempty" gorm:"size:16"`
+	Destination string    `json:"destination" gorm:"size:512"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum" gorm:"size:64"`
+	Status      string    `json:"status" gorm:"size:16;index"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	StartedAt   time.Time `json:"startedAt" gorm:"index"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Status 备份健康状况
+type Status struct {
+	LastRun             *BackupRun `json:"lastRun,omitempty"`
+	LastSuccess         *BackupRun `json:"lastSuccess,omitempty"`
+	ConsecutiveFailures int64      `json:"consecutiveFailures"`
+	HoursSinceSuccess   float64    `json:"hoursSinceSuccess"`
+	MaxAgeHours         float64    `json:"maxAgeHours"`
+	Overdue             bool       `json:"overdue"`
+}
+
+// Recorder 备份执行记录的持久化与查询
+type Recorder struct {
+	db     *gorm.DB
+	maxAge time.Duration
+	since  time.Time // 从未成功备份时，按记录器创建时间计算距上次成功的时长
+}
+
+// NewRecorder 创建备份执行记录器，maxAge <= 0 时使用 DefaultMaxAge
+func NewRecorder(db *gorm.DB, maxAge time.Duration) *Recorder {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &Recorder{db: db, maxAge: maxAge, since: time.Now()}
+}
+
+var (
+	recorderMu sync.RWMutex
+	recorder   *Recorder
+)
+
+// SetRecorder 设置全局备份执行记录器
+func SetRecorder(r *Recorder) {
+	recorderMu.Lock()
+	defer recorderMu.Unlock()
+	recorder = r
+}
+
+// GetRecorder 获取全局备份执行记录器，未设置时返回 nil
+func GetRecorder() *Recorder {
+	recorderMu.RLock()
+	defer recorderMu.RUnlock()
+	return recorder
+}
+
+// Record 保存一次备份结果
+func (r *Recorder) Record(res Result) (*BackupRun, error) {
+	run := &BackupRun{
+		Target:      res.Target,
+		Kind:        res.Kind,
+		Destination: res.Destination,
+		Size:        res.Size,
+		Checksum:    res.Checksum,
+		Status:      RunSucceeded,
+		DurationMs:  res.Duration.Milliseconds(),
+		StartedAt:   res.StartedAt,
+	}
+	if res.Err != nil {
+		run.Status = RunFailed
+		run.Error = res.Err.Error()
+	}
+	if err := r.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// List 按开始时间倒序列出执行记录，status 为空时不过滤
+func (r *Recorder) List(status string, limit int) ([]BackupRun, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	q := r.db.Order("started_at DESC, id DESC").Limit(limit)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	runs := make([]BackupRun, 0)
+	err := q.Find(&runs).Error
+	return runs, err
+}
+
+// Status 汇总最近一次执行、最近一次成功与其后的连续失败次数
+func (r *Recorder) Status(now time.Time) (*Status, error) {
+	st := &Status{MaxAgeHours: r.maxAge.Hours()}
+	var last BackupRun
+	if err := r.db.Order("started_at DESC, id DESC").First(&last).Error; err == nil {
+		st.LastRun = &last
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	failures := r.db.Model(&BackupRun{}).Where("status = ?", RunFailed)
+	since := r.since
+	var success BackupRun
+	if err := r.db.Where("status = ?", RunSucceeded).Order("started_at DESC, id DESC").First(&success).Error; err == nil {
+		st.LastSuccess = &success
+		since = success.StartedAt
+		failures = failures.Where("started_at > ?", success.StartedAt)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := failures.Count(&st.ConsecutiveFailures).Error; err != nil {
+		return nil, err
+	}
+	st.HoursSinceSuccess = now.Sub(since).Hours()
+	st.Overdue = now.Sub(since) > r.maxAge
+	return st, nil
+}
+
+// metricValue 告警指标：backup.consecutive_failures 与 backup.hours_since_success
+func (r *Recorder) metricValue(name string) (float64, bool) {
+	st, err := r.Status(time.Now())
+	if err != nil {
+		return 0, false
+	}
+	switch name {
+	case "consecutive_failures":
+		return float64(st.ConsecutiveFailures), true
+	case "hours_since_success":
+		return st.HoursSinceSuccess, true
+	}
+	return 0, false
+}
+
+// RegisterAlerts 注册 backup.* 告警指标，并添加备份失败与超时未成功的告警规则
+func RegisterAlerts(monitor *metrics.Monitor, maxAge time.Duration) {
+	if monitor == nil {
+		return
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	monitor.RegisterMetricSource("backup.", func(name string) (float64, bool) {
+		r := GetRecorder()
+		if r == nil {
+			return 0, false
+		}
+		return r.metricValue(name)
+	})
+	engine := monitor.GetAlertEngine()
+	if engine == nil {
+		return
+	}
+	rules := []metrics.AlertRule{
+		{
+			Name:        RuleBackupFailed,
+			Metric:      "backup.consecutive_failures",
+			Operator:    metrics.OpGreaterThan,
+			Threshold:   0,
+			Severity:    "critical",
+			Annotations: map[string]string{"summary": "latest database backup failed"},
+		},
+		{
+			Name:        RuleBackupOverdue,
+			Metric:      "backup.hours_since_success",
+			Operator:    metrics.OpGreaterThan,
+			Threshold:   maxAge.Hours(),
+			Severity:    "critical",
+			Annotations: map[string]string{"summary": "no successful database backup within " + maxAge.String()},
+		},
+	}
+	for _, rule := range rules {
+		// 规则文件中已定义的同名规则保持不变
+		_, _ = engine.AddRule(rule)
+	}
+}
+
+// RegisterMonitorRoutes 在监控 API 下注册备份执行记录与健康状况接口
+func RegisterMonitorRoutes(r *gin.RouterGroup) {
+	r.GET("/backups", func(c *gin.Context) {
+		rec := GetRecorder()
+		if rec == nil {
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": []BackupRun{}})
+			return
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		runs, err := rec.List(c.Query("status"), limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": runs})
+	})
+	r.GET("/backups/status", func(c *gin.Context) {
+		rec := GetRecorder()
+		if rec == nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "backup history disabled"})
+			return
+		}
+		st, err := rec.Status(time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": st})
+	})
+}
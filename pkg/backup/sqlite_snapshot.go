@@ -0,0 +1,37 @@
+//go:build !mysql && !pg
+
+package backup
+
+import (
+	"context"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// vacuumSQLiteInto 以独立连接执行 VACUUM INTO，把数据库当前的一致快照写入 dst。
+// VACUUM INTO 只是一次读事务，WAL 模式下不阻塞写入；dst 必须不存在
+func vacuumSQLiteInto(dsn, dst string) error {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = 10000"); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "VACUUM INTO ?", dst)
+	return err
+}
@@ -0,0 +1,10 @@
+//go:build mysql || pg
+
+package backup
+
+import "errors"
+
+// vacuumSQLiteInto 未编译 SQLite 驱动的构建无法在线备份 SQLite
+func vacuumSQLiteInto(dsn, dst string) error {
+	return errors.New("sqlite backup is not available in this build")
+}
@@ -0,0 +1,122 @@
+//go:build !mysql && !pg
+
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+type backupTestRow struct {
+	ID   uint `gorm:"primaryKey"`
+	Body string
+}
+
+func openBackupTestDB(t *testing.T, dsn string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func insertBackupTestRows(t *testing.T, db *gorm.DB, from, to int) {
+	for i := from; i < to; i++ {
+		require.NoError(t, db.Create(&backupTestRow{Body: fmt.Sprintf("row %d %s", i, strings.Repeat("x", 200))}).Error)
+	}
+}
+
+func countRestoredRows(t *testing.T, path string) int64 {
+	db := openBackupTestDB(t, path)
+	var n int64
+	require.NoError(t, db.Model(&backupTestRow{}).Count(&n).Error)
+	return n
+}
+
+func TestBackupSQLiteRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "live.db")
+	db := openBackupTestDB(t, src)
+	require.NoError(t, db.Exec("PRAGMA journal_mode = WAL").Error)
+	require.NoError(t, db.AutoMigrate(&backupTestRow{}))
+	dir := t.TempDir()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 全量 -> 增量 -> 增量 -> 链满后重新全量，备份期间源库连接保持打开
+	insertBackupTestRows(t, db, 0, 100)
+	full, err := BackupSQLite(src, dir, t0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, ArtifactFull, full.Kind)
+	assert.Equal(t, full.PageCount, full.Pages)
+
+	insertBackupTestRows(t, db, 100, 150)
+	inc1, err := BackupSQLite(src, dir, t0.Add(time.Hour), 3)
+	require.NoError(t, err)
+	assert.Equal(t, ArtifactIncremental, inc1.Kind)
+	assert.Less(t, inc1.Pages, inc1.PageCount)
+
+	require.NoError(t, db.Where("id <= ?", 20).Delete(&backupTestRow{}).Error)
+	inc2, err := BackupSQLite(src, dir, t0.Add(2*time.Hour), 3)
+	require.NoError(t, err)
+	assert.Equal(t, ArtifactIncremental, inc2.Kind)
+
+	insertBackupTestRows(t, db, 150, 160)
+	next, err := BackupSQLite(src, dir, t0.Add(3*time.Hour), 3)
+	require.NoError(t, err)
+	assert.Equal(t, ArtifactFull, next.Kind)
+
+	// 快照文件在备份后删除
+	_, err = os.Stat(filepath.Join(dir, sqliteSnapshotName))
+	assert.True(t, os.IsNotExist(err))
+
+	cases := []struct {
+		at   time.Time
+		file string
+		rows int64
+	}{
+		{t0, full.File, 100},
+		{t0.Add(90 * time.Minute), inc1.File, 150},
+		{t0.Add(2 * time.Hour), inc2.File, 130},
+		{time.Time{}, next.File, 140},
+	}
+	for _, tc := range cases {
+		dst := filepath.Join(t.TempDir(), "restored.db")
+		art, err := RestoreSQLite(dir, tc.at, dst)
+		require.NoError(t, err)
+		assert.Equal(t, tc.file, art.File)
+		assert.Equal(t, tc.rows, countRestoredRows(t, dst))
+	}
+}
+
+func TestRestoreSQLiteErrors(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "live.db")
+	db := openBackupTestDB(t, src)
+	require.NoError(t, db.AutoMigrate(&backupTestRow{}))
+	insertBackupTestRows(t, db, 0, 10)
+	dir := t.TempDir()
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := BackupSQLite(src, dir, t0, 0)
+	require.NoError(t, err)
+
+	// 早于第一次备份
+	_, err = RestoreSQLite(dir, t0.Add(-time.Minute), filepath.Join(t.TempDir(), "restored.db"))
+	assert.Error(t, err)
+
+	// 不覆盖已有文件
+	_, err = RestoreSQLite(dir, time.Time{}, src)
+	assert.Error(t, err)
+
+	// 内存数据库无法备份
+	_, err = BackupSQLite("file::memory:", t.TempDir(), t0, 0)
+	assert.Error(t, err)
+}
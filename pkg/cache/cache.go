@@ -40,6 +40,15 @@ type Cache interface {
 	// GetWithTTL 获取值并返回剩余TTL
 	GetWithTTL(ctx context.Context, key string) (interface{}, time.Duration, bool)
 
+	// SetWithTags 设置缓存值并关联标签，之后可通过 InvalidateTag 批量删除
+	SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error
+
+	// InvalidateTag 删除与标签关联的所有键
+	InvalidateTag(ctx context.Context, tags ...string) error
+
+	// DeleteByPrefix 删除指定前缀的所有键，返回删除数量
+	DeleteByPrefix(ctx context.Context, prefix string) (int, error)
+
 	// Close 关闭缓存连接
 	Close() error
 }
@@ -108,6 +117,15 @@ type Options struct {
 
 	// 本地缓存过期时间（通常比分布式缓存短）
 	LocalExpiration time.Duration
+
+	// 跨节点失效通知的 Redis 频道，为空时不广播，其他节点的本地缓存只能等待过期
+	InvalidationChannel string
+
+	// 每条失效消息最多携带的键数，待发送的键达到该数量时立即发送
+	InvalidationBatchSize int
+
+	// 失效消息的合并发送间隔
+	InvalidationInterval time.Duration
 }
 
 // DefaultOptions 默认选项
@@ -116,5 +134,9 @@ func DefaultOptions() *Options {
 		Expiration:      5 * time.Minute,
 		UseLocalCache:   true,
 		LocalExpiration: 1 * time.Minute,
+
+		InvalidationChannel:   "cache:invalidate",
+		InvalidationBatchSize: 100,
+		InvalidationInterval:  10 * time.Millisecond,
 	}
 }
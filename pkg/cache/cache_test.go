@@ -2,8 +2,14 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
 )
 
 func TestLocalCache(t *testing.T) {
@@ -35,3 +41,379 @@ func TestLocalCache(t *testing.T) {
 		}
 	})
 }
+
+type cachedUser struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func TestModelCache(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&cachedUser{}); err != nil {
+		t.Fatal(err)
+	}
+	local := NewLocalCache(LocalConfig{MaxSize: 100, DefaultExpiration: time.Minute, CleanupInterval: time.Minute})
+	defer local.Close()
+	users, err := NewModel[cachedUser](local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Register(db); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	db.Create(&cachedUser{ID: 1, Name: "alice"})
+	db.Create(&cachedUser{ID: 2, Name: "bob"})
+
+	var queries atomic.Int32
+	db.Callback().Query().Before("gorm:query").Register("test:count", func(*gorm.DB) { queries.Add(1) })
+	get := func(id uint) string {
+		t.Helper()
+		u, err := users.GetByID(ctx, db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Name
+	}
+
+	if get(1) != "alice" || get(1) != "alice" || queries.Load() != 1 {
+		t.Fatalf("expected one query, got %d", queries.Load())
+	}
+	if _, err := users.GetByID(ctx, db, 99); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// 按主键更新只失效该记录
+	get(2)
+	db.Model(&cachedUser{ID: 1}).Update("name", "alice2")
+	before := queries.Load()
+	if get(1) != "alice2" || get(2) != "bob" || queries.Load() != before+1 {
+		t.Fatalf("expected only user 1 to reload, got %d queries", queries.Load()-before)
+	}
+
+	// 按条件更新清空全部
+	db.Model(&cachedUser{}).Where("id > 0").Update("name", "x")
+	if get(1) != "x" || get(2) != "x" {
+		t.Fatal("expected condition update to invalidate all")
+	}
+
+	db.Delete(&cachedUser{ID: 2})
+	if _, err := users.GetByID(ctx, db, 2); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected deleted user to miss, got %v", err)
+	}
+}
+
+// memoryBus 进程内的失效通道，模拟 Redis pub/sub
+type memoryBus struct {
+	mu   sync.Mutex
+	subs []chan []byte
+	sent int
+}
+
+func (b *memoryBus) Publish(ctx context.Context, channel string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent++
+	for _, ch := range b.subs {
+		ch <- payload
+	}
+	return nil
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	ch := make(chan []byte, 64)
+	b.mu.Lock()
+	b.subs = append(b.subs, ch)
+	b.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.subs {
+			if sub == ch {
+				b.subs = append(b.subs[:i], b.subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (b *memoryBus) published() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sent
+}
+
+func TestLayeredCacheInvalidation(t *testing.T) {
+	localConfig := LocalConfig{MaxSize: 100, DefaultExpiration: time.Minute, CleanupInterval: time.Minute}
+	// 两个节点共享同一个“分布式”缓存
+	shared := NewLocalCache(localConfig)
+	bus := &memoryBus{}
+	options := DefaultOptions()
+	options.InvalidationBatchSize = 2
+	options.InvalidationInterval = 5 * time.Millisecond
+
+	newNode := func() *layeredCache {
+		lc, err := newLayeredCache(NewLocalCache(localConfig), shared, options, bus)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lc
+	}
+	nodeA, nodeB := newNode(), newNode()
+	defer nodeA.Close()
+	defer nodeB.Close()
+	ctx := context.Background()
+
+	eventually := func(cond func() bool, msg string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal(msg)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	localHas := func(lc *layeredCache, key string) bool {
+		_, ok := lc.local.Get(ctx, key)
+		return ok
+	}
+
+	t.Run("Set invalidates other nodes", func(t *testing.T) {
+		if err := nodeA.Set(ctx, "user:1", "v1", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := nodeB.Get(ctx, "user:1"); v != "v1" {
+			t.Fatalf("expected v1, got %v", v)
+		}
+		if err := nodeA.Set(ctx, "user:1", "v2", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		eventually(func() bool { return !localHas(nodeB, "user:1") }, "node B kept stale local entry")
+		if v, _ := nodeB.Get(ctx, "user:1"); v != "v2" {
+			t.Fatalf("expected v2, got %v", v)
+		}
+		// 本节点不处理自己发出的失效消息
+		if !localHas(nodeA, "user:1") {
+			t.Fatal("node A dropped its own local entry")
+		}
+	})
+
+	t.Run("Delete invalidates other nodes", func(t *testing.T) {
+		nodeA.Set(ctx, "user:2", "v", time.Minute)
+		nodeB.Get(ctx, "user:2")
+		eventually(func() bool { return localHas(nodeB, "user:2") }, "node B missing entry")
+		if err := nodeA.Delete(ctx, "user:2"); err != nil {
+			t.Fatal(err)
+		}
+		eventually(func() bool { return !localHas(nodeB, "user:2") }, "node B kept deleted entry")
+	})
+
+	t.Run("Batching", func(t *testing.T) {
+		before := bus.published()
+		nodeA.DeleteMulti(ctx, "k1", "k2", "k3", "k4", "k5")
+		// 5 个键按每批 2 个拆成 3 条消息
+		eventually(func() bool { return bus.published()-before == 3 }, "unexpected message count")
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		nodeB.local.Set(ctx, "user:3", "v", time.Minute)
+		if err := nodeA.Clear(ctx); err != nil {
+			t.Fatal(err)
+		}
+		eventually(func() bool { return !localHas(nodeB, "user:3") }, "node B not cleared")
+	})
+}
+
+type typedProfile struct {
+	ID   uint     `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func TestTypedCache(t *testing.T) {
+	local := NewLocalCache(LocalConfig{MaxSize: 100, DefaultExpiration: time.Minute, CleanupInterval: time.Minute})
+	defer local.Close()
+	profiles := NewTypedCache[typedProfile](local, WithKeyPrefix("profile:"))
+	ctx := context.Background()
+
+	t.Run("Set and Get", func(t *testing.T) {
+		want := typedProfile{ID: 1, Name: "alice", Tags: []string{"admin"}}
+		if err := profiles.Set(ctx, "1", want, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := profiles.Get(ctx, "1")
+		if !ok || got.Name != want.Name || len(got.Tags) != 1 {
+			t.Fatalf("expected %+v, got %+v (%v)", want, got, ok)
+		}
+		// 底层按前缀保存编码后的字符串
+		if raw, _ := local.Get(ctx, "profile:1"); raw != `{"id":1,"name":"alice","tags":["admin"]}` {
+			t.Fatalf("unexpected raw value %v", raw)
+		}
+		if _, ok := profiles.Get(ctx, "missing"); ok {
+			t.Fatal("expected miss")
+		}
+	})
+
+	t.Run("Undecodable value is a miss", func(t *testing.T) {
+		local.Set(ctx, "profile:bad", 42, time.Minute)
+		if _, ok := profiles.Get(ctx, "bad"); ok {
+			t.Fatal("expected miss for foreign value")
+		}
+	})
+
+	t.Run("GetOrLoad", func(t *testing.T) {
+		var loads atomic.Int32
+		load := func(ctx context.Context) (typedProfile, error) {
+			loads.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return typedProfile{ID: 2, Name: "bob"}, nil
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if p, err := profiles.GetOrLoad(ctx, "2", load); err != nil || p.Name != "bob" {
+					t.Errorf("unexpected result %+v, %v", p, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := loads.Load(); n != 1 {
+			t.Fatalf("expected a single load, got %d", n)
+		}
+		if _, err := profiles.GetOrLoad(ctx, "2", load); err != nil || loads.Load() != 1 {
+			t.Fatal("expected cached value")
+		}
+
+		errLoad := errors.New("db down")
+		if _, err := profiles.GetOrLoad(ctx, "3", func(ctx context.Context) (typedProfile, error) {
+			return typedProfile{}, errLoad
+		}); !errors.Is(err, errLoad) {
+			t.Fatalf("expected load error, got %v", err)
+		}
+		if local.Exists(ctx, "profile:3") {
+			t.Fatal("failed load must not be cached")
+		}
+	})
+
+	t.Run("Custom codec", func(t *testing.T) {
+		counts := NewTypedCache[int](local, WithCodec(wrapCodec{}))
+		if err := counts.Set(ctx, "n", 7, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if raw, _ := local.Get(ctx, "n"); raw != "<7>" {
+			t.Fatalf("codec not used, raw %v", raw)
+		}
+		if n, ok := counts.Get(ctx, "n"); !ok || n != 7 {
+			t.Fatalf("expected 7, got %d", n)
+		}
+	})
+}
+
+// wrapCodec 用尖括号包裹 JSON，验证自定义 Codec 生效
+type wrapCodec struct{}
+
+func (wrapCodec) Marshal(v any) ([]byte, error) {
+	data, err := JSONCodec.Marshal(v)
+	return append(append([]byte("<"), data...), '>'), err
+}
+
+func (wrapCodec) Unmarshal(data []byte, v any) error {
+	return JSONCodec.Unmarshal(data[1:len(data)-1], v)
+}
+
+func TestTagAndPrefixInvalidation(t *testing.T) {
+	localConfig := LocalConfig{MaxSize: 100, DefaultExpiration: time.Minute, CleanupInterval: time.Minute}
+	ctx := context.Background()
+
+	for name, c := range map[string]Cache{"local": NewLocalCache(localConfig), "gocache": NewGoCache(localConfig)} {
+		t.Run(name, func(t *testing.T) {
+			defer c.Close()
+			c.SetWithTags(ctx, "profile:42", "p", time.Minute, "user:42")
+			c.SetWithTags(ctx, "groups:42", "g", time.Minute, "user:42", "groups")
+			c.SetWithTags(ctx, "profile:7", "p", time.Minute, "user:7")
+			c.Set(ctx, "other", "o", time.Minute)
+
+			if err := c.InvalidateTag(ctx, "user:42"); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range map[string]bool{"profile:42": false, "groups:42": false, "profile:7": true, "other": true} {
+				if got := c.Exists(ctx, key); got != want {
+					t.Errorf("%s exists = %v, want %v", key, got, want)
+				}
+			}
+			// 已失效的标签再次失效不报错，键重新写入后不受旧标签影响
+			c.Set(ctx, "groups:42", "g", time.Minute)
+			if err := c.InvalidateTag(ctx, "groups", "missing"); err != nil {
+				t.Fatal(err)
+			}
+			if !c.Exists(ctx, "groups:42") {
+				t.Error("untagged key removed by stale tag")
+			}
+
+			c.Set(ctx, "profile:8", "p", time.Minute)
+			n, err := c.DeleteByPrefix(ctx, "profile:")
+			if err != nil || n != 2 {
+				t.Fatalf("expected 2 deleted, got %d (%v)", n, err)
+			}
+			if !c.Exists(ctx, "other") || c.Exists(ctx, "profile:7") {
+				t.Error("prefix delete removed wrong keys")
+			}
+			if _, err := c.DeleteByPrefix(ctx, ""); err != ErrEmptyPrefix {
+				t.Errorf("expected ErrEmptyPrefix, got %v", err)
+			}
+		})
+	}
+
+	t.Run("layered", func(t *testing.T) {
+		shared := NewLocalCache(localConfig)
+		bus := &memoryBus{}
+		options := DefaultOptions()
+		options.InvalidationInterval = 5 * time.Millisecond
+		newNode := func() *layeredCache {
+			lc, err := newLayeredCache(NewLocalCache(localConfig), shared, options, bus)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return lc
+		}
+		nodeA, nodeB := newNode(), newNode()
+		defer nodeA.Close()
+		defer nodeB.Close()
+		eventually := func(cond func() bool, msg string) {
+			t.Helper()
+			deadline := time.Now().Add(time.Second)
+			for !cond() {
+				if time.Now().After(deadline) {
+					t.Fatal(msg)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		nodeA.SetWithTags(ctx, "feed:1", "f", time.Minute, "user:1")
+		nodeB.Get(ctx, "feed:1")
+		if err := nodeA.InvalidateTag(ctx, "user:1"); err != nil {
+			t.Fatal(err)
+		}
+		if shared.Exists(ctx, "feed:1") || nodeA.local.Exists(ctx, "feed:1") {
+			t.Fatal("tagged key not deleted")
+		}
+		eventually(func() bool { return !nodeB.local.Exists(ctx, "feed:1") }, "node B kept tagged entry")
+
+		nodeA.Set(ctx, "feed:2", "f", time.Minute)
+		nodeB.Get(ctx, "feed:2")
+		if _, err := nodeA.DeleteByPrefix(ctx, "feed:"); err != nil {
+			t.Fatal(err)
+		}
+		eventually(func() bool { return !nodeB.local.Exists(ctx, "feed:2") }, "node B kept prefixed entry")
+	})
+}
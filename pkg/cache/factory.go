@@ -59,11 +59,35 @@ func NewLayeredCache(config Config, options *Options) (Cache, error) {
 		return nil, fmt.Errorf("unsupported distributed cache type: %s", config.Type)
 	}
 
-	return &layeredCache{
-		local:       localCache,
-		distributed: distributedCache,
+	var bus InvalidationBus
+	if rc, ok := distributedCache.(*redisCache); ok && options.InvalidationChannel != "" {
+		bus = NewRedisInvalidationBus(rc.client)
+	}
+
+	lc, err := newLayeredCache(localCache, distributedCache, options, bus)
+	if err != nil {
+		localCache.Close()
+		distributedCache.Close()
+		return nil, fmt.Errorf("failed to subscribe cache invalidation: %w", err)
+	}
+	return lc, nil
+}
+
+// newLayeredCache 组装分层缓存，bus 不为空时通过它在节点间广播本地缓存失效
+func newLayeredCache(local, distributed Cache, options *Options, bus InvalidationBus) (*layeredCache, error) {
+	lc := &layeredCache{
+		local:       local,
+		distributed: distributed,
 		options:     options,
-	}, nil
+	}
+	if bus != nil && options.InvalidationChannel != "" {
+		inv, err := newInvalidator(bus, local, options)
+		if err != nil {
+			return nil, err
+		}
+		lc.invalidator = inv
+	}
+	return lc, nil
 }
 
 // layeredCache 分层缓存实现
@@ -71,6 +95,14 @@ type layeredCache struct {
 	local       Cache
 	distributed Cache
 	options     *Options
+	invalidator *invalidator
+}
+
+// invalidate 通知其他节点删除本地缓存中的键
+func (lc *layeredCache) invalidate(keys ...string) {
+	if lc.invalidator != nil {
+		lc.invalidator.invalidate(keys...)
+	}
 }
 
 // Get 从本地缓存获取，如果没有则从分布式缓存获取并回填本地缓存
@@ -97,6 +129,8 @@ func (lc *layeredCache) Set(ctx context.Context, key string, value interface{},
 		return err
 	}
 
+	lc.invalidate(key)
+
 	// 设置到本地缓存
 	return lc.local.Set(ctx, key, value, lc.options.LocalExpiration)
 }
@@ -109,7 +143,12 @@ func (lc *layeredCache) Delete(ctx context.Context, key string) error {
 	}
 
 	// 删除分布式缓存
-	return lc.distributed.Delete(ctx, key)
+	if err := lc.distributed.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	lc.invalidate(key)
+	return nil
 }
 
 // Exists 检查键是否存在
@@ -125,7 +164,14 @@ func (lc *layeredCache) Clear(ctx context.Context) error {
 	}
 
 	// 清空分布式缓存
-	return lc.distributed.Clear(ctx)
+	if err := lc.distributed.Clear(ctx); err != nil {
+		return err
+	}
+
+	if lc.invalidator != nil {
+		return lc.invalidator.invalidateAll(ctx)
+	}
+	return nil
 }
 
 // GetMulti 批量获取
@@ -166,6 +212,12 @@ func (lc *layeredCache) SetMulti(ctx context.Context, data map[string]interface{
 		return err
 	}
 
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	lc.invalidate(keys...)
+
 	// 设置到本地缓存
 	return lc.local.SetMulti(ctx, data, lc.options.LocalExpiration)
 }
@@ -178,7 +230,12 @@ func (lc *layeredCache) DeleteMulti(ctx context.Context, keys ...string) error {
 	}
 
 	// 删除分布式缓存
-	return lc.distributed.DeleteMulti(ctx, keys...)
+	if err := lc.distributed.DeleteMulti(ctx, keys...); err != nil {
+		return err
+	}
+
+	lc.invalidate(keys...)
+	return nil
 }
 
 // Increment 自增
@@ -189,6 +246,8 @@ func (lc *layeredCache) Increment(ctx context.Context, key string, value int64)
 		return 0, err
 	}
 
+	lc.invalidate(key)
+
 	// 更新本地缓存
 	lc.local.Set(ctx, key, result, lc.options.LocalExpiration)
 	return result, nil
@@ -202,6 +261,8 @@ func (lc *layeredCache) Decrement(ctx context.Context, key string, value int64)
 		return 0, err
 	}
 
+	lc.invalidate(key)
+
 	// 更新本地缓存
 	lc.local.Set(ctx, key, result, lc.options.LocalExpiration)
 	return result, nil
@@ -224,8 +285,60 @@ func (lc *layeredCache) GetWithTTL(ctx context.Context, key string) (interface{}
 	return nil, 0, false
 }
 
+// SetWithTags 写入分布式缓存并关联标签，本地缓存只保存值，标签失效时按分布式缓存中的关联删除
+func (lc *layeredCache) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	if err := lc.distributed.SetWithTags(ctx, key, value, expiration, tags...); err != nil {
+		return err
+	}
+	lc.invalidate(key)
+	return lc.local.Set(ctx, key, value, lc.options.LocalExpiration)
+}
+
+// InvalidateTag 删除标签关联的键，并通知其他节点删除本地缓存
+func (lc *layeredCache) InvalidateTag(ctx context.Context, tags ...string) error {
+	var keys []string
+	if tm, ok := lc.distributed.(tagLister); ok {
+		members, err := tm.tagMembers(ctx, tags...)
+		if err != nil {
+			return err
+		}
+		keys = members
+	}
+	if err := lc.distributed.InvalidateTag(ctx, tags...); err != nil {
+		return err
+	}
+	if err := lc.local.DeleteMulti(ctx, keys...); err != nil {
+		return err
+	}
+	lc.invalidate(keys...)
+	return nil
+}
+
+// DeleteByPrefix 删除两层缓存中指定前缀的键，返回分布式缓存中删除的数量
+func (lc *layeredCache) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+	n, err := lc.distributed.DeleteByPrefix(ctx, prefix)
+	if err != nil {
+		return n, err
+	}
+	if _, err := lc.local.DeleteByPrefix(ctx, prefix); err != nil {
+		return n, err
+	}
+	if lc.invalidator != nil {
+		return n, lc.invalidator.invalidatePrefix(ctx, prefix)
+	}
+	return n, nil
+}
+
 // Close 关闭缓存连接
 func (lc *layeredCache) Close() error {
+	// 发送剩余的失效消息并停止订阅
+	if lc.invalidator != nil {
+		lc.invalidator.close()
+	}
+
 	// 关闭本地缓存
 	if err := lc.local.Close(); err != nil {
 		return err
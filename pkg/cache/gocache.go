@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	gocache "github.com/patrickmn/go-cache"
@@ -10,6 +11,7 @@ import (
 // goCacheWrapper go-cache包装器
 type goCacheWrapper struct {
 	cache *gocache.Cache
+	tags  *tagIndex
 }
 
 // NewGoCache 创建基于go-cache的本地缓存
@@ -23,9 +25,15 @@ func NewGoCache(config LocalConfig) Cache {
 
 	// 设置最大项数（go-cache本身没有这个限制，但我们可以通过监控来实现）
 
-	return &goCacheWrapper{
+	gc := &goCacheWrapper{
 		cache: c,
+		tags:  newTagIndex(),
 	}
+	// 删除或过期时同步清除标签
+	c.OnEvicted(func(key string, _ interface{}) {
+		gc.tags.forget(key)
+	})
+	return gc
 }
 
 // Get 获取缓存值
@@ -57,6 +65,7 @@ func (gc *goCacheWrapper) Exists(ctx context.Context, key string) bool {
 // Clear 清空所有缓存
 func (gc *goCacheWrapper) Clear(ctx context.Context) error {
 	gc.cache.Flush()
+	gc.tags.reset()
 	return nil
 }
 
@@ -127,6 +136,37 @@ func (gc *goCacheWrapper) GetWithTTL(ctx context.Context, key string) (interface
 	return nil, 0, false
 }
 
+// SetWithTags 设置缓存值并关联标签
+func (gc *goCacheWrapper) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	gc.cache.Set(key, value, expiration)
+	gc.tags.add(key, tags...)
+	return nil
+}
+
+// InvalidateTag 删除与标签关联的所有键
+func (gc *goCacheWrapper) InvalidateTag(ctx context.Context, tags ...string) error {
+	return gc.DeleteMulti(ctx, gc.tags.take(tags...)...)
+}
+
+func (gc *goCacheWrapper) tagMembers(ctx context.Context, tags ...string) ([]string, error) {
+	return gc.tags.members(tags...), nil
+}
+
+// DeleteByPrefix 删除指定前缀的所有键
+func (gc *goCacheWrapper) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+	n := 0
+	for key := range gc.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			gc.cache.Delete(key)
+			n++
+		}
+	}
+	return n, nil
+}
+
 // Close 关闭缓存连接
 func (gc *goCacheWrapper) Close() error {
 	// go-cache不需要关闭连接
@@ -0,0 +1,241 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InvalidationBus 跨节点失效通知的发布订阅通道
+type InvalidationBus interface {
+	// Publish 向频道发布消息
+	Publish(ctx context.Context, channel string, payload []byte) error
+
+	// Subscribe 订阅频道，ctx 取消后返回的通道关闭
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+}
+
+// invalidationMessage 失效消息，Node 用于忽略本节点发出的消息
+type invalidationMessage struct {
+	Node   string   `json:"node"`
+	Keys   []string `json:"keys,omitempty"`
+	Prefix string   `json:"prefix,omitempty"`
+	Clear  bool     `json:"clear,omitempty"`
+}
+
+// redisBus 基于 Redis pub/sub 的失效通道
+type redisBus struct {
+	client *redis.Client
+}
+
+// NewRedisInvalidationBus 创建基于 Redis pub/sub 的失效通道
+func NewRedisInvalidationBus(client *redis.Client) InvalidationBus {
+	return &redisBus{client: client}
+}
+
+// Publish 发布消息
+func (b *redisBus) Publish(ctx context.Context, channel string, payload []byte) error {
+	return b.client.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe 订阅频道，连接断开时由 go-redis 自动重连
+func (b *redisBus) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	pubsub := b.client.Subscribe(ctx, channel)
+	// 等待订阅确认，确保返回后不会漏掉消息
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	out := make(chan []byte, 64)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// invalidator 收集本节点的写操作并批量广播失效消息，同时把其他节点的消息应用到本地缓存
+type invalidator struct {
+	bus      InvalidationBus
+	channel  string
+	node     string
+	local    Cache
+	batch    int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	notify  chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// newInvalidator 订阅失效频道并启动批量发送协程
+func newInvalidator(bus InvalidationBus, local Cache, options *Options) (*invalidator, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs, err := bus.Subscribe(ctx, options.InvalidationChannel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	inv := &invalidator{
+		bus:      bus,
+		channel:  options.InvalidationChannel,
+		node:     newNodeID(),
+		local:    local,
+		batch:    options.InvalidationBatchSize,
+		interval: options.InvalidationInterval,
+		pending:  make(map[string]struct{}),
+		notify:   make(chan struct{}, 1),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	if inv.batch <= 0 {
+		inv.batch = 100
+	}
+	if inv.interval <= 0 {
+		inv.interval = 10 * time.Millisecond
+	}
+
+	go inv.receive(ctx, msgs)
+	go inv.run(ctx)
+	return inv, nil
+}
+
+// invalidate 登记失效的键，积累到批量大小或到达发送间隔时广播
+func (inv *invalidator) invalidate(keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	inv.mu.Lock()
+	for _, key := range keys {
+		inv.pending[key] = struct{}{}
+	}
+	full := len(inv.pending) >= inv.batch
+	inv.mu.Unlock()
+
+	if full {
+		select {
+		case inv.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// invalidateAll 立即广播清空消息，并丢弃已被覆盖的待发送键
+func (inv *invalidator) invalidateAll(ctx context.Context) error {
+	inv.mu.Lock()
+	inv.pending = make(map[string]struct{})
+	inv.mu.Unlock()
+	return inv.publish(ctx, invalidationMessage{Node: inv.node, Clear: true})
+}
+
+// invalidatePrefix 立即广播按前缀删除的消息
+func (inv *invalidator) invalidatePrefix(ctx context.Context, prefix string) error {
+	return inv.publish(ctx, invalidationMessage{Node: inv.node, Prefix: prefix})
+}
+
+// run 按间隔或批量大小发送待失效的键
+func (inv *invalidator) run(ctx context.Context) {
+	defer close(inv.done)
+	ticker := time.NewTicker(inv.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-inv.notify:
+		}
+		inv.flush(ctx)
+	}
+}
+
+// flush 发送所有待失效的键，每条消息最多 batch 个
+func (inv *invalidator) flush(ctx context.Context) {
+	inv.mu.Lock()
+	if len(inv.pending) == 0 {
+		inv.mu.Unlock()
+		return
+	}
+	keys := make([]string, 0, len(inv.pending))
+	for key := range inv.pending {
+		keys = append(keys, key)
+	}
+	inv.pending = make(map[string]struct{})
+	inv.mu.Unlock()
+
+	for start := 0; start < len(keys); start += inv.batch {
+		end := start + inv.batch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		// 发送失败时其他节点的本地缓存只能等待 LocalExpiration 过期
+		_ = inv.publish(ctx, invalidationMessage{Node: inv.node, Keys: keys[start:end]})
+	}
+}
+
+func (inv *invalidator) publish(ctx context.Context, msg invalidationMessage) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return inv.bus.Publish(ctx, inv.channel, payload)
+}
+
+// receive 把其他节点的失效消息应用到本地缓存
+func (inv *invalidator) receive(ctx context.Context, msgs <-chan []byte) {
+	for payload := range msgs {
+		var msg invalidationMessage
+		if err := json.Unmarshal(payload, &msg); err != nil || msg.Node == inv.node {
+			continue
+		}
+		if msg.Clear {
+			_ = inv.local.Clear(ctx)
+			continue
+		}
+		if msg.Prefix != "" {
+			_, _ = inv.local.DeleteByPrefix(ctx, msg.Prefix)
+			continue
+		}
+		_ = inv.local.DeleteMulti(ctx, msg.Keys...)
+	}
+}
+
+// close 发送剩余的失效键后停止订阅
+func (inv *invalidator) close() {
+	inv.cancel()
+	<-inv.done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	inv.flush(ctx)
+}
+
+func newNodeID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
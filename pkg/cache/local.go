@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"time"
 )
@@ -10,6 +11,7 @@ import (
 type localCache struct {
 	config LocalConfig
 	cache  *lruCache
+	tags   *tagIndex
 	mu     sync.RWMutex
 }
 
@@ -37,6 +39,7 @@ func NewLocalCache(config LocalConfig) Cache {
 			items:   make(map[string]*cacheItem),
 			keys:    make([]string, 0),
 		},
+		tags: newTagIndex(),
 	}
 
 	// 启动清理协程
@@ -92,6 +95,7 @@ func (lc *localCache) Delete(ctx context.Context, key string) error {
 	defer lc.mu.Unlock()
 
 	lc.cache.delete(key)
+	lc.tags.forget(key)
 	return nil
 }
 
@@ -120,6 +124,7 @@ func (lc *localCache) Clear(ctx context.Context) error {
 	defer lc.mu.Unlock()
 
 	lc.cache.clear()
+	lc.tags.reset()
 	return nil
 }
 
@@ -242,6 +247,44 @@ func (lc *localCache) GetWithTTL(ctx context.Context, key string) (interface{},
 	return item.value, ttl, true
 }
 
+// SetWithTags 设置缓存值并关联标签
+func (lc *localCache) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	if err := lc.Set(ctx, key, value, expiration); err != nil {
+		return err
+	}
+	lc.tags.add(key, tags...)
+	return nil
+}
+
+// InvalidateTag 删除与标签关联的所有键
+func (lc *localCache) InvalidateTag(ctx context.Context, tags ...string) error {
+	return lc.DeleteMulti(ctx, lc.tags.take(tags...)...)
+}
+
+func (lc *localCache) tagMembers(ctx context.Context, tags ...string) ([]string, error) {
+	return lc.tags.members(tags...), nil
+}
+
+// DeleteByPrefix 删除指定前缀的所有键
+func (lc *localCache) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+	lc.mu.Lock()
+	var keys []string
+	for key := range lc.cache.items {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		lc.cache.delete(key)
+	}
+	lc.mu.Unlock()
+	lc.tags.forget(keys...)
+	return len(keys), nil
+}
+
 // Close 关闭缓存连接
 func (lc *localCache) Close() error {
 	// 本地缓存不需要关闭连接
@@ -269,6 +312,11 @@ func (lc *localCache) cleanup() {
 			lc.cache.delete(key)
 		}
 	}
+	// 同时清除过期或被淘汰键的标签
+	lc.tags.prune(func(key string) bool {
+		_, ok := lc.cache.items[key]
+		return ok
+	})
 }
 
 // LRU缓存方法实现
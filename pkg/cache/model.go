@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Model GORM 模型的读穿透缓存，按主键缓存单条记录，减少各处手写的 查缓存-回表-写回 逻辑。
+// Register 在 db 上安装更新、删除回调自动失效；按条件批量更新/删除不携带主键，会清空该模型的全部缓存
+type Model[T any] struct {
+	typed  *TypedCache[T]
+	cache  Cache
+	prefix string
+
+	schema *schema.Schema
+	pk     *schema.Field
+}
+
+// NewModel 创建模型缓存，键前缀默认为 model:表名:，可通过 WithKeyPrefix 覆盖
+func NewModel[T any](c Cache, opts ...TypedOption) (*Model[T], error) {
+	var zero T
+	s, err := schema.Parse(&zero, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return nil, fmt.Errorf("model %T has no primary key", zero)
+	}
+	m := &Model[T]{cache: c, schema: s, pk: s.PrioritizedPrimaryField}
+	opts = append([]TypedOption{WithKeyPrefix("model:" + s.Table + ":")}, opts...)
+	m.typed = NewTypedCache[T](c, opts...)
+	m.prefix = m.typed.prefix
+	return m, nil
+}
+
+// GetByID 按主键读取，未命中时回表并写回；记录不存在时返回 gorm.ErrRecordNotFound，不缓存
+func (m *Model[T]) GetByID(ctx context.Context, db *gorm.DB, id any) (*T, error) {
+	row, err := m.typed.GetOrLoad(ctx, fmt.Sprint(id), func(ctx context.Context) (T, error) {
+		var row T
+		err := db.WithContext(ctx).Where(m.pk.DBName+" = ?", id).Take(&row).Error
+		return row, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// Invalidate 删除指定主键的缓存
+func (m *Model[T]) Invalidate(ctx context.Context, ids ...any) error {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, m.typed.key(fmt.Sprint(id)))
+	}
+	return m.cache.DeleteMulti(ctx, keys...)
+}
+
+// InvalidateAll 删除该模型的全部缓存
+func (m *Model[T]) InvalidateAll(ctx context.Context) error {
+	_, err := m.cache.DeleteByPrefix(ctx, m.prefix)
+	return err
+}
+
+// Register 在 db 上安装更新、删除后的失效回调，同一模型只能注册一次
+func (m *Model[T]) Register(db *gorm.DB) error {
+	name := "cache:model_" + m.schema.Table
+	cb := db.Callback()
+	if err := cb.Update().After("gorm:update").Register(name+"_update", m.afterChange); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register(name+"_delete", m.afterChange)
+}
+
+// afterChange 从语句目标中提取主键失效，取不到主键时清空该模型的缓存
+func (m *Model[T]) afterChange(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != m.schema.Table {
+		return
+	}
+	ctx := tx.Statement.Context
+	var ids []any
+	collect := func(rv reflect.Value) bool {
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return false
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return false
+		}
+		id, zero := m.pk.ValueOf(ctx, rv)
+		if zero {
+			return false
+		}
+		ids = append(ids, id)
+		return true
+	}
+
+	complete := true
+	rv := tx.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for n := 0; n < rv.Len(); n++ {
+			complete = collect(rv.Index(n)) && complete
+		}
+	default:
+		complete = collect(rv)
+	}
+
+	// 失效不依赖业务请求的 ctx，避免请求取消导致缓存残留
+	bg, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var err error
+	if complete && len(ids) > 0 {
+		err = m.Invalidate(bg, ids...)
+	} else {
+		err = m.InvalidateAll(bg)
+	}
+	if err != nil {
+		tx.Logger.Error(ctx, "cache: invalidate model %s failed: %v", m.schema.Table, err)
+	}
+}
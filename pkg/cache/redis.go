@@ -178,6 +178,119 @@ func (rc *redisCache) GetWithTTL(ctx context.Context, key string) (interface{},
 	return value, ttl.Val(), true
 }
 
+// tagKeyPrefix 标签集合的键前缀，集合成员为关联的缓存键
+const tagKeyPrefix = "cache:tag:"
+
+// redisBatchSize SCAN 每次返回的建议数量及每次 DEL 的键数
+const redisBatchSize = 500
+
+// tagScript 把键加入各标签集合；集合过期时间取成员中最长的，过期时间为 0 的成员使集合不过期
+var tagScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+for _, tag in ipairs(KEYS) do
+	local existed = redis.call('EXISTS', tag)
+	redis.call('SADD', tag, ARGV[1])
+	if ttl <= 0 then
+		redis.call('PERSIST', tag)
+	else
+		local cur = redis.call('PTTL', tag)
+		if existed == 0 or (cur >= 0 and cur < ttl) then
+			redis.call('PEXPIRE', tag, ttl)
+		end
+	end
+end
+return 1
+`)
+
+func tagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+// SetWithTags 设置缓存值并把键加入各标签集合
+func (rc *redisCache) SetWithTags(ctx context.Context, key string, value interface{}, expiration time.Duration, tags ...string) error {
+	if err := rc.Set(ctx, key, value, expiration); err != nil {
+		return err
+	}
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag != "" {
+			tagKeys = append(tagKeys, tagKey(tag))
+		}
+	}
+	if len(tagKeys) == 0 {
+		return nil
+	}
+	return tagScript.Run(ctx, rc.client, tagKeys, key, expiration.Milliseconds()).Err()
+}
+
+// tagMembers 返回标签集合中的键（去重）
+func (rc *redisCache) tagMembers(ctx context.Context, tags ...string) ([]string, error) {
+	seen := make(map[string]struct{})
+	var keys []string
+	for _, tag := range tags {
+		members, err := rc.client.SMembers(ctx, tagKey(tag)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range members {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// InvalidateTag 删除标签集合中的所有键及集合本身
+func (rc *redisCache) InvalidateTag(ctx context.Context, tags ...string) error {
+	keys, err := rc.tagMembers(ctx, tags...)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		keys = append(keys, tagKey(tag))
+	}
+	return rc.deleteInBatches(ctx, keys)
+}
+
+// DeleteByPrefix 通过 SCAN 遍历前缀匹配的键并分批删除，不阻塞 Redis
+func (rc *redisCache) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+	pattern := escapeGlob(prefix) + "*"
+	var cursor uint64
+	deleted := 0
+	for {
+		keys, next, err := rc.client.Scan(ctx, cursor, pattern, redisBatchSize).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(keys) > 0 {
+			n, err := rc.client.Del(ctx, keys...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += int(n)
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+func (rc *redisCache) deleteInBatches(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += redisBatchSize {
+		end := min(start+redisBatchSize, len(keys))
+		if err := rc.client.Del(ctx, keys[start:end]...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close 关闭缓存连接
 func (rc *redisCache) Close() error {
 	return rc.client.Close()
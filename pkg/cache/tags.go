@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrEmptyPrefix DeleteByPrefix 的前缀为空，清空全部请使用 Clear
+var ErrEmptyPrefix = errors.New("cache: empty prefix")
+
+// tagLister 可列出标签关联键的缓存，分层缓存据此删除各节点的本地副本
+type tagLister interface {
+	tagMembers(ctx context.Context, tags ...string) ([]string, error)
+}
+
+// tagIndex 本地缓存的标签索引，记录标签与键的双向关系
+type tagIndex struct {
+	mu   sync.Mutex
+	tags map[string]map[string]struct{}
+	keys map[string]map[string]struct{}
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{
+		tags: make(map[string]map[string]struct{}),
+		keys: make(map[string]map[string]struct{}),
+	}
+}
+
+// add 把键关联到标签
+func (ti *tagIndex) add(key string, tags ...string) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		if ti.tags[tag] == nil {
+			ti.tags[tag] = make(map[string]struct{})
+		}
+		ti.tags[tag][key] = struct{}{}
+		if ti.keys[key] == nil {
+			ti.keys[key] = make(map[string]struct{})
+		}
+		ti.keys[key][tag] = struct{}{}
+	}
+}
+
+// members 返回标签关联的键（去重）
+func (ti *tagIndex) members(tags ...string) []string {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	seen := make(map[string]struct{})
+	var keys []string
+	for _, tag := range tags {
+		for key := range ti.tags[tag] {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// take 移除标签并返回其关联的键
+func (ti *tagIndex) take(tags ...string) []string {
+	keys := ti.members(tags...)
+	ti.forget(keys...)
+	return keys
+}
+
+// forget 从索引中移除已删除的键
+func (ti *tagIndex) forget(keys ...string) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	for _, key := range keys {
+		for tag := range ti.keys[key] {
+			delete(ti.tags[tag], key)
+			if len(ti.tags[tag]) == 0 {
+				delete(ti.tags, tag)
+			}
+		}
+		delete(ti.keys, key)
+	}
+}
+
+// prune 移除已不在缓存中的键（过期或被淘汰）
+func (ti *tagIndex) prune(exists func(key string) bool) {
+	ti.mu.Lock()
+	var stale []string
+	for key := range ti.keys {
+		if !exists(key) {
+			stale = append(stale, key)
+		}
+	}
+	ti.mu.Unlock()
+	ti.forget(stale...)
+}
+
+// reset 清空索引
+func (ti *tagIndex) reset() {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	ti.tags = make(map[string]map[string]struct{})
+	ti.keys = make(map[string]map[string]struct{})
+}
+
+// escapeGlob 转义 Redis 匹配模式中的特殊字符
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
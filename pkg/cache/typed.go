@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Codec 类型化缓存的序列化方式
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// JSONCodec 默认的 JSON 序列化
+var JSONCodec Codec = jsonCodec{}
+
+// TypedCache 在 Cache 之上提供类型安全的读写，值经 Codec 编码后以字符串保存，
+// 本地缓存与 Redis 中的格式一致，读取时无需类型断言
+type TypedCache[T any] struct {
+	cache      Cache
+	codec      Codec
+	prefix     string
+	expiration time.Duration
+	group      singleflight.Group
+}
+
+// TypedOption 类型化缓存选项
+type TypedOption func(*typedOptions)
+
+type typedOptions struct {
+	codec      Codec
+	prefix     string
+	expiration time.Duration
+}
+
+// WithCodec 指定序列化方式，默认 JSONCodec
+func WithCodec(codec Codec) TypedOption {
+	return func(o *typedOptions) { o.codec = codec }
+}
+
+// WithKeyPrefix 为所有键添加前缀，避免不同类型的值共用同一个键
+func WithKeyPrefix(prefix string) TypedOption {
+	return func(o *typedOptions) { o.prefix = prefix }
+}
+
+// WithExpiration 设置 GetOrLoad 写回时的过期时间
+func WithExpiration(expiration time.Duration) TypedOption {
+	return func(o *typedOptions) { o.expiration = expiration }
+}
+
+// NewTypedCache 创建类型化缓存
+func NewTypedCache[T any](cache Cache, opts ...TypedOption) *TypedCache[T] {
+	o := typedOptions{codec: JSONCodec, expiration: 5 * time.Minute}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.codec == nil {
+		o.codec = JSONCodec
+	}
+	return &TypedCache[T]{cache: cache, codec: o.codec, prefix: o.prefix, expiration: o.expiration}
+}
+
+func (tc *TypedCache[T]) key(key string) string {
+	return tc.prefix + key
+}
+
+// Get 获取缓存值，不存在或无法解码时返回 false
+func (tc *TypedCache[T]) Get(ctx context.Context, key string) (T, bool) {
+	var value T
+	raw, ok := tc.cache.Get(ctx, tc.key(key))
+	if !ok {
+		return value, false
+	}
+	if err := tc.decode(raw, &value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// Set 编码后写入缓存
+func (tc *TypedCache[T]) Set(ctx context.Context, key string, value T, expiration time.Duration) error {
+	data, err := tc.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode cache value %s: %w", key, err)
+	}
+	return tc.cache.Set(ctx, tc.key(key), string(data), expiration)
+}
+
+// Delete 删除缓存
+func (tc *TypedCache[T]) Delete(ctx context.Context, key string) error {
+	return tc.cache.Delete(ctx, tc.key(key))
+}
+
+// GetOrLoad 缓存未命中时调用 load 加载并写回，同一键的并发加载只执行一次；
+// load 返回错误时不写缓存
+func (tc *TypedCache[T]) GetOrLoad(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if value, ok := tc.Get(ctx, key); ok {
+		return value, nil
+	}
+	v, err, _ := tc.group.Do(key, func() (any, error) {
+		// 等待期间可能已被其他调用写入
+		if value, ok := tc.Get(ctx, key); ok {
+			return value, nil
+		}
+		value, err := load(ctx)
+		if err != nil {
+			return value, err
+		}
+		if err := tc.Set(ctx, key, value, tc.expiration); err != nil {
+			return value, err
+		}
+		return value, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+func (tc *TypedCache[T]) decode(raw any, value *T) error {
+	switch v := raw.(type) {
+	case string:
+		return tc.codec.Unmarshal([]byte(v), value)
+	case []byte:
+		return tc.codec.Unmarshal(v, value)
+	case T:
+		// 通过原始 Cache 直接写入的同类型值
+		*value = v
+		return nil
+	default:
+		return fmt.Errorf("unexpected cache value type %T", raw)
+	}
+}
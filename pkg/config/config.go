@@ -27,19 +27,62 @@ type Config struct {
 	LLMBaseURL       string `env:"LLM_BASE_URL"`
 	LLMModel         string `env:"LLM_MODEL"`
 	SearchEnabled    bool   `env:"SEARCH_ENABLED"`
+	SearchRequired   bool   `env:"SEARCH_REQUIRED"`
 	SearchPath       string `env:"SEARCH_PATH"`
+	SearchIndexDir   string `env:"SEARCH_INDEX_DIR"`
 	SearchBatchSize  int    `env:"SEARCH_BATCH_SIZE"`
+	SearchDriver     string `env:"SEARCH_DRIVER"`
+	SearchESAddrs    string `env:"SEARCH_ES_ADDRESSES"`
+	SearchESIndex    string `env:"SEARCH_ES_INDEX"`
+	SearchESUser     string `env:"SEARCH_ES_USERNAME"`
+	SearchESPass     string `env:"SEARCH_ES_PASSWORD"`
+	SearchESAPIKey   string `env:"SEARCH_ES_API_KEY"`
+	SearchFeedback   bool   `env:"SEARCH_FEEDBACK_ENABLED"`
+	SearchClickBoost int    `env:"SEARCH_CLICK_BOOST"`
+	SearchCacheTTL   int    `env:"SEARCH_CACHE_TTL"`
+	SearchSynonyms   string `env:"SEARCH_SYNONYMS_PATH"`
+	SearchStopwords  string `env:"SEARCH_STOPWORDS_PATH"`
+	SearchACLField   string `env:"SEARCH_ACL_FIELD"`
+	SearchMaskPII    bool   `env:"SEARCH_MASK_PII"`
+	SearchUnstored   string `env:"SEARCH_UNSTORED_FIELDS"`
 	MonitorPrefix    string `env:"MONITOR_PREFIX"`
+	MonitorSlowHTTP  int    `env:"MONITOR_SLOW_HTTP_MS"`
+	MonitorPersist   int    `env:"MONITOR_PERSIST_SECONDS"`
+	MonitorRetention int    `env:"MONITOR_RETENTION_DAYS"`
+	MonitorExplain   bool   `env:"MONITOR_EXPLAIN_ENABLED"`
 	LanguageEnabled  bool   `env:"LANGUAGE_ENABLED"`
 	APISecretKey     string `env:"API_SECRET_KEY"`
 	BackupEnabled    bool   `env:"BACKUP_ENABLED"`
 	BackupPath       string `env:"BACKUP_PATH"`
 	BackupSchedule   string `env:"BACKUP_SCHEDULE"`
+	BackupFullEvery  int    `env:"BACKUP_FULL_EVERY"`
+	BackupMaxAge     int    `env:"BACKUP_MAX_AGE_HOURS"`
+	QueueBackend     string `env:"QUEUE_BACKEND"`
+	QueueRedisAddr   string `env:"QUEUE_REDIS_ADDR"`
+	QueueRedisPass   string `env:"QUEUE_REDIS_PASSWORD"`
+	QueueRedisDB     int    `env:"QUEUE_REDIS_DB"`
+	QueueConcurrency int    `env:"QUEUE_CONCURRENCY"`
+	TraceExporter    string `env:"TRACE_EXPORTER"`
+	TraceEndpoint    string `env:"TRACE_EXPORTER_ENDPOINT"`
+	TraceServiceName string `env:"TRACE_SERVICE_NAME"`
+	RedactEnabled    bool   `env:"REDACT_ENABLED"`
+	RedactFields     string `env:"REDACT_FIELDS"`
+	RedactPatterns   string `env:"REDACT_PATTERNS"`
+	FixturesPath     string `env:"FIXTURES_PATH"`
+	FixturesEnvs     string `env:"FIXTURES_ENVIRONMENTS"`
+	GeoIPDBPath      string `env:"GEOIP_DB_PATH"`
+	GeoIPCacheSize   int    `env:"GEOIP_CACHE_SIZE"`
 }
 
 var GlobalConfig *Config
 
+// Load 加载全局配置
 func Load() error {
+	return LoadWithOptions(LoadOptions{})
+}
+
+// LoadWithOptions 按 内置默认值 < YAML 文件 < 环境变量 < 命令行参数 的优先级加载全局配置
+func LoadWithOptions(opts LoadOptions) error {
 	// 1. 根据环境加载 .env 文件
 	env := os.Getenv("APP_ENV")
 	if env == "" {
@@ -50,7 +93,12 @@ func Load() error {
 		log.Printf("Failed to load .env file: %v", err)
 	}
 
-	// 2. 加载全局配置
+	// 2. 注册内置默认值、配置文件与命令行覆盖值
+	if err := applyLayers(opts); err != nil {
+		return err
+	}
+
+	// 3. 加载全局配置
 	GlobalConfig = &Config{
 		MachineID:        util.GetIntEnv("MACHINE_ID"),
 		DBDriver:         util.GetEnv("DB_DRIVER"),
@@ -77,18 +125,55 @@ func Load() error {
 			Port:     util.GetIntEnv("MAIL_PORT"),
 			From:     util.GetEnv("MAIL_FROM"),
 		},
-		LLMApiKey:       util.GetEnv("LLM_API_KEY"),
-		LLMBaseURL:      util.GetEnv("LLM_BASE_URL"),
-		LLMModel:        util.GetEnv("LLM_MODEL"),
-		SearchEnabled:   util.GetBoolEnv("SEARCH_ENABLED"),
-		SearchPath:      util.GetEnv("SEARCH_PATH"),
-		SearchBatchSize: int(util.GetIntEnv("SEARCH_BATCH_SIZE")),
-		MonitorPrefix:   util.GetEnv("MONITOR_PREFIX"),
-		LanguageEnabled: util.GetBoolEnv("LANGUAGE_ENABLED"),
-		APISecretKey:    util.GetEnv("API_SECRET_KEY"),
-		BackupEnabled:   util.GetBoolEnv("BACKUP_ENABLED"),
-		BackupPath:      util.GetEnv("BACKUP_PATH"),
-		BackupSchedule:  util.GetEnv("BACKUP_SCHEDULE"),
+		LLMApiKey:        util.GetEnv("LLM_API_KEY"),
+		LLMBaseURL:       util.GetEnv("LLM_BASE_URL"),
+		LLMModel:         util.GetEnv("LLM_MODEL"),
+		SearchEnabled:    util.GetBoolEnv("SEARCH_ENABLED"),
+		SearchRequired:   util.GetBoolEnv("SEARCH_REQUIRED"),
+		SearchPath:       util.GetEnv("SEARCH_PATH"),
+		SearchIndexDir:   util.GetEnv("SEARCH_INDEX_DIR"),
+		SearchBatchSize:  int(util.GetIntEnv("SEARCH_BATCH_SIZE")),
+		SearchDriver:     util.GetEnv("SEARCH_DRIVER"),
+		SearchESAddrs:    util.GetEnv("SEARCH_ES_ADDRESSES"),
+		SearchESIndex:    util.GetEnv("SEARCH_ES_INDEX"),
+		SearchESUser:     util.GetEnv("SEARCH_ES_USERNAME"),
+		SearchESPass:     util.GetEnv("SEARCH_ES_PASSWORD"),
+		SearchESAPIKey:   util.GetEnv("SEARCH_ES_API_KEY"),
+		SearchFeedback:   util.GetBoolEnv("SEARCH_FEEDBACK_ENABLED"),
+		SearchClickBoost: int(util.GetIntEnv("SEARCH_CLICK_BOOST")),
+		SearchCacheTTL:   int(util.GetIntEnv("SEARCH_CACHE_TTL")),
+		SearchSynonyms:   util.GetEnv("SEARCH_SYNONYMS_PATH"),
+		SearchStopwords:  util.GetEnv("SEARCH_STOPWORDS_PATH"),
+		SearchACLField:   util.GetEnv("SEARCH_ACL_FIELD"),
+		SearchMaskPII:    util.GetBoolEnv("SEARCH_MASK_PII"),
+		SearchUnstored:   util.GetEnv("SEARCH_UNSTORED_FIELDS"),
+		MonitorPrefix:    util.GetEnv("MONITOR_PREFIX"),
+		MonitorSlowHTTP:  int(util.GetIntEnv("MONITOR_SLOW_HTTP_MS")),
+		MonitorPersist:   int(util.GetIntEnv("MONITOR_PERSIST_SECONDS")),
+		MonitorRetention: int(util.GetIntEnv("MONITOR_RETENTION_DAYS")),
+		MonitorExplain:   util.GetBoolEnv("MONITOR_EXPLAIN_ENABLED"),
+		LanguageEnabled:  util.GetBoolEnv("LANGUAGE_ENABLED"),
+		APISecretKey:     util.GetEnv("API_SECRET_KEY"),
+		BackupEnabled:    util.GetBoolEnv("BACKUP_ENABLED"),
+		BackupPath:       util.GetEnv("BACKUP_PATH"),
+		BackupSchedule:   util.GetEnv("BACKUP_SCHEDULE"),
+		BackupFullEvery:  int(util.GetIntEnv("BACKUP_FULL_EVERY")),
+		BackupMaxAge:     int(util.GetIntEnv("BACKUP_MAX_AGE_HOURS")),
+		QueueBackend:     util.GetEnv("QUEUE_BACKEND"),
+		QueueRedisAddr:   util.GetEnv("QUEUE_REDIS_ADDR"),
+		QueueRedisPass:   util.GetEnv("QUEUE_REDIS_PASSWORD"),
+		QueueRedisDB:     int(util.GetIntEnv("QUEUE_REDIS_DB")),
+		QueueConcurrency: int(util.GetIntEnv("QUEUE_CONCURRENCY")),
+		TraceExporter:    util.GetEnv("TRACE_EXPORTER"),
+		TraceEndpoint:    util.GetEnv("TRACE_EXPORTER_ENDPOINT"),
+		TraceServiceName: util.GetEnv("TRACE_SERVICE_NAME"),
+		RedactEnabled:    util.GetBoolEnv("REDACT_ENABLED"),
+		RedactFields:     util.GetEnv("REDACT_FIELDS"),
+		RedactPatterns:   util.GetEnv("REDACT_PATTERNS"),
+		FixturesPath:     util.GetEnv("FIXTURES_PATH"),
+		FixturesEnvs:     util.GetEnv("FIXTURES_ENVIRONMENTS"),
+		GeoIPDBPath:      util.GetEnv("GEOIP_DB_PATH"),
+		GeoIPCacheSize:   int(util.GetIntEnv("GEOIP_CACHE_SIZE")),
 	}
 	return nil
 }
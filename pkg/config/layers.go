@@ -0,0 +1,231 @@
+package config
+
+import (
+	"HibiscusIM/pkg/util"
+	_ "embed"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed defaults.yaml
+var defaultsYAML []byte
+
+// 配置来源，按优先级从低到高排列
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// LoadOptions 分层配置加载选项
+type LoadOptions struct {
+	// YAML 配置文件路径，为空时读取 CONFIG_FILE 环境变量
+	ConfigFile string
+	// 命令行覆盖值，优先级最高
+	Overrides map[string]string
+}
+
+// Entry 单项生效配置
+type Entry struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+var (
+	defaultValues = map[string]string{}
+	fileValues    = map[string]string{}
+	overrideKeys  = map[string]bool{}
+	configFile    string
+)
+
+// secretMarkers 键名包含这些片段时视为敏感信息
+var secretMarkers = []string{"PASSWORD", "SECRET", "TOKEN", "API_KEY", "DSN", "PRIVATE"}
+
+// applyLayers 依次注册内置默认值、YAML 文件与命令行覆盖值
+func applyLayers(opts LoadOptions) error {
+	defaults, err := parseYAMLValues(defaultsYAML)
+	if err != nil {
+		return fmt.Errorf("parse embedded defaults: %w", err)
+	}
+	defaultValues = defaults
+
+	configFile = opts.ConfigFile
+	if configFile == "" {
+		configFile, _ = util.LookupRawEnv("CONFIG_FILE")
+	}
+	fileValues = map[string]string{}
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return fmt.Errorf("read config file %s: %w", configFile, err)
+		}
+		if fileValues, err = parseYAMLValues(data); err != nil {
+			return fmt.Errorf("parse config file %s: %w", configFile, err)
+		}
+	}
+
+	// 文件配置与默认值都作为环境变量缺省值，文件优先
+	for k, v := range defaultValues {
+		util.SetEnvDefault(k, v)
+	}
+	for k, v := range fileValues {
+		util.SetEnvDefault(k, v)
+	}
+
+	overrideKeys = map[string]bool{}
+	for k, v := range opts.Overrides {
+		k = strings.ToUpper(k)
+		overrideKeys[k] = true
+		util.SetEnvOverride(k, v)
+	}
+	return nil
+}
+
+// parseYAMLValues 解析 YAML 并将嵌套键展开为大写下划线形式
+func parseYAMLValues(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	flattenValues("", raw, out)
+	return out, nil
+}
+
+func flattenValues(prefix string, in map[string]any, out map[string]string) {
+	for k, v := range in {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch val := v.(type) {
+		case map[string]any:
+			flattenValues(key, val, out)
+		case nil:
+			out[key] = ""
+		default:
+			out[key] = fmt.Sprint(val)
+		}
+	}
+}
+
+// Source 返回配置项的生效来源
+func Source(key string) string {
+	key = strings.ToUpper(key)
+	if overrideKeys[key] {
+		return SourceFlag
+	}
+	if _, ok := util.LookupRawEnv(key); ok {
+		return SourceEnv
+	}
+	if _, ok := fileValues[key]; ok {
+		return SourceFile
+	}
+	if _, ok := defaultValues[key]; ok {
+		return SourceDefault
+	}
+	return ""
+}
+
+// ConfigFile 返回当前加载的 YAML 配置文件路径
+func ConfigFile() string {
+	return configFile
+}
+
+// IsSecretKey 判断配置键是否为敏感信息
+func IsSecretKey(key string) bool {
+	key = strings.ToUpper(key)
+	for _, m := range secretMarkers {
+		if strings.Contains(key, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskValue 对敏感配置值脱敏
+func MaskValue(key, value string) string {
+	if value == "" || !IsSecretKey(key) {
+		return value
+	}
+	return "******"
+}
+
+// Effective 返回所有已知配置项的生效值（敏感值已脱敏），按键名排序
+func Effective() []Entry {
+	keys := map[string]bool{}
+	for k := range defaultValues {
+		keys[k] = true
+	}
+	for k := range fileValues {
+		keys[k] = true
+	}
+	for k := range overrideKeys {
+		keys[k] = true
+	}
+	for _, k := range knownKeys {
+		keys[k] = true
+	}
+
+	entries := make([]Entry, 0, len(keys))
+	for k := range keys {
+		v, ok := util.LookupEnv(k)
+		if !ok {
+			continue
+		}
+		entries = append(entries, Entry{Key: k, Value: MaskValue(k, v), Source: Source(k)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// knownKeys GlobalConfig 读取的全部配置键
+var knownKeys = []string{
+	"MACHINE_ID", "DB_DRIVER", "DSN", "ADDR", "MODE",
+	"DOCS_PREFIX", "API_PREFIX", "ADMIN_PREFIX", "AUTH_PREFIX",
+	"SESSION_SECRET", "SESSION_EXPIRE_DAYS",
+	"LOG_LEVEL", "LOG_FILENAME", "LOG_MAX_SIZE", "LOG_MAX_AGE", "LOG_MAX_BACKUPS",
+	"MAIL_HOST", "MAIL_USERNAME", "MAIL_PASSWORD", "MAIL_PORT", "MAIL_FROM",
+	"LLM_API_KEY", "LLM_BASE_URL", "LLM_MODEL",
+	"SEARCH_ENABLED", "SEARCH_REQUIRED", "SEARCH_PATH", "SEARCH_INDEX_DIR", "SEARCH_BATCH_SIZE",
+	"SEARCH_DRIVER", "SEARCH_ES_ADDRESSES", "SEARCH_ES_INDEX", "SEARCH_ES_USERNAME", "SEARCH_ES_PASSWORD", "SEARCH_ES_API_KEY",
+	"SEARCH_FEEDBACK_ENABLED", "SEARCH_CLICK_BOOST", "SEARCH_CACHE_TTL", "SEARCH_SYNONYMS_PATH", "SEARCH_STOPWORDS_PATH", "SEARCH_ACL_FIELD",
+	"SEARCH_MASK_PII", "SEARCH_UNSTORED_FIELDS",
+	"MONITOR_PREFIX", "MONITOR_SLOW_HTTP_MS", "MONITOR_PERSIST_SECONDS", "MONITOR_RETENTION_DAYS", "MONITOR_EXPLAIN_ENABLED",
+	"LANGUAGE_ENABLED", "API_SECRET_KEY",
+	"BACKUP_ENABLED", "BACKUP_PATH", "BACKUP_SCHEDULE", "BACKUP_FULL_EVERY", "BACKUP_MAX_AGE_HOURS",
+	"QUEUE_BACKEND", "QUEUE_REDIS_ADDR", "QUEUE_REDIS_PASSWORD", "QUEUE_REDIS_DB", "QUEUE_CONCURRENCY",
+	"TRACE_EXPORTER", "TRACE_EXPORTER_ENDPOINT", "TRACE_SERVICE_NAME",
+	"REDACT_ENABLED", "REDACT_FIELDS", "REDACT_PATTERNS",
+	"FIXTURES_PATH", "FIXTURES_ENVIRONMENTS",
+	"GEOIP_DB_PATH", "GEOIP_CACHE_SIZE",
+}
+
+// OverrideFlags 命令行 -set KEY=VALUE 参数，可重复指定
+type OverrideFlags map[string]string
+
+// String 实现 flag.Value
+func (o OverrideFlags) String() string {
+	parts := make([]string, 0, len(o))
+	for k, v := range o {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// Set 实现 flag.Value
+func (o OverrideFlags) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(k) == "" {
+		return fmt.Errorf("invalid override %q, expected KEY=VALUE", s)
+	}
+	o[strings.ToUpper(strings.TrimSpace(k))] = v
+	return nil
+}
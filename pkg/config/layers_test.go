@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"HibiscusIM/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseYAMLValues(t *testing.T) {
+	values, err := parseYAMLValues([]byte("addr: \":9000\"\nlog:\n  level: debug\n  max_size: 10\n"))
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", values["ADDR"])
+	assert.Equal(t, "debug", values["LOG_LEVEL"])
+	assert.Equal(t, "10", values["LOG_MAX_SIZE"])
+}
+
+func TestDefaultsLeaveOptionalRoutesDisabled(t *testing.T) {
+	values, err := parseYAMLValues(defaultsYAML)
+	require.NoError(t, err)
+	for _, key := range []string{"ADMIN_PREFIX", "DOCS_PREFIX", "MONITOR_PREFIX"} {
+		assert.Empty(t, values[key], key)
+	}
+	assert.Equal(t, "/api", values["API_PREFIX"])
+}
+
+func TestLayerPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("layer_test:\n  file: from-file\n  env: from-file\n  flag: from-file\n"), 0o644))
+	t.Setenv("LAYER_TEST_ENV", "from-env")
+	t.Setenv("LAYER_TEST_FLAG", "from-env")
+
+	require.NoError(t, applyLayers(LoadOptions{
+		ConfigFile: file,
+		Overrides:  map[string]string{"layer_test_flag": "from-flag"},
+	}))
+
+	assert.Equal(t, "from-file", util.GetEnv("LAYER_TEST_FILE"))
+	assert.Equal(t, SourceFile, Source("LAYER_TEST_FILE"))
+	assert.Equal(t, "from-env", util.GetEnv("LAYER_TEST_ENV"))
+	assert.Equal(t, SourceEnv, Source("LAYER_TEST_ENV"))
+	assert.Equal(t, "from-flag", util.GetEnv("LAYER_TEST_FLAG"))
+	assert.Equal(t, SourceFlag, Source("LAYER_TEST_FLAG"))
+	assert.Equal(t, SourceDefault, Source("QUEUE_BACKEND"))
+}
+
+func TestMaskValue(t *testing.T) {
+	assert.Equal(t, "******", MaskValue("MAIL_PASSWORD", "hunter2"))
+	assert.Equal(t, "******", MaskValue("DSN", "user:pass@/db"))
+	assert.Equal(t, "", MaskValue("SESSION_SECRET", ""))
+	assert.Equal(t, ":8000", MaskValue("ADDR", ":8000"))
+}
+
+func TestOverrideFlags(t *testing.T) {
+	o := OverrideFlags{}
+	require.NoError(t, o.Set("log_level=debug"))
+	require.NoError(t, o.Set("DSN=a=b"))
+	assert.Error(t, o.Set("novalue"))
+	assert.Equal(t, "debug", o["LOG_LEVEL"])
+	assert.Equal(t, "a=b", o["DSN"])
+}
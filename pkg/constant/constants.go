@@ -13,6 +13,23 @@ const ENV_SESSION_FIELD = "SESSION_FIELD"
 const ENV_SESSION_SECRET = "SESSION_SECRET"
 const ENV_SESSION_EXPIRE_DAYS = "SESSION_EXPIRE_DAYS"
 
+// Rate limiter store failure mode: open|closed, Default Value: open
+const ENV_RATE_LIMIT_FAILURE_MODE = "RATE_LIMIT_FAILURE_MODE"
+
+// Rate limiter algorithm: fixed|sliding, Default Value: fixed
+const ENV_RATE_LIMIT_ALGORITHM = "RATE_LIMIT_ALGORITHM"
+
+// Rate limiter Redis store, shared by all instances; memory store when RATE_LIMIT_REDIS_ADDR is empty
+const ENV_RATE_LIMIT_REDIS_ADDR = "RATE_LIMIT_REDIS_ADDR"
+const ENV_RATE_LIMIT_REDIS_PASSWORD = "RATE_LIMIT_REDIS_PASSWORD"
+const ENV_RATE_LIMIT_REDIS_DB = "RATE_LIMIT_REDIS_DB"
+const ENV_RATE_LIMIT_REDIS_PREFIX = "RATE_LIMIT_REDIS_PREFIX"
+
+// Response compression, Default Value: enabled, gzip level 0 (default), min size 1024 bytes
+const ENV_COMPRESSION_DISABLED = "COMPRESSION_DISABLED"
+const ENV_COMPRESSION_LEVEL = "COMPRESSION_LEVEL"
+const ENV_COMPRESSION_MIN_SIZE = "COMPRESSION_MIN_SIZE"
+
 // DB
 const ENV_DB_DRIVER = "DB_DRIVER"
 const ENV_DSN = "DSN"
@@ -0,0 +1,137 @@
+package geoip
+
+import (
+	"HibiscusIM/pkg/cache"
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// Unknown 数据库不可用或查询失败时返回的位置
+const Unknown = "unknown"
+
+// Config GeoIP 配置
+type Config struct {
+	// GeoLite2/GeoIP2 City 数据库路径
+	DBPath string
+	// 查询结果缓存条数，默认 10000
+	CacheSize int
+	// 查询结果缓存时长，默认 1 小时
+	CacheTTL time.Duration
+}
+
+// Location IP 对应的地理位置，无法解析的字段为 Unknown
+type Location struct {
+	City        string `json:"city"`
+	Country     string `json:"country"`
+	CountryCode string `json:"countryCode"`
+}
+
+var unknownLocation = Location{City: Unknown, Country: Unknown, CountryCode: Unknown}
+
+// Resolver IP 地理位置解析，数据库在创建时打开一次，查询结果缓存在本地 LRU 中；
+// 为 nil 或数据库未加载时总是返回 Unknown
+type Resolver struct {
+	reader *geoip2.Reader
+	cache  cache.Cache
+	ttl    time.Duration
+}
+
+// New 打开数据库并创建解析器；打开失败时返回错误及一个总是返回 Unknown 的解析器，调用方可降级继续使用
+func New(cfg Config) (*Resolver, error) {
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = 10000
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	r := &Resolver{
+		cache: cache.NewLocalCache(cache.LocalConfig{
+			MaxSize:           cfg.CacheSize,
+			DefaultExpiration: cfg.CacheTTL,
+			CleanupInterval:   10 * time.Minute,
+		}),
+		ttl: cfg.CacheTTL,
+	}
+	reader, err := geoip2.Open(cfg.DBPath)
+	if err != nil {
+		return r, err
+	}
+	r.reader = reader
+	return r, nil
+}
+
+// Available 数据库是否已加载
+func (r *Resolver) Available() bool {
+	return r != nil && r.reader != nil
+}
+
+// Lookup 查询 IP 的地理位置，IP 无效、数据库不可用或查询失败时返回 Unknown
+func (r *Resolver) Lookup(ip string) Location {
+	if !r.Available() {
+		return unknownLocation
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return unknownLocation
+	}
+	ctx := context.Background()
+	if v, ok := r.cache.Get(ctx, ip); ok {
+		if loc, ok := v.(Location); ok {
+			return loc
+		}
+	}
+	loc := unknownLocation
+	if record, err := r.reader.City(parsed); err == nil {
+		if name := record.City.Names["en"]; name != "" {
+			loc.City = name
+		}
+		if name := record.Country.Names["en"]; name != "" {
+			loc.Country = name
+		}
+		if code := record.Country.IsoCode; code != "" {
+			loc.CountryCode = code
+		}
+	}
+	_ = r.cache.Set(ctx, ip, loc, r.ttl)
+	return loc
+}
+
+// City 查询 IP 所在城市，无法解析时返回 Unknown
+func (r *Resolver) City(ip string) string {
+	return r.Lookup(ip).City
+}
+
+// Close 关闭数据库并释放缓存
+func (r *Resolver) Close() error {
+	if r == nil {
+		return nil
+	}
+	_ = r.cache.Close()
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultResolver *Resolver
+)
+
+// SetDefault 设置全局解析器，启动时调用一次
+func SetDefault(r *Resolver) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultResolver = r
+}
+
+// Default 获取全局解析器，未设置时为 nil（查询返回 Unknown）
+func Default() *Resolver {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultResolver
+}
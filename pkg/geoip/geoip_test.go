@@ -0,0 +1,24 @@
+package geoip
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverFallback(t *testing.T) {
+	r, err := New(Config{DBPath: filepath.Join(t.TempDir(), "missing.mmdb")})
+	assert.Error(t, err)
+	require.NotNil(t, r)
+	assert.False(t, r.Available())
+	assert.Equal(t, Unknown, r.City("8.8.8.8"))
+	assert.Equal(t, Location{City: Unknown, Country: Unknown, CountryCode: Unknown}, r.Lookup("not-an-ip"))
+	assert.NoError(t, r.Close())
+
+	var nilResolver *Resolver
+	assert.Equal(t, Unknown, nilResolver.City("8.8.8.8"))
+	assert.NoError(t, nilResolver.Close())
+	assert.Equal(t, Unknown, Default().City("8.8.8.8"))
+}
@@ -0,0 +1,204 @@
+package grpcx
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"HibiscusIM/pkg/websocket"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 携带凭证的 metadata 键
+const (
+	AuthorizationHeader = "authorization"
+	APIKeyHeader        = "x-api-key"
+)
+
+// ErrInvalidCredentials 凭证无效
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Credential 请求携带的凭证，Scheme 为 bearer 或 api_key
+type Credential struct {
+	Scheme string
+	Token  string
+}
+
+// Principal 认证通过的调用方
+type Principal struct {
+	Subject string
+	Scheme  string
+}
+
+// Authenticator 校验调用方凭证，返回调用方标识
+type Authenticator interface {
+	Authenticate(ctx context.Context, cred Credential) (string, error)
+}
+
+// AuthenticatorFunc 函数形式的 Authenticator
+type AuthenticatorFunc func(ctx context.Context, cred Credential) (string, error)
+
+// Authenticate 实现 Authenticator
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, cred Credential) (string, error) {
+	return f(ctx, cred)
+}
+
+// ChainAuthenticators 依次尝试多个认证器，返回第一个成功的结果
+func ChainAuthenticators(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context, cred Credential) (string, error) {
+		err := ErrInvalidCredentials
+		for _, a := range auths {
+			if a == nil {
+				continue
+			}
+			var subject string
+			if subject, err = a.Authenticate(ctx, cred); err == nil {
+				return subject, nil
+			}
+		}
+		return "", err
+	})
+}
+
+// APIKeyAuthenticator 按 API Key 认证，keys 为 API Key 到调用方标识的映射，比较时间恒定
+func APIKeyAuthenticator(keys map[string]string) Authenticator {
+	return AuthenticatorFunc(func(_ context.Context, cred Credential) (string, error) {
+		if cred.Scheme != "api_key" {
+			return "", ErrInvalidCredentials
+		}
+		for key, subject := range keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(cred.Token)) == 1 {
+				return subject, nil
+			}
+		}
+		return "", ErrInvalidCredentials
+	})
+}
+
+// JWTAuthenticator 校验 HS256 签名的 Bearer JWT，调用方标识取自 sub 声明，与 WebSocket 握手令牌格式一致
+func JWTAuthenticator(secret, issuer string) Authenticator {
+	jwt := websocket.NewJWTAuthenticator(secret, issuer)
+	return AuthenticatorFunc(func(_ context.Context, cred Credential) (string, error) {
+		if cred.Scheme != "bearer" {
+			return "", ErrInvalidCredentials
+		}
+		return jwt.Authenticate(nil, cred.Token)
+	})
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext 读取认证拦截器写入的调用方
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// AuthServerInterceptor 校验 metadata 中的 authorization: Bearer <token> 或 x-api-key，
+// 认证失败返回 Unauthenticated；skip 中的方法（如健康检查 /grpc.health.v1.Health/Check）不校验
+func AuthServerInterceptor(auth Authenticator, skip ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, auth, info.FullMethod, skip)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthStreamInterceptor 流式调用的认证拦截器，规则同 AuthServerInterceptor
+func AuthStreamInterceptor(auth Authenticator, skip ...string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), auth, info.FullMethod, skip)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticate(ctx context.Context, auth Authenticator, method string, skip []string) (context.Context, error) {
+	for _, m := range skip {
+		if m == method {
+			return ctx, nil
+		}
+	}
+	cred, ok := credentialFromMetadata(ctx)
+	if !ok {
+		return ctx, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+	subject, err := auth.Authenticate(ctx, cred)
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return context.WithValue(ctx, principalKey{}, &Principal{Subject: subject, Scheme: cred.Scheme}), nil
+}
+
+// credentialFromMetadata 优先读取 Bearer 令牌，其次读取 API Key
+func credentialFromMetadata(ctx context.Context) (Credential, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return Credential{}, false
+	}
+	if values := md.Get(AuthorizationHeader); len(values) > 0 {
+		scheme, token, found := strings.Cut(values[0], " ")
+		if found && strings.EqualFold(scheme, "bearer") && strings.TrimSpace(token) != "" {
+			return Credential{Scheme: "bearer", Token: strings.TrimSpace(token)}, true
+		}
+	}
+	if values := md.Get(APIKeyHeader); len(values) > 0 && values[0] != "" {
+		return Credential{Scheme: "api_key", Token: values[0]}, true
+	}
+	return Credential{}, false
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// tokenCredentials 每次调用附带令牌的客户端凭证
+type tokenCredentials struct {
+	header   string
+	value    string
+	insecure bool
+}
+
+// BearerToken 每次调用附带 authorization: Bearer <token>，默认只在 TLS 连接上发送，Dial 使用 WithInsecure 时放行
+func BearerToken(token string) credentials.PerRPCCredentials {
+	return tokenCredentials{header: AuthorizationHeader, value: "Bearer " + token}
+}
+
+// APIKey 每次调用附带 x-api-key，发送限制同 BearerToken
+func APIKey(key string) credentials.PerRPCCredentials {
+	return tokenCredentials{header: APIKeyHeader, value: key}
+}
+
+// GetRequestMetadata 实现 credentials.PerRPCCredentials
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{t.header: t.value}, nil
+}
+
+// RequireTransportSecurity 实现 credentials.PerRPCCredentials
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return !t.insecure
+}
+
+// allowInsecure 明文连接（如本机调试）下允许发送令牌
+func allowInsecure(c credentials.PerRPCCredentials) credentials.PerRPCCredentials {
+	if t, ok := c.(tokenCredentials); ok {
+		t.insecure = true
+		return t
+	}
+	return c
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
@@ -15,6 +16,11 @@ type ServerConfig struct {
 	Addr             string
 	UnaryTimeout     time.Duration
 	EnableReflection bool
+	// TLS 为 nil 时使用明文连接，仅适合监听 localhost
+	TLS *TLSConfig
+	// Auth 非 nil 时校验每次调用的 API Key 或 JWT，AuthSkipMethods 中的方法（完整方法名）不校验
+	Auth            Authenticator
+	AuthSkipMethods []string
 }
 
 // ClientConfig gRPC 客户端配置
@@ -23,20 +29,37 @@ type ClientConfig struct {
 	UnaryTimeout   time.Duration
 	WithInsecure   bool
 	DefaultHeaders map[string]string
+	// TLS 非 nil 时优先于 WithInsecure
+	TLS *TLSConfig
+	// PerRPC 每次调用附带的凭证，如 BearerToken、APIKey
+	PerRPC credentials.PerRPCCredentials
 }
 
-// NewServer 创建 gRPC Server，已内置日志/恢复/超时拦截器
-func NewServer(cfg ServerConfig, extra ...grpc.UnaryServerInterceptor) *grpc.Server {
+// NewServer 创建 gRPC Server，已内置日志/恢复/超时拦截器，配置了 Auth 时认证先于其他拦截器执行
+func NewServer(cfg ServerConfig, extra ...grpc.UnaryServerInterceptor) (*grpc.Server, error) {
 	interceptors := []grpc.UnaryServerInterceptor{
 		serverTimeoutInterceptor(cfg.UnaryTimeout),
 		recoveryInterceptor(),
 	}
 	interceptors = append(extra, interceptors...)
-	gs := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	var opts []grpc.ServerOption
+	if cfg.Auth != nil {
+		interceptors = append([]grpc.UnaryServerInterceptor{AuthServerInterceptor(cfg.Auth, cfg.AuthSkipMethods...)}, interceptors...)
+		opts = append(opts, grpc.ChainStreamInterceptor(AuthStreamInterceptor(cfg.Auth, cfg.AuthSkipMethods...)))
+	}
+	if cfg.TLS != nil {
+		creds, err := cfg.TLS.ServerCredentials()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	opts = append(opts, grpc.ChainUnaryInterceptor(interceptors...))
+	gs := grpc.NewServer(opts...)
 	if cfg.EnableReflection {
 		reflection.Register(gs)
 	}
-	return gs
+	return gs, nil
 }
 
 // Dial 创建客户端连接，内置超时与默认Header注入拦截器
@@ -44,8 +67,22 @@ func Dial(cfg ClientConfig, extra ...grpc.UnaryClientInterceptor) (*grpc.ClientC
 	opts := []grpc.DialOption{
 		grpc.WithBlock(),
 	}
-	if cfg.WithInsecure {
+	perRPC := cfg.PerRPC
+	switch {
+	case cfg.TLS != nil:
+		creds, err := cfg.TLS.ClientCredentials(cfg.Target)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(creds))
+	case cfg.WithInsecure:
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if perRPC != nil {
+			perRPC = allowInsecure(perRPC)
+		}
+	}
+	if perRPC != nil {
+		opts = append(opts, grpc.WithPerRPCCredentials(perRPC))
 	}
 	cis := []grpc.UnaryClientInterceptor{
 		clientTimeoutInterceptor(cfg.UnaryTimeout),
@@ -0,0 +1,143 @@
+package grpcx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TLSConfig 传输层加密配置，证书可以是文件路径或内嵌 PEM，同时设置时优先使用 PEM
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// CAFile 服务端用于校验客户端证书、客户端用于校验服务端证书的 CA，为空时客户端使用系统根证书
+	CAFile string
+
+	CertPEM []byte
+	KeyPEM  []byte
+	CAPEM   []byte
+
+	// RequireClientCert 服务端要求客户端出示由 CA 签发的证书（mTLS）
+	RequireClientCert bool
+	// ServerName 客户端校验服务端证书时使用的名称，为空时取 Target 的主机名
+	ServerName string
+	// MinVersion 最低 TLS 版本，为 0 时使用 TLS 1.2
+	MinVersion uint16
+}
+
+// ServerCredentials 构造服务端传输凭证，启用 mTLS 时必须配置 CA
+func (c *TLSConfig) ServerCredentials() (credentials.TransportCredentials, error) {
+	cert, err := c.certificate()
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, errors.New("grpcx: server tls requires a certificate")
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{*cert}, MinVersion: c.minVersion()}
+	pool, err := c.caPool()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c.RequireClientCert && pool == nil:
+		return nil, errors.New("grpcx: mutual tls requires a client CA")
+	case c.RequireClientCert:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = pool
+	case pool != nil:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		cfg.ClientCAs = pool
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// ClientCredentials 构造客户端传输凭证，配置了证书时向服务端出示（mTLS）
+func (c *TLSConfig) ClientCredentials(target string) (credentials.TransportCredentials, error) {
+	cfg := &tls.Config{ServerName: c.ServerName, MinVersion: c.minVersion()}
+	if cfg.ServerName == "" {
+		cfg.ServerName = targetHost(target)
+	}
+	cert, err := c.certificate()
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	if cfg.RootCAs, err = c.caPool(); err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+func (c *TLSConfig) minVersion() uint16 {
+	if c.MinVersion == 0 {
+		return tls.VersionTLS12
+	}
+	return c.MinVersion
+}
+
+// certificate 加载证书与私钥，两者都未配置时返回 nil
+func (c *TLSConfig) certificate() (*tls.Certificate, error) {
+	certPEM, err := readPEM(c.CertPEM, c.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := readPEM(c.KeyPEM, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if certPEM == nil && keyPEM == nil {
+		return nil, nil
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("grpcx: load key pair: %w", err)
+	}
+	return &cert, nil
+}
+
+// caPool 加载 CA 证书，未配置时返回 nil
+func (c *TLSConfig) caPool() (*x509.CertPool, error) {
+	caPEM, err := readPEM(c.CAPEM, c.CAFile)
+	if err != nil || caPEM == nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("grpcx: no valid certificate in CA")
+	}
+	return pool, nil
+}
+
+func readPEM(pem []byte, file string) ([]byte, error) {
+	if len(pem) > 0 {
+		return pem, nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("grpcx: read %s: %w", file, err)
+	}
+	return data, nil
+}
+
+// targetHost 从 host:port 或 scheme:///host:port 形式的 Target 中取出主机名
+func targetHost(target string) string {
+	if i := strings.LastIndex(target, "/"); i >= 0 {
+		target = target[i+1:]
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
+	}
+	return target
+}
@@ -0,0 +1,68 @@
+package grpcx
+
+import (
+	"HibiscusIM/pkg/metrics"
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TracingServerInterceptor 从 metadata 读取 W3C traceparent，为每次调用创建服务端跨度；monitor 为 nil 时使用全局监控器
+func TracingServerInterceptor(monitor *metrics.Monitor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		m := monitorOrGlobal(monitor)
+		if m == nil {
+			return handler(ctx, req)
+		}
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(metrics.TraceparentHeader); len(values) > 0 {
+				ctx = metrics.ContextWithTraceparent(ctx, values[0])
+			}
+		}
+		ctx, span := m.StartSpan(ctx, info.FullMethod,
+			metrics.WithSpanKind(metrics.SpanKindServer),
+			metrics.WithTags(map[string]string{"rpc.system": "grpc", "rpc.method": info.FullMethod}),
+		)
+		resp, err := handler(ctx, req)
+		if span != nil {
+			span.SetTag("rpc.grpc.status_code", status.Code(err).String())
+		}
+		m.EndSpan(span, err)
+		return resp, err
+	}
+}
+
+// TracingClientInterceptor 为每次调用创建客户端跨度，并通过 metadata 向下游传递 traceparent
+func TracingClientInterceptor(monitor *metrics.Monitor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		m := monitorOrGlobal(monitor)
+		if m == nil {
+			if tp := metrics.TraceparentFromContext(ctx); tp != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, metrics.TraceparentHeader, tp)
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		ctx, span := m.StartSpan(ctx, method,
+			metrics.WithSpanKind(metrics.SpanKindClient),
+			metrics.WithTags(map[string]string{"rpc.system": "grpc", "rpc.method": method, "net.peer.name": cc.Target()}),
+		)
+		if tp := metrics.TraceparentFromContext(ctx); tp != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, metrics.TraceparentHeader, tp)
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if span != nil {
+			span.SetTag("rpc.grpc.status_code", status.Code(err).String())
+		}
+		m.EndSpan(span, err)
+		return err
+	}
+}
+
+func monitorOrGlobal(monitor *metrics.Monitor) *metrics.Monitor {
+	if monitor != nil {
+		return monitor
+	}
+	return metrics.GetGlobalMonitor()
+}
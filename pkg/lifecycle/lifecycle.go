@@ -0,0 +1,318 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 组件状态
+const (
+	StatePending  = "pending"
+	StateRunning  = "running"
+	StateDegraded = "degraded" // 可选组件启动失败，服务降级运行
+	StateSkipped  = "skipped"  // 可选组件的依赖不可用，未启动
+	StateFailed   = "failed"
+	StateStopped  = "stopped"
+)
+
+var (
+	ErrDuplicateComponent = errors.New("duplicate component")
+	ErrUnknownComponent   = errors.New("unknown component")
+	ErrDependencyCycle    = errors.New("dependency cycle")
+)
+
+// Component 受管理的子系统
+type Component struct {
+	Name string
+	// 依赖的组件，先于本组件启动、后于本组件停止
+	DependsOn []string
+	Start     func(ctx context.Context) error
+	Stop      func(ctx context.Context) error
+	// Health 启动后轮询直到返回 nil 才视为就绪，超时视为启动失败
+	Health func(ctx context.Context) error
+	// Optional 为 true 时启动失败或依赖不可用只降级，不中止整体启动
+	Optional bool
+}
+
+// ComponentStatus 组件运行状态
+type ComponentStatus struct {
+	Name      string        `json:"name"`
+	State     string        `json:"state"`
+	Optional  bool          `json:"optional"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"startedAt,omitempty"`
+	Took      time.Duration `json:"took"`
+}
+
+// Config 启停超时配置
+type Config struct {
+	// 单个组件 Start 的超时，默认 30 秒
+	StartTimeout time.Duration
+	// 健康检查等待就绪的超时，默认 30 秒
+	HealthTimeout time.Duration
+	// 健康检查轮询间隔，默认 500 毫秒
+	HealthInterval time.Duration
+	// 单个组件 Stop 的超时，默认 10 秒
+	StopTimeout time.Duration
+}
+
+type entry struct {
+	Component
+	status ComponentStatus
+}
+
+// Manager 按依赖顺序启动组件、逆序停止，可选组件失败时降级运行
+type Manager struct {
+	cfg Config
+
+	mu         sync.Mutex
+	components map[string]*entry
+	names      []string // 注册顺序
+	started    []string // 启动顺序，停止时逆序
+}
+
+// NewManager 创建生命周期管理器
+func NewManager(cfg Config) *Manager {
+	if cfg.StartTimeout <= 0 {
+		cfg.StartTimeout = 30 * time.Second
+	}
+	if cfg.HealthTimeout <= 0 {
+		cfg.HealthTimeout = 30 * time.Second
+	}
+	if cfg.HealthInterval <= 0 {
+		cfg.HealthInterval = 500 * time.Millisecond
+	}
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = 10 * time.Second
+	}
+	return &Manager{cfg: cfg, components: make(map[string]*entry)}
+}
+
+// Register 注册组件，依赖可在之后注册，启动时校验
+func (m *Manager) Register(c Component) error {
+	if c.Name == "" {
+		return errors.New("component name is required")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.components[c.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateComponent, c.Name)
+	}
+	m.components[c.Name] = &entry{Component: c, status: ComponentStatus{Name: c.Name, State: StatePending, Optional: c.Optional}}
+	m.names = append(m.names, c.Name)
+	return nil
+}
+
+// MustRegister 注册组件，失败时 panic，用于启动代码中的静态注册
+func (m *Manager) MustRegister(c Component) {
+	if err := m.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// Start 按依赖顺序启动组件；names 不为空时只启动这些组件及其依赖，已运行或已降级的组件不会重复启动。
+// 必需组件失败时停止本管理器已启动的全部组件并返回错误
+func (m *Manager) Start(ctx context.Context, names ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, err := m.order(names)
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		e := m.components[name]
+		if e.status.State != StatePending && e.status.State != StateStopped {
+			continue
+		}
+		err := m.checkDependencies(e)
+		if err == nil {
+			err = m.startOne(ctx, e)
+			if err == nil {
+				continue
+			}
+		} else if e.Optional {
+			e.status.State, e.status.Error = StateSkipped, err.Error()
+			zap.L().Warn("component skipped", zap.String("component", name), zap.Error(err))
+			continue
+		}
+		e.status.Error = err.Error()
+		if e.Optional {
+			e.status.State = StateDegraded
+			zap.L().Warn("optional component failed, running degraded", zap.String("component", name), zap.Error(err))
+			continue
+		}
+		e.status.State = StateFailed
+		zap.L().Error("component failed to start", zap.String("component", name), zap.Error(err))
+		if stopErr := m.stopAll(ctx); stopErr != nil {
+			zap.L().Warn("rollback after failed start", zap.Error(stopErr))
+		}
+		return fmt.Errorf("start %s: %w", name, err)
+	}
+	return nil
+}
+
+// checkDependencies 依赖必须全部处于运行状态
+func (m *Manager) checkDependencies(e *entry) error {
+	for _, dep := range e.DependsOn {
+		if state := m.components[dep].status.State; state != StateRunning {
+			return fmt.Errorf("dependency %s is %s", dep, state)
+		}
+	}
+	return nil
+}
+
+// startOne 启动单个组件并等待健康检查通过，健康检查失败时停止该组件
+func (m *Manager) startOne(ctx context.Context, e *entry) error {
+	begin := time.Now()
+	if e.Start != nil {
+		startCtx, cancel := context.WithTimeout(ctx, m.cfg.StartTimeout)
+		err := e.Start(startCtx)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	if e.Health != nil {
+		if err := m.waitHealthy(ctx, e); err != nil {
+			if e.Stop != nil {
+				stopCtx, cancel := context.WithTimeout(context.Background(), m.cfg.StopTimeout)
+				_ = e.Stop(stopCtx)
+				cancel()
+			}
+			return fmt.Errorf("health check: %w", err)
+		}
+	}
+	e.status.State, e.status.Error = StateRunning, ""
+	e.status.StartedAt = begin
+	e.status.Took = time.Since(begin)
+	m.started = append(m.started, e.Name)
+	zap.L().Info("component started", zap.String("component", e.Name), zap.Duration("took", e.status.Took))
+	return nil
+}
+
+// waitHealthy 轮询健康检查直到通过或超时，超时返回最后一次错误
+func (m *Manager) waitHealthy(ctx context.Context, e *entry) error {
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.HealthTimeout)
+	defer cancel()
+	ticker := time.NewTicker(m.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		err := e.Health(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop 按启动的逆序停止全部已启动组件，返回合并后的错误
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stopAll(ctx)
+}
+
+func (m *Manager) stopAll(ctx context.Context) error {
+	var errs []error
+	for i := len(m.started) - 1; i >= 0; i-- {
+		e := m.components[m.started[i]]
+		if e.Stop != nil {
+			stopCtx, cancel := context.WithTimeout(ctx, m.cfg.StopTimeout)
+			err := e.Stop(stopCtx)
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("stop %s: %w", e.Name, err))
+				zap.L().Warn("component failed to stop", zap.String("component", e.Name), zap.Error(err))
+			}
+		}
+		e.status.State = StateStopped
+		zap.L().Info("component stopped", zap.String("component", e.Name))
+	}
+	m.started = nil
+	return errors.Join(errs...)
+}
+
+// order 依赖拓扑排序，同一层级保持注册顺序；names 不为空时只包含这些组件及其传递依赖
+func (m *Manager) order(names []string) ([]string, error) {
+	if len(names) == 0 {
+		names = m.names
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	marks := make(map[string]int, len(m.components))
+	out := make([]string, 0, len(m.components))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		e, ok := m.components[name]
+		if !ok {
+			if len(path) > 0 {
+				return fmt.Errorf("%w: %s (required by %s)", ErrUnknownComponent, name, path[len(path)-1])
+			}
+			return fmt.Errorf("%w: %s", ErrUnknownComponent, name)
+		}
+		switch marks[name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %v -> %s", ErrDependencyCycle, path, name)
+		}
+		marks[name] = visiting
+		for _, dep := range e.DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = done
+		out = append(out, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Status 全部组件状态，按注册顺序
+func (m *Manager) Status() []ComponentStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ComponentStatus, 0, len(m.names))
+	for _, name := range m.names {
+		out = append(out, m.components[name].status)
+	}
+	return out
+}
+
+// Running 组件是否已启动且正在运行
+func (m *Manager) Running(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.components[name]
+	return ok && e.status.State == StateRunning
+}
+
+// Degraded 降级或跳过的可选组件
+func (m *Manager) Degraded() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []string
+	for _, name := range m.names {
+		if s := m.components[name].status.State; s == StateDegraded || s == StateSkipped {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder 记录组件启停顺序
+type recorder struct{ events []string }
+
+func (r *recorder) component(name string, deps ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: deps,
+		Start: func(ctx context.Context) error {
+			r.events = append(r.events, "start "+name)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			r.events = append(r.events, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func newTestManager() *Manager {
+	return NewManager(Config{HealthTimeout: 100 * time.Millisecond, HealthInterval: 5 * time.Millisecond})
+}
+
+func TestManagerOrder(t *testing.T) {
+	r := &recorder{}
+	m := newTestManager()
+	m.MustRegister(r.component("ws", "db", "cache"))
+	m.MustRegister(r.component("search", "ws"))
+	m.MustRegister(r.component("db"))
+	m.MustRegister(r.component("cache"))
+	assert.ErrorIs(t, m.Register(r.component("db")), ErrDuplicateComponent)
+
+	ctx := context.Background()
+	require.NoError(t, m.Start(ctx, "ws"))
+	assert.Equal(t, []string{"start db", "start cache", "start ws"}, r.events)
+	assert.False(t, m.Running("search"))
+
+	// 已启动的组件不会重复启动
+	require.NoError(t, m.Start(ctx))
+	assert.Equal(t, []string{"start db", "start cache", "start ws", "start search"}, r.events)
+
+	r.events = nil
+	require.NoError(t, m.Stop(ctx))
+	assert.Equal(t, []string{"stop search", "stop ws", "stop cache", "stop db"}, r.events)
+	for _, s := range m.Status() {
+		assert.Equal(t, StateStopped, s.State, s.Name)
+	}
+}
+
+func TestManagerDependencyErrors(t *testing.T) {
+	m := newTestManager()
+	m.MustRegister(Component{Name: "a", DependsOn: []string{"b"}})
+	m.MustRegister(Component{Name: "b", DependsOn: []string{"a"}})
+	assert.ErrorIs(t, m.Start(context.Background()), ErrDependencyCycle)
+
+	m = newTestManager()
+	m.MustRegister(Component{Name: "a", DependsOn: []string{"missing"}})
+	assert.ErrorIs(t, m.Start(context.Background()), ErrUnknownComponent)
+}
+
+func TestManagerDegradation(t *testing.T) {
+	r := &recorder{}
+	m := newTestManager()
+	m.MustRegister(r.component("db"))
+	search := r.component("search", "db")
+	search.Optional = true
+	search.Start = func(ctx context.Context) error { return errors.New("index locked") }
+	m.MustRegister(search)
+	feedback := r.component("feedback", "search")
+	feedback.Optional = true
+	m.MustRegister(feedback)
+	m.MustRegister(r.component("http", "db"))
+
+	require.NoError(t, m.Start(context.Background()))
+	assert.Equal(t, []string{"start db", "start http"}, r.events)
+	assert.Equal(t, []string{"search", "feedback"}, m.Degraded())
+
+	states := map[string]ComponentStatus{}
+	for _, s := range m.Status() {
+		states[s.Name] = s
+	}
+	assert.Equal(t, StateDegraded, states["search"].State)
+	assert.Equal(t, "index locked", states["search"].Error)
+	assert.Equal(t, StateSkipped, states["feedback"].State)
+	assert.Equal(t, StateRunning, states["http"].State)
+}
+
+func TestManagerRequiredFailureRollsBack(t *testing.T) {
+	r := &recorder{}
+	m := newTestManager()
+	m.MustRegister(r.component("db"))
+	m.MustRegister(r.component("cache", "db"))
+	ws := r.component("ws", "cache")
+	ws.Start = func(ctx context.Context) error { return errors.New("port in use") }
+	m.MustRegister(ws)
+
+	err := m.Start(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "start ws")
+	assert.Equal(t, []string{"start db", "start cache", "stop cache", "stop db"}, r.events)
+	assert.False(t, m.Running("db"))
+}
+
+func TestManagerHealthGate(t *testing.T) {
+	r := &recorder{}
+	m := newTestManager()
+	var checks atomic.Int32
+	ready := r.component("ready")
+	ready.Health = func(ctx context.Context) error {
+		if checks.Add(1) < 3 {
+			return errors.New("warming up")
+		}
+		return nil
+	}
+	m.MustRegister(ready)
+	never := r.component("never")
+	never.Optional = true
+	never.Health = func(ctx context.Context) error { return errors.New("unreachable") }
+	m.MustRegister(never)
+
+	require.NoError(t, m.Start(context.Background()))
+	assert.True(t, m.Running("ready"))
+	assert.EqualValues(t, 3, checks.Load())
+	assert.False(t, m.Running("never"))
+	// 健康检查未通过的组件已启动的部分会被停止
+	assert.Equal(t, []string{"start ready", "start never", "stop never"}, r.events)
+	assert.Equal(t, []string{"never"}, m.Degraded())
+}
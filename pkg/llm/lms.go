@@ -18,6 +18,7 @@ type LMStudioHandler struct {
 	ctx         context.Context
 	apiKey      string
 	lmStudioURL string
+	usageUser   string
 }
 
 // NewLMStudioHandler creates a new LM Studio handler
@@ -31,8 +32,16 @@ func NewLMStudioHandler(ctx context.Context, apiKey, lmStudioURL, systemPrompt s
 	}
 }
 
+// SetUsageUser sets the user that subsequent calls are attributed to in usage reports
+func (h *LMStudioHandler) SetUsageUser(userID string) {
+	h.usageUser = userID
+}
+
 // QueryStream processes the LLM response as a stream for LM Studio
-func (h *LMStudioHandler) QueryStream(model, text string, ttsCallback func(segment string, playID string, autoHangup bool) error) (string, error) {
+func (h *LMStudioHandler) QueryStream(model, text string, ttsCallback func(segment string, playID string, autoHangup bool) error) (_ string, err error) {
+	usage := startUsage(ProviderLMStudio, model, h.usageUser, true)
+	defer func() { usage.finish(err) }()
+
 	// Prepare the request to LM Studio's API
 	requestBody := map[string]interface{}{
 		"model": model,
@@ -63,6 +72,7 @@ func (h *LMStudioHandler) QueryStream(model, text string, ttsCallback func(segme
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
+	usage.tokens(responseTokens(response))
 
 	// Here you would process the stream response in segments
 	// For now, we're simulating sending a TTS segment
@@ -75,7 +85,10 @@ func (h *LMStudioHandler) QueryStream(model, text string, ttsCallback func(segme
 }
 
 // Query queries the LLM with text and gets a response for LM Studio
-func (h *LMStudioHandler) Query(model, text string) (string, *HangupTool, error) {
+func (h *LMStudioHandler) Query(model, text string) (_ string, _ *HangupTool, err error) {
+	usage := startUsage(ProviderLMStudio, model, h.usageUser, false)
+	defer func() { usage.finish(err) }()
+
 	// Prepare the request to LM Studio's API
 	requestBody := map[string]interface{}{
 		"model": model,
@@ -106,6 +119,7 @@ func (h *LMStudioHandler) Query(model, text string) (string, *HangupTool, error)
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return "", nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	usage.tokens(responseTokens(response))
 
 	// Extract the response content (this is a placeholder for real response content)
 	content := response["text"].(string)
@@ -18,6 +18,7 @@ type OllamaHandler struct {
 	ctx       context.Context
 	apiKey    string
 	ollamaURL string
+	usageUser string
 }
 
 // NewOllamaHandler creates a new Ollama handler
@@ -31,8 +32,16 @@ func NewOllamaHandler(ctx context.Context, apiKey, ollamaURL, systemPrompt strin
 	}
 }
 
+// SetUsageUser sets the user that subsequent calls are attributed to in usage reports
+func (h *OllamaHandler) SetUsageUser(userID string) {
+	h.usageUser = userID
+}
+
 // QueryStream processes the LLM response as a stream for Ollama
-func (h *OllamaHandler) QueryStream(model, text string, ttsCallback func(segment string, playID string, autoHangup bool) error) (string, error) {
+func (h *OllamaHandler) QueryStream(model, text string, ttsCallback func(segment string, playID string, autoHangup bool) error) (_ string, err error) {
+	usage := startUsage(ProviderOllama, model, h.usageUser, true)
+	defer func() { usage.finish(err) }()
+
 	// Prepare the request to Ollama's API
 	requestBody := map[string]interface{}{
 		"model": model,
@@ -64,6 +73,7 @@ func (h *OllamaHandler) QueryStream(model, text string, ttsCallback func(segment
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
+	usage.tokens(responseTokens(response))
 
 	// Here you would process the stream response in segments
 	// For now, we're simulating sending a TTS segment
@@ -76,7 +86,10 @@ func (h *OllamaHandler) QueryStream(model, text string, ttsCallback func(segment
 }
 
 // Query queries the LLM with text and gets a response for Ollama
-func (h *OllamaHandler) Query(model, text string) (string, *HangupTool, error) {
+func (h *OllamaHandler) Query(model, text string) (_ string, _ *HangupTool, err error) {
+	usage := startUsage(ProviderOllama, model, h.usageUser, false)
+	defer func() { usage.finish(err) }()
+
 	// Prepare the request to Ollama's API
 	requestBody := map[string]interface{}{
 		"model": model,
@@ -107,6 +120,7 @@ func (h *OllamaHandler) Query(model, text string) (string, *HangupTool, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return "", nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	usage.tokens(responseTokens(response))
 
 	// Extract the response content (this is a placeholder for real response content)
 	content := response["text"].(string)
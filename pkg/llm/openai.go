@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"regexp"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sashabaranov/go-openai"
@@ -22,6 +23,8 @@ type LLMHandler struct {
 	messages    []openai.ChatCompletionMessage
 	hangupChan  chan struct{}
 	interruptCh chan struct{}
+	usageUser   string
+	cache       *SemanticCache
 }
 
 // ToolCall represents a function call from the LLM
@@ -53,11 +56,68 @@ func NewLLMHandler(ctx context.Context, apiKey, endpoint, systemPrompt string, l
 	}
 }
 
+// SetUsageUser sets the user that subsequent calls are attributed to in usage reports
+func (h *LLMHandler) SetUsageUser(userID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.usageUser = userID
+}
+
+// SetSemanticCache enables serving repeated questions from cache, nil disables it.
+// Only the first question of a conversation is cached, since later answers depend on the history
+func (h *LLMHandler) SetSemanticCache(cache *SemanticCache) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.cache = cache
+}
+
+// cacheScope keys cached answers by model and system prompt
+func (h *LLMHandler) cacheScope(model string) string {
+	return model + "\x00" + h.systemMsg
+}
+
+// cachedAnswer looks up the semantic cache for the first question of a conversation,
+// on a hit the question and answer are added to the history
+func (h *LLMHandler) cachedAnswer(model, text string) (string, bool) {
+	if h.cache == nil || len(h.messages) != 1 {
+		return "", false
+	}
+	start := time.Now()
+	answer, ok := h.cache.Lookup(h.ctx, h.cacheScope(model), text)
+	if !ok {
+		return "", false
+	}
+	RecordUsage(UsageEvent{
+		Provider: ProviderOpenAI,
+		Model:    model,
+		UserID:   h.usageUser,
+		Outcome:  UsageOutcomeCacheHit,
+		Latency:  time.Since(start),
+	})
+	h.messages = append(h.messages,
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: text},
+		openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: answer},
+	)
+	return answer, true
+}
+
 // QueryStream processes the LLM response as a stream and sends segments to TTS as they arrive
-func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment string, playID string, autoHangup bool) error) (string, error) {
+func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment string, playID string, autoHangup bool) error) (_ string, err error) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
+	if model == "" {
+		model = openai.GPT4o
+	}
+	if answer, ok := h.cachedAnswer(model, text); ok {
+		playID := fmt.Sprintf("llm-%s", uuid.New().String())
+		if err := ttsCallback(answer, playID, false); err != nil {
+			h.logger.WithError(err).Error("Failed to send TTS segment")
+		}
+		return answer, nil
+	}
+	firstTurn := len(h.messages) == 1
+
 	// Add user message to history
 	h.messages = append(h.messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleUser,
@@ -81,14 +141,12 @@ func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment st
 	}
 
 	// Construct the OpenAI request
-	if model == "" {
-		model = openai.GPT4o
-	}
 	request := openai.ChatCompletionRequest{
-		Model:       model,
-		Messages:    h.messages,
-		Temperature: 0.7,
-		Stream:      true,
+		Model:         model,
+		Messages:      h.messages,
+		Temperature:   0.7,
+		Stream:        true,
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
 		Tools: []openai.Tool{
 			{
 				Type:     openai.ToolTypeFunction,
@@ -97,6 +155,9 @@ func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment st
 		},
 	}
 
+	usage := startUsage(ProviderOpenAI, model, h.usageUser, true)
+	defer func() { usage.finish(err) }()
+
 	// Generate a unique playID for this conversation
 	playID := fmt.Sprintf("llm-%s", uuid.New().String())
 	h.logger.WithField("playID", playID).Info("Starting LLM stream with playID")
@@ -127,6 +188,11 @@ func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment st
 			return "", fmt.Errorf("error receiving from stream: %w", err)
 		}
 
+		// The final chunk carries token usage when include_usage is set
+		if response.Usage != nil {
+			usage.tokens(response.Usage.PromptTokens, response.Usage.CompletionTokens, response.Usage.TotalTokens)
+		}
+
 		// Check for function calls (hangup)
 		if len(response.Choices) > 0 && len(response.Choices[0].Delta.ToolCalls) > 0 {
 			for _, toolCall := range response.Choices[0].Delta.ToolCalls {
@@ -185,14 +251,26 @@ func (h *LLMHandler) QueryStream(model, text string, ttsCallback func(segment st
 		"hangup":         shouldHangup,
 	}).Info("LLM stream completed")
 
+	if h.cache != nil && firstTurn && !shouldHangup {
+		h.cache.Store(h.ctx, h.cacheScope(model), text, fullResponse)
+	}
+
 	return fullResponse, nil
 }
 
 // Query the LLM with text and get a response (non-streaming version, kept for compatibility)
-func (h *LLMHandler) Query(model, text string) (string, *HangupTool, error) {
+func (h *LLMHandler) Query(model, text string) (_ string, _ *HangupTool, err error) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 
+	if model == "" {
+		model = openai.GPT4o
+	}
+	if answer, ok := h.cachedAnswer(model, text); ok {
+		return answer, nil, nil
+	}
+	firstTurn := len(h.messages) == 1
+
 	// Add user message to history
 	h.messages = append(h.messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleUser,
@@ -216,9 +294,6 @@ func (h *LLMHandler) Query(model, text string) (string, *HangupTool, error) {
 	}
 
 	// Construct the OpenAI request
-	if model == "" {
-		model = openai.GPT4o
-	}
 	request := openai.ChatCompletionRequest{
 		Model:       model,
 		Messages:    h.messages,
@@ -231,11 +306,15 @@ func (h *LLMHandler) Query(model, text string) (string, *HangupTool, error) {
 		},
 	}
 
+	usage := startUsage(ProviderOpenAI, model, h.usageUser, false)
+	defer func() { usage.finish(err) }()
+
 	// Send the request to OpenAI
 	response, err := h.client.CreateChatCompletion(h.ctx, request)
 	if err != nil {
 		return "", nil, fmt.Errorf("error querying OpenAI: %w", err)
 	}
+	usage.tokens(response.Usage.PromptTokens, response.Usage.CompletionTokens, response.Usage.TotalTokens)
 
 	// Process the response
 	message := response.Choices[0].Message
@@ -258,6 +337,10 @@ func (h *LLMHandler) Query(model, text string) (string, *HangupTool, error) {
 		}
 	}
 
+	if h.cache != nil && firstTurn && hangupTool == nil {
+		h.cache.Store(h.ctx, h.cacheScope(model), text, message.Content)
+	}
+
 	return message.Content, hangupTool, nil
 }
 
@@ -0,0 +1,367 @@
+package llm
+
+import (
+	"HibiscusIM/pkg/redact"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 语义缓存默认配置
+const (
+	DefaultSemanticThreshold  = 0.92
+	DefaultSemanticMaxEntries = 1000
+	DefaultSemanticTTL        = time.Hour
+	defaultEmbeddingDim       = 512
+)
+
+// 语义缓存查询结果，用于 llm_semantic_cache_requests_total 的 result 标签
+const (
+	cacheResultExact = "exact"
+	cacheResultHit   = "hit"
+	cacheResultMiss  = "miss"
+	cacheResultSkip  = "skip"
+)
+
+var llmSemanticCache = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "llm_semantic_cache_requests_total",
+	Help: "Total number of LLM semantic cache lookups by result",
+}, []string{"result"})
+
+// Embedder 将文本转换为向量
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// HashEmbedder 本地哈希向量：英文按单词与字符三元组、中日韩文字按单字与二元组哈希到固定维度，
+// 不依赖外部模型，适合识别措辞相近的重复问题
+type HashEmbedder struct {
+	dim int
+}
+
+// NewHashEmbedder 创建本地哈希向量器，dim <= 0 时使用 512 维
+func NewHashEmbedder(dim int) *HashEmbedder {
+	if dim <= 0 {
+		dim = defaultEmbeddingDim
+	}
+	return &HashEmbedder{dim: dim}
+}
+
+// Embed 实现 Embedder
+func (e *HashEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	vec := make([]float32, e.dim)
+	add := func(feature string, weight float32) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum32()
+		// 最高位决定符号，减少哈希冲突带来的偏差
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vec[sum%uint32(e.dim)] += weight
+	}
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		var latin []rune
+		flush := func() {
+			if len(latin) == 0 {
+				return
+			}
+			w := string(latin)
+			add("w:"+w, 1)
+			padded := []rune("^" + w + "$")
+			for i := 0; i+3 <= len(padded); i++ {
+				add("t:"+string(padded[i:i+3]), 0.5)
+			}
+			latin = latin[:0]
+		}
+		var prevCJK rune
+		for _, r := range word {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+				flush()
+				add("c:"+string(r), 0.5)
+				if prevCJK != 0 {
+					add("b:"+string([]rune{prevCJK, r}), 1)
+				}
+				prevCJK = r
+				continue
+			}
+			prevCJK = 0
+			latin = append(latin, r)
+		}
+		flush()
+	}
+	return vec, nil
+}
+
+// SemanticCacheConfig 语义缓存配置，为 0 时使用默认值
+type SemanticCacheConfig struct {
+	// Threshold 余弦相似度不低于该值时命中，默认 0.92
+	Threshold float64
+	// MaxEntries 最多缓存的回答数，超出时淘汰最久未使用的，默认 1000
+	MaxEntries int
+	// TTL 回答的有效期，默认 1 小时
+	TTL time.Duration
+	// Personal 判断提问是否含用户个人信息，含个人信息的提问既不查询也不写入缓存；为空时使用 PersonalPrompt
+	Personal func(prompt string) bool
+}
+
+// SemanticCacheStats 语义缓存统计
+type SemanticCacheStats struct {
+	Entries int   `json:"entries"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Skipped int64 `json:"skipped"`
+}
+
+type semanticEntry struct {
+	key      string
+	scope    string
+	vec      []float32
+	response string
+	expires  time.Time
+	lastUsed time.Time
+}
+
+// SemanticCache 按提问的向量相似度复用回答：先按规范化后的提问精确匹配，未命中时在同一 scope 内
+// 查找相似度最高且不低于阈值的回答。scope 须包含除提问外影响回答的全部上下文，如模型与系统提示词
+type SemanticCache struct {
+	embedder Embedder
+	cfg      SemanticCacheConfig
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*semanticEntry // 按 scope 与规范化提问的哈希索引
+	stats   SemanticCacheStats
+}
+
+// NewSemanticCache 创建语义缓存，embedder 为 nil 时使用本地 HashEmbedder
+func NewSemanticCache(embedder Embedder, cfg SemanticCacheConfig) *SemanticCache {
+	if embedder == nil {
+		embedder = NewHashEmbedder(0)
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = DefaultSemanticThreshold
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultSemanticMaxEntries
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultSemanticTTL
+	}
+	if cfg.Personal == nil {
+		cfg.Personal = PersonalPrompt
+	}
+	return &SemanticCache{
+		embedder: embedder,
+		cfg:      cfg,
+		now:      time.Now,
+		entries:  make(map[string]*semanticEntry),
+	}
+}
+
+var (
+	// personalRedactor 不受全局脱敏开关影响，始终按内置规则识别邮箱、手机号与凭证
+	personalRedactor, _ = redact.New(redact.DefaultConfig())
+	// personalNumber 订单号、账号等长数字串
+	personalNumber = regexp.MustCompile(`\d{6,}`)
+	// personalMarkers 询问自身数据的表述
+	personalMarkers = []string{"my ", "mine", "myself", "我的", "本人"}
+)
+
+// PersonalPrompt 判断提问是否含个人信息或询问自身数据：邮箱、手机号、凭证、长数字串，以及“我的”等表述
+func PersonalPrompt(prompt string) bool {
+	if personalRedactor.String(prompt) != prompt || personalNumber.MatchString(prompt) {
+		return true
+	}
+	lower := strings.ToLower(prompt) + " "
+	for _, m := range personalMarkers {
+		if strings.Contains(lower, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup 查询缓存的回答
+func (c *SemanticCache) Lookup(ctx context.Context, scope, prompt string) (string, bool) {
+	if c.cfg.Personal(prompt) {
+		c.record(cacheResultSkip)
+		return "", false
+	}
+	now := c.now()
+	scope = scopeHash(scope)
+	key := semanticKey(scope, prompt)
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		e.lastUsed = now
+		c.mu.Unlock()
+		c.record(cacheResultExact)
+		return e.response, true
+	}
+	c.mu.Unlock()
+
+	vec, err := c.embed(ctx, prompt)
+	if err != nil {
+		c.record(cacheResultMiss)
+		return "", false
+	}
+
+	c.mu.Lock()
+	var best *semanticEntry
+	bestScore := c.cfg.Threshold
+	for _, e := range c.entries {
+		if e.scope != scope || !now.Before(e.expires) {
+			continue
+		}
+		if score := cosine(vec, e.vec); score >= bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best != nil {
+		best.lastUsed = now
+		response := best.response
+		c.mu.Unlock()
+		c.record(cacheResultHit)
+		return response, true
+	}
+	c.mu.Unlock()
+	c.record(cacheResultMiss)
+	return "", false
+}
+
+// Store 缓存提问的回答，含个人信息的提问与空回答不缓存
+func (c *SemanticCache) Store(ctx context.Context, scope, prompt, response string) {
+	if strings.TrimSpace(response) == "" || c.cfg.Personal(prompt) {
+		return
+	}
+	vec, err := c.embed(ctx, prompt)
+	if err != nil {
+		return
+	}
+	now := c.now()
+	scope = scopeHash(scope)
+	key := semanticKey(scope, prompt)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.cfg.MaxEntries {
+		c.evictLocked(now)
+	}
+	c.entries[key] = &semanticEntry{
+		key:      key,
+		scope:    scope,
+		vec:      vec,
+		response: response,
+		expires:  now.Add(c.cfg.TTL),
+		lastUsed: now,
+	}
+}
+
+// Clear 清空缓存
+func (c *SemanticCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*semanticEntry)
+}
+
+// Stats 缓存条目数与命中统计
+func (c *SemanticCache) Stats() SemanticCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = len(c.entries)
+	return st
+}
+
+// evictLocked 先清理过期条目，仍然已满时淘汰最久未使用的条目，调用方需持有锁
+func (c *SemanticCache) evictLocked(now time.Time) {
+	var oldest *semanticEntry
+	for key, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldest == nil || e.lastUsed.Before(oldest.lastUsed) {
+			oldest = e
+		}
+	}
+	if len(c.entries) >= c.cfg.MaxEntries && oldest != nil {
+		delete(c.entries, oldest.key)
+	}
+}
+
+func (c *SemanticCache) record(result string) {
+	llmSemanticCache.WithLabelValues(result).Inc()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch result {
+	case cacheResultExact, cacheResultHit:
+		c.stats.Hits++
+	case cacheResultMiss:
+		c.stats.Misses++
+	case cacheResultSkip:
+		c.stats.Skipped++
+	}
+}
+
+// embed 计算提问的单位向量
+func (c *SemanticCache) embed(ctx context.Context, prompt string) ([]float32, error) {
+	vec, err := c.embedder.Embed(ctx, normalizePrompt(prompt))
+	if err != nil {
+		return nil, err
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vec, nil
+	}
+	norm = math.Sqrt(norm)
+	out := make([]float32, len(vec))
+	for i, v := range vec {
+		out[i] = float32(float64(v) / norm)
+	}
+	return out, nil
+}
+
+// normalizePrompt 忽略大小写与多余空白
+func normalizePrompt(prompt string) string {
+	return strings.Join(strings.Fields(strings.ToLower(prompt)), " ")
+}
+
+// scopeHash scope 可能包含完整的系统提示词，只保存其哈希
+func scopeHash(scope string) string {
+	sum := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(sum[:])
+}
+
+func semanticKey(scope, prompt string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + normalizePrompt(prompt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// cosine 单位向量的余弦相似度，维度不同时视为不相似
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
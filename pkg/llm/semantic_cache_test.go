@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemanticCacheLookup(t *testing.T) {
+	ctx := context.Background()
+	c := NewSemanticCache(nil, SemanticCacheConfig{Threshold: 0.8})
+
+	c.Store(ctx, "gpt-4o", "How do I reset the password for an account?", "Open settings and choose reset.")
+	answer, ok := c.Lookup(ctx, "gpt-4o", "how do I  reset the password for an account")
+	assert.True(t, ok)
+	assert.Equal(t, "Open settings and choose reset.", answer)
+
+	// 措辞相近的问题命中
+	_, ok = c.Lookup(ctx, "gpt-4o", "How can I reset the password for an account?")
+	assert.True(t, ok)
+
+	// 不相关的问题与其他 scope 不命中
+	_, ok = c.Lookup(ctx, "gpt-4o", "What is the capital of France?")
+	assert.False(t, ok)
+	_, ok = c.Lookup(ctx, "gpt-3.5", "How do I reset the password for an account?")
+	assert.False(t, ok)
+
+	c.Store(ctx, "gpt-4o", "今天北京的天气怎么样", "晴")
+	_, ok = c.Lookup(ctx, "gpt-4o", "今天北京天气怎么样？")
+	assert.True(t, ok)
+
+	st := c.Stats()
+	assert.Equal(t, 2, st.Entries)
+	assert.EqualValues(t, 3, st.Hits)
+	assert.EqualValues(t, 2, st.Misses)
+}
+
+func TestSemanticCachePersonalPrompts(t *testing.T) {
+	ctx := context.Background()
+	c := NewSemanticCache(nil, SemanticCacheConfig{})
+
+	for _, prompt := range []string{
+		"Where is my order?",
+		"查询我的订单状态",
+		"Send the invoice to alice@example.com",
+		"What is the status of order 20240501123?",
+	} {
+		assert.True(t, PersonalPrompt(prompt), prompt)
+		c.Store(ctx, "", prompt, "answer")
+		_, ok := c.Lookup(ctx, "", prompt)
+		assert.False(t, ok, prompt)
+	}
+	assert.False(t, PersonalPrompt("Tell me a joke about cats"))
+	assert.Zero(t, c.Stats().Entries)
+	assert.EqualValues(t, 4, c.Stats().Skipped)
+}
+
+func TestSemanticCacheExpiryAndEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewSemanticCache(nil, SemanticCacheConfig{MaxEntries: 2, TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	c.Store(ctx, "", "first question about go", "a")
+	now = now.Add(time.Second)
+	c.Store(ctx, "", "second question about rust", "b")
+	now = now.Add(time.Second)
+	_, ok := c.Lookup(ctx, "", "first question about go")
+	assert.True(t, ok)
+
+	// 已满时淘汰最久未使用的条目
+	c.Store(ctx, "", "third question about python", "c")
+	_, ok = c.Lookup(ctx, "", "second question about rust")
+	assert.False(t, ok)
+	_, ok = c.Lookup(ctx, "", "first question about go")
+	assert.True(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = c.Lookup(ctx, "", "third question about python")
+	assert.False(t, ok)
+}
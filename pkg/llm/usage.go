@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"HibiscusIM/pkg/metrics"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/spf13/cast"
+)
+
+// LLM 提供方
+const (
+	ProviderOpenAI   = "openai"
+	ProviderOllama   = "ollama"
+	ProviderLMStudio = "lmstudio"
+)
+
+// 调用结果
+const (
+	UsageOutcomeSuccess  = "success"
+	UsageOutcomeError    = "error"
+	UsageOutcomeCacheHit = "cache_hit" // 由语义缓存返回，未调用提供方
+)
+
+// UsageEvent 一次 LLM 调用的用量事件
+type UsageEvent struct {
+	Provider         string        `json:"provider"`
+	Model            string        `json:"model"`
+	UserID           string        `json:"userId,omitempty"`
+	Stream           bool          `json:"stream"`
+	Latency          time.Duration `json:"latency"`
+	PromptTokens     int           `json:"promptTokens"`
+	CompletionTokens int           `json:"completionTokens"`
+	TotalTokens      int           `json:"totalTokens"`
+	Outcome          string        `json:"outcome"`
+	Error            string        `json:"error,omitempty"`
+	Time             time.Time     `json:"time"`
+}
+
+// UsageRecorder 用量事件接收方，实现需保证不阻塞调用方
+type UsageRecorder interface {
+	RecordUsage(event UsageEvent)
+}
+
+// UsageRecorderFunc 函数形式的 UsageRecorder
+type UsageRecorderFunc func(event UsageEvent)
+
+// RecordUsage 实现 UsageRecorder
+func (f UsageRecorderFunc) RecordUsage(event UsageEvent) {
+	f(event)
+}
+
+var (
+	usageMu       sync.RWMutex
+	usageRecorder UsageRecorder
+
+	llmRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_requests_total",
+		Help: "Total number of LLM calls",
+	}, []string{"provider", "model", "outcome"})
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_tokens_total",
+		Help: "Total number of LLM tokens consumed",
+	}, []string{"provider", "model", "type"})
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llm_request_duration_seconds",
+		Help:    "LLM call latency in seconds",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"provider", "model"})
+)
+
+// SetUsageRecorder 设置全局用量事件接收方，nil 时仅记录 Prometheus 指标
+func SetUsageRecorder(r UsageRecorder) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usageRecorder = r
+}
+
+// GetUsageRecorder 获取全局用量事件接收方
+func GetUsageRecorder() UsageRecorder {
+	usageMu.RLock()
+	defer usageMu.RUnlock()
+	return usageRecorder
+}
+
+// RecordUsage 记录一次调用：更新 Prometheus 指标与业务指标，并转发给全局接收方
+func RecordUsage(event UsageEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Outcome == "" {
+		event.Outcome = UsageOutcomeSuccess
+	}
+	if event.TotalTokens == 0 {
+		event.TotalTokens = event.PromptTokens + event.CompletionTokens
+	}
+
+	llmRequests.WithLabelValues(event.Provider, event.Model, event.Outcome).Inc()
+	llmTokens.WithLabelValues(event.Provider, event.Model, "prompt").Add(float64(event.PromptTokens))
+	llmTokens.WithLabelValues(event.Provider, event.Model, "completion").Add(float64(event.CompletionTokens))
+	llmDuration.WithLabelValues(event.Provider, event.Model).Observe(event.Latency.Seconds())
+
+	if m := metrics.GetGlobalMonitor(); m != nil && m.GetMetrics() != nil {
+		userType := "anonymous"
+		if event.UserID != "" {
+			userType = "user"
+		}
+		m.GetMetrics().RecordBusinessOperation("llm."+event.Provider, event.Outcome, userType)
+		m.GetMetrics().RecordBusinessDuration("llm."+event.Provider, event.Model, event.Latency)
+	}
+
+	if r := GetUsageRecorder(); r != nil {
+		r.RecordUsage(event)
+	}
+}
+
+// usageTracker 单次调用的用量采集
+type usageTracker struct {
+	event UsageEvent
+	start time.Time
+}
+
+func startUsage(provider, model, userID string, stream bool) *usageTracker {
+	return &usageTracker{
+		event: UsageEvent{Provider: provider, Model: model, UserID: userID, Stream: stream},
+		start: time.Now(),
+	}
+}
+
+// tokens 记录提供方返回的 token 数
+func (u *usageTracker) tokens(prompt, completion, total int) {
+	u.event.PromptTokens = prompt
+	u.event.CompletionTokens = completion
+	u.event.TotalTokens = total
+}
+
+// finish 结束采集并上报
+func (u *usageTracker) finish(err error) {
+	u.event.Latency = time.Since(u.start)
+	if err != nil {
+		u.event.Outcome = UsageOutcomeError
+		u.event.Error = err.Error()
+	}
+	RecordUsage(u.event)
+}
+
+// responseTokens 从 JSON 响应中解析 token 数，兼容 Ollama 的 prompt_eval_count/eval_count 与 OpenAI 风格的 usage
+func responseTokens(resp map[string]interface{}) (prompt, completion, total int) {
+	if u, ok := resp["usage"].(map[string]interface{}); ok {
+		return cast.ToInt(u["prompt_tokens"]), cast.ToInt(u["completion_tokens"]), cast.ToInt(u["total_tokens"])
+	}
+	prompt = cast.ToInt(resp["prompt_eval_count"])
+	completion = cast.ToInt(resp["eval_count"])
+	return prompt, completion, prompt + completion
+}
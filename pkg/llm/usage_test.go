@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	var m dto.Metric
+	require.NoError(t, c.Write(&m))
+	return m.GetCounter().GetValue()
+}
+
+func TestRecordUsage(t *testing.T) {
+	var events []UsageEvent
+	SetUsageRecorder(UsageRecorderFunc(func(ev UsageEvent) { events = append(events, ev) }))
+	defer SetUsageRecorder(nil)
+
+	before := counterValue(t, llmTokens.WithLabelValues(ProviderOpenAI, "usage-test", "prompt"))
+
+	u := startUsage(ProviderOpenAI, "usage-test", "42", true)
+	u.tokens(12, 8, 0)
+	u.finish(nil)
+	startUsage(ProviderOpenAI, "usage-test", "", false).finish(errors.New("boom"))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "42", events[0].UserID)
+	assert.Equal(t, 20, events[0].TotalTokens)
+	assert.Equal(t, UsageOutcomeSuccess, events[0].Outcome)
+	assert.True(t, events[0].Stream)
+	assert.False(t, events[0].Time.IsZero())
+	assert.Equal(t, UsageOutcomeError, events[1].Outcome)
+	assert.Equal(t, "boom", events[1].Error)
+
+	assert.Equal(t, before+12, counterValue(t, llmTokens.WithLabelValues(ProviderOpenAI, "usage-test", "prompt")))
+	assert.Equal(t, 1.0, counterValue(t, llmRequests.WithLabelValues(ProviderOpenAI, "usage-test", UsageOutcomeError)))
+
+	RecordUsage(UsageEvent{Provider: ProviderOllama, Model: "usage-test", Latency: time.Second})
+	assert.Len(t, events, 3)
+}
+
+func TestResponseTokens(t *testing.T) {
+	p, c, total := responseTokens(map[string]interface{}{"prompt_eval_count": float64(5), "eval_count": float64(7)})
+	assert.Equal(t, []int{5, 7, 12}, []int{p, c, total})
+
+	p, c, total = responseTokens(map[string]interface{}{"usage": map[string]interface{}{
+		"prompt_tokens": float64(3), "completion_tokens": float64(4), "total_tokens": float64(7),
+	}})
+	assert.Equal(t, []int{3, 4, 7}, []int{p, c, total})
+
+	p, c, total = responseTokens(map[string]interface{}{})
+	assert.Equal(t, []int{0, 0, 0}, []int{p, c, total})
+}
@@ -0,0 +1,672 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 告警状态
+const (
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// 规则比较运算符
+const (
+	OpGreaterThan    = ">"
+	OpGreaterOrEqual = ">="
+	OpLessThan       = "<"
+	OpLessOrEqual    = "<="
+	OpEqual          = "=="
+	OpNotEqual       = "!="
+)
+
+// maxResolvedAlerts 保留的已恢复告警数
+const maxResolvedAlerts = 200
+
+// 系统资源阈值对应的内置告警规则，规则文件中的同名规则会覆盖
+const (
+	RuleSystemCPUHigh    = "system_cpu_high"
+	RuleSystemMemoryHigh = "system_memory_high"
+)
+
+var (
+	// ErrAlertRuleNotFound 告警规则不存在
+	ErrAlertRuleNotFound = errors.New("alert rule not found")
+	// ErrAlertRuleExists 告警规则已存在
+	ErrAlertRuleExists = errors.New("alert rule already exists")
+)
+
+// AlertRule 告警规则：指标值满足条件并持续 For 后触发
+//
+// Metric 取值：
+//   - 系统指标：cpu.usage_percent、memory.usage_percent、disk.usage_percent、load.1、
+//     process.cpu_percent、process.memory_percent、process.num_fds、runtime.goroutines、
+//     runtime.heap_alloc、network.connections
+//   - SQL 指标：sql.total_queries、sql.slow_queries、sql.error_rate、sql.avg_duration_ms
+//   - 自定义指标：custom.<name>，取最近一次采样值
+//   - 扩展指标：通过 Monitor.RegisterMetricSource 注册的前缀，如 backup.consecutive_failures
+type AlertRule struct {
+	Name        string            `json:"name"`
+	Metric      string            `json:"metric"`
+	Operator    string            `json:"operator"`
+	Threshold   float64           `json:"threshold"`
+	For         string            `json:"for,omitempty"` // 持续时长，如 5m，为空时立即触发
+	Severity    string            `json:"severity,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Disabled    bool              `json:"disabled,omitempty"`
+
+	forDuration time.Duration
+}
+
+// validate 校验规则并解析持续时长
+func (r *AlertRule) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("rule name required")
+	}
+	if strings.TrimSpace(r.Metric) == "" {
+		return errors.New("rule metric required")
+	}
+	if r.Operator == "" {
+		r.Operator = OpGreaterThan
+	}
+	switch r.Operator {
+	case OpGreaterThan, OpGreaterOrEqual, OpLessThan, OpLessOrEqual, OpEqual, OpNotEqual:
+	default:
+		return fmt.Errorf("unsupported operator: %s", r.Operator)
+	}
+	r.forDuration = 0
+	if r.For != "" {
+		d, err := time.ParseDuration(r.For)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid for duration: %s", r.For)
+		}
+		r.forDuration = d
+	}
+	return nil
+}
+
+// match 判断指标值是否满足告警条件
+func (r *AlertRule) match(value float64) bool {
+	switch r.Operator {
+	case OpGreaterThan:
+		return value > r.Threshold
+	case OpGreaterOrEqual:
+		return value >= r.Threshold
+	case OpLessThan:
+		return value < r.Threshold
+	case OpLessOrEqual:
+		return value <= r.Threshold
+	case OpEqual:
+		return value == r.Threshold
+	case OpNotEqual:
+		return value != r.Threshold
+	}
+	return false
+}
+
+// labels 告警标签，包含规则标签与 alertname/severity
+func (r *AlertRule) labels() map[string]string {
+	labels := map[string]string{"alertname": r.Name}
+	if r.Severity != "" {
+		labels["severity"] = r.Severity
+	}
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// Alert 告警实例
+type Alert struct {
+	Rule        string            `json:"rule"`
+	Metric      string            `json:"metric"`
+	State       string            `json:"state"`
+	Value       float64           `json:"value"`
+	Threshold   float64           `json:"threshold"`
+	Operator    string            `json:"operator"`
+	Severity    string            `json:"severity,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Suppressed  bool              `json:"suppressed"`
+	ActiveAt    time.Time         `json:"activeAt"`
+	FiredAt     time.Time         `json:"firedAt,omitempty"`
+	ResolvedAt  time.Time         `json:"resolvedAt,omitempty"`
+	LastEvalAt  time.Time         `json:"lastEvalAt"`
+}
+
+func (a *Alert) clone() *Alert {
+	cp := *a
+	cp.Labels = copyStringMap(a.Labels)
+	cp.Annotations = copyStringMap(a.Annotations)
+	return &cp
+}
+
+// Notifier 告警通知渠道，告警触发与恢复时调用
+type Notifier interface {
+	Notify(ctx context.Context, alert *Alert) error
+}
+
+// NotifierFunc 函数形式的通知渠道
+type NotifierFunc func(ctx context.Context, alert *Alert) error
+
+// Notify 实现 Notifier
+func (f NotifierFunc) Notify(ctx context.Context, alert *Alert) error {
+	return f(ctx, alert)
+}
+
+// MetricSource 按名称读取指标当前值，指标不存在或暂无数据时返回 false
+type MetricSource func(name string) (float64, bool)
+
+// AlertEngine 告警引擎，定期按规则评估指标，维护 pending/firing/resolved 状态并发送通知
+type AlertEngine struct {
+	mu        sync.RWMutex
+	rules     map[string]*AlertRule
+	active    map[string]*Alert
+	resolved  []*Alert
+	notifiers []Notifier
+	source    MetricSource
+	silences  *SilenceManager
+	interval  time.Duration
+	stop      chan struct{}
+	running   bool
+	now       func() time.Time
+}
+
+// NewAlertEngine 创建告警引擎，silences 为 nil 时不做静默检查
+func NewAlertEngine(source MetricSource, silences *SilenceManager, interval time.Duration) *AlertEngine {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &AlertEngine{
+		rules:    make(map[string]*AlertRule),
+		active:   make(map[string]*Alert),
+		source:   source,
+		silences: silences,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// AddNotifier 添加通知渠道
+func (e *AlertEngine) AddNotifier(n Notifier) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.notifiers = append(e.notifiers, n)
+}
+
+// AddRule 添加告警规则
+func (e *AlertEngine) AddRule(rule AlertRule) (*AlertRule, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.rules[rule.Name]; ok {
+		return nil, ErrAlertRuleExists
+	}
+	e.rules[rule.Name] = &rule
+	cp := rule
+	return &cp, nil
+}
+
+// UpdateRule 更新告警规则，已有告警状态保留，下次评估按新规则计算
+func (e *AlertEngine) UpdateRule(rule AlertRule) (*AlertRule, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.rules[rule.Name]; !ok {
+		return nil, ErrAlertRuleNotFound
+	}
+	e.rules[rule.Name] = &rule
+	cp := rule
+	return &cp, nil
+}
+
+// setResourceRules 按资源阈值写入内置告警规则，阈值为 0 的规则停用
+func (e *AlertEngine) setResourceRules(t ResourceThresholds) {
+	rules := []AlertRule{
+		{Name: RuleSystemCPUHigh, Metric: "cpu.usage_percent", Threshold: t.CPUPercent, Disabled: t.CPUPercent <= 0},
+		{Name: RuleSystemMemoryHigh, Metric: "memory.usage_percent", Threshold: t.MemoryPercent, Disabled: t.MemoryPercent <= 0},
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range rules {
+		rule := rules[i]
+		rule.Operator = OpGreaterThan
+		rule.Severity = "warning"
+		_ = rule.validate()
+		e.rules[rule.Name] = &rule
+	}
+}
+
+// DeleteRule 删除告警规则及其未恢复的告警
+func (e *AlertEngine) DeleteRule(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.rules[name]; !ok {
+		return ErrAlertRuleNotFound
+	}
+	delete(e.rules, name)
+	delete(e.active, name)
+	return nil
+}
+
+// LoadRulesFile 从 JSON 文件加载告警规则（规则数组），同名规则覆盖
+func (e *AlertEngine) LoadRulesFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var rules []AlertRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return 0, err
+	}
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return 0, fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range rules {
+		rule := rules[i]
+		e.rules[rule.Name] = &rule
+	}
+	return len(rules), nil
+}
+
+// ListRules 列出告警规则，按名称排序
+func (e *AlertEngine) ListRules() []AlertRule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	list := make([]AlertRule, 0, len(e.rules))
+	for _, r := range e.rules {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Alerts 当前 pending/firing 的告警，includeResolved 时附带最近恢复的告警
+func (e *AlertEngine) Alerts(includeResolved bool) []*Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	list := make([]*Alert, 0, len(e.active))
+	for _, a := range e.active {
+		list = append(list, a.clone())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ActiveAt.After(list[j].ActiveAt) })
+	if includeResolved {
+		for i := len(e.resolved) - 1; i >= 0; i-- {
+			list = append(list, e.resolved[i].clone())
+		}
+	}
+	return list
+}
+
+// Start 启动定时评估
+func (e *AlertEngine) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return
+	}
+	e.running = true
+	e.stop = make(chan struct{})
+	go e.loop(e.stop)
+}
+
+// Stop 停止定时评估
+func (e *AlertEngine) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.running {
+		return
+	}
+	e.running = false
+	close(e.stop)
+}
+
+func (e *AlertEngine) loop(stop chan struct{}) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.Evaluate()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Evaluate 按规则评估一次，状态变为 firing 或 resolved 时发送通知
+func (e *AlertEngine) Evaluate() {
+	now := e.now()
+	var notify []*Alert
+
+	e.mu.Lock()
+	for name, rule := range e.rules {
+		if rule.Disabled {
+			if a, ok := e.active[name]; ok && a.State == AlertStateFiring {
+				notify = append(notify, e.resolveLocked(a, now))
+			}
+			delete(e.active, name)
+			continue
+		}
+		value, ok := e.source(rule.Metric)
+		if !ok {
+			// 暂无数据时保持现有状态
+			continue
+		}
+		alert, exists := e.active[name]
+		if !rule.match(value) {
+			if exists {
+				if alert.State == AlertStateFiring {
+					alert.Value = value
+					notify = append(notify, e.resolveLocked(alert, now))
+				}
+				delete(e.active, name)
+			}
+			continue
+		}
+
+		if !exists {
+			alert = &Alert{
+				Rule:        rule.Name,
+				Metric:      rule.Metric,
+				State:       AlertStatePending,
+				Severity:    rule.Severity,
+				Labels:      rule.labels(),
+				Annotations: copyStringMap(rule.Annotations),
+				ActiveAt:    now,
+			}
+			e.active[name] = alert
+		}
+		alert.Value = value
+		alert.Threshold = rule.Threshold
+		alert.Operator = rule.Operator
+		alert.LastEvalAt = now
+		switch {
+		case alert.State == AlertStatePending && now.Sub(alert.ActiveAt) >= rule.forDuration:
+			alert.State = AlertStateFiring
+			alert.FiredAt = now
+			// 被静默或处于维护窗口时仍记为 firing，但不发送通知
+			if e.suppressLocked(alert) {
+				continue
+			}
+			notify = append(notify, alert.clone())
+		case alert.State == AlertStateFiring && alert.Suppressed && !e.suppressLocked(alert):
+			// 静默结束后告警仍在，补发通知
+			notify = append(notify, alert.clone())
+		}
+	}
+	notifiers := append([]Notifier(nil), e.notifiers...)
+	e.mu.Unlock()
+
+	for _, a := range notify {
+		e.dispatch(notifiers, a)
+	}
+}
+
+// suppressLocked 检查告警是否被静默，并同步抑制注解，调用方需持有写锁
+func (e *AlertEngine) suppressLocked(alert *Alert) bool {
+	for _, k := range []string{AnnotationSilencedBy, AnnotationSilenceComment, AnnotationMaintenanceWindow, AnnotationSuppressedUntil} {
+		delete(alert.Annotations, k)
+	}
+	alert.Suppressed = false
+	if e.silences == nil {
+		return false
+	}
+	if alert.Annotations == nil {
+		alert.Annotations = map[string]string{}
+	}
+	alert.Suppressed = e.silences.Annotate(alert.Rule, alert.Labels, alert.Annotations)
+	return alert.Suppressed
+}
+
+// resolveLocked 标记告警恢复并记入历史，返回用于通知的副本，调用方需持有写锁
+func (e *AlertEngine) resolveLocked(alert *Alert, now time.Time) *Alert {
+	alert.State = AlertStateResolved
+	alert.ResolvedAt = now
+	alert.LastEvalAt = now
+	e.resolved = append(e.resolved, alert)
+	if len(e.resolved) > maxResolvedAlerts {
+		e.resolved = e.resolved[len(e.resolved)-maxResolvedAlerts:]
+	}
+	if alert.Suppressed {
+		return nil
+	}
+	return alert.clone()
+}
+
+func (e *AlertEngine) dispatch(notifiers []Notifier, alert *Alert) {
+	if alert == nil {
+		return
+	}
+	for _, n := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := n.Notify(ctx, alert); err != nil {
+			log.Printf("alert notify failed: rule=%s state=%s: %v", alert.Rule, alert.State, err)
+		}
+		cancel()
+	}
+}
+
+// WebhookNotifier 以 JSON POST 告警到 Webhook 地址
+type WebhookNotifier struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// NewWebhookNotifier 创建 Webhook 通知渠道
+func NewWebhookNotifier(url string, headers map[string]string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Headers: headers, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Notify 实现 Notifier
+func (w *WebhookNotifier) Notify(ctx context.Context, alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MailSender 邮件发送接口，notification.MailNotification 实现了该接口
+type MailSender interface {
+	Send(to, subject, body string) error
+}
+
+// EmailNotifier 以邮件发送告警
+type EmailNotifier struct {
+	Sender MailSender
+	To     []string
+}
+
+// NewEmailNotifier 创建邮件通知渠道，忽略空白收件人
+func NewEmailNotifier(sender MailSender, to ...string) *EmailNotifier {
+	n := &EmailNotifier{Sender: sender}
+	for _, addr := range to {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			n.To = append(n.To, addr)
+		}
+	}
+	return n
+}
+
+// Notify 实现 Notifier
+func (n *EmailNotifier) Notify(ctx context.Context, alert *Alert) error {
+	subject := fmt.Sprintf("[%s] %s", strings.ToUpper(alert.State), alert.Rule)
+	var b strings.Builder
+	fmt.Fprintf(&b, "告警规则：%s\n", alert.Rule)
+	fmt.Fprintf(&b, "状态：%s\n", alert.State)
+	fmt.Fprintf(&b, "指标：%s = %g（条件 %s %g）\n", alert.Metric, alert.Value, alert.Operator, alert.Threshold)
+	if alert.Severity != "" {
+		fmt.Fprintf(&b, "级别：%s\n", alert.Severity)
+	}
+	fmt.Fprintf(&b, "开始时间：%s\n", alert.ActiveAt.Format(time.RFC3339))
+	if !alert.ResolvedAt.IsZero() {
+		fmt.Fprintf(&b, "恢复时间：%s\n", alert.ResolvedAt.Format(time.RFC3339))
+	}
+	keys := make([]string, 0, len(alert.Annotations))
+	for k := range alert.Annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s：%s\n", k, alert.Annotations[k])
+	}
+	var errs []error
+	for _, to := range n.To {
+		if err := n.Sender.Send(to, subject, b.String()); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterMetricSource 注册以 prefix（如 backup.）开头的告警指标，source 收到去掉前缀后的名称
+func (m *Monitor) RegisterMetricSource(prefix string, source MetricSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sources == nil {
+		m.sources = make(map[string]MetricSource)
+	}
+	m.sources[prefix] = source
+}
+
+// alertMetricValue 从系统监控、SQL分析与扩展指标读取告警指标
+func (m *Monitor) alertMetricValue(name string) (float64, bool) {
+	m.mu.RLock()
+	for prefix, source := range m.sources {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			m.mu.RUnlock()
+			return source(rest)
+		}
+	}
+	m.mu.RUnlock()
+	if custom, ok := strings.CutPrefix(name, "custom."); ok {
+		if m.systemMonitor == nil {
+			return 0, false
+		}
+		series, ok := m.systemMonitor.GetCustomMetricHistory(custom, time.Time{}, 1)
+		if !ok || len(series.Points) == 0 {
+			return 0, false
+		}
+		return series.Points[len(series.Points)-1].Value, true
+	}
+	if sqlMetric, ok := strings.CutPrefix(name, "sql."); ok {
+		return m.sqlMetricValue(sqlMetric)
+	}
+
+	stats := m.GetLatestSystemStats()
+	if stats == nil {
+		return 0, false
+	}
+	switch name {
+	case "cpu.usage_percent":
+		return stats.CPU.UsagePercent, true
+	case "memory.usage_percent":
+		return stats.Memory.UsagePercent, true
+	case "disk.usage_percent":
+		return stats.Disk.UsagePercent, true
+	case "load.1":
+		if len(stats.CPU.LoadAvg) == 0 {
+			return 0, false
+		}
+		return stats.CPU.LoadAvg[0], true
+	case "process.cpu_percent":
+		return stats.Process.CPUPercent, true
+	case "process.memory_percent":
+		return float64(stats.Process.MemoryPercent), true
+	case "process.num_fds":
+		return float64(stats.Process.NumFDs), true
+	case "runtime.goroutines":
+		return float64(stats.Runtime.Goroutines), true
+	case "runtime.heap_alloc":
+		return float64(stats.Runtime.HeapAlloc), true
+	case "network.connections":
+		return float64(stats.Network.Connections), true
+	}
+	return 0, false
+}
+
+// sqlMetricValue 读取SQL分析统计
+func (m *Monitor) sqlMetricValue(name string) (float64, bool) {
+	if m.sqlAnalyzer == nil {
+		return 0, false
+	}
+	stats := m.sqlAnalyzer.GetQueryStats()
+	switch name {
+	case "total_queries":
+		v, ok := stats["total_queries"].(int)
+		return float64(v), ok
+	case "slow_queries":
+		v, ok := stats["slow_queries"].(int)
+		return float64(v), ok
+	case "error_rate":
+		v, ok := stats["error_rate"].(float64)
+		return v, ok
+	case "avg_duration_ms":
+		s, ok := stats["avg_duration"].(string)
+		if !ok {
+			return 0, false
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, false
+		}
+		return float64(d) / float64(time.Millisecond), true
+	}
+	return 0, false
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	cp := make(map[string]string, len(m))
+	for k, v := range m {
+		cp[k] = v
+	}
+	return cp
+}
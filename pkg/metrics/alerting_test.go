@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertEngineStateMachine(t *testing.T) {
+	values := map[string]float64{}
+	source := func(name string) (float64, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+	now := time.Now()
+	silences := NewSilenceManager()
+	engine := NewAlertEngine(source, silences, time.Minute)
+	engine.now = func() time.Time { return now }
+
+	var notified []*Alert
+	engine.AddNotifier(NotifierFunc(func(ctx context.Context, a *Alert) error {
+		notified = append(notified, a)
+		return nil
+	}))
+
+	_, err := engine.AddRule(AlertRule{Name: "HighCPU", Metric: "cpu.usage_percent", Threshold: 90, For: "2m", Severity: "critical"})
+	require.NoError(t, err)
+	_, err = engine.AddRule(AlertRule{Name: "HighCPU", Metric: "cpu.usage_percent"})
+	assert.ErrorIs(t, err, ErrAlertRuleExists)
+	_, err = engine.AddRule(AlertRule{Name: "Bad", Metric: "x", Operator: "~"})
+	assert.Error(t, err)
+
+	// 暂无数据不产生告警
+	engine.Evaluate()
+	assert.Empty(t, engine.Alerts(false))
+
+	values["cpu.usage_percent"] = 95
+	engine.Evaluate()
+	alerts := engine.Alerts(false)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, AlertStatePending, alerts[0].State)
+	assert.Empty(t, notified)
+
+	now = now.Add(3 * time.Minute)
+	engine.Evaluate()
+	alerts = engine.Alerts(false)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, AlertStateFiring, alerts[0].State)
+	assert.Equal(t, "critical", alerts[0].Labels["severity"])
+	require.Len(t, notified, 1)
+	assert.Equal(t, AlertStateFiring, notified[0].State)
+
+	// 持续触发不重复通知
+	now = now.Add(time.Minute)
+	engine.Evaluate()
+	assert.Len(t, notified, 1)
+
+	values["cpu.usage_percent"] = 50
+	engine.Evaluate()
+	assert.Empty(t, engine.Alerts(false))
+	require.Len(t, notified, 2)
+	assert.Equal(t, AlertStateResolved, notified[1].State)
+	resolved := engine.Alerts(true)
+	require.Len(t, resolved, 1)
+	assert.Equal(t, AlertStateResolved, resolved[0].State)
+
+	// 条件在持续时长内恢复时不触发
+	values["cpu.usage_percent"] = 99
+	engine.Evaluate()
+	values["cpu.usage_percent"] = 10
+	engine.Evaluate()
+	assert.Empty(t, engine.Alerts(false))
+	assert.Len(t, notified, 2)
+
+	// 静默期间触发不通知，静默结束后补发
+	silences.now = func() time.Time { return now }
+	s, err := silences.AddSilence(Silence{AlertName: "HighCPU", EndsAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	values["cpu.usage_percent"] = 99
+	engine.Evaluate()
+	now = now.Add(3 * time.Minute)
+	engine.Evaluate()
+	alerts = engine.Alerts(false)
+	require.Len(t, alerts, 1)
+	assert.True(t, alerts[0].Suppressed)
+	assert.Equal(t, s.ID, alerts[0].Annotations[AnnotationSilencedBy])
+	assert.Len(t, notified, 2)
+
+	require.NoError(t, silences.ExpireSilence(s.ID))
+	engine.Evaluate()
+	require.Len(t, notified, 3)
+	assert.False(t, notified[2].Suppressed)
+	assert.NotContains(t, notified[2].Annotations, AnnotationSilencedBy)
+
+	require.NoError(t, engine.DeleteRule("HighCPU"))
+	assert.Empty(t, engine.Alerts(false))
+	assert.ErrorIs(t, engine.DeleteRule("HighCPU"), ErrAlertRuleNotFound)
+}
+
+func TestWebhookAndEmailNotifier(t *testing.T) {
+	var got Alert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	alert := &Alert{Rule: "SlowSQL", Metric: "sql.avg_duration_ms", State: AlertStateFiring, Value: 250, Operator: ">", Threshold: 200, ActiveAt: time.Now()}
+	require.NoError(t, NewWebhookNotifier(srv.URL, nil).Notify(context.Background(), alert))
+	assert.Equal(t, "SlowSQL", got.Rule)
+	assert.Equal(t, 250.0, got.Value)
+
+	mail := &fakeMailSender{}
+	require.NoError(t, NewEmailNotifier(mail, "ops@example.com", " ").Notify(context.Background(), alert))
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, "ops@example.com", mail.sent[0][0])
+	assert.Equal(t, "[FIRING] SlowSQL", mail.sent[0][1])
+	assert.Contains(t, mail.sent[0][2], "sql.avg_duration_ms = 250")
+}
+
+type fakeMailSender struct {
+	sent [][3]string
+}
+
+func (f *fakeMailSender) Send(to, subject, body string) error {
+	f.sent = append(f.sent, [3]string{to, subject, body})
+	return nil
+}
+
+func TestMonitorAlertMetrics(t *testing.T) {
+	m := NewMonitor(&MonitorConfig{EnableSQLAnalysis: true, MaxQueries: 100, SlowThreshold: 100 * time.Millisecond, EnableAlerting: true})
+	m.RecordSQLQuery(context.Background(), "select 1", nil, "t", "select", 300*time.Millisecond, 1, nil)
+	m.RecordSQLQuery(context.Background(), "select 2", nil, "t", "select", 100*time.Millisecond, 1, nil)
+
+	v, ok := m.alertMetricValue("sql.avg_duration_ms")
+	require.True(t, ok)
+	assert.Equal(t, 200.0, v)
+	v, ok = m.alertMetricValue("sql.total_queries")
+	require.True(t, ok)
+	assert.Equal(t, 2.0, v)
+	_, ok = m.alertMetricValue("cpu.usage_percent")
+	assert.False(t, ok)
+	require.NotNil(t, m.GetAlertEngine())
+}
+
+func TestMonitorMetricSource(t *testing.T) {
+	m := NewMonitor(&MonitorConfig{EnableAlerting: true})
+	m.RegisterMetricSource("backup.", func(name string) (float64, bool) {
+		return 3, name == "consecutive_failures"
+	})
+
+	v, ok := m.alertMetricValue("backup.consecutive_failures")
+	require.True(t, ok)
+	assert.Equal(t, 3.0, v)
+	_, ok = m.alertMetricValue("backup.unknown")
+	assert.False(t, ok)
+	_, ok = m.alertMetricValue("cpu.usage_percent")
+	assert.False(t, ok)
+}
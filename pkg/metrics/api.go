@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 	"strconv"
@@ -22,14 +23,22 @@ func NewMonitorAPI(monitor *Monitor) *MonitorAPI {
 	}
 }
 
-// RegisterRoutes 注册监控API路由
-func (api *MonitorAPI) RegisterRoutes(r *gin.RouterGroup) {
+// RegisterRoutes 注册监控API路由，admin 为修改告警规则、静默与维护窗口等写操作，
+// 以及查看包含请求头与请求体的慢请求样本前执行的鉴权中间件
+func (api *MonitorAPI) RegisterRoutes(r *gin.RouterGroup, admin ...gin.HandlerFunc) {
+	protected := func(h gin.HandlerFunc) []gin.HandlerFunc {
+		return append(append([]gin.HandlerFunc{}, admin...), h)
+	}
+
 	// 系统概览
 	r.GET("/overview", api.GetOverview)
 
 	// 系统监控
 	r.GET("/system", api.GetSystemStats)
 	r.GET("/system/latest", api.GetLatestSystemStats)
+	r.GET("/system/history", api.GetSystemHistory)
+	r.GET("/custom-metrics", api.ListCustomMetrics)
+	r.GET("/custom-metrics/:name", api.GetCustomMetricHistory)
 
 	// SQL分析
 	r.GET("/sql/slow", api.GetSlowQueries)
@@ -38,6 +47,10 @@ func (api *MonitorAPI) RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/sql/table/:table", api.GetQueriesByTable)
 	r.GET("/sql/operation/:operation", api.GetQueriesByOperation)
 
+	// 慢请求样本
+	r.GET("/http/slow", protected(api.GetSlowHTTPRequests)...)
+	r.GET("/http/slow/:id", protected(api.GetSlowHTTPRequest)...)
+
 	// 链路追踪
 	r.GET("/traces", api.GetTraces)
 	r.GET("/traces/:traceID", api.GetTraceDetail)
@@ -46,6 +59,22 @@ func (api *MonitorAPI) RegisterRoutes(r *gin.RouterGroup) {
 	r.GET("/metrics", api.GetMetrics)
 	r.GET("/metrics/prometheus", api.GetPrometheusMetrics)
 
+	// 告警
+	r.GET("/alerts", api.GetAlerts)
+	r.GET("/alerts/rules", api.ListAlertRules)
+	r.POST("/alerts/rules", protected(api.CreateAlertRule)...)
+	r.PUT("/alerts/rules/:name", protected(api.UpdateAlertRule)...)
+	r.DELETE("/alerts/rules/:name", protected(api.DeleteAlertRule)...)
+
+	// 告警静默与维护窗口
+	r.GET("/alerts/silences", api.ListSilences)
+	r.POST("/alerts/silences", protected(api.CreateSilence)...)
+	r.DELETE("/alerts/silences/:id", protected(api.ExpireSilence)...)
+	r.GET("/alerts/maintenance", api.ListMaintenanceWindows)
+	r.POST("/alerts/maintenance", protected(api.CreateMaintenanceWindow)...)
+	r.DELETE("/alerts/maintenance/:id", protected(api.DeleteMaintenanceWindow)...)
+	r.POST("/alerts/check", api.CheckSuppression)
+
 	RegisterMonitorUI(r, api)
 }
 
@@ -82,6 +111,52 @@ func (api *MonitorAPI) GetSystemStats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": stats})
 }
 
+// GetSystemHistory 按时间范围获取 CPU/内存/磁盘使用率历史，from/to 支持 RFC3339、毫秒时间戳或相对时长（如 168h），
+// from 默认 1h、to 默认当前时间；resolution 可指定 raw/1m/5m/1h，为空时按范围与 max_points 自动选择
+func (api *MonitorAPI) GetSystemHistory(c *gin.Context) {
+	sm := api.monitor.GetSystemMonitor()
+	if sm == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "system monitor disabled"})
+		return
+	}
+	now := time.Now()
+	from, err := parseTimeQuery(c.DefaultQuery("from", "1h"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid from"})
+		return
+	}
+	to := now
+	if v := c.Query("to"); v != "" {
+		if to, err = parseTimeQuery(v, now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid to"})
+			return
+		}
+	}
+	maxPoints, _ := strconv.Atoi(c.DefaultQuery("max_points", "0"))
+
+	history, err := sm.GetStatsRange(from, to, c.Query("resolution"), maxPoints)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": history})
+}
+
+// parseTimeQuery 解析时间参数：相对时长表示 now 之前，否则为 RFC3339 或毫秒时间戳
+func parseTimeQuery(v string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
 // GetLatestSystemStats 获取最新系统统计
 func (api *MonitorAPI) GetLatestSystemStats(c *gin.Context) {
 	stats := api.monitor.GetLatestSystemStats()
@@ -91,6 +166,45 @@ func (api *MonitorAPI) GetLatestSystemStats(c *gin.Context) {
 	})
 }
 
+// ListCustomMetrics 获取自定义指标列表
+func (api *MonitorAPI) ListCustomMetrics(c *gin.Context) {
+	sm := api.monitor.GetSystemMonitor()
+	if sm == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": []CustomMetricInfo{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": sm.ListCustomMetrics()})
+}
+
+// GetCustomMetricHistory 获取自定义指标历史，since 支持 RFC3339 时间或相对时长（如 1h）
+func (api *MonitorAPI) GetCustomMetricHistory(c *gin.Context) {
+	sm := api.monitor.GetSystemMonitor()
+	if sm == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "system monitor disabled"})
+		return
+	}
+
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, v); err == nil {
+			since = t
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid since"})
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+
+	series, ok := sm.GetCustomMetricHistory(c.Param("name"), since, limit)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "metric not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": series})
+}
+
 // GetSlowQueries 获取慢查询列表
 func (api *MonitorAPI) GetSlowQueries(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
@@ -114,7 +228,7 @@ func (api *MonitorAPI) GetSlowQueries(c *gin.Context) {
 		}
 		all = all[start:end]
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": all, "page": page, "limit": limit})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": redactQueries(all), "page": page, "limit": limit})
 }
 
 func (api *MonitorAPI) GetQueryPatterns(c *gin.Context) {
@@ -168,7 +282,7 @@ func (api *MonitorAPI) GetQueriesByTable(c *gin.Context) {
 	queries := api.monitor.GetQueriesByTable(table, limit)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    queries,
+		"data":    redactQueries(queries),
 	})
 }
 
@@ -181,7 +295,7 @@ func (api *MonitorAPI) GetQueriesByOperation(c *gin.Context) {
 	queries := api.monitor.GetQueriesByOperation(operation, limit)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    queries,
+		"data":    redactQueries(queries),
 	})
 }
 
@@ -233,13 +347,62 @@ func (api *MonitorAPI) GetTraces(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    filtered,
+		"data":    redactSpans(filtered),
 		"page":    page,
 		"limit":   limit,
 		"total":   len(all),
 	})
 }
 
+// GetSlowHTTPRequests 最近的慢请求样本，query: route 按路由过滤，limit 默认 50
+func (api *MonitorAPI) GetSlowHTTPRequests(c *gin.Context) {
+	rec := api.monitor.GetSlowHTTPRecorder()
+	if rec == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": []*SlowHTTPRequest{}})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 {
+		limit = 50
+	}
+	base := strings.TrimSuffix(c.FullPath(), "/http/slow")
+	samples := rec.List(c.Query("route"), limit)
+	items := make([]gin.H, 0, len(samples))
+	for _, s := range samples {
+		items = append(items, slowHTTPItem(base, s))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"data":      items,
+		"threshold": rec.Threshold().String(),
+	})
+}
+
+// GetSlowHTTPRequest 慢请求样本详情
+func (api *MonitorAPI) GetSlowHTTPRequest(c *gin.Context) {
+	rec := api.monitor.GetSlowHTTPRecorder()
+	if rec == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "slow request capture is disabled"})
+		return
+	}
+	sample, ok := rec.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "slow request not found"})
+		return
+	}
+	base := strings.TrimSuffix(c.FullPath(), "/http/slow/:id")
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": slowHTTPItem(base, sample)})
+}
+
+// slowHTTPItem 慢请求样本及其链路详情地址
+func slowHTTPItem(base string, s *SlowHTTPRequest) gin.H {
+	item := gin.H{"request": s}
+	if s.TraceID != "" {
+		item["trace_url"] = base + "/traces/" + s.TraceID
+	}
+	return item
+}
+
 // GetTraceDetail 获取追踪详情
 func (api *MonitorAPI) GetTraceDetail(c *gin.Context) {
 	traceID := c.Param("traceID")
@@ -255,7 +418,7 @@ func (api *MonitorAPI) GetTraceDetail(c *gin.Context) {
 	spans := api.monitor.GetTraceSpans(traceID)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data":    spans,
+		"data":    redactSpans(spans),
 	})
 }
 
@@ -286,3 +449,231 @@ func (api *MonitorAPI) GetPrometheusMetrics(c *gin.Context) {
 	c.Header("Content-Type", "text/plain")
 	c.String(http.StatusOK, "# Prometheus metrics are automatically exposed at /metrics endpoint\n# This endpoint is for compatibility only")
 }
+
+// GetAlerts 获取当前告警，resolved=true 时附带最近恢复的告警
+func (api *MonitorAPI) GetAlerts(c *gin.Context) {
+	engine := api.monitor.GetAlertEngine()
+	if engine == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": []*Alert{}})
+		return
+	}
+	resolved, _ := strconv.ParseBool(c.DefaultQuery("resolved", "false"))
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": engine.Alerts(resolved)})
+}
+
+// ListAlertRules 获取告警规则列表
+func (api *MonitorAPI) ListAlertRules(c *gin.Context) {
+	engine := api.monitor.GetAlertEngine()
+	if engine == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": []AlertRule{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": engine.ListRules()})
+}
+
+// CreateAlertRule 创建告警规则
+func (api *MonitorAPI) CreateAlertRule(c *gin.Context) {
+	engine := api.monitor.GetAlertEngine()
+	if engine == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "alerting disabled"})
+		return
+	}
+	var rule AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	created, err := engine.AddRule(rule)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrAlertRuleExists) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": created})
+}
+
+// UpdateAlertRule 更新告警规则
+func (api *MonitorAPI) UpdateAlertRule(c *gin.Context) {
+	engine := api.monitor.GetAlertEngine()
+	if engine == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "alerting disabled"})
+		return
+	}
+	var rule AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	rule.Name = c.Param("name")
+	updated, err := engine.UpdateRule(rule)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrAlertRuleNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// DeleteAlertRule 删除告警规则
+func (api *MonitorAPI) DeleteAlertRule(c *gin.Context) {
+	engine := api.monitor.GetAlertEngine()
+	if engine == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "alerting disabled"})
+		return
+	}
+	if err := engine.DeleteRule(c.Param("name")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// silenceRequest 创建静默规则/维护窗口的请求，Duration 与 EndsAt 二选一
+type silenceRequest struct {
+	Name      string            `json:"name"`
+	AlertName string            `json:"alertName"`
+	Labels    map[string]string `json:"labels"`
+	StartsAt  time.Time         `json:"startsAt"`
+	EndsAt    time.Time         `json:"endsAt"`
+	Duration  string            `json:"duration"`
+	Comment   string            `json:"comment"`
+	CreatedBy string            `json:"createdBy"`
+}
+
+// timeRange 解析请求中的生效时间范围
+func (req *silenceRequest) timeRange() (time.Time, time.Time, error) {
+	start := req.StartsAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	end := req.EndsAt
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return start, end, err
+		}
+		end = start.Add(d)
+	}
+	return start, end, nil
+}
+
+// ListSilences 获取静默规则列表，all=true 时包含已过期规则
+func (api *MonitorAPI) ListSilences(c *gin.Context) {
+	all, _ := strconv.ParseBool(c.DefaultQuery("all", "false"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    api.monitor.GetSilenceManager().ListSilences(all),
+	})
+}
+
+// CreateSilence 创建静默规则
+func (api *MonitorAPI) CreateSilence(c *gin.Context) {
+	var req silenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if req.AlertName == "" && len(req.Labels) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "alertName or labels required"})
+		return
+	}
+	start, end, err := req.timeRange()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	s, err := api.monitor.GetSilenceManager().AddSilence(Silence{
+		AlertName: req.AlertName,
+		Labels:    req.Labels,
+		StartsAt:  start,
+		EndsAt:    end,
+		Comment:   req.Comment,
+		CreatedBy: req.CreatedBy,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": s})
+}
+
+// ExpireSilence 立即结束静默规则
+func (api *MonitorAPI) ExpireSilence(c *gin.Context) {
+	if err := api.monitor.GetSilenceManager().ExpireSilence(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListMaintenanceWindows 获取维护窗口列表，all=true 时包含已结束窗口
+func (api *MonitorAPI) ListMaintenanceWindows(c *gin.Context) {
+	all, _ := strconv.ParseBool(c.DefaultQuery("all", "false"))
+	sm := api.monitor.GetSilenceManager()
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"data":          sm.ListMaintenanceWindows(all),
+		"inMaintenance": sm.InMaintenance(),
+	})
+}
+
+// CreateMaintenanceWindow 创建维护窗口，如发布前调用
+func (api *MonitorAPI) CreateMaintenanceWindow(c *gin.Context) {
+	var req silenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	start, end, err := req.timeRange()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	w, err := api.monitor.GetSilenceManager().AddMaintenanceWindow(MaintenanceWindow{
+		Name:      req.Name,
+		Labels:    req.Labels,
+		StartsAt:  start,
+		EndsAt:    end,
+		Comment:   req.Comment,
+		CreatedBy: req.CreatedBy,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": w})
+}
+
+// DeleteMaintenanceWindow 删除维护窗口
+func (api *MonitorAPI) DeleteMaintenanceWindow(c *gin.Context) {
+	if err := api.monitor.GetSilenceManager().DeleteMaintenanceWindow(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// CheckSuppression 检查指定告警当前是否被抑制，并返回将附加的注解
+func (api *MonitorAPI) CheckSuppression(c *gin.Context) {
+	var req struct {
+		AlertName string            `json:"alertName"`
+		Labels    map[string]string `json:"labels"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	sup := api.monitor.GetSilenceManager().Check(req.AlertName, req.Labels)
+	data := gin.H{"suppressed": sup != nil}
+	if sup != nil {
+		data["suppression"] = sup
+		data["annotations"] = sup.Annotations()
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// 自定义指标类型
+const (
+	CustomMetricCounter = "counter"
+	CustomMetricGauge   = "gauge"
+	CustomMetricTimer   = "timer"
+)
+
+// MetricPoint 自定义指标的单个采样点，计时器额外记录采样周期内的次数与最值（毫秒）
+type MetricPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Count     int64     `json:"count,omitempty"`
+	Min       float64   `json:"min,omitempty"`
+	Max       float64   `json:"max,omitempty"`
+}
+
+// CustomMetricSeries 自定义指标时间序列
+type CustomMetricSeries struct {
+	Name   string        `json:"name"`
+	Kind   string        `json:"kind"`
+	Points []MetricPoint `json:"points"`
+}
+
+// CustomMetricInfo 自定义指标概要
+type CustomMetricInfo struct {
+	Name    string       `json:"name"`
+	Kind    string       `json:"kind"`
+	Samples int          `json:"samples"`
+	Latest  *MetricPoint `json:"latest,omitempty"`
+}
+
+// customSeries 单个自定义指标的当前值与历史采样
+type customSeries struct {
+	kind  string
+	value float64
+	// 计时器在当前采样周期内的聚合
+	count    int64
+	sum      float64
+	min, max float64
+	points   []MetricPoint
+}
+
+// series 获取或创建指标序列，调用方需持有写锁
+func (sm *SystemMonitor) series(name, kind string) *customSeries {
+	s, ok := sm.customSeries[name]
+	if !ok || s.kind != kind {
+		s = &customSeries{kind: kind}
+		sm.customSeries[name] = s
+	}
+	return s
+}
+
+// IncrCounter 累加计数器
+func (sm *SystemMonitor) IncrCounter(name string, delta float64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	s := sm.series(name, CustomMetricCounter)
+	s.value += delta
+	sm.customMetrics[name] = s.value
+}
+
+// SetGauge 设置仪表盘指标的当前值
+func (sm *SystemMonitor) SetGauge(name string, value float64) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.series(name, CustomMetricGauge).value = value
+	sm.customMetrics[name] = value
+}
+
+// ObserveTimer 记录一次耗时，按采样周期聚合为平均值/最值
+func (sm *SystemMonitor) ObserveTimer(name string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	s := sm.series(name, CustomMetricTimer)
+	if s.count == 0 || ms < s.min {
+		s.min = ms
+	}
+	if s.count == 0 || ms > s.max {
+		s.max = ms
+	}
+	s.count++
+	s.sum += ms
+	sm.customMetrics[name] = ms
+}
+
+// StartTimer 开始计时，返回的函数在结束时调用
+func (sm *SystemMonitor) StartTimer(name string) func() {
+	start := time.Now()
+	return func() {
+		sm.ObserveTimer(name, time.Since(start))
+	}
+}
+
+// sampleCustomMetrics 记录所有自定义指标在采样时刻的值，保留条数与系统统计一致
+func (sm *SystemMonitor) sampleCustomMetrics(ts time.Time) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for _, s := range sm.customSeries {
+		p := MetricPoint{Timestamp: ts, Value: s.value}
+		if s.kind == CustomMetricTimer {
+			if s.count > 0 {
+				p.Value = s.sum / float64(s.count)
+			}
+			p.Count, p.Min, p.Max = s.count, s.min, s.max
+			s.count, s.sum, s.min, s.max = 0, 0, 0, 0
+		}
+		s.points = append(s.points, p)
+		if sm.maxStats > 0 && len(s.points) > sm.maxStats {
+			s.points = s.points[len(s.points)-sm.maxStats:]
+		}
+	}
+}
+
+// ListCustomMetrics 列出所有自定义指标
+func (sm *SystemMonitor) ListCustomMetrics() []CustomMetricInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	list := make([]CustomMetricInfo, 0, len(sm.customSeries))
+	for name, s := range sm.customSeries {
+		info := CustomMetricInfo{Name: name, Kind: s.kind, Samples: len(s.points)}
+		if n := len(s.points); n > 0 {
+			latest := s.points[n-1]
+			info.Latest = &latest
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// GetCustomMetricHistory 获取自定义指标的历史采样，since 为零值时不限开始时间，limit<=0 时返回全部
+func (sm *SystemMonitor) GetCustomMetricHistory(name string, since time.Time, limit int) (*CustomMetricSeries, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	s, ok := sm.customSeries[name]
+	if !ok {
+		return nil, false
+	}
+	start := sort.Search(len(s.points), func(i int) bool { return !s.points[i].Timestamp.Before(since) })
+	points := s.points[start:]
+	if limit > 0 && len(points) > limit {
+		points = points[len(points)-limit:]
+	}
+	out := &CustomMetricSeries{Name: name, Kind: s.kind, Points: make([]MetricPoint, len(points))}
+	copy(out.Points, points)
+	return out, true
+}
+
+// toGaugeValue 将 SetCustomMetric 的数值转换为仪表盘值
+func toGaugeValue(value interface{}) (float64, bool) {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return cast.ToFloat64(value), true
+	}
+	return 0, false
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomMetricSeries(t *testing.T) {
+	sm := NewSystemMonitor(3, time.Second)
+	base := time.Now()
+
+	sm.IncrCounter("orders", 2)
+	sm.SetGauge("online_users", 10)
+	sm.ObserveTimer("checkout", 10*time.Millisecond)
+	sm.ObserveTimer("checkout", 30*time.Millisecond)
+	sm.SetCustomMetric("queue_depth", 5)
+	sm.SetCustomMetric("build", "v1.2.3")
+	sm.sampleCustomMetrics(base)
+
+	sm.IncrCounter("orders", 1)
+	sm.sampleCustomMetrics(base.Add(time.Second))
+
+	orders, ok := sm.GetCustomMetricHistory("orders", time.Time{}, 0)
+	require.True(t, ok)
+	assert.Equal(t, CustomMetricCounter, orders.Kind)
+	require.Len(t, orders.Points, 2)
+	assert.Equal(t, 3.0, orders.Points[1].Value)
+
+	checkout, ok := sm.GetCustomMetricHistory("checkout", time.Time{}, 0)
+	require.True(t, ok)
+	assert.Equal(t, 20.0, checkout.Points[0].Value)
+	assert.EqualValues(t, 2, checkout.Points[0].Count)
+	assert.Equal(t, 30.0, checkout.Points[0].Max)
+	// 计时器在每个采样周期重置
+	assert.EqualValues(t, 0, checkout.Points[1].Count)
+
+	_, ok = sm.GetCustomMetricHistory("build", time.Time{}, 0)
+	assert.False(t, ok)
+	assert.Len(t, sm.ListCustomMetrics(), 4)
+
+	// 保留条数与系统统计一致
+	for i := 2; i < 6; i++ {
+		sm.sampleCustomMetrics(base.Add(time.Duration(i) * time.Second))
+	}
+	orders, _ = sm.GetCustomMetricHistory("orders", time.Time{}, 0)
+	assert.Len(t, orders.Points, 3)
+	orders, _ = sm.GetCustomMetricHistory("orders", base.Add(5*time.Second), 0)
+	assert.Len(t, orders.Points, 1)
+}
@@ -0,0 +1,447 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 追踪导出器类型
+const (
+	TraceExporterOTLP   = "otlp"
+	TraceExporterJaeger = "jaeger"
+)
+
+// 默认 OTLP/HTTP 接收地址，Jaeger 1.35+ 原生支持 OTLP，端口相同
+const (
+	DefaultOTLPEndpoint   = "http://localhost:4318/v1/traces"
+	DefaultJaegerEndpoint = "http://localhost:4318/v1/traces"
+)
+
+// SpanExporter 将已结束的跨度发送到进程外的追踪后端
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+// BatchOptions 批量导出配置
+type BatchOptions struct {
+	QueueSize     int           // 待导出队列长度，队列满时丢弃新跨度
+	MaxBatchSize  int           // 单次导出的最大跨度数
+	FlushInterval time.Duration // 定时导出间隔
+	ExportTimeout time.Duration // 单次导出超时
+}
+
+// DefaultBatchOptions 默认批量导出配置
+func DefaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		QueueSize:     2048,
+		MaxBatchSize:  512,
+		FlushInterval: 5 * time.Second,
+		ExportTimeout: 10 * time.Second,
+	}
+}
+
+// batchSpanProcessor 异步批量导出，避免阻塞请求链路
+type batchSpanProcessor struct {
+	exporter SpanExporter
+	opts     BatchOptions
+	queue    chan *Span
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	dropped  atomic.Int64
+}
+
+func newBatchSpanProcessor(exporter SpanExporter, opts BatchOptions) *batchSpanProcessor {
+	def := DefaultBatchOptions()
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = def.QueueSize
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = def.MaxBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = def.FlushInterval
+	}
+	if opts.ExportTimeout <= 0 {
+		opts.ExportTimeout = def.ExportTimeout
+	}
+	p := &batchSpanProcessor{
+		exporter: exporter,
+		opts:     opts,
+		queue:    make(chan *Span, opts.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// onEnd 跨度结束后入队，队列满时丢弃
+func (p *batchSpanProcessor) onEnd(span *Span) {
+	select {
+	case <-p.stop:
+		return
+	default:
+	}
+	select {
+	case p.queue <- span:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+func (p *batchSpanProcessor) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, p.opts.MaxBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), p.opts.ExportTimeout)
+		if err := p.exporter.ExportSpans(ctx, batch); err != nil {
+			log.Printf("trace export failed: %d spans dropped: %v", len(batch), err)
+		}
+		cancel()
+		batch = make([]*Span, 0, p.opts.MaxBatchSize)
+	}
+
+	for {
+		select {
+		case span := <-p.queue:
+			batch = append(batch, span)
+			if len(batch) >= p.opts.MaxBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-p.stop:
+			// 导出队列中剩余的跨度
+			for {
+				select {
+				case span := <-p.queue:
+					batch = append(batch, span)
+					if len(batch) >= p.opts.MaxBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// shutdown 停止接收新跨度，导出剩余跨度后关闭导出器
+func (p *batchSpanProcessor) shutdown(ctx context.Context) error {
+	p.once.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return p.exporter.Shutdown(ctx)
+}
+
+// SetExporter 设置跨度导出器，替换时会先关闭原导出器；exporter 为 nil 时仅保留内存中的跨度
+func (t *Tracer) SetExporter(exporter SpanExporter, opts BatchOptions) {
+	var processor *batchSpanProcessor
+	if exporter != nil {
+		processor = newBatchSpanProcessor(exporter, opts)
+	}
+	t.mu.Lock()
+	old := t.processor
+	t.processor = processor
+	t.mu.Unlock()
+	if old != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), old.opts.ExportTimeout)
+		defer cancel()
+		_ = old.shutdown(ctx)
+	}
+}
+
+// DroppedSpans 因导出队列已满而丢弃的跨度数
+func (t *Tracer) DroppedSpans() int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.processor == nil {
+		return 0
+	}
+	return t.processor.dropped.Load()
+}
+
+// Shutdown 导出剩余跨度并关闭导出器
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	processor := t.processor
+	t.processor = nil
+	t.mu.Unlock()
+	if processor == nil {
+		return nil
+	}
+	return processor.shutdown(ctx)
+}
+
+// OTLPConfig OTLP/HTTP 导出配置
+type OTLPConfig struct {
+	Endpoint    string            // 完整的接收地址，例如 http://collector:4318/v1/traces
+	ServiceName string            // 写入 resource 的 service.name
+	Headers     map[string]string // 额外请求头，例如鉴权
+	Timeout     time.Duration
+	Client      *http.Client
+}
+
+// OTLPExporter 以 OTLP/HTTP JSON 协议导出跨度，兼容 OpenTelemetry Collector 与 Jaeger
+type OTLPExporter struct {
+	cfg    OTLPConfig
+	client *http.Client
+}
+
+// NewOTLPExporter 创建 OTLP 导出器
+func NewOTLPExporter(cfg OTLPConfig) *OTLPExporter {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultOTLPEndpoint
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "HibiscusIM"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+	return &OTLPExporter{cfg: cfg, client: client}
+}
+
+// NewJaegerExporter 创建导出到 Jaeger 的导出器，使用 Jaeger 的 OTLP/HTTP 接收端口
+func NewJaegerExporter(endpoint, serviceName string) *OTLPExporter {
+	if endpoint == "" {
+		endpoint = DefaultJaegerEndpoint
+	}
+	return NewOTLPExporter(OTLPConfig{Endpoint: endpoint, ServiceName: serviceName})
+}
+
+// NewTraceExporter 按类型创建导出器，kind 为空时返回 nil
+func NewTraceExporter(kind, endpoint, serviceName string) (SpanExporter, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case TraceExporterOTLP:
+		return NewOTLPExporter(OTLPConfig{Endpoint: endpoint, ServiceName: serviceName}), nil
+	case TraceExporterJaeger:
+		return NewJaegerExporter(endpoint, serviceName), nil
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter: %s", kind)
+	}
+}
+
+// ExportSpans 实现 SpanExporter
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []*Span) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(e.buildRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp export: status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Shutdown 实现 SpanExporter
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// OTLP JSON 编码结构，字段名与 opentelemetry-proto 的 JSON 映射一致
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func (e *OTLPExporter) buildRequest(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		out = append(out, toOTLPSpan(span))
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: toOTLPValue(e.cfg.ServiceName)},
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "HibiscusIM/pkg/metrics"},
+			Spans: out,
+		}},
+	}}}
+}
+
+func toOTLPSpan(span *Span) otlpSpan {
+	span.mu.RLock()
+	defer span.mu.RUnlock()
+
+	kind := span.Kind
+	if kind == SpanKindUnspecified {
+		kind = SpanKindInternal
+	}
+	s := otlpSpan{
+		TraceID:           span.TraceID,
+		SpanID:            span.ID,
+		ParentSpanID:      span.ParentID,
+		Name:              span.Name,
+		Kind:              int(kind),
+		StartTimeUnixNano: unixNano(span.StartTime),
+		EndTimeUnixNano:   unixNano(span.EndTime),
+		Attributes:        make([]otlpKeyValue, 0, len(span.Tags)+len(span.Attributes)),
+	}
+	for k, v := range span.Tags {
+		s.Attributes = append(s.Attributes, otlpKeyValue{Key: k, Value: toOTLPValue(v)})
+	}
+	s.Attributes = append(s.Attributes, toOTLPAttributes(span.Attributes)...)
+	for _, ev := range span.Events {
+		s.Events = append(s.Events, otlpEvent{
+			TimeUnixNano: unixNano(ev.Time),
+			Name:         ev.Name,
+			Attributes:   toOTLPAttributes(ev.Attributes),
+		})
+	}
+	// OTLP 状态码：0 Unset、1 Ok、2 Error
+	switch span.Status {
+	case SpanStatusOK:
+		s.Status.Code = 1
+	case SpanStatusError:
+		s.Status.Code = 2
+		if span.Error != nil {
+			s.Status.Message = span.Error.Error()
+		}
+	}
+	return s
+}
+
+func toOTLPAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	kvs := make([]otlpKeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: toOTLPValue(v)})
+	}
+	return kvs
+}
+
+func toOTLPValue(v interface{}) otlpValue {
+	switch val := v.(type) {
+	case string:
+		return otlpValue{StringValue: &val}
+	case bool:
+		return otlpValue{BoolValue: &val}
+	case int:
+		s := strconv.FormatInt(int64(val), 10)
+		return otlpValue{IntValue: &s}
+	case int32:
+		s := strconv.FormatInt(int64(val), 10)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpValue{IntValue: &s}
+	case uint:
+		s := strconv.FormatUint(uint64(val), 10)
+		return otlpValue{IntValue: &s}
+	case uint64:
+		s := strconv.FormatUint(val, 10)
+		return otlpValue{IntValue: &s}
+	case float32:
+		f := float64(val)
+		return otlpValue{DoubleValue: &f}
+	case float64:
+		return otlpValue{DoubleValue: &val}
+	default:
+		s := fmt.Sprint(val)
+		return otlpValue{StringValue: &s}
+	}
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceparentPropagation(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	traceID, spanID, flags, ok := ParseTraceparent(header)
+	require.True(t, ok)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+	assert.Equal(t, "00f067aa0ba902b7", spanID)
+	assert.Equal(t, "01", flags)
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, _, _, ok := ParseTraceparent(bad)
+		assert.False(t, ok, bad)
+	}
+
+	tracer := NewTracer(100)
+	ctx := ContextWithTraceparent(context.Background(), header)
+	ctx, span := tracer.StartSpan(ctx, "server", WithSpanKind(SpanKindServer))
+	assert.Equal(t, traceID, span.TraceID)
+	assert.Equal(t, spanID, span.ParentID)
+
+	_, child := tracer.StartSpan(ctx, "child")
+	assert.Equal(t, traceID, child.TraceID)
+	assert.Equal(t, span.ID, child.ParentID)
+
+	h := http.Header{}
+	InjectHTTP(ctx, h)
+	assert.Equal(t, "00-"+traceID+"-"+span.ID+"-01", h.Get(TraceparentHeader))
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []otlpRequest
+		headers  http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		received = append(received, req)
+		headers = r.Header.Clone()
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tracer := NewTracer(100)
+	tracer.SetExporter(NewOTLPExporter(OTLPConfig{
+		Endpoint:    srv.URL,
+		ServiceName: "im-test",
+		Headers:     map[string]string{"Authorization": "Bearer t"},
+	}), BatchOptions{FlushInterval: time.Hour, MaxBatchSize: 10})
+
+	ctx, parent := tracer.StartSpan(context.Background(), "GET /api/ping",
+		WithSpanKind(SpanKindServer), WithTags(map[string]string{"method": "GET"}))
+	_, child := tracer.StartSpan(ctx, "db.query", WithAttributes(map[string]interface{}{"rows": 3}))
+	child.AddEvent("slow", map[string]interface{}{"ms": 120.5})
+	tracer.EndSpan(child, errors.New("timeout"))
+	tracer.EndSpan(parent, nil)
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 1)
+	assert.Equal(t, "Bearer t", headers.Get("Authorization"))
+	rs := received[0].ResourceSpans[0]
+	assert.Equal(t, "service.name", rs.Resource.Attributes[0].Key)
+	assert.Equal(t, "im-test", *rs.Resource.Attributes[0].Value.StringValue)
+
+	spans := rs.ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	db, srvSpan := spans[0], spans[1]
+	assert.Equal(t, "db.query", db.Name)
+	assert.Equal(t, srvSpan.SpanID, db.ParentSpanID)
+	assert.Equal(t, srvSpan.TraceID, db.TraceID)
+	assert.Len(t, db.TraceID, 32)
+	assert.Len(t, db.SpanID, 16)
+	assert.Equal(t, int(SpanKindInternal), db.Kind)
+	assert.Equal(t, 2, db.Status.Code)
+	assert.Equal(t, "timeout", db.Status.Message)
+	assert.Equal(t, "3", *db.Attributes[0].Value.IntValue)
+	require.Len(t, db.Events, 1)
+	assert.Equal(t, 120.5, *db.Events[0].Attributes[0].Value.DoubleValue)
+	assert.Equal(t, int(SpanKindServer), srvSpan.Kind)
+	assert.Equal(t, 1, srvSpan.Status.Code)
+	assert.Equal(t, "GET", *srvSpan.Attributes[0].Value.StringValue)
+}
+
+func TestNewTraceExporter(t *testing.T) {
+	exp, err := NewTraceExporter("", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, exp)
+
+	exp, err = NewTraceExporter(TraceExporterJaeger, "", "im")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultJaegerEndpoint, exp.(*OTLPExporter).cfg.Endpoint)
+
+	_, err = NewTraceExporter("zipkin", "", "")
+	assert.Error(t, err)
+}
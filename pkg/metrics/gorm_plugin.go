@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// gormStartKey 语句开始时间在 gorm 实例中的键
+const gormStartKey = "metrics:start"
+
+// GormPlugin 挂载到 Create/Query/Update/Delete 回调，自动把每条语句的耗时、影响行数与错误
+// 记录到 SQL 分析器和 db_query_duration_seconds 直方图，不再需要手动调用 RecordSQLQuery
+type GormPlugin struct {
+	monitor *Monitor
+}
+
+// NewGormPlugin 创建 GORM 插件，monitor 为 nil 时每次记录使用全局监控器
+func NewGormPlugin(monitor *Monitor) *GormPlugin {
+	return &GormPlugin{monitor: monitor}
+}
+
+// Name 实现 gorm.Plugin
+func (p *GormPlugin) Name() string {
+	return "metrics:sql"
+}
+
+// Initialize 实现 gorm.Plugin，在各类语句执行前后注册计时回调
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("metrics:before_create", p.before); err != nil {
+		return err
+	}
+	if err := cb.Create().After("gorm:create").Register("metrics:after_create", p.after("create")); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("metrics:before_query", p.before); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("metrics:after_query", p.after("query")); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("metrics:before_update", p.before); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("metrics:after_update", p.after("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("metrics:before_delete", p.before); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("metrics:after_delete", p.after("delete"))
+}
+
+func (p *GormPlugin) before(tx *gorm.DB) {
+	tx.InstanceSet(gormStartKey, time.Now())
+}
+
+// after 记录语句；DryRun 或未生成语句（如钩子中止）时跳过，记录不存在不视为错误
+func (p *GormPlugin) after(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(gormStartKey)
+		if !ok || tx.DryRun || tx.Statement.SQL.Len() == 0 {
+			return
+		}
+		start, _ := v.(time.Time)
+		duration := time.Since(start)
+		m := monitorOrGlobal(p.monitor)
+		if m == nil {
+			return
+		}
+
+		table := tx.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		sql := tx.Statement.SQL.String()
+		err := tx.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		m.RecordDBQuery(operation, table, sqlType(sql), duration)
+		m.RecordSQLQuery(tx.Statement.Context, sql, tx.Statement.Vars, table, sqlType(sql), duration, tx.RowsAffected, err)
+	}
+}
+
+// sqlType 语句的首个关键字（大写），如 SELECT、INSERT
+func sqlType(sql string) string {
+	word, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	return strings.ToUpper(word)
+}
+
+func monitorOrGlobal(m *Monitor) *Monitor {
+	if m != nil {
+		return m
+	}
+	return GetGlobalMonitor()
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type pluginUser struct {
+	ID    uint
+	Email string
+}
+
+func TestGormPlugin(t *testing.T) {
+	m := NewMonitor(&MonitorConfig{EnableSQLAnalysis: true, MaxQueries: 100, SlowThreshold: time.Hour})
+	m.metrics = sharedMetrics()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&pluginUser{}))
+	require.NoError(t, db.Use(NewGormPlugin(m)))
+
+	require.NoError(t, db.Create(&pluginUser{Email: "a@example.com"}).Error)
+	require.NoError(t, db.Create(&pluginUser{Email: "b@example.com"}).Error)
+	var users []pluginUser
+	require.NoError(t, db.Where("email LIKE ?", "%@example.com").Find(&users).Error)
+	require.NoError(t, db.Model(&pluginUser{}).Where("id = ?", users[0].ID).Update("email", "c@example.com").Error)
+	require.NoError(t, db.Where("1 = 1").Delete(&pluginUser{}).Error)
+	// 记录不存在不计为错误
+	assert.ErrorIs(t, db.First(&pluginUser{}).Error, gorm.ErrRecordNotFound)
+	// DryRun 不执行语句，不记录
+	db.Session(&gorm.Session{DryRun: true}).Find(&users)
+
+	sa := m.GetSQLAnalyzer()
+	queries := sa.GetQueriesByTable("plugin_users", 100)
+	require.Len(t, queries, 6)
+	byOp := map[string][]*SQLQuery{}
+	for _, q := range queries {
+		byOp[q.Operation] = append(byOp[q.Operation], q)
+		assert.NoError(t, q.Error)
+	}
+	assert.Len(t, byOp["INSERT"], 2)
+	assert.Len(t, byOp["SELECT"], 2)
+	require.Len(t, byOp["DELETE"], 1)
+	assert.Equal(t, int64(2), byOp["DELETE"][0].RowsAffected)
+	require.Len(t, byOp["UPDATE"], 1)
+	assert.Contains(t, byOp["UPDATE"][0].SQL, "UPDATE `plugin_users`")
+
+	// 直方图按 回调操作、表、语句类型 记录
+	ch := make(chan prometheus.Metric, 100)
+	m.metrics.dbQueryDuration.Collect(ch)
+	close(ch)
+	assert.GreaterOrEqual(t, len(ch), 4)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnmatchedRoute 未匹配到路由的请求使用的 path 标签，避免按原始路径产生无限多的时间序列
+const UnmatchedRoute = "<unmatched>"
+
+// HTTPMetricsMiddleware 记录 HTTP 请求数、耗时与请求/响应大小，path 标签为路由模板（如 /api/user/:id）
+func HTTPMetricsMiddleware(m *Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		path := c.FullPath()
+		if path == "" {
+			path = UnmatchedRoute
+		}
+
+		// 未声明长度（分块传输）的请求按实际读取的字节数统计
+		var body *countingReader
+		if c.Request.ContentLength < 0 && c.Request.Body != nil {
+			body = &countingReader{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+
+		c.Next()
+
+		requestSize := c.Request.ContentLength
+		if body != nil {
+			requestSize = body.n.Load()
+		}
+		responseSize := int64(c.Writer.Size())
+		if responseSize < 0 {
+			responseSize = 0
+		}
+		m.RecordHTTPRequest(c.Request.Method, path, strconv.Itoa(c.Writer.Status()), c.HandlerName(), time.Since(start), max(requestSize, 0), responseSize)
+	}
+}
+
+// countingReader 统计已读取的请求体字节数
+type countingReader struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatheredValue 从默认注册表读取带指定标签的计数器或直方图样本数
+func gatheredValue(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+	next:
+		for _, metric := range mf.GetMetric() {
+			got := make(map[string]string, len(metric.GetLabel()))
+			for _, l := range metric.GetLabel() {
+				got[l.GetName()] = l.GetValue()
+			}
+			for k, v := range labels {
+				if got[k] != v {
+					continue next
+				}
+			}
+			if h := metric.GetHistogram(); h != nil {
+				return h.GetSampleSum()
+			}
+			return metric.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// sharedMetrics 指标注册到默认注册表，同一进程内只能创建一次
+var sharedMetrics = sync.OnceValue(NewMetrics)
+
+func TestHTTPMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := sharedMetrics()
+	m.httpRequestsTotal.Reset()
+	m.httpRequestSize.Reset()
+	m.httpResponseSize.Reset()
+	r := gin.New()
+	r.Use(HTTPMetricsMiddleware(m))
+	r.POST("/users/:id", func(c *gin.Context) {
+		_, _ = io.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, "created")
+	})
+
+	for _, id := range []string{"1", "2", "3"} {
+		req := httptest.NewRequest(http.MethodPost, "/users/"+id, strings.NewReader("hello"))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// 分块传输的请求按实际读取的字节数统计
+	req := httptest.NewRequest(http.MethodPost, "/users/4", io.NopCloser(strings.NewReader("chunked body")))
+	req.ContentLength = -1
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere/1", nil))
+
+	// 路径按路由模板归并
+	assert.Equal(t, 4.0, gatheredValue(t, "http_requests_total", map[string]string{"method": "POST", "path": "/users/:id", "status": "201"}))
+	assert.Equal(t, 0.0, gatheredValue(t, "http_requests_total", map[string]string{"path": "/users/1"}))
+	assert.Equal(t, 1.0, gatheredValue(t, "http_requests_total", map[string]string{"method": "GET", "path": UnmatchedRoute, "status": "404"}))
+	assert.Equal(t, float64(3*len("hello")+len("chunked body")), gatheredValue(t, "http_request_size_bytes", map[string]string{"method": "POST", "path": "/users/:id"}))
+	assert.Equal(t, float64(4*len("created")), gatheredValue(t, "http_response_size_bytes", map[string]string{"method": "POST", "path": "/users/:id", "status": "201"}))
+}
@@ -1,29 +1,27 @@
 package metrics
 
 import (
-	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// MonitorMiddleware 监控中间件
+// MonitorMiddleware 监控中间件：在请求跨度上记录请求开始与完成事件，并为超过阈值的慢请求保存样本。
+// 注册在 TracingMiddleware 之后时沿用其创建的跨度，否则自行创建
 func MonitorMiddleware(monitor *Monitor) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
+		span := SpanFromContext(c.Request.Context())
+		owned := span == nil
+		if owned {
+			span = startRequestSpan(monitor, c)
+		}
 
-		// 开始链路追踪
-		ctx, span := monitor.StartSpan(c.Request.Context(), c.HandlerName(),
-			WithTags(map[string]string{
-				"method": c.Request.Method,
-				"path":   c.Request.URL.Path,
-				"ip":     c.ClientIP(),
-			}),
-		)
-
-		// 将span添加到上下文
-		c.Request = c.Request.WithContext(ctx)
+		// 捕获请求体与响应体，请求结束后超过阈值才生成慢请求样本
+		var finishSlow func(time.Time, time.Duration, string, []*SQLQuery)
+		if rec := monitor.GetSlowHTTPRecorder(); rec != nil {
+			finishSlow = rec.capture(c)
+		}
 
 		// 记录请求开始
 		if span != nil {
@@ -39,37 +37,25 @@ func MonitorMiddleware(monitor *Monitor) gin.HandlerFunc {
 		// 计算请求耗时
 		duration := time.Since(start)
 
-		// 记录HTTP请求指标
-		status := c.Writer.Status()
-		requestSize := c.Request.ContentLength
-		if requestSize < 0 {
-			requestSize = 0
-		}
-		responseSize := int64(c.Writer.Size())
-
-		monitor.RecordHTTPRequest(
-			c.Request.Method,
-			c.Request.URL.Path,
-			strconv.Itoa(c.Writer.Status()),
-			c.HandlerName(),
-			duration,
-			requestSize,
-			responseSize,
-		)
-
-		// 结束链路追踪
-		var err error
-		if status >= 400 {
-			err = gin.Error{Err: fmt.Errorf("HTTP %d", status)}
-		}
-		monitor.EndSpan(span, err)
-
-		// 记录请求完成事件
+		// HTTP 请求指标由 HTTPMetricsMiddleware 按路由模板记录；跨度由 TracingMiddleware 结束，
+		// 完成事件需在此之前写入以便随跨度一起导出
 		if span != nil {
 			span.AddEvent("request_completed", map[string]interface{}{
-				"status_code": status,
+				"status_code": c.Writer.Status(),
 				"duration_ms": duration.Milliseconds(),
 			})
 		}
+		if owned {
+			endRequestSpan(monitor, c, span, TracingConfig{})
+		}
+
+		if finishSlow != nil && duration >= monitor.GetSlowHTTPRecorder().Threshold() {
+			traceID := getTraceIDFromContext(c.Request.Context())
+			var queries []*SQLQuery
+			if sa := monitor.GetSQLAnalyzer(); sa != nil {
+				queries = sa.GetQueriesByTrace(traceID)
+			}
+			finishSlow(start, duration, traceID, queries)
+		}
 	}
 }
@@ -5,6 +5,7 @@ import (
 	_ "embed"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
 	"net/http"
 	"sync"
 	"time"
@@ -16,6 +17,10 @@ type Monitor struct {
 	tracer        *Tracer
 	sqlAnalyzer   *SQLAnalyzer
 	systemMonitor *SystemMonitor
+	silences      *SilenceManager
+	alerts        *AlertEngine
+	slowHTTP      *SlowHTTPRecorder
+	sources       map[string]MetricSource
 	mu            sync.RWMutex
 	config        *MonitorConfig
 }
@@ -35,9 +40,19 @@ type MonitorConfig struct {
 	SlowThreshold     time.Duration `json:"slow_threshold" yaml:"slow_threshold" default:"100ms"`
 
 	// 系统监控配置
-	EnableSystemMonitor bool          `json:"enable_system_monitor" yaml:"enable_system_monitor" default:"true"`
-	MaxStats            int           `json:"max_stats" yaml:"max_stats" default:"1000"`
-	MonitorInterval     time.Duration `json:"monitor_interval" yaml:"monitor_interval" default:"30s"`
+	EnableSystemMonitor bool           `json:"enable_system_monitor" yaml:"enable_system_monitor" default:"true"`
+	MaxStats            int            `json:"max_stats" yaml:"max_stats" default:"1000"`
+	MonitorInterval     time.Duration  `json:"monitor_interval" yaml:"monitor_interval" default:"30s"`
+	StatsRetention      StatsRetention `json:"stats_retention" yaml:"stats_retention"` // 1m/5m/1h 聚合历史的保留时长
+
+	// 告警配置
+	EnableAlerting bool          `json:"enable_alerting" yaml:"enable_alerting" default:"true"`
+	AlertInterval  time.Duration `json:"alert_interval" yaml:"alert_interval" default:"30s"`
+
+	// 慢请求样本配置，阈值为 0 时不记录
+	SlowHTTPThreshold  time.Duration `json:"slow_http_threshold" yaml:"slow_http_threshold" default:"1s"`
+	MaxSlowHTTPSamples int           `json:"max_slow_http_samples" yaml:"max_slow_http_samples" default:"200"`
+	SlowHTTPBodyLimit  int           `json:"slow_http_body_limit" yaml:"slow_http_body_limit" default:"4096"`
 }
 
 // DefaultMonitorConfig 默认监控配置
@@ -52,6 +67,12 @@ func DefaultMonitorConfig() *MonitorConfig {
 		EnableSystemMonitor: true,
 		MaxStats:            1000,
 		MonitorInterval:     30 * time.Second,
+		StatsRetention:      DefaultStatsRetention(),
+		EnableAlerting:      true,
+		AlertInterval:       30 * time.Second,
+		SlowHTTPThreshold:   time.Second,
+		MaxSlowHTTPSamples:  defaultMaxSlowHTTPSamples,
+		SlowHTTPBodyLimit:   defaultSlowHTTPBodyLimit,
 	}
 }
 
@@ -62,7 +83,8 @@ func NewMonitor(config *MonitorConfig) *Monitor {
 	}
 
 	monitor := &Monitor{
-		config: config,
+		config:   config,
+		silences: NewSilenceManager(),
 	}
 
 	// 初始化指标收集
@@ -83,6 +105,25 @@ func NewMonitor(config *MonitorConfig) *Monitor {
 	// 初始化系统监控
 	if config.EnableSystemMonitor {
 		monitor.systemMonitor = NewSystemMonitor(config.MaxStats, config.MonitorInterval)
+		monitor.systemMonitor.SetRetention(config.StatsRetention)
+	}
+
+	// 初始化告警引擎
+	if config.EnableAlerting {
+		monitor.alerts = NewAlertEngine(monitor.alertMetricValue, monitor.silences, config.AlertInterval)
+	}
+
+	// 系统资源阈值对应内置告警规则，越过阈值时立即评估，不必等待下一个评估周期
+	if monitor.systemMonitor != nil && monitor.alerts != nil {
+		monitor.alerts.setResourceRules(monitor.systemMonitor.Thresholds())
+		monitor.systemMonitor.OnThreshold(func(ThresholdEvent) {
+			monitor.alerts.Evaluate()
+		})
+	}
+
+	// 初始化慢请求样本
+	if config.SlowHTTPThreshold > 0 {
+		monitor.slowHTTP = NewSlowHTTPRecorder(config.SlowHTTPThreshold, config.MaxSlowHTTPSamples, config.SlowHTTPBodyLimit)
 	}
 
 	return monitor
@@ -111,6 +152,8 @@ func RegisterMonitorUI(grp *gin.RouterGroup, api *MonitorAPI) {
 					"tracing":        m != nil && m.GetTracer() != nil,
 					"sql_analysis":   m != nil && m.GetSQLAnalyzer() != nil,
 					"system_monitor": m != nil && m.GetSystemMonitor() != nil,
+					"alerting":       m != nil && m.GetAlertEngine() != nil,
+					"slow_http":      m != nil && m.GetSlowHTTPRecorder() != nil,
 				},
 				"defaults": gin.H{
 					"refresh_seconds": 30,
@@ -134,6 +177,10 @@ func (m *Monitor) Start() {
 	if m.systemMonitor != nil {
 		m.systemMonitor.Start()
 	}
+	if m.alerts != nil {
+		m.alerts.Start()
+	}
+	m.silences.Start(silenceCleanupInterval, silenceRetention)
 }
 
 // Stop 停止监控
@@ -144,6 +191,23 @@ func (m *Monitor) Stop() {
 	if m.systemMonitor != nil {
 		m.systemMonitor.Stop()
 	}
+	if m.alerts != nil {
+		m.alerts.Stop()
+	}
+	m.silences.Stop()
+	if m.tracer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = m.tracer.Shutdown(ctx)
+	}
+}
+
+// SetTraceExporter 设置链路追踪导出器，跨度结束后批量发送到进程外
+func (m *Monitor) SetTraceExporter(exporter SpanExporter, opts BatchOptions) {
+	if m.tracer == nil {
+		return
+	}
+	m.tracer.SetExporter(exporter, opts)
 }
 
 // GetMetrics 获取指标管理器
@@ -166,6 +230,32 @@ func (m *Monitor) GetSystemMonitor() *SystemMonitor {
 	return m.systemMonitor
 }
 
+// GetSilenceManager 获取告警静默管理器
+func (m *Monitor) GetSilenceManager() *SilenceManager {
+	return m.silences
+}
+
+// GetAlertEngine 获取告警引擎
+func (m *Monitor) GetAlertEngine() *AlertEngine {
+	return m.alerts
+}
+
+// SetResourceThresholds 设置系统资源阈值，并同步更新对应的内置告警规则
+func (m *Monitor) SetResourceThresholds(t ResourceThresholds) {
+	if m.systemMonitor == nil {
+		return
+	}
+	m.systemMonitor.SetThresholds(t)
+	if m.alerts != nil {
+		m.alerts.setResourceRules(t)
+	}
+}
+
+// GetSlowHTTPRecorder 获取慢请求记录器
+func (m *Monitor) GetSlowHTTPRecorder() *SlowHTTPRecorder {
+	return m.slowHTTP
+}
+
 // StartSpan 开始链路追踪跨度
 func (m *Monitor) StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
 	if m.tracer == nil {
@@ -190,6 +280,19 @@ func (m *Monitor) RecordSQLQuery(ctx context.Context, sql string, params []inter
 	m.sqlAnalyzer.RecordQuery(ctx, sql, params, table, operation, duration, rowsAffected, err)
 }
 
+// EnableExplain 对慢查询执行 EXPLAIN 并保存执行计划，仅支持 MySQL 与 SQLite
+func (m *Monitor) EnableExplain(db *gorm.DB, concurrency int) error {
+	if m.sqlAnalyzer == nil {
+		return nil
+	}
+	e, err := NewExplainer(db, concurrency)
+	if err != nil {
+		return err
+	}
+	m.sqlAnalyzer.SetExplainer(e)
+	return nil
+}
+
 // RecordHTTPRequest 记录HTTP请求指标
 func (m *Monitor) RecordHTTPRequest(method, path, status, handler string, duration time.Duration, requestSize, responseSize int64) {
 	if m.metrics == nil {
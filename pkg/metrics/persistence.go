@@ -0,0 +1,271 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 持久化的监控数据类型
+const (
+	MonitorDataSystem    = "system"
+	MonitorDataSlowQuery = "slow_query"
+	MonitorDataAlert     = "alert"
+)
+
+const (
+	defaultPersistInterval  = time.Minute
+	defaultPersistRetention = 7 * 24 * time.Hour
+	persistBatchSize        = 200
+	// maxPendingAlerts 数据库不可用时缓冲的告警事件上限，超出后丢弃最早的事件
+	maxPendingAlerts = 1000
+	maxSummaryLength = 512
+)
+
+// MonitorData 持久化的监控数据：系统统计快照、慢查询与告警事件
+type MonitorData struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Kind      string    `json:"kind" gorm:"size:16;index"`
+	Name      string    `json:"name" gorm:"size:128;index"` // 系统快照为主机名，慢查询为表名，告警为规则名
+	Value     float64   `json:"value"`                      // 系统快照为 CPU 使用率，慢查询为耗时毫秒，告警为指标值
+	Summary   string    `json:"summary" gorm:"size:512"`
+	Data      string    `json:"data"`                   // 原始记录 JSON
+	Timestamp time.Time `json:"timestamp" gorm:"index"` // 采样、查询开始或告警状态变化的时间
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PersistOptions 监控数据持久化配置，为 0 时使用默认值
+type PersistOptions struct {
+	Interval  time.Duration // 写入间隔，默认 1 分钟
+	Retention time.Duration // 保留时长，默认 7 天
+}
+
+// Persister 定期将系统统计快照、慢查询与告警事件写入 monitor_data，重启后历史仍可查询
+type Persister struct {
+	db      *gorm.DB
+	monitor *Monitor
+	opts    PersistOptions
+
+	mu     sync.Mutex
+	alerts []*Alert // 待写入的告警事件
+
+	flushMu   sync.Mutex
+	lastStats time.Time // 已写入的最新系统快照时间
+	lastQuery time.Time // 已写入的最新慢查询开始时间
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPersister 创建监控数据持久化器，并订阅告警触发与恢复事件
+func NewPersister(db *gorm.DB, monitor *Monitor, opts PersistOptions) *Persister {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultPersistInterval
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultPersistRetention
+	}
+	p := &Persister{db: db, monitor: monitor, opts: opts}
+	if engine := monitor.GetAlertEngine(); engine != nil {
+		engine.AddNotifier(NotifierFunc(func(_ context.Context, a *Alert) error {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if len(p.alerts) >= maxPendingAlerts {
+				p.alerts = p.alerts[1:]
+			}
+			p.alerts = append(p.alerts, a.clone())
+			return nil
+		}))
+	}
+	return p
+}
+
+// Start 启动定期写入与过期数据清理
+func (p *Persister) Start() {
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop(p.stop, p.done)
+}
+
+// Stop 停止定期写入，并写入尚未持久化的数据
+func (p *Persister) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+	if err := p.Flush(context.Background()); err != nil {
+		log.Printf("persist monitor data failed: %v", err)
+	}
+}
+
+func (p *Persister) loop(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(p.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ctx := context.Background()
+			if err := p.Flush(ctx); err != nil {
+				log.Printf("persist monitor data failed: %v", err)
+			}
+			if err := p.Prune(ctx, time.Now()); err != nil {
+				log.Printf("prune monitor data failed: %v", err)
+			}
+		}
+	}
+}
+
+// Flush 写入上次写入之后新增的系统快照、慢查询与告警事件，写入失败时下次重试
+func (p *Persister) Flush(ctx context.Context) error {
+	p.flushMu.Lock()
+	defer p.flushMu.Unlock()
+
+	rows, lastStats := p.systemRows()
+	queryRows, lastQuery := p.slowQueryRows()
+	rows = append(rows, queryRows...)
+
+	p.mu.Lock()
+	alerts := p.alerts
+	p.alerts = nil
+	p.mu.Unlock()
+	for _, a := range alerts {
+		rows = append(rows, alertRow(a))
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := p.db.WithContext(ctx).CreateInBatches(rows, persistBatchSize).Error; err != nil {
+		// 告警事件放回缓冲区，快照与慢查询按时间水位下次重新读取
+		p.mu.Lock()
+		p.alerts = append(alerts, p.alerts...)
+		if n := len(p.alerts); n > maxPendingAlerts {
+			p.alerts = p.alerts[n-maxPendingAlerts:]
+		}
+		p.mu.Unlock()
+		return err
+	}
+	p.lastStats = lastStats
+	p.lastQuery = lastQuery
+	return nil
+}
+
+// Prune 删除超过保留时长的监控数据
+func (p *Persister) Prune(ctx context.Context, now time.Time) error {
+	return p.db.WithContext(ctx).Where("timestamp < ?", now.Add(-p.opts.Retention)).Delete(&MonitorData{}).Error
+}
+
+// systemRows 上次写入之后的系统统计快照
+func (p *Persister) systemRows() ([]MonitorData, time.Time) {
+	last := p.lastStats
+	sm := p.monitor.GetSystemMonitor()
+	if sm == nil {
+		return nil, last
+	}
+	var rows []MonitorData
+	for _, s := range sm.GetStatsHistory(0) {
+		if !s.Timestamp.After(p.lastStats) {
+			continue
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			continue
+		}
+		rows = append(rows, MonitorData{
+			Kind:      MonitorDataSystem,
+			Name:      s.Host.Hostname,
+			Value:     s.CPU.UsagePercent,
+			Summary:   fmt.Sprintf("cpu %.1f%%, memory %.1f%%, disk %.1f%%", s.CPU.UsagePercent, s.Memory.UsagePercent, s.Disk.UsagePercent),
+			Data:      string(data),
+			Timestamp: s.Timestamp,
+		})
+		if s.Timestamp.After(last) {
+			last = s.Timestamp
+		}
+	}
+	return rows, last
+}
+
+// slowQueryRows 上次写入之后的慢查询，SQL 与参数已脱敏
+func (p *Persister) slowQueryRows() ([]MonitorData, time.Time) {
+	last := p.lastQuery
+	sa := p.monitor.GetSQLAnalyzer()
+	if sa == nil {
+		return nil, last
+	}
+	var rows []MonitorData
+	for _, q := range redactQueries(sa.GetSlowQueries(0)) {
+		if !q.StartTime.After(p.lastQuery) {
+			continue
+		}
+		// error 类型无法直接序列化，按字符串保存
+		record := struct {
+			*SQLQuery
+			Error string `json:"error,omitempty"`
+		}{SQLQuery: q}
+		if q.Error != nil {
+			record.Error = q.Error.Error()
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			continue
+		}
+		rows = append(rows, MonitorData{
+			Kind:      MonitorDataSlowQuery,
+			Name:      q.Table,
+			Value:     float64(q.Duration) / float64(time.Millisecond),
+			Summary:   truncateSummary(q.SQL),
+			Data:      string(data),
+			Timestamp: q.StartTime,
+		})
+		if q.StartTime.After(last) {
+			last = q.StartTime
+		}
+	}
+	return rows, last
+}
+
+// alertRow 告警触发或恢复事件
+func alertRow(a *Alert) MonitorData {
+	at := a.LastEvalAt
+	switch {
+	case a.State == AlertStateResolved && !a.ResolvedAt.IsZero():
+		at = a.ResolvedAt
+	case a.State == AlertStateFiring && !a.FiredAt.IsZero():
+		at = a.FiredAt
+	}
+	summary := fmt.Sprintf("%s: %s %s %g (value %g)", a.State, a.Metric, a.Operator, a.Threshold, a.Value)
+	if s := a.Annotations["summary"]; s != "" {
+		summary += ", " + s
+	}
+	data, _ := json.Marshal(a)
+	return MonitorData{
+		Kind:      MonitorDataAlert,
+		Name:      a.Rule,
+		Value:     a.Value,
+		Summary:   truncateSummary(summary),
+		Data:      string(data),
+		Timestamp: at,
+	}
+}
+
+func truncateSummary(s string) string {
+	r := []rune(s)
+	if len(r) <= maxSummaryLength {
+		return s
+	}
+	return string(r[:maxSummaryLength-3]) + "..."
+}
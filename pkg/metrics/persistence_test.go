@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPersisterFlush(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&MonitorData{}))
+
+	m := NewMonitor(&MonitorConfig{
+		EnableSystemMonitor: true, MaxStats: 10, MonitorInterval: time.Second,
+		EnableSQLAnalysis: true, MaxQueries: 10, SlowThreshold: time.Millisecond,
+		EnableAlerting: true,
+	})
+	m.SetResourceThresholds(ResourceThresholds{CPUPercent: 80})
+	p := NewPersister(db, m, PersistOptions{Retention: time.Hour})
+
+	now := time.Now()
+	stats := &SystemStats{Timestamp: now, CustomMetrics: map[string]interface{}{}}
+	stats.CPU.UsagePercent = 95
+	stats.Host.Hostname = "node-1"
+	m.GetSystemMonitor().record(stats)
+	m.GetSQLAnalyzer().RecordQuery(context.Background(), "SELECT * FROM users WHERE email = ?", []interface{}{"a@b.c"},
+		"users", "SELECT", 50*time.Millisecond, 1, errors.New("timeout"))
+
+	require.NoError(t, p.Flush(context.Background()))
+	count := func(kind string) int64 {
+		var n int64
+		require.NoError(t, db.Model(&MonitorData{}).Where("kind = ?", kind).Count(&n).Error)
+		return n
+	}
+	assert.EqualValues(t, 1, count(MonitorDataSystem))
+	assert.EqualValues(t, 1, count(MonitorDataSlowQuery))
+	assert.EqualValues(t, 1, count(MonitorDataAlert))
+
+	var query MonitorData
+	require.NoError(t, db.Where("kind = ?", MonitorDataSlowQuery).First(&query).Error)
+	assert.Equal(t, "users", query.Name)
+	assert.Equal(t, 50.0, query.Value)
+	assert.Contains(t, query.Data, `"error":"timeout"`)
+
+	var alert MonitorData
+	require.NoError(t, db.Where("kind = ?", MonitorDataAlert).First(&alert).Error)
+	assert.Equal(t, RuleSystemCPUHigh, alert.Name)
+
+	// 已写入的数据不重复写入
+	require.NoError(t, p.Flush(context.Background()))
+	var total int64
+	require.NoError(t, db.Model(&MonitorData{}).Count(&total).Error)
+	assert.EqualValues(t, 3, total)
+
+	require.NoError(t, p.Prune(context.Background(), now.Add(2*time.Hour)))
+	require.NoError(t, db.Model(&MonitorData{}).Count(&total).Error)
+	assert.Zero(t, total)
+}
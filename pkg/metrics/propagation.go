@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader W3C Trace Context 传播头
+const TraceparentHeader = "traceparent"
+
+// remoteParent 上游服务传入的父跨度
+type remoteParent struct {
+	traceID string
+	spanID  string
+	flags   string
+}
+
+type remoteParentKey struct{}
+
+// ParseTraceparent 解析 traceparent 头，格式为 version-traceid-spanid-flags
+func ParseTraceparent(value string) (traceID, spanID, flags string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return "", "", "", false
+	}
+	version := parts[0]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", "", false
+	}
+	traceID, spanID, flags = parts[1], parts[2], parts[3]
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return "", "", "", false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return "", "", "", false
+	}
+	if !isLowerHex(flags, 2) {
+		return "", "", "", false
+	}
+	return traceID, spanID, flags, true
+}
+
+// FormatTraceparent 生成当前跨度的 traceparent 头
+func FormatTraceparent(span *Span) string {
+	if span == nil || !isLowerHex(span.TraceID, 32) || !isLowerHex(span.ID, 16) {
+		return ""
+	}
+	return "00-" + span.TraceID + "-" + span.ID + "-01"
+}
+
+// ContextWithTraceparent 将上游传入的 traceparent 作为远程父跨度写入上下文，无效值原样返回 ctx
+func ContextWithTraceparent(ctx context.Context, value string) context.Context {
+	traceID, spanID, flags, ok := ParseTraceparent(value)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteParentKey{}, remoteParent{traceID: traceID, spanID: spanID, flags: flags})
+}
+
+// TraceparentFromContext 返回向下游传播的 traceparent，无跨度时透传远程父跨度
+func TraceparentFromContext(ctx context.Context) string {
+	if span := getSpanFromContext(ctx); span != nil {
+		return FormatTraceparent(span)
+	}
+	if remote, ok := remoteParentFromContext(ctx); ok {
+		return "00-" + remote.traceID + "-" + remote.spanID + "-" + remote.flags
+	}
+	return ""
+}
+
+// ExtractHTTP 从 HTTP 请求头提取 traceparent
+func ExtractHTTP(ctx context.Context, header http.Header) context.Context {
+	return ContextWithTraceparent(ctx, header.Get(TraceparentHeader))
+}
+
+// InjectHTTP 将当前 traceparent 写入 HTTP 请求头，供调用下游服务时使用
+func InjectHTTP(ctx context.Context, header http.Header) {
+	if value := TraceparentFromContext(ctx); value != "" {
+		header.Set(TraceparentHeader, value)
+	}
+}
+
+func remoteParentFromContext(ctx context.Context) (remoteParent, bool) {
+	remote, ok := ctx.Value(remoteParentKey{}).(remoteParent)
+	return remote, ok
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
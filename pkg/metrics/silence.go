@@ -0,0 +1,354 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 过期静默规则与维护窗口的清理间隔与保留时长，保留期内仍可通过 includeExpired 查询
+const (
+	silenceCleanupInterval = 10 * time.Minute
+	silenceRetention       = 24 * time.Hour
+)
+
+// 被抑制告警的注解键
+const (
+	AnnotationSilencedBy        = "silenced_by"
+	AnnotationSilenceComment    = "silence_comment"
+	AnnotationMaintenanceWindow = "maintenance_window"
+	AnnotationSuppressedUntil   = "suppressed_until"
+)
+
+var (
+	// ErrSilenceNotFound 静默规则不存在
+	ErrSilenceNotFound = errors.New("silence not found")
+	// ErrMaintenanceWindowNotFound 维护窗口不存在
+	ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+	// ErrInvalidTimeRange 结束时间早于开始时间
+	ErrInvalidTimeRange = errors.New("endsAt must be after startsAt")
+)
+
+// Silence 告警静默规则，按告警名称与标签匹配，在时间范围内生效
+type Silence struct {
+	ID string `json:"id"`
+	// 告警名称，为空时匹配所有告警
+	AlertName string `json:"alertName"`
+	// 标签需全部相等才算匹配
+	Labels    map[string]string `json:"labels,omitempty"`
+	StartsAt  time.Time         `json:"startsAt"`
+	EndsAt    time.Time         `json:"endsAt"`
+	Comment   string            `json:"comment,omitempty"`
+	CreatedBy string            `json:"createdBy,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// MaintenanceWindow 维护窗口，如发布期间，窗口内匹配的告警全部抑制
+type MaintenanceWindow struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// 生效范围标签，为空时作用于所有告警
+	Labels    map[string]string `json:"labels,omitempty"`
+	StartsAt  time.Time         `json:"startsAt"`
+	EndsAt    time.Time         `json:"endsAt"`
+	Comment   string            `json:"comment,omitempty"`
+	CreatedBy string            `json:"createdBy,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Suppression 告警抑制结果
+type Suppression struct {
+	Silence           *Silence           `json:"silence,omitempty"`
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+}
+
+// Until 抑制的结束时间
+func (s *Suppression) Until() time.Time {
+	if s.MaintenanceWindow != nil {
+		return s.MaintenanceWindow.EndsAt
+	}
+	if s.Silence != nil {
+		return s.Silence.EndsAt
+	}
+	return time.Time{}
+}
+
+// Annotations 被抑制告警上附加的注解
+func (s *Suppression) Annotations() map[string]string {
+	ann := map[string]string{}
+	if s.Silence != nil {
+		ann[AnnotationSilencedBy] = s.Silence.ID
+		if s.Silence.Comment != "" {
+			ann[AnnotationSilenceComment] = s.Silence.Comment
+		}
+	}
+	if s.MaintenanceWindow != nil {
+		ann[AnnotationMaintenanceWindow] = s.MaintenanceWindow.Name
+	}
+	ann[AnnotationSuppressedUntil] = s.Until().Format(time.RFC3339)
+	return ann
+}
+
+// SilenceManager 管理告警静默规则与维护窗口，供告警引擎在通知前检查
+type SilenceManager struct {
+	mu       sync.RWMutex
+	silences map[string]*Silence
+	windows  map[string]*MaintenanceWindow
+	seq      int64
+	now      func() time.Time
+	stop     chan struct{}
+}
+
+// NewSilenceManager 创建静默管理器
+func NewSilenceManager() *SilenceManager {
+	return &SilenceManager{
+		silences: make(map[string]*Silence),
+		windows:  make(map[string]*MaintenanceWindow),
+		now:      time.Now,
+	}
+}
+
+// nextID 生成ID，调用方需持有写锁
+func (sm *SilenceManager) nextID(prefix string) string {
+	sm.seq++
+	return fmt.Sprintf("%s_%d_%d", prefix, sm.now().Unix(), sm.seq)
+}
+
+// AddSilence 添加静默规则，未指定开始时间时立即生效
+func (sm *SilenceManager) AddSilence(s Silence) (*Silence, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := sm.now()
+	if s.StartsAt.IsZero() {
+		s.StartsAt = now
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return nil, ErrInvalidTimeRange
+	}
+	s.ID = sm.nextID("silence")
+	s.CreatedAt = now
+	sm.silences[s.ID] = &s
+	return &s, nil
+}
+
+// ExpireSilence 立即结束静默规则
+func (sm *SilenceManager) ExpireSilence(id string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.silences[id]
+	if !ok {
+		return ErrSilenceNotFound
+	}
+	now := sm.now()
+	if s.EndsAt.After(now) {
+		s.EndsAt = now
+	}
+	return nil
+}
+
+// ListSilences 列出静默规则，按开始时间倒序
+func (sm *SilenceManager) ListSilences(includeExpired bool) []*Silence {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := sm.now()
+	list := make([]*Silence, 0, len(sm.silences))
+	for _, s := range sm.silences {
+		if !includeExpired && !s.EndsAt.After(now) {
+			continue
+		}
+		cp := *s
+		list = append(list, &cp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartsAt.After(list[j].StartsAt) })
+	return list
+}
+
+// AddMaintenanceWindow 添加维护窗口
+func (sm *SilenceManager) AddMaintenanceWindow(w MaintenanceWindow) (*MaintenanceWindow, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := sm.now()
+	if w.StartsAt.IsZero() {
+		w.StartsAt = now
+	}
+	if !w.EndsAt.After(w.StartsAt) {
+		return nil, ErrInvalidTimeRange
+	}
+	if w.Name == "" {
+		w.Name = "maintenance"
+	}
+	w.ID = sm.nextID("maintenance")
+	w.CreatedAt = now
+	sm.windows[w.ID] = &w
+	return &w, nil
+}
+
+// DeleteMaintenanceWindow 删除维护窗口
+func (sm *SilenceManager) DeleteMaintenanceWindow(id string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if _, ok := sm.windows[id]; !ok {
+		return ErrMaintenanceWindowNotFound
+	}
+	delete(sm.windows, id)
+	return nil
+}
+
+// ListMaintenanceWindows 列出维护窗口，按开始时间倒序
+func (sm *SilenceManager) ListMaintenanceWindows(includeExpired bool) []*MaintenanceWindow {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := sm.now()
+	list := make([]*MaintenanceWindow, 0, len(sm.windows))
+	for _, w := range sm.windows {
+		if !includeExpired && !w.EndsAt.After(now) {
+			continue
+		}
+		cp := *w
+		list = append(list, &cp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartsAt.After(list[j].StartsAt) })
+	return list
+}
+
+// InMaintenance 当前是否处于任一全局维护窗口内
+func (sm *SilenceManager) InMaintenance() bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := sm.now()
+	for _, w := range sm.windows {
+		if len(w.Labels) == 0 && activeAt(w.StartsAt, w.EndsAt, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 检查告警是否被静默或处于维护窗口，返回 nil 表示未被抑制
+func (sm *SilenceManager) Check(alertName string, labels map[string]string) *Suppression {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	now := sm.now()
+	var sup Suppression
+	for _, w := range sm.windows {
+		if activeAt(w.StartsAt, w.EndsAt, now) && matchLabels(w.Labels, labels) {
+			if sup.MaintenanceWindow == nil || w.EndsAt.After(sup.MaintenanceWindow.EndsAt) {
+				cp := *w
+				sup.MaintenanceWindow = &cp
+			}
+		}
+	}
+	for _, s := range sm.silences {
+		if !activeAt(s.StartsAt, s.EndsAt, now) {
+			continue
+		}
+		if s.AlertName != "" && s.AlertName != alertName {
+			continue
+		}
+		if !matchLabels(s.Labels, labels) {
+			continue
+		}
+		if sup.Silence == nil || s.EndsAt.After(sup.Silence.EndsAt) {
+			cp := *s
+			sup.Silence = &cp
+		}
+	}
+	if sup.Silence == nil && sup.MaintenanceWindow == nil {
+		return nil
+	}
+	return &sup
+}
+
+// Annotate 若告警被抑制，将抑制信息写入注解并返回 true
+func (sm *SilenceManager) Annotate(alertName string, labels, annotations map[string]string) bool {
+	sup := sm.Check(alertName, labels)
+	if sup == nil {
+		return false
+	}
+	for k, v := range sup.Annotations() {
+		annotations[k] = v
+	}
+	return true
+}
+
+// Start 定期清理过期的静默规则与维护窗口
+func (sm *SilenceManager) Start(interval, retention time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.stop != nil {
+		return
+	}
+	sm.stop = make(chan struct{})
+	go sm.cleanupLoop(sm.stop, interval, retention)
+}
+
+// Stop 停止定期清理
+func (sm *SilenceManager) Stop() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.stop == nil {
+		return
+	}
+	close(sm.stop)
+	sm.stop = nil
+}
+
+func (sm *SilenceManager) cleanupLoop(stop chan struct{}, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sm.Cleanup(retention)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Cleanup 清理结束时间早于 retention 之前的静默规则与维护窗口
+func (sm *SilenceManager) Cleanup(retention time.Duration) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	cutoff := sm.now().Add(-retention)
+	removed := 0
+	for id, s := range sm.silences {
+		if s.EndsAt.Before(cutoff) {
+			delete(sm.silences, id)
+			removed++
+		}
+	}
+	for id, w := range sm.windows {
+		if w.EndsAt.Before(cutoff) {
+			delete(sm.windows, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// activeAt 判断时间点是否在 [start, end) 内
+func activeAt(start, end, at time.Time) bool {
+	return !at.Before(start) && at.Before(end)
+}
+
+// matchLabels 判断 labels 是否包含 matchers 中的全部键值
+func matchLabels(matchers, labels map[string]string) bool {
+	for k, v := range matchers {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
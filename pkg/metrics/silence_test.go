@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSilenceMatching(t *testing.T) {
+	sm := NewSilenceManager()
+	now := time.Now()
+	sm.now = func() time.Time { return now }
+
+	_, err := sm.AddSilence(Silence{AlertName: "HighCPU", EndsAt: now.Add(-time.Minute)})
+	assert.ErrorIs(t, err, ErrInvalidTimeRange)
+
+	s, err := sm.AddSilence(Silence{
+		AlertName: "HighCPU",
+		Labels:    map[string]string{"instance": "web-1"},
+		EndsAt:    now.Add(time.Hour),
+		Comment:   "investigating",
+	})
+	require.NoError(t, err)
+
+	assert.Nil(t, sm.Check("HighCPU", map[string]string{"instance": "web-2"}))
+	assert.Nil(t, sm.Check("HighMemory", map[string]string{"instance": "web-1"}))
+
+	ann := map[string]string{}
+	assert.True(t, sm.Annotate("HighCPU", map[string]string{"instance": "web-1", "env": "prod"}, ann))
+	assert.Equal(t, s.ID, ann[AnnotationSilencedBy])
+	assert.Equal(t, "investigating", ann[AnnotationSilenceComment])
+
+	require.NoError(t, sm.ExpireSilence(s.ID))
+	assert.Nil(t, sm.Check("HighCPU", map[string]string{"instance": "web-1"}))
+	assert.Empty(t, sm.ListSilences(false))
+	assert.Len(t, sm.ListSilences(true), 1)
+	assert.ErrorIs(t, sm.ExpireSilence("missing"), ErrSilenceNotFound)
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	sm := NewSilenceManager()
+	now := time.Now()
+	sm.now = func() time.Time { return now }
+
+	w, err := sm.AddMaintenanceWindow(MaintenanceWindow{Name: "deploy v2", EndsAt: now.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	assert.True(t, sm.InMaintenance())
+
+	sup := sm.Check("AnyAlert", nil)
+	require.NotNil(t, sup)
+	assert.Equal(t, "deploy v2", sup.Annotations()[AnnotationMaintenanceWindow])
+
+	// 窗口结束后不再抑制，并可被清理
+	now = now.Add(time.Hour)
+	assert.False(t, sm.InMaintenance())
+	assert.Nil(t, sm.Check("AnyAlert", nil))
+	assert.Equal(t, 1, sm.Cleanup(10*time.Minute))
+	assert.ErrorIs(t, sm.DeleteMaintenanceWindow(w.ID), ErrMaintenanceWindowNotFound)
+}
+
+func TestSilenceCleanupLoop(t *testing.T) {
+	sm := NewSilenceManager()
+	s, err := sm.AddSilence(Silence{AlertName: "HighCPU", EndsAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	require.NoError(t, sm.ExpireSilence(s.ID))
+	require.Len(t, sm.ListSilences(true), 1)
+
+	sm.Start(10*time.Millisecond, 0)
+	defer sm.Stop()
+	assert.Eventually(t, func() bool {
+		return len(sm.ListSilences(true)) == 0
+	}, time.Second, 10*time.Millisecond)
+}
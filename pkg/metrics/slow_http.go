@@ -0,0 +1,249 @@
+package metrics
+
+import (
+	"HibiscusIM/pkg/redact"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认的慢请求样本数与请求/响应体截断长度
+const (
+	defaultMaxSlowHTTPSamples = 200
+	defaultSlowHTTPBodyLimit  = 4096
+)
+
+// sensitiveHeaders 不记录原值的请求头
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"x-auth-token":        true,
+	"x-csrf-token":        true,
+}
+
+// DBTimeBreakdown 慢请求内按表和操作汇总的数据库耗时
+type DBTimeBreakdown struct {
+	Table     string        `json:"table"`
+	Operation string        `json:"operation"`
+	Count     int           `json:"count"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// SlowHTTPRequest 慢 HTTP 请求样本
+type SlowHTTPRequest struct {
+	ID        string        `json:"id"`
+	TraceID   string        `json:"trace_id,omitempty"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Route     string        `json:"route"`
+	Query     string        `json:"query,omitempty"`
+	Handler   string        `json:"handler"`
+	Status    int           `json:"status"`
+	Duration  time.Duration `json:"duration"`
+	StartTime time.Time     `json:"start_time"`
+	ClientIP  string        `json:"client_ip"`
+	// 请求头，凭证类请求头只保留占位
+	Headers map[string]string `json:"headers"`
+	// 请求体与响应体，超过长度限制时截断
+	RequestBody           string `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool   `json:"request_body_truncated,omitempty"`
+	ResponseBody          string `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool   `json:"response_body_truncated,omitempty"`
+	// 同一链路中 SQL 分析器记录的查询耗时
+	DBTime      time.Duration     `json:"db_time"`
+	DBQueries   int               `json:"db_queries"`
+	DBBreakdown []DBTimeBreakdown `json:"db_breakdown,omitempty"`
+}
+
+// SlowHTTPRecorder 保存最近的慢请求样本，超过容量时丢弃最早的样本
+type SlowHTTPRecorder struct {
+	threshold time.Duration
+	bodyLimit int
+	max       int
+
+	mu      sync.RWMutex
+	samples []*SlowHTTPRequest
+}
+
+// NewSlowHTTPRecorder 创建慢请求记录器，threshold <= 0 时不记录
+func NewSlowHTTPRecorder(threshold time.Duration, maxSamples, bodyLimit int) *SlowHTTPRecorder {
+	if maxSamples <= 0 {
+		maxSamples = defaultMaxSlowHTTPSamples
+	}
+	if bodyLimit <= 0 {
+		bodyLimit = defaultSlowHTTPBodyLimit
+	}
+	return &SlowHTTPRecorder{threshold: threshold, bodyLimit: bodyLimit, max: maxSamples}
+}
+
+// Threshold 慢请求阈值
+func (r *SlowHTTPRecorder) Threshold() time.Duration {
+	return r.threshold
+}
+
+// add 保存样本
+func (r *SlowHTTPRecorder) add(sample *SlowHTTPRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, sample)
+	if len(r.samples) > r.max {
+		r.samples = r.samples[len(r.samples)-r.max:]
+	}
+}
+
+// List 最近的慢请求，按开始时间倒序；route 不为空时只返回该路由的样本
+func (r *SlowHTTPRecorder) List(route string, limit int) []*SlowHTTPRequest {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*SlowHTTPRequest, 0, len(r.samples))
+	for i := len(r.samples) - 1; i >= 0; i-- {
+		if route != "" && r.samples[i].Route != route {
+			continue
+		}
+		out = append(out, r.samples[i])
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// Get 按ID获取慢请求样本
+func (r *SlowHTTPRecorder) Get(id string) (*SlowHTTPRequest, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, s := range r.samples {
+		if s.ID == id {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// limitedBuffer 只保留前 limit 字节
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// captureBody 包装请求体，处理器读取时复制前 limit 字节
+type captureBody struct {
+	io.ReadCloser
+	buf *limitedBuffer
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.buf.Write(p[:n])
+	return n, err
+}
+
+// captureWriter 包装响应，写出时复制前 limit 字节
+type captureWriter struct {
+	gin.ResponseWriter
+	buf *limitedBuffer
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	_, _ = w.buf.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	_, _ = w.buf.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// capture 开始捕获请求体与响应体，返回请求结束后生成样本的函数
+func (r *SlowHTTPRecorder) capture(c *gin.Context) func(start time.Time, duration time.Duration, traceID string, queries []*SQLQuery) {
+	reqBuf := &limitedBuffer{limit: r.bodyLimit}
+	if c.Request.Body != nil && c.Request.Body != http.NoBody {
+		c.Request.Body = &captureBody{ReadCloser: c.Request.Body, buf: reqBuf}
+	}
+	respBuf := &limitedBuffer{limit: r.bodyLimit}
+	c.Writer = &captureWriter{ResponseWriter: c.Writer, buf: respBuf}
+
+	return func(start time.Time, duration time.Duration, traceID string, queries []*SQLQuery) {
+		red := redact.Default()
+		headers := make(map[string]string, len(c.Request.Header))
+		for k, v := range c.Request.Header {
+			if sensitiveHeaders[strings.ToLower(k)] {
+				headers[k] = redact.Mask
+				continue
+			}
+			headers[k] = red.Tag(k, strings.Join(v, ", "))
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		sample := &SlowHTTPRequest{
+			ID:                    fmt.Sprintf("http_%d", start.UnixNano()),
+			TraceID:               traceID,
+			Method:                c.Request.Method,
+			Path:                  c.Request.URL.Path,
+			Route:                 route,
+			Query:                 red.String(c.Request.URL.RawQuery),
+			Handler:               c.HandlerName(),
+			Status:                c.Writer.Status(),
+			Duration:              duration,
+			StartTime:             start,
+			ClientIP:              c.ClientIP(),
+			Headers:               headers,
+			RequestBody:           red.JSON(reqBuf.buf.String()),
+			RequestBodyTruncated:  reqBuf.truncated,
+			ResponseBody:          red.JSON(respBuf.buf.String()),
+			ResponseBodyTruncated: respBuf.truncated,
+		}
+		sample.DBTime, sample.DBQueries, sample.DBBreakdown = dbBreakdown(queries)
+		r.add(sample)
+	}
+}
+
+// dbBreakdown 按表和操作汇总查询耗时，耗时高的在前
+func dbBreakdown(queries []*SQLQuery) (time.Duration, int, []DBTimeBreakdown) {
+	var total time.Duration
+	groups := make(map[string]*DBTimeBreakdown)
+	for _, q := range queries {
+		total += q.Duration
+		key := q.Table + "\x00" + q.Operation
+		g, ok := groups[key]
+		if !ok {
+			g = &DBTimeBreakdown{Table: q.Table, Operation: q.Operation}
+			groups[key] = g
+		}
+		g.Count++
+		g.Duration += q.Duration
+	}
+	out := make([]DBTimeBreakdown, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Duration > out[j].Duration })
+	return total, len(queries), out
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"HibiscusIM/pkg/redact"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowHTTPCapture(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMonitor(&MonitorConfig{
+		EnableTracing:      true,
+		MaxSpans:           100,
+		EnableSQLAnalysis:  true,
+		MaxQueries:         100,
+		SlowThreshold:      time.Second,
+		SlowHTTPThreshold:  10 * time.Millisecond,
+		MaxSlowHTTPSamples: 10,
+		SlowHTTPBodyLimit:  16,
+	})
+
+	r := gin.New()
+	r.Use(MonitorMiddleware(m))
+	r.POST("/orders/:id", func(c *gin.Context) {
+		var body map[string]any
+		_ = c.ShouldBindJSON(&body)
+		ctx := c.Request.Context()
+		m.GetSQLAnalyzer().RecordQuery(ctx, "SELECT * FROM orders", nil, "orders", "SELECT", 3*time.Millisecond, 1, nil)
+		m.GetSQLAnalyzer().RecordQuery(ctx, "SELECT * FROM orders", nil, "orders", "SELECT", 2*time.Millisecond, 1, nil)
+		m.GetSQLAnalyzer().RecordQuery(ctx, "UPDATE users", nil, "users", "UPDATE", 4*time.Millisecond, 1, nil)
+		if c.Param("id") == "slow" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "padding": strings.Repeat("x", 32)})
+	})
+	NewMonitorAPI(m).RegisterRoutes(r.Group("/monitor"))
+
+	do := func(path string) {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"note":"a fairly long request body"}`))
+		req.Header.Set("Authorization", "Bearer secret-token")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	do("/orders/fast")
+	do("/orders/slow")
+
+	// 未超过阈值的请求不记录
+	samples := m.GetSlowHTTPRecorder().List("", 0)
+	require.Len(t, samples, 1)
+	s := samples[0]
+	assert.Equal(t, "/orders/:id", s.Route)
+	assert.Equal(t, "/orders/slow", s.Path)
+	assert.Equal(t, redact.Mask, s.Headers["Authorization"])
+	assert.Equal(t, "application/json", s.Headers["Content-Type"])
+	assert.Len(t, s.RequestBody, 16)
+	assert.True(t, s.RequestBodyTruncated)
+	assert.True(t, s.ResponseBodyTruncated)
+	assert.NotEmpty(t, s.TraceID)
+	assert.Equal(t, 3, s.DBQueries)
+	assert.Equal(t, 9*time.Millisecond, s.DBTime)
+	assert.Equal(t, []DBTimeBreakdown{
+		{Table: "orders", Operation: "SELECT", Count: 2, Duration: 5 * time.Millisecond},
+		{Table: "users", Operation: "UPDATE", Count: 1, Duration: 4 * time.Millisecond},
+	}, s.DBBreakdown)
+	assert.Empty(t, m.GetSlowHTTPRecorder().List("/users", 0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/monitor/http/slow?route=/orders/:id", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data []struct {
+			Request  SlowHTTPRequest `json:"request"`
+			TraceURL string          `json:"trace_url"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "/monitor/traces/"+s.TraceID, list.Data[0].TraceURL)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/monitor/http/slow/"+s.ID, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/monitor/http/slow/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSlowHTTPRoutesRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMonitor(&MonitorConfig{SlowHTTPThreshold: time.Millisecond, MaxSlowHTTPSamples: 10})
+	r := gin.New()
+	deny := func(c *gin.Context) {
+		if c.GetHeader("X-Admin") == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+	NewMonitorAPI(m).RegisterRoutes(r.Group("/monitor"), deny)
+
+	// 样本包含请求头与请求体，只有管理员可以查看
+	for _, path := range []string{"/monitor/http/slow", "/monitor/http/slow/missing"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/monitor/http/slow", nil)
+	req.Header.Set("X-Admin", "1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
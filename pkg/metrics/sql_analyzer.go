@@ -1,7 +1,9 @@
 package metrics
 
 import (
+	"HibiscusIM/pkg/redact"
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"sort"
@@ -23,14 +25,15 @@ type SQLQuery struct {
 	EndTime      time.Time              `json:"end_time"`
 	RowsAffected int64                  `json:"rows_affected"`
 	Error        error                  `json:"error,omitempty"`
-	ExplainPlan  *ExplainPlan           `json:"explain_plan,omitempty"`
+	ExplainPlan  []ExplainPlan          `json:"explain_plan,omitempty"`
 	Tags         map[string]string      `json:"tags"`
 	Attributes   map[string]interface{} `json:"attributes"`
 }
 
-// ExplainPlan 执行计划
+// ExplainPlan 执行计划中的一行，MySQL 填充 EXPLAIN 各列，SQLite 填充 Parent 与 Detail
 type ExplainPlan struct {
 	ID           int     `json:"id"`
+	Parent       int     `json:"parent,omitempty"`
 	SelectType   string  `json:"select_type"`
 	Table        string  `json:"table"`
 	Partitions   string  `json:"partitions"`
@@ -43,6 +46,7 @@ type ExplainPlan struct {
 	Filtered     float64 `json:"filtered"`
 	Extra        string  `json:"extra"`
 	Cost         float64 `json:"cost"`
+	Detail       string  `json:"detail,omitempty"`
 }
 
 // SQLAnalyzer SQL分析器
@@ -53,6 +57,7 @@ type SQLAnalyzer struct {
 	maxQueries    int
 	slowThreshold time.Duration
 	patterns      map[string]*QueryPattern
+	explainer     *Explainer
 }
 
 // QueryPattern 查询模式
@@ -79,20 +84,29 @@ func NewSQLAnalyzer(maxQueries int, slowThreshold time.Duration) *SQLAnalyzer {
 	}
 }
 
+// SetExplainer 设置慢查询的 EXPLAIN 执行器，为 nil 时不采集执行计划
+func (sa *SQLAnalyzer) SetExplainer(e *Explainer) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+	sa.explainer = e
+}
+
 // RecordQuery 记录SQL查询
 func (sa *SQLAnalyzer) RecordQuery(ctx context.Context, sql string, params []interface{}, table, operation string, duration time.Duration, rowsAffected int64, err error) *SQLQuery {
+	// 参数与内联字面量可能含个人信息，保存前脱敏
+	r := redact.Default()
 	query := &SQLQuery{
 		ID:           generateQueryID(),
 		TraceID:      getTraceIDFromContext(ctx),
-		SQL:          sql,
-		Params:       params,
+		SQL:          r.String(sql),
+		Params:       r.Slice(params),
 		Table:        table,
 		Operation:    operation,
 		Duration:     duration,
 		StartTime:    time.Now().Add(-duration),
 		EndTime:      time.Now(),
 		RowsAffected: rowsAffected,
-		Error:        err,
+		Error:        redactError(r, err),
 		Tags:         make(map[string]string),
 		Attributes:   make(map[string]interface{}),
 	}
@@ -120,6 +134,13 @@ func (sa *SQLAnalyzer) RecordQuery(ctx context.Context, sql string, params []int
 		if len(sa.slowQueries) > 1000 {
 			sa.slowQueries = sa.slowQueries[1:]
 		}
+		// 执行计划需用原始语句与参数获取，完成后写回记录
+		if sa.explainer != nil {
+			id := query.ID
+			sa.explainer.submit(sa.normalizeSQL(query.SQL), sql, params, func(plan []ExplainPlan) {
+				sa.setExplainPlan(id, plan)
+			})
+		}
 	}
 
 	// 分析查询模式
@@ -128,6 +149,61 @@ func (sa *SQLAnalyzer) RecordQuery(ctx context.Context, sql string, params []int
 	return query
 }
 
+// setExplainPlan 以副本替换记录写入执行计划，已返回给调用方的记录不会被修改
+func (sa *SQLAnalyzer) setExplainPlan(id string, plan []ExplainPlan) {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	var updated *SQLQuery
+	for i := len(sa.slowQueries) - 1; i >= 0; i-- {
+		if q := sa.slowQueries[i]; q.ID == id {
+			cp := *q
+			cp.ExplainPlan = plan
+			updated = &cp
+			sa.slowQueries[i] = updated
+			break
+		}
+	}
+	if updated == nil {
+		return
+	}
+	if _, ok := sa.queries[id]; ok {
+		sa.queries[id] = updated
+	}
+}
+
+// redactError 错误信息可能带出参数值，脱敏后若有变化则替换为新的错误
+func redactError(r *redact.Redactor, err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := r.String(err.Error()); msg != err.Error() {
+		return errors.New(msg)
+	}
+	return err
+}
+
+// redacted 返回脱敏后的副本，供接口输出
+func (q *SQLQuery) redacted(r *redact.Redactor) *SQLQuery {
+	out := *q
+	out.SQL = r.String(q.SQL)
+	out.Params = r.Slice(q.Params)
+	out.Error = redactError(r, q.Error)
+	out.Tags = r.StringMap(q.Tags)
+	out.Attributes = r.Map(q.Attributes)
+	return &out
+}
+
+// redactQueries 批量脱敏查询记录
+func redactQueries(queries []*SQLQuery) []*SQLQuery {
+	r := redact.Default()
+	out := make([]*SQLQuery, len(queries))
+	for i, q := range queries {
+		out[i] = q.redacted(r)
+	}
+	return out
+}
+
 // analyzeQueryPattern 分析查询模式
 func (sa *SQLAnalyzer) analyzeQueryPattern(query *SQLQuery) {
 	// 生成查询模式（去除具体值，保留结构）
@@ -220,6 +296,26 @@ func (sa *SQLAnalyzer) GetQueryPatterns(limit int) []*QueryPattern {
 	return patterns
 }
 
+// GetQueriesByTrace 获取同一链路中的查询，按开始时间排序
+func (sa *SQLAnalyzer) GetQueriesByTrace(traceID string) []*SQLQuery {
+	if traceID == "" {
+		return nil
+	}
+	sa.mu.RLock()
+	defer sa.mu.RUnlock()
+
+	var queries []*SQLQuery
+	for _, q := range sa.queries {
+		if q.TraceID == traceID {
+			queries = append(queries, q)
+		}
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].StartTime.Before(queries[j].StartTime)
+	})
+	return queries
+}
+
 // GetQueriesByTable 按表获取查询
 func (sa *SQLAnalyzer) GetQueriesByTable(table string, limit int) []*SQLQuery {
 	sa.mu.RLock()
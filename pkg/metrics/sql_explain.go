@@ -0,0 +1,285 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultExplainConcurrency = 2
+	defaultExplainTimeout     = 2 * time.Second
+	// explainCooldown 同一查询模式在该时间内只执行一次 EXPLAIN
+	explainCooldown = 5 * time.Minute
+)
+
+// ErrExplainUnsupported 方言或语句不支持 EXPLAIN
+var ErrExplainUnsupported = errors.New("explain not supported")
+
+// Explainer 对慢查询执行 EXPLAIN，支持 MySQL 与 SQLite；同时执行的 EXPLAIN 数量受限，
+// 超出时直接跳过，不阻塞业务查询
+type Explainer struct {
+	db      *gorm.DB
+	dialect string
+	timeout time.Duration
+	sem     chan struct{}
+
+	mu        sync.Mutex
+	explained map[string]time.Time // 查询模式最近一次 EXPLAIN 的时间
+}
+
+// NewExplainer 创建 EXPLAIN 执行器，concurrency <= 0 时最多同时执行 2 个
+func NewExplainer(db *gorm.DB, concurrency int) (*Explainer, error) {
+	dialect := db.Dialector.Name()
+	if dialect != "mysql" && dialect != "sqlite" {
+		return nil, fmt.Errorf("%w: dialect %s", ErrExplainUnsupported, dialect)
+	}
+	if concurrency <= 0 {
+		concurrency = defaultExplainConcurrency
+	}
+	return &Explainer{
+		db:        db,
+		dialect:   dialect,
+		timeout:   defaultExplainTimeout,
+		sem:       make(chan struct{}, concurrency),
+		explained: make(map[string]time.Time),
+	}, nil
+}
+
+// submit 在后台执行 EXPLAIN 并回调结果；并发已满或同一模式冷却期内时跳过
+func (e *Explainer) submit(pattern, query string, params []interface{}, done func([]ExplainPlan)) {
+	now := time.Now()
+	e.mu.Lock()
+	if last, ok := e.explained[pattern]; ok && now.Sub(last) < explainCooldown {
+		e.mu.Unlock()
+		return
+	}
+	select {
+	case e.sem <- struct{}{}:
+	default:
+		e.mu.Unlock()
+		return
+	}
+	e.explained[pattern] = now
+	for p, at := range e.explained {
+		if now.Sub(at) >= explainCooldown {
+			delete(e.explained, p)
+		}
+	}
+	e.mu.Unlock()
+
+	go func() {
+		defer func() { <-e.sem }()
+		ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+		defer cancel()
+		plan, err := e.Explain(ctx, query, params)
+		if err != nil {
+			if !errors.Is(err, ErrExplainUnsupported) {
+				log.Printf("explain slow query failed: %v", err)
+			}
+			return
+		}
+		done(plan)
+	}()
+}
+
+// Explain 将参数以转义后的字面量代入语句并执行 EXPLAIN。只处理单条 SELECT/UPDATE/DELETE 语句，
+// EXPLAIN 不会实际执行语句
+func (e *Explainer) Explain(ctx context.Context, query string, params []interface{}) ([]ExplainPlan, error) {
+	stmt := strings.TrimRight(strings.TrimSpace(query), "; \t\n")
+	if !explainable(stmt) {
+		return nil, ErrExplainUnsupported
+	}
+	bound, err := bindParams(stmt, params, e.dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := "EXPLAIN "
+	if e.dialect == "sqlite" {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+	rows, err := e.db.WithContext(ctx).Raw(prefix + bound).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var plans []ExplainPlan
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		dest := make([]interface{}, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(cols))
+		for i, col := range cols {
+			row[strings.ToLower(col)] = values[i].String
+		}
+		plans = append(plans, explainRow(row))
+	}
+	return plans, rows.Err()
+}
+
+// explainRow 将 MySQL EXPLAIN 或 SQLite EXPLAIN QUERY PLAN 的一行转换为执行计划
+func explainRow(row map[string]string) ExplainPlan {
+	atoi := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	rows, _ := strconv.ParseInt(row["rows"], 10, 64)
+	filtered, _ := strconv.ParseFloat(row["filtered"], 64)
+	return ExplainPlan{
+		ID:           atoi(row["id"]),
+		Parent:       atoi(row["parent"]),
+		SelectType:   row["select_type"],
+		Table:        row["table"],
+		Partitions:   row["partitions"],
+		Type:         row["type"],
+		PossibleKeys: row["possible_keys"],
+		Key:          row["key"],
+		KeyLen:       atoi(row["key_len"]),
+		Ref:          row["ref"],
+		Rows:         rows,
+		Filtered:     filtered,
+		Extra:        row["extra"],
+		Detail:       row["detail"],
+	}
+}
+
+// explainable 仅允许单条 SELECT/UPDATE/DELETE 语句
+func explainable(stmt string) bool {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "UPDATE", "DELETE":
+	default:
+		return false
+	}
+	return !strings.Contains(stripQuoted(stmt), ";")
+}
+
+// stripQuoted 去掉字符串字面量与引号标识符，便于检查语句结构
+func stripQuoted(stmt string) string {
+	var b strings.Builder
+	var quote rune
+	for _, r := range stmt {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// bindParams 将 ? 占位符替换为转义后的字面量，字面量与引号标识符中的 ? 不替换；
+// 占位符与参数数量不一致或参数类型无法安全转义时返回错误
+func bindParams(stmt string, params []interface{}, dialect string) (string, error) {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	n := 0
+	for _, r := range stmt {
+		if quote != 0 {
+			b.WriteRune(r)
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\' && dialect == "mysql":
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch r {
+		case '\'', '"', '`':
+			quote = r
+			b.WriteRune(r)
+		case '?':
+			if n >= len(params) {
+				return "", fmt.Errorf("explain: more placeholders than %d params", len(params))
+			}
+			lit, err := sqlLiteral(params[n], dialect)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(lit)
+			n++
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if n != len(params) {
+		return "", fmt.Errorf("explain: %d placeholders for %d params", n, len(params))
+	}
+	return b.String(), nil
+}
+
+// sqlLiteral 将参数转换为 SQL 字面量
+func sqlLiteral(v interface{}, dialect string) (string, error) {
+	switch x := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if x {
+			return "1", nil
+		}
+		return "0", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", x), nil
+	case float32:
+		return sqlFloat(float64(x))
+	case float64:
+		return sqlFloat(x)
+	case string:
+		return quoteString(x, dialect), nil
+	case []byte:
+		return "X'" + hex.EncodeToString(x) + "'", nil
+	case time.Time:
+		return quoteString(x.UTC().Format("2006-01-02 15:04:05.999999"), dialect), nil
+	case fmt.Stringer:
+		return quoteString(x.String(), dialect), nil
+	}
+	return "", fmt.Errorf("explain: unsupported param type %T", v)
+}
+
+func sqlFloat(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("explain: invalid float param %v", f)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
+
+// quoteString 单引号字符串字面量；MySQL 默认将反斜杠视为转义符，需一并转义
+func quoteString(s, dialect string) string {
+	if dialect == "mysql" {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
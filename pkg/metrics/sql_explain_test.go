@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBindParams(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	got, err := bindParams("SELECT * FROM users WHERE email = ? AND note = '?' AND id IN (?, ?) AND created_at > ?",
+		[]interface{}{"o'neil\\", 1, true, at}, "mysql")
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM users WHERE email = 'o''neil\\' AND note = '?' AND id IN (1, 1) AND created_at > '2024-05-01 08:30:00'`, got)
+
+	got, err = bindParams("SELECT ? , ?", []interface{}{"a\\b", nil}, "sqlite")
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT 'a\b' , NULL`, got)
+
+	_, err = bindParams("SELECT ?", nil, "sqlite")
+	assert.Error(t, err)
+	_, err = bindParams("SELECT 1", []interface{}{1}, "sqlite")
+	assert.Error(t, err)
+	_, err = bindParams("SELECT ?", []interface{}{struct{}{}}, "sqlite")
+	assert.Error(t, err)
+}
+
+func TestExplainable(t *testing.T) {
+	assert.True(t, explainable("select * from users where name = 'a;b'"))
+	assert.True(t, explainable("DELETE FROM users WHERE id = 1"))
+	assert.False(t, explainable("INSERT INTO users (id) VALUES (1)"))
+	assert.False(t, explainable("SELECT 1; DROP TABLE users"))
+	assert.False(t, explainable(""))
+}
+
+func TestSQLAnalyzerExplainPlan(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)").Error)
+	require.NoError(t, db.Exec("CREATE INDEX idx_users_email ON users (email)").Error)
+
+	e, err := NewExplainer(db, 1)
+	require.NoError(t, err)
+	plan, err := e.Explain(context.Background(), "SELECT * FROM users WHERE email = ?", []interface{}{"a@b.c"})
+	require.NoError(t, err)
+	require.NotEmpty(t, plan)
+	assert.Contains(t, plan[0].Detail, "idx_users_email")
+
+	_, err = e.Explain(context.Background(), "INSERT INTO users (email) VALUES (?)", []interface{}{"a@b.c"})
+	assert.ErrorIs(t, err, ErrExplainUnsupported)
+
+	sa := NewSQLAnalyzer(10, time.Millisecond)
+	sa.SetExplainer(e)
+	q := sa.RecordQuery(context.Background(), "SELECT * FROM users WHERE email = ?", []interface{}{"a@b.c"},
+		"users", "SELECT", 10*time.Millisecond, 1, nil)
+	assert.Empty(t, q.ExplainPlan)
+	require.Eventually(t, func() bool {
+		slow := sa.GetSlowQueries(0)
+		return len(slow) == 1 && len(slow[0].ExplainPlan) > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, q.ID, sa.GetSlowQueries(0)[0].ID)
+}
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"log"
+	"os"
 	"runtime"
 	"sync"
 	"time"
@@ -8,6 +10,7 @@ import (
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 	"github.com/shirou/gopsutil/v3/net"
 	"github.com/shirou/gopsutil/v3/process"
@@ -91,7 +94,7 @@ type InterfaceStats struct {
 	IsUp        bool   `json:"is_up"`
 }
 
-// ProcessStats 进程统计信息
+// ProcessStats 进程统计信息，包含子进程时 CPU、内存、线程与文件描述符为进程树合计
 type ProcessStats struct {
 	PID           int32   `json:"pid"`
 	PPID          int32   `json:"ppid"`
@@ -105,6 +108,7 @@ type ProcessStats struct {
 	NumFDs        int32   `json:"num_fds"`
 	CreateTime    int64   `json:"create_time"`
 	Uptime        float64 `json:"uptime"`
+	Children      int     `json:"children,omitempty"` // 计入合计的子进程数
 }
 
 // RuntimeStats Go运行时统计信息
@@ -153,6 +157,29 @@ type User struct {
 	Started  int    `json:"started"`
 }
 
+// ResourceThresholds 系统资源阈值，值为 0 时不检查该项
+type ResourceThresholds struct {
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
+}
+
+// DefaultResourceThresholds 默认阈值：CPU 使用率超过 90%、内存使用率超过 85%
+func DefaultResourceThresholds() ResourceThresholds {
+	return ResourceThresholds{CPUPercent: 90, MemoryPercent: 85}
+}
+
+// ThresholdEvent 资源使用率越过阈值的事件，Exceeded 为 false 表示已回落到阈值以内
+type ThresholdEvent struct {
+	Metric    string    `json:"metric"` // 与告警指标同名，如 cpu.usage_percent
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Exceeded  bool      `json:"exceeded"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ThresholdHandler 阈值事件回调，在采集协程中同步调用，耗时操作应自行异步处理
+type ThresholdHandler func(ThresholdEvent)
+
 // SystemMonitor 系统监控器
 type SystemMonitor struct {
 	stats         []*SystemStats
@@ -162,9 +189,25 @@ type SystemMonitor struct {
 	stopChan      chan struct{}
 	isRunning     bool
 	customMetrics map[string]interface{}
+	customSeries  map[string]*customSeries
+
+	// 进程监控目标，默认为当前进程
+	pid             int32
+	includeChildren bool
+	// 进程句柄在两次采集间复用以计算区间 CPU 使用率，仅由采集协程访问
+	proc     *process.Process
+	children map[int32]*process.Process
+
+	thresholds ResourceThresholds
+	handlers   []ThresholdHandler
+	exceeded   map[string]bool
+
+	// 按分辨率聚合的历史，保留时长远超原始样本
+	retention StatsRetention
+	tiers     []*rollupTier
 }
 
-// NewSystemMonitor 创建系统监控器
+// NewSystemMonitor 创建系统监控器，监控当前进程并使用默认资源阈值与聚合保留时长
 func NewSystemMonitor(maxStats int, interval time.Duration) *SystemMonitor {
 	return &SystemMonitor{
 		stats:         make([]*SystemStats, 0),
@@ -173,9 +216,45 @@ func NewSystemMonitor(maxStats int, interval time.Duration) *SystemMonitor {
 		stopChan:      make(chan struct{}),
 		isRunning:     false,
 		customMetrics: make(map[string]interface{}),
+		customSeries:  make(map[string]*customSeries),
+		pid:           int32(os.Getpid()),
+		children:      make(map[int32]*process.Process),
+		thresholds:    DefaultResourceThresholds(),
+		exceeded:      make(map[string]bool),
+		retention:     DefaultStatsRetention(),
+		tiers:         newRollupTiers(DefaultStatsRetention()),
 	}
 }
 
+// SetProcessTarget 设置监控的进程，includeChildren 为 true 时同时统计其全部子孙进程
+func (sm *SystemMonitor) SetProcessTarget(pid int32, includeChildren bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.pid = pid
+	sm.includeChildren = includeChildren
+}
+
+// SetThresholds 设置资源阈值
+func (sm *SystemMonitor) SetThresholds(t ResourceThresholds) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.thresholds = t
+}
+
+// Thresholds 当前资源阈值
+func (sm *SystemMonitor) Thresholds() ResourceThresholds {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.thresholds
+}
+
+// OnThreshold 注册阈值回调，资源使用率超过阈值及回落时各调用一次
+func (sm *SystemMonitor) OnThreshold(fn ThresholdHandler) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.handlers = append(sm.handlers, fn)
+}
+
 // Start 启动监控
 func (sm *SystemMonitor) Start() {
 	if sm.isRunning {
@@ -239,14 +318,21 @@ func (sm *SystemMonitor) collectStats() {
 	// 收集主机信息
 	sm.collectHostStats(stats)
 
+	// 采样自定义指标时间序列
+	sm.sampleCustomMetrics(stats.Timestamp)
+
+	sm.record(stats)
+}
+
+// record 保存一次采集结果并触发阈值回调
+func (sm *SystemMonitor) record(stats *SystemStats) {
+	sm.mu.Lock()
+
 	// 复制自定义指标
 	for k, v := range sm.customMetrics {
 		stats.CustomMetrics[k] = v
 	}
 
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
 	// 添加新统计信息
 	sm.stats = append(sm.stats, stats)
 
@@ -254,6 +340,48 @@ func (sm *SystemMonitor) collectStats() {
 	if len(sm.stats) > sm.maxStats {
 		sm.stats = sm.stats[1:]
 	}
+	sm.rollupLocked(stats)
+	events := sm.thresholdEventsLocked(stats)
+	handlers := append([]ThresholdHandler(nil), sm.handlers...)
+	sm.mu.Unlock()
+
+	// 统计写入后再回调，回调中读取到的最新统计即为本次采集
+	for _, ev := range events {
+		for _, fn := range handlers {
+			fn(ev)
+		}
+	}
+}
+
+// thresholdEventsLocked 对比上次状态，返回本次越过阈值或回落的事件，调用方需持有写锁
+func (sm *SystemMonitor) thresholdEventsLocked(stats *SystemStats) []ThresholdEvent {
+	checks := []struct {
+		metric    string
+		value     float64
+		threshold float64
+	}{
+		{"cpu.usage_percent", stats.CPU.UsagePercent, sm.thresholds.CPUPercent},
+		{"memory.usage_percent", stats.Memory.UsagePercent, sm.thresholds.MemoryPercent},
+	}
+	var events []ThresholdEvent
+	for _, c := range checks {
+		exceeded := c.threshold > 0 && c.value > c.threshold
+		if exceeded == sm.exceeded[c.metric] {
+			continue
+		}
+		sm.exceeded[c.metric] = exceeded
+		if exceeded {
+			log.Printf("system monitor: %s %.1f exceeds threshold %.1f", c.metric, c.value, c.threshold)
+		}
+		events = append(events, ThresholdEvent{
+			Metric:    c.metric,
+			Value:     c.value,
+			Threshold: c.threshold,
+			Exceeded:  exceeded,
+			Timestamp: stats.Timestamp,
+		})
+	}
+	return events
 }
 
 // collectCPUStats 收集CPU统计信息
@@ -271,7 +399,9 @@ func (sm *SystemMonitor) collectCPUStats(stats *SystemStats) {
 		stats.CPU.CountLogical = runtime.NumCPU()
 		stats.CPU.Frequency = cpuInfo[0].Mhz
 	}
-	stats.CPU.LoadAvg = []float64{0, 0, 0}
+	if avg, err := load.Avg(); err == nil {
+		stats.CPU.LoadAvg = []float64{avg.Load1, avg.Load5, avg.Load15}
+	}
 }
 
 // collectMemoryStats 收集内存统计信息
@@ -336,31 +466,103 @@ func (sm *SystemMonitor) collectNetworkStats(stats *SystemStats) {
 	}
 }
 
-// collectProcessStats 收集进程统计信息
+// collectProcessStats 收集监控目标进程的统计信息
 func (sm *SystemMonitor) collectProcessStats(stats *SystemStats) {
-	if p, err := process.NewProcess(int32(runtime.NumCPU())); err == nil {
-		if name, err := p.Name(); err == nil {
-			stats.Process.Name = name
-		}
-		stats.Process.Status = "unknown"
-		if cpuPercent, err := p.CPUPercent(); err == nil {
-			stats.Process.CPUPercent = cpuPercent
+	sm.mu.RLock()
+	pid, includeChildren := sm.pid, sm.includeChildren
+	sm.mu.RUnlock()
+
+	p := sm.proc
+	if p == nil || p.Pid != pid {
+		np, err := process.NewProcess(pid)
+		if err != nil {
+			return
 		}
-		if memoryPercent, err := p.MemoryPercent(); err == nil {
-			stats.Process.MemoryPercent = memoryPercent
+		p = np
+		sm.proc = np
+	}
+
+	stats.Process.PID = p.Pid
+	if ppid, err := p.Ppid(); err == nil {
+		stats.Process.PPID = ppid
+	}
+	if name, err := p.Name(); err == nil {
+		stats.Process.Name = name
+	}
+	stats.Process.Status = "unknown"
+	if status, err := p.Status(); err == nil && len(status) > 0 {
+		stats.Process.Status = status[0]
+	}
+	if createTime, err := p.CreateTime(); err == nil {
+		stats.Process.CreateTime = createTime
+		stats.Process.Uptime = float64(time.Now().Unix()-createTime/1000) / 3600 // 小时
+	}
+	addProcessUsage(&stats.Process, p)
+
+	if !includeChildren {
+		clear(sm.children)
+		return
+	}
+	seen := make(map[int32]bool)
+	for _, child := range descendants(p) {
+		seen[child.Pid] = true
+		// 复用上次的句柄，子进程的 CPU 使用率按采集间隔计算
+		if cached, ok := sm.children[child.Pid]; ok {
+			child = cached
+		} else {
+			sm.children[child.Pid] = child
 		}
-		if memoryInfo, err := p.MemoryInfo(); err == nil {
-			stats.Process.MemoryRSS = memoryInfo.RSS
-			stats.Process.MemoryVMS = memoryInfo.VMS
+		addProcessUsage(&stats.Process, child)
+		stats.Process.Children++
+	}
+	for pid := range sm.children {
+		if !seen[pid] {
+			delete(sm.children, pid)
 		}
-		if numThreads, err := p.NumThreads(); err == nil {
-			stats.Process.NumThreads = numThreads
+	}
+}
+
+// addProcessUsage 将进程的资源使用累加到统计中
+func addProcessUsage(stats *ProcessStats, p *process.Process) {
+	if cpuPercent, err := p.Percent(0); err == nil {
+		stats.CPUPercent += cpuPercent
+	}
+	if memoryPercent, err := p.MemoryPercent(); err == nil {
+		stats.MemoryPercent += memoryPercent
+	}
+	if memoryInfo, err := p.MemoryInfo(); err == nil {
+		stats.MemoryRSS += memoryInfo.RSS
+		stats.MemoryVMS += memoryInfo.VMS
+	}
+	if numThreads, err := p.NumThreads(); err == nil {
+		stats.NumThreads += numThreads
+	}
+	if numFDs, err := p.NumFDs(); err == nil {
+		stats.NumFDs += numFDs
+	}
+}
+
+// descendants 进程的全部子孙进程，进程无子进程或无法枚举时返回空
+func descendants(p *process.Process) []*process.Process {
+	var result []*process.Process
+	visited := map[int32]bool{p.Pid: true}
+	queue := []*process.Process{p}
+	for len(queue) > 0 {
+		children, err := queue[0].Children()
+		queue = queue[1:]
+		if err != nil {
+			continue
 		}
-		if createTime, err := p.CreateTime(); err == nil {
-			stats.Process.CreateTime = createTime
-			stats.Process.Uptime = float64(time.Now().Unix()-createTime/1000) / 3600 // 小时
+		for _, c := range children {
+			if visited[c.Pid] {
+				continue
+			}
+			visited[c.Pid] = true
+			result = append(result, c)
+			queue = append(queue, c)
 		}
 	}
+	return result
 }
 
 // collectRuntimeStats 收集运行时统计信息
@@ -444,11 +646,14 @@ func (sm *SystemMonitor) GetStatsHistory(limit int) []*SystemStats {
 	return result
 }
 
-// SetCustomMetric 设置自定义指标
+// SetCustomMetric 设置自定义指标，数值类型同时记录为仪表盘时间序列
 func (sm *SystemMonitor) SetCustomMetric(key string, value interface{}) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	sm.customMetrics[key] = value
+	if v, ok := toGaugeValue(value); ok {
+		sm.series(key, CustomMetricGauge).value = v
+	}
 }
 
 // GetCustomMetric 获取自定义指标
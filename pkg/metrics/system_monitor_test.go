@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemMonitorProcessTarget(t *testing.T) {
+	sm := NewSystemMonitor(3, time.Second)
+	stats := &SystemStats{}
+	sm.collectProcessStats(stats)
+	assert.Equal(t, int32(os.Getpid()), stats.Process.PID)
+	assert.NotEmpty(t, stats.Process.Name)
+	assert.NotZero(t, stats.Process.MemoryRSS)
+	assert.Zero(t, stats.Process.Children)
+
+	cmd := exec.Command("sleep", "5")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("start child process: %v", err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	sm.SetProcessTarget(int32(os.Getpid()), true)
+	stats = &SystemStats{}
+	sm.collectProcessStats(stats)
+	if stats.Process.Children == 0 {
+		t.Skip("child processes cannot be enumerated here")
+	}
+	assert.Contains(t, sm.children, int32(cmd.Process.Pid))
+}
+
+func TestSystemMonitorThresholds(t *testing.T) {
+	sm := NewSystemMonitor(3, time.Second)
+	assert.Equal(t, DefaultResourceThresholds(), sm.Thresholds())
+
+	at := time.Now()
+	check := func(cpu, mem float64) []ThresholdEvent {
+		stats := &SystemStats{Timestamp: at}
+		stats.CPU.UsagePercent = cpu
+		stats.Memory.UsagePercent = mem
+		return sm.thresholdEventsLocked(stats)
+	}
+
+	assert.Empty(t, check(50, 50))
+	events := check(95, 50)
+	require.Len(t, events, 1)
+	assert.Equal(t, ThresholdEvent{Metric: "cpu.usage_percent", Value: 95, Threshold: 90, Exceeded: true, Timestamp: at}, events[0])
+	// 持续超过阈值不重复触发
+	assert.Empty(t, check(96, 50))
+
+	events = check(40, 90)
+	require.Len(t, events, 2)
+	assert.False(t, events[0].Exceeded)
+	assert.Equal(t, "memory.usage_percent", events[1].Metric)
+	assert.True(t, events[1].Exceeded)
+
+	// 阈值为 0 时不检查
+	sm.SetThresholds(ResourceThresholds{CPUPercent: 90})
+	events = check(40, 99)
+	require.Len(t, events, 1)
+	assert.False(t, events[0].Exceeded)
+}
+
+func TestMonitorResourceAlertRules(t *testing.T) {
+	m := NewMonitor(&MonitorConfig{EnableSystemMonitor: true, MaxStats: 10, MonitorInterval: time.Second, EnableAlerting: true})
+	rules := m.GetAlertEngine().ListRules()
+	require.Len(t, rules, 2)
+	assert.Equal(t, RuleSystemCPUHigh, rules[0].Name)
+	assert.Equal(t, 90.0, rules[0].Threshold)
+	assert.Equal(t, RuleSystemMemoryHigh, rules[1].Name)
+	assert.Equal(t, 85.0, rules[1].Threshold)
+
+	m.SetResourceThresholds(ResourceThresholds{CPUPercent: 1})
+	rules = m.GetAlertEngine().ListRules()
+	assert.Equal(t, 1.0, rules[0].Threshold)
+	assert.True(t, rules[1].Disabled)
+
+	// 越过阈值时立即评估告警
+	var fired []*Alert
+	m.GetAlertEngine().AddNotifier(NotifierFunc(func(_ context.Context, a *Alert) error {
+		fired = append(fired, a)
+		return nil
+	}))
+	stats := &SystemStats{Timestamp: time.Now(), CustomMetrics: map[string]interface{}{}}
+	stats.CPU.UsagePercent = 50
+	m.GetSystemMonitor().record(stats)
+	require.Len(t, fired, 1)
+	assert.Equal(t, RuleSystemCPUHigh, fired[0].Rule)
+	assert.Equal(t, AlertStateFiring, fired[0].State)
+}
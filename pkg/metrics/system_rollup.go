@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+)
+
+// 历史统计分辨率
+const (
+	ResolutionRaw        = "raw"
+	ResolutionMinute     = "1m"
+	ResolutionFiveMinute = "5m"
+	ResolutionHour       = "1h"
+)
+
+// defaultMaxHistoryPoints 自动选择分辨率时单次返回的点数上限
+const defaultMaxHistoryPoints = 500
+
+// StatsRetention 各分辨率聚合数据的保留时长，为 0 时使用默认值；原始样本仍按 MaxStats 条保留
+type StatsRetention struct {
+	Minute     time.Duration `json:"minute" yaml:"minute" default:"6h"`
+	FiveMinute time.Duration `json:"five_minute" yaml:"five_minute" default:"48h"`
+	Hour       time.Duration `json:"hour" yaml:"hour" default:"192h"`
+}
+
+// DefaultStatsRetention 默认保留：1 分钟聚合 6 小时，5 分钟聚合 2 天，1 小时聚合 8 天
+func DefaultStatsRetention() StatsRetention {
+	return StatsRetention{Minute: 6 * time.Hour, FiveMinute: 48 * time.Hour, Hour: 8 * 24 * time.Hour}
+}
+
+// withDefaults 以默认值补齐未设置的保留时长
+func (r StatsRetention) withDefaults() StatsRetention {
+	def := DefaultStatsRetention()
+	if r.Minute <= 0 {
+		r.Minute = def.Minute
+	}
+	if r.FiveMinute <= 0 {
+		r.FiveMinute = def.FiveMinute
+	}
+	if r.Hour <= 0 {
+		r.Hour = def.Hour
+	}
+	return r
+}
+
+// AggregateValue 时间桶内指标的均值与最值
+type AggregateValue struct {
+	Avg float64 `json:"avg"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// StatsBucket 时间桶内 CPU、内存、磁盘使用率的聚合，原始分辨率下每个桶即一次采样
+type StatsBucket struct {
+	Timestamp time.Time      `json:"timestamp"` // 桶起始时间
+	Samples   int            `json:"samples"`
+	CPU       AggregateValue `json:"cpu"`
+	Memory    AggregateValue `json:"memory"`
+	Disk      AggregateValue `json:"disk"`
+}
+
+// StatsHistory 按时间范围查询的历史统计
+type StatsHistory struct {
+	Resolution  string        `json:"resolution"`
+	StepSeconds float64       `json:"step_seconds"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Points      []StatsBucket `json:"points"`
+}
+
+// aggregate 桶内单个指标的累计值
+type aggregate struct {
+	sum, min, max float64
+}
+
+func (a *aggregate) add(v float64, first bool) {
+	if first || v < a.min {
+		a.min = v
+	}
+	if first || v > a.max {
+		a.max = v
+	}
+	a.sum += v
+}
+
+func (a aggregate) value(n int) AggregateValue {
+	if n == 0 {
+		return AggregateValue{}
+	}
+	return AggregateValue{Avg: a.sum / float64(n), Min: a.min, Max: a.max}
+}
+
+// rollupBucket 聚合中的时间桶
+type rollupBucket struct {
+	start             time.Time
+	samples           int
+	cpu, memory, disk aggregate
+}
+
+func (b *rollupBucket) bucket() StatsBucket {
+	return StatsBucket{
+		Timestamp: b.start,
+		Samples:   b.samples,
+		CPU:       b.cpu.value(b.samples),
+		Memory:    b.memory.value(b.samples),
+		Disk:      b.disk.value(b.samples),
+	}
+}
+
+// rollupTier 单一分辨率的聚合序列，最后一个桶为正在累计的当前桶
+type rollupTier struct {
+	resolution string
+	step       time.Duration
+	retention  time.Duration
+	buckets    []*rollupBucket
+}
+
+// add 将采样计入所属时间桶，并清理超出保留时长的桶
+func (t *rollupTier) add(stats *SystemStats) {
+	start := stats.Timestamp.Truncate(t.step)
+	var b *rollupBucket
+	if n := len(t.buckets); n > 0 && !t.buckets[n-1].start.Before(start) {
+		// 时钟回拨时并入当前桶
+		b = t.buckets[n-1]
+	} else {
+		b = &rollupBucket{start: start}
+		t.buckets = append(t.buckets, b)
+	}
+	first := b.samples == 0
+	b.cpu.add(stats.CPU.UsagePercent, first)
+	b.memory.add(stats.Memory.UsagePercent, first)
+	b.disk.add(stats.Disk.UsagePercent, first)
+	b.samples++
+
+	cutoff := stats.Timestamp.Add(-t.retention)
+	drop := 0
+	for drop < len(t.buckets)-1 && t.buckets[drop].start.Add(t.step).Before(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		t.buckets = append(t.buckets[:0:0], t.buckets[drop:]...)
+	}
+}
+
+// points 与 [from, to] 有交集的桶
+func (t *rollupTier) points(from, to time.Time) []StatsBucket {
+	out := make([]StatsBucket, 0)
+	for _, b := range t.buckets {
+		if b.start.Add(t.step).After(from) && !b.start.After(to) {
+			out = append(out, b.bucket())
+		}
+	}
+	return out
+}
+
+// newRollupTiers 按保留时长创建各分辨率的聚合序列，由细到粗排列
+func newRollupTiers(r StatsRetention) []*rollupTier {
+	return []*rollupTier{
+		{resolution: ResolutionMinute, step: time.Minute, retention: r.Minute},
+		{resolution: ResolutionFiveMinute, step: 5 * time.Minute, retention: r.FiveMinute},
+		{resolution: ResolutionHour, step: time.Hour, retention: r.Hour},
+	}
+}
+
+// SetRetention 设置聚合数据的保留时长，已有聚合数据会被清空
+func (sm *SystemMonitor) SetRetention(r StatsRetention) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.retention = r.withDefaults()
+	sm.tiers = newRollupTiers(sm.retention)
+}
+
+// Retention 当前聚合数据的保留时长
+func (sm *SystemMonitor) Retention() StatsRetention {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.retention
+}
+
+// rollupLocked 将采样计入各分辨率的聚合，调用方需持有写锁
+func (sm *SystemMonitor) rollupLocked(stats *SystemStats) {
+	for _, t := range sm.tiers {
+		t.add(stats)
+	}
+}
+
+// GetStatsRange 查询时间范围内的历史统计。resolution 为空时自动选择：在保留时长覆盖 from 且点数不超过
+// maxPoints 的分辨率中取最细的一档，都不满足时取 1h；maxPoints <= 0 时按 500 计算
+func (sm *SystemMonitor) GetStatsRange(from, to time.Time, resolution string, maxPoints int) (*StatsHistory, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid range: from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if maxPoints <= 0 {
+		maxPoints = defaultMaxHistoryPoints
+	}
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if resolution == "" {
+		resolution = sm.pickResolutionLocked(from, to, maxPoints)
+	}
+	history := &StatsHistory{Resolution: resolution, From: from, To: to}
+	if resolution == ResolutionRaw {
+		history.StepSeconds = sm.interval.Seconds()
+		history.Points = make([]StatsBucket, 0)
+		for _, s := range sm.stats {
+			if s.Timestamp.Before(from) || s.Timestamp.After(to) {
+				continue
+			}
+			history.Points = append(history.Points, StatsBucket{
+				Timestamp: s.Timestamp,
+				Samples:   1,
+				CPU:       AggregateValue{Avg: s.CPU.UsagePercent, Min: s.CPU.UsagePercent, Max: s.CPU.UsagePercent},
+				Memory:    AggregateValue{Avg: s.Memory.UsagePercent, Min: s.Memory.UsagePercent, Max: s.Memory.UsagePercent},
+				Disk:      AggregateValue{Avg: s.Disk.UsagePercent, Min: s.Disk.UsagePercent, Max: s.Disk.UsagePercent},
+			})
+		}
+		return history, nil
+	}
+	for _, t := range sm.tiers {
+		if t.resolution == resolution {
+			history.StepSeconds = t.step.Seconds()
+			history.Points = t.points(from, to)
+			return history, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported resolution: %s", resolution)
+}
+
+// pickResolutionLocked 为时间范围选择分辨率，调用方需持有读锁
+func (sm *SystemMonitor) pickResolutionLocked(from, to time.Time, maxPoints int) string {
+	span := to.Sub(from)
+	now := time.Now()
+	// 原始样本只在内存中保留 MaxStats 条
+	if sm.interval > 0 && len(sm.stats) > 0 && !sm.stats[0].Timestamp.After(from) &&
+		span/sm.interval <= time.Duration(maxPoints) {
+		return ResolutionRaw
+	}
+	for _, t := range sm.tiers {
+		if !now.Add(-t.retention).After(from) && span/t.step <= time.Duration(maxPoints) {
+			return t.resolution
+		}
+	}
+	return ResolutionHour
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleStats(ts time.Time, cpu float64) *SystemStats {
+	stats := &SystemStats{Timestamp: ts, CustomMetrics: map[string]interface{}{}}
+	stats.CPU.UsagePercent = cpu
+	stats.Memory.UsagePercent = cpu / 2
+	return stats
+}
+
+func TestSystemMonitorRollup(t *testing.T) {
+	sm := NewSystemMonitor(10, 30*time.Second)
+	sm.SetRetention(StatsRetention{Minute: 10 * time.Minute})
+	assert.Equal(t, 10*time.Minute, sm.Retention().Minute)
+	assert.Equal(t, DefaultStatsRetention().Hour, sm.Retention().Hour)
+
+	base := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	for i := 0; i < 40; i++ {
+		sm.record(sampleStats(base.Add(time.Duration(i)*30*time.Second), float64(i)))
+	}
+
+	// 原始样本只保留最近 10 条，1 分钟聚合只保留 10 分钟
+	assert.Len(t, sm.GetStatsHistory(0), 10)
+	minute, err := sm.GetStatsRange(base, base.Add(time.Hour), ResolutionMinute, 0)
+	require.NoError(t, err)
+	require.Len(t, minute.Points, 11)
+	last := minute.Points[len(minute.Points)-1]
+	assert.Equal(t, base.Add(19*time.Minute), last.Timestamp)
+	assert.Equal(t, 2, last.Samples)
+	assert.Equal(t, AggregateValue{Avg: 38.5, Min: 38, Max: 39}, last.CPU)
+
+	five, err := sm.GetStatsRange(base, base.Add(time.Hour), ResolutionFiveMinute, 0)
+	require.NoError(t, err)
+	require.Len(t, five.Points, 4)
+	assert.Equal(t, 10, five.Points[0].Samples)
+	assert.Equal(t, 4.5, five.Points[0].CPU.Avg)
+	assert.Equal(t, 2.25, five.Points[0].Memory.Avg)
+	assert.Equal(t, 300.0, five.StepSeconds)
+
+	hour, err := sm.GetStatsRange(base, base.Add(time.Hour), ResolutionHour, 0)
+	require.NoError(t, err)
+	require.Len(t, hour.Points, 1)
+	assert.Equal(t, 40, hour.Points[0].Samples)
+	assert.Equal(t, 39.0, hour.Points[0].CPU.Max)
+
+	_, err = sm.GetStatsRange(base, base.Add(time.Hour), "15m", 0)
+	assert.Error(t, err)
+	_, err = sm.GetStatsRange(base, base, "", 0)
+	assert.Error(t, err)
+}
+
+func TestSystemMonitorPickResolution(t *testing.T) {
+	sm := NewSystemMonitor(1000, 30*time.Second)
+	now := time.Now()
+	for i := 120; i >= 0; i-- {
+		sm.record(sampleStats(now.Add(-time.Duration(i)*30*time.Second), 10))
+	}
+
+	// 原始样本覆盖的范围直接返回原始样本
+	h, err := sm.GetStatsRange(now.Add(-30*time.Minute), now, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, ResolutionRaw, h.Resolution)
+	assert.Len(t, h.Points, 61)
+
+	// 点数超过上限时降低分辨率
+	h, err = sm.GetStatsRange(now.Add(-30*time.Minute), now, "", 40)
+	require.NoError(t, err)
+	assert.Equal(t, ResolutionMinute, h.Resolution)
+
+	// 超出原始样本与 1 分钟聚合保留范围
+	h, err = sm.GetStatsRange(now.Add(-24*time.Hour), now, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, ResolutionFiveMinute, h.Resolution)
+
+	h, err = sm.GetStatsRange(now.Add(-7*24*time.Hour), now, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, ResolutionHour, h.Resolution)
+	assert.NotEmpty(t, h.Points)
+}
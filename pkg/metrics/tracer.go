@@ -1,8 +1,10 @@
 package metrics
 
 import (
+	"HibiscusIM/pkg/redact"
 	"context"
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
 	"sync"
 	"time"
 )
@@ -13,6 +15,7 @@ type Span struct {
 	TraceID    string                 `json:"trace_id"`
 	ParentID   string                 `json:"parent_id"`
 	Name       string                 `json:"name"`
+	Kind       SpanKind               `json:"kind"`
 	StartTime  time.Time              `json:"start_time"`
 	EndTime    time.Time              `json:"end_time"`
 	Duration   time.Duration          `json:"duration"`
@@ -41,11 +44,22 @@ const (
 	SpanStatusError
 )
 
+// SpanKind 跨度类型，与 OpenTelemetry 的 SpanKind 取值一致
+type SpanKind int
+
+const (
+	SpanKindUnspecified SpanKind = iota
+	SpanKindInternal
+	SpanKindServer
+	SpanKindClient
+)
+
 // Tracer 链路追踪器
 type Tracer struct {
-	spans    map[string]*Span
-	mu       sync.RWMutex
-	maxSpans int
+	spans     map[string]*Span
+	mu        sync.RWMutex
+	maxSpans  int
+	processor *batchSpanProcessor
 }
 
 // NewTracer 创建新的追踪器
@@ -80,12 +94,14 @@ func (t *Tracer) StartSpan(ctx context.Context, name string, opts ...SpanOption)
 		span.TraceID = generateTraceID()
 	}
 
-	// 获取父跨度ID
+	// 获取父跨度ID，本地父跨度优先于上游传入的远程父跨度
 	if parentSpan := getSpanFromContext(ctx); parentSpan != nil {
 		span.ParentID = parentSpan.ID
 		parentSpan.mu.Lock()
 		parentSpan.Children = append(parentSpan.Children, span)
 		parentSpan.mu.Unlock()
+	} else if remote, ok := remoteParentFromContext(ctx); ok {
+		span.ParentID = remote.spanID
 	}
 
 	// 存储跨度
@@ -109,17 +125,23 @@ func (t *Tracer) EndSpan(span *Span, err error) {
 	}
 
 	span.mu.Lock()
-	defer span.mu.Unlock()
-
 	span.EndTime = time.Now()
 	span.Duration = span.EndTime.Sub(span.StartTime)
 
 	if err != nil {
 		span.Status = SpanStatusError
-		span.Error = err
+		span.Error = redactError(redact.Default(), err)
 	} else {
 		span.Status = SpanStatusOK
 	}
+	span.mu.Unlock()
+
+	t.mu.RLock()
+	processor := t.processor
+	t.mu.RUnlock()
+	if processor != nil {
+		processor.onEnd(span)
+	}
 }
 
 // AddEvent 添加事件到跨度
@@ -130,7 +152,7 @@ func (s *Span) AddEvent(name string, attrs map[string]interface{}) {
 	event := Event{
 		Time:       time.Now(),
 		Name:       name,
-		Attributes: attrs,
+		Attributes: redact.Default().Map(attrs),
 	}
 	s.Events = append(s.Events, event)
 }
@@ -139,14 +161,53 @@ func (s *Span) AddEvent(name string, attrs map[string]interface{}) {
 func (s *Span) SetTag(key, value string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.Tags[key] = value
+	s.Tags[key] = redact.Default().Tag(key, value)
 }
 
 // SetAttribute 设置属性
 func (s *Span) SetAttribute(key string, value interface{}) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.Attributes[key] = value
+	s.Attributes[key] = redact.Default().Value(key, value)
+}
+
+// redacted 返回脱敏后的副本（含子跨度），供接口输出
+func (s *Span) redacted(r *redact.Redactor) *Span {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := &Span{
+		ID:         s.ID,
+		TraceID:    s.TraceID,
+		ParentID:   s.ParentID,
+		Name:       s.Name,
+		Kind:       s.Kind,
+		StartTime:  s.StartTime,
+		EndTime:    s.EndTime,
+		Duration:   s.Duration,
+		Tags:       r.StringMap(s.Tags),
+		Attributes: r.Map(s.Attributes),
+		Events:     make([]Event, len(s.Events)),
+		Status:     s.Status,
+		Error:      redactError(r, s.Error),
+		Children:   make([]*Span, len(s.Children)),
+	}
+	for i, ev := range s.Events {
+		out.Events[i] = Event{Time: ev.Time, Name: ev.Name, Attributes: r.Map(ev.Attributes)}
+	}
+	for i, child := range s.Children {
+		out.Children[i] = child.redacted(r)
+	}
+	return out
+}
+
+// redactSpans 批量脱敏跨度
+func redactSpans(spans []*Span) []*Span {
+	r := redact.Default()
+	out := make([]*Span, len(spans))
+	for i, s := range spans {
+		out[i] = s.redacted(r)
+	}
+	return out
 }
 
 // GetSpans 获取所有跨度
@@ -218,11 +279,19 @@ func WithParent(parent *Span) SpanOption {
 	}
 }
 
+// WithSpanKind 设置跨度类型
+func WithSpanKind(kind SpanKind) SpanOption {
+	return func(s *Span) {
+		s.Kind = kind
+	}
+}
+
 // WithTags 设置标签
 func WithTags(tags map[string]string) SpanOption {
 	return func(s *Span) {
+		r := redact.Default()
 		for k, v := range tags {
-			s.Tags[k] = v
+			s.Tags[k] = r.Tag(k, v)
 		}
 	}
 }
@@ -230,8 +299,9 @@ func WithTags(tags map[string]string) SpanOption {
 // WithAttributes 设置属性
 func WithAttributes(attrs map[string]interface{}) SpanOption {
 	return func(s *Span) {
+		r := redact.Default()
 		for k, v := range attrs {
-			s.Attributes[k] = v
+			s.Attributes[k] = r.Value(k, v)
 		}
 	}
 }
@@ -252,15 +322,24 @@ func getTraceIDFromContext(ctx context.Context) string {
 	if span := getSpanFromContext(ctx); span != nil {
 		return span.TraceID
 	}
+	if remote, ok := remoteParentFromContext(ctx); ok {
+		return remote.traceID
+	}
 	return ""
 }
 
-// generateSpanID 生成跨度ID
+// generateSpanID 生成跨度ID，8字节十六进制，符合 W3C Trace Context
 func generateSpanID() string {
-	return fmt.Sprintf("span_%d", time.Now().UnixNano())
+	return randomHex(8)
 }
 
-// generateTraceID 生成追踪ID
+// generateTraceID 生成追踪ID，16字节十六进制，符合 W3C Trace Context
 func generateTraceID() string {
-	return fmt.Sprintf("trace_%d", time.Now().UnixNano())
+	return randomHex(16)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
 }
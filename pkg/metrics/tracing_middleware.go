@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TracingConfig 请求链路追踪配置
+type TracingConfig struct {
+	// UserID 请求处理结束后返回当前用户 ID 作为 user_id 标签，返回空串时不设置；
+	// 应只读取处理过程中已加载的用户，避免为追踪额外查询
+	UserID func(c *gin.Context) string
+}
+
+// TracingMiddleware 为每个请求创建服务端跨度并写入请求上下文，沿用上游传入的 W3C traceparent。
+// 跨度以 "方法 路由模板" 命名，带 method、route、path、status、user_id 标签，响应 5xx 时以错误状态结束。
+// 处理器以 *gin.Context 作为 context.Context 向下传递时，需开启 gin.Engine.ContextWithFallback 才能取到跨度
+func TracingMiddleware(monitor *Monitor, cfg TracingConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if monitor == nil {
+			c.Next()
+			return
+		}
+		span := startRequestSpan(monitor, c)
+		c.Next()
+		endRequestSpan(monitor, c, span, cfg)
+	}
+}
+
+// startRequestSpan 创建请求跨度并替换请求上下文
+func startRequestSpan(monitor *Monitor, c *gin.Context) *Span {
+	route := c.FullPath()
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	ctx := ExtractHTTP(c.Request.Context(), c.Request.Header)
+	ctx, span := monitor.StartSpan(ctx, c.Request.Method+" "+route,
+		WithSpanKind(SpanKindServer),
+		WithTags(map[string]string{
+			"method": c.Request.Method,
+			"route":  route,
+			"path":   c.Request.URL.Path,
+			"ip":     c.ClientIP(),
+		}),
+	)
+	c.Request = c.Request.WithContext(ctx)
+	return span
+}
+
+// endRequestSpan 按响应补充标签并结束请求跨度
+func endRequestSpan(monitor *Monitor, c *gin.Context, span *Span, cfg TracingConfig) {
+	if span == nil {
+		return
+	}
+	status := c.Writer.Status()
+	span.SetTag("status", strconv.Itoa(status))
+	if cfg.UserID != nil {
+		if uid := cfg.UserID(c); uid != "" {
+			span.SetTag("user_id", uid)
+		}
+	}
+	monitor.EndSpan(span, requestSpanError(c, status))
+}
+
+// requestSpanError 响应 5xx 时的跨度错误，优先使用处理器通过 c.Error 记录的错误
+func requestSpanError(c *gin.Context, status int) error {
+	if status < http.StatusInternalServerError {
+		return nil
+	}
+	if err := c.Errors.Last(); err != nil {
+		return err.Err
+	}
+	return fmt.Errorf("HTTP %d", status)
+}
+
+// SpanFromContext 返回上下文中的当前跨度，处理器可借此添加标签与事件，不存在时返回 nil
+func SpanFromContext(ctx context.Context) *Span {
+	return getSpanFromContext(ctx)
+}
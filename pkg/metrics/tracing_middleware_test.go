@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMonitor(&MonitorConfig{EnableTracing: true, MaxSpans: 100})
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(TracingMiddleware(m, TracingConfig{UserID: func(c *gin.Context) string { return c.GetString("uid") }}))
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Set("uid", "42")
+		// 以 *gin.Context 作为上下文创建的子跨度挂在请求跨度下
+		_, child := m.StartSpan(c, "load user")
+		m.EndSpan(child, nil)
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		_ = c.Error(errors.New("db unavailable"))
+		c.Status(http.StatusInternalServerError)
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	do := func(path string, header http.Header) *Span {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		for _, s := range m.GetTracer().GetSpans() {
+			if s.Kind == SpanKindServer && s.Tags["path"] == path {
+				return s
+			}
+		}
+		t.Fatalf("no server span for %s", path)
+		return nil
+	}
+
+	span := do("/users/7", nil)
+	assert.Equal(t, "GET /users/:id", span.Name)
+	assert.Equal(t, map[string]string{
+		"method":  "GET",
+		"route":   "/users/:id",
+		"path":    "/users/7",
+		"ip":      "192.0.2.1",
+		"status":  "200",
+		"user_id": "42",
+	}, span.Tags)
+	assert.Equal(t, SpanStatusOK, span.Status)
+	spans := m.GetTraceSpans(span.TraceID)
+	require.Len(t, spans, 2)
+	for _, s := range spans {
+		if s.ID != span.ID {
+			assert.Equal(t, "load user", s.Name)
+			assert.Equal(t, span.ID, s.ParentID)
+		}
+	}
+
+	// 5xx 以错误状态结束，优先使用 c.Error 记录的错误
+	span = do("/fail", nil)
+	assert.Equal(t, SpanStatusError, span.Status)
+	assert.EqualError(t, span.Error, "db unavailable")
+	assert.NotContains(t, span.Tags, "user_id")
+
+	// 4xx 不视为服务端错误
+	span = do("/missing", nil)
+	assert.Equal(t, SpanStatusOK, span.Status)
+	assert.Equal(t, "404", span.Tags["status"])
+
+	// 未匹配路由使用统一名称，沿用上游 traceparent
+	span = do("/nope", http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}})
+	assert.Equal(t, "GET "+UnmatchedRoute, span.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", span.ParentID)
+}
+
+func TestSpanFromContext(t *testing.T) {
+	m := NewMonitor(&MonitorConfig{EnableTracing: true, MaxSpans: 10})
+	assert.Nil(t, SpanFromContext(context.Background()))
+	ctx, span := m.StartSpan(context.Background(), "op")
+	assert.Same(t, span, SpanFromContext(ctx))
+}
@@ -0,0 +1,286 @@
+package middleware
+
+import (
+	"HibiscusIM/pkg/redact"
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// auditOmittedBody 文件等非文本或超过捕获上限的请求体与响应体的占位
+const auditOmittedBody = "[omitted]"
+
+// auditCaptureLimit 请求体与响应体的捕获上限，需完整解析后才能按字段脱敏，超过上限时不记录内容
+const auditCaptureLimit = 1 << 20
+
+// AuditLogConfig 审计日志配置
+type AuditLogConfig struct {
+	// 队列长度，队列满时丢弃日志，默认 1024
+	BufferSize int
+	// 单次批量写入条数，默认 100
+	BatchSize int
+	// 最长攒批时间，默认 1 秒
+	FlushInterval time.Duration
+	// 请求体与响应体脱敏后的最大记录长度，超出部分截断，默认 8KB
+	BodyLimit int
+	// 额外按字段名整体脱敏的字段，password、token 等默认字段始终脱敏
+	RedactFields []string
+	// User 返回当前用户ID与用户名，匿名请求返回 nil；默认读取上下文中的 user_id 与 username
+	User func(c *gin.Context) (userID *int64, username string)
+}
+
+// AuditLogger 审计日志记录器，只记录通过 Handler 显式开启审计的路由，
+// 保存脱敏后的请求体与响应体，由后台协程批量写入 audit_logs 表
+type AuditLogger struct {
+	cfg      AuditLogConfig
+	db       *gorm.DB
+	redactor *redact.Redactor
+	queue    chan AuditLog
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	// 队列满丢弃的日志数
+	dropped atomic.Int64
+}
+
+// NewAuditLogger 创建审计日志记录器并启动后台写入
+func NewAuditLogger(db *gorm.DB, cfg AuditLogConfig) *AuditLogger {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1024
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.BodyLimit <= 0 {
+		cfg.BodyLimit = 8 << 10
+	}
+	if cfg.User == nil {
+		cfg.User = contextUser
+	}
+	// 审计日志不受全局脱敏开关影响，凭证字段始终脱敏
+	rc := redact.DefaultConfig()
+	rc.Fields = append(append([]string{}, redact.DefaultFields...), cfg.RedactFields...)
+	redactor, err := redact.New(rc)
+	if err != nil {
+		log.Printf("audit log redactor: %v, using defaults", err)
+		redactor, _ = redact.New(redact.DefaultConfig())
+	}
+	l := &AuditLogger{
+		cfg:      cfg,
+		db:       db,
+		redactor: redactor,
+		queue:    make(chan AuditLog, cfg.BufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// Handler 返回开启审计的路由中间件，action 为审计动作名称，例如 user.update；
+// l 为 nil 时直接放行，便于按配置关闭审计
+func (l *AuditLogger) Handler(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		reqBuf := &auditBuffer{limit: auditCaptureLimit}
+		captureRequest := auditableBody(c.ContentType())
+		if captureRequest && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			c.Request.Body = &auditBody{ReadCloser: c.Request.Body, buf: reqBuf}
+		}
+		respBuf := &auditBuffer{limit: auditCaptureLimit}
+		w := &auditWriter{ResponseWriter: c.Writer, buf: respBuf}
+		c.Writer = w
+
+		c.Next()
+
+		userID, username := l.cfg.User(c)
+		entry := AuditLog{
+			UserID:     userID,
+			Username:   username,
+			Action:     action,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Route:      c.FullPath(),
+			Query:      l.redactor.String(c.Request.URL.RawQuery),
+			Status:     c.Writer.Status(),
+			IPAddress:  c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			DurationMs: time.Since(start).Milliseconds(),
+			CreatedAt:  start,
+		}
+		entry.RequestBody, entry.RequestTruncated = l.body(reqBuf, captureRequest)
+		entry.ResponseBody, entry.ResponseTruncated = l.body(respBuf, auditableBody(w.Header().Get("Content-Type")))
+		select {
+		case l.queue <- entry:
+		default:
+			if n := l.dropped.Add(1); n == 1 || n%1000 == 0 {
+				log.Printf("audit log queue full, %d logs dropped", n)
+			}
+		}
+	}
+}
+
+// body 脱敏后的请求体或响应体，先脱敏再截断到 BodyLimit，避免截断后无法解析导致凭证字段漏脱敏；
+// 文件等二进制内容或超过捕获上限时只记录占位
+func (l *AuditLogger) body(buf *auditBuffer, capture bool) (string, bool) {
+	if !capture || buf.truncated {
+		if buf.buf.Len() > 0 || buf.truncated {
+			return auditOmittedBody, buf.truncated
+		}
+		return "", false
+	}
+	body := l.redactor.JSON(buf.buf.String())
+	if len(body) <= l.cfg.BodyLimit {
+		return body, false
+	}
+	return strings.ToValidUTF8(body[:l.cfg.BodyLimit], ""), true
+}
+
+// auditableBody 只记录文本类请求体与响应体
+func auditableBody(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	if ct == "" {
+		return true
+	}
+	for _, prefix := range []string{"application/json", "application/x-www-form-urlencoded", "text/", "application/xml"} {
+		if strings.HasPrefix(ct, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dropped 队列满丢弃的日志数
+func (l *AuditLogger) Dropped() int64 {
+	return l.dropped.Load()
+}
+
+// Close 停止后台写入，提交已入队的日志
+func (l *AuditLogger) Close() {
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+	})
+}
+
+// run 攒批写入，达到批量大小或等待超时后提交
+func (l *AuditLogger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]AuditLog, 0, l.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := l.db.CreateInBatches(batch, l.cfg.BatchSize).Error; err != nil {
+			log.Printf("write %d audit logs failed: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case entry := <-l.queue:
+			if batch = append(batch, entry); len(batch) >= l.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-l.stop:
+			for {
+				select {
+				case entry := <-l.queue:
+					batch = append(batch, entry)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// auditBuffer 只保留前 limit 字节
+type auditBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *auditBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if len(p) > room {
+			b.buf.Write(p[:room])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// auditBody 包装请求体，处理器读取时复制内容
+type auditBody struct {
+	io.ReadCloser
+	buf *auditBuffer
+}
+
+func (b *auditBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.buf.Write(p[:n])
+	return n, err
+}
+
+// auditWriter 包装响应，写出时复制内容
+type auditWriter struct {
+	gin.ResponseWriter
+	buf *auditBuffer
+}
+
+func (w *auditWriter) Write(p []byte) (int, error) {
+	_, _ = w.buf.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	_, _ = w.buf.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// AuditLog 合规审计日志，记录开启审计的接口的请求与响应，写入后不可修改
+type AuditLog struct {
+	ID                int64     `gorm:"primaryKey;autoIncrement;not null" json:"id"`
+	UserID            *int64    `gorm:"index" json:"user_id"`                   // 操作的用户 ID，匿名请求为空
+	Username          string    `gorm:"size:128" json:"username"`               // 操作的用户名，匿名请求为空
+	Action            string    `gorm:"size:64;index" json:"action"`            // 审计动作名称
+	Method            string    `gorm:"size:16" json:"method"`                  // HTTP 请求方法
+	Path              string    `json:"path"`                                   // 请求路径
+	Route             string    `gorm:"size:255" json:"route"`                  // 路由模板
+	Query             string    `json:"query"`                                  // 脱敏后的查询参数
+	Status            int       `json:"status"`                                 // HTTP 响应状态码
+	IPAddress         string    `gorm:"size:64" json:"ip_address"`              // 用户 IP 地址
+	UserAgent         string    `json:"user_agent"`                             // 用户的浏览器信息
+	RequestBody       string    `gorm:"type:text" json:"request_body"`          // 脱敏后的请求体
+	RequestTruncated  bool      `json:"request_truncated"`                      // 请求体是否被截断
+	ResponseBody      string    `gorm:"type:text" json:"response_body"`         // 脱敏后的响应体
+	ResponseTruncated bool      `json:"response_truncated"`                     // 响应体是否被截断
+	DurationMs        int64     `json:"duration_ms"`                            // 处理耗时（毫秒）
+	CreatedAt         time.Time `gorm:"autoCreateTime;index" json:"created_at"` // 请求时间
+}
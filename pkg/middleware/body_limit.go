@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimitConfig 请求体大小限制配置
+type BodyLimitConfig struct {
+	// 默认上限（字节），<= 0 表示不限制
+	Default int64
+	// 按路径前缀覆盖上限（如上传、导入路由组），多个前缀匹配时取最长的前缀，<= 0 表示不限制
+	Groups map[string]int64
+}
+
+// limitFor 请求路径适用的上限
+func (cfg BodyLimitConfig) limitFor(path string) int64 {
+	limit, matched := cfg.Default, ""
+	for prefix, l := range cfg.Groups {
+		if len(prefix) > len(matched) && strings.HasPrefix(path, prefix) {
+			limit, matched = l, prefix
+		}
+	}
+	return limit
+}
+
+// BodyLimit 限制请求体大小：Content-Length 超过上限时直接返回 413，未声明长度的请求读取超过上限时返回错误，
+// 避免大请求体耗尽内存
+func BodyLimit(cfg BodyLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := cfg.limitFor(c.Request.URL.Path)
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body too large, limit is %d bytes", limit)})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
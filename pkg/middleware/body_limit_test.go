@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimitFor(t *testing.T) {
+	cfg := BodyLimitConfig{
+		Default: 10,
+		Groups: map[string]int64{
+			"/api/upload":       100,
+			"/api/upload/video": 0,
+		},
+	}
+	assert.Equal(t, int64(10), cfg.limitFor("/api/users"))
+	assert.Equal(t, int64(100), cfg.limitFor("/api/upload/avatar"))
+	assert.Equal(t, int64(0), cfg.limitFor("/api/upload/video/1"), "longest prefix wins")
+}
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(BodyLimit(BodyLimitConfig{Default: 10, Groups: map[string]int64{"/upload": 100, "/free": 0}}))
+	echo := func(c *gin.Context) {
+		b, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		c.String(http.StatusOK, "%d", len(b))
+	}
+	r.POST("/api", echo)
+	r.POST("/upload", echo)
+	r.POST("/free", echo)
+
+	post := func(path string, body string, chunked bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if chunked {
+			// 未声明长度的请求
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "10", post("/api", strings.Repeat("a", 10), false).Body.String())
+	// 声明的长度超过上限时不进入处理器
+	w := post("/api", strings.Repeat("a", 11), false)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "limit is 10 bytes")
+	// 未声明长度时读取超过上限报错
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/api", strings.Repeat("a", 11), true).Code)
+
+	assert.Equal(t, "50", post("/upload", strings.Repeat("a", 50), false).Body.String())
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post("/upload", strings.Repeat("a", 101), true).Code)
+	assert.Equal(t, "1000", post("/free", strings.Repeat("a", 1000), false).Body.String())
+}
@@ -0,0 +1,365 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 熔断器状态
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+var (
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "circuit_breaker_state",
+		Help: "Circuit breaker state: 0 closed, 1 half-open, 2 open",
+	}, []string{"name"})
+	circuitBreakerRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "circuit_breaker_requests_total",
+		Help: "Calls through circuit breakers by result (success, failure, rejected)",
+	}, []string{"name", "result"})
+	circuitBreakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "circuit_breaker_transitions_total",
+		Help: "Circuit breaker state transitions",
+	}, []string{"name", "to"})
+)
+
+// CircuitBreakerConfig 熔断配置
+type CircuitBreakerConfig struct {
+	// 统计失败率的滑动窗口，默认 10 秒
+	Window time.Duration
+	// 窗口内请求数达到该值后才判断失败率，默认 20
+	MinRequests int
+	// 窗口内失败率达到该值时熔断，取值 (0, 1]，默认 0.5
+	FailureRate float64
+	// 熔断后等待多久进入半开状态试探，默认 30 秒
+	OpenTimeout time.Duration
+	// 半开状态允许的试探请求数，全部成功后恢复，任一失败重新熔断，默认 3
+	HalfOpenRequests int
+}
+
+func (cfg *CircuitBreakerConfig) applyDefaults() {
+	if cfg.Window <= 0 {
+		cfg.Window = 10 * time.Second
+	}
+	if cfg.MinRequests <= 0 {
+		cfg.MinRequests = 20
+	}
+	if cfg.FailureRate <= 0 || cfg.FailureRate > 1 {
+		cfg.FailureRate = 0.5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 30 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 3
+	}
+}
+
+// circuitBuckets 滑动窗口的分桶数
+const circuitBuckets = 10
+
+type circuitBucket struct {
+	start     time.Time
+	successes int64
+	failures  int64
+}
+
+// CircuitBreakerStatus 熔断器当前状态
+type CircuitBreakerStatus struct {
+	Name        string     `json:"name"`
+	State       string     `json:"state"`
+	Requests    int64      `json:"requests"`     // 窗口内的请求数
+	Failures    int64      `json:"failures"`     // 窗口内的失败数
+	FailureRate float64    `json:"failure_rate"` // 窗口内的失败率
+	Rejected    int64      `json:"rejected"`     // 熔断期间累计拒绝的请求数
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
+	RetryAt     *time.Time `json:"retry_at,omitempty"` // 熔断时进入半开试探的时间
+}
+
+// CircuitBreaker 按失败率熔断的断路器：关闭状态下统计滑动窗口内的失败率，超过阈值后打开并直接拒绝调用，
+// 等待 OpenTimeout 后进入半开状态放行少量试探请求，试探全部成功后关闭，任一失败重新打开
+type CircuitBreaker struct {
+	name string
+	cfg  CircuitBreakerConfig
+
+	mu       sync.Mutex
+	state    string
+	buckets  [circuitBuckets]circuitBucket
+	openedAt time.Time
+	// 半开状态已放行与已成功的试探请求数
+	probes    int
+	succeeded int
+	rejected  int64
+	now       func() time.Time
+}
+
+// NewCircuitBreaker 创建熔断器，name 用于指标与管理接口
+func NewCircuitBreaker(name string, cfg CircuitBreakerConfig) *CircuitBreaker {
+	cfg.applyDefaults()
+	b := &CircuitBreaker{name: name, cfg: cfg, state: CircuitClosed, now: time.Now}
+	circuitBreakerState.WithLabelValues(name).Set(0)
+	return b
+}
+
+// Name 熔断器名称
+func (b *CircuitBreaker) Name() string { return b.name }
+
+// Allow 申请一次调用，熔断时返回 ErrCircuitOpen；放行时调用方需以调用结果执行 done
+func (b *CircuitBreaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.transition(CircuitHalfOpen)
+	}
+	switch b.state {
+	case CircuitOpen:
+		b.reject()
+		return nil, ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probes >= b.cfg.HalfOpenRequests {
+			b.reject()
+			return nil, ErrCircuitOpen
+		}
+		b.probes++
+	}
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() { b.record(success) })
+	}, nil
+}
+
+// Execute 通过熔断器执行 fn，fn 返回错误视为失败
+func (b *CircuitBreaker) Execute(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	done(err == nil)
+	return err
+}
+
+// CallWithBreaker 通过熔断器执行带返回值的 fn
+func CallWithBreaker[T any](b *CircuitBreaker, fn func() (T, error)) (T, error) {
+	var zero T
+	done, err := b.Allow()
+	if err != nil {
+		return zero, err
+	}
+	v, err := fn()
+	done(err == nil)
+	return v, err
+}
+
+// Reset 手动恢复为关闭状态并清空统计
+func (b *CircuitBreaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buckets = [circuitBuckets]circuitBucket{}
+	if b.state != CircuitClosed {
+		b.transition(CircuitClosed)
+	}
+}
+
+// Status 熔断器当前状态
+func (b *CircuitBreaker) Status() CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	successes, failures := b.counts(now)
+	st := CircuitBreakerStatus{
+		Name:     b.name,
+		State:    b.state,
+		Requests: successes + failures,
+		Failures: failures,
+		Rejected: b.rejected,
+	}
+	if st.Requests > 0 {
+		st.FailureRate = float64(failures) / float64(st.Requests)
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		retryAt := openedAt.Add(b.cfg.OpenTimeout)
+		st.OpenedAt, st.RetryAt = &openedAt, &retryAt
+	}
+	return st
+}
+
+// retryAfter 距离进入半开试探的剩余时间
+func (b *CircuitBreaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return max(b.openedAt.Add(b.cfg.OpenTimeout).Sub(b.now()), 0)
+}
+
+// record 记录调用结果并按需切换状态，调用方未持有锁
+func (b *CircuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	circuitBreakerRequests.WithLabelValues(b.name, result).Inc()
+
+	switch b.state {
+	case CircuitHalfOpen:
+		if !success {
+			b.open()
+			return
+		}
+		if b.succeeded++; b.succeeded >= b.cfg.HalfOpenRequests {
+			b.buckets = [circuitBuckets]circuitBucket{}
+			b.transition(CircuitClosed)
+		}
+	case CircuitClosed:
+		now := b.now()
+		bucket := b.bucket(now)
+		if success {
+			bucket.successes++
+			return
+		}
+		bucket.failures++
+		successes, failures := b.counts(now)
+		total := successes + failures
+		if total >= int64(b.cfg.MinRequests) && float64(failures)/float64(total) >= b.cfg.FailureRate {
+			b.open()
+		}
+	}
+	// 打开状态下完成的调用是熔断前放行的，不影响状态
+}
+
+// bucket 当前时间所在的桶，过期的桶先清空
+func (b *CircuitBreaker) bucket(now time.Time) *circuitBucket {
+	width := b.cfg.Window / circuitBuckets
+	start := now.Truncate(width)
+	bk := &b.buckets[int(start.UnixNano()/int64(width))%circuitBuckets]
+	if !bk.start.Equal(start) {
+		*bk = circuitBucket{start: start}
+	}
+	return bk
+}
+
+// counts 滑动窗口内的成功与失败数
+func (b *CircuitBreaker) counts(now time.Time) (successes, failures int64) {
+	for _, bk := range b.buckets {
+		if !bk.start.IsZero() && now.Sub(bk.start) < b.cfg.Window {
+			successes += bk.successes
+			failures += bk.failures
+		}
+	}
+	return successes, failures
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.now()
+	b.transition(CircuitOpen)
+}
+
+func (b *CircuitBreaker) reject() {
+	b.rejected++
+	circuitBreakerRequests.WithLabelValues(b.name, "rejected").Inc()
+}
+
+// transition 切换状态并更新指标，调用方持有锁
+func (b *CircuitBreaker) transition(to string) {
+	b.state = to
+	b.probes, b.succeeded = 0, 0
+	circuitBreakerTransitions.WithLabelValues(b.name, to).Inc()
+	var v float64
+	switch to {
+	case CircuitHalfOpen:
+		v = 1
+	case CircuitOpen:
+		v = 2
+	}
+	circuitBreakerState.WithLabelValues(b.name).Set(v)
+}
+
+// CircuitBreakerRegistry 按名称管理熔断器，名称通常为路由或下游依赖
+type CircuitBreakerRegistry struct {
+	cfg      CircuitBreakerConfig
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewCircuitBreakerRegistry 创建熔断器注册表，cfg 为新建熔断器的配置
+func NewCircuitBreakerRegistry(cfg CircuitBreakerConfig) *CircuitBreakerRegistry {
+	cfg.applyDefaults()
+	return &CircuitBreakerRegistry{cfg: cfg, breakers: make(map[string]*CircuitBreaker)}
+}
+
+// Get 返回名为 name 的熔断器，不存在时创建
+func (r *CircuitBreakerRegistry) Get(name string) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.breakers[name]
+	if !ok {
+		b = NewCircuitBreaker(name, r.cfg)
+		r.breakers[name] = b
+	}
+	return b
+}
+
+// List 全部熔断器的状态，按名称排序
+func (r *CircuitBreakerRegistry) List() []CircuitBreakerStatus {
+	r.mu.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+	list := make([]CircuitBreakerStatus, len(breakers))
+	for i, b := range breakers {
+		list[i] = b.Status()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Reset 恢复指定熔断器，不存在时返回 false
+func (r *CircuitBreakerRegistry) Reset(name string) bool {
+	r.mu.Lock()
+	b, ok := r.breakers[name]
+	r.mu.Unlock()
+	if ok {
+		b.Reset()
+	}
+	return ok
+}
+
+// Handler 返回熔断中间件，响应 5xx 视为失败；熔断时返回 503 并带 Retry-After。
+// name 为空时按路由模板分别熔断，否则同名的路由共用一个熔断器（如同一下游依赖）
+func (r *CircuitBreakerRegistry) Handler(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := name
+		if key == "" {
+			key = c.FullPath()
+		}
+		b := r.Get(key)
+		done, err := b.Allow()
+		if err != nil {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(b.retryAfter().Seconds()))))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "service temporarily unavailable", "breaker": key})
+			return
+		}
+		c.Next()
+		done(c.Writer.Status() < http.StatusInternalServerError)
+	}
+}
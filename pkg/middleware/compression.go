@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompressionEncoder 响应压缩编码，Name 为 Content-Encoding 取值（如 gzip、br）
+type CompressionEncoder struct {
+	Name string
+	// New 创建写入 w 的压缩流，Close 时写出剩余数据
+	New func(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// GzipEncoder 标准库 gzip 编码，压缩流按级别复用
+var GzipEncoder = CompressionEncoder{Name: "gzip", New: newPooledGzipWriter}
+
+// DefaultCompressionTypes 默认压缩的响应类型，"text/*" 形式按主类型匹配
+var DefaultCompressionTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/css",
+	"text/html",
+	"text/javascript",
+	"text/plain",
+	"text/xml",
+}
+
+// CompressionConfig 响应压缩配置
+type CompressionConfig struct {
+	// 按优先级排列的编码，客户端权重相同时取靠前的编码，默认只有 gzip；
+	// brotli 等编码可由调用方基于相应的库提供
+	Encoders []CompressionEncoder
+	// 压缩级别，0 使用各编码的默认级别
+	Level int
+	// 小于该字节数的响应不压缩，默认 1024
+	MinSize int
+	// 允许压缩的响应类型，默认 DefaultCompressionTypes；事件流等需要逐条推送的类型不应加入
+	ContentTypes []string
+	// 不压缩的路径前缀
+	SkipPaths []string
+}
+
+// Compression 按 Accept-Encoding 协商压缩响应。响应先缓冲到 MinSize 再判断类型与大小，
+// 已设置 Content-Encoding 的响应、WebSocket 升级与 HEAD 请求原样透传
+func Compression(cfg CompressionConfig) gin.HandlerFunc {
+	if len(cfg.Encoders) == 0 {
+		cfg.Encoders = []CompressionEncoder{GzipEncoder}
+	}
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = 1024
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressionTypes
+	}
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" || compressionSkipped(cfg.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		enc, ok := negotiateEncoding(c.GetHeader("Accept-Encoding"), cfg.Encoders)
+		if !ok {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, cfg: &cfg, enc: enc}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+func compressionSkipped(prefixes []string, path string) bool {
+	for _, p := range prefixes {
+		if p != "" && strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding 按 Accept-Encoding 的 q 值选择编码，q=0 表示拒绝，"*" 匹配未列出的编码
+func negotiateEncoding(header string, encoders []CompressionEncoder) (CompressionEncoder, bool) {
+	if header == "" {
+		return CompressionEncoder{}, false
+	}
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		weights[name] = q
+	}
+	var best CompressionEncoder
+	bestQ := 0.0
+	for _, enc := range encoders {
+		q, ok := weights[enc.Name]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// compressWriter 缓冲响应开头以决定是否压缩，决定后直接写入压缩流或原始连接
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg *CompressionConfig
+	enc CompressionEncoder
+
+	buf     bytes.Buffer
+	decided bool
+	zw      io.WriteCloser
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf.Write(b)
+		if w.buf.Len() < w.cfg.MinSize {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.zw != nil {
+		return w.zw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 推送已缓冲的数据，未达到 MinSize 时按类型决定是否压缩
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if f, ok := w.zw.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return w.ResponseWriter.Hijack()
+}
+
+// decide 根据响应头与已缓冲的内容决定是否压缩，并写出缓冲
+func (w *compressWriter) decide() error {
+	w.decided = true
+	if w.shouldCompress() {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.enc.Name)
+		h.Add("Vary", "Accept-Encoding")
+		zw, err := w.enc.New(w.ResponseWriter, w.cfg.Level)
+		if err == nil {
+			w.zw = zw
+			_, err = w.zw.Write(w.buf.Bytes())
+			w.buf.Reset()
+			return err
+		}
+		h.Del("Content-Encoding")
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || w.buf.Len() == 0 {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(w.buf.Bytes())
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range w.cfg.ContentTypes {
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok && strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// finish 请求处理结束：小于 MinSize 的响应原样写出，压缩流写出剩余数据
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.decided = true
+		if w.buf.Len() > 0 {
+			_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+			w.buf.Reset()
+		}
+	}
+	if w.zw != nil {
+		_ = w.zw.Close()
+	}
+}
+
+// gzipPools 按压缩级别复用 gzip.Writer
+var gzipPools sync.Map
+
+type pooledGzipWriter struct {
+	*gzip.Writer
+	pool *sync.Pool
+}
+
+func (w *pooledGzipWriter) Close() error {
+	err := w.Writer.Close()
+	w.pool.Put(w.Writer)
+	return err
+}
+
+func newPooledGzipWriter(dst io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	p, _ := gzipPools.LoadOrStore(level, &sync.Pool{})
+	pool := p.(*sync.Pool)
+	if zw, ok := pool.Get().(*gzip.Writer); ok {
+		zw.Reset(dst)
+		return &pooledGzipWriter{Writer: zw, pool: pool}, nil
+	}
+	zw, err := gzip.NewWriterLevel(dst, level)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledGzipWriter{Writer: zw, pool: pool}, nil
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	identity := func(w io.Writer, level int) (io.WriteCloser, error) { return nil, nil }
+	br := CompressionEncoder{Name: "br", New: identity}
+	encoders := []CompressionEncoder{br, GzipEncoder}
+
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, br", "br"},               // 权重相同时取靠前的编码
+		{"br;q=0.5, gzip;q=0.8", "gzip"}, // 按 q 值选择
+		{"BR; q=0.9 , GZIP;q=0.1", "br"}, // 大小写与空白
+		{"gzip;q=0, br;q=0", ""},         // q=0 表示拒绝
+		{"*", "br"},                      // * 匹配未列出的编码
+		{"*;q=0.1, gzip;q=0.5", "gzip"},  // 显式列出的优先于 *
+		{"br;q=0, *;q=0.3", "gzip"},      // 显式拒绝不受 * 影响
+		{"deflate, identity", ""},        // 没有支持的编码
+		{"gzip;q=abc", "gzip"},           // 无效 q 值按 1 处理
+	}
+	for _, tc := range cases {
+		enc, ok := negotiateEncoding(tc.header, encoders)
+		if tc.want == "" {
+			assert.False(t, ok, tc.header)
+			continue
+		}
+		require.True(t, ok, tc.header)
+		assert.Equal(t, tc.want, enc.Name, tc.header)
+	}
+}
+
+func newCompressionTestRouter(cfg CompressionConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Compression(cfg))
+	big := strings.Repeat("hello compression ", 200)
+	r.GET("/json", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": big}) })
+	r.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "tiny") })
+	r.GET("/png", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(big)) })
+	r.GET("/encoded", func(c *gin.Context) {
+		c.Header("Content-Encoding", "br")
+		c.Data(http.StatusOK, "text/plain", []byte(big))
+	})
+	r.GET("/skip/json", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"data": big}) })
+	return r
+}
+
+func TestCompressionResponses(t *testing.T) {
+	r := newCompressionTestRouter(CompressionConfig{SkipPaths: []string{"/skip"}})
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/json", "br;q=1, gzip;q=0.5")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "hello compression")
+
+	for path, accept := range map[string]string{
+		"/json":      "gzip;q=0",
+		"/small":     "gzip",
+		"/png":       "gzip",
+		"/skip/json": "gzip",
+	} {
+		w := get(path, accept)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), path)
+	}
+	assert.Equal(t, "tiny", get("/small", "gzip").Body.String())
+
+	// 已编码的响应原样透传
+	w = get("/encoded", "gzip")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Body.String(), "hello compression")
+}
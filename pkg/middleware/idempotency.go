@@ -1,47 +1,94 @@
 package middleware
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
-	"io"
+	constants "HibiscusIM/pkg/constant"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
+// idemMaxResponse 可回放的最大响应体，超出时重复请求只返回 409
+const idemMaxResponse = 1 << 20
+
+// IdemResponse 首次请求的响应，重复请求时原样回放
+type IdemResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+}
+
 type IdemStore interface {
 	Set(key string, ttl time.Duration) bool // return true if set, false if exists
+	// SaveResponse 保存首次请求的响应，在 ttl 内供重复请求回放
+	SaveResponse(key string, resp IdemResponse, ttl time.Duration) error
+	// GetResponse 读取已保存的响应，首次请求仍在处理或未保存响应时返回 false
+	GetResponse(key string) (*IdemResponse, bool)
+	// Release 释放幂等键，首次请求失败时允许客户端重试
+	Release(key string)
+}
+
+type memoryIdemEntry struct {
+	exp  time.Time
+	resp *IdemResponse
 }
 
 type memoryIdemStore struct {
 	mu sync.Mutex
-	m  map[string]time.Time
+	m  map[string]memoryIdemEntry
 }
 
-func newMemoryIdemStore() *memoryIdemStore { return &memoryIdemStore{m: make(map[string]time.Time)} }
+func newMemoryIdemStore() *memoryIdemStore {
+	return &memoryIdemStore{m: make(map[string]memoryIdemEntry)}
+}
 
 func (s *memoryIdemStore) Set(key string, ttl time.Duration) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	now := time.Now()
-	if exp, ok := s.m[key]; ok && exp.After(now) {
+	if e, ok := s.m[key]; ok && e.exp.After(now) {
 		return false
 	}
-	s.m[key] = now.Add(ttl)
+	s.m[key] = memoryIdemEntry{exp: now.Add(ttl)}
 	return true
 }
 
+func (s *memoryIdemStore) SaveResponse(key string, resp IdemResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = memoryIdemEntry{exp: time.Now().Add(ttl), resp: &resp}
+	return nil
+}
+
+func (s *memoryIdemStore) GetResponse(key string) (*IdemResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.m[key]
+	if !ok || e.resp == nil || !e.exp.After(time.Now()) {
+		return nil, false
+	}
+	return e.resp, true
+}
+
+func (s *memoryIdemStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, key)
+}
+
 // 清理过期键（可选）
 func (s *memoryIdemStore) gc() {
 	for {
 		time.Sleep(1 * time.Minute)
 		now := time.Now()
 		s.mu.Lock()
-		for k, exp := range s.m {
-			if exp.Before(now) {
+		for k, e := range s.m {
+			if e.exp.Before(now) {
 				delete(s.m, k)
 			}
 		}
@@ -52,9 +99,14 @@ func (s *memoryIdemStore) gc() {
 type IdempotencyConfig struct {
 	HeaderName string        // Idempotency-Key 的请求头名
 	TTL        time.Duration // 决定一段时间内重复请求的拒绝窗口
-	Store      IdemStore     // 可选外部存储（如 Redis）
+	Store      IdemStore     // 可选外部存储（如 Redis），多实例部署时需共享存储
+	// Scope 幂等键的归属，只有同一归属的重复请求才会回放；默认依次取 user_id、会话中的用户与客户端 IP
+	Scope func(c *gin.Context) string
 }
 
+// IdempotencyMiddleware 按幂等键拒绝重复请求：首次请求的响应保存后，重复请求直接回放该响应并带上
+// Idempotent-Replayed 响应头；首次请求仍在处理时返回 409，首次请求返回 5xx 时释放幂等键允许重试。
+// 幂等键按调用方、请求方法与路由隔离，未携带幂等键的请求不做去重
 func IdempotencyMiddleware(cfg IdempotencyConfig) gin.HandlerFunc {
 	if cfg.HeaderName == "" {
 		cfg.HeaderName = "Idempotency-Key"
@@ -62,6 +114,9 @@ func IdempotencyMiddleware(cfg IdempotencyConfig) gin.HandlerFunc {
 	if cfg.TTL <= 0 {
 		cfg.TTL = 10 * time.Minute
 	}
+	if cfg.Scope == nil {
+		cfg.Scope = idempotencyScope
+	}
 	store := cfg.Store
 	if store == nil {
 		mem := newMemoryIdemStore()
@@ -71,16 +126,53 @@ func IdempotencyMiddleware(cfg IdempotencyConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		key := strings.TrimSpace(c.GetHeader(cfg.HeaderName))
 		if key == "" {
-			// 兜底以请求体生成哈希作为幂等键
-			b, _ := io.ReadAll(c.Request.Body)
-			c.Request.Body = io.NopCloser(strings.NewReader(string(b)))
-			h := sha256.Sum256(b)
-			key = hex.EncodeToString(h[:])
+			c.Next()
+			return
 		}
+		key = cfg.Scope(c) + ":" + c.Request.Method + ":" + c.FullPath() + ":" + key
 		if !store.Set(key, cfg.TTL) {
+			if resp, ok := store.GetResponse(key); ok {
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(resp.Status, resp.ContentType, resp.Body)
+				c.Abort()
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "duplicate request"})
 			return
 		}
+
+		w := &responseCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
 		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.Status() >= http.StatusInternalServerError {
+			store.Release(key)
+			return
+		}
+		if w.body.Len() > idemMaxResponse {
+			return
+		}
+		resp := IdemResponse{
+			Status:      w.Status(),
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+		}
+		if err := store.SaveResponse(key, resp, cfg.TTL); err != nil {
+			log.Printf("idempotency store save response failed: %v", err)
+		}
+	}
+}
+
+// idempotencyScope 默认的幂等键归属：已登录时为用户，否则为客户端 IP
+func idempotencyScope(c *gin.Context) string {
+	if user := currentUserID(c); user != "" {
+		return "user:" + user
+	}
+	if _, ok := c.Get(sessions.DefaultKey); ok {
+		if uid := sessions.Default(c).Get(constants.UserField); uid != nil {
+			return fmt.Sprintf("user:%v", uid)
+		}
 	}
+	return "ip:" + clientIPFromRequest(c)
 }
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// idemPending 首次请求处理中的占位值
+const idemPending = "pending"
+
+// RedisIdemStoreConfig Redis 幂等存储配置
+type RedisIdemStoreConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	// 键前缀，默认 "idem:"
+	Prefix string
+	// 单次操作超时，默认 1 秒
+	Timeout time.Duration
+}
+
+// RedisIdemStore 基于 Redis 的幂等存储，多实例共享幂等键：SETNX 加 TTL 占位，
+// 首次请求完成后以响应覆盖占位值，供其他实例上的重复请求回放
+type RedisIdemStore struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+// NewRedisIdemStore 创建 Redis 幂等存储
+func NewRedisIdemStore(cfg RedisIdemStoreConfig) (*RedisIdemStore, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "idem:"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return &RedisIdemStore{client: client, prefix: cfg.Prefix, timeout: cfg.Timeout}, nil
+}
+
+// Set 幂等键不存在时写入占位值；Redis 不可用时放行请求，避免阻断业务
+func (s *RedisIdemStore) Set(key string, ttl time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	ok, err := s.client.SetNX(ctx, s.prefix+key, idemPending, ttl).Result()
+	if err != nil {
+		log.Printf("idempotency store set failed: %v", err)
+		return true
+	}
+	return ok
+}
+
+// SaveResponse 以响应覆盖占位值
+func (s *RedisIdemStore) SaveResponse(key string, resp IdemResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+// GetResponse 读取保存的响应，仍为占位值时返回 false
+func (s *RedisIdemStore) GetResponse(key string) (*IdemResponse, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("idempotency store get failed: %v", err)
+		}
+		return nil, false
+	}
+	if string(data) == idemPending {
+		return nil, false
+	}
+	var resp IdemResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, false
+	}
+	return &resp, true
+}
+
+// Release 删除幂等键
+func (s *RedisIdemStore) Release(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+		log.Printf("idempotency store release failed: %v", err)
+	}
+}
+
+// Close 关闭 Redis 连接
+func (s *RedisIdemStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type idempotencyTestServer struct {
+	router *gin.Engine
+	calls  atomic.Int32
+}
+
+func newIdempotencyTestServer(store IdemStore) *idempotencyTestServer {
+	gin.SetMode(gin.TestMode)
+	s := &idempotencyTestServer{router: gin.New()}
+	s.router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set("user_id", user)
+		}
+		c.Next()
+	})
+	s.router.Use(IdempotencyMiddleware(IdempotencyConfig{TTL: time.Minute, Store: store}))
+	s.router.POST("/orders", func(c *gin.Context) {
+		n := s.calls.Add(1)
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"order": n, "user": c.GetString("user_id")})
+	})
+	return s
+}
+
+func (s *idempotencyTestServer) post(path, user, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"item":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// testIdempotencyReplay 各存储共用的回放与隔离用例，key 需在存储中唯一
+func testIdempotencyReplay(t *testing.T, store IdemStore, key string) {
+	s := newIdempotencyTestServer(store)
+
+	first := s.post("/orders", "alice", key)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	// 同一用户重复请求回放首次响应
+	replay := s.post("/orders", "alice", key)
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, int32(1), s.calls.Load())
+
+	// 其他用户使用相同的幂等键不会拿到 alice 的响应
+	other := s.post("/orders", "bob", key)
+	assert.Equal(t, http.StatusCreated, other.Code)
+	assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+	assert.Contains(t, other.Body.String(), `"user":"bob"`)
+	assert.Equal(t, int32(2), s.calls.Load())
+
+	// 未携带幂等键的相同请求体不去重
+	s.post("/orders", "alice", "")
+	s.post("/orders", "alice", "")
+	assert.Equal(t, int32(4), s.calls.Load())
+
+	// 5xx 释放幂等键，允许重试
+	failKey := key + "-fail"
+	assert.Equal(t, http.StatusInternalServerError, s.post("/orders?fail=1", "alice", failKey).Code)
+	retry := s.post("/orders", "alice", failKey)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotencyMemoryStore(t *testing.T) {
+	testIdempotencyReplay(t, newMemoryIdemStore(), "order-1")
+}
+
+func TestIdempotencyInFlightConflict(t *testing.T) {
+	store := newMemoryIdemStore()
+	s := newIdempotencyTestServer(store)
+	// 首次请求仍在处理：已占位但尚未保存响应
+	require.True(t, store.Set("user:alice:POST:/orders:order-2", time.Minute))
+	w := s.post("/orders", "alice", "order-2")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, int32(0), s.calls.Load())
+}
+
+func TestIdempotencyAnonymousScopedByIP(t *testing.T) {
+	s := newIdempotencyTestServer(newMemoryIdemStore())
+	do := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("Idempotency-Key", "anon")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+	do("10.0.0.1")
+	assert.Equal(t, "true", do("10.0.0.1").Header().Get("Idempotent-Replayed"))
+	assert.Empty(t, do("10.0.0.2").Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(2), s.calls.Load())
+}
+
+// newTestRedisIdemStore 连接 IDEMPOTENCY_TEST_REDIS_ADDR 指定的 Redis，未设置时跳过
+func newTestRedisIdemStore(t *testing.T) *RedisIdemStore {
+	addr := os.Getenv("IDEMPOTENCY_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("IDEMPOTENCY_TEST_REDIS_ADDR not set")
+	}
+	store, err := NewRedisIdemStore(RedisIdemStoreConfig{Addr: addr, Prefix: fmt.Sprintf("idem_test_%d:", time.Now().UnixNano())})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestRedisIdemStore(t *testing.T) {
+	store := newTestRedisIdemStore(t)
+
+	assert.True(t, store.Set("k", time.Minute))
+	assert.False(t, store.Set("k", time.Minute))
+	_, ok := store.GetResponse("k")
+	assert.False(t, ok, "pending key has no response")
+
+	require.NoError(t, store.SaveResponse("k", IdemResponse{Status: http.StatusOK, ContentType: "text/plain", Body: []byte("done")}, time.Minute))
+	resp, ok := store.GetResponse("k")
+	require.True(t, ok)
+	assert.Equal(t, http.StatusOK, resp.Status)
+	assert.Equal(t, "done", string(resp.Body))
+
+	store.Release("k")
+	_, ok = store.GetResponse("k")
+	assert.False(t, ok)
+	assert.True(t, store.Set("k", time.Minute))
+
+	// 占位随 TTL 过期
+	assert.True(t, store.Set("short", 50*time.Millisecond))
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, store.Set("short", time.Minute))
+}
+
+func TestIdempotencyRedisStore(t *testing.T) {
+	testIdempotencyReplay(t, newTestRedisIdemStore(t), "order-1")
+}
@@ -1,86 +1,228 @@
 package middleware
 
 import (
-	constants "HibiscusIM/pkg/constant"
+	"HibiscusIM/pkg/redact"
 	"log"
-	"net"
-	"net/http"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mssola/user_agent"
-	"github.com/oschwald/geoip2-golang"
+	"github.com/spf13/cast"
 	"gorm.io/gorm"
 )
 
-// OperationLogMiddleware 记录操作日志
-func OperationLogMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		db := c.MustGet(constants.DbField).(*gorm.DB)
-		// 获取用户 ID 和用户名（假设已通过认证中间件获取）
-		userID, _ := c.Get("user_id")
-		username, _ := c.Get("username")
-
-		// 获取请求的操作和目标
-		action := c.Request.Method   // 操作类型：POST、GET、PUT、DELETE
-		target := c.Request.URL.Path // 操作目标：API 路径
+// OperationLogConfig 操作日志配置
+type OperationLogConfig struct {
+	// 队列长度，队列满时丢弃日志，默认 1024
+	BufferSize int
+	// 单次批量写入条数，默认 100
+	BatchSize int
+	// 最长攒批时间，默认 1 秒
+	FlushInterval time.Duration
+	// 采样率，取值 (0, 1) 时按比例随机记录，其他值全部记录
+	SampleRate float64
+	// 不记录的路径前缀
+	ExcludePaths []string
+	// User 返回当前用户ID与用户名，匿名请求返回 nil；默认读取上下文中的 user_id 与 username
+	User func(c *gin.Context) (userID *int64, username string)
+	// Locate 根据 IP 解析地理位置，为空时不记录位置
+	Locate func(ip string) string
+}
 
-		// 获取请求的 IP 地址
-		ipAddress := c.ClientIP()
+// OperationLogger 操作日志记录器，请求处理完成后入队，由后台协程批量写入数据库，不阻塞请求
+type OperationLogger struct {
+	cfg   OperationLogConfig
+	db    *gorm.DB
+	queue chan OperationLog
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+	// 队列满丢弃的日志数
+	dropped atomic.Int64
+}
 
-		// 获取用户代理信息
-		userAgent := c.GetHeader("User-Agent")
+// NewOperationLogger 创建操作日志记录器并启动后台写入
+func NewOperationLogger(db *gorm.DB, cfg OperationLogConfig) *OperationLogger {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1024
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.User == nil {
+		cfg.User = contextUser
+	}
+	l := &OperationLogger{
+		cfg:   cfg,
+		db:    db,
+		queue: make(chan OperationLog, cfg.BufferSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
 
-		// 获取请求来源页面
-		referer := c.GetHeader("Referer")
+// contextUser 读取认证中间件写入上下文的 user_id 与 username，缺失或类型不符时视为匿名
+func contextUser(c *gin.Context) (*int64, string) {
+	username := c.GetString("username")
+	v, ok := c.Get("user_id")
+	if !ok {
+		return nil, username
+	}
+	id, err := cast.ToInt64E(v)
+	if err != nil || id <= 0 {
+		return nil, username
+	}
+	return &id, username
+}
 
-		ua := user_agent.New(c.GetHeader("User-Agent"))
-		device := ua.Platform()
+// Handler 返回记录操作日志的中间件，在请求处理完成后记录响应状态
+func (l *OperationLogger) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if !l.shouldLog(c.Request.URL.Path) {
+			return
+		}
+		userID, username := l.cfg.User(c)
+		ua := user_agent.New(c.Request.UserAgent())
 		browser, version := ua.Browser()
-		os := ua.OS()
+		ip := c.ClientIP()
+		var location string
+		if l.cfg.Locate != nil {
+			location = l.cfg.Locate(ip)
+		}
+		entry := newOperationLog(OperationLog{
+			UserID:          userID,
+			Username:        username,
+			Action:          c.Request.Method,
+			Target:          c.Request.URL.Path,
+			Details:         "User action recorded",
+			IPAddress:       ip,
+			UserAgent:       c.Request.UserAgent(),
+			Referer:         c.Request.Referer(),
+			Device:          ua.Platform(),
+			Browser:         browser + version,
+			OperatingSystem: ua.OS(),
+			Location:        location,
+			RequestMethod:   c.Request.Method,
+			Status:          c.Writer.Status(),
+		})
+		select {
+		case l.queue <- entry:
+		default:
+			if n := l.dropped.Add(1); n == 1 || n%1000 == 0 {
+				log.Printf("operation log queue full, %d logs dropped", n)
+			}
+		}
+	}
+}
 
-		// 获取请求方法
-		requestMethod := c.Request.Method
+// shouldLog 排除指定路径后按采样率决定是否记录
+func (l *OperationLogger) shouldLog(path string) bool {
+	for _, prefix := range l.cfg.ExcludePaths {
+		if prefix != "" && strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	if rate := l.cfg.SampleRate; rate > 0 && rate < 1 {
+		return rand.Float64() < rate
+	}
+	return true
+}
+
+// Dropped 队列满丢弃的日志数
+func (l *OperationLogger) Dropped() int64 {
+	return l.dropped.Load()
+}
 
-		// 获取地理位置信息（根据 IP 获取）
-		location := getGeoLocation(ipAddress)
+// Close 停止后台写入，提交已入队的日志
+func (l *OperationLogger) Close() {
+	l.once.Do(func() {
+		close(l.stop)
+		<-l.done
+	})
+}
 
-		// 记录操作日志
-		err := CreateOperationLog(db, userID.(int64), username.(string), action, target, "User action recorded", ipAddress, userAgent, referer, device, browser+version, os, location.(string), requestMethod)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record operation log"})
-			c.Abort()
+// run 攒批写入，达到批量大小或等待超时后提交
+func (l *OperationLogger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]OperationLog, 0, l.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
 			return
 		}
-
-		// 继续执行后续处理
-		c.Next()
+		if err := l.db.CreateInBatches(batch, l.cfg.BatchSize).Error; err != nil {
+			log.Printf("write %d operation logs failed: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case entry := <-l.queue:
+			if batch = append(batch, entry); len(batch) >= l.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-l.stop:
+			for {
+				select {
+				case entry := <-l.queue:
+					batch = append(batch, entry)
+				default:
+					flush()
+					return
+				}
+			}
+		}
 	}
 }
 
 // OperationLog 记录用户操作日志
 type OperationLog struct {
 	ID              int64     `gorm:"primaryKey;autoIncrement;not null" json:"id"`
-	UserID          int64     `gorm:"not null" json:"user_id"`          // 操作的用户 ID
-	Username        string    `gorm:"not null" json:"username"`         // 操作的用户名
-	Action          string    `gorm:"not null" json:"action"`           // 操作类型（如：创建、删除、更新等）
-	Target          string    `gorm:"not null" json:"target"`           // 操作目标（如：用户、订单等）
-	Details         string    `gorm:"not null" json:"details"`          // 操作详细描述
-	IPAddress       string    `gorm:"not null" json:"ip_address"`       // 用户 IP 地址
-	UserAgent       string    `gorm:"not null" json:"user_agent"`       // 用户的浏览器信息
-	Referer         string    `gorm:"not null" json:"referer"`          // 请求来源页面
-	Device          string    `gorm:"not null" json:"device"`           // 用户设备（手机、桌面等）
-	Browser         string    `gorm:"not null" json:"browser"`          // 浏览器信息（如 Chrome, Firefox 等）
-	OperatingSystem string    `gorm:"not null" json:"operating_system"` // 操作系统（如 Windows, MacOS 等）
-	Location        string    `gorm:"not null" json:"location"`         // 用户的地理位置
-	RequestMethod   string    `gorm:"not null" json:"request_method"`   // HTTP 请求方法（GET、POST等）
-	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"` // 操作时间
+	UserID          *int64    `gorm:"index" json:"user_id"`                   // 操作的用户 ID，匿名请求为空
+	Username        string    `gorm:"size:128" json:"username"`               // 操作的用户名，匿名请求为空
+	Action          string    `gorm:"not null" json:"action"`                 // 操作类型（如：创建、删除、更新等）
+	Target          string    `gorm:"not null" json:"target"`                 // 操作目标（如：用户、订单等）
+	Details         string    `gorm:"not null" json:"details"`                // 操作详细描述
+	IPAddress       string    `gorm:"not null" json:"ip_address"`             // 用户 IP 地址
+	UserAgent       string    `gorm:"not null" json:"user_agent"`             // 用户的浏览器信息
+	Referer         string    `gorm:"not null" json:"referer"`                // 请求来源页面
+	Device          string    `gorm:"not null" json:"device"`                 // 用户设备（手机、桌面等）
+	Browser         string    `gorm:"not null" json:"browser"`                // 浏览器信息（如 Chrome, Firefox 等）
+	OperatingSystem string    `gorm:"not null" json:"operating_system"`       // 操作系统（如 Windows, MacOS 等）
+	Location        string    `gorm:"not null" json:"location"`               // 用户的地理位置
+	RequestMethod   string    `gorm:"not null" json:"request_method"`         // HTTP 请求方法（GET、POST等）
+	Status          int       `json:"status"`                                 // HTTP 响应状态码
+	CreatedAt       time.Time `gorm:"autoCreateTime;index" json:"created_at"` // 操作时间
+}
+
+// newOperationLog 填充创建时间并对目标、详情与来源页面脱敏
+func newOperationLog(entry OperationLog) OperationLog {
+	r := redact.Default()
+	entry.Target = r.String(entry.Target)
+	entry.Details = r.String(entry.Details)
+	entry.Referer = r.String(entry.Referer)
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return entry
 }
 
-// CreateOperationLog 创建操作日志
+// CreateOperationLog 同步创建操作日志，userID 为 0 时视为匿名；目标、详情与来源页面保存前脱敏
 func CreateOperationLog(db *gorm.DB, userID int64, username, action, target, details, ipAddress, userAgent, referer, device, browser, operatingSystem, location, requestMethod string) error {
-	log := OperationLog{
-		UserID:          userID,
+	entry := newOperationLog(OperationLog{
 		Username:        username,
 		Action:          action,
 		Target:          target,
@@ -93,27 +235,20 @@ func CreateOperationLog(db *gorm.DB, userID int64, username, action, target, det
 		OperatingSystem: operatingSystem,
 		Location:        location,
 		RequestMethod:   requestMethod,
-		CreatedAt:       time.Now(),
+	})
+	if userID > 0 {
+		entry.UserID = &userID
 	}
-
 	// 保存操作日志到数据库
-	if err := db.Create(&log).Error; err != nil {
-		return err
-	}
-	return nil
+	return db.Create(&entry).Error
 }
 
-func getGeoLocation(address string) interface{} {
-	// 使用 GeoIP 获取位置信息
-	reader, err := geoip2.Open("GeoLite2-City.mmdb")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer reader.Close()
-
-	record, err := reader.City(net.ParseIP(address))
-	if err != nil {
-		log.Fatal(err)
-	}
-	return record.City.Names["en"] // 返回城市名
+// Redacted 返回脱敏后的副本，供管理后台输出在启用脱敏前写入的记录
+func (l *OperationLog) Redacted() *OperationLog {
+	r := redact.Default()
+	out := *l
+	out.Target = r.String(l.Target)
+	out.Details = r.String(l.Details)
+	out.Referer = r.String(l.Referer)
+	return &out
 }
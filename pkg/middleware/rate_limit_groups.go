@@ -0,0 +1,237 @@
+package middleware
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// RateLimitScopeGroup 共享配额组的限流桶
+const RateLimitScopeGroup = "group"
+
+// quotaMembersPerGroup 每个配额组记录用量的成员上限，超出后新成员计入 "*"，避免通配成员无限增长
+const quotaMembersPerGroup = 1000
+
+var rateLimitGroupRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rate_limit_group_requests_total",
+	Help: "Requests from quota group members by result (allowed, denied_member, denied_group)",
+}, []string{"group", "result"})
+
+// QuotaGroup 共享配额组（如组织）：成员各自仍受自身速率限制，同时组内所有成员共用 Rate 配额
+//
+// Members 为成员的限流键，如 "user:42"、"hdr:X-API-Key:abc"、"ip:10.0.0.1"，以 * 结尾时按前缀匹配
+type QuotaGroup struct {
+	Rate    string   `json:"rate"`
+	Members []string `json:"members"`
+}
+
+// QuotaMemberUsage 成员在配额组中的用量
+type QuotaMemberUsage struct {
+	Member  string `json:"member"`
+	Allowed int64  `json:"allowed"`
+	Denied  int64  `json:"denied"`
+}
+
+// QuotaGroupUsage 配额组自统计开始以来的用量
+type QuotaGroupUsage struct {
+	Group        string             `json:"group"`
+	Rate         string             `json:"rate"`
+	Allowed      int64              `json:"allowed"`
+	DeniedMember int64              `json:"denied_member"` // 成员自身配额耗尽被拒绝
+	DeniedGroup  int64              `json:"denied_group"`  // 组配额耗尽被拒绝
+	Members      []QuotaMemberUsage `json:"members"`
+	Since        time.Time          `json:"since"`
+}
+
+type quotaGroupPrefix struct {
+	prefix string
+	group  string
+}
+
+// quotaGroupIndex 成员到配额组的索引，同一成员属于多个组时取第一个匹配
+type quotaGroupIndex struct {
+	exact    map[string]string
+	prefixes []quotaGroupPrefix // 按前缀长度降序
+}
+
+func compileQuotaGroups(groups map[string]QuotaGroup) quotaGroupIndex {
+	idx := quotaGroupIndex{exact: make(map[string]string)}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g := groups[name]
+		if g.Rate == "" {
+			continue
+		}
+		for _, m := range g.Members {
+			m = strings.TrimSpace(m)
+			if m == "" {
+				continue
+			}
+			if strings.HasSuffix(m, "*") {
+				idx.prefixes = append(idx.prefixes, quotaGroupPrefix{prefix: strings.TrimSuffix(m, "*"), group: name})
+				continue
+			}
+			if _, ok := idx.exact[m]; !ok {
+				idx.exact[m] = name
+			}
+		}
+	}
+	sort.SliceStable(idx.prefixes, func(i, j int) bool { return len(idx.prefixes[i].prefix) > len(idx.prefixes[j].prefix) })
+	return idx
+}
+
+// match 依次匹配调用方的各个身份，返回所属的配额组与命中的成员键
+func (idx quotaGroupIndex) match(principals []string) (group, member string, ok bool) {
+	for _, p := range principals {
+		if g, ok := idx.exact[p]; ok {
+			return g, p, true
+		}
+	}
+	for _, p := range principals {
+		for _, pre := range idx.prefixes {
+			if strings.HasPrefix(p, pre.prefix) {
+				return pre.group, p, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// principalKeys 调用方可用于匹配配额组成员的身份：与路由无关的限流键、用户、请求头与 IP
+func principalKeys(cfg RateLimiterConfig, c *gin.Context, ip, user string) []string {
+	keys := []string{buildLimitKeyForRoute(cfg, c, ip, user, "")}
+	if user != "" {
+		keys = append(keys, "user:"+user)
+	}
+	if cfg.HeaderName != "" {
+		if hv := strings.TrimSpace(c.GetHeader(cfg.HeaderName)); hv != "" {
+			keys = append(keys, "hdr:"+cfg.HeaderName+":"+hv)
+		}
+	}
+	return append(keys, "ip:"+ip)
+}
+
+// quotaGroupFor 返回调用方所属的配额组
+func (l *RateLimiter) quotaGroupFor(cfg *RateLimiterConfig, c *gin.Context, ip, user string) (name, member string, group QuotaGroup, ok bool) {
+	if len(cfg.QuotaGroups) == 0 {
+		return "", "", QuotaGroup{}, false
+	}
+	l.mu.RLock()
+	idx := l.groups
+	l.mu.RUnlock()
+	name, member, ok = idx.match(principalKeys(*cfg, c, ip, user))
+	if !ok {
+		return "", "", QuotaGroup{}, false
+	}
+	return name, member, cfg.QuotaGroups[name], true
+}
+
+// quotaGroupKey 配额组在存储中的限流键
+func quotaGroupKey(name string) string { return "group:" + name }
+
+type quotaMemberCounter struct {
+	allowed, denied int64
+}
+
+type quotaGroupCounter struct {
+	allowed, deniedMember, deniedGroup int64
+	members                            map[string]*quotaMemberCounter
+}
+
+// quotaUsageTracker 按配额组与成员统计请求数，供计费与用量核对
+type quotaUsageTracker struct {
+	mu     sync.Mutex
+	since  time.Time
+	groups map[string]*quotaGroupCounter
+}
+
+func newQuotaUsageTracker() *quotaUsageTracker {
+	return &quotaUsageTracker{since: time.Now(), groups: make(map[string]*quotaGroupCounter)}
+}
+
+// record 记录一次请求，result 为 allowed、denied_member 或 denied_group
+func (t *quotaUsageTracker) record(group, member, result string) {
+	rateLimitGroupRequests.WithLabelValues(group, result).Inc()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	g, ok := t.groups[group]
+	if !ok {
+		g = &quotaGroupCounter{members: make(map[string]*quotaMemberCounter)}
+		t.groups[group] = g
+	}
+	m, ok := g.members[member]
+	if !ok {
+		if len(g.members) >= quotaMembersPerGroup {
+			member = "*"
+			m = g.members[member]
+		}
+		if m == nil {
+			m = &quotaMemberCounter{}
+			g.members[member] = m
+		}
+	}
+	switch result {
+	case "allowed":
+		g.allowed++
+		m.allowed++
+	case "denied_member":
+		g.deniedMember++
+		m.denied++
+	default:
+		g.deniedGroup++
+		m.denied++
+	}
+}
+
+func (t *quotaUsageTracker) snapshot(groups map[string]QuotaGroup, reset bool) []QuotaGroupUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	names := make(map[string]bool, len(groups)+len(t.groups))
+	for name := range groups {
+		names[name] = true
+	}
+	for name := range t.groups {
+		names[name] = true
+	}
+	list := make([]QuotaGroupUsage, 0, len(names))
+	for name := range names {
+		u := QuotaGroupUsage{Group: name, Rate: groups[name].Rate, Members: []QuotaMemberUsage{}, Since: t.since}
+		if g, ok := t.groups[name]; ok {
+			u.Allowed, u.DeniedMember, u.DeniedGroup = g.allowed, g.deniedMember, g.deniedGroup
+			for member, m := range g.members {
+				u.Members = append(u.Members, QuotaMemberUsage{Member: member, Allowed: m.allowed, Denied: m.denied})
+			}
+			sort.Slice(u.Members, func(i, j int) bool { return u.Members[i].Member < u.Members[j].Member })
+		}
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Group < list[j].Group })
+	if reset {
+		t.since = time.Now()
+		t.groups = make(map[string]*quotaGroupCounter)
+	}
+	return list
+}
+
+// GroupUsage 各配额组的用量，reset 为 true 时读取后清零重新统计（如按账期结算）
+func (l *RateLimiter) GroupUsage(reset bool) []QuotaGroupUsage {
+	return l.usage.snapshot(l.getConfig().QuotaGroups, reset)
+}
+
+// RateLimiterGroupUsage 全局限流器中各配额组的用量
+func RateLimiterGroupUsage(reset bool) []QuotaGroupUsage {
+	ensureInitialized()
+	rateLimiterMutex.RLock()
+	rl := globalRL
+	rateLimiterMutex.RUnlock()
+	return rl.GroupUsage(reset)
+}
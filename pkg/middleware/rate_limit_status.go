@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 限流桶作用范围
+const (
+	RateLimitScopeGlobal = "global"
+	RateLimitScopeRoute  = "route"
+	RateLimitScopeKey    = "key"
+)
+
+// RateLimitBucket 调用方在某个限流桶中的配额
+type RateLimitBucket struct {
+	Scope     string `json:"scope"`           // global|route|key|group
+	Route     string `json:"route,omitempty"` // route 桶对应的路由，global 桶在 ip+route 标识下为查询的路由
+	Key       string `json:"key"`             // 存储中的限流键
+	Rate      string `json:"rate"`
+	Limit     int64  `json:"limit"`
+	Remaining int64  `json:"remaining"`
+	Reset     int64  `json:"reset"`    // 窗口重置的 Unix 时间戳（秒）
+	ResetIn   int    `json:"reset_in"` // 距离重置的秒数
+	Reached   bool   `json:"reached"`
+}
+
+// RateLimitStatus 调用方当前的限流状态
+type RateLimitStatus struct {
+	Identifier string            `json:"identifier"`
+	Exempt     bool              `json:"exempt"`  // 命中白名单，不受限流
+	Blocked    bool              `json:"blocked"` // 命中黑名单，所有请求都会被拒绝
+	Buckets    []RateLimitBucket `json:"buckets"`
+}
+
+// Status 计算调用方在各限流桶中的剩余配额，只读取存储不计数。
+// routes 为额外关心的路由，ip+route 标识下默认速率按路由分桶，需要指定路由才能给出 global 桶
+func (l *RateLimiter) Status(c *gin.Context, routes ...string) (RateLimitStatus, error) {
+	cfg := l.getConfig()
+	identifier := cfg.Identifier
+	if identifier == "" {
+		identifier = "ip"
+	}
+	status := RateLimitStatus{Identifier: identifier, Buckets: []RateLimitBucket{}}
+
+	clientIP := clientIPFromRequest(c)
+	userID := currentUserID(c)
+	if ipListed(clientIP, l.whiteCIDRs) || userListed(userID, cfg.WhitelistUsers) {
+		status.Exempt = true
+		return status, nil
+	}
+	if ipListed(clientIP, l.blackCIDRs) || userListed(userID, cfg.BlacklistUsers) {
+		status.Blocked = true
+		return status, nil
+	}
+
+	// 配额组与路由无关，组内成员共用同一个桶
+	if name, _, group, ok := l.quotaGroupFor(cfg, c, clientIP, userID); ok {
+		bucket, err := l.peekBucket(c, RateLimitScopeGroup, "", quotaGroupKey(name), group.Rate)
+		if err != nil {
+			return status, err
+		}
+		status.Buckets = append(status.Buckets, bucket)
+	}
+
+	// 限流键与路由无关时，键覆盖速率作用于所有路由
+	if identifier != "ip+route" {
+		key := buildLimitKeyForRoute(*cfg, c, clientIP, userID, "")
+		if rate := l.pickRateForKey(cfg, key); rate != "" {
+			bucket, err := l.peekBucket(c, RateLimitScopeKey, "", key, rate)
+			if err != nil {
+				return status, err
+			}
+			status.Buckets = append(status.Buckets, bucket)
+			return status, nil
+		}
+	}
+
+	defaultRate := cfg.Rate
+	if defaultRate == "" {
+		defaultRate = "10-S"
+	}
+	if identifier != "ip+route" {
+		key := buildLimitKeyForRoute(*cfg, c, clientIP, userID, "")
+		bucket, err := l.peekBucket(c, RateLimitScopeGlobal, "", key, defaultRate)
+		if err != nil {
+			return status, err
+		}
+		status.Buckets = append(status.Buckets, bucket)
+	}
+
+	routeSet := make(map[string]bool, len(cfg.PerRouteRates)+len(routes))
+	for route, rate := range cfg.PerRouteRates {
+		if rate != "" {
+			routeSet[route] = true
+		}
+	}
+	for _, route := range routes {
+		if route != "" {
+			routeSet[route] = true
+		}
+	}
+	sorted := make([]string, 0, len(routeSet))
+	for route := range routeSet {
+		sorted = append(sorted, route)
+	}
+	sort.Strings(sorted)
+
+	for _, route := range sorted {
+		key := buildLimitKeyForRoute(*cfg, c, clientIP, userID, route)
+		scope, rate := RateLimitScopeRoute, cfg.PerRouteRates[route]
+		if override := l.pickRateForKey(cfg, key); override != "" {
+			scope, rate = RateLimitScopeKey, override
+		} else if rate == "" {
+			// 没有路由覆盖速率的路由只在 ip+route 标识下有独立的 global 桶
+			if identifier != "ip+route" {
+				continue
+			}
+			scope, rate = RateLimitScopeGlobal, defaultRate
+		}
+		bucket, err := l.peekBucket(c, scope, route, key, rate)
+		if err != nil {
+			return status, err
+		}
+		status.Buckets = append(status.Buckets, bucket)
+	}
+	return status, nil
+}
+
+// peekBucket 读取限流键在指定速率下的状态
+func (l *RateLimiter) peekBucket(c *gin.Context, scope, route, key, rate string) (RateLimitBucket, error) {
+	start := time.Now()
+	ctx, err := l.getLimiter(pickAlgorithmForRoute(l.getConfig(), route, route), rate).Peek(c, key)
+	l.health.observe("peek", time.Since(start), err)
+	if err != nil {
+		return RateLimitBucket{}, err
+	}
+	resetIn := int(time.Until(time.Unix(ctx.Reset, 0)).Seconds())
+	if resetIn < 0 {
+		resetIn = 0
+	}
+	return RateLimitBucket{
+		Scope:     scope,
+		Route:     route,
+		Key:       key,
+		Rate:      rate,
+		Limit:     ctx.Limit,
+		Remaining: ctx.Remaining,
+		Reset:     ctx.Reset,
+		ResetIn:   resetIn,
+		Reached:   ctx.Reached,
+	}, nil
+}
+
+// RateLimitStatusFor 查询调用方在全局限流器中的状态
+func RateLimitStatusFor(c *gin.Context, routes ...string) (RateLimitStatus, error) {
+	ensureInitialized()
+	rateLimiterMutex.RLock()
+	rl := globalRL
+	rateLimiterMutex.RUnlock()
+	return rl.Status(c, routes...)
+}
@@ -21,25 +21,39 @@ import (
 // 示例：
 // Rate: "100-M"、Identifier: "ip"/"user"/"header"、HeaderName: "X-Client-ID"
 // PerRouteRates: {"/api/v1/heavy": "10-S", "/api/v1/normal": "100-S"}
+// PerKeyRates: {"hdr:X-Client-ID:partner": "1000-M", "user:42": "50-S"} 按完整限流键覆盖速率，优先于路由速率
 // WhitelistCIDRs/BlacklistCIDRs: ["10.0.0.0/8", "127.0.0.1/32"]
 // WhitelistUsers/BlacklistUsers: ["admin", "ops-*"] 支持前缀匹配
 // SkipPaths: ["/health", "/metrics", "/static/"] 前缀匹配
 // AddHeaders: 是否写标准限流响应头；DenyStatus/DenyMessage: 自定义拒绝响应
 //
-// Store 采用内存，可通过 SetRateLimiterStore 注入外部存储（如 Redis）。
+// FailureMode: 存储（如 Redis）出错时 open 放行、closed 返回 503
+//
+// Algorithm: fixed 固定窗口（默认）、sliding 滑动窗口；PerRouteAlgorithms: {"/api/v1/login": "sliding"} 按路由覆盖
+//
+// QuotaGroups: {"acme": {"rate": "5000-H", "members": ["user:42", "hdr:X-API-Key:acme-*"]}}
+// 组内成员先按自身速率限流，再共同消耗组配额，任一耗尽即拒绝
+//
+// Store 采用内存，可通过 SetRateLimiterStore 注入外部存储，或使用 NewRedisStoreFactory 创建 Redis 存储。
 type RateLimiterConfig struct {
-	Rate           string            `json:"rate"`            // e.g. "100-M", "1000-H"
-	PerRouteRates  map[string]string `json:"per_route_rates"` // 路由覆盖速率
-	Identifier     string            `json:"identifier"`      // ip|user|header|ip+route
-	HeaderName     string            `json:"header_name"`     // 当 identifier=header 时使用
-	WhitelistCIDRs []string          `json:"whitelist_cidrs"`
-	BlacklistCIDRs []string          `json:"blacklist_cidrs"`
-	WhitelistUsers []string          `json:"whitelist_users"`
-	BlacklistUsers []string          `json:"blacklist_users"`
-	SkipPaths      []string          `json:"skip_paths"`
-	AddHeaders     bool              `json:"add_headers"`
-	DenyStatus     int               `json:"deny_status"` // 默认 429
-	DenyMessage    string            `json:"deny_message"`
+	Rate           string                `json:"rate"`            // e.g. "100-M", "1000-H"
+	PerRouteRates  map[string]string     `json:"per_route_rates"` // 路由覆盖速率
+	PerKeyRates    map[string]string     `json:"per_key_rates"`   // 限流键覆盖速率
+	Identifier     string                `json:"identifier"`      // ip|user|header|ip+route
+	HeaderName     string                `json:"header_name"`     // 当 identifier=header 时使用
+	WhitelistCIDRs []string              `json:"whitelist_cidrs"`
+	BlacklistCIDRs []string              `json:"blacklist_cidrs"`
+	WhitelistUsers []string              `json:"whitelist_users"`
+	BlacklistUsers []string              `json:"blacklist_users"`
+	SkipPaths      []string              `json:"skip_paths"`
+	AddHeaders     bool                  `json:"add_headers"`
+	DenyStatus     int                   `json:"deny_status"` // 默认 429
+	DenyMessage    string                `json:"deny_message"`
+	FailureMode    string                `json:"failure_mode"` // open|closed，存储不可用时放行或拒绝，默认 open
+	QuotaGroups    map[string]QuotaGroup `json:"quota_groups"` // 共享配额组，组名 -> 组配置
+	// 限流算法 fixed|sliding，默认 fixed
+	Algorithm          string            `json:"algorithm"`
+	PerRouteAlgorithms map[string]string `json:"per_route_algorithms"` // 路由覆盖算法
 }
 
 // StoreFactory 用于按需创建 store（例如基于 Redis 客户端）
@@ -88,10 +102,13 @@ type RateLimiter struct {
 	store          limiter.Store
 	storeFactory   StoreFactory
 	observer       MetricsObserver
-	limitersByRate map[string]*limiter.Limiter // rate字符串 -> limiter
+	limitersByRate map[string]rateCounter // 算法:rate字符串 -> limiter
 	mu             sync.RWMutex
 	whiteCIDRs     []*net.IPNet
 	blackCIDRs     []*net.IPNet
+	health         *storeHealthTracker
+	groups         quotaGroupIndex
+	usage          *quotaUsageTracker
 }
 
 // NewRateLimiter 构造函数（推荐使用），避免全局依赖
@@ -102,9 +119,12 @@ func NewRateLimiter(cfg RateLimiterConfig, store limiter.Store) *RateLimiter {
 	l := &RateLimiter{
 		cfg:            &cfg,
 		store:          store,
-		limitersByRate: make(map[string]*limiter.Limiter),
+		limitersByRate: make(map[string]rateCounter),
+		health:         newStoreHealthTracker(),
+		usage:          newQuotaUsageTracker(),
 	}
 	l.compileCIDRs()
+	l.groups = compileQuotaGroups(cfg.QuotaGroups)
 	return l
 }
 
@@ -115,7 +135,7 @@ func (l *RateLimiter) WithStoreFactory(factory StoreFactory) *RateLimiter {
 	l.storeFactory = factory
 	if factory != nil {
 		l.store = factory.Create()
-		l.limitersByRate = make(map[string]*limiter.Limiter) // 重建缓存
+		l.limitersByRate = make(map[string]rateCounter) // 重建缓存
 	}
 	return l
 }
@@ -160,18 +180,28 @@ func (l *RateLimiter) Middleware() gin.HandlerFunc {
 		}
 
 		key := buildLimitKey(*cfg, c, clientIP, userID)
-		rateStr := l.pickRateForRoute(cfg, c)
-		lim := l.getLimiter(rateStr)
+		rateStr := l.pickRateForKey(cfg, key)
+		if rateStr == "" {
+			rateStr = l.pickRateForRoute(cfg, c)
+		}
+		lim := l.getLimiter(pickAlgorithmForRoute(cfg, c.FullPath(), c.Request.URL.Path), rateStr)
 
-		context, err := lim.Get(c, key)
+		context, err := l.getContext(c, lim, key)
 		if err != nil {
+			if l.handleStoreFailure(c, *cfg) {
+				return
+			}
 			c.Next()
 			return
 		}
 		if cfg.AddHeaders {
 			setStandardHeaders(c, context)
 		}
+		groupName, member, group, inGroup := l.quotaGroupFor(cfg, c, clientIP, userID)
 		if context.Reached {
+			if inGroup {
+				l.usage.record(groupName, member, "denied_member")
+			}
 			retry := time.Until(time.Unix(context.Reset, 0))
 			setRetryAfter(c, retry)
 			l.reportDeny(c, key)
@@ -179,6 +209,29 @@ func (l *RateLimiter) Middleware() gin.HandlerFunc {
 			return
 		}
 
+		if inGroup {
+			groupKey := quotaGroupKey(groupName)
+			groupCtx, err := l.getContext(c, l.getLimiter(cfg.Algorithm, group.Rate), groupKey)
+			if err != nil {
+				if l.handleStoreFailure(c, *cfg) {
+					return
+				}
+			} else {
+				// 组配额比成员配额更紧时以组配额为准输出响应头
+				if cfg.AddHeaders && groupCtx.Remaining < context.Remaining {
+					setStandardHeaders(c, groupCtx)
+				}
+				if groupCtx.Reached {
+					l.usage.record(groupName, member, "denied_group")
+					setRetryAfter(c, time.Until(time.Unix(groupCtx.Reset, 0)))
+					l.reportDeny(c, groupKey)
+					denyTooMany(c, *cfg, int(groupCtx.Limit), int(groupCtx.Remaining), time.Unix(groupCtx.Reset, 0))
+					return
+				}
+			}
+			l.usage.record(groupName, member, "allowed")
+		}
+
 		l.reportAllow(c, key)
 		c.Next()
 	}
@@ -210,16 +263,20 @@ func (l *RateLimiter) reportDeny(c *gin.Context, key string) {
 	}
 }
 
-func (l *RateLimiter) getLimiter(rateStr string) *limiter.Limiter {
+func (l *RateLimiter) getLimiter(algorithm, rateStr string) rateCounter {
+	if algorithm != AlgorithmSlidingWindow {
+		algorithm = AlgorithmFixedWindow
+	}
+	cacheKey := algorithm + ":" + rateStr
 	l.mu.RLock()
-	lim, ok := l.limitersByRate[rateStr]
+	lim, ok := l.limitersByRate[cacheKey]
 	l.mu.RUnlock()
 	if ok {
 		return lim
 	}
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if lim, ok = l.limitersByRate[rateStr]; ok {
+	if lim, ok = l.limitersByRate[cacheKey]; ok {
 		return lim
 	}
 	store := l.store
@@ -230,8 +287,12 @@ func (l *RateLimiter) getLimiter(rateStr string) *limiter.Limiter {
 	if err != nil {
 		r = limiter.Rate{Period: time.Second, Limit: 10}
 	}
-	lim = limiter.New(store, r)
-	l.limitersByRate[rateStr] = lim
+	if algorithm == AlgorithmSlidingWindow {
+		lim = newSlidingWindowLimiter(store, r)
+	} else {
+		lim = limiter.New(store, r)
+	}
+	l.limitersByRate[cacheKey] = lim
 	return lim
 }
 
@@ -254,6 +315,14 @@ func (l *RateLimiter) pickRateForRoute(cfg *RateLimiterConfig, c *gin.Context) s
 	return "10-S"
 }
 
+// pickRateForKey 返回限流键的覆盖速率，未配置时返回空串
+func (l *RateLimiter) pickRateForKey(cfg *RateLimiterConfig, key string) string {
+	if cfg.PerKeyRates == nil {
+		return ""
+	}
+	return cfg.PerKeyRates[key]
+}
+
 func (l *RateLimiter) getConfig() *RateLimiterConfig {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -265,6 +334,7 @@ func (l *RateLimiter) UpdateConfig(cfg RateLimiterConfig) {
 	defer l.mu.Unlock()
 	l.cfg = &cfg
 	l.compileCIDRs()
+	l.groups = compileQuotaGroups(cfg.QuotaGroups)
 }
 
 func (l *RateLimiter) compileCIDRs() {
@@ -305,7 +375,10 @@ func SetRateLimiterConfig(config RateLimiterConfig) {
 	rateLimiterMutex.Lock()
 	defer rateLimiterMutex.Unlock()
 	rateLimiterConfig = &config
-	globalRL = nil
+	// 已挂载的中间件持有原实例，原地更新以便配置即时生效
+	if globalRL != nil {
+		globalRL.UpdateConfig(config)
+	}
 }
 
 // GetRateLimiterConfig 获取当前配置（拷贝）
@@ -427,6 +500,15 @@ func userListed(user string, patterns []string) bool {
 }
 
 func buildLimitKey(cfg RateLimiterConfig, c *gin.Context, ip, user string) string {
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return buildLimitKeyForRoute(cfg, c, ip, user, route)
+}
+
+// buildLimitKeyForRoute 计算请求访问 route 时使用的限流键，只有 ip+route 标识与路由相关
+func buildLimitKeyForRoute(cfg RateLimiterConfig, c *gin.Context, ip, user, route string) string {
 	switch cfg.Identifier {
 	case "user":
 		if user != "" {
@@ -440,10 +522,6 @@ func buildLimitKey(cfg RateLimiterConfig, c *gin.Context, ip, user string) strin
 		}
 		return "ip:" + ip
 	case "ip+route":
-		route := c.FullPath()
-		if route == "" {
-			route = c.Request.URL.Path
-		}
 		return "iprt:" + ip + ":" + route
 	default: // ip
 		return "ip:" + ip
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/ulule/limiter/v3"
+)
+
+// 限流存储故障时的处理策略
+const (
+	// FailureModeOpen 存储不可用时放行请求（默认）
+	FailureModeOpen = "open"
+	// FailureModeClosed 存储不可用时拒绝请求
+	FailureModeClosed = "closed"
+)
+
+// storeUnhealthyAfter 连续失败多少次后判定存储不健康
+const storeUnhealthyAfter = 3
+
+// healthProbeKey 健康探测使用的限流键，仅 Peek 不计数
+const healthProbeKey = "__rate_limiter_health__"
+
+var (
+	rateLimitStoreDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rate_limit_store_duration_seconds",
+		Help:    "Latency of rate limiter store calls",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"op", "result"})
+	rateLimitStoreErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rate_limit_store_errors_total",
+		Help: "Failed rate limiter store calls",
+	})
+	rateLimitStoreHealthy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "rate_limit_store_healthy",
+		Help: "Whether the rate limiter store is healthy (1) or degraded (0)",
+	})
+	rateLimitStoreFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limit_store_failure_decisions_total",
+		Help: "Requests decided by the failure mode while the store was failing",
+	}, []string{"mode"})
+)
+
+// StoreHealth 限流存储健康状态
+type StoreHealth struct {
+	Healthy           bool          `json:"healthy"`
+	FailureMode       string        `json:"failure_mode"`
+	ConsecutiveErrors int           `json:"consecutive_errors"`
+	TotalCalls        int64         `json:"total_calls"`
+	TotalErrors       int64         `json:"total_errors"`
+	LastError         string        `json:"last_error,omitempty"`
+	LastErrorAt       time.Time     `json:"last_error_at,omitempty"`
+	LastSuccessAt     time.Time     `json:"last_success_at,omitempty"`
+	LastLatency       time.Duration `json:"last_latency"`
+}
+
+// storeHealthTracker 记录存储调用结果
+type storeHealthTracker struct {
+	mu     sync.Mutex
+	health StoreHealth
+}
+
+func newStoreHealthTracker() *storeHealthTracker {
+	rateLimitStoreHealthy.Set(1)
+	return &storeHealthTracker{health: StoreHealth{Healthy: true}}
+}
+
+// observe 记录一次存储调用的耗时与结果
+func (t *storeHealthTracker) observe(op string, latency time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+		rateLimitStoreErrors.Inc()
+	}
+	rateLimitStoreDuration.WithLabelValues(op, result).Observe(latency.Seconds())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := &t.health
+	h.TotalCalls++
+	h.LastLatency = latency
+	if err != nil {
+		h.TotalErrors++
+		h.ConsecutiveErrors++
+		h.LastError = err.Error()
+		h.LastErrorAt = time.Now()
+	} else {
+		h.ConsecutiveErrors = 0
+		h.LastSuccessAt = time.Now()
+	}
+	h.Healthy = h.ConsecutiveErrors < storeUnhealthyAfter
+	if h.Healthy {
+		rateLimitStoreHealthy.Set(1)
+	} else {
+		rateLimitStoreHealthy.Set(0)
+	}
+}
+
+func (t *storeHealthTracker) snapshot() StoreHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.health
+}
+
+// getContext 调用存储获取限流状态并记录耗时
+func (l *RateLimiter) getContext(c *gin.Context, lim rateCounter, key string) (limiter.Context, error) {
+	start := time.Now()
+	ctx, err := lim.Get(c, key)
+	l.health.observe("get", time.Since(start), err)
+	return ctx, err
+}
+
+// handleStoreFailure 按故障策略处理存储错误，返回 true 表示请求已被拒绝
+func (l *RateLimiter) handleStoreFailure(c *gin.Context, cfg RateLimiterConfig) bool {
+	if cfg.FailureMode != FailureModeClosed {
+		rateLimitStoreFailures.WithLabelValues(FailureModeOpen).Inc()
+		return false
+	}
+	rateLimitStoreFailures.WithLabelValues(FailureModeClosed).Inc()
+	l.reportDeny(c, "store_error")
+	setRetryAfter(c, time.Second)
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Rate limiter unavailable"})
+	return true
+}
+
+// ProbeStore 主动探测存储可用性，结果计入健康状态
+func (l *RateLimiter) ProbeStore(ctx context.Context) error {
+	l.mu.RLock()
+	store := l.store
+	l.mu.RUnlock()
+	start := time.Now()
+	_, err := store.Peek(ctx, healthProbeKey, limiter.Rate{Period: time.Second, Limit: 1})
+	l.health.observe("probe", time.Since(start), err)
+	return err
+}
+
+// StoreHealth 当前存储健康状态
+func (l *RateLimiter) StoreHealth() StoreHealth {
+	h := l.health.snapshot()
+	h.FailureMode = l.getConfig().FailureMode
+	if h.FailureMode == "" {
+		h.FailureMode = FailureModeOpen
+	}
+	return h
+}
+
+// RateLimiterStoreHealth 探测全局限流器的存储并返回健康状态
+func RateLimiterStoreHealth(ctx context.Context) StoreHealth {
+	ensureInitialized()
+	rateLimiterMutex.RLock()
+	rl := globalRL
+	rateLimiterMutex.RUnlock()
+	_ = rl.ProbeStore(ctx)
+	return rl.StoreHealth()
+}
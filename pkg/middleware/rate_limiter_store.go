@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/ulule/limiter/v3"
+	redisstore "github.com/ulule/limiter/v3/drivers/store/redis"
+)
+
+// 限流算法
+const (
+	// AlgorithmFixedWindow 固定窗口：每个周期重新计数，窗口边界处最多可放行两倍速率
+	AlgorithmFixedWindow = "fixed"
+	// AlgorithmSlidingWindow 滑动窗口：按上一周期计数的剩余比例加上本周期计数估算，流量更平滑
+	AlgorithmSlidingWindow = "sliding"
+)
+
+// RedisStoreConfig Redis 限流存储配置
+type RedisStoreConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	// 键前缀，默认 "ratelimit"
+	Prefix string
+}
+
+// RedisStoreFactory 基于 Redis 的存储工厂，多实例共享限流计数
+type RedisStoreFactory struct {
+	client *redis.Client
+	store  limiter.Store
+}
+
+// NewRedisStoreFactory 连接 Redis 并创建限流存储，连接失败时返回错误
+func NewRedisStoreFactory(cfg RedisStoreConfig) (*RedisStoreFactory, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "ratelimit"
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	store, err := redisstore.NewStoreWithOptions(client, limiter.StoreOptions{Prefix: cfg.Prefix})
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to create redis rate limit store: %w", err)
+	}
+	return &RedisStoreFactory{client: client, store: store}, nil
+}
+
+// Create 返回共享的 Redis 存储
+func (f *RedisStoreFactory) Create() limiter.Store { return f.store }
+
+// Close 关闭 Redis 连接
+func (f *RedisStoreFactory) Close() error { return f.client.Close() }
+
+// rateCounter 限流计数，Get 计入本次请求，Peek 只读取状态
+type rateCounter interface {
+	Get(ctx context.Context, key string) (limiter.Context, error)
+	Peek(ctx context.Context, key string) (limiter.Context, error)
+}
+
+// slidingWindowLimiter 滑动窗口计数：每个周期单独计数，
+// 估算值为上一周期计数乘以其仍在窗口内的比例加上本周期计数
+type slidingWindowLimiter struct {
+	store limiter.Store
+	rate  limiter.Rate
+	now   func() time.Time
+}
+
+func newSlidingWindowLimiter(store limiter.Store, rate limiter.Rate) *slidingWindowLimiter {
+	return &slidingWindowLimiter{store: store, rate: rate, now: time.Now}
+}
+
+// Get 计入本次请求并返回限流状态
+func (s *slidingWindowLimiter) Get(ctx context.Context, key string) (limiter.Context, error) {
+	return s.count(ctx, key, true)
+}
+
+// Peek 返回限流状态，不计入请求
+func (s *slidingWindowLimiter) Peek(ctx context.Context, key string) (limiter.Context, error) {
+	return s.count(ctx, key, false)
+}
+
+func (s *slidingWindowLimiter) count(ctx context.Context, key string, increment bool) (limiter.Context, error) {
+	period := s.rate.Period
+	now := s.now()
+	window := now.UnixNano() / int64(period)
+	windowEnd := time.Unix(0, (window+1)*int64(period))
+
+	// 周期计数保留两个周期供下一周期估算；上限取最大值，使存储返回的剩余数可还原出实际计数
+	counter := limiter.Rate{Period: 2 * period, Limit: math.MaxInt64}
+	prev, err := s.store.Peek(ctx, key+":"+strconv.FormatInt(window-1, 10), counter)
+	if err != nil {
+		return limiter.Context{}, err
+	}
+	curKey := key + ":" + strconv.FormatInt(window, 10)
+	var cur limiter.Context
+	if increment {
+		cur, err = s.store.Increment(ctx, curKey, 1, counter)
+	} else {
+		cur, err = s.store.Peek(ctx, curKey, counter)
+	}
+	if err != nil {
+		return limiter.Context{}, err
+	}
+
+	overlap := float64(windowEnd.Sub(now)) / float64(period)
+	used := int64(math.Ceil(float64(counter.Limit-prev.Remaining)*overlap)) + counter.Limit - cur.Remaining
+	lctx := limiter.Context{Limit: s.rate.Limit, Reset: windowEnd.Unix()}
+	if used > s.rate.Limit {
+		lctx.Reached = true
+	} else {
+		lctx.Remaining = s.rate.Limit - used
+	}
+	return lctx, nil
+}
+
+// pickAlgorithmForRoute 路由使用的限流算法，未配置时为固定窗口
+func pickAlgorithmForRoute(cfg *RateLimiterConfig, fullPath, rawPath string) string {
+	if cfg.PerRouteAlgorithms != nil {
+		if a, ok := cfg.PerRouteAlgorithms[fullPath]; ok && fullPath != "" && a != "" {
+			return a
+		}
+		if a, ok := cfg.PerRouteAlgorithms[rawPath]; ok && rawPath != "" && a != "" {
+			return a
+		}
+	}
+	if cfg.Algorithm != "" {
+		return cfg.Algorithm
+	}
+	return AlgorithmFixedWindow
+}
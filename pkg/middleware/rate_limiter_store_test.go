@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulule/limiter/v3"
+	"github.com/ulule/limiter/v3/drivers/store/memory"
+)
+
+func TestSlidingWindowBoundaries(t *testing.T) {
+	ctx := context.Background()
+	period := time.Minute
+	s := newSlidingWindowLimiter(memory.NewStore(), limiter.Rate{Period: period, Limit: 10})
+	// 对齐到下一个周期的起点
+	start := time.Unix(0, (time.Now().UnixNano()/int64(period)+1)*int64(period))
+	now := start
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		lctx, err := s.Get(ctx, "k")
+		require.NoError(t, err)
+		require.False(t, lctx.Reached, "request %d", i)
+	}
+	lctx, err := s.Peek(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), lctx.Remaining)
+	assert.False(t, lctx.Reached)
+	assert.Equal(t, start.Add(period).Unix(), lctx.Reset)
+
+	// 进入下一周期的瞬间，上一周期的计数仍完整计入，不会出现固定窗口的两倍放行
+	now = start.Add(period)
+	lctx, err = s.Peek(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), lctx.Remaining)
+	lctx, err = s.Get(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, lctx.Reached)
+
+	// 周期过去一半，上一周期计数按一半估算：ceil(10*0.5) + 本周期 1
+	now = start.Add(period + period/2)
+	lctx, err = s.Peek(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), lctx.Remaining)
+
+	// 周期末尾：上一周期只剩 10% 仍在窗口内，向上取整为 1
+	now = start.Add(2*period - period/10)
+	lctx, err = s.Peek(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), lctx.Remaining)
+
+	// 两个周期后计数全部滑出窗口
+	now = start.Add(3 * period)
+	lctx, err = s.Peek(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), lctx.Remaining)
+	assert.False(t, lctx.Reached)
+}
+
+func TestSlidingWindowKeysIsolated(t *testing.T) {
+	ctx := context.Background()
+	s := newSlidingWindowLimiter(memory.NewStore(), limiter.Rate{Period: time.Minute, Limit: 1})
+	now := time.Unix(0, (time.Now().UnixNano()/int64(time.Minute)+1)*int64(time.Minute))
+	s.now = func() time.Time { return now }
+
+	lctx, err := s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, lctx.Reached)
+	lctx, err = s.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, lctx.Reached)
+	lctx, err = s.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, lctx.Reached)
+}
@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"HibiscusIM/pkg/cache"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 响应缓存状态，写入 X-Cache 响应头
+const (
+	ResponseCacheHit    = "HIT"
+	ResponseCacheMiss   = "MISS"
+	ResponseCacheBypass = "BYPASS"
+)
+
+// ResponseCacheConfig 响应缓存配置
+type ResponseCacheConfig struct {
+	Cache cache.Cache
+	// 缓存时长，<=0 时使用 1 分钟
+	TTL time.Duration
+	// 缓存键前缀，默认 "resp:"
+	Prefix string
+	// Scope 返回响应所属的用户范围（如用户ID），不同范围分别缓存；为空表示所有用户共享同一份响应
+	Scope func(c *gin.Context) string
+}
+
+// cachedResponse 缓存的响应
+type cachedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Body        []byte `json:"body"`
+	StoredAt    int64  `json:"storedAt"`
+}
+
+// ResponseCache 幂等 GET 接口的响应缓存。路由通过 Handler 按依赖的模型打标签，
+// RegisterHooks 安装 GORM 回调后，这些模型的表发生写入时自动使相关响应失效
+type ResponseCache struct {
+	cfg ResponseCacheConfig
+	db  *gorm.DB
+
+	mu     sync.RWMutex
+	tables map[string]bool
+}
+
+// NewResponseCache 创建响应缓存，db 用于解析模型表名
+func NewResponseCache(db *gorm.DB, cfg ResponseCacheConfig) *ResponseCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "resp:"
+	}
+	return &ResponseCache{cfg: cfg, db: db, tables: make(map[string]bool)}
+}
+
+// RegisterHooks 在 db 上安装回调，被缓存路由依赖的表写入成功后使其响应失效
+func (rc *ResponseCache) RegisterHooks(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("response_cache:invalidate_create", rc.afterWrite); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("response_cache:invalidate_update", rc.afterWrite); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("response_cache:invalidate_delete", rc.afterWrite)
+}
+
+func (rc *ResponseCache) afterWrite(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	table := tx.Statement.Schema.Table
+	rc.mu.RLock()
+	tracked := rc.tables[table]
+	rc.mu.RUnlock()
+	if !tracked {
+		return
+	}
+	if err := rc.Invalidate(tx.Statement.Context, table); err != nil {
+		log.Printf("response cache invalidation failed for %s: %v", table, err)
+	}
+}
+
+// Invalidate 使依赖指定表的全部缓存响应失效
+func (rc *ResponseCache) Invalidate(ctx context.Context, tables ...string) error {
+	tags := make([]string, len(tables))
+	for i, t := range tables {
+		tags[i] = rc.cfg.Prefix + "table:" + t
+	}
+	return rc.cfg.Cache.InvalidateTag(ctx, tags...)
+}
+
+// Handler 返回缓存中间件，models 为响应依赖的模型，其表写入后缓存失效；模型无法解析时 panic
+func (rc *ResponseCache) Handler(models ...interface{}) gin.HandlerFunc {
+	tags := make([]string, 0, len(models))
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: rc.db}
+		if err := stmt.Parse(m); err != nil {
+			panic(fmt.Sprintf("response cache: parse model %T: %v", m, err))
+		}
+		rc.mu.Lock()
+		rc.tables[stmt.Schema.Table] = true
+		rc.mu.Unlock()
+		tags = append(tags, rc.cfg.Prefix+"table:"+stmt.Schema.Table)
+	}
+	maxAge := strconv.Itoa(int(rc.cfg.TTL / time.Second))
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		directives := parseCacheControl(c.GetHeader("Cache-Control"))
+		if _, ok := directives["no-store"]; ok {
+			c.Header("X-Cache", ResponseCacheBypass)
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := rc.key(c)
+		_, noCache := directives["no-cache"]
+		if !noCache {
+			if entry, ok := rc.lookup(ctx, key); ok {
+				age := time.Now().Unix() - entry.StoredAt
+				if limit, err := strconv.ParseInt(directives["max-age"], 10, 64); err != nil || age <= limit {
+					c.Header("X-Cache", ResponseCacheHit)
+					c.Header("Age", strconv.FormatInt(max(age, 0), 10))
+					c.Header("Cache-Control", "private, max-age="+maxAge)
+					c.Data(entry.Status, entry.ContentType, entry.Body)
+					c.Abort()
+					return
+				}
+			}
+		}
+		if _, ok := directives["only-if-cached"]; ok {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "response not cached"})
+			return
+		}
+
+		w := &responseCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Header("X-Cache", ResponseCacheMiss)
+		c.Header("Cache-Control", "private, max-age="+maxAge)
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		if w.Status() != http.StatusOK || c.Request.Method != http.MethodGet || !cacheableBody(w.body.Bytes()) {
+			return
+		}
+		data, err := json.Marshal(cachedResponse{
+			Status:      http.StatusOK,
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        w.body.Bytes(),
+			StoredAt:    time.Now().Unix(),
+		})
+		if err != nil {
+			return
+		}
+		if err := rc.cfg.Cache.SetWithTags(ctx, key, string(data), rc.cfg.TTL, tags...); err != nil {
+			log.Printf("response cache store failed: %v", err)
+		}
+	}
+}
+
+// key 由路由、路径参数、查询参数、语言与用户范围生成缓存键
+func (rc *ResponseCache) key(c *gin.Context) string {
+	params := make([]string, 0, len(c.Params))
+	for _, p := range c.Params {
+		params = append(params, p.Key+"="+p.Value)
+	}
+	sort.Strings(params)
+	scope := ""
+	if rc.cfg.Scope != nil {
+		scope = rc.cfg.Scope(c)
+	}
+	h := sha256.New()
+	for _, part := range []string{
+		c.FullPath(),
+		strings.Join(params, "&"),
+		c.Request.URL.Query().Encode(),
+		c.GetHeader("Accept-Language"),
+		scope,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return rc.cfg.Prefix + "entry:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func (rc *ResponseCache) lookup(ctx context.Context, key string) (cachedResponse, bool) {
+	raw, ok := rc.cfg.Cache.Get(ctx, key)
+	if !ok {
+		return cachedResponse{}, false
+	}
+	var entry cachedResponse
+	var err error
+	switch v := raw.(type) {
+	case string:
+		err = json.Unmarshal([]byte(v), &entry)
+	case []byte:
+		err = json.Unmarshal(v, &entry)
+	default:
+		err = fmt.Errorf("unexpected cached value %T", raw)
+	}
+	return entry, err == nil && entry.Status != 0
+}
+
+// cacheableBody response.Fail 以 200 状态返回错误，JSON 响应中 code 不为 200 时不缓存
+func cacheableBody(body []byte) bool {
+	var envelope struct {
+		Code *int `json:"code"`
+	}
+	if json.Unmarshal(body, &envelope) != nil || envelope.Code == nil {
+		return true
+	}
+	return *envelope.Code == http.StatusOK
+}
+
+// parseCacheControl 解析 Cache-Control 指令，指令名小写
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			directives[name] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return directives
+}
+
+// responseCaptureWriter 在写出响应的同时保留一份响应体
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,173 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 通知推送渠道
+const (
+	ChannelWebSocket = "websocket"
+	ChannelSSE       = "sse"
+	ChannelEmail     = "email"
+)
+
+// Pusher 实时推送渠道，Push 返回是否至少送达一个在线连接，用户不在线时返回 false
+type Pusher interface {
+	Push(userID uint, n *InternalNotification) bool
+}
+
+// Mailer 离线时的邮件发送，MailNotification 实现了该接口
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// NotificationPreference 用户通知渠道偏好，没有记录时所有渠道均启用
+type NotificationPreference struct {
+	UserID       uint       `json:"user_id" gorm:"primaryKey"` // 用户 ID
+	WebSocket    bool       `json:"websocket"`                 // 是否通过 WebSocket 推送
+	SSE          bool       `json:"sse"`                       // 是否通过 SSE 推送
+	Email        bool       `json:"email"`                     // 离线时是否发送邮件
+	Digest       bool       `json:"digest"`                    // 是否每天发送未读通知摘要邮件
+	DigestHour   int        `json:"digest_hour"`               // 摘要发送时间，用户当地时间的整点（0-23）
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`  // 上次发送摘要的时间
+	UpdatedAt    time.Time  `json:"updated_at"`                // 更新时间
+}
+
+// DefaultDigestHour 默认在用户当地时间 8 点发送摘要
+const DefaultDigestHour = 8
+
+// DefaultPreference 返回全部渠道启用、未开启摘要的默认偏好
+func DefaultPreference(userID uint) NotificationPreference {
+	return NotificationPreference{UserID: userID, WebSocket: true, SSE: true, Email: true, DigestHour: DefaultDigestHour}
+}
+
+// Enabled 渠道是否启用，未知渠道视为启用
+func (p NotificationPreference) Enabled(channel string) bool {
+	switch channel {
+	case ChannelWebSocket:
+		return p.WebSocket
+	case ChannelSSE:
+		return p.SSE
+	case ChannelEmail:
+		return p.Email
+	}
+	return true
+}
+
+// DispatchResult 一次分发的结果
+type DispatchResult struct {
+	Delivered []string `json:"delivered"` // 实时送达的渠道
+	Emailed   bool     `json:"emailed"`   // 是否已发送邮件
+}
+
+type namedPusher struct {
+	channel string
+	pusher  Pusher
+}
+
+// Dispatcher 把站内通知推送给在线用户（WebSocket、SSE），都未送达时按用户偏好回退为邮件
+type Dispatcher struct {
+	db *gorm.DB
+
+	mu      sync.RWMutex
+	pushers []namedPusher
+	mailer  Mailer
+	emailOf func(userID uint) (string, error)
+}
+
+var (
+	globalDispatcher   *Dispatcher
+	globalDispatcherMu sync.RWMutex
+)
+
+// SetDispatcher 设置全局通知分发器，InternalNotificationService.Send 写入后通过它推送
+func SetDispatcher(d *Dispatcher) {
+	globalDispatcherMu.Lock()
+	defer globalDispatcherMu.Unlock()
+	globalDispatcher = d
+}
+
+// GetDispatcher 获取全局通知分发器
+func GetDispatcher() *Dispatcher {
+	globalDispatcherMu.RLock()
+	defer globalDispatcherMu.RUnlock()
+	return globalDispatcher
+}
+
+// NewDispatcher 创建通知分发器，db 用于读取用户渠道偏好
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{db: db}
+}
+
+// AddPusher 注册实时推送渠道，按注册顺序依次推送
+func (d *Dispatcher) AddPusher(channel string, p Pusher) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pushers = append(d.pushers, namedPusher{channel: channel, pusher: p})
+}
+
+// SetMailFallback 设置离线邮件回退，emailOf 根据用户 ID 查询收件地址
+func (d *Dispatcher) SetMailFallback(m Mailer, emailOf func(userID uint) (string, error)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mailer = m
+	d.emailOf = emailOf
+}
+
+// Preference 读取用户渠道偏好，没有记录时返回默认偏好
+func (d *Dispatcher) Preference(ctx context.Context, userID uint) (NotificationPreference, error) {
+	var pref NotificationPreference
+	err := d.db.WithContext(ctx).Where("user_id = ?", userID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultPreference(userID), nil
+	}
+	return pref, err
+}
+
+// SetPreference 保存用户渠道偏好
+func (d *Dispatcher) SetPreference(ctx context.Context, pref NotificationPreference) error {
+	pref.UpdatedAt = time.Now()
+	return d.db.WithContext(ctx).Save(&pref).Error
+}
+
+// Dispatch 按用户偏好推送通知：依次尝试各实时渠道，均未送达（用户离线或关闭了实时渠道）时发送邮件
+func (d *Dispatcher) Dispatch(ctx context.Context, n *InternalNotification) (DispatchResult, error) {
+	result := DispatchResult{}
+	pref, err := d.Preference(ctx, n.UserID)
+	if err != nil {
+		return result, err
+	}
+
+	d.mu.RLock()
+	pushers := d.pushers
+	mailer, emailOf := d.mailer, d.emailOf
+	d.mu.RUnlock()
+
+	for _, p := range pushers {
+		if pref.Enabled(p.channel) && p.pusher.Push(n.UserID, n) {
+			result.Delivered = append(result.Delivered, p.channel)
+		}
+	}
+	if len(result.Delivered) > 0 || !pref.Email || mailer == nil || emailOf == nil {
+		return result, nil
+	}
+
+	to, err := emailOf(n.UserID)
+	if err != nil {
+		return result, fmt.Errorf("lookup email of user %d: %w", n.UserID, err)
+	}
+	if to == "" {
+		return result, nil
+	}
+	if err := mailer.Send(to, n.Title, n.Content); err != nil {
+		return result, err
+	}
+	result.Emailed = true
+	return result, nil
+}
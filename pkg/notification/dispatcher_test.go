@@ -0,0 +1,96 @@
+package notification
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type fakePusher struct {
+	online map[uint]bool
+	pushed []uint
+}
+
+func (p *fakePusher) Push(userID uint, n *InternalNotification) bool {
+	if !p.online[userID] {
+		return false
+	}
+	p.pushed = append(p.pushed, n.ID)
+	return true
+}
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *fakeMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, to+":"+subject)
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:dispatcher?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&NotificationPreference{}))
+
+	ctx := context.Background()
+	ws := &fakePusher{online: map[uint]bool{1: true}}
+	sse := &fakePusher{online: map[uint]bool{1: true, 2: true}}
+	mailer := &fakeMailer{}
+	d := NewDispatcher(db)
+	d.AddPusher(ChannelWebSocket, ws)
+	d.AddPusher(ChannelSSE, sse)
+	d.SetMailFallback(mailer, func(userID uint) (string, error) {
+		if userID == 4 {
+			return "", nil
+		}
+		return "user@example.com", nil
+	})
+
+	// 在线用户通过所有启用的实时渠道推送
+	result, err := d.Dispatch(ctx, &InternalNotification{ID: 10, UserID: 1, Title: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ChannelWebSocket, ChannelSSE}, result.Delivered)
+	assert.False(t, result.Emailed)
+
+	// 关闭 SSE 后只通过 WebSocket 推送
+	require.NoError(t, d.SetPreference(ctx, NotificationPreference{UserID: 1, WebSocket: true, Email: true}))
+	result, err = d.Dispatch(ctx, &InternalNotification{ID: 11, UserID: 1, Title: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ChannelWebSocket}, result.Delivered)
+	assert.Equal(t, []uint{10}, sse.pushed)
+
+	// 仅 SSE 在线
+	result, err = d.Dispatch(ctx, &InternalNotification{ID: 12, UserID: 2, Title: "t"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ChannelSSE}, result.Delivered)
+
+	// 离线时回退为邮件
+	result, err = d.Dispatch(ctx, &InternalNotification{ID: 13, UserID: 3, Title: "offline"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Delivered)
+	assert.True(t, result.Emailed)
+	assert.Equal(t, []string{"user@example.com:offline"}, mailer.sent)
+
+	// 关闭邮件或没有收件地址时不发送
+	require.NoError(t, d.SetPreference(ctx, NotificationPreference{UserID: 3, WebSocket: true, SSE: true}))
+	result, err = d.Dispatch(ctx, &InternalNotification{ID: 14, UserID: 3, Title: "offline"})
+	require.NoError(t, err)
+	assert.False(t, result.Emailed)
+	result, err = d.Dispatch(ctx, &InternalNotification{ID: 15, UserID: 4, Title: "offline"})
+	require.NoError(t, err)
+	assert.False(t, result.Emailed)
+	assert.Len(t, mailer.sent, 1)
+
+	pref, err := d.Preference(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPreference(5), pref)
+}
@@ -1,8 +1,12 @@
 package notification
 
 import (
-	"gorm.io/gorm"
+	"HibiscusIM/pkg/logger"
+	"context"
 	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // InternalNotification 站内通知
@@ -25,8 +29,24 @@ func NewInternalNotificationService(db *gorm.DB) *InternalNotificationService {
 	return &InternalNotificationService{DB: db}
 }
 
-// Send 发送站内通知
+// Send 发送站内通知，设置了全局分发器时异步推送给在线用户或回退为邮件；
+// 设置了发件箱投递器时通知与发件箱记录在同一事务中写入，由投递器保证分发
 func (s *InternalNotificationService) Send(userID uint, title, content string) error {
+	if relay := GetOutboxRelay(); relay != nil {
+		var n *InternalNotification
+		err := s.DB.Transaction(func(tx *gorm.DB) (err error) {
+			n, err = WriteOutbox(tx, userID, title, content)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := relay.Publish(context.Background(), n.ID); err != nil {
+			logger.Warn("publish notification outbox failed", zap.Uint("id", n.ID), zap.Error(err))
+		}
+		return nil
+	}
+
 	notification := InternalNotification{
 		UserID:    userID,
 		Title:     title,
@@ -36,7 +56,20 @@ func (s *InternalNotificationService) Send(userID uint, title, content string) e
 	}
 
 	// 将通知存储到数据库
-	return s.DB.Create(&notification).Error
+	if err := s.DB.Create(&notification).Error; err != nil {
+		return err
+	}
+	if counter := GetUnreadCounter(); counter != nil {
+		counter.Incr(context.Background(), userID, 1)
+	}
+	if d := GetDispatcher(); d != nil {
+		go func() {
+			if _, err := d.Dispatch(context.Background(), &notification); err != nil {
+				logger.Warn("dispatch notification failed", zap.Uint("id", notification.ID), zap.Uint("user_id", userID), zap.Error(err))
+			}
+		}()
+	}
+	return nil
 }
 
 // GetUnreadNotifications 获取用户的未读通知
@@ -46,18 +79,39 @@ func (s *InternalNotificationService) GetUnreadNotifications(userID uint) ([]Int
 	return notifications, err
 }
 
+// GetUnreadNotificationsCount 获取用户未读通知数，启用计数器时直接读取缓存计数
 func (s *InternalNotificationService) GetUnreadNotificationsCount(userID uint) (count int64, err error) {
+	if counter := GetUnreadCounter(); counter != nil {
+		return counter.Get(context.Background(), userID)
+	}
 	return count, s.DB.Model(&InternalNotification{}).Where("user_id = ? AND read = ?", userID, false).Count(&count).Error
 }
 
 // MarkAsRead 将通知标记为已读
 func (s *InternalNotificationService) MarkAsRead(notificationID uint) error {
-	return s.DB.Model(&InternalNotification{}).Where("id = ?", notificationID).Update("read", true).Error
+	var notification InternalNotification
+	if err := s.DB.Select("id", "user_id").First(&notification, notificationID).Error; err != nil {
+		return err
+	}
+	result := s.DB.Model(&InternalNotification{}).Where("id = ? AND read = ?", notificationID, false).Update("read", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if counter := GetUnreadCounter(); counter != nil && result.RowsAffected > 0 {
+		counter.Decr(context.Background(), notification.UserID, result.RowsAffected)
+	}
+	return nil
 }
 
-// MarkAsRead 将通知标记为已读
+// MarkAllAsRead 将用户所有通知标记为已读
 func (s *InternalNotificationService) MarkAllAsRead(userID uint) error {
-	return s.DB.Model(&InternalNotification{}).Where("user_id = ?", userID).Update("read", true).Error
+	if err := s.DB.Model(&InternalNotification{}).Where("user_id = ?", userID).Update("read", true).Error; err != nil {
+		return err
+	}
+	if counter := GetUnreadCounter(); counter != nil {
+		counter.Reset(context.Background(), userID)
+	}
+	return nil
 }
 
 // GetPaginatedNotifications 获取用户的分页通知
@@ -109,5 +163,15 @@ func (s *InternalNotificationService) GetOne(userID uint, notificationID uint) (
 }
 
 func (s *InternalNotificationService) Delete(userID uint, notificationID uint) error {
+	result := s.DB.Where("user_id = ? AND id = ? AND read = ?", userID, notificationID, false).Delete(&InternalNotification{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		if counter := GetUnreadCounter(); counter != nil {
+			counter.Decr(context.Background(), userID, result.RowsAffected)
+		}
+		return nil
+	}
 	return s.DB.Where("user_id = ? AND id = ?", userID, notificationID).Delete(&InternalNotification{}).Error
 }
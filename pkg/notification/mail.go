@@ -138,14 +138,21 @@ func (m *MailNotification) SendWelcomeEmail(to string, username string, verifyUR
 }
 
 func (m *MailNotification) SendVerificationCode(to, code string) error {
+	return m.SendVerificationCodeWithReport(to, code, "")
+}
+
+// SendVerificationCodeWithReport 发送验证码邮件，并附上收件人举报未请求邮件时使用的一次性令牌
+func (m *MailNotification) SendVerificationCodeWithReport(to, code, reportToken string) error {
 	tmpl, err := template.New("verification").Parse(hibiscusIM.VerificationHTML)
 	if err != nil {
 		return fmt.Errorf("failed to parse verification template: %w", err)
 	}
 	data := struct {
-		Code string
+		Code        string
+		ReportToken string
 	}{
-		Code: code,
+		Code:        code,
+		ReportToken: reportToken,
 	}
 	var body bytes.Buffer
 	if err := tmpl.Execute(&body, data); err != nil {
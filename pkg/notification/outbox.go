@@ -0,0 +1,263 @@
+package notification
+
+import (
+	"HibiscusIM/pkg/logger"
+	"HibiscusIM/pkg/queue"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 发件箱记录状态
+const (
+	OutboxPending    = "pending"    // 已随业务事务提交，等待投递
+	OutboxEnqueued   = "enqueued"   // 已放入任务队列
+	OutboxDispatched = "dispatched" // 已完成分发
+	OutboxFailed     = "failed"     // 重试耗尽
+)
+
+// TaskTypeOutbox 发件箱投递任务类型
+const TaskTypeOutbox = "notification.outbox"
+
+const (
+	// outboxClaimTimeout 已入队但超过该时间仍未完成的记录重新投递，覆盖进程退出导致的任务丢失
+	outboxClaimTimeout = 5 * time.Minute
+	// outboxBatchSize 每次轮询投递的记录数
+	outboxBatchSize = 100
+)
+
+// NotificationOutbox 通知发件箱，与站内通知在同一事务中写入，提交后由 OutboxRelay 异步分发
+type NotificationOutbox struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	NotificationID uint       `json:"notification_id" gorm:"uniqueIndex"`
+	UserID         uint       `json:"user_id" gorm:"index"`
+	Status         string     `json:"status" gorm:"size:16;index"`
+	Counted        bool       `json:"counted"` // 是否已计入未读计数，避免重复投递时重复累加
+	Attempts       int        `json:"attempts"`
+	LastError      string     `json:"last_error,omitempty" gorm:"size:512"`
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	DispatchedAt   *time.Time `json:"dispatched_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// outboxTask 投递一条发件箱记录
+type outboxTask struct {
+	OutboxID uint `json:"outbox_id"`
+}
+
+// Type 实现 queue.Task
+func (outboxTask) Type() string {
+	return TaskTypeOutbox
+}
+
+// WriteOutbox 在事务 tx 中写入站内通知及其发件箱记录，事务回滚时两者一并丢弃
+func WriteOutbox(tx *gorm.DB, userID uint, title, content string) (*InternalNotification, error) {
+	n := &InternalNotification{UserID: userID, Title: title, Content: content, CreatedAt: time.Now()}
+	if err := tx.Create(n).Error; err != nil {
+		return nil, err
+	}
+	entry := NotificationOutbox{NotificationID: n.ID, UserID: userID, Status: OutboxPending, CreatedAt: n.CreatedAt}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// SendTx 在调用方的事务中创建站内通知，事务提交后由发件箱投递器分发
+func (s *InternalNotificationService) SendTx(tx *gorm.DB, userID uint, title, content string) (*InternalNotification, error) {
+	return WriteOutbox(tx, userID, title, content)
+}
+
+var (
+	outboxRelay   *OutboxRelay
+	outboxRelayMu sync.RWMutex
+)
+
+// SetOutboxRelay 设置全局发件箱投递器，设置后 Send 经由发件箱分发
+func SetOutboxRelay(r *OutboxRelay) {
+	outboxRelayMu.Lock()
+	defer outboxRelayMu.Unlock()
+	outboxRelay = r
+}
+
+// GetOutboxRelay 获取全局发件箱投递器，未设置时返回 nil
+func GetOutboxRelay() *OutboxRelay {
+	outboxRelayMu.RLock()
+	defer outboxRelayMu.RUnlock()
+	return outboxRelay
+}
+
+// OutboxRelay 发件箱投递器：将已提交的发件箱记录放入任务队列，由队列 worker 计入未读数并分发。
+// 记录状态按条件更新推进，重复投递的任务遇到已分发的记录直接跳过
+type OutboxRelay struct {
+	db         *gorm.DB
+	queue      *queue.Queue
+	dispatcher func() *Dispatcher
+}
+
+// NewOutboxRelay 创建发件箱投递器，q 为 nil 时在投递时直接分发
+func NewOutboxRelay(db *gorm.DB, q *queue.Queue) *OutboxRelay {
+	r := &OutboxRelay{db: db, queue: q, dispatcher: GetDispatcher}
+	if q != nil {
+		q.Register(TaskTypeOutbox, func(ctx context.Context, msg *queue.Message) error {
+			var task outboxTask
+			if err := msg.Decode(&task); err != nil {
+				return err
+			}
+			// 队列已到最后一次尝试时，失败后记录标记为 failed，不再由轮询重新投递
+			return r.process(ctx, task.OutboxID, msg.Attempt >= msg.MaxRetry)
+		})
+	}
+	return r
+}
+
+// Publish 事务提交后立即投递通知对应的发件箱记录，失败时由 Poll 兜底
+func (r *OutboxRelay) Publish(ctx context.Context, notificationID uint) error {
+	var entry NotificationOutbox
+	if err := r.db.WithContext(ctx).Where("notification_id = ?", notificationID).First(&entry).Error; err != nil {
+		return err
+	}
+	if r.queue == nil {
+		go func() {
+			if err := r.deliver(context.Background(), entry.ID); err != nil {
+				logger.Warn("deliver notification outbox failed", zap.Uint("outbox_id", entry.ID), zap.Error(err))
+			}
+		}()
+		return nil
+	}
+	_, err := r.claim(ctx, entry.ID)
+	return err
+}
+
+// Poll 投递待处理的发件箱记录，并将入队后长时间未完成的记录重新投递，返回投递数
+func (r *OutboxRelay) Poll(ctx context.Context) (int, error) {
+	db := r.db.WithContext(ctx)
+	if err := db.Model(&NotificationOutbox{}).
+		Where("status = ? AND claimed_at < ?", OutboxEnqueued, time.Now().Add(-outboxClaimTimeout)).
+		Update("status", OutboxPending).Error; err != nil {
+		return 0, err
+	}
+	var ids []uint
+	if err := db.Model(&NotificationOutbox{}).Where("status = ?", OutboxPending).
+		Order("id").Limit(outboxBatchSize).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if r.queue == nil {
+			if err := r.deliver(ctx, id); err != nil {
+				logger.Warn("deliver notification outbox failed", zap.Uint("outbox_id", id), zap.Error(err))
+				continue
+			}
+			delivered++
+			continue
+		}
+		ok, err := r.claim(ctx, id)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// claim 将待处理记录标记为已入队并放入队列，记录已被其他实例认领时返回 false
+func (r *OutboxRelay) claim(ctx context.Context, id uint) (bool, error) {
+	now := time.Now()
+	res := r.db.WithContext(ctx).Model(&NotificationOutbox{}).
+		Where("id = ? AND status = ?", id, OutboxPending).
+		Updates(map[string]any{"status": OutboxEnqueued, "claimed_at": now})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+	if _, err := r.queue.Enqueue(ctx, outboxTask{OutboxID: id}); err != nil {
+		r.db.Model(&NotificationOutbox{}).Where("id = ? AND status = ?", id, OutboxEnqueued).Update("status", OutboxPending)
+		return false, err
+	}
+	return true, nil
+}
+
+// deliver 不经过队列直接分发，失败的记录保持待处理由下次轮询重试
+func (r *OutboxRelay) deliver(ctx context.Context, id uint) error {
+	res := r.db.WithContext(ctx).Model(&NotificationOutbox{}).
+		Where("id = ? AND status = ?", id, OutboxPending).
+		Updates(map[string]any{"status": OutboxEnqueued, "claimed_at": time.Now()})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+	err := r.process(ctx, id, false)
+	if err != nil {
+		r.db.Model(&NotificationOutbox{}).Where("id = ? AND status = ?", id, OutboxEnqueued).Update("status", OutboxPending)
+	}
+	return err
+}
+
+// process 计入未读数并分发一条发件箱记录，已分发的记录直接跳过
+func (r *OutboxRelay) process(ctx context.Context, id uint, final bool) error {
+	db := r.db.WithContext(ctx)
+	var entry NotificationOutbox
+	if err := db.First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if entry.Status == OutboxDispatched || entry.Status == OutboxFailed {
+		return nil
+	}
+	var n InternalNotification
+	if err := db.First(&n, entry.NotificationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 通知已被删除，无需分发
+			return r.markDispatched(ctx, id)
+		}
+		return r.fail(ctx, id, final, err)
+	}
+
+	if !entry.Counted {
+		res := db.Model(&NotificationOutbox{}).Where("id = ? AND counted = ?", id, false).Update("counted", true)
+		if res.Error != nil {
+			return r.fail(ctx, id, final, res.Error)
+		}
+		if counter := GetUnreadCounter(); counter != nil && res.RowsAffected > 0 && !n.Read {
+			counter.Incr(ctx, n.UserID, 1)
+		}
+	}
+	if d := r.dispatcher(); d != nil {
+		if _, err := d.Dispatch(ctx, &n); err != nil {
+			return r.fail(ctx, id, final, err)
+		}
+	}
+	return r.markDispatched(ctx, id)
+}
+
+func (r *OutboxRelay) markDispatched(ctx context.Context, id uint) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&NotificationOutbox{}).Where("id = ?", id).
+		Updates(map[string]any{"status": OutboxDispatched, "dispatched_at": now}).Error
+}
+
+// fail 记录失败原因，final 为 true 时标记为 failed
+func (r *OutboxRelay) fail(ctx context.Context, id uint, final bool, cause error) error {
+	msg := cause.Error()
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	updates := map[string]any{"attempts": gorm.Expr("attempts + 1"), "last_error": msg}
+	if final {
+		updates["status"] = OutboxFailed
+	}
+	if err := r.db.Model(&NotificationOutbox{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		logger.Warn("update notification outbox failed", zap.Uint("outbox_id", id), zap.Error(err))
+	}
+	return cause
+}
@@ -0,0 +1,79 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestOutboxRelay(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:outbox?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&InternalNotification{}, &NotificationOutbox{}, &NotificationPreference{}))
+	db.Where("1 = 1").Delete(&InternalNotification{})
+	db.Where("1 = 1").Delete(&NotificationOutbox{})
+
+	ctx := context.Background()
+	ws := &fakePusher{online: map[uint]bool{1: true}}
+	d := NewDispatcher(db)
+	d.AddPusher(ChannelWebSocket, ws)
+	relay := NewOutboxRelay(db, nil)
+	relay.dispatcher = func() *Dispatcher { return d }
+	service := NewInternalNotificationService(db)
+
+	// 事务回滚时通知与发件箱记录一并丢弃
+	rollback := errors.New("rollback")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := service.SendTx(tx, 1, "dropped", "")
+		require.NoError(t, err)
+		return rollback
+	})
+	require.ErrorIs(t, err, rollback)
+	var count int64
+	db.Model(&NotificationOutbox{}).Count(&count)
+	assert.Zero(t, count)
+
+	var n *InternalNotification
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) (err error) {
+		n, err = service.SendTx(tx, 1, "hello", "world")
+		return err
+	}))
+
+	// 提交后由轮询分发，再次轮询不会重复分发
+	delivered, err := relay.Poll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	delivered, err = relay.Poll(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, delivered)
+	assert.Equal(t, []uint{n.ID}, ws.pushed)
+
+	var entry NotificationOutbox
+	require.NoError(t, db.Where("notification_id = ?", n.ID).First(&entry).Error)
+	assert.Equal(t, OutboxDispatched, entry.Status)
+	assert.True(t, entry.Counted)
+	assert.NotNil(t, entry.DispatchedAt)
+
+	// 重复投递的任务遇到已分发的记录直接跳过
+	require.NoError(t, relay.process(ctx, entry.ID, false))
+	assert.Equal(t, []uint{n.ID}, ws.pushed)
+
+	// 入队后长时间未完成的记录重新投递
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) (err error) {
+		n, err = service.SendTx(tx, 1, "stuck", "")
+		return err
+	}))
+	stale := time.Now().Add(-2 * outboxClaimTimeout)
+	require.NoError(t, db.Model(&NotificationOutbox{}).Where("notification_id = ?", n.ID).
+		Updates(map[string]any{"status": OutboxEnqueued, "claimed_at": stale}).Error)
+	delivered, err = relay.Poll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	assert.Len(t, ws.pushed, 2)
+}
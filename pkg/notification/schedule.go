@@ -0,0 +1,192 @@
+package notification
+
+import (
+	"HibiscusIM/pkg/logger"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// LocalTimeLayout 定时通知的当地时间格式
+const LocalTimeLayout = "2006-01-02 15:04"
+
+// ScheduledNotification 定时发送的站内通知，按用户当地时间预约，DeliverAt 为换算后的 UTC 时间
+type ScheduledNotification struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`           // 定时通知 ID
+	UserID    uint       `json:"user_id" gorm:"index"`           // 用户 ID
+	Title     string     `json:"title"`                          // 通知标题
+	Content   string     `json:"content"`                        // 通知内容
+	LocalTime string     `json:"local_time" gorm:"size:16"`      // 预约的用户当地时间
+	Timezone  string     `json:"timezone" gorm:"size:64"`        // 预约时用户的时区
+	DeliverAt time.Time  `json:"deliver_at" gorm:"index"`        // 发送时间
+	SentAt    *time.Time `json:"sent_at,omitempty" gorm:"index"` // 实际发送时间，未发送时为空
+	CreatedAt time.Time  `json:"created_at"`                     // 创建时间
+}
+
+// ParseLocalTime 将 loc 时区的当地时间（格式 2006-01-02 15:04）换算为绝对时间；
+// 夏令时跳过的时刻顺延到跳变之后，重复的时刻取第一次出现
+func ParseLocalTime(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	t, err := time.ParseInLocation(LocalTimeLayout, strings.TrimSpace(value), loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid local time %q: %w", value, err)
+	}
+	return t, nil
+}
+
+// ScheduleLocal 预约在用户当地时间 localTime 发送站内通知，loc 为用户时区
+func (s *InternalNotificationService) ScheduleLocal(userID uint, title, content, localTime string, loc *time.Location) (*ScheduledNotification, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	at, err := ParseLocalTime(localTime, loc)
+	if err != nil {
+		return nil, err
+	}
+	scheduled := &ScheduledNotification{
+		UserID:    userID,
+		Title:     title,
+		Content:   content,
+		LocalTime: at.Format(LocalTimeLayout),
+		Timezone:  loc.String(),
+		DeliverAt: at.UTC(),
+		CreatedAt: time.Now(),
+	}
+	if err := s.DB.Create(scheduled).Error; err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// CancelScheduled 取消用户尚未发送的定时通知
+func (s *InternalNotificationService) CancelScheduled(userID, id uint) error {
+	return s.DB.Where("id = ? AND user_id = ? AND sent_at IS NULL", id, userID).Delete(&ScheduledNotification{}).Error
+}
+
+// DeliverDue 发送截至 now 已到期的定时通知，返回发送数；先标记再发送，多实例同时执行时每条只发送一次
+func (s *InternalNotificationService) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	// 发送时间统一按 UTC 保存与比较，避免按文本保存时间的数据库因时区偏移比较出错
+	now = now.UTC()
+	var due []ScheduledNotification
+	if err := s.DB.WithContext(ctx).Where("sent_at IS NULL AND deliver_at <= ?", now).
+		Order("deliver_at").Limit(500).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, n := range due {
+		res := s.DB.WithContext(ctx).Model(&ScheduledNotification{}).
+			Where("id = ? AND sent_at IS NULL", n.ID).Update("sent_at", now)
+		if res.Error != nil {
+			return sent, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		if err := s.Send(n.UserID, n.Title, n.Content); err != nil {
+			logger.Warn("deliver scheduled notification failed", zap.Uint("id", n.ID), zap.Uint("user_id", n.UserID), zap.Error(err))
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// digestMaxItems 摘要邮件最多列出的通知数
+const digestMaxItems = 20
+
+// SendDigests 为开启每日摘要的用户在其当地时间 DigestHour 之后发送一封未读通知摘要邮件，每个当地日最多一次；
+// location 返回用户时区，缺失的用户使用服务器时区。未配置邮件发送时不做任何处理
+func (d *Dispatcher) SendDigests(ctx context.Context, now time.Time, location func(userIDs []uint) map[uint]*time.Location) (int, error) {
+	d.mu.RLock()
+	mailer, emailOf := d.mailer, d.emailOf
+	d.mu.RUnlock()
+	if mailer == nil || emailOf == nil {
+		return 0, nil
+	}
+
+	sent := 0
+	var prefs []NotificationPreference
+	err := d.db.WithContext(ctx).Where("digest = ?", true).FindInBatches(&prefs, 200, func(tx *gorm.DB, batch int) error {
+		ids := make([]uint, len(prefs))
+		for i := range prefs {
+			ids[i] = prefs[i].UserID
+		}
+		var locs map[uint]*time.Location
+		if location != nil {
+			locs = location(ids)
+		}
+		for _, pref := range prefs {
+			loc := locs[pref.UserID]
+			if loc == nil {
+				loc = time.Local
+			}
+			due := digestDue(now, loc, pref.DigestHour)
+			if now.Before(due) || (pref.LastDigestAt != nil && !pref.LastDigestAt.Before(due)) {
+				continue
+			}
+			ok, err := d.sendDigest(ctx, pref, due, mailer, emailOf)
+			if err != nil {
+				logger.Warn("send notification digest failed", zap.Uint("user_id", pref.UserID), zap.Error(err))
+				continue
+			}
+			if ok {
+				sent++
+			}
+			if err := d.db.WithContext(ctx).Model(&NotificationPreference{}).
+				Where("user_id = ?", pref.UserID).Update("last_digest_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	return sent, err
+}
+
+// digestDue 用户当地日的摘要发送时间
+func digestDue(now time.Time, loc *time.Location, hour int) time.Time {
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), min(max(hour, 0), 23), 0, 0, 0, loc)
+}
+
+// sendDigest 汇总上次摘要（首次为 24 小时内）以来的未读通知，没有未读通知时不发送
+func (d *Dispatcher) sendDigest(ctx context.Context, pref NotificationPreference, due time.Time, mailer Mailer, emailOf func(uint) (string, error)) (bool, error) {
+	since := due.AddDate(0, 0, -1)
+	if pref.LastDigestAt != nil {
+		since = *pref.LastDigestAt
+	}
+	// 通知的创建时间按服务器时区写入，换算到同一时区再比较
+	since = since.In(time.Local)
+	var unread []InternalNotification
+	var total int64
+	query := func() *gorm.DB {
+		return d.db.WithContext(ctx).Model(&InternalNotification{}).
+			Where("user_id = ? AND read = ? AND created_at > ?", pref.UserID, false, since)
+	}
+	if err := query().Count(&total).Error; err != nil || total == 0 {
+		return false, err
+	}
+	if err := query().Order("created_at DESC").Limit(digestMaxItems).Find(&unread).Error; err != nil {
+		return false, err
+	}
+	to, err := emailOf(pref.UserID)
+	if err != nil || to == "" {
+		return false, err
+	}
+	var body strings.Builder
+	for _, n := range unread {
+		fmt.Fprintf(&body, "- %s\n", n.Title)
+	}
+	if int(total) > len(unread) {
+		fmt.Fprintf(&body, "... 以及其他 %d 条\n", int(total)-len(unread))
+	}
+	if err := mailer.Send(to, fmt.Sprintf("您有 %d 条未读通知", total), body.String()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseLocalTimeDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	at, err := ParseLocalTime("2026-03-07 09:00", ny)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 7, 14, 0, 0, 0, time.UTC), at.UTC())
+	// 夏令时开始后同一当地时间对应的 UTC 时间提前一小时
+	at, err = ParseLocalTime("2026-03-08 09:00", ny)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 8, 13, 0, 0, 0, time.UTC), at.UTC())
+
+	_, err = ParseLocalTime("tomorrow", ny)
+	assert.Error(t, err)
+}
+
+func TestScheduleLocalAndDeliver(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:scheduled_notification?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&InternalNotification{}, &ScheduledNotification{}))
+	require.NoError(t, db.Where("1 = 1").Delete(&InternalNotification{}).Error)
+	require.NoError(t, db.Where("1 = 1").Delete(&ScheduledNotification{}).Error)
+
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+	s := NewInternalNotificationService(db)
+	n, err := s.ScheduleLocal(7, "reminder", "stand up", "2026-05-01 09:00", shanghai)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 5, 1, 1, 0, 0, 0, time.UTC), n.DeliverAt.UTC())
+	assert.Equal(t, "Asia/Shanghai", n.Timezone)
+
+	ctx := context.Background()
+	sent, err := s.DeliverDue(ctx, time.Date(2026, 5, 1, 0, 59, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	sent, err = s.DeliverDue(ctx, time.Date(2026, 5, 1, 1, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	// 已发送的定时通知不会重复发送
+	sent, err = s.DeliverDue(ctx, time.Date(2026, 5, 1, 2, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	unread, err := s.GetUnreadNotifications(7)
+	require.NoError(t, err)
+	require.Len(t, unread, 1)
+	assert.Equal(t, "reminder", unread[0].Title)
+}
+
+func TestSendDigestsInUserTimezone(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:notification_digest?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&InternalNotification{}, &NotificationPreference{}))
+	require.NoError(t, db.Where("1 = 1").Delete(&InternalNotification{}).Error)
+	require.NoError(t, db.Where("1 = 1").Delete(&NotificationPreference{}).Error)
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	locs := map[uint]*time.Location{1: tokyo, 2: ny}
+	locate := func(ids []uint) map[uint]*time.Location { return locs }
+
+	ctx := context.Background()
+	created := time.Date(2026, 6, 1, 20, 0, 0, 0, time.UTC)
+	for _, uid := range []uint{1, 2} {
+		require.NoError(t, db.Create(&InternalNotification{UserID: uid, Title: "hello", CreatedAt: created}).Error)
+		pref := DefaultPreference(uid)
+		pref.Digest = true
+		if uid == 2 {
+			pref.DigestHour = 20
+		}
+		require.NoError(t, db.Create(&pref).Error)
+	}
+
+	mailer := &fakeMailer{}
+	d := NewDispatcher(db)
+	d.SetMailFallback(mailer, func(userID uint) (string, error) {
+		return map[uint]string{1: "tokyo@example.com", 2: "ny@example.com"}[userID], nil
+	})
+
+	// 23:00 UTC 为东京次日 8 点、纽约 19 点：只有东京用户到了摘要时间，纽约用户在当地 20 点发送
+	now := time.Date(2026, 6, 1, 23, 0, 0, 0, time.UTC)
+	sent, err := d.SendDigests(ctx, now, locate)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"tokyo@example.com:您有 1 条未读通知"}, mailer.sent)
+
+	// 同一当地日不重复发送
+	sent, err = d.SendDigests(ctx, now.Add(30*time.Minute), locate)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	// 纽约当地 20 点（次日 0:00 UTC）发送
+	sent, err = d.SendDigests(ctx, time.Date(2026, 6, 2, 0, 0, 0, 0, time.UTC), locate)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, "ny@example.com:您有 1 条未读通知", mailer.sent[1])
+}
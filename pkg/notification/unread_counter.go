@@ -0,0 +1,221 @@
+package notification
+
+import (
+	"HibiscusIM/pkg/cache"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+	"gorm.io/gorm"
+)
+
+// UnreadCounter 基于缓存增量维护的用户未读通知计数
+type UnreadCounter struct {
+	db    *gorm.DB
+	cache cache.Cache
+	ttl   time.Duration
+
+	mu       sync.RWMutex
+	onChange func(userID uint, count int64)
+
+	// 本节点写入过缓存计数的用户，ReconcileAll 据此归零已没有未读通知的用户
+	trackMu sync.Mutex
+	tracked map[uint]struct{}
+}
+
+var (
+	globalCounter   *UnreadCounter
+	globalCounterMu sync.RWMutex
+)
+
+// SetUnreadCounter 设置全局未读计数器
+func SetUnreadCounter(c *UnreadCounter) {
+	globalCounterMu.Lock()
+	defer globalCounterMu.Unlock()
+	globalCounter = c
+}
+
+// GetUnreadCounter 获取全局未读计数器
+func GetUnreadCounter() *UnreadCounter {
+	globalCounterMu.RLock()
+	defer globalCounterMu.RUnlock()
+	return globalCounter
+}
+
+// NewUnreadCounter 创建未读计数器，ttl 为计数缓存时长，过期后从数据库重新统计
+func NewUnreadCounter(db *gorm.DB, c cache.Cache, ttl time.Duration) *UnreadCounter {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &UnreadCounter{db: db, cache: c, ttl: ttl, tracked: make(map[uint]struct{})}
+}
+
+// OnChange 设置计数变化回调，用于通过 WS/SSE 推送角标更新
+func (u *UnreadCounter) OnChange(fn func(userID uint, count int64)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.onChange = fn
+}
+
+func (u *UnreadCounter) key(userID uint) string {
+	return fmt.Sprintf("notification:unread:%d", userID)
+}
+
+// set 写入缓存计数并记录该用户
+func (u *UnreadCounter) set(ctx context.Context, userID uint, count int64) {
+	_ = u.cache.Set(ctx, u.key(userID), count, u.ttl)
+	u.trackMu.Lock()
+	u.tracked[userID] = struct{}{}
+	u.trackMu.Unlock()
+}
+
+// trackedUsers 本节点写入过缓存计数的用户
+func (u *UnreadCounter) trackedUsers() []uint {
+	u.trackMu.Lock()
+	defer u.trackMu.Unlock()
+	ids := make([]uint, 0, len(u.tracked))
+	for id := range u.tracked {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// untrack 缓存计数已过期的用户不再跟踪
+func (u *UnreadCounter) untrack(userID uint) {
+	u.trackMu.Lock()
+	delete(u.tracked, userID)
+	u.trackMu.Unlock()
+}
+
+// notify 触发计数变化回调
+func (u *UnreadCounter) notify(userID uint, count int64) {
+	u.mu.RLock()
+	fn := u.onChange
+	u.mu.RUnlock()
+	if fn != nil {
+		fn(userID, count)
+	}
+}
+
+// Get 获取未读数，缓存未命中时从数据库统计并回填
+func (u *UnreadCounter) Get(ctx context.Context, userID uint) (int64, error) {
+	if v, ok := u.cache.Get(ctx, u.key(userID)); ok {
+		if n, err := cast.ToInt64E(v); err == nil {
+			return n, nil
+		}
+	}
+	return u.Reconcile(ctx, userID)
+}
+
+// Incr 增加未读数，缓存中没有计数时跳过，下次读取时再统计
+func (u *UnreadCounter) Incr(ctx context.Context, userID uint, delta int64) {
+	key := u.key(userID)
+	if !u.cache.Exists(ctx, key) {
+		u.pushFresh(ctx, userID)
+		return
+	}
+	n, err := u.cache.Increment(ctx, key, delta)
+	if err != nil || n < 0 {
+		// 计数异常时以数据库为准
+		u.pushFresh(ctx, userID)
+		return
+	}
+	u.notify(userID, n)
+}
+
+// Decr 减少未读数
+func (u *UnreadCounter) Decr(ctx context.Context, userID uint, delta int64) {
+	u.Incr(ctx, userID, -delta)
+}
+
+// Reset 清零未读数
+func (u *UnreadCounter) Reset(ctx context.Context, userID uint) {
+	u.set(ctx, userID, 0)
+	u.notify(userID, 0)
+}
+
+// Reconcile 从数据库重新统计单个用户的未读数
+func (u *UnreadCounter) Reconcile(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := u.db.WithContext(ctx).Model(&InternalNotification{}).
+		Where("user_id = ? AND read = ?", userID, false).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	u.set(ctx, userID, count)
+	return count, nil
+}
+
+// ReconcileAll 批量校正所有存在未读通知的用户计数，并将本节点缓存过、已没有未读通知的用户计数归零
+func (u *UnreadCounter) ReconcileAll(ctx context.Context) (int, error) {
+	var rows []struct {
+		UserID uint
+		Count  int64
+	}
+	err := u.db.WithContext(ctx).Model(&InternalNotification{}).
+		Select("user_id, COUNT(*) AS count").
+		Where("read = ?", false).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	fixed := 0
+	unread := make(map[uint]bool, len(rows))
+	for _, r := range rows {
+		unread[r.UserID] = true
+		if v, ok := u.cache.Get(ctx, u.key(r.UserID)); ok && cast.ToInt64(v) == r.Count {
+			continue
+		}
+		u.set(ctx, r.UserID, r.Count)
+		u.notify(r.UserID, r.Count)
+		fixed++
+	}
+	// 分组结果只包含仍有未读通知的用户，其余用户的残留计数需要单独归零
+	for _, userID := range u.trackedUsers() {
+		if unread[userID] {
+			continue
+		}
+		v, ok := u.cache.Get(ctx, u.key(userID))
+		if !ok {
+			u.untrack(userID)
+			continue
+		}
+		if cast.ToInt64(v) == 0 {
+			continue
+		}
+		u.set(ctx, userID, 0)
+		u.notify(userID, 0)
+		fixed++
+	}
+	return fixed, nil
+}
+
+// StartReconciler 定期校正计数，直到 ctx 取消
+func (u *UnreadCounter) StartReconciler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = u.ReconcileAll(ctx)
+			}
+		}
+	}()
+}
+
+// pushFresh 从数据库统计并推送最新计数
+func (u *UnreadCounter) pushFresh(ctx context.Context, userID uint) {
+	if n, err := u.Reconcile(ctx, userID); err == nil {
+		u.notify(userID, n)
+	}
+}
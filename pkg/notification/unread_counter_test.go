@@ -0,0 +1,81 @@
+package notification
+
+import (
+	"HibiscusIM/pkg/cache"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUnreadCounter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:unread_counter?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&InternalNotification{}))
+
+	ctx := context.Background()
+	counter := NewUnreadCounter(db, cache.NewLocalCache(cache.LocalConfig{
+		MaxSize:           100,
+		DefaultExpiration: time.Minute,
+		CleanupInterval:   time.Minute,
+	}), time.Minute)
+
+	var mu sync.Mutex
+	pushed := map[uint]int64{}
+	counter.OnChange(func(userID uint, count int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		pushed[userID] = count
+	})
+	SetUnreadCounter(counter)
+	defer SetUnreadCounter(nil)
+
+	service := NewInternalNotificationService(db)
+	require.NoError(t, service.Send(7, "t1", "c1"))
+	require.NoError(t, service.Send(7, "t2", "c2"))
+
+	count, err := service.GetUnreadNotificationsCount(7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// 缓存已存在后增量维护
+	require.NoError(t, service.Send(7, "t3", "c3"))
+	count, _ = counter.Get(ctx, 7)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, int64(3), pushed[7])
+
+	var first InternalNotification
+	require.NoError(t, db.Where("user_id = ?", 7).First(&first).Error)
+	require.NoError(t, service.MarkAsRead(first.ID))
+	// 重复标记不会重复扣减
+	require.NoError(t, service.MarkAsRead(first.ID))
+	count, _ = counter.Get(ctx, 7)
+	assert.Equal(t, int64(2), count)
+
+	require.NoError(t, service.MarkAllAsRead(7))
+	count, _ = counter.Get(ctx, 7)
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, int64(0), pushed[7])
+
+	// 校正：绕过服务直接写库后计数被修正
+	require.NoError(t, db.Create(&InternalNotification{UserID: 7, Title: "raw"}).Error)
+	fixed, err := counter.ReconcileAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fixed)
+	count, _ = counter.Get(ctx, 7)
+	assert.Equal(t, int64(1), count)
+
+	// 校正：用户的未读通知被直接删除后不再出现在分组结果中，计数仍需归零
+	require.NoError(t, db.Where("user_id = ?", 7).Delete(&InternalNotification{}).Error)
+	fixed, err = counter.ReconcileAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fixed)
+	count, _ = counter.Get(ctx, 7)
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, int64(0), pushed[7])
+}
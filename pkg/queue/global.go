@@ -0,0 +1,24 @@
+package queue
+
+import (
+	"sync"
+)
+
+var (
+	globalQueue *Queue
+	mu          sync.RWMutex
+)
+
+// SetGlobalQueue 设置全局任务队列实例
+func SetGlobalQueue(q *Queue) {
+	mu.Lock()
+	defer mu.Unlock()
+	globalQueue = q
+}
+
+// GetGlobalQueue 获取全局任务队列实例
+func GetGlobalQueue() *Queue {
+	mu.RLock()
+	defer mu.RUnlock()
+	return globalQueue
+}
@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryBroker 进程内队列后端，不具备持久化能力，用于开发与测试
+type memoryBroker struct {
+	mu      sync.Mutex
+	pending map[string][]*Message
+	dead    map[string][]*Message
+	notify  chan struct{}
+	closed  bool
+}
+
+// NewMemoryBroker 创建进程内队列后端
+func NewMemoryBroker() Broker {
+	return &memoryBroker{
+		pending: make(map[string][]*Message),
+		dead:    make(map[string][]*Message),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// Enqueue 投递消息
+func (b *memoryBroker) Enqueue(ctx context.Context, msg *Message, delay time.Duration) error {
+	if delay > 0 {
+		time.AfterFunc(delay, func() { _ = b.Enqueue(context.Background(), msg, 0) })
+		return nil
+	}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	b.pending[msg.Queue] = append(b.pending[msg.Queue], msg)
+	b.mu.Unlock()
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Dequeue 获取消息
+func (b *memoryBroker) Dequeue(ctx context.Context, queue string, timeout time.Duration) (*Message, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if list := b.pending[queue]; len(list) > 0 {
+			msg := list[0]
+			b.pending[queue] = list[1:]
+			b.mu.Unlock()
+			return msg, nil
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, ErrNoMessage
+		case <-b.notify:
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// Ack 确认消息
+func (b *memoryBroker) Ack(ctx context.Context, msg *Message) error {
+	return nil
+}
+
+// Retry 延迟重新入队
+func (b *memoryBroker) Retry(ctx context.Context, msg *Message, delay time.Duration) error {
+	return b.Enqueue(ctx, msg, delay)
+}
+
+// DeadLetter 移入死信队列
+func (b *memoryBroker) DeadLetter(ctx context.Context, msg *Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dead[msg.Queue] = append(b.dead[msg.Queue], msg)
+	return nil
+}
+
+// DeadLetters 获取死信消息
+func (b *memoryBroker) DeadLetters(ctx context.Context, queue string, limit int64) ([]*Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := b.dead[queue]
+	if limit > 0 && int64(len(list)) > limit {
+		list = list[:limit]
+	}
+	out := make([]*Message, len(list))
+	copy(out, list)
+	return out, nil
+}
+
+// Len 获取待处理消息数
+func (b *memoryBroker) Len(ctx context.Context, queue string) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int64(len(b.pending[queue])), nil
+}
+
+// Close 关闭后端
+func (b *memoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
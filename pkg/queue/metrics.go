@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	tasksEnqueued = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_tasks_enqueued_total",
+			Help: "Total number of tasks enqueued",
+		},
+		[]string{"queue", "type"},
+	)
+
+	tasksProcessed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "queue_tasks_processed_total",
+			Help: "Total number of tasks processed",
+		},
+		[]string{"queue", "type", "status"},
+	)
+
+	taskDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "queue_task_duration_seconds",
+			Help:    "Task processing duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"queue", "type"},
+	)
+
+	tasksInflight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "queue_tasks_inflight",
+			Help: "Number of tasks currently being processed",
+		},
+		[]string{"queue"},
+	)
+)
+
+const (
+	statusSuccess = "success"
+	statusRetry   = "retry"
+	statusDead    = "dead"
+)
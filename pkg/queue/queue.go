@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"HibiscusIM/pkg/util"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNoMessage 队列暂无可消费消息
+	ErrNoMessage = errors.New("queue: no message")
+	// ErrClosed 队列已关闭
+	ErrClosed = errors.New("queue: closed")
+	// ErrNoHandler 任务类型未注册处理器
+	ErrNoHandler = errors.New("queue: no handler registered")
+)
+
+// Task 后台任务，Type 用于路由到对应处理器，任务本身以JSON形式持久化
+type Task interface {
+	Type() string
+}
+
+// Message 队列中传输的任务信封
+type Message struct {
+	ID         string          `json:"id"`
+	Queue      string          `json:"queue"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempt    int             `json:"attempt"`
+	MaxRetry   int             `json:"max_retry"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	LastError  string          `json:"last_error,omitempty"`
+}
+
+// Decode 将消息负载解析到目标任务
+func (m *Message) Decode(v any) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+// HandlerFunc 任务处理函数，返回错误时按退避策略重试
+type HandlerFunc func(ctx context.Context, msg *Message) error
+
+// Broker 队列存储后端
+type Broker interface {
+	// Enqueue 投递消息，delay>0 时延迟投递
+	Enqueue(ctx context.Context, msg *Message, delay time.Duration) error
+	// Dequeue 阻塞获取消息，超时返回 ErrNoMessage
+	Dequeue(ctx context.Context, queue string, timeout time.Duration) (*Message, error)
+	// Ack 确认消息处理完成
+	Ack(ctx context.Context, msg *Message) error
+	// Retry 确认当前投递并延迟重新入队
+	Retry(ctx context.Context, msg *Message, delay time.Duration) error
+	// DeadLetter 确认当前投递并移入死信队列
+	DeadLetter(ctx context.Context, msg *Message) error
+	// DeadLetters 获取死信队列中的消息
+	DeadLetters(ctx context.Context, queue string, limit int64) ([]*Message, error)
+	// Len 获取待处理消息数
+	Len(ctx context.Context, queue string) (int64, error)
+	// Close 关闭后端
+	Close() error
+}
+
+// Option 任务投递选项
+type Option func(m *Message, delay *time.Duration)
+
+// WithDelay 延迟投递
+func WithDelay(d time.Duration) Option {
+	return func(m *Message, delay *time.Duration) {
+		*delay = d
+	}
+}
+
+// WithMaxRetry 覆盖默认最大重试次数
+func WithMaxRetry(n int) Option {
+	return func(m *Message, delay *time.Duration) {
+		m.MaxRetry = n
+	}
+}
+
+// NewBroker 根据后端类型创建队列后端："redis" 或 "memory"
+func NewBroker(backend string, cfg RedisConfig) (Broker, error) {
+	switch backend {
+	case "redis":
+		return NewRedisBroker(cfg)
+	case "", "memory":
+		return NewMemoryBroker(), nil
+	default:
+		return nil, fmt.Errorf("unsupported queue backend: %s", backend)
+	}
+}
+
+// generateMessageID 生成消息ID
+func generateMessageID() string {
+	return fmt.Sprintf("msg_%d", util.SnowflakeUtil.NextID())
+}
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTask struct {
+	Value string `json:"value"`
+}
+
+func (t testTask) Type() string { return "test" }
+
+func newTestQueue(maxRetry int) *Queue {
+	return NewQueue(NewMemoryBroker(), Config{
+		Name:        "test",
+		Concurrency: 2,
+		MaxRetry:    maxRetry,
+		BaseBackoff: 10 * time.Millisecond,
+		MaxBackoff:  20 * time.Millisecond,
+		PollTimeout: 50 * time.Millisecond,
+	})
+}
+
+func TestQueueProcess(t *testing.T) {
+	q := newTestQueue(0)
+	done := make(chan string, 1)
+	q.Register("test", func(ctx context.Context, msg *Message) error {
+		var task testTask
+		require.NoError(t, msg.Decode(&task))
+		done <- task.Value
+		return nil
+	})
+	q.Start(context.Background())
+	defer q.Stop()
+
+	_, err := q.Enqueue(context.Background(), testTask{Value: "hello"})
+	require.NoError(t, err)
+
+	select {
+	case v := <-done:
+		assert.Equal(t, "hello", v)
+	case <-time.After(2 * time.Second):
+		t.Fatal("任务未执行")
+	}
+}
+
+func TestQueueRetryAndDeadLetter(t *testing.T) {
+	q := newTestQueue(2)
+	var calls int32
+	q.Register("test", func(ctx context.Context, msg *Message) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("boom")
+	})
+	q.Start(context.Background())
+	defer q.Stop()
+
+	_, err := q.Enqueue(context.Background(), testTask{Value: "fail"})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		dead, _ := q.Broker().DeadLetters(context.Background(), "test", 10)
+		return len(dead) == 1
+	}, 2*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	dead, _ := q.Broker().DeadLetters(context.Background(), "test", 10)
+	assert.Equal(t, 3, dead[0].Attempt)
+	assert.Equal(t, "boom", dead[0].LastError)
+}
+
+func TestQueueBackoff(t *testing.T) {
+	q := NewQueue(NewMemoryBroker(), Config{Name: "test", BaseBackoff: time.Second, MaxBackoff: 4 * time.Second})
+	assert.GreaterOrEqual(t, q.backoff(1), time.Second)
+	assert.GreaterOrEqual(t, q.backoff(3), 4*time.Second)
+	assert.LessOrEqual(t, q.backoff(10), 4*time.Second+4*time.Second/5)
+}
+
+func TestGenerateMessageIDUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := generateMessageID()
+		require.False(t, seen[id], "重复的消息ID: %s", id)
+		seen[id] = true
+	}
+}
+
+// newTestRedisBroker 连接 QUEUE_TEST_REDIS_ADDR 指定的 Redis，未设置时跳过
+func newTestRedisBroker(t *testing.T, prefix, consumer string) *redisBroker {
+	addr := os.Getenv("QUEUE_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("QUEUE_TEST_REDIS_ADDR not set")
+	}
+	b, err := NewRedisBroker(RedisConfig{Addr: addr, Prefix: prefix, ConsumerID: consumer, LeaseTTL: time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = b.Close() })
+	return b.(*redisBroker)
+}
+
+func TestRedisBrokerReapLostConsumer(t *testing.T) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("queue_test_%d", time.Now().UnixNano())
+	lost := newTestRedisBroker(t, prefix, "lost")
+	alive := newTestRedisBroker(t, prefix, "alive")
+	t.Cleanup(func() {
+		keys, _ := alive.client.Keys(ctx, prefix+":*").Result()
+		if len(keys) > 0 {
+			alive.client.Del(ctx, keys...)
+		}
+	})
+
+	_, err := lost.Recover(ctx, "test")
+	require.NoError(t, err)
+	require.NoError(t, lost.Enqueue(ctx, &Message{ID: generateMessageID(), Queue: "test", Type: "test"}, 0))
+	_, err = lost.Dequeue(ctx, "test", time.Second)
+	require.NoError(t, err)
+
+	// 租约未过期时不回收
+	n, err := alive.Reap(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	require.NoError(t, alive.client.Del(ctx, lost.aliveKey("test", "lost")).Err())
+	n, err = alive.Reap(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	pending, err := alive.Len(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending)
+}
@@ -0,0 +1,304 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisConfig Redis队列后端配置
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+	// 键前缀
+	Prefix string
+	// 消费者ID，用于隔离各节点的处理中列表，默认 hostname-pid
+	ConsumerID string
+	// 消费者存活租约，超过该时长未续约的消费者视为失联，其处理中消息由其他节点放回待处理列表，默认 1 分钟
+	LeaseTTL time.Duration
+}
+
+// promoteScript 将到期的延迟消息移入待处理列表
+var promoteScript = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 100)
+for _, v in ipairs(items) do
+	redis.call('ZREM', KEYS[1], v)
+	redis.call('LPUSH', KEYS[2], v)
+end
+return #items
+`)
+
+// redisBroker 基于Redis列表的持久化队列后端
+//
+// 键布局：
+//
+//	{prefix}:{queue}                        待处理列表
+//	{prefix}:{queue}:delayed                延迟/重试有序集合
+//	{prefix}:{queue}:processing:{consumer}  处理中列表
+//	{prefix}:{queue}:alive:{consumer}       消费者存活租约
+//	{prefix}:{queue}:dead                   死信列表
+type redisBroker struct {
+	client   *redis.Client
+	prefix   string
+	consumer string
+	leaseTTL time.Duration
+	// 消息ID到原始数据的映射，用于 Ack 时从处理中列表移除
+	inflight map[string]string
+	mu       sync.Mutex
+}
+
+// NewRedisBroker 创建Redis队列后端
+func NewRedisBroker(cfg RedisConfig) (Broker, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	if cfg.Prefix == "" {
+		cfg.Prefix = "queue"
+	}
+	if cfg.ConsumerID == "" {
+		host, _ := os.Hostname()
+		cfg.ConsumerID = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = time.Minute
+	}
+	return &redisBroker{
+		client:   client,
+		prefix:   cfg.Prefix,
+		consumer: cfg.ConsumerID,
+		leaseTTL: cfg.LeaseTTL,
+		inflight: make(map[string]string),
+	}, nil
+}
+
+func (b *redisBroker) pendingKey(queue string) string {
+	return b.prefix + ":" + queue
+}
+
+func (b *redisBroker) delayedKey(queue string) string {
+	return b.prefix + ":" + queue + ":delayed"
+}
+
+func (b *redisBroker) processingKey(queue string) string {
+	return b.prefix + ":" + queue + ":processing:" + b.consumer
+}
+
+func (b *redisBroker) aliveKey(queue, consumer string) string {
+	return b.prefix + ":" + queue + ":alive:" + consumer
+}
+
+func (b *redisBroker) deadKey(queue string) string {
+	return b.prefix + ":" + queue + ":dead"
+}
+
+// Enqueue 投递消息
+func (b *redisBroker) Enqueue(ctx context.Context, msg *Message, delay time.Duration) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if delay > 0 {
+		return b.client.ZAdd(ctx, b.delayedKey(msg.Queue), redis.Z{
+			Score:  float64(time.Now().Add(delay).UnixMilli()),
+			Member: data,
+		}).Err()
+	}
+	return b.client.LPush(ctx, b.pendingKey(msg.Queue), data).Err()
+}
+
+// Dequeue 阻塞获取消息，并将其放入当前节点的处理中列表
+func (b *redisBroker) Dequeue(ctx context.Context, queue string, timeout time.Duration) (*Message, error) {
+	if err := promoteScript.Run(ctx, b.client,
+		[]string{b.delayedKey(queue), b.pendingKey(queue)},
+		time.Now().UnixMilli(),
+	).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	raw, err := b.client.BLMove(ctx, b.pendingKey(queue), b.processingKey(queue), "RIGHT", "LEFT", timeout).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoMessage
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var msg Message
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		// 无法解析的消息直接移入死信，避免阻塞队列
+		pipe := b.client.TxPipeline()
+		pipe.LRem(ctx, b.processingKey(queue), 1, raw)
+		pipe.LPush(ctx, b.deadKey(queue), raw)
+		_, _ = pipe.Exec(ctx)
+		return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+
+	b.mu.Lock()
+	b.inflight[msg.ID] = raw
+	b.mu.Unlock()
+	return &msg, nil
+}
+
+// takeInflight 取出并移除消息原始数据
+func (b *redisBroker) takeInflight(msg *Message) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	raw, ok := b.inflight[msg.ID]
+	delete(b.inflight, msg.ID)
+	return raw, ok
+}
+
+// Ack 确认消息
+func (b *redisBroker) Ack(ctx context.Context, msg *Message) error {
+	raw, ok := b.takeInflight(msg)
+	if !ok {
+		return nil
+	}
+	return b.client.LRem(ctx, b.processingKey(msg.Queue), 1, raw).Err()
+}
+
+// Retry 确认当前投递并放入延迟集合
+func (b *redisBroker) Retry(ctx context.Context, msg *Message, delay time.Duration) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	raw, _ := b.takeInflight(msg)
+	pipe := b.client.TxPipeline()
+	if raw != "" {
+		pipe.LRem(ctx, b.processingKey(msg.Queue), 1, raw)
+	}
+	pipe.ZAdd(ctx, b.delayedKey(msg.Queue), redis.Z{
+		Score:  float64(time.Now().Add(delay).UnixMilli()),
+		Member: data,
+	})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DeadLetter 确认当前投递并移入死信列表
+func (b *redisBroker) DeadLetter(ctx context.Context, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	raw, _ := b.takeInflight(msg)
+	pipe := b.client.TxPipeline()
+	if raw != "" {
+		pipe.LRem(ctx, b.processingKey(msg.Queue), 1, raw)
+	}
+	pipe.LPush(ctx, b.deadKey(msg.Queue), data)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DeadLetters 获取死信消息
+func (b *redisBroker) DeadLetters(ctx context.Context, queue string, limit int64) ([]*Message, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	items, err := b.client.LRange(ctx, b.deadKey(queue), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Message, 0, len(items))
+	for _, raw := range items {
+		var msg Message
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			continue
+		}
+		out = append(out, &msg)
+	}
+	return out, nil
+}
+
+// Len 获取待处理消息数
+func (b *redisBroker) Len(ctx context.Context, queue string) (int64, error) {
+	return b.client.LLen(ctx, b.pendingKey(queue)).Result()
+}
+
+// Recover 续约当前消费者，并将当前节点上次未确认的消息放回待处理列表，需在启动消费前调用
+func (b *redisBroker) Recover(ctx context.Context, queue string) (int, error) {
+	if err := b.heartbeat(ctx, queue); err != nil {
+		return 0, err
+	}
+	return b.requeue(ctx, b.processingKey(queue), queue)
+}
+
+// ReapInterval 续约与回收的执行间隔
+func (b *redisBroker) ReapInterval() time.Duration {
+	return b.leaseTTL / 3
+}
+
+// Reap 续约当前消费者，并将租约已过期的消费者的处理中消息放回待处理列表。
+// 消费者ID默认包含进程号，重启后无法通过 Recover 找回旧进程的消息，由存活的节点回收
+func (b *redisBroker) Reap(ctx context.Context, queue string) (int, error) {
+	if err := b.heartbeat(ctx, queue); err != nil {
+		return 0, err
+	}
+	prefix := b.prefix + ":" + queue + ":processing:"
+	n := 0
+	iter := b.client.Scan(ctx, 0, prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		consumer := key[len(prefix):]
+		if consumer == b.consumer {
+			continue
+		}
+		alive, err := b.client.Exists(ctx, b.aliveKey(queue, consumer)).Result()
+		if err != nil {
+			return n, err
+		}
+		if alive > 0 {
+			continue
+		}
+		moved, err := b.requeue(ctx, key, queue)
+		n += moved
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, iter.Err()
+}
+
+// heartbeat 续约当前消费者的存活租约
+func (b *redisBroker) heartbeat(ctx context.Context, queue string) error {
+	return b.client.Set(ctx, b.aliveKey(queue, b.consumer), time.Now().UnixMilli(), b.leaseTTL).Err()
+}
+
+// requeue 将处理中列表的消息逐条移回待处理列表队尾，优先被消费
+func (b *redisBroker) requeue(ctx context.Context, processing, queue string) (int, error) {
+	n := 0
+	for {
+		_, err := b.client.LMove(ctx, processing, b.pendingKey(queue), "RIGHT", "RIGHT").Result()
+		if errors.Is(err, redis.Nil) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Close 关闭后端
+func (b *redisBroker) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,310 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Config 队列配置
+type Config struct {
+	// 队列名称
+	Name string
+	// 并发worker数量
+	Concurrency int
+	// 默认最大重试次数，0 使用默认值，负数表示不重试
+	MaxRetry int
+	// 重试退避基数
+	BaseBackoff time.Duration
+	// 重试退避上限
+	MaxBackoff time.Duration
+	// 单次拉取阻塞时长
+	PollTimeout time.Duration
+	// 单个任务执行超时，0 表示不限制
+	TaskTimeout time.Duration
+}
+
+// DefaultConfig 默认队列配置
+func DefaultConfig(name string) Config {
+	return Config{
+		Name:        name,
+		Concurrency: 4,
+		MaxRetry:    5,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Minute,
+		PollTimeout: 2 * time.Second,
+		TaskTimeout: 5 * time.Minute,
+	}
+}
+
+// Queue 任务队列，负责投递任务以及运行worker池
+type Queue struct {
+	broker   Broker
+	cfg      Config
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	running  bool
+}
+
+// NewQueue 创建任务队列
+func NewQueue(broker Broker, cfg Config) *Queue {
+	def := DefaultConfig(cfg.Name)
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = def.Concurrency
+	}
+	if cfg.MaxRetry == 0 {
+		cfg.MaxRetry = def.MaxRetry
+	} else if cfg.MaxRetry < 0 {
+		cfg.MaxRetry = 0
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = def.BaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = def.MaxBackoff
+	}
+	if cfg.PollTimeout <= 0 {
+		cfg.PollTimeout = def.PollTimeout
+	}
+	return &Queue{
+		broker:   broker,
+		cfg:      cfg,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Name 队列名称
+func (q *Queue) Name() string {
+	return q.cfg.Name
+}
+
+// Broker 队列后端
+func (q *Queue) Broker() Broker {
+	return q.broker
+}
+
+// Register 注册任务处理器
+func (q *Queue) Register(taskType string, h HandlerFunc) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[taskType] = h
+}
+
+// Enqueue 投递任务
+func (q *Queue) Enqueue(ctx context.Context, task Task, opts ...Option) (*Message, error) {
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task: %w", err)
+	}
+	msg := &Message{
+		ID:         generateMessageID(),
+		Queue:      q.cfg.Name,
+		Type:       task.Type(),
+		Payload:    payload,
+		MaxRetry:   q.cfg.MaxRetry,
+		EnqueuedAt: time.Now(),
+	}
+	var delay time.Duration
+	for _, opt := range opts {
+		opt(msg, &delay)
+	}
+	if err := q.broker.Enqueue(ctx, msg, delay); err != nil {
+		return nil, err
+	}
+	tasksEnqueued.WithLabelValues(q.cfg.Name, msg.Type).Inc()
+	return msg, nil
+}
+
+// Replay 将死信消息重新投递，重置重试次数
+func (q *Queue) Replay(ctx context.Context, msg *Message) error {
+	msg.Attempt = 0
+	msg.LastError = ""
+	return q.broker.Enqueue(ctx, msg, 0)
+}
+
+// Start 启动worker池
+func (q *Queue) Start(ctx context.Context) {
+	q.mu.Lock()
+	if q.running {
+		q.mu.Unlock()
+		return
+	}
+	q.running = true
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.mu.Unlock()
+
+	if r, ok := q.broker.(interface {
+		Recover(ctx context.Context, queue string) (int, error)
+	}); ok {
+		if n, err := r.Recover(ctx, q.cfg.Name); err != nil {
+			zap.L().Error("queue recover failed", zap.String("queue", q.cfg.Name), zap.Error(err))
+		} else if n > 0 {
+			zap.L().Info("queue recovered inflight tasks", zap.String("queue", q.cfg.Name), zap.Int("count", n))
+		}
+	}
+
+	if r, ok := q.broker.(reaper); ok {
+		q.wg.Add(1)
+		go q.reap(ctx, r)
+	}
+
+	for i := 0; i < q.cfg.Concurrency; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx)
+	}
+}
+
+// reaper 支持回收失联消费者处理中消息的后端
+type reaper interface {
+	// Reap 续约当前消费者并回收失联消费者的处理中消息
+	Reap(ctx context.Context, queue string) (int, error)
+	// ReapInterval 执行间隔
+	ReapInterval() time.Duration
+}
+
+// reap 定期续约并回收失联消费者的处理中消息
+func (q *Queue) reap(ctx context.Context, r reaper) {
+	defer q.wg.Done()
+	ticker := time.NewTicker(r.ReapInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := r.Reap(ctx, q.cfg.Name); err != nil {
+				if ctx.Err() == nil {
+					zap.L().Warn("queue reap failed", zap.String("queue", q.cfg.Name), zap.Error(err))
+				}
+			} else if n > 0 {
+				zap.L().Info("queue reclaimed tasks from lost consumers", zap.String("queue", q.cfg.Name), zap.Int("count", n))
+			}
+		}
+	}
+}
+
+// Stop 停止worker池并等待正在执行的任务完成
+func (q *Queue) Stop() {
+	q.mu.Lock()
+	if !q.running {
+		q.mu.Unlock()
+		return
+	}
+	q.running = false
+	q.cancel()
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// worker 拉取并处理任务
+func (q *Queue) worker(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		msg, err := q.broker.Dequeue(ctx, q.cfg.Name, q.cfg.PollTimeout)
+		if err != nil {
+			if errors.Is(err, ErrNoMessage) {
+				continue
+			}
+			if errors.Is(err, ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			zap.L().Warn("queue dequeue failed", zap.String("queue", q.cfg.Name), zap.Error(err))
+			time.Sleep(q.cfg.BaseBackoff)
+			continue
+		}
+		q.process(ctx, msg)
+	}
+}
+
+// process 执行单个任务，并根据结果确认、重试或移入死信
+func (q *Queue) process(ctx context.Context, msg *Message) {
+	q.mu.RLock()
+	h := q.handlers[msg.Type]
+	q.mu.RUnlock()
+
+	tasksInflight.WithLabelValues(q.cfg.Name).Inc()
+	defer tasksInflight.WithLabelValues(q.cfg.Name).Dec()
+
+	start := time.Now()
+	var err error
+	if h == nil {
+		err = ErrNoHandler
+	} else {
+		err = q.invoke(ctx, h, msg)
+	}
+	taskDuration.WithLabelValues(q.cfg.Name, msg.Type).Observe(time.Since(start).Seconds())
+
+	// 使用独立上下文确保停止时仍能完成确认
+	ackCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err == nil {
+		tasksProcessed.WithLabelValues(q.cfg.Name, msg.Type, statusSuccess).Inc()
+		if err := q.broker.Ack(ackCtx, msg); err != nil {
+			zap.L().Warn("queue ack failed", zap.String("id", msg.ID), zap.Error(err))
+		}
+		return
+	}
+
+	msg.Attempt++
+	msg.LastError = err.Error()
+	if msg.Attempt > msg.MaxRetry || errors.Is(err, ErrNoHandler) {
+		tasksProcessed.WithLabelValues(q.cfg.Name, msg.Type, statusDead).Inc()
+		zap.L().Error("queue task moved to dead letter",
+			zap.String("queue", q.cfg.Name), zap.String("type", msg.Type),
+			zap.String("id", msg.ID), zap.Int("attempt", msg.Attempt), zap.Error(err))
+		if err := q.broker.DeadLetter(ackCtx, msg); err != nil {
+			zap.L().Error("queue dead letter failed", zap.String("id", msg.ID), zap.Error(err))
+		}
+		return
+	}
+
+	tasksProcessed.WithLabelValues(q.cfg.Name, msg.Type, statusRetry).Inc()
+	delay := q.backoff(msg.Attempt)
+	zap.L().Warn("queue task failed, retrying",
+		zap.String("queue", q.cfg.Name), zap.String("type", msg.Type),
+		zap.String("id", msg.ID), zap.Int("attempt", msg.Attempt),
+		zap.Duration("delay", delay), zap.Error(err))
+	if err := q.broker.Retry(ackCtx, msg, delay); err != nil {
+		zap.L().Error("queue retry failed", zap.String("id", msg.ID), zap.Error(err))
+	}
+}
+
+// invoke 调用处理器，捕获panic并应用超时
+func (q *Queue) invoke(ctx context.Context, h HandlerFunc, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panic: %v", r)
+		}
+	}()
+	if q.cfg.TaskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.cfg.TaskTimeout)
+		defer cancel()
+	}
+	return h(ctx, msg)
+}
+
+// backoff 指数退避并加入抖动
+func (q *Queue) backoff(attempt int) time.Duration {
+	d := q.cfg.BaseBackoff
+	for i := 1; i < attempt && d < q.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > q.cfg.MaxBackoff {
+		d = q.cfg.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/5 + 1))
+	return d + jitter
+}
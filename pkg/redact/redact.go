@@ -0,0 +1,300 @@
+// Package redact 对监控、日志等输出中的个人信息与凭证脱敏
+//
+// 脱敏分两类规则：
+//   - 字段规则：字段名（忽略大小写及 -、_）以配置的名称结尾时整体替换，例如 password、user_email、X-Api-Key
+//   - 值规则：对字符串内容做正则替换，内置 email、phone、token 三类，也可以用 "re:<正则>" 追加自定义规则
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Mask 整体替换时使用的掩码
+const Mask = "******"
+
+// DefaultFields 默认按字段名整体脱敏的字段
+var DefaultFields = []string{
+	"password", "passwd", "secret", "token", "accesstoken", "refreshtoken",
+	"apikey", "authorization", "cookie", "session", "email", "phone", "mobile",
+}
+
+// DefaultPatterns 默认启用的内置值规则
+var DefaultPatterns = []string{"email", "phone", "token"}
+
+// Config 脱敏配置
+type Config struct {
+	// Enabled 为 false 时原样输出
+	Enabled bool
+	// Fields 字段名规则，为空时使用 DefaultFields
+	Fields []string
+	// Patterns 值规则：内置规则名（email、phone、token）或 "re:<正则>"，为空时使用 DefaultPatterns
+	Patterns []string
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{Enabled: true, Fields: DefaultFields, Patterns: DefaultPatterns}
+}
+
+type rule struct {
+	re      *regexp.Regexp
+	replace func(re *regexp.Regexp, s string) string
+}
+
+func replaceWith(tmpl string) func(re *regexp.Regexp, s string) string {
+	return func(re *regexp.Regexp, s string) string { return re.ReplaceAllString(s, tmpl) }
+}
+
+// keepTail 只保留末尾 n 个字符
+func keepTail(n int) func(re *regexp.Regexp, s string) string {
+	return func(re *regexp.Regexp, s string) string {
+		return re.ReplaceAllStringFunc(s, func(m string) string {
+			if len(m) <= n {
+				return Mask
+			}
+			return "***" + m[len(m)-n:]
+		})
+	}
+}
+
+// builtinRules 内置值规则
+var builtinRules = map[string][]rule{
+	// 邮箱保留首字符与域名，便于排查
+	"email": {{
+		re:      regexp.MustCompile(`([A-Za-z0-9])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`),
+		replace: replaceWith("${1}***@${2}"),
+	}},
+	// 手机号保留后 4 位：国内手机号与带国际区号的号码
+	"phone": {
+		{re: regexp.MustCompile(`\+\d{1,3}[\- ]?\d{6,14}\b`), replace: keepTail(4)},
+		{re: regexp.MustCompile(`\b1[3-9]\d{9}\b`), replace: keepTail(4)},
+	},
+	// 令牌：Bearer 头、JWT 以及 URL/表单中的敏感参数
+	"token": {
+		{re: regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`), replace: replaceWith("${1} " + Mask)},
+		{re: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`), replace: replaceWith(Mask)},
+		{
+			re:      regexp.MustCompile(`(?i)\b(access_token|refresh_token|id_token|token|api_key|apikey|password|passwd|secret|signature|sign)=([^&\s"']+)`),
+			replace: replaceWith("${1}=" + Mask),
+		},
+	},
+}
+
+// Redactor 脱敏器，零值与 nil 均原样输出
+type Redactor struct {
+	enabled bool
+	fields  []string
+	rules   []rule
+}
+
+// New 按配置创建脱敏器，自定义正则无效或内置规则名未知时返回错误
+func New(cfg Config) (*Redactor, error) {
+	r := &Redactor{enabled: cfg.Enabled}
+	fields := cfg.Fields
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+	for _, f := range fields {
+		if f = normalizeKey(f); f != "" {
+			r.fields = append(r.fields, f)
+		}
+	}
+	patterns := cfg.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultPatterns
+	}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redact pattern %q: %w", expr, err)
+			}
+			r.rules = append(r.rules, rule{re: re, replace: replaceWith(Mask)})
+			continue
+		}
+		rules, ok := builtinRules[strings.ToLower(p)]
+		if !ok {
+			return nil, fmt.Errorf("unknown redact pattern %q", p)
+		}
+		r.rules = append(r.rules, rules...)
+	}
+	return r, nil
+}
+
+// normalizeKey 字段名转小写并去掉 - 与 _
+func normalizeKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	return strings.NewReplacer("-", "", "_", "", ".", "").Replace(key)
+}
+
+// SensitiveField 字段名是否需要整体脱敏
+func (r *Redactor) SensitiveField(key string) bool {
+	if r == nil || !r.enabled || key == "" {
+		return false
+	}
+	key = normalizeKey(key)
+	for _, f := range r.fields {
+		if strings.HasSuffix(key, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// String 对字符串应用值规则
+func (r *Redactor) String(s string) string {
+	if r == nil || !r.enabled || s == "" {
+		return s
+	}
+	for _, rl := range r.rules {
+		s = rl.replace(rl.re, s)
+	}
+	return s
+}
+
+// Value 按字段名与值规则脱敏，递归处理 map 与切片，返回新的值，不修改入参
+func (r *Redactor) Value(key string, v any) any {
+	if r == nil || !r.enabled || v == nil {
+		return v
+	}
+	if r.SensitiveField(key) {
+		return Mask
+	}
+	switch val := v.(type) {
+	case string:
+		return r.String(val)
+	case json.Number, bool:
+		return v
+	case []byte:
+		return r.String(string(val))
+	case error:
+		return r.String(val.Error())
+	case map[string]any:
+		return r.Map(val)
+	case map[string]string:
+		return r.StringMap(val)
+	case []any:
+		return r.Slice(val)
+	case []string:
+		out := make([]string, len(val))
+		for i, s := range val {
+			out[i] = r.String(s)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Map 返回脱敏后的副本
+func (r *Redactor) Map(m map[string]any) map[string]any {
+	if r == nil || !r.enabled || m == nil {
+		return m
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = r.Value(k, v)
+	}
+	return out
+}
+
+// StringMap 返回脱敏后的副本
+func (r *Redactor) StringMap(m map[string]string) map[string]string {
+	if r == nil || !r.enabled || m == nil {
+		return m
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = r.Tag(k, v)
+	}
+	return out
+}
+
+// Tag 对单个字符串字段脱敏
+func (r *Redactor) Tag(key, value string) string {
+	if r.SensitiveField(key) && value != "" {
+		return Mask
+	}
+	return r.String(value)
+}
+
+// Slice 返回脱敏后的副本，例如 SQL 参数
+func (r *Redactor) Slice(vs []any) []any {
+	if r == nil || !r.enabled || vs == nil {
+		return vs
+	}
+	out := make([]any, len(vs))
+	for i, v := range vs {
+		out[i] = r.Value("", v)
+	}
+	return out
+}
+
+// JSON 对 JSON 文本按字段名与值规则脱敏，无法解析时按普通字符串处理
+func (r *Redactor) JSON(s string) string {
+	if r == nil || !r.enabled || s == "" {
+		return s
+	}
+	var v any
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return r.String(s)
+	}
+	data, err := json.Marshal(r.Value("", v))
+	if err != nil {
+		return r.String(s)
+	}
+	return string(data)
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRedactor = mustNew(DefaultConfig())
+)
+
+func mustNew(cfg Config) *Redactor {
+	r, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// SetDefault 设置全局脱敏器，nil 表示关闭脱敏
+func SetDefault(r *Redactor) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRedactor = r
+}
+
+// Default 获取全局脱敏器，未设置时使用 DefaultConfig
+func Default() *Redactor {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultRedactor
+}
+
+// ParseConfig 解析配置项：fields 以逗号分隔，patterns 以分号分隔（正则中可能含逗号），为空时使用默认规则
+func ParseConfig(enabled bool, fields, patterns string) Config {
+	cfg := Config{Enabled: enabled}
+	for _, f := range strings.Split(fields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			cfg.Fields = append(cfg.Fields, f)
+		}
+	}
+	for _, p := range strings.Split(patterns, ";") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.Patterns = append(cfg.Patterns, p)
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,97 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDefault(t *testing.T) *Redactor {
+	r, err := New(DefaultConfig())
+	require.NoError(t, err)
+	return r
+}
+
+func TestRedactString(t *testing.T) {
+	r := newDefault(t)
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"email", "login failed for alice.smith@example.com", "login failed for a***@example.com"},
+		{"cn phone", "SELECT * FROM users WHERE phone = '13812345678'", "SELECT * FROM users WHERE phone = '***5678'"},
+		{"intl phone", "call +44 2071234567 now", "call ***4567 now"},
+		{"bearer", "Authorization: Bearer abc.def-123", "Authorization: Bearer ******"},
+		{"jwt", "token eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig_x", "token ******"},
+		{"query", "/api/auth?user=1&access_token=s3cr3t&page=2", "/api/auth?user=1&access_token=******&page=2"},
+		{"plain", "SELECT id FROM messages WHERE id = 42", "SELECT id FROM messages WHERE id = 42"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.String(tc.in))
+		})
+	}
+}
+
+func TestRedactFields(t *testing.T) {
+	r := newDefault(t)
+	assert.True(t, r.SensitiveField("password"))
+	assert.True(t, r.SensitiveField("user_email"))
+	assert.True(t, r.SensitiveField("X-Api-Key"))
+	assert.False(t, r.SensitiveField("userId"))
+
+	in := map[string]any{
+		"userId":   7,
+		"password": "hunter2",
+		"note":     "contact bob@example.com",
+		"nested":   map[string]any{"refresh_token": "r1", "ok": true},
+		"list":     []any{"13912345678", 3},
+	}
+	out := r.Map(in)
+	assert.Equal(t, 7, out["userId"])
+	assert.Equal(t, Mask, out["password"])
+	assert.Equal(t, "contact b***@example.com", out["note"])
+	assert.Equal(t, map[string]any{"refresh_token": Mask, "ok": true}, out["nested"])
+	assert.Equal(t, []any{"***5678", 3}, out["list"])
+	// 不修改入参
+	assert.Equal(t, "hunter2", in["password"])
+
+	assert.Equal(t, map[string]string{"http.url": "/a?token=******", "auth.cookie": Mask},
+		r.StringMap(map[string]string{"http.url": "/a?token=x", "auth.cookie": "sid=1"}))
+	assert.Equal(t, []any{"a***@b.io", 1, true}, r.Slice([]any{"ann@b.io", 1, true}))
+	assert.Equal(t, "dial ***0000 failed", r.Value("", errors.New("dial 13800000000 failed")))
+}
+
+func TestRedactJSON(t *testing.T) {
+	r := newDefault(t)
+	got := r.JSON(`{"email":"a@b.io","size":12345678901234567,"msg":"from c@d.io"}`)
+	assert.JSONEq(t, `{"email":"******","size":12345678901234567,"msg":"from c***@d.io"}`, got)
+	assert.Equal(t, "not json a***@b.io", r.JSON("not json a@b.io"))
+}
+
+func TestRedactCustomPatterns(t *testing.T) {
+	r, err := New(ParseConfig(true, "ssn", `re:\d{3}-\d{2}-\d{4}; email`))
+	require.NoError(t, err)
+	assert.Equal(t, "id ****** mail x***@y.com phone 13812345678", r.String("id 123-45-6789 mail xy@y.com phone 13812345678"))
+	assert.True(t, r.SensitiveField("user_ssn"))
+	assert.False(t, r.SensitiveField("password"))
+
+	_, err = New(Config{Enabled: true, Patterns: []string{"re:("}})
+	assert.Error(t, err)
+	_, err = New(Config{Enabled: true, Patterns: []string{"iban"}})
+	assert.Error(t, err)
+}
+
+func TestRedactDisabled(t *testing.T) {
+	r, err := New(Config{Enabled: false})
+	require.NoError(t, err)
+	assert.Equal(t, "a@b.io", r.String("a@b.io"))
+	assert.Equal(t, "x", r.Value("password", "x"))
+
+	var nilRedactor *Redactor
+	assert.Equal(t, "a@b.io", nilRedactor.String("a@b.io"))
+	assert.Equal(t, "x", nilRedactor.Tag("password", "x"))
+}
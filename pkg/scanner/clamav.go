@@ -0,0 +1,106 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamavChunkSize INSTREAM 单个数据块大小
+const clamavChunkSize = 64 * 1024
+
+// ClamAV 通过 clamd 的 INSTREAM 命令扫描，地址为 tcp 的 host:port 或 unix socket 路径
+type ClamAV struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamAV 创建 ClamAV 扫描器，addr 以 / 开头或带 unix:// 前缀时使用 unix socket
+func NewClamAV(addr string, timeout time.Duration) *ClamAV {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix://") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix://")
+	} else if strings.HasPrefix(addr, "/") {
+		network = "unix"
+	}
+	if addr == "" {
+		addr = "127.0.0.1:3310"
+	}
+	return &ClamAV{network: network, addr: addr, timeout: timeout}
+}
+
+// Name 实现 Scanner
+func (c *ClamAV) Name() string {
+	return KindClamAV
+}
+
+// Scan 实现 Scanner
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrScannerUnavailable, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else if c.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, err
+	}
+	buf := make([]byte, clamavChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return Result{}, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return Result{}, err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return Result{}, rerr
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return Result{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Result{}, err
+	}
+	return parseClamAVReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamAVReply 解析 clamd 响应，如 "stream: OK" 或 "stream: Eicar-Signature FOUND"
+func parseClamAVReply(reply string) (Result, error) {
+	res := Result{Engine: KindClamAV, ScannedAt: time.Now()}
+	_, status, _ := strings.Cut(reply, ": ")
+	switch {
+	case status == "OK":
+		res.Verdict = VerdictClean
+	case strings.HasSuffix(status, " FOUND"):
+		res.Verdict = VerdictInfected
+		res.Signature = strings.TrimSuffix(status, " FOUND")
+	default:
+		return Result{}, fmt.Errorf("clamav: unexpected reply %q", reply)
+	}
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPScanner 调用外部扫描 API：以原始文件内容 POST，响应 {"clean":bool,"signature":"..."}
+type HTTPScanner struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPScanner 创建外部 API 扫描器，apiKey 非空时以 Bearer 方式携带
+func NewHTTPScanner(url, apiKey string, timeout time.Duration) *HTTPScanner {
+	return &HTTPScanner{url: url, apiKey: apiKey, client: &http.Client{Timeout: timeout}}
+}
+
+// Name 实现 Scanner
+func (s *HTTPScanner) Name() string {
+	return KindHTTP
+}
+
+// Scan 实现 Scanner
+func (s *HTTPScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if s.url == "" {
+		return Result{}, fmt.Errorf("%w: SCANNER_API_URL not configured", ErrScannerUnavailable)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, r)
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrScannerUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("%w: status %d", ErrScannerUnavailable, resp.StatusCode)
+	}
+
+	var body struct {
+		Clean     bool   `json:"clean"`
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return Result{}, fmt.Errorf("scanner: decode response: %w", err)
+	}
+	res := Result{Verdict: VerdictClean, Engine: KindHTTP, ScannedAt: time.Now()}
+	if !body.Clean {
+		res.Verdict = VerdictInfected
+		res.Signature = body.Signature
+	}
+	return res, nil
+}
@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"HibiscusIM/pkg/util"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 扫描结论
+const (
+	VerdictClean    = "clean"
+	VerdictInfected = "infected"
+)
+
+// 扫描器类型
+const (
+	KindNone   = "none"
+	KindClamAV = "clamav"
+	KindHTTP   = "http"
+)
+
+// ErrScannerUnavailable 扫描服务不可用
+var ErrScannerUnavailable = errors.New("scanner: service unavailable")
+
+// Result 单次扫描结果
+type Result struct {
+	Verdict   string    `json:"verdict"`
+	Signature string    `json:"signature,omitempty"`
+	Engine    string    `json:"engine"`
+	ScannedAt time.Time `json:"scannedAt"`
+}
+
+// Clean 是否未发现威胁
+func (r Result) Clean() bool {
+	return r.Verdict == VerdictClean
+}
+
+// Scanner 文件内容扫描器（杀毒或内容策略），返回错误表示扫描未完成而非文件有问题
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Pipeline 依次执行多个扫描器，任一扫描器判定不通过即停止
+type Pipeline struct {
+	scanners []Scanner
+}
+
+// NewPipeline 创建扫描流水线
+func NewPipeline(scanners ...Scanner) *Pipeline {
+	return &Pipeline{scanners: scanners}
+}
+
+// Name 实现 Scanner
+func (p *Pipeline) Name() string {
+	names := make([]string, 0, len(p.scanners))
+	for _, s := range p.scanners {
+		names = append(names, s.Name())
+	}
+	if len(names) == 0 {
+		return KindNone
+	}
+	return strings.Join(names, "+")
+}
+
+// Scan 实现 Scanner，多个扫描器时先将内容读入内存以便重复读取
+func (p *Pipeline) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	if len(p.scanners) == 0 {
+		return Result{Verdict: VerdictClean, Engine: KindNone, ScannedAt: time.Now()}, nil
+	}
+	if len(p.scanners) == 1 {
+		return p.scanners[0].Scan(ctx, r)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	var last Result
+	for _, s := range p.scanners {
+		res, err := s.Scan(ctx, bytes.NewReader(data))
+		if err != nil {
+			return Result{}, err
+		}
+		if !res.Clean() {
+			return res, nil
+		}
+		last = res
+	}
+	last.Engine = p.Name()
+	return last, nil
+}
+
+// LoadFromEnv 根据环境变量创建扫描流水线，SCANNER_KIND 可为逗号分隔的多个类型，未配置时不扫描
+func LoadFromEnv() *Pipeline {
+	timeout := 30 * time.Second
+	if v := util.GetIntEnv("SCANNER_TIMEOUT_SECONDS"); v > 0 {
+		timeout = time.Duration(v) * time.Second
+	}
+	var scanners []Scanner
+	for _, kind := range strings.Split(util.GetEnv("SCANNER_KIND"), ",") {
+		switch strings.TrimSpace(strings.ToLower(kind)) {
+		case KindClamAV:
+			scanners = append(scanners, NewClamAV(util.GetEnv("CLAMAV_ADDR"), timeout))
+		case KindHTTP:
+			scanners = append(scanners, NewHTTPScanner(util.GetEnv("SCANNER_API_URL"), util.GetEnv("SCANNER_API_KEY"), timeout))
+		}
+	}
+	return NewPipeline(scanners...)
+}
+
+var (
+	globalScanner Scanner
+	mu            sync.RWMutex
+)
+
+// SetGlobalScanner 设置全局扫描器
+func SetGlobalScanner(s Scanner) {
+	mu.Lock()
+	defer mu.Unlock()
+	globalScanner = s
+}
+
+// GetGlobalScanner 获取全局扫描器，未设置时返回空流水线（不扫描）
+func GetGlobalScanner() Scanner {
+	mu.RLock()
+	defer mu.RUnlock()
+	if globalScanner == nil {
+		return NewPipeline()
+	}
+	return globalScanner
+}
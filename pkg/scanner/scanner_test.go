@@ -0,0 +1,152 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClamd 模拟 clamd INSTREAM，内容包含 EICAR 时报毒
+func fakeClamd(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				cmd := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, cmd); err != nil {
+					return
+				}
+				var data bytes.Buffer
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(conn, size); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, conn, int64(n)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(data.String(), "EICAR") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamAVScan(t *testing.T) {
+	addr := fakeClamd(t)
+	s := NewClamAV(addr, 5*time.Second)
+
+	res, err := s.Scan(context.Background(), strings.NewReader("hello world"))
+	require.NoError(t, err)
+	assert.True(t, res.Clean())
+	assert.Equal(t, KindClamAV, res.Engine)
+
+	big := strings.Repeat("a", clamavChunkSize*2+10) + "EICAR"
+	res, err = s.Scan(context.Background(), strings.NewReader(big))
+	require.NoError(t, err)
+	assert.Equal(t, VerdictInfected, res.Verdict)
+	assert.Equal(t, "Eicar-Test-Signature", res.Signature)
+}
+
+func TestClamAVUnavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = NewClamAV(addr, time.Second).Scan(context.Background(), strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrScannerUnavailable)
+}
+
+func TestParseClamAVReply(t *testing.T) {
+	_, err := parseClamAVReply("INSTREAM size limit exceeded. ERROR")
+	assert.Error(t, err)
+
+	assert.Equal(t, "unix", NewClamAV("/var/run/clamd.sock", 0).network)
+	assert.Equal(t, "unix", NewClamAV("unix:///tmp/clamd.sock", 0).network)
+	assert.Equal(t, "127.0.0.1:3310", NewClamAV("", 0).addr)
+}
+
+func TestHTTPScanner(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		clean := !bytes.Contains(body, []byte("bad"))
+		json.NewEncoder(w).Encode(map[string]any{"clean": clean, "signature": "policy.bad-word"})
+	}))
+	defer srv.Close()
+
+	s := NewHTTPScanner(srv.URL, "secret", time.Second)
+	res, err := s.Scan(context.Background(), strings.NewReader("fine"))
+	require.NoError(t, err)
+	assert.True(t, res.Clean())
+	assert.Empty(t, res.Signature)
+
+	res, err = s.Scan(context.Background(), strings.NewReader("bad content"))
+	require.NoError(t, err)
+	assert.False(t, res.Clean())
+	assert.Equal(t, "policy.bad-word", res.Signature)
+
+	_, err = NewHTTPScanner("", "", time.Second).Scan(context.Background(), strings.NewReader("x"))
+	assert.ErrorIs(t, err, ErrScannerUnavailable)
+}
+
+type staticScanner struct {
+	name string
+	res  Result
+	seen string
+}
+
+func (s *staticScanner) Name() string { return s.name }
+
+func (s *staticScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	data, _ := io.ReadAll(r)
+	s.seen = string(data)
+	return s.res, nil
+}
+
+func TestPipeline(t *testing.T) {
+	res, err := NewPipeline().Scan(context.Background(), strings.NewReader("x"))
+	require.NoError(t, err)
+	assert.True(t, res.Clean())
+	assert.Equal(t, KindNone, res.Engine)
+
+	a := &staticScanner{name: "a", res: Result{Verdict: VerdictClean}}
+	b := &staticScanner{name: "b", res: Result{Verdict: VerdictInfected, Signature: "sig"}}
+	p := NewPipeline(a, b)
+	assert.Equal(t, "a+b", p.Name())
+
+	res, err = p.Scan(context.Background(), strings.NewReader("payload"))
+	require.NoError(t, err)
+	assert.Equal(t, VerdictInfected, res.Verdict)
+	assert.Equal(t, "payload", a.seen)
+	assert.Equal(t, "payload", b.seen)
+}
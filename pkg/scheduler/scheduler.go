@@ -25,7 +25,15 @@ func (s *Scheduler) Stop() { s.cancel() }
 
 func (s *Scheduler) Every(d time.Duration, job Job) { go s.loopEvery(d, job) }
 
-func (s *Scheduler) DailyAt(hh, mm int, job Job) { go s.loopDaily(hh, mm, job) }
+func (s *Scheduler) DailyAt(hh, mm int, job Job) { go s.loopDaily(time.Local, hh, mm, job) }
+
+// DailyAtIn 每天在 loc 时区的 hh:mm 执行，夏令时切换当天仍按当地时间执行
+func (s *Scheduler) DailyAtIn(loc *time.Location, hh, mm int, job Job) {
+	if loc == nil {
+		loc = time.Local
+	}
+	go s.loopDaily(loc, hh, mm, job)
+}
 
 func (s *Scheduler) OnceAfter(d time.Duration, job Job) { go s.onceAfter(d, job) }
 
@@ -42,13 +50,21 @@ func (s *Scheduler) loopEvery(d time.Duration, job Job) {
 	}
 }
 
-func (s *Scheduler) loopDaily(hh, mm int, job Job) {
+// NextDaily from 之后 loc 时区下一个 hh:mm。按日历日而非固定 24 小时推进，夏令时切换当天不会偏移一小时；
+// hh:mm 因夏令时跳过而不存在时顺延到跳变后的同一时刻（如 02:30 变为 03:30）
+func NextDaily(from time.Time, loc *time.Location, hh, mm int) time.Time {
+	local := from.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), hh, mm, 0, 0, loc)
+	if !next.After(from) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, hh, mm, 0, 0, loc)
+	}
+	return next
+}
+
+func (s *Scheduler) loopDaily(loc *time.Location, hh, mm int, job Job) {
 	for {
 		now := time.Now()
-		next := time.Date(now.Year(), now.Month(), now.Day(), hh, mm, 0, 0, now.Location())
-		if !next.After(now) {
-			next = next.Add(24 * time.Hour)
-		}
+		next := NextDaily(now, loc, hh, mm)
 		select {
 		case <-s.ctx.Done():
 			return
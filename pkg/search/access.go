@@ -0,0 +1,60 @@
+package search
+
+import (
+	"github.com/blevesearch/bleve/v2"
+	q "github.com/blevesearch/bleve/v2/search/query"
+)
+
+// DefaultACLField 文档访问控制字段，取值为可见该文档的主体列表（如 public、user:42、group:7），需映射为关键词字段
+const DefaultACLField = "acl"
+
+// AccessFilter 文档级访问控制：文档的 Field 字段（如 acl）至少包含 Principals 中的一个值时才可见，
+// Principals 为空时不可见任何文档。过滤条件与查询一同交给引擎执行，命中数与 Facet 统计只覆盖可见文档
+type AccessFilter struct {
+	Field      string   `json:"field"`
+	Principals []string `json:"principals"`
+}
+
+// bleveQuery 可见文档的 bleve 查询
+func (f *AccessFilter) bleveQuery() q.Query {
+	if len(f.Principals) == 0 {
+		return bleve.NewMatchNoneQuery()
+	}
+	terms := make([]q.Query, 0, len(f.Principals))
+	for _, p := range f.Principals {
+		tq := bleve.NewTermQuery(p)
+		tq.SetField(f.Field)
+		terms = append(terms, tq)
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return bleve.NewDisjunctionQuery(terms...)
+}
+
+// esQuery 可见文档的 Elasticsearch 查询
+func (f *AccessFilter) esQuery() map[string]any {
+	if len(f.Principals) == 0 {
+		return map[string]any{"match_none": map[string]any{}}
+	}
+	return map[string]any{"terms": map[string]any{f.Field: f.Principals}}
+}
+
+// withAccess 在查询外层叠加访问过滤，不改变原查询中 should 子句的匹配语义
+func withAccess(query q.Query, access *AccessFilter) q.Query {
+	if access == nil {
+		return query
+	}
+	return bleve.NewConjunctionQuery(query, access.bleveQuery())
+}
+
+// withESAccess 以 filter 叠加访问过滤，不参与评分
+func withESAccess(query map[string]any, access *AccessFilter) map[string]any {
+	if access == nil {
+		return query
+	}
+	return map[string]any{"bool": map[string]any{
+		"must":   []any{query},
+		"filter": []any{access.esQuery()},
+	}}
+}
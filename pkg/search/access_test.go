@@ -0,0 +1,118 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchAccessFilterFacets(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		{ID: "1", Type: "article", Fields: map[string]any{"title": "hello public", "author": "alice", "acl": []string{"public"}}},
+		{ID: "2", Type: "article", Fields: map[string]any{"title": "hello private", "author": "alice", "acl": []string{"user:1"}}},
+		{ID: "3", Type: "article", Fields: map[string]any{"title": "hello group", "author": "bob", "acl": []string{"group:7", "user:2"}}},
+		{ID: "4", Type: "article", Fields: map[string]any{"title": "hello secret", "author": "carol", "acl": []string{"user:3"}}},
+	}))
+	authors := map[string]string{"1": "alice", "2": "alice", "3": "bob", "4": "carol"}
+	base := SearchRequest{
+		Keyword:      "hello",
+		SearchFields: []string{"title"},
+		Facets:       []FacetRequest{{Name: "author", Field: "author"}},
+		Size:         10,
+	}
+
+	facetCounts := func(res SearchResult) map[string]int {
+		counts := map[string]int{}
+		for _, term := range res.Facets["author"].Terms {
+			counts[term.Term] = term.Count
+		}
+		return counts
+	}
+
+	// 未设置访问过滤时统计全部文档
+	res, err := e.Search(ctx, base)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), res.Total)
+	assert.Equal(t, map[string]int{"alice": 2, "bob": 1, "carol": 1}, facetCounts(res))
+
+	cases := []struct {
+		name       string
+		principals []string
+		hits       []string
+		facets     map[string]int
+	}{
+		{"public", []string{"public"}, []string{"1"}, map[string]int{"alice": 1}},
+		{"owner", []string{"public", "user:1"}, []string{"1", "2"}, map[string]int{"alice": 2}},
+		{"group member", []string{"public", "user:5", "group:7"}, []string{"1", "3"}, map[string]int{"alice": 1, "bob": 1}},
+		{"no principals", nil, nil, map[string]int{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := base
+			req.Access = &AccessFilter{Field: DefaultACLField, Principals: tc.principals}
+			res, err := e.Search(ctx, req)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.hits, hitIDs(res))
+			assert.Equal(t, uint64(len(tc.hits)), res.Total)
+			// Facet 统计与可见结果集一致
+			assert.Equal(t, tc.facets, facetCounts(res))
+			visible := map[string]int{}
+			for _, id := range hitIDs(res) {
+				visible[authors[id]]++
+			}
+			assert.Equal(t, visible, facetCounts(res))
+		})
+	}
+
+	// 访问过滤不改变 should 子句至少命中一个的语义
+	res, err = e.Search(ctx, SearchRequest{
+		Matches: []ClauseMatch{{Field: "title", Query: "private"}},
+		Access:  &AccessFilter{Field: DefaultACLField, Principals: []string{"public", "user:1"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, hitIDs(res))
+}
+
+func TestBuildESQueryAccess(t *testing.T) {
+	q := buildESQuery(SearchRequest{
+		MustTerms: map[string][]string{"type": {"article"}},
+		Access:    &AccessFilter{Field: DefaultACLField, Principals: []string{"public", "user:1"}},
+	}, nil, nil)
+	data, err := json.Marshal(q)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bool":{
+		"must":[{"bool":{"filter":[{"term":{"type":"article"}}]}}],
+		"filter":[{"terms":{"acl":["public","user:1"]}}]
+	}}`, string(data))
+
+	q = buildESQuery(SearchRequest{Access: &AccessFilter{Field: DefaultACLField}}, nil, nil)
+	data, err = json.Marshal(q)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bool":{"must":[{"match_all":{}}],"filter":[{"match_none":{}}]}}`, string(data))
+}
+
+func TestCachedEngineAccessKey(t *testing.T) {
+	e := &CachedEngine{}
+	req := SearchRequest{Keyword: "hello"}
+	plain, err := e.resultKey(req)
+	require.NoError(t, err)
+
+	req.Access = &AccessFilter{Field: DefaultACLField, Principals: []string{"public", "user:1"}}
+	user1, err := e.resultKey(req)
+	require.NoError(t, err)
+	req.Access = &AccessFilter{Field: DefaultACLField, Principals: []string{"user:1", "public"}}
+	reordered, err := e.resultKey(req)
+	require.NoError(t, err)
+	req.Access = &AccessFilter{Field: DefaultACLField, Principals: []string{"public", "user:2"}}
+	user2, err := e.resultKey(req)
+	require.NoError(t, err)
+
+	assert.NotEqual(t, plain, user1)
+	assert.Equal(t, user1, reordered)
+	assert.NotEqual(t, user1, user2)
+}
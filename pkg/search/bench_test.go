@@ -0,0 +1,127 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchCorpusSize 合成语料的文档数
+const benchCorpusSize = 5000
+
+var benchWords = strings.Fields(`hello world search engine index query message group chat notice
+	survey voice backup alert metric latency fanout broadcast shard cursor scroll facet highlight
+	golang bleve gorm gin redis mysql sqlite cluster region node replica snapshot compact`)
+
+// newBenchEngine 以固定种子生成 article 语料并建立索引，保证多次运行结果可比
+func newBenchEngine(b *testing.B) Engine {
+	b.Helper()
+	e, err := New(Config{IndexPath: filepath.Join(b.TempDir(), "bench.bleve")}, BuildIndexMapping(""))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = e.Close() })
+
+	rnd := rand.New(rand.NewSource(1))
+	sentence := func(n int) string {
+		words := make([]string, n)
+		for i := range words {
+			words[i] = benchWords[rnd.Intn(len(benchWords))]
+		}
+		return strings.Join(words, " ")
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	docs := make([]Doc, 0, 500)
+	for i := 0; i < benchCorpusSize; i++ {
+		docs = append(docs, Doc{ID: fmt.Sprintf("a%d", i), Type: "article", Fields: map[string]any{
+			"title":     sentence(6),
+			"body":      sentence(40),
+			"tags":      benchWords[rnd.Intn(8)],
+			"author":    fmt.Sprintf("user%d", rnd.Intn(50)),
+			"views":     rnd.Intn(10000),
+			"createdAt": base.Add(time.Duration(i) * time.Hour),
+		}})
+		if len(docs) == cap(docs) {
+			if err := e.IndexBatch(ctx, docs); err != nil {
+				b.Fatal(err)
+			}
+			docs = docs[:0]
+		}
+	}
+	return e
+}
+
+// benchComplexRequest 覆盖 query_builder 各类子句的请求
+func benchComplexRequest() SearchRequest {
+	gte, lt := 100.0, 9000.0
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	boost := 2.0
+	return SearchRequest{
+		Keyword:       "search engine",
+		SearchFields:  []string{"title", "body"},
+		MustTerms:     map[string][]string{"tags": {"hello", "world", "search"}},
+		MustNotTerms:  map[string][]string{"author": {"user1"}},
+		ShouldTerms:   map[string][]string{"author": {"user2", "user3"}},
+		NumericRanges: []NumericRangeFilter{{Field: "views", GTE: &gte, LT: &lt}},
+		TimeRanges:    []TimeRangeFilter{{Field: "createdAt", From: &from, IncFrom: true}},
+		Matches:       []ClauseMatch{{Field: "title", Query: "golang bleve", Boost: &boost}},
+		Phrases:       []ClausePhrase{{Field: "body", Phrase: "broadcast shard", Slop: 2}},
+		Prefixes:      []ClausePrefix{{Field: "title", Prefix: "clu"}},
+		Fuzzies:       []ClauseFuzzy{{Field: "body", Term: "snapshat", Fuzziness: 1}},
+		Facets:        []FacetRequest{{Name: "tags", Field: "tags", Size: 10}},
+		SortBy:        []string{"-views"},
+		Size:          20,
+	}
+}
+
+func BenchmarkBuildQuery(b *testing.B) {
+	b.Run("keyword", func(b *testing.B) {
+		req := SearchRequest{Keyword: "hello world", SearchFields: []string{"title", "body"}}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = buildQuery(req, nil, nil)
+		}
+	})
+	b.Run("complex", func(b *testing.B) {
+		req := benchComplexRequest()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = buildQuery(req, nil, nil)
+		}
+	})
+}
+
+func BenchmarkEngineSearch(b *testing.B) {
+	e := newBenchEngine(b)
+	ctx := context.Background()
+	cases := []struct {
+		name string
+		req  SearchRequest
+	}{
+		{"keyword", SearchRequest{Keyword: "hello world", SearchFields: []string{"title", "body"}, Size: 20}},
+		{"filtered", SearchRequest{
+			Keyword:      "broadcast",
+			SearchFields: []string{"body"},
+			MustTerms:    map[string][]string{"tags": {"hello"}},
+			SortBy:       []string{"-createdAt"},
+			Size:         20,
+		}},
+		{"complex", benchComplexRequest()},
+		{"highlight", SearchRequest{Keyword: "cluster", SearchFields: []string{"title", "body"}, Highlight: true, Size: 20}},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := e.Search(ctx, tc.req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
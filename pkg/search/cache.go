@@ -0,0 +1,243 @@
+package search
+
+import (
+	"HibiscusIM/pkg/cache"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// CacheConfig 搜索结果缓存配置
+type CacheConfig struct {
+	// 结果缓存时长，<=0 时使用 1 分钟
+	TTL time.Duration
+	// 缓存键前缀，多个引擎共用一个缓存时用于区分，默认 "search:"
+	Prefix string
+}
+
+// CacheStats 结果缓存命中统计
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+// allTypes 未按文档类型过滤的查询使用的标签，任意类型的文档变更都会使其失效
+const allTypes = "*"
+
+// CachedEngine 为 Search 增加结果缓存的引擎包装，Index/IndexBatch/Delete 成功后按文档类型使相关结果失效；
+// Scroll、补全与建议等其他方法直接透传
+type CachedEngine struct {
+	Engine
+	cache  cache.Cache
+	ttl    time.Duration
+	prefix string
+
+	// generation 每次失效时递增，查询期间发生失效时不回写结果，避免缓存旧数据
+	generation    atomic.Int64
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+// NewCachedEngine 用 c 缓存 e 的搜索结果
+func NewCachedEngine(e Engine, c cache.Cache, cfg CacheConfig) *CachedEngine {
+	if cfg.TTL <= 0 {
+		cfg.TTL = time.Minute
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "search:"
+	}
+	return &CachedEngine{Engine: e, cache: c, ttl: cfg.TTL, prefix: cfg.Prefix}
+}
+
+// Stats 返回缓存命中统计
+func (e *CachedEngine) Stats() CacheStats {
+	return CacheStats{Hits: e.hits.Load(), Misses: e.misses.Load(), Invalidations: e.invalidations.Load()}
+}
+
+// Search 命中缓存时直接返回，否则查询后按请求涉及的文档类型打标签写入缓存；Explain 请求不缓存
+func (e *CachedEngine) Search(ctx context.Context, req SearchRequest) (SearchResult, error) {
+	if req.Explain {
+		return e.Engine.Search(ctx, req)
+	}
+	key, err := e.resultKey(req)
+	if err != nil {
+		return e.Engine.Search(ctx, req)
+	}
+	if raw, ok := e.cache.Get(ctx, key); ok {
+		var cached SearchResult
+		if decodeCached(raw, &cached) == nil {
+			e.hits.Add(1)
+			return cached, nil
+		}
+	}
+	e.misses.Add(1)
+
+	gen := e.generation.Load()
+	result, err := e.Engine.Search(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	if gen == e.generation.Load() {
+		if data, err := json.Marshal(result); err == nil {
+			_ = e.cache.SetWithTags(ctx, key, string(data), e.ttl, e.typeTags(requestTypes(req)...)...)
+		}
+	}
+	return result, nil
+}
+
+// Index 写入后使该类型及未按类型过滤的结果失效
+func (e *CachedEngine) Index(ctx context.Context, doc Doc) error {
+	if err := e.Engine.Index(ctx, doc); err != nil {
+		return err
+	}
+	e.invalidate(ctx, doc.Type)
+	return nil
+}
+
+// IndexBatch 写入后使批次中涉及类型的结果失效，部分失败时同样失效
+func (e *CachedEngine) IndexBatch(ctx context.Context, docs []Doc) error {
+	err := e.Engine.IndexBatch(ctx, docs)
+	types := make([]string, 0, 1)
+	for _, d := range docs {
+		if !slices.Contains(types, d.Type) {
+			types = append(types, d.Type)
+		}
+	}
+	if len(types) > 0 {
+		e.invalidate(ctx, types...)
+	}
+	return err
+}
+
+// Delete 删除时无法得知文档类型，使全部结果失效
+func (e *CachedEngine) Delete(ctx context.Context, id string) error {
+	if err := e.Engine.Delete(ctx, id); err != nil {
+		return err
+	}
+	e.invalidate(ctx, "")
+	return nil
+}
+
+// Recreate 重建索引后使全部结果失效
+func (e *CachedEngine) Recreate(ctx context.Context, mapping []byte) error {
+	err := e.Engine.Recreate(ctx, mapping)
+	e.invalidate(ctx, "")
+	return err
+}
+
+// Invalidate 使全部缓存结果失效，用于绕过引擎直接修改索引（如重建、恢复快照）之后
+func (e *CachedEngine) Invalidate(ctx context.Context) {
+	e.invalidate(ctx, "")
+}
+
+// invalidate 使指定类型的结果失效，类型为空表示未知类型，删除全部结果
+func (e *CachedEngine) invalidate(ctx context.Context, types ...string) {
+	e.generation.Add(1)
+	e.invalidations.Add(1)
+	if slices.Contains(types, "") {
+		if _, err := e.cache.DeleteByPrefix(ctx, e.prefix); err != nil {
+			log.Printf("search cache invalidation failed: %v", err)
+		}
+		return
+	}
+	if err := e.cache.InvalidateTag(ctx, e.typeTags(append(types, allTypes)...)...); err != nil {
+		log.Printf("search cache invalidation failed: %v", err)
+	}
+}
+
+func (e *CachedEngine) typeTags(types ...string) []string {
+	tags := make([]string, len(types))
+	for i, t := range types {
+		tags[i] = e.prefix + "type:" + t
+	}
+	return tags
+}
+
+// requestTypes 请求通过 MustTerms 限定的文档类型，未限定时返回 allTypes
+func requestTypes(req SearchRequest) []string {
+	if types := req.MustTerms["type"]; len(types) > 0 {
+		return types
+	}
+	return []string{allTypes}
+}
+
+// resultKey 由规范化后的请求生成缓存键，字段顺序与词项顺序不影响结果的部分先排序；
+// 带访问过滤的请求按可见范围分别缓存
+func (e *CachedEngine) resultKey(req SearchRequest) (string, error) {
+	var payload any = canonicalRequest(req)
+	if req.Access != nil {
+		payload = struct {
+			Request SearchRequest
+			Access  AccessFilter
+		}{canonicalRequest(req), AccessFilter{Field: req.Access.Field, Principals: sortedCopy(req.Access.Principals)}}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return e.prefix + "result:" + hex.EncodeToString(sum[:]), nil
+}
+
+func canonicalRequest(req SearchRequest) SearchRequest {
+	req.SearchFields = sortedCopy(req.SearchFields)
+	req.IncludeFields = sortedCopy(req.IncludeFields)
+	req.HighlightFields = sortedCopy(req.HighlightFields)
+	req.MustTerms = sortedTerms(req.MustTerms)
+	req.MustNotTerms = sortedTerms(req.MustNotTerms)
+	req.ShouldTerms = sortedTerms(req.ShouldTerms)
+	if len(req.Facets) > 1 {
+		req.Facets = slices.Clone(req.Facets)
+		sort.Slice(req.Facets, func(i, j int) bool { return req.Facets[i].Name < req.Facets[j].Name })
+	}
+	if req.Size <= 0 {
+		req.Size = 10
+	}
+	if req.From < 0 {
+		req.From = 0
+	}
+	return req
+}
+
+func sortedCopy(s []string) []string {
+	if len(s) < 2 {
+		return s
+	}
+	s = slices.Clone(s)
+	sort.Strings(s)
+	return s
+}
+
+func sortedTerms(terms map[string][]string) map[string][]string {
+	if len(terms) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(terms))
+	for field, values := range terms {
+		out[field] = sortedCopy(values)
+	}
+	return out
+}
+
+func decodeCached(raw any, v any) error {
+	switch data := raw.(type) {
+	case string:
+		return json.Unmarshal([]byte(data), v)
+	case []byte:
+		return json.Unmarshal(data, v)
+	default:
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	}
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"HibiscusIM/pkg/cache"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingEngine 记录 Search 调用次数，按请求中的 type 过滤返回已索引的文档ID
+type countingEngine struct {
+	Engine
+	docs     map[string]string // id -> type
+	searches int
+}
+
+func (e *countingEngine) Index(_ context.Context, doc Doc) error {
+	e.docs[doc.ID] = doc.Type
+	return nil
+}
+
+func (e *countingEngine) IndexBatch(ctx context.Context, docs []Doc) error {
+	for _, d := range docs {
+		_ = e.Index(ctx, d)
+	}
+	return nil
+}
+
+func (e *countingEngine) Delete(_ context.Context, id string) error {
+	delete(e.docs, id)
+	return nil
+}
+
+func (e *countingEngine) Recreate(context.Context, []byte) error {
+	e.docs = map[string]string{}
+	return nil
+}
+
+func (e *countingEngine) Search(_ context.Context, req SearchRequest) (SearchResult, error) {
+	e.searches++
+	types := req.MustTerms["type"]
+	var res SearchResult
+	for id, typ := range e.docs {
+		if len(types) == 0 || types[0] == typ {
+			res.Hits = append(res.Hits, Hit{ID: id, Score: 1})
+		}
+	}
+	res.Total = uint64(len(res.Hits))
+	return res, nil
+}
+
+func TestCachedEngine(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingEngine{docs: map[string]string{}}
+	e := NewCachedEngine(inner, cache.NewLocalCache(cache.LocalConfig{
+		MaxSize:           100,
+		DefaultExpiration: time.Minute,
+		CleanupInterval:   time.Minute,
+	}), CacheConfig{TTL: time.Minute})
+	require.NoError(t, e.IndexBatch(ctx, []Doc{{ID: "a1", Type: "article"}, {ID: "n1", Type: "note"}}))
+
+	articles := SearchRequest{Keyword: "hello", SearchFields: []string{"title", "body"}, MustTerms: map[string][]string{"type": {"article"}}}
+	all := SearchRequest{Keyword: "hello"}
+
+	res, err := e.Search(ctx, articles)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1"}, hitIDs(res))
+	res, err = e.Search(ctx, all)
+	require.NoError(t, err)
+	assert.Len(t, res.Hits, 2)
+	assert.Equal(t, 2, inner.searches)
+
+	// 字段顺序与默认分页不同的等价请求命中同一缓存
+	res, err = e.Search(ctx, SearchRequest{Keyword: "hello", SearchFields: []string{"body", "title"}, MustTerms: map[string][]string{"type": {"article"}}, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1"}, hitIDs(res))
+	assert.Equal(t, 2, inner.searches)
+
+	// note 变更只使 note 与未按类型过滤的结果失效
+	require.NoError(t, e.Index(ctx, Doc{ID: "n2", Type: "note"}))
+	_, err = e.Search(ctx, articles)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inner.searches)
+	res, err = e.Search(ctx, all)
+	require.NoError(t, err)
+	assert.Len(t, res.Hits, 3)
+	assert.Equal(t, 3, inner.searches)
+
+	// 删除时类型未知，全部失效
+	require.NoError(t, e.Delete(ctx, "a1"))
+	res, err = e.Search(ctx, articles)
+	require.NoError(t, err)
+	assert.Empty(t, res.Hits)
+	assert.Equal(t, 4, inner.searches)
+
+	// Explain 不走缓存
+	_, err = e.Search(ctx, SearchRequest{Keyword: "hello", Explain: true})
+	require.NoError(t, err)
+	_, err = e.Search(ctx, SearchRequest{Keyword: "hello", Explain: true})
+	require.NoError(t, err)
+	assert.Equal(t, 6, inner.searches)
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 4, Invalidations: 3}, e.Stats())
+
+	// 重建索引后全部失效
+	_, err = e.Search(ctx, all)
+	require.NoError(t, err)
+	require.NoError(t, e.Recreate(ctx, nil))
+	res, err = e.Search(ctx, all)
+	require.NoError(t, err)
+	assert.Empty(t, res.Hits)
+	assert.Equal(t, 8, inner.searches)
+}
@@ -0,0 +1,376 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// 数据库词典条目类型
+const (
+	DictionaryKindSynonym  = "synonym"
+	DictionaryKindStopword = "stopword"
+)
+
+// SynonymRule 同义词规则：To 为空时 Terms 互为同义词；否则查询 Terms 中任一词时同时匹配 To，反之不成立
+type SynonymRule struct {
+	Terms []string `json:"terms"`
+	To    []string `json:"to,omitempty"`
+}
+
+// ParseSynonymRule 解析一行同义词规则，格式与 Solr 相同："a, b, c" 或 "a, b => c, d"
+func ParseSynonymRule(line string) (SynonymRule, error) {
+	split := func(s string) []string {
+		var out []string
+		for _, t := range strings.Split(s, ",") {
+			if t = normalizeTerm(t); t != "" {
+				out = append(out, t)
+			}
+		}
+		return out
+	}
+	if from, to, ok := strings.Cut(line, "=>"); ok {
+		rule := SynonymRule{Terms: split(from), To: split(to)}
+		if len(rule.Terms) == 0 || len(rule.To) == 0 {
+			return SynonymRule{}, fmt.Errorf("invalid synonym rule %q: both sides of => are required", line)
+		}
+		return rule, nil
+	}
+	rule := SynonymRule{Terms: split(line)}
+	if len(rule.Terms) < 2 {
+		return SynonymRule{}, fmt.Errorf("invalid synonym rule %q: at least two terms are required", line)
+	}
+	return rule, nil
+}
+
+// Dictionary 同义词与停用词词典，只读，重载时整体替换
+type Dictionary struct {
+	synonyms  map[string][]string
+	stopwords map[string]struct{}
+	// 同义词中最长的词数，用于匹配多词同义词
+	maxWords int
+}
+
+// NewDictionary 创建词典，词条统一转为小写
+func NewDictionary(rules []SynonymRule, stopwords []string) *Dictionary {
+	d := &Dictionary{synonyms: map[string][]string{}, stopwords: map[string]struct{}{}, maxWords: 1}
+	add := func(term string, targets []string) {
+		term = normalizeTerm(term)
+		if term == "" {
+			return
+		}
+		for _, t := range targets {
+			if t = normalizeTerm(t); t != "" && t != term && !slices.Contains(d.synonyms[term], t) {
+				d.synonyms[term] = append(d.synonyms[term], t)
+			}
+		}
+		d.maxWords = max(d.maxWords, len(strings.Fields(term)))
+	}
+	for _, r := range rules {
+		targets := r.To
+		if len(targets) == 0 {
+			targets = r.Terms
+		}
+		for _, term := range r.Terms {
+			add(term, targets)
+		}
+	}
+	for _, w := range stopwords {
+		if w = normalizeTerm(w); w != "" {
+			d.stopwords[w] = struct{}{}
+		}
+	}
+	return d
+}
+
+// Empty 词典为空
+func (d *Dictionary) Empty() bool {
+	return d == nil || (len(d.synonyms) == 0 && len(d.stopwords) == 0)
+}
+
+// Synonyms 词的同义词，不包含词本身
+func (d *Dictionary) Synonyms(term string) []string {
+	if d == nil {
+		return nil
+	}
+	return d.synonyms[normalizeTerm(term)]
+}
+
+// IsStopword 是否为停用词
+func (d *Dictionary) IsStopword(term string) bool {
+	if d == nil {
+		return false
+	}
+	_, ok := d.stopwords[normalizeTerm(term)]
+	return ok
+}
+
+// expandTerms 将文本切分为词组，每组第一个为原词，其后为同义词；多词同义词按最长匹配。
+// 停用词被去掉，全部为停用词时保留原文；没有命中任何同义词或停用词时返回 nil，调用方沿用原查询
+func (d *Dictionary) expandTerms(text string) [][]string {
+	if d.Empty() {
+		return nil
+	}
+	tokens := dictionaryTokens(text)
+	var groups [][]string
+	changed := false
+	for i := 0; i < len(tokens); {
+		n := min(d.maxWords, len(tokens)-i)
+		for ; n > 1; n-- {
+			if _, ok := d.synonyms[strings.Join(tokens[i:i+n], " ")]; ok {
+				break
+			}
+		}
+		term := strings.Join(tokens[i:i+n], " ")
+		i += n
+		if syns := d.synonyms[term]; len(syns) > 0 {
+			groups = append(groups, append([]string{term}, syns...))
+			changed = true
+			continue
+		}
+		if _, ok := d.stopwords[term]; ok {
+			changed = true
+			continue
+		}
+		groups = append(groups, []string{term})
+	}
+	if !changed {
+		return nil
+	}
+	if len(groups) == 0 {
+		for _, t := range tokens {
+			groups = append(groups, []string{t})
+		}
+	}
+	return groups
+}
+
+// expandQueryString 扩展 query string 语法中的普通词：去掉停用词并追加同义词（多词同义词加引号），
+// 带字段、运算符或引号的词保持不变
+func (d *Dictionary) expandQueryString(qs string) string {
+	if d.Empty() {
+		return qs
+	}
+	fields := strings.Fields(qs)
+	var plain []string
+	for _, f := range fields {
+		if isPlainTerm(f) {
+			plain = append(plain, f)
+		}
+	}
+	groups := d.expandTerms(strings.Join(plain, " "))
+	if groups == nil {
+		return qs
+	}
+	out := make([]string, 0, len(fields)+len(groups))
+	for _, f := range fields {
+		if !isPlainTerm(f) {
+			out = append(out, f)
+		}
+	}
+	for _, g := range groups {
+		for _, t := range g {
+			if strings.Contains(t, " ") {
+				t = `"` + t + `"`
+			}
+			out = append(out, t)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// DictionarySource 词典来源
+type DictionarySource interface {
+	LoadDictionary(ctx context.Context) (rules []SynonymRule, stopwords []string, err error)
+}
+
+// FileDictionarySource 从文件加载词典：同义词文件每行一条规则，停用词文件每行一个词，# 开头为注释；路径为空时跳过
+type FileDictionarySource struct {
+	SynonymsPath  string
+	StopwordsPath string
+}
+
+func (s FileDictionarySource) LoadDictionary(ctx context.Context) ([]SynonymRule, []string, error) {
+	var rules []SynonymRule
+	if s.SynonymsPath != "" {
+		lines, err := readDictionaryLines(s.SynonymsPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, line := range lines {
+			rule, err := ParseSynonymRule(line)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s:%d: %w", s.SynonymsPath, i+1, err)
+			}
+			rules = append(rules, rule)
+		}
+	}
+	var stopwords []string
+	if s.StopwordsPath != "" {
+		lines, err := readDictionaryLines(s.StopwordsPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		stopwords = lines
+	}
+	return rules, stopwords, nil
+}
+
+// readDictionaryLines 读取非空、非注释行
+func readDictionaryLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, sc.Err()
+}
+
+// SearchDictionaryEntry 数据库中的同义词规则或停用词，修改后需重载词典才生效
+type SearchDictionaryEntry struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// synonym 或 stopword
+	Kind string `json:"kind" gorm:"size:16;index"`
+	// 同义词规则（格式同 ParseSynonymRule）或单个停用词
+	Value     string    `json:"value" gorm:"size:512"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DBDictionarySource 从 search_dictionary_entries 表加载词典，存在格式错误的条目时返回全部错误
+type DBDictionarySource struct {
+	DB *gorm.DB
+}
+
+func (s DBDictionarySource) LoadDictionary(ctx context.Context) ([]SynonymRule, []string, error) {
+	var entries []SearchDictionaryEntry
+	if err := s.DB.WithContext(ctx).Order("id").Find(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+	var (
+		rules     []SynonymRule
+		stopwords []string
+		errs      []error
+	)
+	for _, e := range entries {
+		switch e.Kind {
+		case DictionaryKindSynonym:
+			rule, err := ParseSynonymRule(e.Value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("entry %d: %w", e.ID, err))
+				continue
+			}
+			rules = append(rules, rule)
+		case DictionaryKindStopword:
+			stopwords = append(stopwords, e.Value)
+		default:
+			errs = append(errs, fmt.Errorf("entry %d: unknown kind %q", e.ID, e.Kind))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return rules, stopwords, nil
+}
+
+// DictionaryStats 当前词典的规模与加载时间
+type DictionaryStats struct {
+	// 有同义词的词数
+	Synonyms  int       `json:"synonyms"`
+	Stopwords int       `json:"stopwords"`
+	LoadedAt  time.Time `json:"loadedAt"`
+}
+
+// Dictionaries 可热重载的词典，合并多个来源；查询时读取当前词典，重载失败时保留旧词典
+type Dictionaries struct {
+	sources []DictionarySource
+
+	mu      sync.Mutex // 串行化重载
+	current atomic.Pointer[Dictionary]
+	stats   atomic.Pointer[DictionaryStats]
+}
+
+// NewDictionaries 创建词典集合，需调用 Reload 加载
+func NewDictionaries(sources ...DictionarySource) *Dictionaries {
+	return &Dictionaries{sources: sources}
+}
+
+// Current 当前词典，未加载或 d 为 nil 时返回 nil
+func (d *Dictionaries) Current() *Dictionary {
+	if d == nil {
+		return nil
+	}
+	return d.current.Load()
+}
+
+// Stats 当前词典统计
+func (d *Dictionaries) Stats() DictionaryStats {
+	if s := d.stats.Load(); s != nil {
+		return *s
+	}
+	return DictionaryStats{}
+}
+
+// Reload 从全部来源重新加载词典并原子替换，任一来源失败时不替换
+func (d *Dictionaries) Reload(ctx context.Context) (DictionaryStats, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var (
+		rules     []SynonymRule
+		stopwords []string
+	)
+	for _, src := range d.sources {
+		r, s, err := src.LoadDictionary(ctx)
+		if err != nil {
+			return d.Stats(), err
+		}
+		rules = append(rules, r...)
+		stopwords = append(stopwords, s...)
+	}
+	dict := NewDictionary(rules, stopwords)
+	stats := DictionaryStats{Synonyms: len(dict.synonyms), Stopwords: len(dict.stopwords), LoadedAt: time.Now()}
+	d.current.Store(dict)
+	d.stats.Store(&stats)
+	return stats, nil
+}
+
+// normalizeTerm 转小写并合并词内空白
+func normalizeTerm(s string) string {
+	return strings.Join(dictionaryTokens(s), " ")
+}
+
+// dictionaryTokens 按非字母数字字符切分并转小写
+func dictionaryTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// isPlainTerm query string 中不含语法字符的普通词
+func isPlainTerm(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
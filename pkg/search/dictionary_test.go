@@ -0,0 +1,141 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestParseSynonymRule(t *testing.T) {
+	rule, err := ParseSynonymRule("Laptop, notebook ,  NOTE  book")
+	require.NoError(t, err)
+	assert.Equal(t, SynonymRule{Terms: []string{"laptop", "notebook", "note book"}}, rule)
+
+	rule, err = ParseSynonymRule("tv => television")
+	require.NoError(t, err)
+	assert.Equal(t, SynonymRule{Terms: []string{"tv"}, To: []string{"television"}}, rule)
+
+	_, err = ParseSynonymRule("lonely")
+	assert.Error(t, err)
+	_, err = ParseSynonymRule("a => ")
+	assert.Error(t, err)
+}
+
+func TestDictionaryExpand(t *testing.T) {
+	d := NewDictionary([]SynonymRule{
+		{Terms: []string{"laptop", "notebook"}},
+		{Terms: []string{"tv"}, To: []string{"television"}},
+		{Terms: []string{"new york", "nyc"}},
+	}, []string{"please", "the"})
+
+	assert.Equal(t, []string{"television"}, d.Synonyms("TV"))
+	assert.Empty(t, d.Synonyms("television"))
+	assert.True(t, d.IsStopword("Please"))
+
+	assert.Equal(t, [][]string{{"laptop", "notebook"}, {"new york", "nyc"}},
+		d.expandTerms("please the laptop New York"))
+	assert.Nil(t, d.expandTerms("plain words"))
+	// 全部为停用词时保留原词
+	assert.Equal(t, [][]string{{"the"}, {"please"}}, d.expandTerms("the please"))
+
+	assert.Equal(t, `title:x +must laptop notebook`, d.expandQueryString("title:x +must please laptop"))
+	assert.Equal(t, `nyc "new york"`, d.expandQueryString("nyc"))
+	assert.Equal(t, "plain words", d.expandQueryString("plain words"))
+
+	var nilDict *Dictionary
+	assert.Nil(t, nilDict.expandTerms("laptop"))
+	assert.Equal(t, "laptop", nilDict.expandQueryString("laptop"))
+}
+
+func TestSearchWithDictionaries(t *testing.T) {
+	dir := t.TempDir()
+	synonyms := filepath.Join(dir, "synonyms.txt")
+	stopwords := filepath.Join(dir, "stopwords.txt")
+	require.NoError(t, os.WriteFile(synonyms, []byte("# 电脑\nlaptop, notebook\n"), 0o644))
+	require.NoError(t, os.WriteFile(stopwords, []byte("cheap\n"), 0o644))
+
+	dicts := NewDictionaries(FileDictionarySource{SynonymsPath: synonyms, StopwordsPath: stopwords})
+	stats, err := dicts.Reload(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Synonyms)
+	assert.Equal(t, 1, stats.Stopwords)
+
+	e, err := New(Config{IndexPath: filepath.Join(dir, "test.bleve"), Dictionaries: dicts}, BuildIndexMapping(""))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = e.Close() })
+	ctx := context.Background()
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		{ID: "1", Type: "article", Fields: map[string]any{"title": "gaming laptop"}},
+		{ID: "2", Type: "article", Fields: map[string]any{"title": "thin notebook"}},
+		{ID: "3", Type: "article", Fields: map[string]any{"title": "paper notepad"}},
+	}))
+
+	// 停用词 cheap 被去掉，不再要求命中
+	match := SearchRequest{Matches: []ClauseMatch{{Field: "title", Query: "cheap laptop", Operator: "and"}}, MinShould: 1}
+	res, err := e.Search(ctx, match)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "2"}, hitIDs(res))
+
+	res, err = e.Search(ctx, SearchRequest{Keyword: "notebook", SearchFields: []string{"title"}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "2"}, hitIDs(res))
+
+	// 修改词典文件后热重载立即生效
+	require.NoError(t, os.WriteFile(synonyms, []byte("notebook, notepad\n"), 0o644))
+	_, err = dicts.Reload(ctx)
+	require.NoError(t, err)
+	res, err = e.Search(ctx, SearchRequest{Keyword: "notebook", SearchFields: []string{"title"}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2", "3"}, hitIDs(res))
+
+	// 加载失败时保留旧词典
+	require.NoError(t, os.WriteFile(synonyms, []byte("broken\n"), 0o644))
+	_, err = dicts.Reload(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"notepad"}, dicts.Current().Synonyms("notebook"))
+}
+
+func TestDBDictionarySource(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:search_dictionary?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&SearchDictionaryEntry{}))
+	require.NoError(t, db.Create(&[]SearchDictionaryEntry{
+		{Kind: DictionaryKindSynonym, Value: "tv => television"},
+		{Kind: DictionaryKindStopword, Value: "please"},
+	}).Error)
+
+	dicts := NewDictionaries(DBDictionarySource{DB: db})
+	_, err = dicts.Reload(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"television"}, dicts.Current().Synonyms("tv"))
+	assert.True(t, dicts.Current().IsStopword("please"))
+
+	require.NoError(t, db.Create(&SearchDictionaryEntry{Kind: "antonym", Value: "hot, cold"}).Error)
+	_, err = dicts.Reload(context.Background())
+	assert.ErrorContains(t, err, "unknown kind")
+}
+
+func TestBuildESQueryWithDictionary(t *testing.T) {
+	d := NewDictionary([]SynonymRule{{Terms: []string{"tv"}, To: []string{"smart tv"}}}, []string{"cheap"})
+	q := buildESQuery(SearchRequest{
+		Keyword:      "cheap tv",
+		SearchFields: []string{"title"},
+		Matches:      []ClauseMatch{{Field: "title", Query: "cheap tv", Operator: "and"}},
+	}, nil, d)
+	data, err := json.Marshal(q)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bool":{
+		"must":[{"query_string":{"query":"tv \"smart tv\"","fields":["title"]}}],
+		"should":[{"bool":{"must":[{"bool":{"should":[
+			{"match_phrase":{"title":{"query":"tv"}}},
+			{"match_phrase":{"title":{"query":"smart tv"}}}
+		],"minimum_should_match":1}}]}}]
+	}}`, string(data))
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// 内置引擎驱动
+const (
+	DriverBleve         = "bleve"
+	DriverElasticsearch = "elasticsearch"
+	DriverOpenSearch    = "opensearch"
+)
+
+// EngineDriver 引擎驱动，根据配置创建 Engine，mapping 仅对本地 bleve 驱动生效
+type EngineDriver func(cfg Config, m mapping.IndexMapping) (Engine, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]EngineDriver{
+		DriverBleve:         newBleveEngine,
+		DriverElasticsearch: newElasticEngine,
+		DriverOpenSearch:    newElasticEngine,
+	}
+)
+
+// RegisterDriver 注册自定义引擎驱动，同名驱动会被覆盖
+func RegisterDriver(name string, driver EngineDriver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	drivers[strings.ToLower(name)] = driver
+}
+
+// Drivers 已注册的驱动名称
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 按 cfg.Driver 创建搜索引擎，未指定时使用 bleve
+func New(cfg Config, m mapping.IndexMapping) (Engine, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Driver))
+	if name == "" {
+		name = DriverBleve
+	}
+	driversMu.RLock()
+	driver, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown search driver: %s", cfg.Driver)
+	}
+	return driver(cfg, m)
+}
@@ -0,0 +1,1075 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/mapping"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// elasticEngine 基于 Elasticsearch/OpenSearch REST API 的搜索引擎，查询条件与 bleve 引擎保持一致
+type elasticEngine struct {
+	cfg           Config
+	es            ElasticsearchConfig
+	defaultFields []string
+	client        *http.Client
+	next          uint32
+	mu            sync.RWMutex
+	closed        bool
+}
+
+// newElasticEngine 创建 Elasticsearch 引擎，索引不存在时自动创建
+func newElasticEngine(cfg Config, _ mapping.IndexMapping) (Engine, error) {
+	es := cfg.Elasticsearch
+	es.Addresses = nil
+	for _, addr := range cfg.Elasticsearch.Addresses {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			es.Addresses = append(es.Addresses, strings.TrimSuffix(addr, "/"))
+		}
+	}
+	if len(es.Addresses) == 0 {
+		es.Addresses = []string{"http://127.0.0.1:9200"}
+	}
+	if es.Index == "" {
+		return nil, errors.New("elasticsearch index name is required")
+	}
+	e := &elasticEngine{
+		cfg:           cfg,
+		es:            es,
+		defaultFields: cfg.DefaultSearchFields,
+		client:        &http.Client{},
+	}
+
+	ctx := context.Background()
+	if cfg.OpenTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.OpenTimeout)
+		defer cancel()
+	}
+	if err := e.ensureIndex(ctx); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *elasticEngine) guard() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// ensureIndex 检查索引，不存在时创建
+func (e *elasticEngine) ensureIndex(ctx context.Context) error {
+	status, _, err := e.do(ctx, http.MethodHead, "/"+url.PathEscape(e.es.Index), nil, "")
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+	var body io.Reader
+	if len(e.es.IndexBody) > 0 {
+		body = bytes.NewReader(e.es.IndexBody)
+	}
+	status, data, err := e.do(ctx, http.MethodPut, "/"+url.PathEscape(e.es.Index), body, "application/json")
+	if err != nil {
+		return err
+	}
+	// 多个实例同时启动时可能已被其他实例创建
+	if status >= 300 && !bytes.Contains(data, []byte("resource_already_exists_exception")) {
+		return esError(status, data)
+	}
+	return nil
+}
+
+// do 发送请求，连接失败时依次尝试其他节点
+func (e *elasticEngine) do(ctx context.Context, method, path string, body io.Reader, contentType string) (int, []byte, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return 0, nil, err
+		}
+	}
+	start := int(atomic.AddUint32(&e.next, 1))
+	var lastErr error
+	for i := 0; i < len(e.es.Addresses); i++ {
+		addr := e.es.Addresses[(start+i)%len(e.es.Addresses)]
+		req, err := http.NewRequestWithContext(ctx, method, addr+path, bytes.NewReader(payload))
+		if err != nil {
+			return 0, nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		switch {
+		case e.es.APIKey != "":
+			req.Header.Set("Authorization", "ApiKey "+e.es.APIKey)
+		case e.es.Username != "":
+			req.SetBasicAuth(e.es.Username, e.es.Password)
+		}
+		resp, err := e.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, nil, err
+		}
+		return resp.StatusCode, data, nil
+	}
+	return 0, nil, fmt.Errorf("elasticsearch: all nodes failed: %w", lastErr)
+}
+
+// doJSON 发送 JSON 请求，非 2xx 时返回错误，out 不为空时解析响应
+func (e *elasticEngine) doJSON(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	status, data, err := e.do(ctx, method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return esError(status, data)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// esError 解析 Elasticsearch 错误响应
+func esError(status int, data []byte) error {
+	var body struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error.Type != "" {
+		return fmt.Errorf("elasticsearch: [%d] %s: %s", status, body.Error.Type, body.Error.Reason)
+	}
+	return fmt.Errorf("elasticsearch: [%d] %s", status, strings.TrimSpace(string(data)))
+}
+
+func (e *elasticEngine) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.cfg.QueryTimeout > 0 {
+		return context.WithTimeout(ctx, e.cfg.QueryTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// docPath 文档路径，附带刷新策略
+func (e *elasticEngine) docPath(id string) string {
+	p := "/" + url.PathEscape(e.es.Index) + "/_doc/" + url.PathEscape(id)
+	if e.es.Refresh != "" {
+		p += "?refresh=" + url.QueryEscape(e.es.Refresh)
+	}
+	return p
+}
+
+// docSource 文档字段，Type 写入 type 字段
+func docSource(doc Doc) map[string]any {
+	data := make(map[string]any, len(doc.Fields)+1)
+	for k, v := range doc.Fields {
+		data[k] = v
+	}
+	if doc.Type != "" {
+		data["type"] = doc.Type
+	}
+	return data
+}
+
+func (e *elasticEngine) Index(ctx context.Context, doc Doc) error {
+	if err := e.guard(); err != nil {
+		return err
+	}
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+	return e.doJSON(ctx, http.MethodPut, e.docPath(doc.ID), docSource(doc), nil)
+}
+
+func (e *elasticEngine) IndexBatch(ctx context.Context, docs []Doc) error {
+	if err := e.guard(); err != nil {
+		return err
+	}
+	bs := e.cfg.BatchSize
+	if bs <= 0 {
+		bs = 200
+	}
+	path := "/_bulk"
+	if e.es.Refresh != "" {
+		path += "?refresh=" + url.QueryEscape(e.es.Refresh)
+	}
+	for i := 0; i < len(docs); i += bs {
+		end := i + bs
+		if end > len(docs) {
+			end = len(docs)
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, d := range docs[i:end] {
+			if err := enc.Encode(map[string]any{"index": map[string]string{"_index": e.es.Index, "_id": d.ID}}); err != nil {
+				return err
+			}
+			if err := enc.Encode(docSource(d)); err != nil {
+				return err
+			}
+		}
+		status, data, err := e.do(ctx, http.MethodPost, path, &buf, "application/x-ndjson")
+		if err != nil {
+			return err
+		}
+		if status >= 300 {
+			return esError(status, data)
+		}
+		if err := bulkError(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bulkError 返回批量写入中第一个失败项的错误
+func bulkError(data []byte) error {
+	var res struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	if !res.Errors {
+		return nil
+	}
+	failed := 0
+	var first error
+	for _, item := range res.Items {
+		for _, r := range item {
+			if r.Status >= 300 {
+				failed++
+				if first == nil {
+					first = fmt.Errorf("doc %s: %s: %s", r.ID, r.Error.Type, r.Error.Reason)
+				}
+			}
+		}
+	}
+	return fmt.Errorf("elasticsearch: bulk index failed for %d docs, first: %w", failed, first)
+}
+
+func (e *elasticEngine) Delete(ctx context.Context, id string) error {
+	if err := e.guard(); err != nil {
+		return err
+	}
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+	status, data, err := e.do(ctx, http.MethodDelete, e.docPath(id), nil, "")
+	if err != nil {
+		return err
+	}
+	// 与 bleve 一致，删除不存在的文档不报错
+	if status >= 300 && status != http.StatusNotFound {
+		return esError(status, data)
+	}
+	return nil
+}
+
+// esSearchResponse Elasticsearch 搜索响应中用到的部分
+type esSearchResponse struct {
+	Took  int64  `json:"took"`
+	PitID string `json:"pit_id"`
+	Hits  struct {
+		Total json.RawMessage `json:"total"`
+		Hits  []struct {
+			ID          string              `json:"_id"`
+			Score       *float64            `json:"_score"`
+			Source      map[string]any      `json:"_source"`
+			Highlight   map[string][]string `json:"highlight"`
+			Explanation *esExplanation      `json:"_explanation"`
+			Sort        []json.RawMessage   `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]struct {
+		SumOtherDocCount int `json:"sum_other_doc_count"`
+		Buckets          []struct {
+			Key      any `json:"key"`
+			DocCount int `json:"doc_count"`
+		} `json:"buckets"`
+	} `json:"aggregations"`
+}
+
+type esExplanation struct {
+	Value       float64          `json:"value"`
+	Description string           `json:"description"`
+	Details     []*esExplanation `json:"details"`
+}
+
+// toBleve 转换为与 bleve 相同的评分解释结构
+func (x *esExplanation) toBleve() *bsearch.Explanation {
+	if x == nil {
+		return nil
+	}
+	out := &bsearch.Explanation{Value: x.Value, Message: x.Description}
+	for _, d := range x.Details {
+		out.Children = append(out.Children, d.toBleve())
+	}
+	return out
+}
+
+// parseTotal 兼容 7.x 以上的 {"value":n} 与旧版本的数字
+func parseTotal(raw json.RawMessage) uint64 {
+	var obj struct {
+		Value uint64 `json:"value"`
+	}
+	if json.Unmarshal(raw, &obj) == nil && obj.Value > 0 {
+		return obj.Value
+	}
+	var n uint64
+	_ = json.Unmarshal(raw, &n)
+	return n
+}
+
+func (e *elasticEngine) Search(ctx context.Context, req SearchRequest) (SearchResult, error) {
+	if err := e.guard(); err != nil {
+		return SearchResult{}, err
+	}
+	sorts, err := resolveSort(nil, sortFields(req))
+	if err != nil {
+		return SearchResult{}, err
+	}
+	query := buildESQuery(req, e.defaultFields, e.cfg.Dictionaries.Current())
+	body, err := buildESSearchBody(req, query, sorts)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	res, err := e.search(ctx, "/"+url.PathEscape(e.es.Index)+"/_search", body)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	out := res.toResult()
+	if req.Explain {
+		if data, err := json.Marshal(query); err == nil {
+			out.Query = data
+		}
+	}
+	return out, nil
+}
+
+func (e *elasticEngine) search(ctx context.Context, path string, body map[string]any) (esSearchResponse, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+	var res esSearchResponse
+	err := e.doJSON(ctx, http.MethodPost, path, body, &res)
+	return res, err
+}
+
+// toResult 转换为与 bleve 一致的搜索结果
+func (res *esSearchResponse) toResult() SearchResult {
+	out := SearchResult{
+		Total:  parseTotal(res.Hits.Total),
+		Took:   time.Duration(res.Took) * time.Millisecond,
+		Hits:   make([]Hit, 0, len(res.Hits.Hits)),
+		Facets: map[string]FacetResult{},
+	}
+	for _, h := range res.Hits.Hits {
+		hit := Hit{ID: h.ID, Fields: h.Source, Fragments: h.Highlight, Explanation: h.Explanation.toBleve()}
+		if h.Score != nil {
+			hit.Score = *h.Score
+		}
+		for _, v := range h.Sort {
+			hit.Sort = append(hit.Sort, string(v))
+		}
+		out.Hits = append(out.Hits, hit)
+	}
+	for name, agg := range res.Aggregations {
+		fr := FacetResult{Total: agg.SumOtherDocCount}
+		for _, b := range agg.Buckets {
+			fr.Terms = append(fr.Terms, FacetTerm{Term: fmt.Sprint(b.Key), Count: b.DocCount})
+			fr.Total += b.DocCount
+		}
+		out.Facets[name] = fr
+	}
+	return out
+}
+
+// esScrollKeepAlive 游标分页时 point in time 的保留时间，每翻一页续期
+const esScrollKeepAlive = "2m"
+
+// Scroll 基于 search_after 的游标分页。Elasticsearch 使用 point in time 保证翻页期间结果一致，
+// 并由其隐式的 _shard_doc 排序打破平局；OpenSearch 以 _id 作为最后的排序字段
+func (e *elasticEngine) Scroll(ctx context.Context, req SearchRequest, cursor string) (ScrollResult, error) {
+	if err := e.guard(); err != nil {
+		return ScrollResult{}, err
+	}
+	usePIT := !strings.EqualFold(e.cfg.Driver, DriverOpenSearch)
+	var tiebreaker *SortField
+	if !usePIT {
+		tiebreaker = &SortField{Field: SortFieldID}
+	}
+	req, hash, err := prepareScroll(req, tiebreaker)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	cur, err := decodeCursor(cursor, hash)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	sorts, err := resolveSort(nil, req.Sort)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	req.SearchAfter = cur.After
+	body, err := buildESSearchBody(req, buildESQuery(req, e.defaultFields, e.cfg.Dictionaries.Current()), sorts)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+
+	path := "/" + url.PathEscape(e.es.Index) + "/_search"
+	if usePIT {
+		if cur.PIT == "" {
+			if cur.PIT, err = e.openPIT(ctx); err != nil {
+				return ScrollResult{}, err
+			}
+		}
+		// 使用 PIT 时请求路径不能带索引名
+		path = "/_search"
+		body["pit"] = map[string]any{"id": cur.PIT, "keep_alive": esScrollKeepAlive}
+	}
+	res, err := e.search(ctx, path, body)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	if res.PitID != "" {
+		cur.PIT = res.PitID
+	}
+	out := ScrollResult{SearchResult: res.toResult()}
+	out.Cursor = nextCursor(out.SearchResult, req.Size, cur)
+	if out.Cursor == "" && cur.PIT != "" {
+		e.closePIT(cur.PIT)
+	}
+	return out, nil
+}
+
+// openPIT 为索引创建 point in time
+func (e *elasticEngine) openPIT(ctx context.Context) (string, error) {
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+	var res struct {
+		ID string `json:"id"`
+	}
+	path := "/" + url.PathEscape(e.es.Index) + "/_pit?keep_alive=" + esScrollKeepAlive
+	if err := e.doJSON(ctx, http.MethodPost, path, nil, &res); err != nil {
+		return "", err
+	}
+	if res.ID == "" {
+		return "", errors.New("elasticsearch returned empty point in time id")
+	}
+	return res.ID, nil
+}
+
+// closePIT 最后一页后释放 point in time，失败时等待其自然过期
+func (e *elasticEngine) closePIT(id string) {
+	ctx, cancel := e.withTimeout(context.Background())
+	defer cancel()
+	_ = e.doJSON(ctx, http.MethodDelete, "/_pit", map[string]any{"id": id}, nil)
+}
+
+// buildESSort 转换排序描述，缺失值位置与排序方式映射为 missing 与 numeric_type
+func buildESSort(sorts []SortField) []any {
+	out := make([]any, 0, len(sorts))
+	for _, s := range sorts {
+		order := "asc"
+		if s.Desc {
+			order = "desc"
+		}
+		if s.Field == SortFieldScore || s.Field == SortFieldID {
+			out = append(out, map[string]any{s.Field: map[string]any{"order": order}})
+			continue
+		}
+		if s.Mode == SortModeGeo {
+			out = append(out, map[string]any{"_geo_distance": map[string]any{
+				s.Field: map[string]any{"lat": s.Origin.Lat, "lon": s.Origin.Lon},
+				"order": order,
+				"unit":  s.Unit,
+			}})
+			continue
+		}
+		spec := map[string]any{"order": order, "missing": "_" + s.Missing}
+		switch s.Mode {
+		case SortModeNumber:
+			spec["numeric_type"] = "double"
+		case SortModeDate:
+			spec["numeric_type"] = "date"
+		}
+		out = append(out, map[string]any{s.Field: spec})
+	}
+	return out
+}
+
+// buildESSearchBody 组装分页、排序、字段、高亮与聚合
+func buildESSearchBody(req SearchRequest, query map[string]any, sorts []SortField) (map[string]any, error) {
+	if err := validateGeoFilters(req); err != nil {
+		return nil, err
+	}
+	if req.Size <= 0 {
+		req.Size = 10
+	}
+	if req.From < 0 {
+		req.From = 0
+	}
+	body := map[string]any{
+		"query":            query,
+		"from":             req.From,
+		"size":             req.Size,
+		"track_total_hits": true,
+	}
+	if len(sorts) > 0 {
+		body["sort"] = buildESSort(sorts)
+	}
+	if len(req.SearchAfter) > 0 {
+		delete(body, "from")
+		body["search_after"] = decodeSearchAfter(req.SearchAfter)
+	}
+	if len(req.IncludeFields) > 0 {
+		body["_source"] = req.IncludeFields
+	}
+	if req.Highlight {
+		opts, err := newHighlightOptions(req)
+		if err != nil {
+			return nil, err
+		}
+		fields := map[string]any{}
+		for _, f := range req.HighlightFields {
+			fields[f] = map[string]any{}
+		}
+		if len(fields) == 0 {
+			fields["*"] = map[string]any{}
+		}
+		hl := map[string]any{
+			"fields":              fields,
+			"pre_tags":            []string{opts.preTag},
+			"post_tags":           []string{opts.postTag},
+			"fragment_size":       opts.fragmentSize,
+			"number_of_fragments": opts.maxFragments,
+		}
+		// html 编码器转义片段正文，与 bleve 引擎的 html 样式一致
+		if opts.style == HighlightStyleHTML {
+			hl["encoder"] = "html"
+		}
+		body["highlight"] = hl
+	}
+	if len(req.Facets) > 0 {
+		aggs := map[string]any{}
+		for _, f := range req.Facets {
+			size := f.Size
+			if size <= 0 {
+				size = 10
+			}
+			aggs[f.Name] = map[string]any{"terms": map[string]any{"field": f.Field, "size": size}}
+		}
+		body["aggs"] = aggs
+	}
+	if req.Explain {
+		body["explain"] = true
+	}
+	return body, nil
+}
+
+// decodeSearchAfter 将 Hit.Sort 中保存的 JSON 文本还原为原始值，数字保持精度
+func decodeSearchAfter(values []string) []any {
+	out := make([]any, 0, len(values))
+	for _, v := range values {
+		dec := json.NewDecoder(strings.NewReader(v))
+		dec.UseNumber()
+		var x any
+		if err := dec.Decode(&x); err != nil {
+			x = v
+		}
+		out = append(out, x)
+	}
+	return out
+}
+
+// buildESQuery 将 SearchRequest 转换为 Elasticsearch bool 查询，语义与 buildQuery 保持一致
+func buildESQuery(req SearchRequest, defaultFields []string, dict *Dictionary) map[string]any {
+	var must, should, mustNot, filter []any
+
+	// 未指定字段的子句在默认字段（或全部字段）上以 query_string 执行
+	anyField := func(qs string, boost *float64) map[string]any {
+		body := map[string]any{"query": qs}
+		if len(defaultFields) > 0 {
+			body["fields"] = defaultFields
+		}
+		if boost != nil {
+			body["boost"] = *boost
+		}
+		return map[string]any{"query_string": body}
+	}
+	withBoost := func(body map[string]any, boost *float64) map[string]any {
+		if boost != nil {
+			body["boost"] = *boost
+		}
+		return body
+	}
+
+	if strings.TrimSpace(req.Keyword) != "" {
+		fields := req.SearchFields
+		if len(fields) == 0 {
+			fields = defaultFields
+		}
+		body := map[string]any{"query": dict.expandQueryString(req.Keyword)}
+		if len(fields) > 0 {
+			body["fields"] = fields
+		}
+		must = append(must, map[string]any{"query_string": body})
+	}
+
+	if req.QueryString != nil {
+		body := map[string]any{"query": req.QueryString.Query}
+		if len(req.QueryString.Fields) > 0 {
+			body["fields"] = req.QueryString.Fields
+		}
+		should = append(should, map[string]any{"query_string": withBoost(body, req.QueryString.Boost)})
+	}
+
+	for f, vs := range req.MustTerms {
+		if len(vs) == 1 {
+			filter = append(filter, map[string]any{"term": map[string]any{f: vs[0]}})
+		} else if len(vs) > 1 {
+			filter = append(filter, map[string]any{"terms": map[string]any{f: vs}})
+		}
+	}
+	for f, vs := range req.MustNotTerms {
+		for _, v := range vs {
+			mustNot = append(mustNot, map[string]any{"term": map[string]any{f: v}})
+		}
+	}
+	for f, vs := range req.ShouldTerms {
+		for _, v := range vs {
+			should = append(should, map[string]any{"term": map[string]any{f: v}})
+		}
+	}
+
+	// phrase 在原词与同义词中任一命中即可，多词同义词需按短语匹配
+	phrase := func(field, text string) map[string]any {
+		if field == "" {
+			body := map[string]any{"query": text, "type": "phrase"}
+			if len(defaultFields) > 0 {
+				body["fields"] = defaultFields
+			}
+			return map[string]any{"multi_match": body}
+		}
+		return map[string]any{"match_phrase": map[string]any{field: map[string]any{"query": text}}}
+	}
+	for _, m := range req.Matches {
+		operator := "or"
+		if strings.ToLower(m.Operator) == "and" {
+			operator = "and"
+		}
+		if groups := dict.expandTerms(m.Query); groups != nil {
+			clauses := make([]any, 0, len(groups))
+			for _, g := range groups {
+				alts := make([]any, 0, len(g))
+				for _, term := range g {
+					alts = append(alts, phrase(m.Field, term))
+				}
+				clauses = append(clauses, map[string]any{"bool": map[string]any{"should": alts, "minimum_should_match": 1}})
+			}
+			body := map[string]any{"should": clauses, "minimum_should_match": 1}
+			if operator == "and" {
+				body = map[string]any{"must": clauses}
+			}
+			should = append(should, map[string]any{"bool": withBoost(body, m.Boost)})
+			continue
+		}
+		if m.Field == "" {
+			body := map[string]any{"query": m.Query, "operator": operator}
+			if len(defaultFields) > 0 {
+				body["fields"] = defaultFields
+			}
+			should = append(should, map[string]any{"multi_match": withBoost(body, m.Boost)})
+			continue
+		}
+		should = append(should, map[string]any{"match": map[string]any{
+			m.Field: withBoost(map[string]any{"query": m.Query, "operator": operator}, m.Boost),
+		}})
+	}
+	for _, p := range req.Phrases {
+		if p.Field == "" {
+			body := map[string]any{"query": p.Phrase, "type": "phrase", "slop": p.Slop}
+			if len(defaultFields) > 0 {
+				body["fields"] = defaultFields
+			}
+			should = append(should, map[string]any{"multi_match": withBoost(body, p.Boost)})
+			continue
+		}
+		should = append(should, map[string]any{"match_phrase": map[string]any{
+			p.Field: withBoost(map[string]any{"query": p.Phrase, "slop": p.Slop}, p.Boost),
+		}})
+	}
+	for _, pr := range req.Prefixes {
+		if pr.Field == "" {
+			should = append(should, anyField(escapeQueryString(pr.Prefix)+"*", pr.Boost))
+			continue
+		}
+		should = append(should, map[string]any{"prefix": map[string]any{
+			pr.Field: withBoost(map[string]any{"value": pr.Prefix}, pr.Boost),
+		}})
+	}
+	for _, w := range req.Wildcards {
+		if w.Field == "" {
+			should = append(should, anyField(w.Pattern, w.Boost))
+			continue
+		}
+		should = append(should, map[string]any{"wildcard": map[string]any{
+			w.Field: withBoost(map[string]any{"value": w.Pattern}, w.Boost),
+		}})
+	}
+	for _, r := range req.Regexps {
+		if r.Field == "" {
+			should = append(should, anyField("/"+r.Pattern+"/", r.Boost))
+			continue
+		}
+		should = append(should, map[string]any{"regexp": map[string]any{
+			r.Field: withBoost(map[string]any{"value": r.Pattern}, r.Boost),
+		}})
+	}
+	for _, fz := range req.Fuzzies {
+		fuzziness := "AUTO"
+		if fz.Fuzziness > 0 {
+			fuzziness = strconv.Itoa(fz.Fuzziness)
+		}
+		if fz.Field == "" {
+			should = append(should, anyField(escapeQueryString(fz.Term)+"~"+strings.TrimPrefix(fuzziness, "AUTO"), fz.Boost))
+			continue
+		}
+		body := map[string]any{"value": fz.Term, "fuzziness": fuzziness}
+		if fz.Prefix > 0 {
+			body["prefix_length"] = fz.Prefix
+		}
+		should = append(should, map[string]any{"fuzzy": map[string]any{fz.Field: withBoost(body, fz.Boost)}})
+	}
+
+	for _, r := range req.NumericRanges {
+		rng := map[string]any{}
+		if r.GT != nil {
+			rng["gt"] = *r.GT
+		} else if r.GTE != nil {
+			rng["gte"] = *r.GTE
+		}
+		if r.LT != nil {
+			rng["lt"] = *r.LT
+		} else if r.LTE != nil {
+			rng["lte"] = *r.LTE
+		}
+		filter = append(filter, map[string]any{"range": map[string]any{r.Field: rng}})
+	}
+	for _, r := range req.TimeRanges {
+		rng := map[string]any{}
+		if r.From != nil {
+			key := "gt"
+			if r.IncFrom {
+				key = "gte"
+			}
+			rng[key] = r.From.Format(time.RFC3339Nano)
+		}
+		if r.To != nil {
+			key := "lt"
+			if r.IncTo {
+				key = "lte"
+			}
+			rng[key] = r.To.Format(time.RFC3339Nano)
+		}
+		filter = append(filter, map[string]any{"range": map[string]any{r.Field: rng}})
+	}
+
+	for _, g := range req.GeoDistances {
+		filter = append(filter, map[string]any{"geo_distance": map[string]any{
+			"distance": g.Distance,
+			g.Field:    map[string]any{"lat": g.Lat, "lon": g.Lon},
+		}})
+	}
+	for _, g := range req.GeoBoundingBoxes {
+		filter = append(filter, map[string]any{"geo_bounding_box": map[string]any{g.Field: map[string]any{
+			"top_left":     map[string]any{"lat": g.TopLeft.Lat, "lon": g.TopLeft.Lon},
+			"bottom_right": map[string]any{"lat": g.BottomRight.Lat, "lon": g.BottomRight.Lon},
+		}}})
+	}
+
+	if len(must) == 0 && len(should) == 0 && len(mustNot) == 0 && len(filter) == 0 {
+		return withESAccess(map[string]any{"match_all": map[string]any{}}, req.Access)
+	}
+	boolQ := map[string]any{}
+	if len(must) > 0 {
+		boolQ["must"] = must
+	}
+	if len(filter) > 0 {
+		boolQ["filter"] = filter
+	}
+	if len(mustNot) > 0 {
+		boolQ["must_not"] = mustNot
+	}
+	if len(should) > 0 {
+		boolQ["should"] = should
+		if req.MinShould > 0 {
+			boolQ["minimum_should_match"] = req.MinShould
+		}
+	}
+	return withESAccess(map[string]any{"bool": boolQ}, req.Access)
+}
+
+// escapeQueryString 转义 query_string 语法中的保留字符
+func escapeQueryString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`+-=&|><!(){}[]^"~*?:\/ `, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// searchIDs 执行查询并返回命中的文档ID，用于补全与建议
+func (e *elasticEngine) searchIDs(ctx context.Context, query map[string]any, size int) ([]string, error) {
+	if err := e.guard(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := e.withTimeout(ctx)
+	defer cancel()
+	var res esSearchResponse
+	body := map[string]any{"query": query, "size": size, "_source": false}
+	if err := e.doJSON(ctx, http.MethodPost, "/"+url.PathEscape(e.es.Index)+"/_search", body, &res); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, h := range res.Hits.Hits {
+		ids = append(ids, h.ID)
+	}
+	return ids, nil
+}
+
+func (e *elasticEngine) GetAutoCompleteSuggestions(ctx context.Context, keyword string) ([]string, error) {
+	body := map[string]any{"query": keyword, "type": "phrase_prefix"}
+	if len(e.defaultFields) > 0 {
+		body["fields"] = e.defaultFields
+	}
+	return e.searchIDs(ctx, map[string]any{"multi_match": body}, 5)
+}
+
+func (e *elasticEngine) GetSearchSuggestions(ctx context.Context, keyword string) ([]string, error) {
+	body := map[string]any{"query": keyword}
+	if len(e.defaultFields) > 0 {
+		body["fields"] = e.defaultFields
+	}
+	return e.searchIDs(ctx, map[string]any{"multi_match": body}, 5)
+}
+
+// storeSize 索引主分片占用的磁盘大小
+func (e *elasticEngine) storeSize(ctx context.Context) (int64, error) {
+	var res struct {
+		All struct {
+			Primaries struct {
+				Store struct {
+					SizeInBytes int64 `json:"size_in_bytes"`
+				} `json:"store"`
+			} `json:"primaries"`
+		} `json:"_all"`
+	}
+	if err := e.doJSON(ctx, http.MethodGet, "/"+url.PathEscape(e.es.Index)+"/_stats/store", nil, &res); err != nil {
+		return 0, err
+	}
+	return res.All.Primaries.Store.SizeInBytes, nil
+}
+
+// Compact 调用 _forcemerge 合并段并清除已删除文档
+func (e *elasticEngine) Compact(ctx context.Context) (CompactResult, error) {
+	if err := e.guard(); err != nil {
+		return CompactResult{}, err
+	}
+	start := time.Now()
+	before, _ := e.storeSize(ctx)
+	if err := e.doJSON(ctx, http.MethodPost, "/"+url.PathEscape(e.es.Index)+"/_forcemerge?max_num_segments=1", nil, nil); err != nil {
+		return CompactResult{}, err
+	}
+	after, _ := e.storeSize(ctx)
+	return CompactResult{
+		SizeBefore: before,
+		SizeAfter:  after,
+		Reclaimed:  before - after,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// Snapshots 返回远端索引的大小与创建时间
+func (e *elasticEngine) Snapshots() ([]Snapshot, error) {
+	if err := e.guard(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := e.withTimeout(context.Background())
+	defer cancel()
+	size, err := e.storeSize(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snap := Snapshot{
+		Name: e.es.Index,
+		Kind: SnapshotKindIndex,
+		Path: e.es.Addresses[0] + "/" + e.es.Index,
+		Size: size,
+	}
+	var settings map[string]struct {
+		Settings struct {
+			Index struct {
+				CreationDate string `json:"creation_date"`
+			} `json:"index"`
+		} `json:"settings"`
+	}
+	if err := e.doJSON(ctx, http.MethodGet, "/"+url.PathEscape(e.es.Index)+"/_settings", nil, &settings); err == nil {
+		for _, s := range settings {
+			if ms, err := strconv.ParseInt(s.Settings.Index.CreationDate, 10, 64); err == nil {
+				snap.ModTime = time.UnixMilli(ms)
+				snap.AgeSeconds = int64(time.Since(snap.ModTime).Seconds())
+			}
+		}
+	}
+	return []Snapshot{snap}, nil
+}
+
+// IndexStats 返回远端索引的文档数与主分片大小
+func (e *elasticEngine) IndexStats(ctx context.Context) (IndexStats, error) {
+	if err := e.guard(); err != nil {
+		return IndexStats{}, err
+	}
+	var res struct {
+		Count uint64 `json:"count"`
+	}
+	if err := e.doJSON(ctx, http.MethodGet, "/"+url.PathEscape(e.es.Index)+"/_count", nil, &res); err != nil {
+		return IndexStats{}, err
+	}
+	size, err := e.storeSize(ctx)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	return IndexStats{Name: e.es.Index, Driver: DriverElasticsearch, DocCount: res.Count, DiskSize: size}, nil
+}
+
+// Recreate 删除远端索引并以 body 为创建请求体（settings 与 mappings）重建，mapping 为空时沿用 IndexBody
+func (e *elasticEngine) Recreate(ctx context.Context, body []byte) error {
+	if err := e.guard(); err != nil {
+		return err
+	}
+	if len(body) > 0 && !json.Valid(body) {
+		return errors.New("invalid index mapping: malformed JSON")
+	}
+	status, data, err := e.do(ctx, http.MethodDelete, "/"+url.PathEscape(e.es.Index), nil, "")
+	if err != nil {
+		return err
+	}
+	if status >= 300 && status != http.StatusNotFound {
+		return esError(status, data)
+	}
+	if len(body) > 0 {
+		e.es.IndexBody = body
+	}
+	return e.ensureIndex(ctx)
+}
+
+// DropIndex 删除远端索引并关闭引擎
+func (e *elasticEngine) DropIndex(ctx context.Context) error {
+	if err := e.guard(); err != nil {
+		return err
+	}
+	status, data, err := e.do(ctx, http.MethodDelete, "/"+url.PathEscape(e.es.Index), nil, "")
+	if err != nil {
+		return err
+	}
+	if status >= 300 && status != http.StatusNotFound {
+		return esError(status, data)
+	}
+	return e.Close()
+}
+
+// SampleDocs 按随机评分读取 n 个文档
+func (e *elasticEngine) SampleDocs(ctx context.Context, n int) ([]Hit, error) {
+	if err := e.guard(); err != nil {
+		return nil, err
+	}
+	body := map[string]any{
+		"query": map[string]any{"function_score": map[string]any{
+			"query":        map[string]any{"match_all": map[string]any{}},
+			"random_score": map[string]any{},
+		}},
+		"size": n,
+	}
+	res, err := e.search(ctx, "/"+url.PathEscape(e.es.Index)+"/_search", body)
+	if err != nil {
+		return nil, err
+	}
+	return res.toResult().Hits, nil
+}
+
+// GetDocs 按ID读取文档
+func (e *elasticEngine) GetDocs(ctx context.Context, ids []string) (map[string]map[string]any, error) {
+	if err := e.guard(); err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]any, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	body := map[string]any{"query": map[string]any{"ids": map[string]any{"values": ids}}, "size": len(ids)}
+	res, err := e.search(ctx, "/"+url.PathEscape(e.es.Index)+"/_search", body)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range res.toResult().Hits {
+		out[h.ID] = h.Fields
+	}
+	return out, nil
+}
+
+func (e *elasticEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	e.client.CloseIdleConnections()
+	return nil
+}
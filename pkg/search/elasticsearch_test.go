@@ -0,0 +1,365 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeES 模拟 Elasticsearch 的部分 REST 接口
+type fakeES struct {
+	mu         sync.Mutex
+	indexed    bool
+	docs       map[string]map[string]any
+	lastSearch map[string]any
+	pitClosed  string
+	indexBody  []byte
+}
+
+func newFakeES(t *testing.T) (*fakeES, *httptest.Server) {
+	f := &fakeES{docs: map[string]map[string]any{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		assert.Equal(t, "ApiKey secret", r.Header.Get("Authorization"))
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/docs":
+			if !f.indexed {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPut && r.URL.Path == "/docs":
+			f.indexed = true
+			f.indexBody, _ = io.ReadAll(r.Body)
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/docs/_doc/"):
+			var src map[string]any
+			json.NewDecoder(r.Body).Decode(&src)
+			f.docs[strings.TrimPrefix(r.URL.Path, "/docs/_doc/")] = src
+			w.Write([]byte(`{"result":"created"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/docs":
+			f.indexed = false
+			f.docs = map[string]map[string]any{}
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.URL.Path == "/docs/_count":
+			w.Write([]byte(`{"count":` + strconv.Itoa(len(f.docs)) + `}`))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/docs/_doc/"):
+			id := strings.TrimPrefix(r.URL.Path, "/docs/_doc/")
+			if _, ok := f.docs[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(f.docs, id)
+		case r.URL.Path == "/_bulk":
+			assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			sc := bufio.NewScanner(r.Body)
+			var items []string
+			for sc.Scan() {
+				var action map[string]map[string]string
+				json.Unmarshal(sc.Bytes(), &action)
+				sc.Scan()
+				var src map[string]any
+				json.Unmarshal(sc.Bytes(), &src)
+				id := action["index"]["_id"]
+				if id == "bad" {
+					items = append(items, `{"index":{"_id":"bad","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed"}}}`)
+					continue
+				}
+				f.docs[id] = src
+				items = append(items, `{"index":{"_id":"`+id+`","status":201}}`)
+			}
+			errs := strings.Contains(strings.Join(items, ""), `"status":400`)
+			w.Write([]byte(`{"errors":` + map[bool]string{true: "true", false: "false"}[errs] + `,"items":[` + strings.Join(items, ",") + `]}`))
+		case r.URL.Path == "/docs/_search":
+			body, _ := io.ReadAll(r.Body)
+			f.lastSearch = map[string]any{}
+			json.Unmarshal(body, &f.lastSearch)
+			w.Write([]byte(`{"took":3,"hits":{"total":{"value":1},"hits":[
+				{"_id":"1","_score":1.5,"_source":{"title":"hello world"},"highlight":{"title":["<mark>hello</mark> world"]},
+				 "_explanation":{"value":1.5,"description":"weight(title:hello)","details":[{"value":1,"description":"tf"}]}}]},
+				"aggregations":{"types":{"sum_other_doc_count":2,"buckets":[{"key":"article","doc_count":3}]}}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/docs/_pit":
+			assert.Equal(t, "2m", r.URL.Query().Get("keep_alive"))
+			w.Write([]byte(`{"id":"pit-1"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/_pit":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			f.pitClosed = body["id"]
+			w.Write([]byte(`{"succeeded":true}`))
+		case r.URL.Path == "/_search":
+			body, _ := io.ReadAll(r.Body)
+			f.lastSearch = map[string]any{}
+			json.Unmarshal(body, &f.lastSearch)
+			if _, ok := f.lastSearch["search_after"]; ok {
+				w.Write([]byte(`{"took":1,"pit_id":"pit-2","hits":{"total":{"value":3},"hits":[
+					{"_id":"3","_score":1.0,"_source":{"title":"c"},"sort":[1.0,9007199254740993]}]}}`))
+				return
+			}
+			w.Write([]byte(`{"took":1,"pit_id":"pit-1","hits":{"total":{"value":3},"hits":[
+				{"_id":"1","_score":2.0,"_source":{"title":"a"},"sort":[2.0,1]},
+				{"_id":"2","_score":1.5,"_source":{"title":"b"},"sort":[1.5,9007199254740993]}]}}`))
+		case r.URL.Path == "/docs/_stats/store":
+			w.Write([]byte(`{"_all":{"primaries":{"store":{"size_in_bytes":2048}}}}`))
+		case r.URL.Path == "/docs/_forcemerge":
+			assert.Equal(t, "1", r.URL.Query().Get("max_num_segments"))
+			w.Write([]byte(`{"_shards":{"total":1}}`))
+		case r.URL.Path == "/docs/_settings":
+			w.Write([]byte(`{"docs":{"settings":{"index":{"creation_date":"1700000000000"}}}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"type":"illegal_argument_exception","reason":"unsupported ` + r.URL.Path + `"}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func newTestElasticEngine(t *testing.T, srv *httptest.Server) Engine {
+	t.Helper()
+	e, err := New(Config{
+		Driver:    DriverElasticsearch,
+		BatchSize: 2,
+		Elasticsearch: ElasticsearchConfig{
+			Addresses: []string{srv.URL + "/"},
+			Index:     "docs",
+			APIKey:    "secret",
+		},
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+func TestElasticEngineIndexAndDelete(t *testing.T) {
+	f, srv := newFakeES(t)
+	e := newTestElasticEngine(t, srv)
+	assert.True(t, f.indexed)
+	ctx := context.Background()
+
+	require.NoError(t, e.Index(ctx, Doc{ID: "1", Type: "article", Fields: map[string]any{"title": "hello"}}))
+	assert.Equal(t, "article", f.docs["1"]["type"])
+
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		{ID: "2", Fields: map[string]any{"title": "a"}},
+		{ID: "3", Fields: map[string]any{"title": "b"}},
+		{ID: "4", Fields: map[string]any{"title": "c"}},
+	}))
+	assert.Len(t, f.docs, 4)
+
+	err := e.IndexBatch(ctx, []Doc{{ID: "bad"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mapper_parsing_exception")
+
+	require.NoError(t, e.Delete(ctx, "1"))
+	require.NoError(t, e.Delete(ctx, "missing"))
+	assert.Len(t, f.docs, 3)
+
+	require.NoError(t, e.Close())
+	assert.ErrorIs(t, e.Index(ctx, Doc{ID: "5"}), ErrClosed)
+}
+
+func TestElasticEngineSearch(t *testing.T) {
+	f, srv := newFakeES(t)
+	e := newTestElasticEngine(t, srv)
+
+	res, err := e.Search(context.Background(), SearchRequest{
+		Keyword:      "hello",
+		SearchFields: []string{"title"},
+		MustTerms:    map[string][]string{"type": {"article"}},
+		Facets:       []FacetRequest{{Name: "types", Field: "type"}},
+		SortBy:       []string{"-created_at", "_score"},
+		Highlight:    true,
+		Explain:      true,
+		Size:         5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), res.Total)
+	assert.Equal(t, 3*time.Millisecond, res.Took)
+	require.Len(t, res.Hits, 1)
+	assert.Equal(t, 1.5, res.Hits[0].Score)
+	assert.Equal(t, "hello world", res.Hits[0].Fields["title"])
+	assert.Equal(t, []string{"<mark>hello</mark> world"}, res.Hits[0].Fragments["title"])
+	require.NotNil(t, res.Hits[0].Explanation)
+	assert.Equal(t, "weight(title:hello)", res.Hits[0].Explanation.Message)
+	assert.Len(t, res.Hits[0].Explanation.Children, 1)
+	assert.Equal(t, 5, res.Facets["types"].Total)
+	assert.Equal(t, []FacetTerm{{Term: "article", Count: 3}}, res.Facets["types"].Terms)
+	assert.NotEmpty(t, res.Query)
+
+	assert.Equal(t, float64(5), f.lastSearch["size"])
+	assert.Equal(t, true, f.lastSearch["explain"])
+	assert.Equal(t, []any{
+		map[string]any{"created_at": map[string]any{"order": "desc", "missing": "_last"}},
+		map[string]any{"_score": map[string]any{"order": "asc"}},
+	}, f.lastSearch["sort"])
+	assert.Contains(t, f.lastSearch, "aggs")
+	assert.Equal(t, map[string]any{
+		"fields":              map[string]any{"*": map[string]any{}},
+		"pre_tags":            []any{"<mark>"},
+		"post_tags":           []any{"</mark>"},
+		"fragment_size":       float64(200),
+		"number_of_fragments": float64(1),
+		"encoder":             "html",
+	}, f.lastSearch["highlight"])
+
+	_, err = e.Search(context.Background(), SearchRequest{
+		Keyword:         "hello",
+		Highlight:       true,
+		HighlightFields: []string{"title"},
+		HighlightStyle:  HighlightStylePlain,
+		PreTag:          "[",
+		PostTag:         "]",
+		FragmentSize:    5000,
+		MaxFragments:    3,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"fields":              map[string]any{"title": map[string]any{}},
+		"pre_tags":            []any{"["},
+		"post_tags":           []any{"]"},
+		"fragment_size":       float64(maxFragmentSize),
+		"number_of_fragments": float64(3),
+	}, f.lastSearch["highlight"])
+
+	_, err = e.Search(context.Background(), SearchRequest{Keyword: "hello", Highlight: true, HighlightStyle: "ansi"})
+	assert.Error(t, err)
+}
+
+func TestElasticEngineScroll(t *testing.T) {
+	f, srv := newFakeES(t)
+	e := newTestElasticEngine(t, srv)
+	ctx := context.Background()
+	req := SearchRequest{Keyword: "hello", SearchFields: []string{"title"}, Size: 2}
+
+	res, err := e.Scroll(ctx, req, "")
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 2)
+	require.NotEmpty(t, res.Cursor)
+	assert.Equal(t, []string{"1.5", "9007199254740993"}, res.Hits[1].Sort)
+	assert.Equal(t, map[string]any{"id": "pit-1", "keep_alive": "2m"}, f.lastSearch["pit"])
+	assert.Equal(t, []any{map[string]any{"_score": map[string]any{"order": "desc"}}}, f.lastSearch["sort"])
+
+	res, err = e.Scroll(ctx, req, res.Cursor)
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 1)
+	assert.Empty(t, res.Cursor)
+	assert.Equal(t, map[string]any{"id": "pit-1", "keep_alive": "2m"}, f.lastSearch["pit"])
+	assert.Equal(t, []any{1.5, 9007199254740993.0}, f.lastSearch["search_after"])
+	assert.NotContains(t, f.lastSearch, "from")
+	assert.Equal(t, "pit-2", f.pitClosed)
+}
+
+func TestDecodeSearchAfter(t *testing.T) {
+	values := decodeSearchAfter([]string{"1.5", "9007199254740993", `"abc"`, "not json"})
+	data, err := json.Marshal(values)
+	require.NoError(t, err)
+	assert.Equal(t, `[1.5,9007199254740993,"abc","not json"]`, string(data))
+}
+
+func TestElasticEngineMaintenance(t *testing.T) {
+	_, srv := newFakeES(t)
+	e := newTestElasticEngine(t, srv)
+
+	res, err := e.Compact(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2048), res.SizeBefore)
+
+	snaps, err := e.Snapshots()
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	assert.Equal(t, "docs", snaps[0].Name)
+	assert.Equal(t, int64(2048), snaps[0].Size)
+	assert.Equal(t, int64(1700000000), snaps[0].ModTime.Unix())
+}
+
+func TestElasticEngineIndexStatsAndRecreate(t *testing.T) {
+	f, srv := newFakeES(t)
+	e := newTestElasticEngine(t, srv)
+	ctx := context.Background()
+	require.NoError(t, e.Index(ctx, Doc{ID: "1", Fields: map[string]any{"title": "a"}}))
+
+	stats, err := e.IndexStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, IndexStats{Name: "docs", Driver: DriverElasticsearch, DocCount: 1, DiskSize: 2048}, stats)
+
+	require.Error(t, e.Recreate(ctx, []byte(`{"mappings":`)))
+	assert.Len(t, f.docs, 1)
+
+	body := `{"mappings":{"properties":{"title":{"type":"keyword"}}}}`
+	require.NoError(t, e.Recreate(ctx, []byte(body)))
+	assert.True(t, f.indexed)
+	assert.Empty(t, f.docs)
+	assert.JSONEq(t, body, string(f.indexBody))
+}
+
+func TestBuildESQuery(t *testing.T) {
+	assert.Equal(t, map[string]any{"match_all": map[string]any{}}, buildESQuery(SearchRequest{}, nil, nil))
+
+	gte := 10.0
+	boost := 2.0
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := buildESQuery(SearchRequest{
+		MustNotTerms:  map[string][]string{"status": {"deleted"}},
+		ShouldTerms:   map[string][]string{"tag": {"go"}},
+		NumericRanges: []NumericRangeFilter{{Field: "views", GTE: &gte}},
+		TimeRanges:    []TimeRangeFilter{{Field: "created_at", From: &from, IncFrom: true}},
+		Matches:       []ClauseMatch{{Field: "title", Query: "hello", Operator: "AND", Boost: &boost}},
+		Prefixes:      []ClausePrefix{{Prefix: "hel lo"}},
+		Fuzzies:       []ClauseFuzzy{{Field: "title", Term: "helo", Fuzziness: 1, Prefix: 2}},
+		MinShould:     2,
+	}, []string{"title", "body"}, nil)
+
+	data, err := json.Marshal(q)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bool":{
+		"filter":[
+			{"range":{"views":{"gte":10}}},
+			{"range":{"created_at":{"gte":"2024-01-01T00:00:00Z"}}}
+		],
+		"must_not":[{"term":{"status":"deleted"}}],
+		"should":[
+			{"term":{"tag":"go"}},
+			{"match":{"title":{"query":"hello","operator":"and","boost":2}}},
+			{"query_string":{"query":"hel\\ lo*","fields":["title","body"]}},
+			{"fuzzy":{"title":{"value":"helo","fuzziness":"1","prefix_length":2}}}
+		],
+		"minimum_should_match":2
+	}}`, string(data))
+
+	q = buildESQuery(SearchRequest{
+		GeoDistances: []GeoDistanceFilter{{Field: "location", Lat: 39.9, Lon: 116.4, Distance: "5km"}},
+		GeoBoundingBoxes: []GeoBoundingBoxFilter{{
+			Field:       "location",
+			TopLeft:     GeoPoint{Lat: 40, Lon: 116},
+			BottomRight: GeoPoint{Lat: 39, Lon: 117},
+		}},
+	}, nil, nil)
+	data, err = json.Marshal(q)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"bool":{"filter":[
+		{"geo_distance":{"distance":"5km","location":{"lat":39.9,"lon":116.4}}},
+		{"geo_bounding_box":{"location":{"top_left":{"lat":40,"lon":116},"bottom_right":{"lat":39,"lon":117}}}}
+	]}}`, string(data))
+
+	sorts, err := resolveSort(nil, []SortField{{Field: "location", Origin: &GeoPoint{Lat: 39.9, Lon: 116.4}, Unit: "km"}})
+	require.NoError(t, err)
+	data, err = json.Marshal(buildESSort(sorts))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"_geo_distance":{"location":{"lat":39.9,"lon":116.4},"order":"asc","unit":"km"}}]`, string(data))
+}
+
+func TestUnknownDriver(t *testing.T) {
+	_, err := New(Config{Driver: "solr"}, nil)
+	assert.Error(t, err)
+	assert.Contains(t, Drivers(), DriverOpenSearch)
+}
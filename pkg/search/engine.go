@@ -2,6 +2,7 @@ package search
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"os"
 	"sync"
@@ -19,8 +20,16 @@ type Engine interface {
 	IndexBatch(ctx context.Context, docs []Doc) error
 	Delete(ctx context.Context, id string) error
 	Search(ctx context.Context, req SearchRequest) (SearchResult, error)
+	// Scroll 游标分页，cursor 为空时从第一页开始，返回的 Cursor 用于获取下一页
+	Scroll(ctx context.Context, req SearchRequest, cursor string) (ScrollResult, error)
 	GetAutoCompleteSuggestions(ctx context.Context, keyword string) ([]string, error)
 	GetSearchSuggestions(ctx context.Context, keyword string) ([]string, error)
+	Compact(ctx context.Context) (CompactResult, error)
+	Snapshots() ([]Snapshot, error)
+	// IndexStats 返回索引文档数与磁盘占用
+	IndexStats(ctx context.Context) (IndexStats, error)
+	// Recreate 删除索引并按 mapping 重新创建，mapping 为空时沿用当前映射
+	Recreate(ctx context.Context, mapping []byte) error
 	Close() error
 }
 
@@ -32,7 +41,11 @@ type bleveEngine struct {
 	closed        bool
 }
 
-func New(cfg Config, m mapping.IndexMapping) (Engine, error) { // mapping 引自 bleve
+// newBleveEngine 创建本地 bleve 引擎，索引目录不存在时按 mapping 新建；设置 IndexDir 时返回 IndexManager
+func newBleveEngine(cfg Config, m mapping.IndexMapping) (Engine, error) { // mapping 引自 bleve
+	if cfg.IndexDir != "" {
+		return NewIndexManager(cfg, m)
+	}
 	be := &bleveEngine{cfg: cfg, defaultFields: cfg.DefaultSearchFields}
 
 	var idx bleve.Index
@@ -145,7 +158,10 @@ func (e *bleveEngine) Search(ctx context.Context, req SearchRequest) (SearchResu
 		return SearchResult{}, err
 	}
 
-	q := buildQuery(req, e.defaultFields)
+	if err := validateGeoFilters(req); err != nil {
+		return SearchResult{}, err
+	}
+	q := buildQuery(req, e.defaultFields, e.cfg.Dictionaries.Current())
 	sr := bleve.NewSearchRequest(q)
 
 	// 分页
@@ -157,10 +173,22 @@ func (e *bleveEngine) Search(ctx context.Context, req SearchRequest) (SearchResu
 	}
 	sr.Size = req.Size
 	sr.From = req.From
+	if len(req.SearchAfter) > 0 {
+		sr.From = 0
+		sr.SearchAfter = req.SearchAfter
+	}
 
-	// 排序
-	if len(req.SortBy) > 0 {
-		sr.SortBy(req.SortBy)
+	// 排序，字段需在索引映射中且类型与排序方式相符
+	if fields := sortFields(req); len(fields) > 0 {
+		sorts, err := resolveSort(e.index.Mapping(), fields)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		order, err := toBleveSort(sorts)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		sr.SortByCustom(order)
 	}
 
 	// 字段
@@ -170,21 +198,26 @@ func (e *bleveEngine) Search(ctx context.Context, req SearchRequest) (SearchResu
 		sr.Fields = req.IncludeFields
 	}
 
-	// 高亮
+	// 高亮，片段按请求的样式、标签、长度与数量生成
+	var hl highlightOptions
 	if req.Highlight {
-		hl := bleve.NewHighlightWithStyle("html")
-		// 如果你想限定高亮字段（可选）
-		// 注意：v2 没有 SetFragmentSize/SetMaxFragments
-		// 有些版本没有 AddField 方法；若没有，就直接用默认（所有可高亮字段）
+		var err error
+		if hl, err = newHighlightOptions(req); err != nil {
+			return SearchResult{}, err
+		}
+		style, err := hl.bleveHighlighter()
+		if err != nil {
+			return SearchResult{}, err
+		}
+		sr.Highlight = bleve.NewHighlightWithStyle(style)
 		for _, f := range req.HighlightFields {
-			// 如果你的 bleve 版本有 AddField:
-			// hl.AddField(f)
-			// 否则可以忽略字段选择，使用默认行为
-			_ = f
+			sr.Highlight.AddField(f)
 		}
-		sr.Highlight = hl
 	}
 
+	// 评分解释
+	sr.Explain = req.Explain
+
 	// Facets
 	if len(req.Facets) > 0 {
 		sr.Facets = make(map[string]*bleve.FacetRequest, len(req.Facets))
@@ -218,12 +251,19 @@ func (e *bleveEngine) Search(ctx context.Context, req SearchRequest) (SearchResu
 	}
 	for _, h := range res.Hits {
 		out.Hits = append(out.Hits, Hit{
-			ID:        h.ID,
-			Score:     h.Score,
-			Fields:    h.Fields,
-			Fragments: h.Fragments,
+			ID:          h.ID,
+			Score:       h.Score,
+			Fields:      h.Fields,
+			Fragments:   hl.format(h.Fragments),
+			Explanation: h.Expl,
+			Sort:        h.Sort,
 		})
 	}
+	if req.Explain {
+		if data, err := json.Marshal(q); err == nil {
+			out.Query = data
+		}
+	}
 	// Facets
 	if res.Facets != nil {
 		for name, fr := range res.Facets {
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEngine(t *testing.T) Engine {
+	t.Helper()
+	e, err := New(Config{IndexPath: filepath.Join(t.TempDir(), "test.bleve")}, BuildIndexMapping(""))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+func TestSearchExplain(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		{ID: "1", Type: "article", Fields: map[string]any{"title": "hello world", "body": "first"}},
+		{ID: "2", Type: "article", Fields: map[string]any{"title": "goodbye", "body": "hello again"}},
+	}))
+
+	res, err := e.Search(ctx, SearchRequest{Keyword: "hello", SearchFields: []string{"title", "body"}})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Hits)
+	assert.Nil(t, res.Hits[0].Explanation)
+	assert.Nil(t, res.Query)
+
+	res, err = e.Search(ctx, SearchRequest{Keyword: "hello", SearchFields: []string{"title", "body"}, Explain: true})
+	require.NoError(t, err)
+	require.NotEmpty(t, res.Hits)
+	assert.NotNil(t, res.Hits[0].Explanation)
+	assert.NotEmpty(t, res.Query)
+}
+
+func TestSearchHighlight(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	body := "hello there, this sentence is padding between the matches. " +
+		"it keeps going for a while so that the second <hello> lands in another fragment"
+	require.NoError(t, e.Index(ctx, Doc{ID: "1", Type: "article", Fields: map[string]any{
+		"type": "article", "title": "hello world", "body": body,
+	}}))
+	base := SearchRequest{Keyword: "hello", SearchFields: []string{"title", "body"}, Highlight: true}
+
+	res, err := e.Search(ctx, base)
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 1)
+	assert.Equal(t, []string{"<mark>hello</mark> world"}, res.Hits[0].Fragments["title"])
+	require.Len(t, res.Hits[0].Fragments["body"], 1)
+	assert.Contains(t, res.Hits[0].Fragments["body"][0], "&lt;<mark>hello</mark>&gt;")
+
+	req := base
+	req.HighlightFields = []string{"body"}
+	req.FragmentSize = 30
+	req.MaxFragments = 3
+	res, err = e.Search(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 1)
+	assert.NotContains(t, res.Hits[0].Fragments, "title")
+	frags := res.Hits[0].Fragments["body"]
+	require.Len(t, frags, 2)
+	for _, f := range frags {
+		assert.Contains(t, f, "<mark>hello</mark>")
+		assert.Less(t, len([]rune(f)), 60)
+	}
+
+	req.HighlightStyle = HighlightStylePlain
+	req.PreTag, req.PostTag = "[", "]"
+	res, err = e.Search(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 1)
+	joined := strings.Join(res.Hits[0].Fragments["body"], " ")
+	assert.Contains(t, joined, "[hello] there")
+	assert.Contains(t, joined, "<[hello]>")
+
+	req.HighlightStyle = "ansi"
+	_, err = e.Search(ctx, req)
+	assert.Error(t, err)
+}
@@ -0,0 +1,249 @@
+package search
+
+import (
+	"HibiscusIM/pkg/redact"
+	"context"
+	"fmt"
+	"html"
+	"maps"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 派生字段名
+const (
+	FieldLang        = "lang"
+	FieldWordCount   = "wordCount"
+	FieldReadingTime = "readingTime" // 阅读时长（分钟）
+)
+
+// 阅读速度：英文等按词、中日韩文字按字计
+const (
+	wordsPerMinute    = 200
+	cjkCharsPerMinute = 400
+)
+
+// Enricher 在文档写入索引前补充或改写字段，返回错误时该文档不写入
+type Enricher func(ctx context.Context, doc *Doc) error
+
+// EnrichPipeline 按文档类型注册的写入前处理链，使调用方提交原始内容即可得到一致的索引字段。
+// 先执行该类型注册的处理器，再执行 "*" 注册的通用处理器
+type EnrichPipeline struct {
+	mu     sync.RWMutex
+	chains map[string][]Enricher
+}
+
+// NewEnrichPipeline 创建空的处理链
+func NewEnrichPipeline() *EnrichPipeline {
+	return &EnrichPipeline{chains: make(map[string][]Enricher)}
+}
+
+// Register 为 docType 追加处理器，docType 为 "*" 时对全部类型生效
+func (p *EnrichPipeline) Register(docType string, enrichers ...Enricher) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chains[docType] = append(p.chains[docType], enrichers...)
+}
+
+// Enrich 依次执行文档类型对应的处理器，返回处理后的副本，不修改入参的 Fields
+func (p *EnrichPipeline) Enrich(ctx context.Context, doc Doc) (Doc, error) {
+	p.mu.RLock()
+	chain := make([]Enricher, 0, len(p.chains[doc.Type])+len(p.chains[allTypes]))
+	chain = append(chain, p.chains[doc.Type]...)
+	chain = append(chain, p.chains[allTypes]...)
+	p.mu.RUnlock()
+	if len(chain) == 0 {
+		return doc, nil
+	}
+	doc.Fields = maps.Clone(doc.Fields)
+	if doc.Fields == nil {
+		doc.Fields = make(map[string]interface{})
+	}
+	for _, e := range chain {
+		if err := e(ctx, &doc); err != nil {
+			return doc, fmt.Errorf("enrich %s %s: %w", doc.Type, doc.ID, err)
+		}
+	}
+	return doc, nil
+}
+
+// EnrichedEngine 写入前经过处理链的引擎包装，其他方法直接透传
+type EnrichedEngine struct {
+	Engine
+	pipeline *EnrichPipeline
+}
+
+// NewEnrichedEngine 用 p 处理写入 e 的文档
+func NewEnrichedEngine(e Engine, p *EnrichPipeline) *EnrichedEngine {
+	return &EnrichedEngine{Engine: e, pipeline: p}
+}
+
+// Index 处理后写入
+func (e *EnrichedEngine) Index(ctx context.Context, doc Doc) error {
+	enriched, err := e.pipeline.Enrich(ctx, doc)
+	if err != nil {
+		return err
+	}
+	return e.Engine.Index(ctx, enriched)
+}
+
+// IndexBatch 处理后写入，任一文档处理失败时整批不写入
+func (e *EnrichedEngine) IndexBatch(ctx context.Context, docs []Doc) error {
+	enriched := make([]Doc, len(docs))
+	for i, d := range docs {
+		var err error
+		if enriched[i], err = e.pipeline.Enrich(ctx, d); err != nil {
+			return err
+		}
+	}
+	return e.Engine.IndexBatch(ctx, enriched)
+}
+
+var (
+	// htmlBlocks 内容不可见的元素，连同内容一起去掉
+	htmlBlocks = regexp.MustCompile(`(?is)<(script|style|noscript)\b[^>]*>.*?</(script|style|noscript)\s*>|<!--.*?-->`)
+	htmlTags   = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// StripHTML 去掉字段中的 HTML 标签、脚本与样式，还原实体并合并空白
+func StripHTML(fields ...string) Enricher {
+	return func(_ context.Context, doc *Doc) error {
+		for _, f := range fields {
+			s, ok := doc.Fields[f].(string)
+			if !ok || !strings.ContainsAny(s, "<&") {
+				continue
+			}
+			s = htmlBlocks.ReplaceAllString(s, " ")
+			s = htmlTags.ReplaceAllString(s, " ")
+			doc.Fields[f] = strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+		}
+		return nil
+	}
+}
+
+// MaskPII 用 r 的值规则对字段脱敏，未指定字段时处理全部字符串字段；r 为 nil 时使用内置规则，不受全局脱敏开关影响
+func MaskPII(r *redact.Redactor, fields ...string) Enricher {
+	if r == nil {
+		r, _ = redact.New(redact.DefaultConfig())
+	}
+	return func(_ context.Context, doc *Doc) error {
+		if len(fields) == 0 {
+			for k, v := range doc.Fields {
+				if s, ok := v.(string); ok {
+					doc.Fields[k] = r.String(s)
+				}
+			}
+			return nil
+		}
+		for _, f := range fields {
+			if s, ok := doc.Fields[f].(string); ok {
+				doc.Fields[f] = r.String(s)
+			}
+		}
+		return nil
+	}
+}
+
+// DetectLanguage 按字段文本的文字分布识别语言写入 lang 字段，已有 lang 时不覆盖
+func DetectLanguage(fields ...string) Enricher {
+	return func(_ context.Context, doc *Doc) error {
+		if lang, _ := doc.Fields[FieldLang].(string); lang != "" {
+			return nil
+		}
+		if lang := detectLanguage(joinFields(doc, fields)); lang != "" {
+			doc.Fields[FieldLang] = lang
+		}
+		return nil
+	}
+}
+
+// DerivedFields 由字段文本计算字数与阅读时长，中日韩文字按字计数
+func DerivedFields(fields ...string) Enricher {
+	return func(_ context.Context, doc *Doc) error {
+		words, cjk := countWords(joinFields(doc, fields))
+		doc.Fields[FieldWordCount] = words + cjk
+		minutes := float64(words)/wordsPerMinute + float64(cjk)/cjkCharsPerMinute
+		doc.Fields[FieldReadingTime] = int(math.Ceil(minutes))
+		return nil
+	}
+}
+
+func joinFields(doc *Doc, fields []string) string {
+	var parts []string
+	for _, f := range fields {
+		if s, ok := doc.Fields[f].(string); ok && s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// detectLanguage 简单的文字分布判断：含假名为 ja、含谚文为 ko、汉字为主为 zh、拉丁字母为主为 en，
+// 其他文字按 Unicode 文字系统返回 ru、ar 等，无法判断时返回空。一个汉字的信息量约为三个字母，按 3 倍计数
+func detectLanguage(s string) string {
+	counts := make(map[string]int)
+	total := 0
+	for _, r := range s {
+		script, weight := "", 1
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			script = "ja"
+		case unicode.Is(unicode.Hangul, r):
+			script = "ko"
+		case unicode.Is(unicode.Han, r):
+			script, weight = "zh", 3
+		case unicode.Is(unicode.Cyrillic, r):
+			script = "ru"
+		case unicode.Is(unicode.Arabic, r):
+			script = "ar"
+		case unicode.Is(unicode.Latin, r):
+			script = "en"
+		default:
+			continue
+		}
+		counts[script] += weight
+		total++
+	}
+	if total == 0 {
+		return ""
+	}
+	// 日文与韩文常夹杂汉字，出现假名或谚文即可判断
+	if counts["ja"] > 0 {
+		return "ja"
+	}
+	if counts["ko"] > 0 && counts["ko"] >= counts["zh"] {
+		return "ko"
+	}
+	best, n := "", 0
+	for _, script := range []string{"zh", "en", "ru", "ar"} {
+		if counts[script] > n {
+			best, n = script, counts[script]
+		}
+	}
+	return best
+}
+
+// countWords 统计非中日韩文字的词数与中日韩文字的字数
+func countWords(s string) (words, cjk int) {
+	inWord := false
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if !inWord {
+				words++
+			}
+			inWord = true
+		case r == '\'' || r == '-':
+			// 词内的撇号与连字符不拆词
+		default:
+			inWord = false
+		}
+	}
+	return words, cjk
+}
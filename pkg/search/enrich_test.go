@@ -0,0 +1,84 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingEngine 记录写入的文档
+type recordingEngine struct {
+	Engine
+	docs map[string]Doc
+}
+
+func (e *recordingEngine) Index(_ context.Context, doc Doc) error {
+	e.docs[doc.ID] = doc
+	return nil
+}
+
+func (e *recordingEngine) IndexBatch(ctx context.Context, docs []Doc) error {
+	for _, d := range docs {
+		_ = e.Index(ctx, d)
+	}
+	return nil
+}
+
+func TestEnrichedEngine(t *testing.T) {
+	ctx := context.Background()
+	p := NewEnrichPipeline()
+	p.Register("article",
+		StripHTML("body"),
+		DetectLanguage("title", "body"),
+		DerivedFields("body"),
+	)
+	p.Register(allTypes, MaskPII(nil, "body"))
+	inner := &recordingEngine{docs: map[string]Doc{}}
+	e := NewEnrichedEngine(inner, p)
+
+	raw := map[string]interface{}{
+		"title": "Release notes",
+		"body":  `<p>Contact <b>alice@example.com</b> &amp; read on.</p><script>track()</script>`,
+	}
+	require.NoError(t, e.Index(ctx, Doc{ID: "a1", Type: "article", Fields: raw}))
+	got := inner.docs["a1"].Fields
+	assert.NotContains(t, got["body"], "<")
+	assert.NotContains(t, got["body"], "track")
+	assert.NotContains(t, got["body"], "alice@example.com")
+	assert.Contains(t, got["body"], "& read on.")
+	assert.Equal(t, "en", got[FieldLang])
+	assert.Equal(t, 1, got[FieldReadingTime])
+	// 不修改调用方的字段
+	assert.Contains(t, raw["body"], "<p>")
+
+	// 未注册的类型只执行通用处理器
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		{ID: "g1", Type: "group", Fields: map[string]interface{}{"name": "go"}},
+		{ID: "a2", Type: "article", Fields: map[string]interface{}{"body": "今天天气很好", FieldLang: "ja"}},
+	}))
+	assert.Equal(t, map[string]interface{}{"name": "go"}, inner.docs["g1"].Fields)
+	assert.Equal(t, "ja", inner.docs["a2"].Fields[FieldLang])
+	assert.Equal(t, 6, inner.docs["a2"].Fields[FieldWordCount])
+
+	p.Register("group", func(context.Context, *Doc) error { return errors.New("boom") })
+	assert.Error(t, e.IndexBatch(ctx, []Doc{{ID: "g2", Type: "group"}}))
+	assert.NotContains(t, inner.docs, "g2")
+}
+
+func TestDetectLanguage(t *testing.T) {
+	assert.Equal(t, "zh", detectLanguage("搜索引擎 search"))
+	assert.Equal(t, "ja", detectLanguage("東京のニュース"))
+	assert.Equal(t, "ko", detectLanguage("안녕하세요"))
+	assert.Equal(t, "ru", detectLanguage("Привет, мир"))
+	assert.Equal(t, "en", detectLanguage("Hello, world 2024"))
+	assert.Empty(t, detectLanguage("123 !!"))
+}
+
+func TestCountWords(t *testing.T) {
+	words, cjk := countWords("It's a well-known fact: 搜索很快")
+	assert.Equal(t, 4, words)
+	assert.Equal(t, 4, cjk)
+}
@@ -0,0 +1,257 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 点击反馈动作
+const (
+	FeedbackClick = "click"
+	// FeedbackAct 在结果上进一步操作，例如打开、回复、下载
+	FeedbackAct = "act"
+)
+
+const (
+	// 参与排名调整的点击统计窗口
+	feedbackWindow = 30 * 24 * time.Hour
+	// 每个查询参与排名调整的文档数上限
+	feedbackTopDocs = 100
+	// 查询点击数的缓存时长
+	feedbackCacheTTL = time.Minute
+	// 查询文本最大长度，超出截断
+	maxFeedbackQuery = 255
+	// 点击率报表统计的最大位置
+	maxCTRPositions = 50
+)
+
+var ErrInvalidFeedback = errors.New("invalid search feedback")
+
+// SearchImpression 一次搜索的展示记录，用于计算各位置点击率
+type SearchImpression struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	Query string `json:"query" gorm:"size:255;index"`
+	// 第一条命中的位置（从 0 开始，即请求的 From）
+	StartPos int `json:"startPos"`
+	// 返回的命中数
+	Shown     int       `json:"shown"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+}
+
+// SearchClick 用户对某条命中的点击或操作，Position 从 1 开始
+type SearchClick struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Query     string    `json:"query" gorm:"size:255;index"`
+	DocID     string    `json:"docId" gorm:"size:255;index"`
+	Position  int       `json:"position"`
+	Action    string    `json:"action" gorm:"size:16"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index"`
+}
+
+// PositionCTR 某个结果位置的点击率
+type PositionCTR struct {
+	Position    int     `json:"position"`
+	Impressions int64   `json:"impressions"`
+	Clicks      int64   `json:"clicks"`
+	CTR         float64 `json:"ctr"`
+}
+
+// DocClicks 查询下文档的点击数
+type DocClicks struct {
+	DocID  string `json:"docId"`
+	Clicks int64  `json:"clicks"`
+}
+
+type clickCacheEntry struct {
+	counts  map[string]int64
+	expires time.Time
+}
+
+// Feedback 收集搜索展示与点击反馈，统计各位置点击率，并可按历史点击调整相关度排序
+type Feedback struct {
+	db *gorm.DB
+	// boost 为 0 时不调整排序，否则命中得分乘以 1 + boost*ln(1+点击数)
+	boost float64
+
+	mu    sync.Mutex
+	cache map[string]clickCacheEntry
+}
+
+// NewFeedback 创建搜索反馈收集器，boost 为点击提升强度，0 表示只记录不调整排序
+func NewFeedback(db *gorm.DB, boost float64) *Feedback {
+	if boost < 0 {
+		boost = 0
+	}
+	return &Feedback{db: db, boost: boost, cache: make(map[string]clickCacheEntry)}
+}
+
+// NormalizeQuery 统一查询文本的大小写与空白，作为反馈统计的键
+func NormalizeQuery(q string) string {
+	q = strings.ToLower(strings.Join(strings.Fields(q), " "))
+	if len(q) > maxFeedbackQuery {
+		q = strings.ToValidUTF8(q[:maxFeedbackQuery], "")
+	}
+	return q
+}
+
+// feedbackQuery 取请求中用于反馈统计的查询文本
+func feedbackQuery(req SearchRequest) string {
+	if req.Keyword != "" {
+		return NormalizeQuery(req.Keyword)
+	}
+	if req.QueryString != nil {
+		return NormalizeQuery(req.QueryString.Query)
+	}
+	return ""
+}
+
+// RecordImpression 记录一次搜索展示，没有查询文本或没有命中时忽略
+func (f *Feedback) RecordImpression(ctx context.Context, query string, start, shown int) error {
+	query = NormalizeQuery(query)
+	if query == "" || shown <= 0 {
+		return nil
+	}
+	return f.db.WithContext(ctx).Create(&SearchImpression{Query: query, StartPos: max(start, 0), Shown: shown}).Error
+}
+
+// RecordClick 记录一次点击或操作
+func (f *Feedback) RecordClick(ctx context.Context, click SearchClick) error {
+	click.Query = NormalizeQuery(click.Query)
+	if click.Action == "" {
+		click.Action = FeedbackClick
+	}
+	if click.Query == "" || click.DocID == "" || click.Position <= 0 ||
+		(click.Action != FeedbackClick && click.Action != FeedbackAct) {
+		return ErrInvalidFeedback
+	}
+	click.ID = 0
+	click.CreatedAt = time.Now()
+	if err := f.db.WithContext(ctx).Create(&click).Error; err != nil {
+		return err
+	}
+	f.mu.Lock()
+	delete(f.cache, click.Query)
+	f.mu.Unlock()
+	return nil
+}
+
+// CTRByPosition 统计 since 之后前 positions 个位置的展示数、点击数与点击率，
+// 同一次展示中的多次点击分别计数
+func (f *Feedback) CTRByPosition(ctx context.Context, since time.Time, positions int) ([]PositionCTR, error) {
+	if positions <= 0 || positions > maxCTRPositions {
+		positions = 10
+	}
+	var pages []struct {
+		StartPos int
+		Shown    int
+		Count    int64
+	}
+	err := f.db.WithContext(ctx).Model(&SearchImpression{}).
+		Select("start_pos, shown, COUNT(*) AS count").
+		Where("created_at >= ? AND start_pos < ?", since, positions).
+		Group("start_pos, shown").
+		Scan(&pages).Error
+	if err != nil {
+		return nil, err
+	}
+	var clicks []struct {
+		Position int
+		Count    int64
+	}
+	err = f.db.WithContext(ctx).Model(&SearchClick{}).
+		Select("position, COUNT(*) AS count").
+		Where("created_at >= ? AND position <= ?", since, positions).
+		Group("position").
+		Scan(&clicks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]PositionCTR, positions)
+	for i := range report {
+		report[i].Position = i + 1
+	}
+	// 展示了第 StartPos+1 到 StartPos+Shown 个位置
+	for _, p := range pages {
+		for pos := p.StartPos + 1; pos <= p.StartPos+p.Shown && pos <= positions; pos++ {
+			report[pos-1].Impressions += p.Count
+		}
+	}
+	for _, c := range clicks {
+		if c.Position >= 1 {
+			report[c.Position-1].Clicks += c.Count
+		}
+	}
+	for i := range report {
+		if report[i].Impressions > 0 {
+			report[i].CTR = float64(report[i].Clicks) / float64(report[i].Impressions)
+		}
+	}
+	return report, nil
+}
+
+// TopClicked 返回查询下点击最多的文档
+func (f *Feedback) TopClicked(ctx context.Context, query string, since time.Time, limit int) ([]DocClicks, error) {
+	if limit <= 0 || limit > feedbackTopDocs {
+		limit = 10
+	}
+	var docs []DocClicks
+	err := f.db.WithContext(ctx).Model(&SearchClick{}).
+		Select("doc_id, COUNT(*) AS clicks").
+		Where("query = ? AND created_at >= ?", NormalizeQuery(query), since).
+		Group("doc_id").
+		Order("clicks DESC").
+		Limit(limit).
+		Scan(&docs).Error
+	return docs, err
+}
+
+// clickCounts 返回查询下近期各文档的点击数，短时缓存
+func (f *Feedback) clickCounts(ctx context.Context, query string) (map[string]int64, error) {
+	now := time.Now()
+	f.mu.Lock()
+	entry, ok := f.cache[query]
+	f.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.counts, nil
+	}
+	docs, err := f.TopClicked(ctx, query, now.Add(-feedbackWindow), feedbackTopDocs)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(docs))
+	for _, d := range docs {
+		counts[d.DocID] = d.Clicks
+	}
+	f.mu.Lock()
+	f.cache[query] = clickCacheEntry{counts: counts, expires: now.Add(feedbackCacheTTL)}
+	f.mu.Unlock()
+	return counts, nil
+}
+
+// Rerank 按历史点击提升常被点击文档的得分并在当前页内重新排序，
+// 只应用于按相关度排序的结果
+func (f *Feedback) Rerank(ctx context.Context, query string, result *SearchResult) error {
+	query = NormalizeQuery(query)
+	if f.boost == 0 || query == "" || len(result.Hits) < 2 {
+		return nil
+	}
+	counts, err := f.clickCounts(ctx, query)
+	if err != nil || len(counts) == 0 {
+		return err
+	}
+	for i := range result.Hits {
+		if n := counts[result.Hits[i].ID]; n > 0 {
+			result.Hits[i].Score *= 1 + f.boost*math.Log1p(float64(n))
+		}
+	}
+	sort.SliceStable(result.Hits, func(i, j int) bool { return result.Hits[i].Score > result.Hits[j].Score })
+	return nil
+}
@@ -0,0 +1,57 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestFeedback(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:search_feedback?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&SearchImpression{}, &SearchClick{}))
+
+	ctx := context.Background()
+	f := NewFeedback(db, 0.5)
+
+	// 两次第一页（10 条）、一次第二页（5 条）展示
+	require.NoError(t, f.RecordImpression(ctx, "Hello  World", 0, 10))
+	require.NoError(t, f.RecordImpression(ctx, "hello world", 0, 10))
+	require.NoError(t, f.RecordImpression(ctx, "hello world", 10, 5))
+	require.NoError(t, f.RecordImpression(ctx, "", 0, 10))
+	require.NoError(t, f.RecordImpression(ctx, "empty", 0, 0))
+
+	require.NoError(t, f.RecordClick(ctx, SearchClick{Query: "HELLO world", DocID: "b", Position: 2}))
+	require.NoError(t, f.RecordClick(ctx, SearchClick{Query: "hello world", DocID: "b", Position: 1, Action: FeedbackAct}))
+	require.NoError(t, f.RecordClick(ctx, SearchClick{Query: "hello world", DocID: "c", Position: 12}))
+	assert.ErrorIs(t, f.RecordClick(ctx, SearchClick{Query: "hello world", DocID: "c"}), ErrInvalidFeedback)
+	assert.ErrorIs(t, f.RecordClick(ctx, SearchClick{Query: "hello world", DocID: "c", Position: 1, Action: "hover"}), ErrInvalidFeedback)
+	assert.ErrorIs(t, f.RecordClick(ctx, SearchClick{DocID: "c", Position: 1}), ErrInvalidFeedback)
+
+	report, err := f.CTRByPosition(ctx, time.Now().Add(-time.Hour), 12)
+	require.NoError(t, err)
+	require.Len(t, report, 12)
+	assert.Equal(t, PositionCTR{Position: 1, Impressions: 2, Clicks: 1, CTR: 0.5}, report[0])
+	assert.Equal(t, PositionCTR{Position: 2, Impressions: 2, Clicks: 1, CTR: 0.5}, report[1])
+	assert.Equal(t, int64(2), report[9].Impressions)
+	assert.Equal(t, PositionCTR{Position: 12, Impressions: 1, Clicks: 1, CTR: 1}, report[11])
+
+	docs, err := f.TopClicked(ctx, "hello world", time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []DocClicks{{DocID: "b", Clicks: 2}, {DocID: "c", Clicks: 1}}, docs)
+
+	// 常被点击的 b 排到 a 之前，未点击的 d 保持在后
+	result := SearchResult{Hits: []Hit{{ID: "a", Score: 1.2}, {ID: "b", Score: 1.0}, {ID: "d", Score: 0.9}}}
+	require.NoError(t, f.Rerank(ctx, "hello world", &result))
+	assert.Equal(t, []string{"b", "a", "d"}, hitIDs(result))
+
+	// boost 为 0 时不调整
+	result = SearchResult{Hits: []Hit{{ID: "a", Score: 1.2}, {ID: "b", Score: 1.0}}}
+	require.NoError(t, NewFeedback(db, 0).Rerank(ctx, "hello world", &result))
+	assert.Equal(t, []string{"a", "b"}, hitIDs(result))
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2/geo"
+)
+
+// validateGeoPoint 校验经纬度取值范围
+func validateGeoPoint(lat, lon float64) error {
+	if lat < -90 || lat > 90 {
+		return fmt.Errorf("latitude %v out of range [-90, 90]", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return fmt.Errorf("longitude %v out of range [-180, 180]", lon)
+	}
+	return nil
+}
+
+// validateGeoFilters 校验地理位置过滤条件，两种引擎共用，避免 bleve 与 Elasticsearch 对非法参数的处理不一致
+func validateGeoFilters(req SearchRequest) error {
+	for _, f := range req.GeoDistances {
+		if f.Field == "" {
+			return fmt.Errorf("geo distance filter: field is required")
+		}
+		if err := validateGeoPoint(f.Lat, f.Lon); err != nil {
+			return fmt.Errorf("geo distance filter on %q: %w", f.Field, err)
+		}
+		d, err := geo.ParseDistance(f.Distance)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("geo distance filter on %q: invalid distance %q", f.Field, f.Distance)
+		}
+	}
+	for _, f := range req.GeoBoundingBoxes {
+		if f.Field == "" {
+			return fmt.Errorf("geo bounding box filter: field is required")
+		}
+		for _, p := range []GeoPoint{f.TopLeft, f.BottomRight} {
+			if err := validateGeoPoint(p.Lat, p.Lon); err != nil {
+				return fmt.Errorf("geo bounding box filter on %q: %w", f.Field, err)
+			}
+		}
+		if f.TopLeft.Lat < f.BottomRight.Lat {
+			return fmt.Errorf("geo bounding box filter on %q: top left must be north of bottom right", f.Field)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoSearch(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		// 天安门、国贸、上海外滩
+		{ID: "tiananmen", Type: "article", Fields: map[string]any{"title": "place", "location": map[string]any{"lat": 39.9087, "lon": 116.3975}}},
+		{ID: "guomao", Type: "article", Fields: map[string]any{"title": "place", "location": map[string]any{"lat": 39.9088, "lon": 116.4605}}},
+		{ID: "bund", Type: "article", Fields: map[string]any{"title": "place", "location": "31.2400,121.4900"}},
+		{ID: "nowhere", Type: "article", Fields: map[string]any{"title": "place"}},
+	}))
+	base := SearchRequest{Keyword: "place", SearchFields: []string{"title"}}
+
+	req := base
+	req.GeoDistances = []GeoDistanceFilter{{Field: "location", Lat: 39.91, Lon: 116.40, Distance: "10km"}}
+	req.Sort = []SortField{{Field: "location", Origin: &GeoPoint{Lat: 39.91, Lon: 116.40}, Unit: "km"}}
+	res, err := e.Search(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tiananmen", "guomao"}, hitIDs(res))
+
+	req = base
+	req.GeoBoundingBoxes = []GeoBoundingBoxFilter{{
+		Field:       "location",
+		TopLeft:     GeoPoint{Lat: 32, Lon: 121},
+		BottomRight: GeoPoint{Lat: 31, Lon: 122},
+	}}
+	res, err = e.Search(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bund"}, hitIDs(res))
+
+	// 按距离由远及近，无坐标的文档视为无穷远
+	req = base
+	req.Sort = []SortField{{Field: "location", Desc: true, Mode: SortModeGeo, Origin: &GeoPoint{Lat: 39.91, Lon: 116.40}}}
+	res, err = e.Search(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nowhere", "bund", "guomao", "tiananmen"}, hitIDs(res))
+}
+
+func TestGeoValidation(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	_, err := e.Search(ctx, SearchRequest{GeoDistances: []GeoDistanceFilter{{Field: "location", Lat: 91, Lon: 0, Distance: "1km"}}})
+	assert.Error(t, err)
+	_, err = e.Search(ctx, SearchRequest{GeoDistances: []GeoDistanceFilter{{Field: "location", Distance: "far"}}})
+	assert.Error(t, err)
+	_, err = e.Search(ctx, SearchRequest{GeoBoundingBoxes: []GeoBoundingBoxFilter{{
+		Field:       "location",
+		TopLeft:     GeoPoint{Lat: 30, Lon: 121},
+		BottomRight: GeoPoint{Lat: 31, Lon: 122},
+	}}})
+	assert.Error(t, err)
+
+	_, err = e.Search(ctx, SearchRequest{Sort: []SortField{{Field: "location"}}})
+	require.ErrorIs(t, err, ErrInvalidSort)
+	_, err = e.Search(ctx, SearchRequest{Sort: []SortField{{Field: "location", Origin: &GeoPoint{}, Unit: "parsecs"}}})
+	require.ErrorIs(t, err, ErrInvalidSort)
+	_, err = e.Search(ctx, SearchRequest{Sort: []SortField{{Field: "views", Origin: &GeoPoint{}}}})
+	require.ErrorIs(t, err, ErrInvalidSort)
+}
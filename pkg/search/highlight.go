@@ -0,0 +1,169 @@
+package search
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/highlight"
+	"github.com/blevesearch/bleve/v2/search/highlight/format/plain"
+	simplefragmenter "github.com/blevesearch/bleve/v2/search/highlight/fragmenter/simple"
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/simple"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+// 高亮样式
+const (
+	// HighlightStyleHTML 片段正文做 HTML 转义，默认以 <mark></mark> 包裹命中词
+	HighlightStyleHTML = "html"
+	// HighlightStylePlain 片段保持原文不转义，默认不包裹命中词，适用于推送通知等纯文本场景
+	HighlightStylePlain = "plain"
+)
+
+const (
+	defaultFragmentSize   = 200
+	maxFragmentSize       = 1000
+	maxHighlightFragments = 10
+
+	// fragmentHighlighterType 注册到 bleve 的高亮器类型，按片段长度与片段数定义实例
+	fragmentHighlighterType = "search_fragments"
+	// bleve 输出片段时用于标记命中词的占位符（Unicode 私有区字符），格式化时替换为实际标签
+	hlStartMarker = "\ue000"
+	hlEndMarker   = "\ue001"
+)
+
+func init() {
+	if err := registry.RegisterHighlighter(fragmentHighlighterType, newFragmentHighlighter); err != nil {
+		panic(err)
+	}
+}
+
+// highlightOptions 规范化后的高亮参数
+type highlightOptions struct {
+	style        string
+	preTag       string
+	postTag      string
+	fragmentSize int
+	maxFragments int
+}
+
+// newHighlightOptions 解析请求中的高亮样式、标签与片段参数，片段长度与数量限制在上限内
+func newHighlightOptions(req SearchRequest) (highlightOptions, error) {
+	opts := highlightOptions{style: strings.ToLower(strings.TrimSpace(req.HighlightStyle))}
+	switch opts.style {
+	case "", HighlightStyleHTML:
+		opts.style = HighlightStyleHTML
+		opts.preTag, opts.postTag = "<mark>", "</mark>"
+	case HighlightStylePlain:
+	default:
+		return highlightOptions{}, fmt.Errorf("unknown highlight style %q", req.HighlightStyle)
+	}
+	if req.PreTag != "" {
+		opts.preTag = req.PreTag
+	}
+	if req.PostTag != "" {
+		opts.postTag = req.PostTag
+	}
+	opts.fragmentSize = defaultFragmentSize
+	if req.FragmentSize > 0 {
+		opts.fragmentSize = min(req.FragmentSize, maxFragmentSize)
+	}
+	opts.maxFragments = 1
+	if req.MaxFragments > 0 {
+		opts.maxFragments = min(req.MaxFragments, maxHighlightFragments)
+	}
+	return opts, nil
+}
+
+// bleveHighlighter 返回对应片段参数的 bleve 高亮器名称，首次使用时在全局注册表中定义
+func (o highlightOptions) bleveHighlighter() (string, error) {
+	name := fmt.Sprintf("%s:%d:%d", fragmentHighlighterType, o.fragmentSize, o.maxFragments)
+	if _, err := bleve.Config.Cache.HighlighterNamed(name); err == nil {
+		return name, nil
+	}
+	_, err := bleve.Config.Cache.DefineHighlighter(name, map[string]interface{}{
+		"type":          fragmentHighlighterType,
+		"fragment_size": o.fragmentSize,
+		"max_fragments": o.maxFragments,
+	})
+	if err != nil {
+		// 并发请求可能已定义同名实例
+		if _, lookupErr := bleve.Config.Cache.HighlighterNamed(name); lookupErr != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// format 将 bleve 输出的占位符片段转换为最终样式
+func (o highlightOptions) format(fragments search.FieldFragmentMap) map[string][]string {
+	if fragments == nil {
+		return nil
+	}
+	out := make(map[string][]string, len(fragments))
+	for field, frags := range fragments {
+		formatted := make([]string, len(frags))
+		for i, f := range frags {
+			formatted[i] = o.formatFragment(f)
+		}
+		out[field] = formatted
+	}
+	return out
+}
+
+func (o highlightOptions) formatFragment(fragment string) string {
+	escape := func(s string) string { return s }
+	if o.style == HighlightStyleHTML {
+		escape = html.EscapeString
+	}
+	var b strings.Builder
+	for {
+		start := strings.Index(fragment, hlStartMarker)
+		if start < 0 {
+			b.WriteString(escape(fragment))
+			return b.String()
+		}
+		b.WriteString(escape(fragment[:start]))
+		rest := fragment[start+len(hlStartMarker):]
+		end := strings.Index(rest, hlEndMarker)
+		if end < 0 {
+			end = len(rest)
+		}
+		b.WriteString(o.preTag)
+		b.WriteString(escape(rest[:end]))
+		b.WriteString(o.postTag)
+		fragment = rest[min(end+len(hlEndMarker), len(rest)):]
+	}
+}
+
+// fragmentHighlighter bleve 检索时固定只取每字段 1 个片段，这里改为按实例配置的片段数返回
+type fragmentHighlighter struct {
+	*simple.Highlighter
+	maxFragments int
+}
+
+func newFragmentHighlighter(config map[string]interface{}, _ *registry.Cache) (highlight.Highlighter, error) {
+	size, _ := config["fragment_size"].(int)
+	if size <= 0 {
+		size = defaultFragmentSize
+	}
+	n, _ := config["max_fragments"].(int)
+	if n <= 0 {
+		n = 1
+	}
+	return &fragmentHighlighter{
+		Highlighter: simple.NewHighlighter(
+			simplefragmenter.NewFragmenter(size),
+			plain.NewFragmentFormatter(hlStartMarker, hlEndMarker),
+			simple.DefaultSeparator,
+		),
+		maxFragments: n,
+	}, nil
+}
+
+func (h *fragmentHighlighter) BestFragmentsInField(dm *search.DocumentMatch, doc index.Document, field string, _ int) []string {
+	return h.Highlighter.BestFragmentsInField(dm, doc, field, h.maxFragments)
+}
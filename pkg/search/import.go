@@ -0,0 +1,123 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// maxImportLineSize 单行文档的最大字节数
+	maxImportLineSize = 4 << 20
+	// maxImportErrors 返回的逐行错误数量上限
+	maxImportErrors = 1000
+)
+
+// ImportLineError 导入失败的行
+type ImportLineError struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportResult NDJSON 导入结果
+type ImportResult struct {
+	Total     int               `json:"total"`
+	Indexed   int               `json:"indexed"`
+	Failed    int               `json:"failed"`
+	Errors    []ImportLineError `json:"errors"`
+	Truncated bool              `json:"truncated"`
+}
+
+func (r *ImportResult) fail(line int, id string, err error) {
+	r.Failed++
+	if len(r.Errors) >= maxImportErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, ImportLineError{Line: line, ID: id, Error: err.Error()})
+}
+
+// ImportNDJSON 逐行读取 NDJSON 文档并分批写入索引。
+// 每批写入完成后才继续读取，从而对上游请求体形成背压
+func ImportNDJSON(ctx context.Context, engine Engine, r io.Reader, batchSize int) (ImportResult, error) {
+	return ImportNDJSONWithProgress(ctx, engine, r, batchSize, nil)
+}
+
+// ImportNDJSONWithProgress 与 ImportNDJSON 相同，每写入一批调用 progress 报告当前结果
+func ImportNDJSONWithProgress(ctx context.Context, engine Engine, r io.Reader, batchSize int, progress func(ImportResult)) (ImportResult, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	res := ImportResult{Errors: []ImportLineError{}}
+
+	docs := make([]Doc, 0, batchSize)
+	lines := make([]int, 0, batchSize)
+	flush := func() error {
+		if len(docs) == 0 {
+			return nil
+		}
+		if err := engine.IndexBatch(ctx, docs); err != nil {
+			if errors.Is(err, ErrClosed) || ctx.Err() != nil {
+				return err
+			}
+			for i, d := range docs {
+				res.fail(lines[i], d.ID, err)
+			}
+		} else {
+			res.Indexed += len(docs)
+		}
+		docs = docs[:0]
+		lines = lines[:0]
+		if progress != nil {
+			progress(res)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLineSize)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		res.Total++
+
+		var doc Doc
+		if err := json.Unmarshal(line, &doc); err != nil {
+			res.fail(lineNo, "", fmt.Errorf("invalid json: %w", err))
+			continue
+		}
+		if doc.ID == "" {
+			res.fail(lineNo, "", errors.New("missing id"))
+			continue
+		}
+		if len(doc.Fields) == 0 {
+			res.fail(lineNo, doc.ID, errors.New("missing fields"))
+			continue
+		}
+
+		docs = append(docs, doc)
+		lines = append(lines, lineNo)
+		if len(docs) >= batchSize {
+			if err := flush(); err != nil {
+				return res, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			err = fmt.Errorf("line %d exceeds %d bytes", lineNo+1, maxImportLineSize)
+		}
+		_ = flush()
+		return res, err
+	}
+	return res, flush()
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"HibiscusIM/pkg/config"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportNDJSON(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	body := strings.Join([]string{
+		`{"id":"1","type":"article","fields":{"title":"hello world"}}`,
+		`{"id":"2","type":"article","fields":{"title":"hello again"}}`,
+		``,
+		`not json`,
+		`{"type":"article","fields":{"title":"no id"}}`,
+		`{"id":"3","type":"article","fields":{"title":"third"}}`,
+	}, "\n")
+
+	res, err := ImportNDJSON(ctx, e, strings.NewReader(body), 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, res.Total)
+	assert.Equal(t, 3, res.Indexed)
+	assert.Equal(t, 2, res.Failed)
+	require.Len(t, res.Errors, 2)
+	assert.Equal(t, 4, res.Errors[0].Line)
+	assert.Equal(t, 5, res.Errors[1].Line)
+
+	sr, err := e.Search(ctx, SearchRequest{Keyword: "hello", SearchFields: []string{"title"}})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, sr.Total)
+}
+
+func TestImportRouteRequiresAdmin(t *testing.T) {
+	prev := config.GlobalConfig
+	config.GlobalConfig = &config.Config{SearchEnabled: true, SearchBatchSize: 10}
+	defer func() { config.GlobalConfig = prev }()
+
+	gin.SetMode(gin.TestMode)
+	admin := false
+	h := NewSearchHandlers(newTestEngine(t))
+	h.SetAdminAuthorizer(func(c *gin.Context) bool { return admin })
+	r := gin.New()
+	h.RegisterSearchRoutes(r.Group(""))
+
+	body := `{"id":"1","type":"article","fields":{"title":"hello"}}`
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/search/import", strings.NewReader(body)))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	admin = true
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/search/import", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,358 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// AllIndexes SearchRequest.Index 取该值时搜索默认索引及全部命名索引
+const AllIndexes = "*"
+
+// DefaultMaxIndexes 未配置 MaxIndexes 时命名索引的数量上限
+const DefaultMaxIndexes = 64
+
+var (
+	ErrInvalidIndexName = errors.New("invalid index name")
+	ErrIndexNotFound    = errors.New("index not found")
+	ErrTooManyIndexes   = errors.New("too many indexes")
+)
+
+// indexNamePattern 命名索引同时作为目录名，只允许小写字母、数字、下划线与连字符
+var indexNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// IndexManager 管理默认索引与 IndexDir 下的多个命名 bleve 索引（按租户或文档类型划分）。
+// 命名索引只能通过 Open 新建；写入按 Doc.Index 路由到已存在的索引，搜索按 SearchRequest.Index 路由，
+// 多个索引以逗号分隔或使用 AllIndexes，通过 bleve IndexAlias 合并结果
+type IndexManager struct {
+	cfg     Config
+	mapping mapping.IndexMapping
+	def     *bleveEngine
+
+	mu      sync.RWMutex
+	indexes map[string]*bleveEngine
+	closed  bool
+}
+
+// NewIndexManager 打开默认索引以及 cfg.IndexDir 下已存在的命名索引
+func NewIndexManager(cfg Config, m mapping.IndexMapping) (*IndexManager, error) {
+	if cfg.IndexDir == "" {
+		return nil, errors.New("index dir is required")
+	}
+	if err := os.MkdirAll(cfg.IndexDir, 0o755); err != nil {
+		return nil, err
+	}
+	defCfg := cfg
+	defCfg.IndexDir = ""
+	def, err := newBleveEngine(defCfg, m)
+	if err != nil {
+		return nil, err
+	}
+	im := &IndexManager{cfg: cfg, mapping: m, def: def.(*bleveEngine), indexes: make(map[string]*bleveEngine)}
+
+	entries, err := os.ReadDir(cfg.IndexDir)
+	if err != nil {
+		_ = im.Close()
+		return nil, err
+	}
+	for _, ent := range entries {
+		if !ent.IsDir() || !indexNamePattern.MatchString(ent.Name()) {
+			continue
+		}
+		if _, err := im.open(ent.Name(), false); err != nil {
+			_ = im.Close()
+			return nil, fmt.Errorf("open index %s: %w", ent.Name(), err)
+		}
+	}
+	return im, nil
+}
+
+// Names 已打开的命名索引，按名称排序
+func (m *IndexManager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return sortedKeys(m.indexes)
+}
+
+// Open 返回命名索引，不存在时按映射新建，超过 MaxIndexes 时返回 ErrTooManyIndexes；name 为空时返回默认索引。
+// 仅供管理员或启动流程调用，不能由客户端写入的文档触发
+func (m *IndexManager) Open(name string) (Engine, error) {
+	e, err := m.open(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// open 获取命名索引，create 为 false 时不新建
+func (m *IndexManager) open(name string, create bool) (*bleveEngine, error) {
+	if name == "" {
+		return m.def, nil
+	}
+	if !indexNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidIndexName, name)
+	}
+	m.mu.RLock()
+	e, ok := m.indexes[name]
+	closed := m.closed
+	m.mu.RUnlock()
+	if closed {
+		return nil, ErrClosed
+	}
+	if ok {
+		return e, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	if e, ok := m.indexes[name]; ok {
+		return e, nil
+	}
+	cfg := m.cfg
+	cfg.IndexDir = ""
+	cfg.IndexPath = filepath.Join(m.cfg.IndexDir, name)
+	if _, err := os.Stat(cfg.IndexPath); os.IsNotExist(err) {
+		if !create {
+			return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, name)
+		}
+		if len(m.indexes) >= m.maxIndexes() {
+			return nil, fmt.Errorf("%w: limit is %d", ErrTooManyIndexes, m.maxIndexes())
+		}
+	}
+	engine, err := newBleveEngine(cfg, m.mapping)
+	if err != nil {
+		return nil, err
+	}
+	e = engine.(*bleveEngine)
+	m.indexes[name] = e
+	return e, nil
+}
+
+// maxIndexes 命名索引的数量上限
+func (m *IndexManager) maxIndexes() int {
+	if m.cfg.MaxIndexes > 0 {
+		return m.cfg.MaxIndexes
+	}
+	return DefaultMaxIndexes
+}
+
+// Drop 关闭并删除命名索引
+func (m *IndexManager) Drop(name string) error {
+	if !indexNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidIndexName, name)
+	}
+	m.mu.Lock()
+	e, ok := m.indexes[name]
+	delete(m.indexes, name)
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, name)
+	}
+	if err := e.Close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(e.cfg.IndexPath)
+}
+
+// all 默认索引及全部命名索引
+func (m *IndexManager) all() []*bleveEngine {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*bleveEngine, 0, len(m.indexes)+1)
+	out = append(out, m.def)
+	for _, name := range sortedKeys(m.indexes) {
+		out = append(out, m.indexes[name])
+	}
+	return out
+}
+
+func sortedKeys(indexes map[string]*bleveEngine) []string {
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// searcher 按 SearchRequest.Index 选择查询的索引，多个索引时返回基于 IndexAlias 的只读引擎
+func (m *IndexManager) searcher(index string) (*bleveEngine, error) {
+	var targets []*bleveEngine
+	if strings.TrimSpace(index) == AllIndexes {
+		targets = m.all()
+	} else {
+		seen := make(map[string]bool)
+		for _, name := range strings.Split(index, ",") {
+			if name = strings.TrimSpace(name); seen[name] {
+				continue
+			}
+			seen[name] = true
+			e, err := m.open(name, false)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, e)
+		}
+	}
+	if len(targets) == 1 {
+		return targets[0], nil
+	}
+	indexes := make([]bleve.Index, 0, len(targets))
+	for _, e := range targets {
+		if err := e.guard(); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, e.index)
+	}
+	return &bleveEngine{cfg: m.cfg, index: bleve.NewIndexAlias(indexes...), defaultFields: m.def.defaultFields}, nil
+}
+
+// Index 写入 doc.Index 指定的索引，索引不存在时返回 ErrIndexNotFound
+func (m *IndexManager) Index(ctx context.Context, doc Doc) error {
+	e, err := m.open(doc.Index, false)
+	if err != nil {
+		return err
+	}
+	return e.Index(ctx, doc)
+}
+
+// IndexBatch 按 Doc.Index 分组后分别批量写入，任一索引不存在时返回 ErrIndexNotFound
+func (m *IndexManager) IndexBatch(ctx context.Context, docs []Doc) error {
+	groups := make(map[string][]Doc)
+	var order []string
+	for _, d := range docs {
+		if _, ok := groups[d.Index]; !ok {
+			order = append(order, d.Index)
+		}
+		groups[d.Index] = append(groups[d.Index], d)
+	}
+	for _, name := range order {
+		e, err := m.open(name, false)
+		if err != nil {
+			return err
+		}
+		if err := e.IndexBatch(ctx, groups[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete 从全部索引中删除该ID，跨租户的文档ID需保持唯一
+func (m *IndexManager) Delete(ctx context.Context, id string) error {
+	for _, e := range m.all() {
+		if err := e.Delete(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search 在 req.Index 指定的一个或多个索引中搜索
+func (m *IndexManager) Search(ctx context.Context, req SearchRequest) (SearchResult, error) {
+	e, err := m.searcher(req.Index)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return e.Search(ctx, req)
+}
+
+// Scroll 在 req.Index 指定的一个或多个索引中游标分页
+func (m *IndexManager) Scroll(ctx context.Context, req SearchRequest, cursor string) (ScrollResult, error) {
+	e, err := m.searcher(req.Index)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	return e.Scroll(ctx, req, cursor)
+}
+
+// GetAutoCompleteSuggestions 使用默认索引
+func (m *IndexManager) GetAutoCompleteSuggestions(ctx context.Context, keyword string) ([]string, error) {
+	return m.def.GetAutoCompleteSuggestions(ctx, keyword)
+}
+
+// GetSearchSuggestions 使用默认索引
+func (m *IndexManager) GetSearchSuggestions(ctx context.Context, keyword string) ([]string, error) {
+	return m.def.GetSearchSuggestions(ctx, keyword)
+}
+
+// Compact 依次合并全部索引，返回合计结果
+func (m *IndexManager) Compact(ctx context.Context) (CompactResult, error) {
+	var total CompactResult
+	for _, e := range m.all() {
+		res, err := e.Compact(ctx)
+		if err != nil {
+			return total, err
+		}
+		total.SizeBefore += res.SizeBefore
+		total.SizeAfter += res.SizeAfter
+		total.Reclaimed += res.Reclaimed
+		total.Duration += res.Duration
+	}
+	return total, nil
+}
+
+// Snapshots 返回全部索引的快照信息
+func (m *IndexManager) Snapshots() ([]Snapshot, error) {
+	var snaps []Snapshot
+	for _, e := range m.all() {
+		s, err := e.Snapshots()
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, s...)
+	}
+	return snaps, nil
+}
+
+// IndexStats 返回全部索引的合计文档数与磁盘占用
+func (m *IndexManager) IndexStats(ctx context.Context) (IndexStats, error) {
+	total := IndexStats{Name: AllIndexes, Driver: DriverBleve}
+	for _, e := range m.all() {
+		s, err := e.IndexStats(ctx)
+		if err != nil {
+			return IndexStats{}, err
+		}
+		total.DocCount += s.DocCount
+		total.DiskSize += s.DiskSize
+	}
+	return total, nil
+}
+
+// Recreate 只重建默认索引，命名索引通过 Drop 删除
+func (m *IndexManager) Recreate(ctx context.Context, data []byte) error {
+	return m.def.Recreate(ctx, data)
+}
+
+// Close 关闭全部索引
+func (m *IndexManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	indexes := m.indexes
+	m.indexes = map[string]*bleveEngine{}
+	m.mu.Unlock()
+
+	err := m.def.Close()
+	for _, e := range indexes {
+		if cerr := e.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIndexManager(t *testing.T, dir string) Engine {
+	t.Helper()
+	e, err := New(Config{
+		IndexPath: filepath.Join(dir, "default.bleve"),
+		IndexDir:  filepath.Join(dir, "indexes"),
+	}, BuildIndexMapping(""))
+	require.NoError(t, err)
+	return e
+}
+
+func TestIndexManager(t *testing.T) {
+	dir := t.TempDir()
+	e := newTestIndexManager(t, dir)
+	ctx := context.Background()
+
+	// 写入不会新建命名索引
+	assert.ErrorIs(t, e.Index(ctx, Doc{ID: "a0", Index: "tenant-a"}), ErrIndexNotFound)
+	assert.ErrorIs(t, e.IndexBatch(ctx, []Doc{{ID: "a0", Index: "tenant-a"}}), ErrIndexNotFound)
+	assert.NoDirExists(t, filepath.Join(dir, "indexes", "tenant-a"))
+	for _, name := range []string{"tenant-a", "tenant-b"} {
+		_, err := e.(*IndexManager).Open(name)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		{ID: "d1", Type: "article", Fields: map[string]any{"title": "hello default"}},
+		{ID: "a1", Type: "article", Index: "tenant-a", Fields: map[string]any{"title": "hello tenant a"}},
+		{ID: "b1", Type: "article", Index: "tenant-b", Fields: map[string]any{"title": "hello tenant b"}},
+	}))
+	require.NoError(t, e.Index(ctx, Doc{ID: "a2", Type: "article", Index: "tenant-a", Fields: map[string]any{"title": "hello again"}}))
+
+	search := func(index string) []string {
+		t.Helper()
+		res, err := e.Search(ctx, SearchRequest{Index: index, Keyword: "hello", SearchFields: []string{"title"}, SortBy: []string{"_id"}})
+		require.NoError(t, err)
+		return hitIDs(res)
+	}
+	assert.Equal(t, []string{"d1"}, search(""))
+	assert.Equal(t, []string{"a1", "a2"}, search("tenant-a"))
+	assert.Equal(t, []string{"a1", "a2", "b1"}, search("tenant-a, tenant-b"))
+	assert.Equal(t, []string{"a1", "a2", "b1", "d1"}, search(AllIndexes))
+
+	_, err := e.Search(ctx, SearchRequest{Index: "missing", Keyword: "hello"})
+	assert.ErrorIs(t, err, ErrIndexNotFound)
+	assert.ErrorIs(t, e.Index(ctx, Doc{ID: "x", Index: "../escape"}), ErrInvalidIndexName)
+
+	require.NoError(t, e.Delete(ctx, "a1"))
+	assert.Equal(t, []string{"a2"}, search("tenant-a"))
+
+	stats, err := e.IndexStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.DocCount)
+
+	// 重新打开时加载已有的命名索引
+	require.NoError(t, e.Close())
+	e = newTestIndexManager(t, dir)
+	t.Cleanup(func() { _ = e.Close() })
+	im := e.(*IndexManager)
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, im.Names())
+	assert.Equal(t, []string{"b1"}, search("tenant-b"))
+
+	require.NoError(t, im.Drop("tenant-b"))
+	assert.Equal(t, []string{"tenant-a"}, im.Names())
+	assert.Equal(t, []string{"a2", "d1"}, search(AllIndexes))
+}
+
+func TestIndexManagerMaxIndexes(t *testing.T) {
+	dir := t.TempDir()
+	e, err := New(Config{
+		IndexPath:  filepath.Join(dir, "default.bleve"),
+		IndexDir:   filepath.Join(dir, "indexes"),
+		MaxIndexes: 2,
+	}, BuildIndexMapping(""))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = e.Close() })
+	im := e.(*IndexManager)
+
+	for _, name := range []string{"a", "b"} {
+		_, err := im.Open(name)
+		require.NoError(t, err)
+	}
+	_, err = im.Open("c")
+	assert.ErrorIs(t, err, ErrTooManyIndexes)
+	assert.NoDirExists(t, filepath.Join(dir, "indexes", "c"))
+
+	// 已存在的索引不受上限影响，删除后可以新建
+	_, err = im.Open("a")
+	require.NoError(t, err)
+	require.NoError(t, im.Drop("b"))
+	_, err = im.Open("c")
+	require.NoError(t, err)
+}
@@ -0,0 +1,483 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// IndexedModel 需要自动同步到搜索索引的 GORM 模型
+type IndexedModel struct {
+	// Model 模型指针，如 &models.Group{}
+	Model interface{}
+	// Type 文档类型，对应索引映射中的文档类型
+	Type string
+	// Fields 将模型转换为文档字段，obj 为模型指针
+	Fields func(obj interface{}) map[string]interface{}
+}
+
+// IndexerOptions 异步同步队列配置
+type IndexerOptions struct {
+	// 待同步队列长度，满时丢弃变更并计数，需通过全量重建修复
+	QueueSize int
+	// 单次同步的最大变更数
+	BatchSize int
+	// 攒批等待时间，同时给事务提交留出时间
+	FlushInterval time.Duration
+}
+
+// DefaultIndexerOptions 默认队列配置
+func DefaultIndexerOptions() IndexerOptions {
+	return IndexerOptions{QueueSize: 10000, BatchSize: 200, FlushInterval: 500 * time.Millisecond}
+}
+
+// IndexerStats 同步统计
+type IndexerStats struct {
+	Queued  int   `json:"queued"`
+	Indexed int64 `json:"indexed"`
+	Deleted int64 `json:"deleted"`
+	Failed  int64 `json:"failed"`
+	Dropped int64 `json:"dropped"`
+}
+
+// ReindexResult 全量重建结果，按文档类型统计
+type ReindexResult struct {
+	Indexed  map[string]int `json:"indexed"`
+	Duration time.Duration  `json:"duration"`
+}
+
+type indexedModel struct {
+	IndexedModel
+	schema *schema.Schema
+	pk     *schema.Field
+}
+
+type indexOp struct {
+	table  string
+	id     interface{}
+	delete bool
+}
+
+// Indexer 通过 GORM 回调监听模型的创建、更新、删除，异步同步到搜索引擎。
+// 变更只记录主键，同步时按主键回表读取最新数据，查不到（已删除或事务回滚）则删除文档；
+// 按条件批量更新/删除不携带主键，不会触发同步，需要调用 Reindex
+type Indexer struct {
+	engine Engine
+	opts   IndexerOptions
+
+	mu     sync.RWMutex
+	db     *gorm.DB
+	models map[string]*indexedModel
+
+	queue   chan indexOp
+	stop    chan struct{}
+	done    chan struct{}
+	started sync.Once
+	closed  sync.Once
+
+	indexed atomic.Int64
+	deleted atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+
+	onReindex func(types []string, res ReindexResult, err error)
+}
+
+// NewIndexer 创建索引同步器，需调用 Register 注册模型后 Start
+func NewIndexer(engine Engine, opts IndexerOptions) *Indexer {
+	def := DefaultIndexerOptions()
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = def.QueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = def.BatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = def.FlushInterval
+	}
+	return &Indexer{
+		engine: engine,
+		opts:   opts,
+		models: make(map[string]*indexedModel),
+		queue:  make(chan indexOp, opts.QueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Register 注册需要同步的模型，并在 db 上安装回调；只能绑定一个 db
+func (i *Indexer) Register(db *gorm.DB, models ...IndexedModel) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.db != nil && i.db != db {
+		return errors.New("indexer already bound to another db")
+	}
+	for _, m := range models {
+		if m.Type == "" || m.Fields == nil {
+			return fmt.Errorf("indexed model %T requires type and fields", m.Model)
+		}
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m.Model); err != nil {
+			return err
+		}
+		pk := stmt.Schema.PrioritizedPrimaryField
+		if pk == nil {
+			return fmt.Errorf("indexed model %T has no primary key", m.Model)
+		}
+		i.models[stmt.Schema.Table] = &indexedModel{IndexedModel: m, schema: stmt.Schema, pk: pk}
+	}
+	if i.db != nil {
+		return nil
+	}
+	i.db = db
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("search:index_create", i.afterSave); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("search:index_update", i.afterSave); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("search:index_delete", i.afterDelete)
+}
+
+// Start 启动后台同步
+func (i *Indexer) Start() {
+	i.started.Do(func() { go i.run() })
+}
+
+// Close 停止后台同步，并同步完队列中剩余的变更
+func (i *Indexer) Close() {
+	i.closed.Do(func() {
+		close(i.stop)
+		started := true
+		i.started.Do(func() { started = false })
+		if started {
+			<-i.done
+		}
+	})
+}
+
+// Stats 同步统计
+func (i *Indexer) Stats() IndexerStats {
+	return IndexerStats{
+		Queued:  len(i.queue),
+		Indexed: i.indexed.Load(),
+		Deleted: i.deleted.Load(),
+		Failed:  i.failed.Load(),
+		Dropped: i.dropped.Load(),
+	}
+}
+
+// DocID 文档ID，格式为 类型:主键
+func DocID(docType string, id interface{}) string {
+	return fmt.Sprintf("%s:%v", docType, id)
+}
+
+func (i *Indexer) afterSave(tx *gorm.DB) {
+	i.capture(tx, false)
+}
+
+func (i *Indexer) afterDelete(tx *gorm.DB) {
+	i.capture(tx, true)
+}
+
+// capture 从语句目标中提取主键并入队，不阻塞业务写入
+func (i *Indexer) capture(tx *gorm.DB, deleted bool) {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return
+	}
+	i.mu.RLock()
+	m, ok := i.models[tx.Statement.Schema.Table]
+	i.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	push := func(rv reflect.Value) {
+		for rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return
+		}
+		id, zero := m.pk.ValueOf(tx.Statement.Context, rv)
+		if zero {
+			return
+		}
+		select {
+		case i.queue <- indexOp{table: m.schema.Table, id: id, delete: deleted}:
+		default:
+			i.dropped.Add(1)
+		}
+	}
+
+	rv := tx.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for n := 0; n < rv.Len(); n++ {
+			push(rv.Index(n))
+		}
+	default:
+		push(rv)
+	}
+}
+
+func (i *Indexer) run() {
+	defer close(i.done)
+	ticker := time.NewTicker(i.opts.FlushInterval)
+	defer ticker.Stop()
+
+	pending := make([]indexOp, 0, i.opts.BatchSize)
+	for {
+		select {
+		case op := <-i.queue:
+			pending = append(pending, op)
+			if len(pending) >= i.opts.BatchSize {
+				i.sync(pending)
+				pending = pending[:0]
+			}
+		case <-ticker.C:
+			if len(pending) > 0 {
+				i.sync(pending)
+				pending = pending[:0]
+			}
+		case <-i.stop:
+			for {
+				select {
+				case op := <-i.queue:
+					pending = append(pending, op)
+				default:
+					i.sync(pending)
+					return
+				}
+			}
+		}
+	}
+}
+
+// sync 合并同一文档的多次变更后同步到引擎
+func (i *Indexer) sync(ops []indexOp) {
+	if len(ops) == 0 {
+		return
+	}
+	ctx := context.Background()
+	latest := make(map[string]indexOp, len(ops))
+	for _, op := range ops {
+		latest[op.table+"\x00"+fmt.Sprint(op.id)] = op
+	}
+	byTable := make(map[string][]interface{})
+	for _, op := range latest {
+		byTable[op.table] = append(byTable[op.table], op.id)
+	}
+
+	i.mu.RLock()
+	db := i.db
+	i.mu.RUnlock()
+
+	for table, ids := range byTable {
+		i.mu.RLock()
+		m := i.models[table]
+		i.mu.RUnlock()
+
+		found, err := m.load(db.Session(&gorm.Session{NewDB: true, Context: ctx}), ids)
+		if err != nil {
+			i.failed.Add(int64(len(ids)))
+			log.Printf("search indexer: load %s failed: %v", table, err)
+			continue
+		}
+		docs := make([]Doc, 0, len(found))
+		for _, id := range ids {
+			key := fmt.Sprint(id)
+			if obj, ok := found[key]; ok {
+				docs = append(docs, Doc{ID: DocID(m.Type, key), Type: m.Type, Fields: m.Fields(obj)})
+				continue
+			}
+			if err := i.engine.Delete(ctx, DocID(m.Type, key)); err != nil {
+				i.failed.Add(1)
+				log.Printf("search indexer: delete %s failed: %v", DocID(m.Type, key), err)
+				continue
+			}
+			i.deleted.Add(1)
+		}
+		if len(docs) == 0 {
+			continue
+		}
+		if err := i.engine.IndexBatch(ctx, docs); err != nil {
+			i.failed.Add(int64(len(docs)))
+			log.Printf("search indexer: index %s failed: %v", table, err)
+			continue
+		}
+		i.indexed.Add(int64(len(docs)))
+	}
+}
+
+// load 按主键批量回表，返回 主键字符串 -> 模型指针
+func (m *indexedModel) load(db *gorm.DB, ids []interface{}) (map[string]interface{}, error) {
+	rows := reflect.New(reflect.SliceOf(m.schema.ModelType))
+	if err := db.Where(m.pk.DBName+" IN ?", ids).Find(rows.Interface()).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, rows.Elem().Len())
+	for n := 0; n < rows.Elem().Len(); n++ {
+		row := rows.Elem().Index(n).Addr()
+		id, _ := m.pk.ValueOf(db.Statement.Context, row.Elem())
+		out[fmt.Sprint(id)] = row.Interface()
+	}
+	return out, nil
+}
+
+// SnippetSource 按 类型:主键 格式的文档ID回表读取 docType 模型的字段原文，供 SnippetEngine 补齐未存储的字段
+func (i *Indexer) SnippetSource(docType string) SnippetSource {
+	return func(ctx context.Context, ids []string) (map[string]map[string]string, error) {
+		db, models := i.registered([]string{docType})
+		if db == nil || len(models) == 0 {
+			return nil, fmt.Errorf("indexer has no model for %s", docType)
+		}
+		m := models[0]
+		keys := make([]interface{}, 0, len(ids))
+		for _, id := range ids {
+			if t, key, ok := strings.Cut(id, ":"); ok && t == docType {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			return nil, nil
+		}
+		found, err := m.load(db.Session(&gorm.Session{NewDB: true, Context: ctx}), keys)
+		if err != nil {
+			return nil, err
+		}
+		out := make(map[string]map[string]string, len(found))
+		for key, obj := range found {
+			fields := make(map[string]string)
+			for k, v := range m.Fields(obj) {
+				if s, ok := v.(string); ok {
+					fields[k] = s
+				}
+			}
+			out[DocID(docType, key)] = fields
+		}
+		return out, nil
+	}
+}
+
+// OnReindex 设置全量重建完成（含失败）后的回调，用于记录系统事件等
+func (i *Indexer) OnReindex(fn func(types []string, res ReindexResult, err error)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.onReindex = fn
+}
+
+// Reindex 分批读取所有已注册模型并重新写入索引，types 为空时重建全部类型。
+// 不会清理数据库中已不存在的文档
+func (i *Indexer) Reindex(ctx context.Context, types ...string) (ReindexResult, error) {
+	return i.ReindexWithProgress(ctx, nil, types...)
+}
+
+// ReindexWithProgress 与 Reindex 相同，每写入一批调用 progress
+func (i *Indexer) ReindexWithProgress(ctx context.Context, progress func(docType string, n int), types ...string) (ReindexResult, error) {
+	res, err := i.reindexInto(ctx, i.engine, types, progress)
+	i.mu.RLock()
+	fn := i.onReindex
+	i.mu.RUnlock()
+	if fn != nil {
+		fn(types, res, err)
+	}
+	return res, err
+}
+
+// registered 已注册的模型，types 为空时返回全部
+func (i *Indexer) registered(types []string) (*gorm.DB, []*indexedModel) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	want := make(map[string]bool, len(types))
+	for _, t := range types {
+		want[t] = true
+	}
+	models := make([]*indexedModel, 0, len(i.models))
+	for _, m := range i.models {
+		if len(want) == 0 || want[m.Type] {
+			models = append(models, m)
+		}
+	}
+	return i.db, models
+}
+
+// Count 各文档类型的记录数，实现 MigrationSource
+func (i *Indexer) Count(ctx context.Context) (map[string]int64, error) {
+	return i.CountTypes(ctx)
+}
+
+// CountTypes 指定文档类型的记录数，types 为空时统计全部类型
+func (i *Indexer) CountTypes(ctx context.Context, types ...string) (map[string]int64, error) {
+	db, models := i.registered(types)
+	if db == nil {
+		return nil, errors.New("indexer has no registered models")
+	}
+	out := make(map[string]int64, len(models))
+	for _, m := range models {
+		var n int64
+		if err := db.Session(&gorm.Session{NewDB: true, Context: ctx}).Model(m.Model).Count(&n).Error; err != nil {
+			return nil, fmt.Errorf("count %s: %w", m.Type, err)
+		}
+		out[m.Type] += n
+	}
+	return out, nil
+}
+
+// Backfill 将全部已注册模型写入 target，实现 MigrationSource
+func (i *Indexer) Backfill(ctx context.Context, target Engine, progress func(docType string, n int)) error {
+	_, err := i.reindexInto(ctx, target, nil, progress)
+	return err
+}
+
+// reindexInto 分批读取模型写入 engine，每写入一批调用 progress
+func (i *Indexer) reindexInto(ctx context.Context, engine Engine, types []string, progress func(docType string, n int)) (ReindexResult, error) {
+	start := time.Now()
+	res := ReindexResult{Indexed: make(map[string]int)}
+
+	db, models := i.registered(types)
+	if db == nil {
+		return res, errors.New("indexer has no registered models")
+	}
+	for _, m := range models {
+		rows := reflect.New(reflect.SliceOf(m.schema.ModelType))
+		err := db.Session(&gorm.Session{NewDB: true, Context: ctx}).
+			Model(m.Model).
+			FindInBatches(rows.Interface(), i.opts.BatchSize, func(tx *gorm.DB, batch int) error {
+				list := rows.Elem()
+				docs := make([]Doc, 0, list.Len())
+				for n := 0; n < list.Len(); n++ {
+					row := list.Index(n).Addr()
+					id, _ := m.pk.ValueOf(ctx, row.Elem())
+					docs = append(docs, Doc{ID: DocID(m.Type, id), Type: m.Type, Fields: m.Fields(row.Interface())})
+				}
+				if err := engine.IndexBatch(ctx, docs); err != nil {
+					return err
+				}
+				res.Indexed[m.Type] += len(docs)
+				if progress != nil {
+					progress(m.Type, len(docs))
+				}
+				return nil
+			}).Error
+		if err != nil {
+			res.Duration = time.Since(start)
+			return res, fmt.Errorf("reindex %s: %w", m.Type, err)
+		}
+	}
+	res.Duration = time.Since(start)
+	return res, nil
+}
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type indexedArticle struct {
+	ID    uint `gorm:"primaryKey"`
+	Title string
+	Body  string
+}
+
+func articleFields(obj interface{}) map[string]interface{} {
+	a := obj.(*indexedArticle)
+	return map[string]interface{}{"title": a.Title, "body": a.Body}
+}
+
+func TestIndexerSync(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:search_indexer?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&indexedArticle{}))
+
+	e := newTestEngine(t)
+	ctx := context.Background()
+	indexer := NewIndexer(e, IndexerOptions{FlushInterval: 20 * time.Millisecond})
+	require.NoError(t, indexer.Register(db, IndexedModel{Model: &indexedArticle{}, Type: "article", Fields: articleFields}))
+	indexer.Start()
+	defer indexer.Close()
+
+	search := func(keyword string) []string {
+		res, err := e.Search(ctx, SearchRequest{Keyword: keyword, SearchFields: []string{"title", "body"}})
+		require.NoError(t, err)
+		ids := make([]string, 0, len(res.Hits))
+		for _, h := range res.Hits {
+			ids = append(ids, h.ID)
+		}
+		return ids
+	}
+
+	// 创建后自动索引
+	first := indexedArticle{Title: "hello world"}
+	require.NoError(t, db.Create(&first).Error)
+	require.NoError(t, db.Create(&[]indexedArticle{{Title: "hello batch"}, {Title: "other"}}).Error)
+	require.Eventually(t, func() bool { return len(search("hello")) == 2 }, 2*time.Second, 20*time.Millisecond)
+
+	// 部分更新时回表读取完整数据
+	require.NoError(t, db.Model(&first).Update("body", "updated text").Error)
+	require.Eventually(t, func() bool { return len(search("updated")) == 1 }, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []string{DocID("article", first.ID)}, search("updated"))
+
+	// 删除后移除文档
+	require.NoError(t, db.Delete(&first).Error)
+	require.Eventually(t, func() bool { return len(search("hello")) == 1 }, 2*time.Second, 20*time.Millisecond)
+
+	stats := indexer.Stats()
+	assert.EqualValues(t, 1, stats.Deleted)
+	assert.Zero(t, stats.Failed)
+
+	// 绕过回调写入的数据通过全量重建补齐
+	var hooked ReindexResult
+	indexer.OnReindex(func(_ []string, res ReindexResult, err error) { hooked = res })
+	require.NoError(t, db.Exec("INSERT INTO indexed_articles (title) VALUES (?)", "hello raw").Error)
+	res, err := indexer.Reindex(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Indexed["article"])
+	assert.Equal(t, res, hooked)
+	assert.Len(t, search("hello"), 2)
+
+	_, err = indexer.Reindex(ctx, "missing")
+	assert.NoError(t, err)
+}
+
+func TestIndexerRegisterValidation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:search_indexer_validation?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	indexer := NewIndexer(newTestEngine(t), IndexerOptions{})
+	assert.Error(t, indexer.Register(db, IndexedModel{Model: &indexedArticle{}}))
+
+	other, err := gorm.Open(sqlite.Open("file:search_indexer_other?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, indexer.Register(db, IndexedModel{Model: &indexedArticle{}, Type: "article", Fields: articleFields}))
+	assert.Error(t, indexer.Register(other, IndexedModel{Model: &indexedArticle{}, Type: "article", Fields: articleFields}))
+	indexer.Close()
+}
@@ -0,0 +1,237 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// ErrCompactUnsupported 当前索引类型不支持合并
+var ErrCompactUnsupported = errors.New("index compaction not supported by this index type")
+
+// 快照类型
+const (
+	SnapshotKindIndex  = "index"
+	SnapshotKindBackup = "backup"
+)
+
+// Snapshot 磁盘上的索引或备份快照
+type Snapshot struct {
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"modTime"`
+	AgeSeconds int64     `json:"ageSeconds"`
+}
+
+// CompactResult 索引合并结果
+type CompactResult struct {
+	SizeBefore int64         `json:"sizeBefore"`
+	SizeAfter  int64         `json:"sizeAfter"`
+	Reclaimed  int64         `json:"reclaimed"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// IndexStats 索引统计
+type IndexStats struct {
+	Name     string `json:"name"`
+	Driver   string `json:"driver"`
+	DocCount uint64 `json:"docCount"`
+	DiskSize int64  `json:"diskSize"`
+}
+
+// Compact 将索引段强制合并为单个段，回收已删除文档占用的磁盘空间
+func (e *bleveEngine) Compact(ctx context.Context) (CompactResult, error) {
+	if err := e.guard(); err != nil {
+		return CompactResult{}, err
+	}
+	adv, err := e.index.Advanced()
+	if err != nil {
+		return CompactResult{}, err
+	}
+	s, ok := adv.(*scorch.Scorch)
+	if !ok {
+		return CompactResult{}, ErrCompactUnsupported
+	}
+
+	start := time.Now()
+	before, _ := dirSize(e.cfg.IndexPath)
+	if err := s.ForceMerge(ctx, nil); err != nil {
+		return CompactResult{}, err
+	}
+	after, _ := dirSize(e.cfg.IndexPath)
+	return CompactResult{
+		SizeBefore: before,
+		SizeAfter:  after,
+		Reclaimed:  before - after,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// Snapshots 返回当前索引目录的快照信息
+func (e *bleveEngine) Snapshots() ([]Snapshot, error) {
+	if err := e.guard(); err != nil {
+		return nil, err
+	}
+	snap, err := newSnapshot(e.cfg.IndexPath, SnapshotKindIndex)
+	if err != nil {
+		return nil, err
+	}
+	return []Snapshot{snap}, nil
+}
+
+// IndexStats 返回文档数与索引目录大小
+func (e *bleveEngine) IndexStats(ctx context.Context) (IndexStats, error) {
+	if err := e.guard(); err != nil {
+		return IndexStats{}, err
+	}
+	count, err := e.index.DocCount()
+	if err != nil {
+		return IndexStats{}, err
+	}
+	size, _ := dirSize(e.cfg.IndexPath)
+	return IndexStats{
+		Name:     filepath.Base(e.cfg.IndexPath),
+		Driver:   DriverBleve,
+		DocCount: count,
+		DiskSize: size,
+	}, nil
+}
+
+// Recreate 关闭并删除索引目录后按新映射重建，mapping 为 bleve 映射的 JSON
+func (e *bleveEngine) Recreate(ctx context.Context, data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return ErrClosed
+	}
+	m := e.index.Mapping()
+	if len(data) > 0 {
+		im, err := ParseIndexMapping(data)
+		if err != nil {
+			return err
+		}
+		m = im
+	}
+	// 先校验映射，避免删除后无法重建
+	if err := m.Validate(); err != nil {
+		return fmt.Errorf("invalid index mapping: %w", err)
+	}
+	if err := e.index.Close(); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(e.cfg.IndexPath); err != nil {
+		return err
+	}
+	idx, err := bleve.New(e.cfg.IndexPath, m)
+	if err != nil {
+		// 索引已删除且无法重建，后续调用直接返回 ErrClosed
+		e.closed = true
+		return err
+	}
+	e.index = idx
+	return nil
+}
+
+// ParseIndexMapping 解析并校验 bleve 映射的 JSON
+func ParseIndexMapping(data []byte) (*mapping.IndexMappingImpl, error) {
+	im := mapping.NewIndexMapping()
+	if err := json.Unmarshal(data, im); err != nil {
+		return nil, fmt.Errorf("invalid index mapping: %w", err)
+	}
+	if err := im.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid index mapping: %w", err)
+	}
+	return im, nil
+}
+
+// DropIndex 关闭并删除索引目录
+func (e *bleveEngine) DropIndex(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.closed {
+		e.closed = true
+		if err := e.index.Close(); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(e.cfg.IndexPath)
+}
+
+// ListBackupSnapshots 列出备份目录下的快照，目录不存在时返回空
+func ListBackupSnapshots(dir string) ([]Snapshot, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	snaps := make([]Snapshot, 0, len(entries))
+	for _, ent := range entries {
+		snap, err := newSnapshot(filepath.Join(dir, ent.Name()), SnapshotKindBackup)
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].ModTime.After(snaps[j].ModTime) })
+	return snaps, nil
+}
+
+// newSnapshot 统计文件或目录的大小与修改时间
+func newSnapshot(path, kind string) (Snapshot, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	size := info.Size()
+	if info.IsDir() {
+		if size, err = dirSize(path); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	return Snapshot{
+		Name:       info.Name(),
+		Kind:       kind,
+		Path:       path,
+		Size:       size,
+		ModTime:    info.ModTime(),
+		AgeSeconds: int64(time.Since(info.ModTime()).Seconds()),
+	}, nil
+}
+
+// dirSize 递归统计目录大小
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// 合并过程中段文件可能被删除
+			return nil
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,82 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactAndSnapshots(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, e.Index(ctx, Doc{ID: fmt.Sprint(i), Fields: map[string]any{"title": "doc"}}))
+	}
+	require.NoError(t, e.Delete(ctx, "0"))
+
+	res, err := e.Compact(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, res.SizeBefore, int64(0))
+
+	snaps, err := e.Snapshots()
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	assert.Equal(t, SnapshotKindIndex, snaps[0].Kind)
+	assert.Greater(t, snaps[0].Size, int64(0))
+}
+
+func TestIndexStatsAndRecreate(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, e.Index(ctx, Doc{ID: fmt.Sprint(i), Fields: map[string]any{"title": "doc"}}))
+	}
+	stats, err := e.IndexStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.DocCount)
+	assert.Equal(t, DriverBleve, stats.Driver)
+	assert.Greater(t, stats.DiskSize, int64(0))
+
+	// 非法映射不删除现有索引
+	require.Error(t, e.Recreate(ctx, []byte(`{"types":`)))
+	stats, err = e.IndexStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.DocCount)
+
+	require.NoError(t, e.Recreate(ctx, nil))
+	stats, err = e.IndexStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), stats.DocCount)
+
+	// 默认映射分词后可按单词命中，keyword 分析器下整段作为一个词项
+	require.NoError(t, e.Index(ctx, Doc{ID: "1", Type: "article", Fields: map[string]any{"title": "Hello World"}}))
+	res, err := e.Search(ctx, SearchRequest{Keyword: "hello", Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), res.Total)
+
+	require.NoError(t, e.Recreate(ctx, []byte(`{"default_analyzer":"keyword"}`)))
+	require.NoError(t, e.Index(ctx, Doc{ID: "1", Fields: map[string]any{"title": "Hello World"}}))
+	res, err = e.Search(ctx, SearchRequest{Keyword: "hello", Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), res.Total)
+}
+
+func TestListBackupSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sys_backup_1.db"), []byte("abc"), 0o644))
+
+	snaps, err := ListBackupSnapshots(dir)
+	require.NoError(t, err)
+	require.Len(t, snaps, 1)
+	assert.Equal(t, SnapshotKindBackup, snaps[0].Kind)
+	assert.Equal(t, int64(3), snaps[0].Size)
+
+	snaps, err = ListBackupSnapshots(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, snaps)
+}
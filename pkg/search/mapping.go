@@ -1,12 +1,15 @@
 package search
 
 import (
+	"strings"
+
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
 	"github.com/blevesearch/bleve/v2/mapping"
 )
 
-func BuildIndexMapping(defaultAnalyzer string) *mapping.IndexMappingImpl {
+// BuildIndexMapping 构建索引映射，unstored 为不存储原文的字段，格式为 类型.字段，如 message.text
+func BuildIndexMapping(defaultAnalyzer string, unstored ...string) *mapping.IndexMappingImpl {
 	if defaultAnalyzer == "" {
 		defaultAnalyzer = standard.Name
 	}
@@ -35,6 +38,11 @@ func BuildIndexMapping(defaultAnalyzer string) *mapping.IndexMappingImpl {
 	dt := mapping.NewDateTimeFieldMapping()
 	dt.Store = true
 	dt.Index = true
+	// 地理坐标，取值为 {"lat": .., "lon": ..}、[lon, lat] 或 "lat,lon"
+	geo := mapping.NewGeoPointFieldMapping()
+	geo.Store = true
+	geo.Index = true
+	geo.DocValues = true
 
 	article := mapping.NewDocumentMapping()
 	article.Dynamic = false
@@ -42,12 +50,80 @@ func BuildIndexMapping(defaultAnalyzer string) *mapping.IndexMappingImpl {
 	article.AddFieldMappingsAt("body", text)
 	article.AddFieldMappingsAt("tags", kw)
 	article.AddFieldMappingsAt("author", kw)
+	article.AddFieldMappingsAt(DefaultACLField, kw)
 	article.AddFieldMappingsAt("createdAt", dt)
 	article.AddFieldMappingsAt("views", num)
+	article.AddFieldMappingsAt("location", geo)
+	article.AddFieldMappingsAt(FieldLang, kw)
+	article.AddFieldMappingsAt(FieldWordCount, num)
+	article.AddFieldMappingsAt(FieldReadingTime, num)
 	idx.AddDocumentMapping("article", article)
 
+	// 由 Indexer 从数据库模型同步的文档
+	group := mapping.NewDocumentMapping()
+	group.Dynamic = false
+	group.AddFieldMappingsAt("name", text)
+	group.AddFieldMappingsAt("groupType", kw)
+	group.AddFieldMappingsAt("createdAt", dt)
+	group.AddFieldMappingsAt("type", kw)
+	group.AddFieldMappingsAt("groupId", kw)
+	group.AddFieldMappingsAt(FieldSuggest, kw)
+	idx.AddDocumentMapping("group", group)
+
+	user := mapping.NewDocumentMapping()
+	user.Dynamic = false
+	user.AddFieldMappingsAt("type", kw)
+	user.AddFieldMappingsAt("name", text)
+	user.AddFieldMappingsAt("avatar", kw)
+	user.AddFieldMappingsAt("userId", kw)
+	user.AddFieldMappingsAt(FieldSuggest, kw)
+	idx.AddDocumentMapping("user", user)
+
+	questionnaire := mapping.NewDocumentMapping()
+	questionnaire.Dynamic = false
+	questionnaire.AddFieldMappingsAt("title", text)
+	questionnaire.AddFieldMappingsAt("description", text)
+	questionnaire.AddFieldMappingsAt("createdAt", dt)
+	questionnaire.AddFieldMappingsAt(FieldLang, kw)
+	idx.AddDocumentMapping("questionnaire", questionnaire)
+
+	// WebSocket 聊天消息，按会话过滤
+	message := mapping.NewDocumentMapping()
+	message.Dynamic = false
+	message.AddFieldMappingsAt("type", kw)
+	message.AddFieldMappingsAt("conversation", kw)
+	message.AddFieldMappingsAt("sender", kw)
+	message.AddFieldMappingsAt("text", text)
+	message.AddFieldMappingsAt("messageId", num)
+	message.AddFieldMappingsAt("createdAt", dt)
+	message.AddFieldMappingsAt("location", geo)
+	message.AddFieldMappingsAt(FieldLang, kw)
+	idx.AddDocumentMapping("message", message)
+
 	def := mapping.NewDocumentMapping()
 	def.Dynamic = false
 	idx.DefaultMapping = def
+
+	for _, name := range unstored {
+		docType, field, ok := strings.Cut(strings.TrimSpace(name), ".")
+		if dm := idx.TypeMapping[docType]; ok && dm != nil {
+			unstoreField(dm, field)
+		}
+	}
 	return idx
 }
+
+// unstoreField 字段只建索引不存储原文，命中不再带该字段与片段，需由 SnippetEngine 回源补齐
+func unstoreField(dm *mapping.DocumentMapping, field string) {
+	prop := dm.Properties[field]
+	if prop == nil {
+		return
+	}
+	fields := make([]*mapping.FieldMapping, len(prop.Fields))
+	for i, f := range prop.Fields {
+		cp := *f
+		cp.Store = false
+		fields[i] = &cp
+	}
+	prop.Fields = fields
+}
@@ -0,0 +1,839 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMigrationInProgress = errors.New("index migration in progress")
+	ErrNoMigration         = errors.New("no index migration in progress")
+	ErrMigrationNotReady   = errors.New("index migration is not ready")
+)
+
+// 迁移阶段，同时作为 SearchIndexVersion.Status
+const (
+	MigrationBackfilling = "backfilling"
+	MigrationVerifying   = "verifying"
+	// 校验通过，可以切换
+	MigrationReady = "ready"
+	// 校验未通过，可重新校验、强制切换或放弃
+	MigrationMismatch = "mismatch"
+	MigrationFailed   = "failed"
+	MigrationAborted  = "aborted"
+	MigrationSwitched = "switched"
+)
+
+// 已切换的索引版本状态
+const (
+	IndexVersionActive  = "active"
+	IndexVersionRetired = "retired"
+)
+
+// 迁移校验默认抽样数
+const defaultMigrationSample = 100
+
+// SearchIndexVersion 映射迁移创建的索引版本，状态为 active 的版本在启动时替代配置中的索引
+type SearchIndexVersion struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// bleve 索引路径或 Elasticsearch 索引名
+	Location string `json:"location" gorm:"size:255"`
+	Status   string `json:"status" gorm:"size:16;index"`
+	// 创建索引时使用的映射，为空表示使用内置映射
+	Mapping     string     `json:"mapping,omitempty" gorm:"type:text"`
+	DocCount    uint64     `json:"docCount"`
+	Error       string     `json:"error,omitempty" gorm:"size:1024"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	ActivatedAt *time.Time `json:"activatedAt,omitempty"`
+}
+
+// ActiveIndexLocation 最近一次迁移切换后的索引位置，未迁移过时返回空字符串
+func ActiveIndexLocation(ctx context.Context, db *gorm.DB) (string, error) {
+	var versions []SearchIndexVersion
+	err := db.WithContext(ctx).Where("status = ?", IndexVersionActive).Order("id DESC").Limit(1).Find(&versions).Error
+	if err != nil || len(versions) == 0 {
+		return "", err
+	}
+	return versions[0].Location, nil
+}
+
+// MigrationSource 迁移时向新索引回填文档的数据源
+type MigrationSource interface {
+	// Count 各文档类型需要处理的记录数，用于计算进度
+	Count(ctx context.Context) (map[string]int64, error)
+	// Backfill 将全部文档写入 target，每处理一批记录调用 progress
+	Backfill(ctx context.Context, target Engine, progress func(docType string, n int)) error
+}
+
+// DocSampler 迁移校验时抽样比对文档，引擎未实现时只比对文档数
+type DocSampler interface {
+	// SampleDocs 随机返回最多 n 个文档及其存储字段
+	SampleDocs(ctx context.Context, n int) ([]Hit, error)
+	// GetDocs 按ID读取文档存储字段，不存在的ID不返回
+	GetDocs(ctx context.Context, ids []string) (map[string]map[string]any, error)
+}
+
+// MigrationOptions 映射迁移配置
+type MigrationOptions struct {
+	// Open 在 location 按 mapping 创建新索引，mapping 为空时使用内置映射
+	Open func(ctx context.Context, location string, mapping []byte) (Engine, error)
+	// Location 新版本索引的位置，需与当前索引不同
+	Location func(version uint) string
+	// 校验时抽样比对的文档数，默认 100
+	SampleSize int
+	// 比对时忽略的字段，用于写入时生成、回填时无法还原的字段
+	IgnoreFields []string
+	// 回填文档写入新索引前的处理链，需与实时写入使用的处理链一致
+	Enrich *EnrichPipeline
+}
+
+// ParityMismatch 抽样比对不一致的文档
+type ParityMismatch struct {
+	ID     string   `json:"id"`
+	Reason string   `json:"reason"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// MigrationVerification 新旧索引的文档数与抽样比对结果
+type MigrationVerification struct {
+	ActiveCount uint64           `json:"activeCount"`
+	TargetCount uint64           `json:"targetCount"`
+	CountMatch  bool             `json:"countMatch"`
+	Sampled     int              `json:"sampled"`
+	Mismatches  []ParityMismatch `json:"mismatches,omitempty"`
+	OK          bool             `json:"ok"`
+	VerifiedAt  time.Time        `json:"verifiedAt"`
+}
+
+// MigrationProgress 当前或最近一次迁移的进度
+type MigrationProgress struct {
+	Version  uint   `json:"version"`
+	Location string `json:"location"`
+	Phase    string `json:"phase"`
+	// 各文档类型已处理与需要处理的记录数
+	Processed map[string]int   `json:"processed"`
+	Total     map[string]int64 `json:"total,omitempty"`
+	// 迁移期间同时写入新索引的文档数与失败数
+	DualWrites      int64                  `json:"dualWrites"`
+	DualWriteErrors int64                  `json:"dualWriteErrors"`
+	Verification    *MigrationVerification `json:"verification,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+	StartedAt       time.Time              `json:"startedAt"`
+	UpdatedAt       time.Time              `json:"updatedAt"`
+	FinishedAt      *time.Time             `json:"finishedAt,omitempty"`
+}
+
+// Migrator 不停机的映射迁移。作为 Engine 包装当前索引：迁移开始后在后台按新映射创建索引并从数据源回填，
+// 期间写入同时发往新旧索引（双写），回填完成后补写回填期间的变更，再比对文档数与抽样文档；
+// 校验通过后由管理员切换，切换时原子替换读写的索引并记录版本，重启后继续使用新索引。
+// 旧索引关闭后保留，确认其他实例均已重启后可手动删除
+type Migrator struct {
+	db      *gorm.DB
+	opts    MigrationOptions
+	sources []MigrationSource
+
+	// mu 保护 active 与 target：读写引擎时持有读锁，切换与放弃时持有写锁，保证不会访问已关闭的索引
+	mu       sync.RWMutex
+	active   Engine
+	location string
+	target   Engine
+
+	// wmu 写入时持有读锁，补写回填期间的变更时持有写锁，避免补写覆盖更新的双写
+	wmu sync.RWMutex
+	// pending 回填期间双写的最新文档，nil 表示删除；仅在回填期间不为 nil
+	pmu     sync.Mutex
+	pending map[string]*Doc
+
+	// smu 保护迁移状态
+	smu      sync.Mutex
+	progress MigrationProgress
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	dualWrites      atomic.Int64
+	dualWriteErrors atomic.Int64
+}
+
+// NewMigrator 包装位于 location 的当前索引
+func NewMigrator(active Engine, db *gorm.DB, location string, opts MigrationOptions) *Migrator {
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = defaultMigrationSample
+	}
+	return &Migrator{db: db, opts: opts, active: active, location: location}
+}
+
+// AddSource 添加回填数据源，需在开始迁移前调用
+func (m *Migrator) AddSource(src MigrationSource) {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	m.sources = append(m.sources, src)
+}
+
+// Location 当前索引位置
+func (m *Migrator) Location() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.location
+}
+
+// Progress 当前或最近一次迁移的进度，未迁移过时 Phase 为空
+func (m *Migrator) Progress() MigrationProgress {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	return m.snapshot()
+}
+
+// snapshot 复制进度，调用方持有 smu
+func (m *Migrator) snapshot() MigrationProgress {
+	p := m.progress
+	p.Processed = maps.Clone(p.Processed)
+	p.Total = maps.Clone(p.Total)
+	p.DualWrites = m.dualWrites.Load()
+	p.DualWriteErrors = m.dualWriteErrors.Load()
+	if p.Verification != nil {
+		v := *p.Verification
+		p.Verification = &v
+	}
+	return p
+}
+
+// Versions 最近的索引版本
+func (m *Migrator) Versions(ctx context.Context, limit int) ([]SearchIndexVersion, error) {
+	var versions []SearchIndexVersion
+	err := m.db.WithContext(ctx).Omit("mapping").Order("id DESC").Limit(limit).Find(&versions).Error
+	return versions, err
+}
+
+// Start 按 mapping 创建新索引并在后台开始回填，mapping 为空时使用内置映射
+func (m *Migrator) Start(ctx context.Context, mapping []byte) (MigrationProgress, error) {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	if m.migrating() {
+		return m.snapshot(), ErrMigrationInProgress
+	}
+	m.cleanup(ctx)
+
+	row := SearchIndexVersion{Status: MigrationBackfilling, Mapping: string(mapping)}
+	if err := m.db.WithContext(ctx).Create(&row).Error; err != nil {
+		return MigrationProgress{}, err
+	}
+	row.Location = m.opts.Location(row.ID)
+	if row.Location == m.Location() {
+		m.updateVersion(row.ID, map[string]any{"status": MigrationFailed, "error": "location in use"})
+		return MigrationProgress{}, fmt.Errorf("index location %s is in use", row.Location)
+	}
+	if err := m.db.WithContext(ctx).Model(&row).Update("location", row.Location).Error; err != nil {
+		return MigrationProgress{}, err
+	}
+	target, err := m.opts.Open(ctx, row.Location, mapping)
+	if err != nil {
+		m.updateVersion(row.ID, map[string]any{"status": MigrationFailed, "error": truncateError(err)})
+		return MigrationProgress{}, fmt.Errorf("open index %s: %w", row.Location, err)
+	}
+
+	total := make(map[string]int64)
+	for _, src := range m.sources {
+		counts, err := src.Count(ctx)
+		if err != nil {
+			log.Printf("search migration: count documents failed: %v", err)
+			continue
+		}
+		for t, n := range counts {
+			total[t] += n
+		}
+	}
+
+	m.pmu.Lock()
+	m.pending = make(map[string]*Doc)
+	m.pmu.Unlock()
+	m.mu.Lock()
+	m.target = target
+	m.mu.Unlock()
+	m.dualWrites.Store(0)
+	m.dualWriteErrors.Store(0)
+
+	now := time.Now()
+	m.progress = MigrationProgress{
+		Version:   row.ID,
+		Location:  row.Location,
+		Phase:     MigrationBackfilling,
+		Processed: make(map[string]int),
+		Total:     total,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	go m.run(runCtx, target, slices.Clone(m.sources), m.done)
+	log.Printf("search migration %d started: building %s", row.ID, row.Location)
+	return m.snapshot(), nil
+}
+
+// migrating 是否有未结束的迁移，调用方持有 smu
+func (m *Migrator) migrating() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.target != nil
+}
+
+// cleanup 删除上次进程退出时未完成的迁移留下的索引，调用方持有 smu
+func (m *Migrator) cleanup(ctx context.Context) {
+	var stale []SearchIndexVersion
+	if err := m.db.WithContext(ctx).Omit("mapping").
+		Where("status IN ?", []string{MigrationBackfilling, MigrationVerifying, MigrationReady, MigrationMismatch}).
+		Find(&stale).Error; err != nil {
+		log.Printf("search migration: load unfinished migrations failed: %v", err)
+		return
+	}
+	for _, v := range stale {
+		if v.Location != "" && v.Location != m.Location() {
+			if e, err := m.opts.Open(ctx, v.Location, nil); err == nil {
+				dropIndex(ctx, e)
+			}
+		}
+		m.updateVersion(v.ID, map[string]any{"status": MigrationAborted, "error": "interrupted"})
+	}
+}
+
+// run 回填、补写并校验
+func (m *Migrator) run(ctx context.Context, target Engine, sources []MigrationSource, done chan struct{}) {
+	defer close(done)
+	// 双写的文档已经过处理，只有回填需要处理
+	backfill := target
+	if m.opts.Enrich != nil {
+		backfill = NewEnrichedEngine(target, m.opts.Enrich)
+	}
+	for _, src := range sources {
+		if err := src.Backfill(ctx, backfill, m.addProgress); err != nil {
+			m.fail(ctx, err)
+			return
+		}
+	}
+	m.catchUp(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	m.setPhase(MigrationVerifying)
+	v, err := m.verify(ctx)
+	if err != nil {
+		m.fail(ctx, fmt.Errorf("verify: %w", err))
+		return
+	}
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	m.applyVerification(v)
+	log.Printf("search migration %d backfilled: %v, verification ok=%t", m.progress.Version, m.progress.Processed, v.OK)
+}
+
+// addProgress 累加已处理的记录数
+func (m *Migrator) addProgress(docType string, n int) {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	m.progress.Processed[docType] += n
+	m.progress.UpdatedAt = time.Now()
+}
+
+// setPhase 更新迁移阶段
+func (m *Migrator) setPhase(phase string) {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	m.progress.Phase = phase
+	m.progress.UpdatedAt = time.Now()
+	m.updateVersion(m.progress.Version, map[string]any{"status": phase})
+}
+
+// applyVerification 记录校验结果并更新阶段，调用方持有 smu
+func (m *Migrator) applyVerification(v *MigrationVerification) {
+	phase := MigrationMismatch
+	if v.OK {
+		phase = MigrationReady
+	}
+	m.progress.Verification = v
+	m.progress.Phase = phase
+	m.progress.UpdatedAt = time.Now()
+	m.updateVersion(m.progress.Version, map[string]any{"status": phase, "doc_count": v.TargetCount})
+}
+
+// fail 回填或校验失败时停止双写并删除新索引；因放弃迁移而取消时由 Abort 处理
+func (m *Migrator) fail(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	m.detachTarget(ctx)
+	now := time.Now()
+	m.progress.Phase = MigrationFailed
+	m.progress.Error = err.Error()
+	m.progress.UpdatedAt = now
+	m.progress.FinishedAt = &now
+	m.updateVersion(m.progress.Version, map[string]any{"status": MigrationFailed, "error": truncateError(err)})
+	log.Printf("search migration %d failed: %v", m.progress.Version, err)
+}
+
+// detachTarget 停止双写并删除新索引，调用方持有 smu
+func (m *Migrator) detachTarget(ctx context.Context) {
+	m.pmu.Lock()
+	m.pending = nil
+	m.pmu.Unlock()
+	m.mu.Lock()
+	target := m.target
+	m.target = nil
+	m.mu.Unlock()
+	if target != nil {
+		dropIndex(ctx, target)
+	}
+}
+
+// catchUp 补写回填期间的变更：回填读取的数据可能早于同时发生的双写，按双写的最新结果覆盖
+func (m *Migrator) catchUp(ctx context.Context) {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+	m.pmu.Lock()
+	pending := m.pending
+	m.pending = nil
+	m.pmu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.target == nil {
+		return
+	}
+	// 补写是双写的重放，只统计失败
+	var docs []Doc
+	for id, doc := range pending {
+		if doc != nil {
+			docs = append(docs, *doc)
+			continue
+		}
+		if err := m.target.Delete(ctx, id); err != nil {
+			m.dualWrite(err)
+		}
+	}
+	if len(docs) > 0 {
+		if err := m.target.IndexBatch(ctx, docs); err != nil {
+			m.dualWrite(err)
+		}
+	}
+}
+
+// Verify 重新校验新索引，回填完成后可用
+func (m *Migrator) Verify(ctx context.Context) (MigrationProgress, error) {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	if !m.migrating() {
+		return m.snapshot(), ErrNoMigration
+	}
+	if p := m.progress.Phase; p != MigrationReady && p != MigrationMismatch {
+		return m.snapshot(), ErrMigrationNotReady
+	}
+	v, err := m.verify(ctx)
+	if err != nil {
+		return m.snapshot(), err
+	}
+	m.applyVerification(v)
+	return m.snapshot(), nil
+}
+
+// verify 比对新旧索引的文档数，并抽样比对文档字段
+func (m *Migrator) verify(ctx context.Context) (*MigrationVerification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.target == nil {
+		return nil, ErrNoMigration
+	}
+	activeStats, err := m.active.IndexStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetStats, err := m.target.IndexStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := &MigrationVerification{
+		ActiveCount: activeStats.DocCount,
+		TargetCount: targetStats.DocCount,
+		CountMatch:  activeStats.DocCount == targetStats.DocCount,
+		VerifiedAt:  time.Now(),
+	}
+	as, ok1 := m.active.(DocSampler)
+	ts, ok2 := m.target.(DocSampler)
+	if ok1 && ok2 {
+		hits, err := as.SampleDocs(ctx, m.opts.SampleSize)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, 0, len(hits))
+		for _, h := range hits {
+			ids = append(ids, h.ID)
+		}
+		docs, err := ts.GetDocs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		v.Sampled = len(hits)
+		for _, h := range hits {
+			doc, ok := docs[h.ID]
+			if !ok {
+				v.Mismatches = append(v.Mismatches, ParityMismatch{ID: h.ID, Reason: "missing"})
+				continue
+			}
+			if diff := diffFields(h.Fields, doc, m.opts.IgnoreFields); len(diff) > 0 {
+				v.Mismatches = append(v.Mismatches, ParityMismatch{ID: h.ID, Reason: "fields differ", Fields: diff})
+			}
+		}
+	}
+	v.OK = v.CountMatch && len(v.Mismatches) == 0
+	return v, nil
+}
+
+// diffFields 不一致的字段名，值按 JSON 归一后比较
+func diffFields(a, b map[string]any, ignore []string) []string {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	var diff []string
+	for k := range keys {
+		if slices.Contains(ignore, k) {
+			continue
+		}
+		if !reflect.DeepEqual(normalizeValue(a[k]), normalizeValue(b[k])) {
+			diff = append(diff, k)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func normalizeValue(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// Switch 将读写切换到新索引并记录为当前版本；校验未通过时需 force
+func (m *Migrator) Switch(ctx context.Context, force bool) (MigrationProgress, error) {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	if !m.migrating() {
+		return m.snapshot(), ErrNoMigration
+	}
+	switch m.progress.Phase {
+	case MigrationReady:
+	case MigrationMismatch:
+		if !force {
+			return m.snapshot(), fmt.Errorf("%w: verification failed, switch with force to override", ErrMigrationNotReady)
+		}
+	default:
+		return m.snapshot(), ErrMigrationNotReady
+	}
+
+	now := time.Now()
+	version := m.progress.Version
+	var docCount uint64
+	if v := m.progress.Verification; v != nil {
+		docCount = v.TargetCount
+	}
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&SearchIndexVersion{}).Where("status = ?", IndexVersionActive).
+			Update("status", IndexVersionRetired).Error; err != nil {
+			return err
+		}
+		return tx.Model(&SearchIndexVersion{ID: version}).Updates(map[string]any{
+			"status":       IndexVersionActive,
+			"doc_count":    docCount,
+			"activated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return m.snapshot(), err
+	}
+
+	m.mu.Lock()
+	old, oldLocation := m.active, m.location
+	m.active, m.location = m.target, m.progress.Location
+	m.target = nil
+	m.mu.Unlock()
+	if err := old.Close(); err != nil {
+		log.Printf("search migration: close retired index %s failed: %v", oldLocation, err)
+	}
+
+	m.progress.Phase = MigrationSwitched
+	m.progress.UpdatedAt = now
+	m.progress.FinishedAt = &now
+	log.Printf("search migration %d switched reads and writes from %s to %s", version, oldLocation, m.progress.Location)
+	return m.snapshot(), nil
+}
+
+// Abort 放弃迁移，停止回填与双写并删除新索引
+func (m *Migrator) Abort(ctx context.Context) (MigrationProgress, error) {
+	m.smu.Lock()
+	if !m.migrating() {
+		defer m.smu.Unlock()
+		return m.snapshot(), ErrNoMigration
+	}
+	cancel, done := m.cancel, m.done
+	m.smu.Unlock()
+	// 等待后台任务退出，期间不持有 smu，后台任务更新进度时需要
+	cancel()
+	<-done
+
+	m.smu.Lock()
+	defer m.smu.Unlock()
+	if !m.migrating() {
+		return m.snapshot(), ErrNoMigration
+	}
+	m.detachTarget(ctx)
+	now := time.Now()
+	m.progress.Phase = MigrationAborted
+	m.progress.UpdatedAt = now
+	m.progress.FinishedAt = &now
+	m.updateVersion(m.progress.Version, map[string]any{"status": MigrationAborted})
+	log.Printf("search migration %d aborted", m.progress.Version)
+	return m.snapshot(), nil
+}
+
+// updateVersion 更新版本记录，失败时只记录日志
+func (m *Migrator) updateVersion(id uint, values map[string]any) {
+	if err := m.db.Model(&SearchIndexVersion{ID: id}).Updates(values).Error; err != nil {
+		log.Printf("search migration: update version %d failed: %v", id, err)
+	}
+}
+
+func truncateError(err error) string {
+	s := err.Error()
+	if len(s) > 1024 {
+		s = s[:1024]
+	}
+	return s
+}
+
+// IndexDropper 可删除自身存储的引擎，放弃迁移时删除新索引
+type IndexDropper interface {
+	DropIndex(ctx context.Context) error
+}
+
+// dropIndex 删除引擎的索引，不支持时只关闭
+func dropIndex(ctx context.Context, e Engine) {
+	if d, ok := e.(IndexDropper); ok {
+		if err := d.DropIndex(ctx); err != nil {
+			log.Printf("search migration: drop index failed: %v", err)
+		}
+		return
+	}
+	_ = e.Close()
+}
+
+// record 回填期间记录双写的最新结果，doc 为 nil 表示删除
+func (m *Migrator) record(id string, doc *Doc) {
+	m.pmu.Lock()
+	defer m.pmu.Unlock()
+	if m.pending != nil {
+		m.pending[id] = doc
+	}
+}
+
+// dualWrite 统计双写结果，新索引写入失败不影响请求，由校验发现
+func (m *Migrator) dualWrite(err error) {
+	if err != nil {
+		if n := m.dualWriteErrors.Add(1); n == 1 || n%1000 == 0 {
+			log.Printf("search migration: write to new index failed (%d errors): %v", n, err)
+		}
+		return
+	}
+	m.dualWrites.Add(1)
+}
+
+func (m *Migrator) Index(ctx context.Context, doc Doc) error {
+	m.wmu.RLock()
+	defer m.wmu.RUnlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.active.Index(ctx, doc); err != nil {
+		return err
+	}
+	if m.target != nil {
+		m.record(doc.ID, &doc)
+		m.dualWrite(m.target.Index(ctx, doc))
+	}
+	return nil
+}
+
+func (m *Migrator) IndexBatch(ctx context.Context, docs []Doc) error {
+	m.wmu.RLock()
+	defer m.wmu.RUnlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.active.IndexBatch(ctx, docs); err != nil {
+		return err
+	}
+	if m.target != nil {
+		for i := range docs {
+			m.record(docs[i].ID, &docs[i])
+		}
+		m.dualWrite(m.target.IndexBatch(ctx, docs))
+	}
+	return nil
+}
+
+func (m *Migrator) Delete(ctx context.Context, id string) error {
+	m.wmu.RLock()
+	defer m.wmu.RUnlock()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err := m.active.Delete(ctx, id); err != nil {
+		return err
+	}
+	if m.target != nil {
+		m.record(id, nil)
+		m.dualWrite(m.target.Delete(ctx, id))
+	}
+	return nil
+}
+
+func (m *Migrator) Search(ctx context.Context, req SearchRequest) (SearchResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.Search(ctx, req)
+}
+
+func (m *Migrator) Scroll(ctx context.Context, req SearchRequest, cursor string) (ScrollResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.Scroll(ctx, req, cursor)
+}
+
+func (m *Migrator) GetAutoCompleteSuggestions(ctx context.Context, keyword string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.GetAutoCompleteSuggestions(ctx, keyword)
+}
+
+func (m *Migrator) GetSearchSuggestions(ctx context.Context, keyword string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.GetSearchSuggestions(ctx, keyword)
+}
+
+func (m *Migrator) Compact(ctx context.Context) (CompactResult, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.Compact(ctx)
+}
+
+func (m *Migrator) Snapshots() ([]Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.Snapshots()
+}
+
+func (m *Migrator) IndexStats(ctx context.Context) (IndexStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.active.IndexStats(ctx)
+}
+
+// Recreate 重建当前索引，迁移期间不可用
+func (m *Migrator) Recreate(ctx context.Context, mapping []byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.target != nil {
+		return ErrMigrationInProgress
+	}
+	return m.active.Recreate(ctx, mapping)
+}
+
+// Close 停止未完成的迁移并关闭索引，未完成的新索引在下次开始迁移时删除
+func (m *Migrator) Close() error {
+	m.smu.Lock()
+	cancel, done := m.cancel, m.done
+	m.smu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.target != nil {
+		_ = m.target.Close()
+		m.target = nil
+	}
+	return m.active.Close()
+}
+
+// SampleDocs 从随机位置读取连续的 n 个文档
+func (e *bleveEngine) SampleDocs(ctx context.Context, n int) ([]Hit, error) {
+	if err := e.guard(); err != nil {
+		return nil, err
+	}
+	count, err := e.index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	from := 0
+	if int(count) > n {
+		from = rand.IntN(int(count) - n + 1)
+	}
+	sr := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), n, from, false)
+	sr.Fields = []string{"*"}
+	res, err := e.index.SearchInContext(ctx, sr)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]Hit, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		hits = append(hits, Hit{ID: h.ID, Fields: h.Fields})
+	}
+	return hits, nil
+}
+
+// GetDocs 按ID读取文档存储字段
+func (e *bleveEngine) GetDocs(ctx context.Context, ids []string) (map[string]map[string]any, error) {
+	if err := e.guard(); err != nil {
+		return nil, err
+	}
+	out := make(map[string]map[string]any, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	sr := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(ids), len(ids), 0, false)
+	sr.Fields = []string{"*"}
+	res, err := e.index.SearchInContext(ctx, sr)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range res.Hits {
+		out[h.ID] = h.Fields
+	}
+	return out, nil
+}
@@ -0,0 +1,177 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeMigrationSource 回填固定文档，gate 关闭前阻塞，用于在回填期间双写
+type fakeMigrationSource struct {
+	docs []Doc
+	gate chan struct{}
+}
+
+func (s *fakeMigrationSource) Count(ctx context.Context) (map[string]int64, error) {
+	return map[string]int64{"article": int64(len(s.docs))}, nil
+}
+
+func (s *fakeMigrationSource) Backfill(ctx context.Context, target Engine, progress func(docType string, n int)) error {
+	select {
+	case <-s.gate:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := target.IndexBatch(ctx, s.docs); err != nil {
+		return err
+	}
+	progress("article", len(s.docs))
+	return nil
+}
+
+func newTestMigrator(t *testing.T, name string) (*Migrator, *gorm.DB, string) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", name)), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&SearchIndexVersion{}))
+	require.NoError(t, db.Where("1 = 1").Delete(&SearchIndexVersion{}).Error)
+
+	dir := t.TempDir()
+	location := filepath.Join(dir, "search.bleve")
+	active, err := New(Config{IndexPath: location}, BuildIndexMapping(""))
+	require.NoError(t, err)
+	m := NewMigrator(active, db, location, MigrationOptions{
+		Open: func(ctx context.Context, location string, mapping []byte) (Engine, error) {
+			return New(Config{IndexPath: location}, BuildIndexMapping(""))
+		},
+		Location: func(version uint) string {
+			return fmt.Sprintf("%s.v%d", location, version)
+		},
+	})
+	t.Cleanup(func() { _ = m.Close() })
+	return m, db, location
+}
+
+func waitMigration(t *testing.T, m *Migrator, phases ...string) MigrationProgress {
+	t.Helper()
+	var p MigrationProgress
+	require.Eventually(t, func() bool {
+		p = m.Progress()
+		for _, phase := range phases {
+			if p.Phase == phase {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	return p
+}
+
+func articles(ids ...string) []Doc {
+	docs := make([]Doc, 0, len(ids))
+	for _, id := range ids {
+		docs = append(docs, Doc{ID: id, Type: "article", Fields: map[string]any{"type": "article", "title": "article " + id}})
+	}
+	return docs
+}
+
+func TestMigratorSwitch(t *testing.T) {
+	m, db, location := newTestMigrator(t, "search_migration")
+	ctx := context.Background()
+	require.NoError(t, m.IndexBatch(ctx, articles("1", "2", "3")))
+
+	src := &fakeMigrationSource{docs: articles("1", "2", "3"), gate: make(chan struct{})}
+	m.AddSource(src)
+	p, err := m.Start(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationBackfilling, p.Phase)
+	assert.Equal(t, int64(3), p.Total["article"])
+	_, err = m.Start(ctx, nil)
+	assert.ErrorIs(t, err, ErrMigrationInProgress)
+	assert.Error(t, m.Recreate(ctx, nil))
+
+	// 回填期间的写入同时发往新索引，回填覆盖的旧数据在补写时修正
+	require.NoError(t, m.Index(ctx, Doc{ID: "4", Type: "article", Fields: map[string]any{"type": "article", "title": "fresh arrival"}}))
+	require.NoError(t, m.Delete(ctx, "1"))
+	close(src.gate)
+
+	p = waitMigration(t, m, MigrationReady, MigrationMismatch)
+	require.Equal(t, MigrationReady, p.Phase, "%+v", p.Verification)
+	assert.Equal(t, 3, p.Processed["article"])
+	assert.Equal(t, int64(2), p.DualWrites)
+	assert.Equal(t, uint64(3), p.Verification.ActiveCount)
+	assert.Equal(t, uint64(3), p.Verification.TargetCount)
+
+	// 切换前读请求仍由旧索引处理
+	assert.Equal(t, location, m.Location())
+	active, err := ActiveIndexLocation(ctx, db)
+	require.NoError(t, err)
+	assert.Empty(t, active)
+
+	p, err = m.Switch(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationSwitched, p.Phase)
+	assert.Equal(t, fmt.Sprintf("%s.v%d", location, p.Version), p.Location)
+	assert.Equal(t, p.Location, m.Location())
+	active, err = ActiveIndexLocation(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, p.Location, active)
+
+	res, err := m.Search(ctx, SearchRequest{Keyword: "fresh", SearchFields: []string{"title"}})
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 1)
+	assert.Equal(t, "4", res.Hits[0].ID)
+	stats, err := m.IndexStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.DocCount)
+
+	// 旧索引保留，供回滚与尚未重启的实例使用
+	_, err = os.Stat(location)
+	assert.NoError(t, err)
+	_, err = m.Switch(ctx, false)
+	assert.ErrorIs(t, err, ErrNoMigration)
+}
+
+func TestMigratorAbort(t *testing.T) {
+	m, db, location := newTestMigrator(t, "search_migration_abort")
+	ctx := context.Background()
+	require.NoError(t, m.IndexBatch(ctx, articles("1", "2")))
+
+	src := &fakeMigrationSource{docs: articles("1"), gate: make(chan struct{})}
+	m.AddSource(src)
+	_, err := m.Start(ctx, nil)
+	require.NoError(t, err)
+	close(src.gate)
+
+	// 新索引缺少文档，校验不通过时不能直接切换
+	p := waitMigration(t, m, MigrationReady, MigrationMismatch)
+	require.Equal(t, MigrationMismatch, p.Phase)
+	assert.False(t, p.Verification.CountMatch)
+	_, err = m.Switch(ctx, false)
+	assert.ErrorIs(t, err, ErrMigrationNotReady)
+
+	p, err = m.Abort(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationAborted, p.Phase)
+	assert.Equal(t, location, m.Location())
+	_, err = os.Stat(p.Location)
+	assert.True(t, os.IsNotExist(err))
+
+	var v SearchIndexVersion
+	require.NoError(t, db.First(&v, p.Version).Error)
+	assert.Equal(t, MigrationAborted, v.Status)
+
+	// 放弃后写入只进入当前索引
+	require.NoError(t, m.Index(ctx, articles("3")[0]))
+	stats, err := m.IndexStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.DocCount)
+}
@@ -0,0 +1,277 @@
+package search
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 后台任务类型
+const (
+	ProgressReindex = "reindex"
+	ProgressImport  = "import"
+)
+
+// 后台任务状态
+const (
+	ProgressRunning  = "running"
+	ProgressFinished = "finished"
+	ProgressFailed   = "failed"
+)
+
+const (
+	// defaultProgressInterval 运行中进度事件的最小间隔
+	defaultProgressInterval = time.Second
+	// defaultProgressKeep 保留的已结束任务数
+	defaultProgressKeep = 20
+)
+
+// Progress 索引重建或导入任务的进度
+type Progress struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	State string `json:"state"`
+	// 已处理的文档数与失败数，Total 为 0 表示总数未知
+	Processed int64 `json:"processed"`
+	Failed    int64 `json:"failed"`
+	Total     int64 `json:"total,omitempty"`
+	// Percent 完成百分比，总数未知时按已读取的字节数估算
+	Percent float64 `json:"percent"`
+	// Rate 每秒处理的文档数，ETA 预计剩余秒数，无法估算时为 0
+	Rate       float64    `json:"rate"`
+	ETA        int64      `json:"eta"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// ProgressTracker 记录后台任务进度并推送进度事件。
+// 运行中的事件按间隔节流，开始与结束事件总是推送；只保留最近的已结束任务供轮询
+type ProgressTracker struct {
+	interval time.Duration
+	keep     int
+
+	mu       sync.RWMutex
+	tasks    map[string]*ProgressTask
+	seq      uint64
+	onUpdate func(Progress)
+}
+
+// NewProgressTracker 创建进度跟踪器，interval 为运行中事件的最小间隔，keep 为保留的已结束任务数
+func NewProgressTracker(interval time.Duration, keep int) *ProgressTracker {
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	if keep <= 0 {
+		keep = defaultProgressKeep
+	}
+	return &ProgressTracker{interval: interval, keep: keep, tasks: make(map[string]*ProgressTask)}
+}
+
+// OnUpdate 设置进度事件回调，用于推送给管理后台
+func (t *ProgressTracker) OnUpdate(fn func(Progress)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onUpdate = fn
+}
+
+// Start 开始一个任务，total 为需要处理的文档数，未知时传 0
+func (t *ProgressTracker) Start(kind string, total int64) *ProgressTask {
+	now := time.Now()
+	t.mu.Lock()
+	t.seq++
+	task := &ProgressTask{
+		tracker: t,
+		p: Progress{
+			ID:        kind + "-" + strconv.FormatInt(now.UnixNano(), 36) + strconv.FormatUint(t.seq, 36),
+			Kind:      kind,
+			State:     ProgressRunning,
+			Total:     total,
+			StartedAt: now,
+			UpdatedAt: now,
+		},
+		emitted: now,
+	}
+	t.tasks[task.p.ID] = task
+	t.prune()
+	t.mu.Unlock()
+
+	t.emit(task.Snapshot())
+	return task
+}
+
+// Get 按ID获取任务进度
+func (t *ProgressTracker) Get(id string) (Progress, bool) {
+	t.mu.RLock()
+	task, ok := t.tasks[id]
+	t.mu.RUnlock()
+	if !ok {
+		return Progress{}, false
+	}
+	return task.Snapshot(), true
+}
+
+// List 运行中与最近结束的任务，按开始时间倒序
+func (t *ProgressTracker) List() []Progress {
+	t.mu.RLock()
+	out := make([]Progress, 0, len(t.tasks))
+	for _, task := range t.tasks {
+		out = append(out, task.Snapshot())
+	}
+	t.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out
+}
+
+// prune 超出保留数量时删除最早结束的任务，调用方持有 mu
+func (t *ProgressTracker) prune() {
+	var finished []Progress
+	for _, task := range t.tasks {
+		if p := task.Snapshot(); p.FinishedAt != nil {
+			finished = append(finished, p)
+		}
+	}
+	if len(finished) <= t.keep {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].FinishedAt.Before(*finished[j].FinishedAt) })
+	for _, p := range finished[:len(finished)-t.keep] {
+		delete(t.tasks, p.ID)
+	}
+}
+
+func (t *ProgressTracker) emit(p Progress) {
+	t.mu.RLock()
+	fn := t.onUpdate
+	t.mu.RUnlock()
+	if fn != nil {
+		fn(p)
+	}
+}
+
+// ProgressTask 一个运行中的任务
+type ProgressTask struct {
+	tracker *ProgressTracker
+
+	mu         sync.Mutex
+	p          Progress
+	bytesRead  int64
+	bytesTotal int64
+	emitted    time.Time
+}
+
+// ID 任务ID
+func (t *ProgressTask) ID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.p.ID
+}
+
+// Add 累加已处理的文档数与失败数
+func (t *ProgressTask) Add(processed, failed int64) {
+	t.update(func(p *Progress) {
+		p.Processed += processed
+		p.Failed += failed
+	})
+}
+
+// Set 设置已处理的文档数与失败数
+func (t *ProgressTask) Set(processed, failed int64) {
+	t.update(func(p *Progress) {
+		p.Processed = processed
+		p.Failed = failed
+	})
+}
+
+// SetBytes 设置已读取的字节数与总字节数，文档总数未知时用于估算进度
+func (t *ProgressTask) SetBytes(read, total int64) {
+	t.mu.Lock()
+	t.bytesRead, t.bytesTotal = read, total
+	t.mu.Unlock()
+}
+
+// Finish 结束任务，err 不为 nil 时标记为失败
+func (t *ProgressTask) Finish(err error) {
+	t.mu.Lock()
+	now := time.Now()
+	t.p.State = ProgressFinished
+	if err != nil {
+		t.p.State = ProgressFailed
+		t.p.Error = err.Error()
+	}
+	t.p.UpdatedAt = now
+	t.p.FinishedAt = &now
+	p := t.snapshot()
+	t.mu.Unlock()
+
+	t.tracker.emit(p)
+}
+
+// Snapshot 当前进度
+func (t *ProgressTask) Snapshot() Progress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.snapshot()
+}
+
+// update 修改进度，距上次推送超过间隔时推送事件
+func (t *ProgressTask) update(fn func(p *Progress)) {
+	t.mu.Lock()
+	now := time.Now()
+	fn(&t.p)
+	t.p.UpdatedAt = now
+	if now.Sub(t.emitted) < t.tracker.interval {
+		t.mu.Unlock()
+		return
+	}
+	t.emitted = now
+	p := t.snapshot()
+	t.mu.Unlock()
+
+	t.tracker.emit(p)
+}
+
+// snapshot 计算百分比、速率与预计剩余时间，调用方持有 mu
+func (t *ProgressTask) snapshot() Progress {
+	p := t.p
+	end := p.UpdatedAt
+	if p.FinishedAt != nil {
+		end = *p.FinishedAt
+	}
+	elapsed := end.Sub(p.StartedAt).Seconds()
+	if elapsed > 0 {
+		p.Rate = float64(p.Processed) / elapsed
+	}
+
+	var fraction float64
+	switch {
+	case p.Total > 0:
+		fraction = float64(p.Processed) / float64(p.Total)
+	case t.bytesTotal > 0:
+		fraction = float64(t.bytesRead) / float64(t.bytesTotal)
+	}
+	if p.State == ProgressFinished {
+		fraction = 1
+	}
+	fraction = min(fraction, 1)
+	p.Percent = fraction * 100
+	if p.State == ProgressRunning && fraction > 0 && fraction < 1 {
+		p.ETA = int64(elapsed * (1 - fraction) / fraction)
+	}
+	return p
+}
+
+// countingReader 统计已读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
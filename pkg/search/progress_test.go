@@ -0,0 +1,89 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTracker(t *testing.T) {
+	tracker := NewProgressTracker(time.Hour, 1)
+	var mu sync.Mutex
+	var events []Progress
+	tracker.OnUpdate(func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, p)
+	})
+
+	task := tracker.Start(ProgressReindex, 10)
+	task.Add(4, 1)
+	p := task.Snapshot()
+	assert.Equal(t, ProgressRunning, p.State)
+	assert.EqualValues(t, 4, p.Processed)
+	assert.EqualValues(t, 1, p.Failed)
+	assert.InDelta(t, 40, p.Percent, 0.001)
+
+	task.Finish(nil)
+	p, ok := tracker.Get(task.ID())
+	require.True(t, ok)
+	assert.Equal(t, ProgressFinished, p.State)
+	assert.InDelta(t, 100, p.Percent, 0.001)
+	assert.Zero(t, p.ETA)
+	require.NotNil(t, p.FinishedAt)
+
+	// 运行中的更新按间隔节流，开始与结束事件总是推送
+	mu.Lock()
+	require.Len(t, events, 2)
+	assert.Equal(t, ProgressRunning, events[0].State)
+	assert.Equal(t, ProgressFinished, events[1].State)
+	mu.Unlock()
+
+	// 只保留最近结束的任务，运行中的任务不受影响
+	failed := tracker.Start(ProgressImport, 0)
+	failed.Finish(errors.New("boom"))
+	running := tracker.Start(ProgressImport, 0)
+	_, ok = tracker.Get(task.ID())
+	assert.False(t, ok)
+	p, ok = tracker.Get(failed.ID())
+	require.True(t, ok)
+	assert.Equal(t, ProgressFailed, p.State)
+	assert.Equal(t, "boom", p.Error)
+	list := tracker.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, running.ID(), list[0].ID)
+}
+
+func TestImportNDJSONProgress(t *testing.T) {
+	e := newTestEngine(t)
+	tracker := NewProgressTracker(time.Nanosecond, 0)
+	task := tracker.Start(ProgressImport, 0)
+
+	body := strings.Join([]string{
+		`{"id":"1","type":"article","fields":{"title":"one"}}`,
+		`{"id":"2","type":"article","fields":{"title":"two"}}`,
+		`{"id":"3","type":"article","fields":{"title":"three"}}`,
+	}, "\n")
+	r := &countingReader{r: strings.NewReader(body)}
+	var batches []int
+	res, err := ImportNDJSONWithProgress(context.Background(), e, r, 2, func(res ImportResult) {
+		batches = append(batches, res.Indexed)
+		task.SetBytes(r.n, int64(len(body)))
+		task.Set(int64(res.Indexed), int64(res.Failed))
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Indexed)
+	assert.Equal(t, []int{2, 3}, batches)
+
+	// 总数未知时按读取的字节数估算
+	p := task.Snapshot()
+	assert.EqualValues(t, 3, p.Processed)
+	assert.InDelta(t, 100, p.Percent, 0.001)
+	assert.Equal(t, int64(len(body)), r.n)
+}
@@ -9,7 +9,8 @@ import (
 	q "github.com/blevesearch/bleve/v2/search/query"
 )
 
-func buildQuery(req SearchRequest, defaultFields []string) q.Query {
+// buildQuery 将 SearchRequest 转换为 bleve 查询，dict 不为空时对 Keyword 与 Match 子句做同义词扩展和停用词过滤
+func buildQuery(req SearchRequest, defaultFields []string, dict *Dictionary) q.Query {
 	var must, should, mustNot []q.Query
 
 	// 0) 兼容旧 Keyword（按字段 OR）
@@ -18,13 +19,14 @@ func buildQuery(req SearchRequest, defaultFields []string) q.Query {
 		if len(fields) == 0 {
 			fields = defaultFields
 		}
+		keyword := dict.expandQueryString(req.Keyword)
 		var qs string
 		if len(fields) == 0 {
-			qs = req.Keyword
+			qs = keyword
 		} else {
 			parts := make([]string, 0, len(fields))
 			for _, f := range fields {
-				parts = append(parts, fmt.Sprintf("%s:(%s)", f, req.Keyword))
+				parts = append(parts, fmt.Sprintf("%s:(%s)", f, keyword))
 			}
 			qs = strings.Join(parts, " OR ")
 		}
@@ -81,6 +83,10 @@ func buildQuery(req SearchRequest, defaultFields []string) q.Query {
 
 	// 3) 高级子句
 	for _, m := range req.Matches {
+		if groups := dict.expandTerms(m.Query); groups != nil {
+			should = append(should, expandedMatchQuery(m, groups))
+			continue
+		}
 		mq := bleve.NewMatchQuery(m.Query)
 		if m.Field != "" {
 			mq.SetField(m.Field)
@@ -172,7 +178,19 @@ func buildQuery(req SearchRequest, defaultFields []string) q.Query {
 		must = append(must, drq)
 	}
 
-	// 6) 组装 Boolean
+	// 6) 地理位置
+	for _, g := range req.GeoDistances {
+		gq := bleve.NewGeoDistanceQuery(g.Lon, g.Lat, g.Distance)
+		gq.SetField(g.Field)
+		must = append(must, gq)
+	}
+	for _, g := range req.GeoBoundingBoxes {
+		gq := bleve.NewGeoBoundingBoxQuery(g.TopLeft.Lon, g.TopLeft.Lat, g.BottomRight.Lon, g.BottomRight.Lat)
+		gq.SetField(g.Field)
+		must = append(must, gq)
+	}
+
+	// 7) 组装 Boolean
 	boolQ := bleve.NewBooleanQuery()
 	if len(must) > 0 {
 		boolQ.AddMust(must...)
@@ -193,7 +211,35 @@ func buildQuery(req SearchRequest, defaultFields []string) q.Query {
 			boolQ.AddShould(should...)
 		}
 	}
-	return boolQ
+	return withAccess(boolQ, req.Access)
+}
+
+// expandedMatchQuery 每个词组内的原词与同义词任一命中即可，词组之间按 Operator 组合
+func expandedMatchQuery(m ClauseMatch, groups [][]string) q.Query {
+	clauses := make([]q.Query, 0, len(groups))
+	for _, g := range groups {
+		alts := make([]q.Query, 0, len(g))
+		for _, term := range g {
+			pq := bleve.NewMatchPhraseQuery(term)
+			if m.Field != "" {
+				pq.SetField(m.Field)
+			}
+			alts = append(alts, pq)
+		}
+		clauses = append(clauses, bleve.NewDisjunctionQuery(alts...))
+	}
+	if strings.ToLower(m.Operator) == "and" {
+		cq := bleve.NewConjunctionQuery(clauses...)
+		if m.Boost != nil {
+			cq.SetBoost(*m.Boost)
+		}
+		return cq
+	}
+	dq := bleve.NewDisjunctionQuery(clauses...)
+	if m.Boost != nil {
+		dq.SetBoost(*m.Boost)
+	}
+	return dq
 }
 
 func rMin(n NumericRangeFilter) *float64 {
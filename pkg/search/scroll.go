@@ -0,0 +1,129 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidCursor 游标无法解析或与查询条件不匹配
+var ErrInvalidCursor = errors.New("invalid scroll cursor")
+
+// maxScrollSize 单页最大条数
+const maxScrollSize = 1000
+
+// ScrollResult 游标分页结果，Cursor 为空表示没有更多结果
+type ScrollResult struct {
+	SearchResult
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// scrollCursor 游标内容，编码后交给客户端原样带回
+type scrollCursor struct {
+	// 上一页最后一条命中的排序值
+	After []string `json:"a"`
+	// 查询条件指纹，防止换查询后沿用旧游标
+	Hash string `json:"h"`
+	// Elasticsearch point in time
+	PIT string `json:"p,omitempty"`
+}
+
+func encodeCursor(c scrollCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析游标并校验查询指纹，cursor 为空时返回首页游标
+func decodeCursor(cursor, hash string) (scrollCursor, error) {
+	if cursor == "" {
+		return scrollCursor{Hash: hash}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return scrollCursor{}, ErrInvalidCursor
+	}
+	var c scrollCursor
+	if err := json.Unmarshal(data, &c); err != nil || len(c.After) == 0 {
+		return scrollCursor{}, ErrInvalidCursor
+	}
+	if c.Hash != hash {
+		return scrollCursor{}, fmt.Errorf("%w: query changed", ErrInvalidCursor)
+	}
+	return c, nil
+}
+
+// prepareScroll 规范化游标分页请求：忽略 From、补齐排序，返回查询指纹
+func prepareScroll(req SearchRequest, tiebreaker *SortField) (SearchRequest, string, error) {
+	if req.Size <= 0 {
+		req.Size = 10
+	}
+	if req.Size > maxScrollSize {
+		req.Size = maxScrollSize
+	}
+	req.From = 0
+	req.SearchAfter = nil
+
+	sorts := sortFields(req)
+	if len(sorts) == 0 {
+		sorts = []SortField{{Field: SortFieldScore, Desc: true}}
+	}
+	if tiebreaker != nil {
+		found := false
+		for _, s := range sorts {
+			if s.Field == tiebreaker.Field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sorts = append(sorts, *tiebreaker)
+		}
+	}
+	req.Sort = sorts
+	req.SortBy = nil
+
+	// 页大小不参与指纹，客户端可以逐页调整
+	fp := req
+	fp.Size = 0
+	data, err := json.Marshal(fp)
+	if err != nil {
+		return req, "", err
+	}
+	sum := sha256.Sum256(data)
+	return req, hex.EncodeToString(sum[:8]), nil
+}
+
+// nextCursor 本页取满时以最后一条命中的排序值生成下一页游标
+func nextCursor(res SearchResult, size int, cur scrollCursor) string {
+	if len(res.Hits) < size {
+		return ""
+	}
+	last := res.Hits[len(res.Hits)-1]
+	if len(last.Sort) == 0 {
+		return ""
+	}
+	cur.After = last.Sort
+	return encodeCursor(cur)
+}
+
+// Scroll 基于 search_after 的游标分页，排序末尾追加文档ID保证翻页稳定
+func (e *bleveEngine) Scroll(ctx context.Context, req SearchRequest, cursor string) (ScrollResult, error) {
+	req, hash, err := prepareScroll(req, &SortField{Field: SortFieldID})
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	cur, err := decodeCursor(cursor, hash)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	req.SearchAfter = cur.After
+	res, err := e.Search(ctx, req)
+	if err != nil {
+		return ScrollResult{}, err
+	}
+	return ScrollResult{SearchResult: res, Cursor: nextCursor(res, req.Size, cur)}, nil
+}
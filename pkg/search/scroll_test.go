@@ -0,0 +1,59 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBleveScroll(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	docs := make([]Doc, 0, 25)
+	for i := 0; i < 25; i++ {
+		docs = append(docs, Doc{ID: fmt.Sprintf("doc-%02d", i), Type: "article", Fields: map[string]any{
+			"title": "hello world",
+			"views": float64(i % 5),
+		}})
+	}
+	require.NoError(t, e.IndexBatch(ctx, docs))
+
+	req := SearchRequest{Keyword: "hello", SearchFields: []string{"title"}, SortBy: []string{"-views"}, Size: 10}
+	seen := map[string]bool{}
+	cursor := ""
+	pages := 0
+	for {
+		res, err := e.Scroll(ctx, req, cursor)
+		require.NoError(t, err)
+		pages++
+		for _, h := range res.Hits {
+			assert.False(t, seen[h.ID], "duplicate hit %s", h.ID)
+			seen[h.ID] = true
+		}
+		if res.Cursor == "" {
+			break
+		}
+		cursor = res.Cursor
+		require.Less(t, pages, 10)
+	}
+	assert.Equal(t, 3, pages)
+	assert.Len(t, seen, 25)
+
+	// 游标与查询条件绑定
+	first, err := e.Scroll(ctx, req, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, first.Cursor)
+	_, err = e.Scroll(ctx, SearchRequest{Keyword: "other", SearchFields: []string{"title"}, Size: 10}, first.Cursor)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = e.Scroll(ctx, req, "not-a-cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	// 页大小可以逐页调整
+	next, err := e.Scroll(ctx, SearchRequest{Keyword: "hello", SearchFields: []string{"title"}, SortBy: []string{"-views"}, Size: 20}, first.Cursor)
+	require.NoError(t, err)
+	assert.Len(t, next.Hits, 15)
+	assert.Empty(t, next.Cursor)
+}
@@ -3,7 +3,14 @@ package search
 import (
 	"HibiscusIM/pkg/config"
 	"HibiscusIM/pkg/response"
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,6 +18,22 @@ import (
 // SearchHandlers 封装搜索相关的API处理
 type SearchHandlers struct {
 	engine Engine
+	// adminAuth 判断当前请求是否具备管理员权限（Explain 调试、索引维护），未设置时一律拒绝
+	adminAuth func(c *gin.Context) bool
+	// indexer 模型自动同步，未设置时不提供重建接口
+	indexer *Indexer
+	// progress 重建与导入任务的进度，未设置时不提供进度接口
+	progress *ProgressTracker
+	// feedback 点击反馈收集，未设置时不提供反馈接口
+	feedback *Feedback
+	// dictionaries 同义词与停用词词典，未设置时不提供词典接口
+	dictionaries *Dictionaries
+	// migrator 映射迁移，未设置时不提供迁移接口
+	migrator *Migrator
+	// access 返回当前请求的文档访问过滤，未设置或返回 nil 时不限制
+	access func(c *gin.Context) *AccessFilter
+	// privateTypes 只能通过业务接口访问的文档类型（如聊天消息），通用搜索与写入接口不可见
+	privateTypes []string
 }
 
 // NewSearchHandlers 创建一个新的SearchHandlers实例
@@ -20,6 +43,61 @@ func NewSearchHandlers(engine Engine) *SearchHandlers {
 	}
 }
 
+// SetAdminAuthorizer 设置管理员权限检查
+func (h *SearchHandlers) SetAdminAuthorizer(fn func(c *gin.Context) bool) {
+	h.adminAuth = fn
+}
+
+// SetAccessResolver 设置文档级访问控制，搜索与游标分页的命中及 Facet 统计只包含当前用户可见的文档
+func (h *SearchHandlers) SetAccessResolver(fn func(c *gin.Context) *AccessFilter) {
+	h.access = fn
+}
+
+// SetPrivateTypes 设置私有文档类型：通用搜索与游标分页对任何人排除这些类型，
+// 通用写入接口拒绝写入，只能由服务端写入并通过各自带权限校验的业务接口查询
+func (h *SearchHandlers) SetPrivateTypes(types ...string) {
+	h.privateTypes = types
+}
+
+// SetIndexer 设置模型索引同步器
+func (h *SearchHandlers) SetIndexer(indexer *Indexer) {
+	h.indexer = indexer
+}
+
+// SetProgressTracker 设置重建与导入任务的进度跟踪
+func (h *SearchHandlers) SetProgressTracker(t *ProgressTracker) {
+	h.progress = t
+}
+
+// SetFeedback 设置搜索点击反馈收集器
+func (h *SearchHandlers) SetFeedback(feedback *Feedback) {
+	h.feedback = feedback
+}
+
+// SetDictionaries 设置同义词与停用词词典，需与引擎配置中的词典为同一实例
+func (h *SearchHandlers) SetDictionaries(d *Dictionaries) {
+	h.dictionaries = d
+}
+
+// SetMigrator 设置映射迁移，需为引擎所包装的同一实例
+func (h *SearchHandlers) SetMigrator(m *Migrator) {
+	h.migrator = m
+}
+
+// isAdmin 判断当前请求是否具备管理员权限
+func (h *SearchHandlers) isAdmin(c *gin.Context) bool {
+	return h.adminAuth != nil && h.adminAuth(c)
+}
+
+// requireAdmin 管理员权限中间件
+func (h *SearchHandlers) requireAdmin(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin privileges required"})
+		return
+	}
+	c.Next()
+}
+
 // RegisterSearchRoutes 注册与搜索相关的路由
 func (h *SearchHandlers) RegisterSearchRoutes(r *gin.RouterGroup) {
 	if !config.GlobalConfig.SearchEnabled {
@@ -32,14 +110,52 @@ func (h *SearchHandlers) RegisterSearchRoutes(r *gin.RouterGroup) {
 	{
 		// 搜索接口
 		searchGroup.POST("/", h.handleSearch)
-		// 索引文档接口
-		searchGroup.POST("/index", h.handleIndex)
-		// 删除文档接口
-		searchGroup.POST("/delete", h.handleDelete)
+		// 游标分页接口，用于深分页与导出
+		searchGroup.POST("/scroll", h.handleScroll)
+		// 索引文档接口（管理员）
+		searchGroup.POST("/index", h.requireAdmin, h.handleIndex)
+		// NDJSON 批量导入接口（管理员）
+		searchGroup.POST("/import", h.requireAdmin, h.handleImport)
+		// 删除文档接口（管理员）
+		searchGroup.POST("/delete", h.requireAdmin, h.handleDelete)
 		// 自动补全接口
 		searchGroup.POST("/auto-complete", h.handleAutoComplete)
 		// 搜索建议接口
 		searchGroup.POST("/suggest", h.handleSuggest)
+		// 索引维护接口（管理员）
+		searchGroup.GET("/snapshots", h.requireAdmin, h.handleListSnapshots)
+		searchGroup.POST("/compact", h.requireAdmin, h.handleCompact)
+		searchGroup.GET("/index/stats", h.requireAdmin, h.handleIndexStats)
+		searchGroup.POST("/index/optimize", h.requireAdmin, h.handleCompact)
+		searchGroup.DELETE("/index", h.requireAdmin, h.handleRecreateIndex)
+		if h.indexer != nil {
+			searchGroup.GET("/indexer", h.requireAdmin, h.handleIndexerStats)
+			searchGroup.POST("/reindex", h.requireAdmin, h.handleReindex)
+		}
+		if h.progress != nil {
+			// 重建与导入任务进度轮询（管理员）
+			searchGroup.GET("/progress", h.requireAdmin, h.handleListProgress)
+			searchGroup.GET("/progress/:id", h.requireAdmin, h.handleGetProgress)
+		}
+		if h.feedback != nil {
+			// 点击反馈上报接口
+			searchGroup.POST("/click", h.handleClick)
+			// 各位置点击率报表（管理员）
+			searchGroup.GET("/ctr", h.requireAdmin, h.handleCTR)
+		}
+		if h.dictionaries != nil {
+			// 词典统计与热重载（管理员）
+			searchGroup.GET("/dictionaries", h.requireAdmin, h.handleDictionaryStats)
+			searchGroup.POST("/dictionaries/reload", h.requireAdmin, h.handleReloadDictionaries)
+		}
+		if h.migrator != nil {
+			// 不停机映射迁移：开始、查看进度、重新校验、切换与放弃（管理员）
+			searchGroup.GET("/migrations", h.requireAdmin, h.handleMigrationStatus)
+			searchGroup.POST("/migrations", h.requireAdmin, h.handleStartMigration)
+			searchGroup.POST("/migrations/verify", h.requireAdmin, h.handleVerifyMigration)
+			searchGroup.POST("/migrations/switch", h.requireAdmin, h.handleSwitchMigration)
+			searchGroup.DELETE("/migrations", h.requireAdmin, h.handleAbortMigration)
+		}
 	}
 }
 
@@ -50,17 +166,105 @@ func (h *SearchHandlers) handleSearch(c *gin.Context) {
 		response.Fail(c, "Invalid search request", gin.H{"error": err.Error()})
 		return
 	}
+	if req.Explain && !h.isAdmin(c) {
+		response.Fail(c, "Explain requires admin privileges", nil)
+		return
+	}
+	h.restrict(c, &req)
 
 	// 执行搜索
 	result, err := h.engine.Search(c, req)
+	if errors.Is(err, ErrInvalidSort) {
+		response.Fail(c, "Invalid search request", gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
 		return
 	}
+	if h.feedback != nil {
+		h.applyFeedback(c, req, &result)
+	}
 
 	response.Success(c, "Get Search Result", result)
 }
 
+// restrict 叠加当前请求的文档访问过滤，并排除私有文档类型
+func (h *SearchHandlers) restrict(c *gin.Context, req *SearchRequest) {
+	if h.access != nil {
+		req.Access = h.access(c)
+	}
+	if len(h.privateTypes) == 0 {
+		return
+	}
+	mustNot := make(map[string][]string, len(req.MustNotTerms)+1)
+	for field, values := range req.MustNotTerms {
+		mustNot[field] = values
+	}
+	mustNot["type"] = append(append([]string(nil), mustNot["type"]...), h.privateTypes...)
+	req.MustNotTerms = mustNot
+}
+
+// isPrivateType 文档是否为私有类型，Doc.Type 为空时按 fields.type 判断
+func (h *SearchHandlers) isPrivateType(doc Doc) bool {
+	docType := doc.Type
+	if docType == "" {
+		docType, _ = doc.Fields["type"].(string)
+	}
+	for _, t := range h.privateTypes {
+		if t == docType {
+			return true
+		}
+	}
+	return false
+}
+
+// applyFeedback 按历史点击调整相关度排序并记录本次展示，失败时只记录日志
+func (h *SearchHandlers) applyFeedback(c *gin.Context, req SearchRequest, result *SearchResult) {
+	query := feedbackQuery(req)
+	if query == "" {
+		return
+	}
+	// 自定义排序或 SearchAfter 翻页时保持引擎顺序
+	if len(sortFields(req)) == 0 && len(req.SearchAfter) == 0 && !req.Explain {
+		if err := h.feedback.Rerank(c.Request.Context(), query, result); err != nil {
+			log.Printf("search feedback rerank failed: %v", err)
+		}
+	}
+	if err := h.feedback.RecordImpression(c.Request.Context(), query, req.From, len(result.Hits)); err != nil {
+		log.Printf("record search impression failed: %v", err)
+	}
+}
+
+// handleScroll 处理游标分页请求，首次请求不带 cursor，之后带上一页返回的 cursor 且查询条件不变
+func (h *SearchHandlers) handleScroll(c *gin.Context) {
+	var req struct {
+		SearchRequest
+		Cursor string `json:"cursor"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid scroll request", gin.H{"error": err.Error()})
+		return
+	}
+	if req.Explain && !h.isAdmin(c) {
+		response.Fail(c, "Explain requires admin privileges", nil)
+		return
+	}
+	h.restrict(c, &req.SearchRequest)
+
+	result, err := h.engine.Scroll(c, req.SearchRequest, req.Cursor)
+	if errors.Is(err, ErrInvalidSort) || errors.Is(err, ErrInvalidCursor) {
+		response.Fail(c, "Invalid scroll request", gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+		return
+	}
+
+	response.Success(c, "Get Scroll Result", result)
+}
+
 // handleIndex 处理文档索引请求
 func (h *SearchHandlers) handleIndex(c *gin.Context) {
 	var doc Doc
@@ -68,6 +272,10 @@ func (h *SearchHandlers) handleIndex(c *gin.Context) {
 		response.Fail(c, "Invalid document", gin.H{"error": err.Error()})
 		return
 	}
+	if h.isPrivateType(doc) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "document type cannot be written through the search API"})
+		return
+	}
 
 	// 索引文档
 	err := h.engine.Index(c, doc)
@@ -78,6 +286,40 @@ func (h *SearchHandlers) handleIndex(c *gin.Context) {
 	response.Success(c, "Document indexed successfully", gin.H{"doc": doc})
 }
 
+// handleImport 处理 NDJSON 批量导入请求，每行一个文档
+func (h *SearchHandlers) handleImport(c *gin.Context) {
+	batchSize, _ := strconv.Atoi(c.Query("batch_size"))
+	if batchSize <= 0 {
+		batchSize = config.GlobalConfig.SearchBatchSize
+	}
+
+	if h.progress == nil {
+		result, err := ImportNDJSON(c.Request.Context(), h.engine, c.Request.Body, batchSize)
+		if err != nil {
+			response.Fail(c, "Import aborted", gin.H{"error": err.Error(), "result": result})
+			return
+		}
+		response.Success(c, "Documents imported", result)
+		return
+	}
+
+	// 文档总数未知，按已读取的请求体字节数估算进度
+	body := &countingReader{r: c.Request.Body}
+	task := h.progress.Start(ProgressImport, 0)
+	c.Header("X-Progress-Id", task.ID())
+	result, err := ImportNDJSONWithProgress(c.Request.Context(), h.engine, body, batchSize, func(res ImportResult) {
+		task.SetBytes(body.n, c.Request.ContentLength)
+		task.Set(int64(res.Indexed), int64(res.Failed))
+	})
+	task.Set(int64(result.Indexed), int64(result.Failed))
+	task.Finish(err)
+	if err != nil {
+		response.Fail(c, "Import aborted", gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	response.Success(c, "Documents imported", result)
+}
+
 // handleDelete 处理文档删除请求
 func (h *SearchHandlers) handleDelete(c *gin.Context) {
 	var req struct {
@@ -137,3 +379,315 @@ func (h *SearchHandlers) handleSuggest(c *gin.Context) {
 
 	response.Success(c, "Get Suggestion successfully", suggestions)
 }
+
+// handleListSnapshots 列出索引与备份快照及其大小、时长
+func (h *SearchHandlers) handleListSnapshots(c *gin.Context) {
+	snaps, err := h.engine.Snapshots()
+	if err != nil {
+		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+		return
+	}
+	backups, err := ListBackupSnapshots(config.GlobalConfig.BackupPath)
+	if err != nil {
+		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+		return
+	}
+	snaps = append(snaps, backups...)
+	sort.SliceStable(snaps, func(i, j int) bool { return snaps[i].ModTime.After(snaps[j].ModTime) })
+
+	var total int64
+	for _, s := range snaps {
+		total += s.Size
+	}
+	response.Success(c, "Get snapshots successfully", gin.H{"snapshots": snaps, "totalSize": total})
+}
+
+// handleCompact 触发索引合并以回收磁盘空间
+func (h *SearchHandlers) handleCompact(c *gin.Context) {
+	result, err := h.engine.Compact(c.Request.Context())
+	if err != nil {
+		response.Fail(c, "Index compaction failed", gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("search index compacted: reclaimed %d bytes in %s", result.Reclaimed, result.Duration)
+	response.Success(c, "Index compacted successfully", result)
+}
+
+// handleIndexStats 索引文档数、磁盘占用，以及结果缓存与同步队列统计
+func (h *SearchHandlers) handleIndexStats(c *gin.Context) {
+	stats, err := h.engine.IndexStats(c.Request.Context())
+	if err != nil {
+		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+		return
+	}
+	data := gin.H{"index": stats}
+	if cached, ok := h.engine.(*CachedEngine); ok {
+		data["cache"] = cached.Stats()
+	}
+	if h.indexer != nil {
+		data["indexer"] = h.indexer.Stats()
+	}
+	response.Success(c, "Get index stats successfully", data)
+}
+
+// handleRecreateIndex 删除并按新映射重建索引，未指定 mapping 时沿用当前映射；
+// reindex 为 true 时重建后从数据库全量导入
+func (h *SearchHandlers) handleRecreateIndex(c *gin.Context) {
+	var req struct {
+		Mapping json.RawMessage `json:"mapping"`
+		Reindex bool            `json:"reindex"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "Invalid recreate request", gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Reindex && h.indexer == nil {
+		response.Fail(c, "Invalid recreate request", gin.H{"error": "reindex requires model indexer"})
+		return
+	}
+	if string(req.Mapping) == "null" {
+		req.Mapping = nil
+	}
+	if err := h.engine.Recreate(c.Request.Context(), req.Mapping); err != nil {
+		response.Fail(c, "Recreate index failed", gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("search index recreated (custom mapping: %t)", len(req.Mapping) > 0)
+	if !req.Reindex {
+		response.Success(c, "Index recreated", nil)
+		return
+	}
+	result, err := h.indexer.Reindex(c.Request.Context())
+	if err != nil {
+		response.Fail(c, "Index recreated but reindex failed", gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	log.Printf("search reindex finished: %v in %s", result.Indexed, result.Duration)
+	response.Success(c, "Index recreated and reindexed", result)
+}
+
+// handleIndexerStats 模型同步队列统计
+func (h *SearchHandlers) handleIndexerStats(c *gin.Context) {
+	response.Success(c, "Get indexer stats successfully", h.indexer.Stats())
+}
+
+// handleReindex 从数据库全量重建已注册模型的索引，可通过 types 指定文档类型
+func (h *SearchHandlers) handleReindex(c *gin.Context) {
+	var req struct {
+		Types []string `json:"types"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "Invalid reindex request", gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if h.progress == nil {
+		result, err := h.indexer.Reindex(c.Request.Context(), req.Types...)
+		if err != nil {
+			response.Fail(c, "Reindex failed", gin.H{"error": err.Error(), "result": result})
+			return
+		}
+		log.Printf("search reindex finished: %v in %s", result.Indexed, result.Duration)
+		response.Success(c, "Reindex finished", result)
+		return
+	}
+
+	var total int64
+	if counts, err := h.indexer.CountTypes(c.Request.Context(), req.Types...); err == nil {
+		for _, n := range counts {
+			total += n
+		}
+	}
+	task := h.progress.Start(ProgressReindex, total)
+	c.Header("X-Progress-Id", task.ID())
+	// async=true 时在后台重建，立即返回任务进度，之后通过进度接口或推送跟踪
+	if async, _ := strconv.ParseBool(c.Query("async")); async {
+		go func() {
+			result, err := h.indexer.ReindexWithProgress(context.Background(), func(_ string, n int) { task.Add(int64(n), 0) }, req.Types...)
+			task.Finish(err)
+			if err != nil {
+				log.Printf("search reindex failed: %v", err)
+				return
+			}
+			log.Printf("search reindex finished: %v in %s", result.Indexed, result.Duration)
+		}()
+		response.Success(c, "Reindex started", task.Snapshot())
+		return
+	}
+	result, err := h.indexer.ReindexWithProgress(c.Request.Context(), func(_ string, n int) { task.Add(int64(n), 0) }, req.Types...)
+	task.Finish(err)
+	if err != nil {
+		response.Fail(c, "Reindex failed", gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	log.Printf("search reindex finished: %v in %s", result.Indexed, result.Duration)
+	response.Success(c, "Reindex finished", result)
+}
+
+// handleListProgress 运行中与最近结束的重建、导入任务
+func (h *SearchHandlers) handleListProgress(c *gin.Context) {
+	response.Success(c, "Get progress successfully", h.progress.List())
+}
+
+// handleGetProgress 按ID查询任务进度
+func (h *SearchHandlers) handleGetProgress(c *gin.Context) {
+	p, ok := h.progress.Get(c.Param("id"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "progress not found"})
+		return
+	}
+	response.Success(c, "Get progress successfully", p)
+}
+
+// handleDictionaryStats 当前词典的同义词数、停用词数与加载时间
+func (h *SearchHandlers) handleDictionaryStats(c *gin.Context) {
+	response.Success(c, "Get dictionary stats successfully", h.dictionaries.Stats())
+}
+
+// handleReloadDictionaries 从文件与数据库重新加载词典，无需重启引擎；加载失败时继续使用旧词典
+func (h *SearchHandlers) handleReloadDictionaries(c *gin.Context) {
+	stats, err := h.dictionaries.Reload(c.Request.Context())
+	if err != nil {
+		response.Fail(c, "Reload dictionaries failed", gin.H{"error": err.Error(), "stats": stats})
+		return
+	}
+	// 缓存的结果按旧词典查询，需要失效
+	if cached, ok := h.engine.(*CachedEngine); ok {
+		cached.Invalidate(c.Request.Context())
+	}
+	log.Printf("search dictionaries reloaded: %d synonyms, %d stopwords", stats.Synonyms, stats.Stopwords)
+	response.Success(c, "Reload dictionaries successfully", stats)
+}
+
+// handleMigrationStatus 当前迁移进度与最近的索引版本
+func (h *SearchHandlers) handleMigrationStatus(c *gin.Context) {
+	versions, err := h.migrator.Versions(c.Request.Context(), 20)
+	if err != nil {
+		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+		return
+	}
+	response.Success(c, "Get migration status successfully", gin.H{
+		"location":  h.migrator.Location(),
+		"migration": h.migrator.Progress(),
+		"versions":  versions,
+	})
+}
+
+// handleStartMigration 按 mapping 在后台创建新索引并回填，未指定 mapping 时使用内置映射
+func (h *SearchHandlers) handleStartMigration(c *gin.Context) {
+	var req struct {
+		Mapping json.RawMessage `json:"mapping"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "Invalid migration request", gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if string(req.Mapping) == "null" {
+		req.Mapping = nil
+	}
+	progress, err := h.migrator.Start(c.Request.Context(), req.Mapping)
+	if err != nil {
+		response.Fail(c, "Start migration failed", gin.H{"error": err.Error(), "migration": progress})
+		return
+	}
+	response.Success(c, "Migration started", progress)
+}
+
+// handleVerifyMigration 重新比对新旧索引的文档数与抽样文档
+func (h *SearchHandlers) handleVerifyMigration(c *gin.Context) {
+	progress, err := h.migrator.Verify(c.Request.Context())
+	if err != nil {
+		response.Fail(c, "Verify migration failed", gin.H{"error": err.Error(), "migration": progress})
+		return
+	}
+	response.Success(c, "Migration verified", progress)
+}
+
+// handleSwitchMigration 切换到新索引，校验未通过时需 force
+func (h *SearchHandlers) handleSwitchMigration(c *gin.Context) {
+	var req struct {
+		Force bool `json:"force"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Fail(c, "Invalid switch request", gin.H{"error": err.Error()})
+			return
+		}
+	}
+	progress, err := h.migrator.Switch(c.Request.Context(), req.Force)
+	if err != nil {
+		response.Fail(c, "Switch index failed", gin.H{"error": err.Error(), "migration": progress})
+		return
+	}
+	// 缓存的结果来自旧索引，需要失效
+	if cached, ok := h.engine.(*CachedEngine); ok {
+		cached.Invalidate(c.Request.Context())
+	}
+	response.Success(c, "Switched to new index", progress)
+}
+
+// handleAbortMigration 放弃迁移并删除新索引
+func (h *SearchHandlers) handleAbortMigration(c *gin.Context) {
+	progress, err := h.migrator.Abort(c.Request.Context())
+	if err != nil {
+		response.Fail(c, "Abort migration failed", gin.H{"error": err.Error(), "migration": progress})
+		return
+	}
+	response.Success(c, "Migration aborted", progress)
+}
+
+// handleClick 记录客户端上报的命中点击或操作，position 为命中在结果中的位置（从 1 开始）
+func (h *SearchHandlers) handleClick(c *gin.Context) {
+	var req struct {
+		Query    string `json:"query" binding:"required"`
+		ID       string `json:"id" binding:"required"`
+		Position int    `json:"position" binding:"required"`
+		Action   string `json:"action"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "Invalid feedback", gin.H{"error": err.Error()})
+		return
+	}
+	err := h.feedback.RecordClick(c.Request.Context(), SearchClick{Query: req.Query, DocID: req.ID, Position: req.Position, Action: req.Action})
+	if errors.Is(err, ErrInvalidFeedback) {
+		response.Fail(c, "Invalid feedback", gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+		return
+	}
+	response.Success(c, "Feedback recorded", nil)
+}
+
+// handleCTR 返回最近 days 天（默认 7）前 positions 个位置的点击率，指定 query 时附带该查询点击最多的文档
+func (h *SearchHandlers) handleCTR(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days <= 0 {
+		days = 7
+	}
+	positions, _ := strconv.Atoi(c.Query("positions"))
+	since := time.Now().AddDate(0, 0, -days)
+
+	report, err := h.feedback.CTRByPosition(c.Request.Context(), since, positions)
+	if err != nil {
+		response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+		return
+	}
+	data := gin.H{"since": since, "positions": report}
+	if q := c.Query("query"); q != "" {
+		docs, err := h.feedback.TopClicked(c.Request.Context(), q, since, 20)
+		if err != nil {
+			response.Fail(c, "Internal Server Error", gin.H{"error": err.Error()})
+			return
+		}
+		data["query"] = NormalizeQuery(q)
+		data["documents"] = docs
+	}
+	response.Success(c, "Get click-through report successfully", data)
+}
@@ -0,0 +1,344 @@
+package search
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2/search/highlight/highlighter/simple"
+	"github.com/spf13/cast"
+)
+
+// SnippetSource 按文档ID批量读取原文，返回 文档ID -> 字段 -> 原文，找不到的文档可省略
+type SnippetSource func(ctx context.Context, ids []string) (map[string]map[string]string, error)
+
+type snippetResolver struct {
+	fields []string
+	source SnippetSource
+}
+
+// SnippetEngine 为了控制索引体积，正文等大字段可以不存储在索引中（见 BuildIndexMapping），此时命中没有字段值与片段。
+// SnippetEngine 按命中ID从数据库或消息存储读取原文，补回请求的字段并在服务端计算高亮，返回结构与索引存储时一致。
+// 原文经过与写入时相同的处理链，保证脱敏等处理同样作用于片段
+type SnippetEngine struct {
+	Engine
+	pipeline *EnrichPipeline
+
+	mu        sync.RWMutex
+	resolvers map[string]snippetResolver
+}
+
+// NewSnippetEngine 包装 e，p 为写入索引时使用的处理链，可为 nil
+func NewSnippetEngine(e Engine, p *EnrichPipeline) *SnippetEngine {
+	return &SnippetEngine{Engine: e, pipeline: p, resolvers: make(map[string]snippetResolver)}
+}
+
+// Register 声明 docType 的 fields 可能未存储在索引中，缺失时从 src 读取
+func (e *SnippetEngine) Register(docType string, src SnippetSource, fields ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resolvers[docType] = snippetResolver{fields: fields, source: src}
+}
+
+// Search 检索后补回缺失的字段与片段，读取原文失败时返回索引中的结果
+func (e *SnippetEngine) Search(ctx context.Context, req SearchRequest) (SearchResult, error) {
+	res, err := e.Engine.Search(ctx, req)
+	if err != nil || len(res.Hits) == 0 {
+		return res, err
+	}
+	if err := e.resolve(ctx, req, res.Hits); err != nil {
+		log.Printf("search: resolve snippets failed: %v", err)
+	}
+	return res, nil
+}
+
+func (e *SnippetEngine) resolve(ctx context.Context, req SearchRequest, hits []Hit) error {
+	var hl highlightOptions
+	if req.Highlight {
+		var err error
+		if hl, err = newHighlightOptions(req); err != nil {
+			return err
+		}
+	}
+
+	// 按文档类型收集缺少字段的命中
+	e.mu.RLock()
+	pending := make(map[string][]int)
+	for i, hit := range hits {
+		docType := snippetDocType(hit, req)
+		r, ok := e.resolvers[docType]
+		if !ok {
+			continue
+		}
+		for _, f := range r.fields {
+			if _, stored := hit.Fields[f]; !stored && (wantsField(req, f) || wantsHighlight(req, f)) {
+				pending[docType] = append(pending[docType], i)
+				break
+			}
+		}
+	}
+	resolvers := make(map[string]snippetResolver, len(pending))
+	for docType := range pending {
+		resolvers[docType] = e.resolvers[docType]
+	}
+	e.mu.RUnlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	terms := queryTerms(req)
+	for docType, idx := range pending {
+		r := resolvers[docType]
+		ids := make([]string, 0, len(idx))
+		for _, i := range idx {
+			ids = append(ids, hits[i].ID)
+		}
+		texts, err := r.source(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, i := range idx {
+			fields, ok := texts[hits[i].ID]
+			if !ok {
+				continue
+			}
+			fields, err = e.enrich(ctx, hits[i].ID, docType, fields)
+			if err != nil {
+				return err
+			}
+			e.fill(&hits[i], req, hl, r.fields, fields, terms)
+		}
+	}
+	return nil
+}
+
+// enrich 原文经过写入时的处理链
+func (e *SnippetEngine) enrich(ctx context.Context, id, docType string, fields map[string]string) (map[string]string, error) {
+	if e.pipeline == nil {
+		return fields, nil
+	}
+	doc := Doc{ID: id, Type: docType, Fields: make(map[string]interface{}, len(fields))}
+	for k, v := range fields {
+		doc.Fields[k] = v
+	}
+	doc, err := e.pipeline.Enrich(ctx, doc)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(fields))
+	for k := range fields {
+		out[k] = cast.ToString(doc.Fields[k])
+	}
+	return out, nil
+}
+
+// fill 补回请求的字段，并为没有片段的高亮字段计算片段
+func (e *SnippetEngine) fill(hit *Hit, req SearchRequest, hl highlightOptions, fields []string, texts map[string]string, terms []string) {
+	for _, f := range fields {
+		text, ok := texts[f]
+		if _, stored := hit.Fields[f]; stored || !ok {
+			continue
+		}
+		if wantsField(req, f) {
+			if hit.Fields == nil {
+				hit.Fields = make(map[string]any)
+			}
+			hit.Fields[f] = text
+		}
+		if !wantsHighlight(req, f) || len(hit.Fragments[f]) > 0 {
+			continue
+		}
+		frags := highlightText(text, terms, hl.fragmentSize, hl.maxFragments)
+		if len(frags) == 0 {
+			continue
+		}
+		if hit.Fragments == nil {
+			hit.Fragments = make(map[string][]string)
+		}
+		for _, frag := range frags {
+			hit.Fragments[f] = append(hit.Fragments[f], hl.formatFragment(frag))
+		}
+	}
+}
+
+// snippetDocType 命中的文档类型：优先取返回的 type 字段，其次取请求限定的唯一类型，最后取 类型:主键 格式ID的前缀
+func snippetDocType(hit Hit, req SearchRequest) string {
+	if t, ok := hit.Fields["type"].(string); ok && t != "" {
+		return t
+	}
+	if types := req.MustTerms["type"]; len(types) == 1 {
+		return types[0]
+	}
+	docType, _, _ := strings.Cut(hit.ID, ":")
+	return docType
+}
+
+func wantsField(req SearchRequest, field string) bool {
+	return len(req.IncludeFields) == 0 || slices.Contains(req.IncludeFields, field) || slices.Contains(req.IncludeFields, "*")
+}
+
+func wantsHighlight(req SearchRequest, field string) bool {
+	return req.Highlight && (len(req.HighlightFields) == 0 || slices.Contains(req.HighlightFields, field))
+}
+
+// queryTerms 收集请求中的查询词（小写），连续的中日韩文字作为一个词
+func queryTerms(req SearchRequest) []string {
+	texts := []string{req.Keyword}
+	if req.QueryString != nil {
+		texts = append(texts, req.QueryString.Query)
+	}
+	for _, m := range req.Matches {
+		texts = append(texts, m.Query)
+	}
+	for _, p := range req.Phrases {
+		texts = append(texts, p.Phrase)
+	}
+	for _, p := range req.Prefixes {
+		texts = append(texts, p.Prefix)
+	}
+	for _, f := range req.Fuzzies {
+		texts = append(texts, f.Term)
+	}
+	seen := make(map[string]bool)
+	var terms []string
+	for _, text := range texts {
+		for _, t := range splitTerms(text) {
+			if !seen[t] {
+				seen[t] = true
+				terms = append(terms, t)
+			}
+		}
+	}
+	return terms
+}
+
+func splitTerms(text string) []string {
+	var terms []string
+	var cur []rune
+	cjk := false
+	flush := func() {
+		if len(cur) > 0 {
+			terms = append(terms, string(cur))
+			cur = cur[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !cjk {
+				flush()
+			}
+			cjk = true
+			cur = append(cur, r)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if cjk {
+				flush()
+			}
+			cjk = false
+			cur = append(cur, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// isWordRune 第 i 个字符是否为字母或数字，越界时为 false
+func isWordRune(text []rune, i int) bool {
+	return i >= 0 && i < len(text) && (unicode.IsLetter(text[i]) || unicode.IsNumber(text[i]))
+}
+
+type textSpan struct{ start, end int }
+
+// highlightText 在原文中标出查询词（不区分大小写，非中日韩词需完整匹配单词），按命中数选出至多 n 个长度为 size 的片段，
+// 命中词以占位符包裹，被截断的一侧加省略号，与 bleve 片段一致
+func highlightText(text string, terms []string, size, n int) []string {
+	if len(terms) == 0 || size <= 0 || n <= 0 {
+		return nil
+	}
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	var spans []textSpan
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if !slices.Equal(lower[i:i+len(t)], t) {
+				continue
+			}
+			if !isCJK(t[0]) && (isWordRune(lower, i-1) || isWordRune(lower, i+len(t))) {
+				continue
+			}
+			spans = append(spans, textSpan{i, i + len(t)})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s.start <= last.end {
+			last.end = max(last.end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	// 每个命中作为候选片段的中心，片段互不重叠，按包含的命中数挑选
+	type window struct{ start, end, hits int }
+	var windows []window
+	for i := 0; i < len(merged); {
+		s := merged[i]
+		start := max(0, s.start-(size-(s.end-s.start))/2)
+		end := min(len(runes), start+size)
+		start = max(0, end-size)
+		w := window{start: start, end: end}
+		for i < len(merged) && merged[i].start < end {
+			w.hits++
+			i++
+		}
+		windows = append(windows, w)
+	}
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].hits > windows[j].hits })
+	windows = windows[:min(n, len(windows))]
+	sort.Slice(windows, func(i, j int) bool { return windows[i].start < windows[j].start })
+
+	frags := make([]string, 0, len(windows))
+	for _, w := range windows {
+		var b strings.Builder
+		if w.start > 0 {
+			b.WriteString(simple.DefaultSeparator)
+		}
+		pos := w.start
+		for _, s := range merged {
+			if s.end <= w.start || s.start >= w.end {
+				continue
+			}
+			start, end := max(s.start, w.start), min(s.end, w.end)
+			b.WriteString(string(runes[pos:start]))
+			b.WriteString(hlStartMarker)
+			b.WriteString(string(runes[start:end]))
+			b.WriteString(hlEndMarker)
+			pos = end
+		}
+		b.WriteString(string(runes[pos:w.end]))
+		if w.end < len(runes) {
+			b.WriteString(simple.DefaultSeparator)
+		}
+		frags = append(frags, b.String())
+	}
+	return frags
+}
@@ -0,0 +1,96 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnippetEngine(t *testing.T) {
+	ctx := context.Background()
+	inner, err := New(Config{IndexPath: filepath.Join(t.TempDir(), "test.bleve")}, BuildIndexMapping("", "message.text"))
+	require.NoError(t, err)
+	defer inner.Close()
+
+	body := map[string]string{
+		"c1#1": "Meet at the <b>station</b> tomorrow, call alice@example.com",
+		"c1#2": "nothing here",
+	}
+	for id, text := range body {
+		require.NoError(t, inner.Index(ctx, Doc{ID: id, Type: "message", Fields: map[string]interface{}{
+			"type": "message", "conversation": "c1", "text": text,
+		}}))
+	}
+
+	req := SearchRequest{
+		MustTerms:       map[string][]string{"type": {"message"}},
+		Matches:         []ClauseMatch{{Field: "text", Query: "station"}},
+		MinShould:       1,
+		IncludeFields:   []string{"conversation", "text"},
+		Highlight:       true,
+		HighlightFields: []string{"text"},
+		Size:            10,
+	}
+	// 未存储的字段不返回原文与片段
+	res, err := inner.Search(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 1)
+	assert.NotContains(t, res.Hits[0].Fields, "text")
+	assert.Empty(t, res.Hits[0].Fragments["text"])
+
+	p := NewEnrichPipeline()
+	p.Register("message", StripHTML("text"), MaskPII(nil, "text"))
+	e := NewSnippetEngine(inner, p)
+	var fetched []string
+	e.Register("message", func(_ context.Context, ids []string) (map[string]map[string]string, error) {
+		fetched = append(fetched, ids...)
+		out := make(map[string]map[string]string)
+		for _, id := range ids {
+			out[id] = map[string]string{"text": body[id]}
+		}
+		return out, nil
+	}, "text")
+
+	res, err = e.Search(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, res.Hits, 1)
+	hit := res.Hits[0]
+	assert.Equal(t, []string{"c1#1"}, fetched)
+	assert.Equal(t, "c1", hit.Fields["conversation"])
+	assert.Contains(t, hit.Fields["text"], "Meet at the station")
+	assert.NotContains(t, hit.Fields["text"], "alice@example.com")
+	require.Len(t, hit.Fragments["text"], 1)
+	assert.Contains(t, hit.Fragments["text"][0], "<mark>station</mark>")
+
+	// 不需要高亮也未请求该字段时不回源
+	fetched = nil
+	req.Highlight = false
+	req.IncludeFields = []string{"conversation"}
+	_, err = e.Search(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, fetched)
+}
+
+func TestHighlightText(t *testing.T) {
+	terms := splitTerms("Go 搜索")
+	assert.Equal(t, []string{"go", "搜索"}, terms)
+
+	frags := highlightText("Let's GO to the 搜索引擎 page; gopher", terms, 100, 1)
+	require.Len(t, frags, 1)
+	assert.Equal(t, "Let's "+hlStartMarker+"GO"+hlEndMarker+" to the "+hlStartMarker+"搜索"+hlEndMarker+"引擎 page; gopher", frags[0])
+
+	// 非中日韩词需完整匹配单词
+	assert.Empty(t, highlightText("ago gopher", []string{"go"}, 100, 1))
+
+	// 片段截断处加省略号，按命中数选择片段
+	text := "aaaa go bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb go go cccc"
+	frags = highlightText(text, []string{"go"}, 12, 1)
+	require.Len(t, frags, 1)
+	assert.Contains(t, frags[0], hlStartMarker+"go"+hlEndMarker+" "+hlStartMarker+"go"+hlEndMarker)
+	assert.True(t, strings.HasPrefix(frags[0], "…"))
+	assert.Len(t, highlightText(text, []string{"go"}, 12, 5), 2)
+}
@@ -0,0 +1,250 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2/geo"
+	"github.com/blevesearch/bleve/v2/mapping"
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
+
+// ErrInvalidSort 排序字段不存在、未索引或排序方式与字段类型不符
+var ErrInvalidSort = errors.New("invalid sort")
+
+// 排序方式
+const (
+	SortModeAuto   = "auto"
+	SortModeNumber = "number"
+	SortModeString = "string"
+	SortModeDate   = "date"
+	// SortModeGeo 按与 Origin 的距离排序，字段需映射为 geopoint
+	SortModeGeo = "geo"
+)
+
+// 缺失值位置
+const (
+	SortMissingFirst = "first"
+	SortMissingLast  = "last"
+)
+
+// 内置排序字段
+const (
+	SortFieldScore = "_score"
+	SortFieldID    = "_id"
+)
+
+// SortField 排序描述
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+	// 缺少该字段的文档排在最前或最后，默认 last
+	Missing string `json:"missing,omitempty"`
+	// auto/number/string/date/geo，auto 时按字段映射类型决定
+	Mode string `json:"mode,omitempty"`
+	// 距离排序的原点，设置后按距离排序
+	Origin *GeoPoint `json:"origin,omitempty"`
+	// 距离单位，如 m、km、mi，默认 m
+	Unit string `json:"unit,omitempty"`
+}
+
+// parseSortBy 将旧的 "-field" 字符串形式转换为排序描述
+func parseSortBy(s string) SortField {
+	if strings.HasPrefix(s, "-") {
+		return SortField{Field: s[1:], Desc: true}
+	}
+	return SortField{Field: s}
+}
+
+// sortFields 合并 Sort 与兼容的 SortBy，Sort 优先
+func sortFields(req SearchRequest) []SortField {
+	fields := make([]SortField, 0, len(req.Sort)+len(req.SortBy))
+	fields = append(fields, req.Sort...)
+	for _, s := range req.SortBy {
+		fields = append(fields, parseSortBy(s))
+	}
+	return fields
+}
+
+// normalize 校验取值并填充默认值
+func (s SortField) normalize() (SortField, error) {
+	s.Field = strings.TrimSpace(s.Field)
+	if s.Field == "" {
+		return s, fmt.Errorf("%w: field is required", ErrInvalidSort)
+	}
+	switch s.Missing = strings.ToLower(s.Missing); s.Missing {
+	case "":
+		s.Missing = SortMissingLast
+	case SortMissingFirst, SortMissingLast:
+	default:
+		return s, fmt.Errorf("%w: missing must be %q or %q, got %q", ErrInvalidSort, SortMissingFirst, SortMissingLast, s.Missing)
+	}
+	switch s.Mode = strings.ToLower(s.Mode); s.Mode {
+	case "":
+		s.Mode = SortModeAuto
+	case SortModeAuto, SortModeNumber, SortModeString, SortModeDate, SortModeGeo:
+	default:
+		return s, fmt.Errorf("%w: mode must be one of auto, number, string, date, geo, got %q", ErrInvalidSort, s.Mode)
+	}
+	if s.Origin != nil && s.Mode == SortModeAuto {
+		s.Mode = SortModeGeo
+	}
+	if s.Mode == SortModeGeo {
+		if s.Origin == nil {
+			return s, fmt.Errorf("%w: geo sort on %q requires an origin", ErrInvalidSort, s.Field)
+		}
+		if err := validateGeoPoint(s.Origin.Lat, s.Origin.Lon); err != nil {
+			return s, fmt.Errorf("%w: %v", ErrInvalidSort, err)
+		}
+		if s.Unit = strings.ToLower(strings.TrimSpace(s.Unit)); s.Unit == "" {
+			s.Unit = "m"
+		}
+		if _, err := geo.ParseDistanceUnit(s.Unit); err != nil {
+			return s, fmt.Errorf("%w: %v", ErrInvalidSort, err)
+		}
+	}
+	return s, nil
+}
+
+// sortModeForType 字段映射类型对应的排序方式
+func sortModeForType(fieldType string) string {
+	switch fieldType {
+	case "number":
+		return SortModeNumber
+	case "datetime":
+		return SortModeDate
+	case "text", "keyword":
+		return SortModeString
+	case "geopoint":
+		return SortModeGeo
+	}
+	return ""
+}
+
+// resolveSort 按索引映射校验排序字段，返回确定排序方式后的描述；m 为空时只校验取值
+func resolveSort(m mapping.IndexMapping, fields []SortField) ([]SortField, error) {
+	out := make([]SortField, 0, len(fields))
+	impl, _ := m.(*mapping.IndexMappingImpl)
+	for _, f := range fields {
+		f, err := f.normalize()
+		if err != nil {
+			return nil, err
+		}
+		if f.Field == SortFieldScore || f.Field == SortFieldID || impl == nil {
+			out = append(out, f)
+			continue
+		}
+
+		fm := impl.FieldMappingForPath(f.Field)
+		if fm.Type == "" {
+			if mappingIsDynamic(impl) {
+				// 动态映射下无法预知字段类型，交给 bleve 自动判断
+				out = append(out, f)
+				continue
+			}
+			return nil, fmt.Errorf("%w: field %q is not in the index mapping, sortable fields: %s",
+				ErrInvalidSort, f.Field, strings.Join(sortableFields(impl), ", "))
+		}
+		if !fm.Index {
+			return nil, fmt.Errorf("%w: field %q is stored but not indexed and cannot be sorted", ErrInvalidSort, f.Field)
+		}
+		mode := sortModeForType(fm.Type)
+		if mode == "" {
+			return nil, fmt.Errorf("%w: field %q of type %s cannot be sorted", ErrInvalidSort, f.Field, fm.Type)
+		}
+		if f.Mode == SortModeAuto && mode == SortModeGeo {
+			return nil, fmt.Errorf("%w: geo sort on %q requires an origin", ErrInvalidSort, f.Field)
+		} else if f.Mode == SortModeAuto {
+			f.Mode = mode
+		} else if f.Mode != mode {
+			return nil, fmt.Errorf("%w: field %q is a %s field and cannot be sorted as %s", ErrInvalidSort, f.Field, fm.Type, f.Mode)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// mappingIsDynamic 默认映射或任一类型映射开启动态字段
+func mappingIsDynamic(m *mapping.IndexMappingImpl) bool {
+	if m.DefaultMapping != nil && m.DefaultMapping.Enabled && m.DefaultMapping.Dynamic {
+		return true
+	}
+	for _, dm := range m.TypeMapping {
+		if dm.Enabled && dm.Dynamic {
+			return true
+		}
+	}
+	return false
+}
+
+// sortableFields 列出映射中可排序的字段，用于错误提示
+func sortableFields(m *mapping.IndexMappingImpl) []string {
+	seen := map[string]bool{SortFieldScore: true, SortFieldID: true}
+	var collect func(prefix string, dm *mapping.DocumentMapping)
+	collect = func(prefix string, dm *mapping.DocumentMapping) {
+		if dm == nil {
+			return
+		}
+		for name, sub := range dm.Properties {
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			for _, fm := range sub.Fields {
+				if fm.Index && sortModeForType(fm.Type) != "" {
+					seen[path] = true
+				}
+			}
+			collect(path, sub)
+		}
+	}
+	collect("", m.DefaultMapping)
+	for _, dm := range m.TypeMapping {
+		collect("", dm)
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toBleveSort 转换为 bleve 排序，距离排序不支持缺失值位置，无坐标的文档视为无穷远
+func toBleveSort(fields []SortField) (bsearch.SortOrder, error) {
+	order := make(bsearch.SortOrder, 0, len(fields))
+	for _, f := range fields {
+		switch f.Field {
+		case SortFieldScore:
+			order = append(order, &bsearch.SortScore{Desc: f.Desc})
+			continue
+		case SortFieldID:
+			order = append(order, &bsearch.SortDocID{Desc: f.Desc})
+			continue
+		}
+		if f.Mode == SortModeGeo {
+			gs, err := bsearch.NewSortGeoDistance(f.Field, f.Unit, f.Origin.Lon, f.Origin.Lat, f.Desc)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidSort, err)
+			}
+			order = append(order, gs)
+			continue
+		}
+		sf := &bsearch.SortField{Field: f.Field, Desc: f.Desc, Missing: bsearch.SortFieldMissingLast}
+		if f.Missing == SortMissingFirst {
+			sf.Missing = bsearch.SortFieldMissingFirst
+		}
+		switch f.Mode {
+		case SortModeNumber:
+			sf.Type = bsearch.SortFieldAsNumber
+		case SortModeDate:
+			sf.Type = bsearch.SortFieldAsDate
+		case SortModeString:
+			sf.Type = bsearch.SortFieldAsString
+		}
+		order = append(order, sf)
+	}
+	return order, nil
+}
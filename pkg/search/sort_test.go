@@ -0,0 +1,59 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hitIDs(res SearchResult) []string {
+	ids := make([]string, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestSearchSortMissing(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		{ID: "1", Type: "article", Fields: map[string]any{"title": "post a", "views": 10}},
+		{ID: "2", Type: "article", Fields: map[string]any{"title": "post b", "views": 30}},
+		{ID: "3", Type: "article", Fields: map[string]any{"title": "post c"}},
+	}))
+
+	res, err := e.Search(ctx, SearchRequest{Keyword: "post", SearchFields: []string{"title"}, Sort: []SortField{{Field: "views", Desc: true}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "1", "3"}, hitIDs(res))
+
+	res, err = e.Search(ctx, SearchRequest{Keyword: "post", SearchFields: []string{"title"}, Sort: []SortField{{Field: "views", Missing: SortMissingFirst, Mode: SortModeNumber}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3", "1", "2"}, hitIDs(res))
+
+	// 兼容旧的 SortBy 写法
+	res, err = e.Search(ctx, SearchRequest{Keyword: "post", SearchFields: []string{"title"}, SortBy: []string{"-views"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2", "1", "3"}, hitIDs(res))
+}
+
+func TestSearchSortValidation(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+
+	_, err := e.Search(ctx, SearchRequest{Sort: []SortField{{Field: "price"}}})
+	require.ErrorIs(t, err, ErrInvalidSort)
+	assert.Contains(t, err.Error(), "views")
+	assert.Contains(t, err.Error(), "createdAt")
+
+	_, err = e.Search(ctx, SearchRequest{Sort: []SortField{{Field: "views", Mode: SortModeDate}}})
+	require.ErrorIs(t, err, ErrInvalidSort)
+
+	_, err = e.Search(ctx, SearchRequest{Sort: []SortField{{Field: "views", Missing: "middle"}}})
+	require.ErrorIs(t, err, ErrInvalidSort)
+
+	_, err = e.Search(ctx, SearchRequest{Sort: []SortField{{Field: SortFieldScore, Desc: true}, {Field: "author"}}})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"HibiscusIM/pkg/cache"
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldSuggest 输入联想使用的前缀字段，取值为 SuggestKeys 生成的小写词，需映射为关键词字段
+const FieldSuggest = "suggest"
+
+const (
+	// defaultTypeaheadLimit 默认返回的联想条数
+	defaultTypeaheadLimit = 10
+	// maxTypeaheadLimit 联想条数上限
+	maxTypeaheadLimit = 50
+	// defaultHotPrefixLen 默认缓存的前缀长度（字符数）上限
+	defaultHotPrefixLen = 3
+)
+
+// SuggestKeys 生成输入联想的前缀键：每个值整体及按空白切分的每个词，统一小写并去重，
+// 使 "Alice Smith" 既能被 "ali" 也能被 "smi" 匹配
+func SuggestKeys(values ...string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	add := func(k string) {
+		if k != "" && !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for _, v := range values {
+		words := strings.Fields(strings.ToLower(v))
+		add(strings.Join(words, " "))
+		for _, word := range words {
+			add(word)
+		}
+	}
+	return keys
+}
+
+// TypeaheadConfig 输入联想配置
+type TypeaheadConfig struct {
+	// 热门前缀结果缓存，为 nil 时不缓存
+	Cache cache.Cache
+	// 缓存时长，<=0 时使用 30 秒；写入不经过联想，只能依赖过期刷新
+	TTL time.Duration
+	// 不超过该长度的前缀视为热门前缀并缓存，<=0 时使用 3。短前缀命中面广、重复率高，
+	// 长前缀命中少，直接查询引擎即可
+	HotPrefixLen int
+}
+
+// Typeahead 基于 FieldSuggest 前缀字段的输入联想
+type Typeahead struct {
+	engine Engine
+	cached *CachedEngine
+	hotLen int
+}
+
+// NewTypeahead 创建输入联想
+func NewTypeahead(engine Engine, cfg TypeaheadConfig) *Typeahead {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 30 * time.Second
+	}
+	if cfg.HotPrefixLen <= 0 {
+		cfg.HotPrefixLen = defaultHotPrefixLen
+	}
+	t := &Typeahead{engine: engine, hotLen: cfg.HotPrefixLen}
+	if cfg.Cache != nil {
+		t.cached = NewCachedEngine(engine, cfg.Cache, CacheConfig{TTL: cfg.TTL, Prefix: "typeahead:"})
+	}
+	return t
+}
+
+// TypeaheadRequest 输入联想请求
+type TypeaheadRequest struct {
+	// 文档类型，如 user、group
+	Type   string
+	Prefix string
+	// 返回条数，默认 10，上限 50
+	Limit int
+	// 返回的存储字段
+	IncludeFields []string
+	// 可见范围，由服务端按当前用户设置
+	Access *AccessFilter
+}
+
+// Suggest 返回前缀匹配的文档，前缀为空时不查询
+func (t *Typeahead) Suggest(ctx context.Context, req TypeaheadRequest) ([]Hit, error) {
+	prefix := strings.ToLower(strings.TrimSpace(req.Prefix))
+	if prefix == "" {
+		return []Hit{}, nil
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultTypeaheadLimit
+	}
+	limit = min(limit, maxTypeaheadLimit)
+
+	sr := SearchRequest{
+		MustTerms:     map[string][]string{"type": {req.Type}},
+		Prefixes:      []ClausePrefix{{Field: FieldSuggest, Prefix: prefix}},
+		MinShould:     1,
+		Size:          limit,
+		IncludeFields: req.IncludeFields,
+		Access:        req.Access,
+	}
+
+	// 短前缀命中面广、重复率高，结果按前缀与可见范围缓存
+	engine := t.engine
+	if t.cached != nil && utf8.RuneCountInString(prefix) <= t.hotLen {
+		engine = t.cached
+	}
+	res, err := engine.Search(ctx, sr)
+	if err != nil {
+		return nil, err
+	}
+	if res.Hits == nil {
+		return []Hit{}, nil
+	}
+	return res.Hits, nil
+}
+
+// CacheStats 热门前缀缓存的命中统计，未启用缓存时为零值
+func (t *Typeahead) CacheStats() CacheStats {
+	if t.cached == nil {
+		return CacheStats{}
+	}
+	return t.cached.Stats()
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"HibiscusIM/pkg/cache"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggestKeys(t *testing.T) {
+	assert.Equal(t, []string{"alice smith", "alice", "smith", "al"}, SuggestKeys(" Alice  Smith", "AL", ""))
+}
+
+func TestTypeahead(t *testing.T) {
+	e := newTestEngine(t)
+	ctx := context.Background()
+	user := func(id, name string) Doc {
+		return Doc{ID: "user:" + id, Type: "user", Fields: map[string]interface{}{
+			"name":       name,
+			"userId":     id,
+			FieldSuggest: SuggestKeys(name),
+		}}
+	}
+	require.NoError(t, e.IndexBatch(ctx, []Doc{
+		user("1", "Alice Smith"),
+		user("2", "Alan Turing"),
+		user("3", "Bob Allen"),
+		{ID: "group:1", Type: "group", Fields: map[string]interface{}{"name": "Alpha", "groupId": "1", FieldSuggest: SuggestKeys("Alpha")}},
+	}))
+
+	ta := NewTypeahead(e, TypeaheadConfig{Cache: cache.NewLocalCache(cache.LocalConfig{
+		MaxSize:           100,
+		DefaultExpiration: time.Minute,
+		CleanupInterval:   time.Minute,
+	})})
+	ids := func(hits []Hit) []string {
+		out := make([]string, 0, len(hits))
+		for _, h := range hits {
+			out = append(out, h.ID)
+		}
+		return out
+	}
+
+	// 按任意词的前缀匹配，只返回指定类型
+	hits, err := ta.Suggest(ctx, TypeaheadRequest{Type: "user", Prefix: "Al"})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1", "user:2", "user:3"}, ids(hits))
+
+	// 可见范围之外的文档不返回
+	hits, err = ta.Suggest(ctx, TypeaheadRequest{Type: "user", Prefix: "al", Access: &AccessFilter{Field: "userId", Principals: []string{"1", "3"}}})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"user:1", "user:3"}, ids(hits))
+
+	hits, err = ta.Suggest(ctx, TypeaheadRequest{Type: "user", Prefix: "al", Access: &AccessFilter{Field: "userId"}})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	hits, err = ta.Suggest(ctx, TypeaheadRequest{Type: "user", Prefix: "turi", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:2"}, ids(hits))
+
+	hits, err = ta.Suggest(ctx, TypeaheadRequest{Type: "user", Prefix: "  "})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	// 短前缀走缓存，长前缀直接查询
+	_, err = ta.Suggest(ctx, TypeaheadRequest{Type: "user", Prefix: "al"})
+	require.NoError(t, err)
+	stats := ta.CacheStats()
+	assert.EqualValues(t, 1, stats.Hits)
+	assert.EqualValues(t, 3, stats.Misses)
+}
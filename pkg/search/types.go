@@ -1,19 +1,52 @@
 package search
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	bsearch "github.com/blevesearch/bleve/v2/search"
+)
 
 type Config struct {
-	IndexPath           string
+	// 引擎驱动：bleve（默认）、elasticsearch、opensearch
+	Driver    string
+	IndexPath string
+	// 命名索引所在目录，设置后 bleve 驱动启用多索引（见 IndexManager），IndexPath 作为默认索引
+	IndexDir string
+	// 通过 IndexManager.Open 新建命名索引的数量上限，0 时使用 DefaultMaxIndexes
+	MaxIndexes          int
 	DefaultAnalyzer     string
 	DefaultSearchFields []string
 	OpenTimeout         time.Duration
 	QueryTimeout        time.Duration
 	BatchSize           int
+	// Driver 为 elasticsearch/opensearch 时使用
+	Elasticsearch ElasticsearchConfig
+	// 查询时的同义词扩展与停用词过滤，为空时不处理
+	Dictionaries *Dictionaries
+}
+
+// ElasticsearchConfig Elasticsearch/OpenSearch 连接配置
+type ElasticsearchConfig struct {
+	// 节点地址，多个时轮询
+	Addresses []string
+	// 索引名
+	Index    string
+	Username string
+	Password string
+	// 优先于用户名密码
+	APIKey string
+	// 写入后的刷新策略：""、"true"、"wait_for"
+	Refresh string
+	// 索引不存在时创建使用的 settings/mappings，为空时使用动态映射
+	IndexBody json.RawMessage
 }
 
 type Doc struct {
-	ID     string                 `json:"id"`
-	Type   string                 `json:"type"`
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// 写入的命名索引，需已通过 IndexManager.Open 创建，为空时写入默认索引，仅多索引模式生效
+	Index  string                 `json:"index,omitempty"`
 	Fields map[string]interface{} `json:"fields"` // 使用 interface{} 来处理任何类型的数据
 }
 
@@ -32,6 +65,27 @@ type TimeRangeFilter struct {
 	IncTo   bool
 }
 
+// GeoPoint 经纬度坐标
+type GeoPoint struct {
+	Lat float64
+	Lon float64
+}
+
+// GeoDistanceFilter 距离圆心不超过 Distance 的文档，Distance 带单位，如 "500m"、"5km"，不带单位时为米
+type GeoDistanceFilter struct {
+	Field    string
+	Lat      float64
+	Lon      float64
+	Distance string
+}
+
+// GeoBoundingBoxFilter 落在矩形范围内的文档
+type GeoBoundingBoxFilter struct {
+	Field       string
+	TopLeft     GeoPoint
+	BottomRight GeoPoint
+}
+
 // -------- 高级搜索子句（新增） --------
 type ClauseMatch struct { // 单字段 Match / 可带权重
 	Field    string
@@ -88,6 +142,9 @@ type FacetRequest struct {
 }
 
 type SearchRequest struct {
+	// 查询的命名索引，多个以逗号分隔，AllIndexes 表示全部，为空时查询默认索引；仅多索引模式生效
+	Index string `json:",omitempty"`
+
 	// 关键字（保留老接口）
 	Keyword      string
 	SearchFields []string
@@ -101,6 +158,10 @@ type SearchRequest struct {
 	NumericRanges []NumericRangeFilter
 	TimeRanges    []TimeRangeFilter
 
+	// 地理位置过滤，字段需映射为 geopoint
+	GeoDistances     []GeoDistanceFilter    `json:",omitempty"`
+	GeoBoundingBoxes []GeoBoundingBoxFilter `json:",omitempty"`
+
 	// 高级查询子句（新增）
 	QueryString *ClauseQueryString
 	Matches     []ClauseMatch
@@ -116,23 +177,39 @@ type SearchRequest struct {
 	// Facet 聚合
 	Facets []FacetRequest
 
-	// 排序与分页
+	// 排序与分页，SortBy 为兼容的 "-field" 简写，追加在 Sort 之后
+	Sort   []SortField
 	SortBy []string
 	From   int
 	Size   int
+	// 上一页最后一条命中的 Hit.Sort，设置后忽略 From，需与排序字段一一对应；深分页请使用 Scroll
+	SearchAfter []string `json:",omitempty"`
 
 	// 字段返回与高亮
 	IncludeFields   []string
 	Highlight       bool
 	HighlightFields []string // 指定需要高亮的字段，默认全部 text 字段
-	FragmentSize    int      // 片段长度
-	MaxFragments    int      // 每字段片段数
+	FragmentSize    int      // 片段长度，默认 200，上限 1000
+	MaxFragments    int      // 每字段片段数，默认 1，上限 10
+	HighlightStyle  string   `json:",omitempty"` // 高亮样式：html（默认，转义正文）或 plain（纯文本）
+	PreTag          string   `json:",omitempty"` // 命中词前置标签，默认 html 为 <mark>、plain 为空
+	PostTag         string   `json:",omitempty"` // 命中词后置标签
+
+	// 调试：返回每个命中的评分解释及编译后的查询结构（仅管理员可用）
+	Explain bool
+
+	// 文档级访问控制，由服务端按当前用户设置，不接受客户端传入
+	Access *AccessFilter `json:"-"`
 }
 type Hit struct {
 	ID        string
 	Score     float64
 	Fields    map[string]any
 	Fragments map[string][]string
+	// 评分解释，仅在 Explain 时返回
+	Explanation *bsearch.Explanation `json:",omitempty"`
+	// 排序值，用作 SearchAfter
+	Sort []string `json:",omitempty"`
 }
 type FacetTerm struct {
 	Term  string
@@ -147,4 +224,6 @@ type SearchResult struct {
 	Took   time.Duration
 	Hits   []Hit
 	Facets map[string]FacetResult
+	// 编译后的查询结构，仅在 Explain 时返回
+	Query json.RawMessage `json:",omitempty"`
 }
@@ -107,6 +107,13 @@ func (h *Hub) SendToGroup(group, data string) {
 	h.mu.RUnlock()
 }
 
+// GroupSize 返回分组中的客户端数
+func (h *Hub) GroupSize(group string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.groups[group])
+}
+
 func (h *Hub) sendAll(msg string) {
 	h.mu.RLock()
 	for _, c := range h.clients {
@@ -121,6 +128,11 @@ func (h *Hub) sendAll(msg string) {
 func formatData(s string) string { return fmt.Sprintf("data: %s\n\n", s) }
 
 func (h *Hub) Serve(c *gin.Context, clientID string) {
+	h.ServeWithGroups(c, clientID)
+}
+
+// ServeWithGroups 建立SSE连接并加入指定分组
+func (h *Hub) ServeWithGroups(c *gin.Context, clientID string, groups ...string) {
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
@@ -132,6 +144,9 @@ func (h *Hub) Serve(c *gin.Context, clientID string) {
 	if gid := c.Query("group"); gid != "" {
 		h.Join(clientID, gid)
 	}
+	for _, g := range groups {
+		h.Join(clientID, g)
+	}
 
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"time"
 
 	"github.com/tencentyun/cos-go-sdk-v5"
 )
@@ -99,3 +100,13 @@ func InitCos(c *CosStore) *cos.Client {
 	})
 	return cClient
 }
+
+// PresignPut implements Store.
+func (o *CosStore) PresignPut(key string, ttl time.Duration, policy UploadPolicy) (*PresignedUpload, error) {
+	return nil, ErrPresignNotSupported
+}
+
+// PresignGet implements Store.
+func (o *CosStore) PresignGet(key string, ttl time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 var UploadDir string = "/tmp"
@@ -89,3 +90,13 @@ func NewLocalStore() Store {
 	}
 	return s
 }
+
+// PresignPut implements Store.
+func (l *LocalStore) PresignPut(key string, ttl time.Duration, policy UploadPolicy) (*PresignedUpload, error) {
+	return nil, ErrPresignNotSupported
+}
+
+// PresignGet implements Store.
+func (l *LocalStore) PresignGet(key string, ttl time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
@@ -2,10 +2,12 @@ package stores
 
 import (
 	"HibiscusIM/pkg/util"
+	"bufio"
 	"context"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
@@ -18,6 +20,10 @@ type MinioStore struct {
 	Bucket    string `env:"MINIO_BUCKET"`
 	UseSSL    bool   `env:"MINIO_USE_SSL"`
 	BaseURL   string `env:"MINIO_PUBLIC_BASE"` // 对外访问域名，可选
+	Region    string `env:"MINIO_REGION"`      // 可选，S3 需要
+
+	// 不为空时代替 AccessKey/SecretKey，用于 S3 的凭证链
+	creds *credentials.Credentials
 }
 
 func NewMinioStore() Store {
@@ -29,13 +35,19 @@ func NewMinioStore() Store {
 		Bucket:    util.GetEnv("MINIO_BUCKET"),
 		UseSSL:    useSSL,
 		BaseURL:   util.GetEnv("MINIO_PUBLIC_BASE"),
+		Region:    util.GetEnv("MINIO_REGION"),
 	}
 }
 
 func (m *MinioStore) client() (*minio.Client, error) {
+	creds := m.creds
+	if creds == nil {
+		creds = credentials.NewStaticV4(m.AccessKey, m.SecretKey, "")
+	}
 	return minio.New(m.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(m.AccessKey, m.SecretKey, ""),
+		Creds:  creds,
 		Secure: m.UseSSL,
+		Region: m.Region,
 	})
 }
 
@@ -45,7 +57,7 @@ func (m *MinioStore) ensureBucket(ctx context.Context, cli *minio.Client) error
 		return err
 	}
 	if !exists {
-		return cli.MakeBucket(ctx, m.Bucket, minio.MakeBucketOptions{})
+		return cli.MakeBucket(ctx, m.Bucket, minio.MakeBucketOptions{Region: m.Region})
 	}
 	return nil
 }
@@ -74,7 +86,10 @@ func (m *MinioStore) Write(key string, r io.Reader) error {
 	if err := m.ensureBucket(context.Background(), cli); err != nil {
 		return err
 	}
-	_, err = cli.PutObject(context.Background(), m.Bucket, key, r, -1, minio.PutObjectOptions{ContentType: http.DetectContentType([]byte{})})
+	// 按内容识别类型，浏览器直接访问 PublicURL 时才能正确展示图片等文件
+	br := bufio.NewReaderSize(r, 512)
+	head, _ := br.Peek(512)
+	_, err = cli.PutObject(context.Background(), m.Bucket, key, br, -1, minio.PutObjectOptions{ContentType: http.DetectContentType(head)})
 	return err
 }
 
@@ -112,3 +127,50 @@ func (m *MinioStore) PublicURL(key string) string {
 	}
 	return scheme + m.Endpoint + "/" + m.Bucket + "/" + key
 }
+
+// PresignGet implements Store.
+func (m *MinioStore) PresignGet(key string, ttl time.Duration) (string, error) {
+	cli, err := m.client()
+	if err != nil {
+		return "", err
+	}
+	u, err := cli.PresignedGetObject(context.Background(), m.Bucket, key, ttl, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// PresignPut implements Store.
+func (m *MinioStore) PresignPut(key string, ttl time.Duration, policy UploadPolicy) (*PresignedUpload, error) {
+	cli, err := m.client()
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(ttl)
+	p := minio.NewPostPolicy()
+	if err := p.SetBucket(m.Bucket); err != nil {
+		return nil, err
+	}
+	if err := p.SetKey(key); err != nil {
+		return nil, err
+	}
+	if err := p.SetExpires(expires.UTC()); err != nil {
+		return nil, err
+	}
+	if policy.ContentType != "" {
+		if err := p.SetContentType(policy.ContentType); err != nil {
+			return nil, err
+		}
+	}
+	if policy.MaxSize > 0 {
+		if err := p.SetContentLengthRange(1, policy.MaxSize); err != nil {
+			return nil, err
+		}
+	}
+	u, fields, err := cli.PresignedPostPolicy(context.Background(), p)
+	if err != nil {
+		return nil, err
+	}
+	return &PresignedUpload{URL: u.String(), Method: http.MethodPost, Fields: fields, Key: key, ExpiresAt: expires}, nil
+}
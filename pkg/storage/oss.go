@@ -1,6 +1,9 @@
 package stores
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 type OssStore struct {
 }
@@ -32,3 +35,13 @@ func (o *OssStore) PublicURL(key string) string {
 func NewOssStore() Store {
 	return &OssStore{}
 }
+
+// PresignPut implements Store.
+func (o *OssStore) PresignPut(key string, ttl time.Duration, policy UploadPolicy) (*PresignedUpload, error) {
+	return nil, ErrPresignNotSupported
+}
+
+// PresignGet implements Store.
+func (o *OssStore) PresignGet(key string, ttl time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
@@ -0,0 +1,52 @@
+package stores
+
+import (
+	"HibiscusIM/pkg/util"
+	"strings"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const defaultS3Region = "us-east-1"
+
+// S3Store AWS S3 存储，通过 S3 兼容协议复用 MinIO 客户端。
+// 未配置 S3_ACCESS_KEY_ID 时依次使用 AWS_* 环境变量、~/.aws/credentials 与 EC2/ECS 实例角色
+type S3Store struct {
+	*MinioStore
+}
+
+func NewS3Store() Store {
+	region := util.GetEnv("S3_REGION")
+	if region == "" {
+		region = defaultS3Region
+	}
+	endpoint := util.GetEnv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	m := &MinioStore{
+		Endpoint:  endpoint,
+		AccessKey: util.GetEnv("S3_ACCESS_KEY_ID"),
+		SecretKey: util.GetEnv("S3_SECRET_ACCESS_KEY"),
+		Bucket:    util.GetEnv("S3_BUCKET"),
+		UseSSL:    true,
+		BaseURL:   util.GetEnv("S3_PUBLIC_BASE"),
+		Region:    region,
+	}
+	if m.AccessKey == "" {
+		m.creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+	return &S3Store{MinioStore: m}
+}
+
+// PublicURL 未配置 S3_PUBLIC_BASE（如 CloudFront 域名）时使用虚拟主机风格的桶地址，需桶策略允许公开读取
+func (s *S3Store) PublicURL(key string) string {
+	if s.BaseURL != "" || s.Endpoint != "s3.amazonaws.com" {
+		return s.MinioStore.PublicURL(key)
+	}
+	return "https://" + s.Bucket + ".s3." + s.Region + ".amazonaws.com/" + strings.TrimLeft(key, "/")
+}
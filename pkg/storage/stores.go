@@ -2,8 +2,12 @@ package stores
 
 import (
 	"HibiscusIM/pkg/util"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -11,18 +15,47 @@ const (
 	KindOss   = "oss"   // aliyun
 	KindCos   = "cos"   // tencent
 	KindMinio = "minio" // minio/s3 compatible
+	KindS3    = "s3"    // aws s3
 )
 
 var ErrInvalidPath = &util.Error{Code: http.StatusBadRequest, Message: "invalid path"}
 
 var DefaultStoreKind = KindLocal
 
+var (
+	defaultMu    sync.RWMutex
+	defaultStore Store
+)
+
+var ErrPresignNotSupported = &util.Error{Code: http.StatusNotImplemented, Message: "presigned url not supported by storage"}
+
+// UploadPolicy 直传的约束，写入签名，客户端无法修改
+type UploadPolicy struct {
+	ContentType string // 为空时不限制
+	MaxSize     int64  // 字节，不大于 0 时不限制
+}
+
+// PresignedUpload 直传签名结果。客户端以 multipart/form-data 向 URL 发送 POST 请求，
+// 先按原样附带 Fields 中的全部字段，最后是文件字段 file；Content-Type 需与 policy 一致
+type PresignedUpload struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Fields    map[string]string `json:"fields"`
+	Key       string            `json:"key"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
 type Store interface {
 	Read(key string) (io.ReadCloser, int64, error)
 	Write(key string, r io.Reader) error
 	Delete(key string) error
 	Exists(key string) (bool, error)
 	PublicURL(key string) string
+	// PresignPut 生成有效期为 ttl 的直传签名，客户端不经过服务端直接上传到 key；
+	// 使用 POST 表单策略而不是 PUT 地址，才能在签名中限制文件大小
+	PresignPut(key string, ttl time.Duration, policy UploadPolicy) (*PresignedUpload, error)
+	// PresignGet 生成有效期为 ttl 的下载地址，私有桶的文件可通过该地址直接下载
+	PresignGet(key string, ttl time.Duration) (string, error)
 }
 
 func GetStore(kind string) Store {
@@ -33,11 +66,63 @@ func GetStore(kind string) Store {
 		return NewCosStore()
 	case KindMinio:
 		return NewMinioStore()
+	case KindS3:
+		return NewS3Store()
 	default:
 		return NewLocalStore()
 	}
 }
 
+// NewStoreFromEnv 按 STORAGE_DRIVER 创建存储，未配置时使用 DefaultStoreKind，
+// 驱动未知或缺少必需配置时返回错误
+func NewStoreFromEnv() (Store, error) {
+	kind := strings.ToLower(strings.TrimSpace(util.GetEnv("STORAGE_DRIVER")))
+	if kind == "" {
+		kind = DefaultStoreKind
+	}
+	var missing []string
+	switch kind {
+	case KindLocal:
+	case KindMinio:
+		missing = missingEnv("MINIO_ENDPOINT", "MINIO_BUCKET")
+	case KindS3:
+		missing = missingEnv("S3_BUCKET")
+	case KindCos:
+		missing = missingEnv("BUCKET_NAME", "REGION")
+	case KindOss:
+		return nil, fmt.Errorf("storage driver %q is not implemented", kind)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", kind)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("storage driver %q requires %s", kind, strings.Join(missing, ", "))
+	}
+	return GetStore(kind), nil
+}
+
+func missingEnv(keys ...string) []string {
+	var missing []string
+	for _, k := range keys {
+		if util.GetEnv(k) == "" {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
+
+// SetDefault 设置 Default 返回的存储，启动时使用 NewStoreFromEnv 的结果
+func SetDefault(s Store) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultStore = s
+}
+
 func Default() Store {
+	defaultMu.RLock()
+	s := defaultStore
+	defaultMu.RUnlock()
+	if s != nil {
+		return s
+	}
 	return GetStore(DefaultStoreKind)
 }
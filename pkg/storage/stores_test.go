@@ -0,0 +1,69 @@
+package stores
+
+import (
+	"HibiscusIM/pkg/util"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setEnv 通过覆盖值设置配置，绕过环境变量缓存
+func setEnv(t *testing.T, key, value string) {
+	util.SetEnvOverride(key, value)
+	t.Cleanup(func() { util.SetEnvOverride(key, "") })
+}
+
+func TestNewStoreFromEnv(t *testing.T) {
+	setEnv(t, "STORAGE_DRIVER", "")
+	s, err := NewStoreFromEnv()
+	require.NoError(t, err)
+	assert.IsType(t, &LocalStore{}, s)
+
+	setEnv(t, "STORAGE_DRIVER", "S3")
+	setEnv(t, "S3_BUCKET", "")
+	_, err = NewStoreFromEnv()
+	assert.ErrorContains(t, err, "S3_BUCKET")
+
+	setEnv(t, "S3_BUCKET", "media")
+	setEnv(t, "S3_REGION", "eu-west-1")
+	setEnv(t, "S3_ACCESS_KEY_ID", "AKID")
+	setEnv(t, "S3_SECRET_ACCESS_KEY", "secret")
+	s, err = NewStoreFromEnv()
+	require.NoError(t, err)
+	require.IsType(t, &S3Store{}, s)
+	assert.Equal(t, "https://media.s3.eu-west-1.amazonaws.com/a/b.png", s.PublicURL("a/b.png"))
+
+	// 预签名在本地计算，不访问 S3
+	u, err := s.PresignGet("a/b.png", time.Minute)
+	require.NoError(t, err)
+	assert.Contains(t, u, "X-Amz-Expires=60")
+	assert.Contains(t, u, "/a/b.png")
+
+	up, err := s.PresignPut("rec/1.wav", time.Minute, UploadPolicy{ContentType: "audio/wav", MaxSize: 1 << 20})
+	require.NoError(t, err)
+	assert.Contains(t, up.URL, "media.s3")
+	assert.Equal(t, "POST", up.Method)
+	assert.Equal(t, "rec/1.wav", up.Fields["key"])
+	assert.Equal(t, "audio/wav", up.Fields["Content-Type"])
+	policy, err := base64.StdEncoding.DecodeString(up.Fields["policy"])
+	require.NoError(t, err)
+	assert.Contains(t, string(policy), `["content-length-range", 1, 1048576]`)
+
+	_, err = (&LocalStore{}).PresignPut("rec/1.wav", time.Minute, UploadPolicy{})
+	assert.ErrorIs(t, err, ErrPresignNotSupported)
+
+	setEnv(t, "STORAGE_DRIVER", "ftp")
+	_, err = NewStoreFromEnv()
+	assert.Error(t, err)
+}
+
+func TestSetDefault(t *testing.T) {
+	defer SetDefault(nil)
+	assert.IsType(t, &LocalStore{}, Default())
+	s := &LocalStore{Root: t.TempDir()}
+	SetDefault(s)
+	assert.Same(t, s, Default())
+}
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,6 +28,51 @@ type Config struct {
 var configValueCache *ExpiredLRUCache[string, string]
 var envCache *ExpiredLRUCache[string, string]
 
+// 分层配置：覆盖值优先于 .env 与环境变量（如命令行参数），默认值在都未设置时生效（如内置默认值、YAML 配置文件）
+var (
+	envLayersMu  sync.RWMutex
+	envOverrides = map[string]string{}
+	envDefaults  = map[string]string{}
+)
+
+// SetEnvOverride 设置最高优先级的配置覆盖值
+func SetEnvOverride(key, value string) {
+	key = strings.ToUpper(key)
+	envLayersMu.Lock()
+	envOverrides[key] = value
+	envLayersMu.Unlock()
+	if envCache != nil {
+		envCache.Remove(key)
+	}
+}
+
+// SetEnvDefault 设置最低优先级的配置默认值
+func SetEnvDefault(key, value string) {
+	key = strings.ToUpper(key)
+	envLayersMu.Lock()
+	envDefaults[key] = value
+	envLayersMu.Unlock()
+	if envCache != nil {
+		envCache.Remove(key)
+	}
+}
+
+// LookupEnvOverride 查询覆盖值
+func LookupEnvOverride(key string) (string, bool) {
+	envLayersMu.RLock()
+	defer envLayersMu.RUnlock()
+	v, ok := envOverrides[strings.ToUpper(key)]
+	return v, ok
+}
+
+// LookupEnvDefault 查询默认值
+func LookupEnvDefault(key string) (string, bool) {
+	envLayersMu.RLock()
+	defer envLayersMu.RUnlock()
+	v, ok := envDefaults[strings.ToUpper(key)]
+	return v, ok
+}
+
 func init() {
 	size := 1024 // fixed size
 	v, _ := strconv.ParseInt(GetEnv(constants.ENV_CONFIG_CACHE_SIZE), 10, 32)
@@ -61,6 +107,9 @@ func GetIntEnv(key string) int64 {
 
 func LookupEnv(key string) (value string, found bool) {
 	key = strings.ToUpper(key)
+	if value, found = LookupEnvOverride(key); found {
+		return
+	}
 	if envCache != nil {
 		if value, found = envCache.Get(key); found {
 			return
@@ -72,6 +121,16 @@ func LookupEnv(key string) (value string, found bool) {
 			envCache.Add(key, value)
 		}
 	}()
+	if value, found = LookupRawEnv(key); found {
+		return
+	}
+	value, found = LookupEnvDefault(key)
+	return
+}
+
+// LookupRawEnv 仅从 .env 文件与进程环境变量中查找，不包含覆盖值与默认值
+func LookupRawEnv(key string) (value string, found bool) {
+	key = strings.ToUpper(key)
 	// Check .env file
 	//
 	data, err := os.ReadFile(".env")
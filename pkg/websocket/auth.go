@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// 握手认证相关错误
+var (
+	ErrMissingToken     = errors.New("missing token")
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrOriginNotAllowed = errors.New("origin not allowed")
+)
+
+// Authenticator 在 WebSocket 升级前校验令牌并返回用户ID
+type Authenticator interface {
+	Authenticate(r *http.Request, token string) (string, error)
+}
+
+// AuthenticatorFunc 函数形式的 Authenticator
+type AuthenticatorFunc func(r *http.Request, token string) (string, error)
+
+// Authenticate 实现 Authenticator
+func (f AuthenticatorFunc) Authenticate(r *http.Request, token string) (string, error) {
+	return f(r, token)
+}
+
+// ChainAuthenticators 依次尝试多个认证器，返回第一个成功的结果，全部失败时返回最后一个错误
+func ChainAuthenticators(auths ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request, token string) (string, error) {
+		err := ErrInvalidToken
+		for _, a := range auths {
+			if a == nil {
+				continue
+			}
+			var userID string
+			if userID, err = a.Authenticate(r, token); err == nil {
+				return userID, nil
+			}
+		}
+		return "", err
+	})
+}
+
+// JWTAuthenticator 校验 HS256 签名的 JWT，用户ID取自 sub 声明
+type JWTAuthenticator struct {
+	secret []byte
+	// Issuer 非空时要求 iss 声明一致
+	Issuer string
+	// Leeway 校验 exp/nbf 时允许的时钟偏差
+	Leeway time.Duration
+	now    func() time.Time
+}
+
+// NewJWTAuthenticator 创建 JWT 认证器
+func NewJWTAuthenticator(secret, issuer string) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		secret: []byte(secret),
+		Issuer: issuer,
+		Leeway: 30 * time.Second,
+		now:    time.Now,
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaims struct {
+	Sub json.RawMessage `json:"sub"`
+	Iss string          `json:"iss"`
+	Exp *int64          `json:"exp"`
+	Nbf *int64          `json:"nbf"`
+}
+
+// Authenticate 实现 Authenticator
+func (a *JWTAuthenticator) Authenticate(_ *http.Request, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return "", ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", ErrInvalidToken
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", ErrInvalidToken
+	}
+	now := a.now()
+	if claims.Exp != nil && now.After(time.Unix(*claims.Exp, 0).Add(a.Leeway)) {
+		return "", ErrTokenExpired
+	}
+	if claims.Nbf != nil && now.Add(a.Leeway).Before(time.Unix(*claims.Nbf, 0)) {
+		return "", ErrInvalidToken
+	}
+	if a.Issuer != "" && claims.Iss != a.Issuer {
+		return "", ErrInvalidToken
+	}
+
+	// sub 兼容字符串与数字
+	var sub interface{}
+	if err := json.Unmarshal(claims.Sub, &sub); err != nil {
+		return "", ErrInvalidToken
+	}
+	var userID string
+	switch v := sub.(type) {
+	case string:
+		userID = v
+	case float64:
+		userID = fmt.Sprintf("%.0f", v)
+	}
+	if userID == "" {
+		return "", ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// SignJWT 生成 HS256 签名的 JWT，供测试与内部服务签发连接令牌
+func SignJWT(secret string, claims map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signing))
+	return signing + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// extractToken 从握手请求中提取令牌，依次支持：
+// Authorization: Bearer <token>、?token= / ?access_token= 查询参数、
+// Sec-WebSocket-Protocol: access_token, <token>（浏览器无法自定义请求头时使用）。
+// 令牌来自子协议时返回需要回显的子协议名
+func extractToken(r *http.Request) (token, subprotocol string) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if t := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")); t != "" {
+			return t, ""
+		}
+	}
+	q := r.URL.Query()
+	for _, key := range []string{"token", "access_token"} {
+		if t := q.Get(key); t != "" {
+			return t, ""
+		}
+	}
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == AuthSubprotocol {
+			return protocols[i+1], AuthSubprotocol
+		}
+	}
+	return "", ""
+}
+
+// originAllowed 校验 Origin：未配置白名单时全部放行（兼容旧部署），
+// 白名单支持 "*"、完整 origin 以及 "*.example.com" 形式的子域名通配
+func originAllowed(cfg *Config, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(cfg.AllowedOrigins) == 0 {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		allowed = strings.TrimSpace(allowed)
+		switch {
+		case allowed == "*":
+			return true
+		case strings.EqualFold(allowed, origin):
+			return true
+		case strings.HasPrefix(allowed, "*."):
+			if strings.HasSuffix(strings.ToLower(u.Hostname()), strings.ToLower(allowed[1:])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rejectUpgrade 完成升级后立即以指定关闭码断开，
+// 浏览器拿不到握手失败的 HTTP 状态码，通过关闭码才能区分未认证与来源受限
+func rejectUpgrade(cfg *Config, w http.ResponseWriter, r *http.Request, code int, reason string) {
+	up := newUpgrader(cfg)
+	// 不读取任何数据即关闭，放行 Origin 不会带来跨站劫持风险
+	up.CheckOrigin = func(*http.Request) bool { return true }
+	var header http.Header
+	if _, sub := extractToken(r); sub != "" {
+		header = http.Header{"Sec-WebSocket-Protocol": []string{sub}}
+	}
+	conn, err := up.Upgrade(w, r, header)
+	if err != nil {
+		logrus.Warnf("WebSocket拒绝连接失败: %v", err)
+		return
+	}
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	conn.Close()
+}
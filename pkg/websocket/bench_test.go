@@ -0,0 +1,205 @@
+package websocket
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// quietLogs 压测期间关闭注册、丢弃等日志输出
+func quietLogs(b *testing.B) {
+	level := logrus.GetLevel()
+	logrus.SetLevel(logrus.ErrorLevel)
+	b.Cleanup(func() { logrus.SetLevel(level) })
+}
+
+// benchHub 注册 n 个加入 group 的连接，codecs 按顺序轮流分配，并启动协程持续消费发送队列
+func benchHub(b *testing.B, n int, group string, codecs ...Codec) (*Hub, *atomic.Int64) {
+	b.Helper()
+	quietLogs(b)
+	cfg := DefaultConfig()
+	cfg.ShardCount = 16
+	cfg.BroadcastWorkerCount = 8
+	// 不丢弃，保证每条消息都被计入
+	cfg.DropOnFull = false
+	cfg.SendTimeout = time.Second
+	hub := NewHub(cfg)
+
+	var received atomic.Int64
+	conns := make([]*Connection, 0, n)
+	for i := 0; i < n; i++ {
+		conn := &Connection{
+			ID:       fmt.Sprintf("bench_%d", i),
+			UserID:   fmt.Sprintf("user_%d", i),
+			Send:     make(chan []byte, 256),
+			Hub:      hub,
+			IsAlive:  true,
+			Groups:   map[string]bool{group: true},
+			Metadata: make(map[string]interface{}),
+		}
+		if len(codecs) > 0 {
+			conn.codec = codecs[i%len(codecs)]
+		}
+		hub.registerConnection(conn)
+		conns = append(conns, conn)
+		go func(c *Connection) {
+			for range c.Send {
+				received.Add(1)
+			}
+		}(conn)
+	}
+	b.Cleanup(func() {
+		for _, c := range conns {
+			hub.unregisterConnection(c)
+		}
+		hub.Close()
+	})
+	return hub, &received
+}
+
+// waitReceived 等待所有连接收到预期数量的消息
+func waitReceived(b *testing.B, received *atomic.Int64, want int64) {
+	deadline := time.Now().Add(30 * time.Second)
+	for received.Load() < want {
+		if time.Now().After(deadline) {
+			b.Fatalf("received %d of %d messages", received.Load(), want)
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+func benchMessage() *Message {
+	return &Message{
+		Type:      MessageTypeChat,
+		Group:     "room",
+		From:      "user_0",
+		Timestamp: time.Now().Unix(),
+		Data:      map[string]interface{}{"text": "hello everyone, this is a broadcast benchmark", "seq": 1},
+	}
+}
+
+func BenchmarkHubGroupFanout(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		for _, mix := range []struct {
+			name   string
+			codecs []Codec
+		}{
+			{"json", nil},
+			{"mixed", []Codec{JSONCodec, NewMsgpackCodec(), ProtobufCodec}},
+		} {
+			b.Run(fmt.Sprintf("conns=%d/%s", n, mix.name), func(b *testing.B) {
+				hub, received := benchHub(b, n, "room", mix.codecs...)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					em, err := newEncodedMessage(benchMessage())
+					if err != nil {
+						b.Fatal(err)
+					}
+					hub.mu.RLock()
+					hub.sendToGroup("room", em)
+					hub.mu.RUnlock()
+				}
+				waitReceived(b, received, int64(b.N*n))
+			})
+		}
+	}
+}
+
+func BenchmarkHubBroadcastAll(b *testing.B) {
+	for _, n := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("conns=%d", n), func(b *testing.B) {
+			hub, received := benchHub(b, n, "room")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				em, err := newEncodedMessage(benchMessage())
+				if err != nil {
+					b.Fatal(err)
+				}
+				// 作业队列将满时等待消费，避免测到丢弃路径
+				for len(hub.broadcastJobs) > cap(hub.broadcastJobs)-hub.shardCount {
+					runtime.Gosched()
+				}
+				hub.enqueueBroadcastAll(em)
+			}
+			waitReceived(b, received, int64(b.N*n))
+		})
+	}
+}
+
+func BenchmarkTrySend(b *testing.B) {
+	quietLogs(b)
+	em, err := newEncodedMessage(benchMessage())
+	if err != nil {
+		b.Fatal(err)
+	}
+	onDrop := func() {}
+
+	b.Run("delivered", func(b *testing.B) {
+		hub := NewHub(DefaultConfig())
+		defer hub.Close()
+		conn := &Connection{ID: "c", Send: make(chan []byte, 1)}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hub.trySend(conn, em, onDrop)
+			<-conn.Send
+		}
+	})
+
+	b.Run("drop_on_full", func(b *testing.B) {
+		cfg := DefaultConfig()
+		cfg.DropOnFull = true
+		hub := NewHub(cfg)
+		defer hub.Close()
+		conn := &Connection{ID: "c", UserID: "u", Send: make(chan []byte)}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hub.trySend(conn, em, func() {
+				hub.recordDrop(DropReasonSendBufferFull, DeadLetterTargetConnection, conn.ID, conn.UserID, em.json)
+			})
+		}
+	})
+
+	b.Run("timeout", func(b *testing.B) {
+		cfg := DefaultConfig()
+		cfg.DropOnFull = false
+		cfg.SendTimeout = time.Microsecond
+		hub := NewHub(cfg)
+		defer hub.Close()
+		conn := &Connection{ID: "c", Send: make(chan []byte)}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hub.trySend(conn, em, onDrop)
+		}
+	})
+
+	b.Run("msgpack", func(b *testing.B) {
+		hub := NewHub(DefaultConfig())
+		defer hub.Close()
+		conn := &Connection{ID: "c", Send: make(chan []byte, 1), codec: NewMsgpackCodec()}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			hub.trySend(conn, em, onDrop)
+			<-conn.Send
+		}
+	})
+}
+
+func BenchmarkCodecMarshal(b *testing.B) {
+	msg := benchMessage()
+	for _, c := range []Codec{JSONCodec, NewMsgpackCodec(), ProtobufCodec} {
+		b.Run(c.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Marshal(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
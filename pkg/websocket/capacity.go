@@ -0,0 +1,314 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 写入系统监控时间序列的 Hub 指标名
+const (
+	MetricWSConnections  = "ws.connections"
+	MetricWSMessagesIn   = "ws.messages_in_per_sec"
+	MetricWSMessagesOut  = "ws.messages_out_per_sec"
+	MetricWSDropsPerSec  = "ws.drops_per_sec"
+	defaultStatsInterval = 10 * time.Second
+)
+
+// StatsMetrics 导出与容量规划使用的全部 Hub 指标
+var StatsMetrics = []string{MetricWSConnections, MetricWSMessagesIn, MetricWSMessagesOut, MetricWSDropsPerSec}
+
+const (
+	// 容量规划时单节点的目标使用率，留出突发余量
+	capacityTargetUsage = 0.7
+	// 使用率达到该比例时视为需要扩容
+	capacityWarningUsage = 0.8
+	// 每个分片建议承载的连接数
+	connectionsPerShard = 5000
+	// 拟合增长趋势至少需要的采样数
+	minCapacitySamples = 2
+	// 历史导出每批写出的采样点数
+	statsExportChunk = 500
+)
+
+// hubCounters Hub 启动以来的累计收发与丢弃计数
+type hubCounters struct {
+	messagesIn  atomic.Int64
+	messagesOut atomic.Int64
+	drops       atomic.Int64
+	violations  atomic.Int64
+}
+
+// HubCounters 累计计数快照
+type HubCounters struct {
+	Connections int64 `json:"connections"`
+	MessagesIn  int64 `json:"messages_in"`
+	MessagesOut int64 `json:"messages_out"`
+	Drops       int64 `json:"drops"`
+	Violations  int64 `json:"violations"`
+}
+
+// Counters 返回当前连接数与累计收发、丢弃计数
+func (h *Hub) Counters() HubCounters {
+	return HubCounters{
+		Connections: h.GetConnectionCount(),
+		MessagesIn:  h.counters.messagesIn.Load(),
+		MessagesOut: h.counters.messagesOut.Load(),
+		Drops:       h.counters.drops.Load(),
+		Violations:  h.counters.violations.Load(),
+	}
+}
+
+// GaugeRecorder 接收 Hub 指标的时间序列存储，metrics.SystemMonitor 实现了该接口
+type GaugeRecorder interface {
+	SetGauge(name string, value float64)
+}
+
+// StatsPoint 指标历史中的一个采样点
+type StatsPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// StatsHistory 按指标名读取 since 之后的历史采样，按时间升序
+type StatsHistory func(metric string, since time.Time) []StatsPoint
+
+// RecordStats 每隔 interval 把连接数与收发、丢弃速率写入 rec，直到 Hub 关闭
+func (h *Hub) RecordStats(rec GaugeRecorder, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStatsInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last, lastAt := h.Counters(), time.Now()
+		rec.SetGauge(MetricWSConnections, float64(last.Connections))
+		for {
+			select {
+			case <-h.ctx.Done():
+				return
+			case now := <-ticker.C:
+				cur := h.Counters()
+				elapsed := now.Sub(lastAt).Seconds()
+				rec.SetGauge(MetricWSConnections, float64(cur.Connections))
+				if elapsed > 0 {
+					rec.SetGauge(MetricWSMessagesIn, float64(cur.MessagesIn-last.MessagesIn)/elapsed)
+					rec.SetGauge(MetricWSMessagesOut, float64(cur.MessagesOut-last.MessagesOut)/elapsed)
+					rec.SetGauge(MetricWSDropsPerSec, float64(cur.Drops-last.Drops)/elapsed)
+				}
+				last, lastAt = cur, now
+			}
+		}
+	}()
+}
+
+// CapacityReport 按历史连接数拟合增长趋势，预测何时触及 MaxConnections 并给出分片与节点数建议
+type CapacityReport struct {
+	Connections    int64   `json:"connections"`
+	MaxConnections int64   `json:"max_connections"`
+	Usage          float64 `json:"usage"`
+	Samples        int     `json:"samples"`
+	// 采样窗口内的峰值连接数
+	Peak float64 `json:"peak"`
+	// 线性拟合得到的每日连接增长，样本不足时为 0
+	GrowthPerDay float64 `json:"growth_per_day"`
+	// 预测周期末的峰值连接数
+	Horizon       string  `json:"horizon"`
+	ProjectedPeak float64 `json:"projected_peak"`
+	// 按当前趋势达到告警使用率与上限的天数，不增长或已超过时为空
+	DaysToWarning *float64 `json:"days_to_warning,omitempty"`
+	DaysToLimit   *float64 `json:"days_to_limit,omitempty"`
+	// 当前与建议的分片数、节点数
+	ShardCount        int `json:"shard_count"`
+	RecommendedShards int `json:"recommended_shards"`
+	RecommendedNodes  int `json:"recommended_nodes"`
+}
+
+// PlanCapacity 根据连接数历史生成容量报告，samples 需按时间升序
+func PlanCapacity(samples []StatsPoint, current, maxConnections int64, shardCount int, horizon time.Duration) CapacityReport {
+	report := CapacityReport{
+		Connections:    current,
+		MaxConnections: maxConnections,
+		Samples:        len(samples),
+		Peak:           float64(current),
+		Horizon:        horizon.String(),
+		ShardCount:     shardCount,
+	}
+	if maxConnections > 0 {
+		report.Usage = float64(current) / float64(maxConnections)
+	}
+	for _, p := range samples {
+		report.Peak = math.Max(report.Peak, p.Value)
+	}
+	if len(samples) >= minCapacitySamples {
+		report.GrowthPerDay = linearSlope(samples) * float64(24*time.Hour/time.Second)
+	}
+
+	report.ProjectedPeak = report.Peak
+	if report.GrowthPerDay > 0 {
+		report.ProjectedPeak += report.GrowthPerDay * horizon.Hours() / 24
+		if maxConnections > 0 {
+			report.DaysToWarning = daysUntil(float64(current), capacityWarningUsage*float64(maxConnections), report.GrowthPerDay)
+			report.DaysToLimit = daysUntil(float64(current), float64(maxConnections), report.GrowthPerDay)
+		}
+	}
+
+	report.RecommendedNodes = 1
+	if maxConnections > 0 {
+		report.RecommendedNodes = max(1, int(math.Ceil(report.ProjectedPeak/(capacityTargetUsage*float64(maxConnections)))))
+	}
+	perNode := report.ProjectedPeak / float64(report.RecommendedNodes)
+	report.RecommendedShards = max(shardCount, nextPowerOfTwo(int(math.Ceil(perNode/connectionsPerShard))))
+	return report
+}
+
+// linearSlope 最小二乘拟合每秒的变化量
+func linearSlope(samples []StatsPoint) float64 {
+	t0 := samples[0].Timestamp
+	var sumX, sumY, sumXY, sumXX float64
+	for _, p := range samples {
+		x := p.Timestamp.Sub(t0).Seconds()
+		sumX += x
+		sumY += p.Value
+		sumXY += x * p.Value
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+func daysUntil(current, target, growthPerDay float64) *float64 {
+	if current >= target {
+		return nil
+	}
+	days := (target - current) / growthPerDay
+	return &days
+}
+
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// SetStatsHistory 设置 Hub 指标历史来源，未设置时容量报告与历史导出不可用
+func (h *Handler) SetStatsHistory(history StatsHistory) {
+	h.statsHistory = history
+}
+
+// parseSince 解析相对时长（如 24h）或 RFC3339 时间，为空时返回 def 之前
+func parseSince(v string, def time.Duration) (time.Time, error) {
+	if v == "" {
+		return time.Now().Add(-def), nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// GetCapacity 容量规划报告，window 为参与拟合的历史窗口（默认 24h），horizon 为预测周期（默认 720h）
+func (h *Handler) GetCapacity(c *gin.Context) {
+	if h.statsHistory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "未启用连接统计历史"})
+		return
+	}
+	since, err := parseSince(c.Query("window"), 24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 window"})
+		return
+	}
+	horizon := 30 * 24 * time.Hour
+	if v := c.Query("horizon"); v != "" {
+		if horizon, err = time.ParseDuration(v); err != nil || horizon <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 horizon"})
+			return
+		}
+	}
+	report := PlanCapacity(h.statsHistory(MetricWSConnections, since), h.hub.GetConnectionCount(),
+		h.hub.config.MaxConnections, h.hub.config.ShardCount, horizon)
+	c.JSON(http.StatusOK, gin.H{
+		"node":     h.hub.nodeID(),
+		"region":   h.hub.Region(),
+		"since":    since,
+		"capacity": report,
+		"counters": h.hub.Counters(),
+	})
+}
+
+// ExportStatsHistory 分块导出 Hub 指标历史，format 为 ndjson（默认）或 csv，
+// metric 可重复指定，默认导出全部 Hub 指标；since 默认 24h
+func (h *Handler) ExportStatsHistory(c *gin.Context) {
+	if h.statsHistory == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "未启用连接统计历史"})
+		return
+	}
+	since, err := parseSince(c.Query("since"), 24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 since"})
+		return
+	}
+	metrics := c.QueryArray("metric")
+	if len(metrics) == 0 {
+		metrics = StatsMetrics
+	}
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 仅支持 ndjson 或 csv"})
+		return
+	}
+
+	filename := fmt.Sprintf("ws-stats-%s-%s.%s", h.hub.nodeID(), time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		_, _ = c.Writer.WriteString("metric,timestamp,value\n")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+	}
+	c.Status(http.StatusOK)
+
+	written := 0
+	for _, metric := range metrics {
+		for _, p := range h.statsHistory(metric, since) {
+			if err := writeStatsPoint(c, format, metric, p); err != nil {
+				return
+			}
+			if written++; written%statsExportChunk == 0 {
+				c.Writer.Flush()
+				if c.Request.Context().Err() != nil {
+					return
+				}
+			}
+		}
+	}
+	c.Writer.Flush()
+}
+
+func writeStatsPoint(c *gin.Context, format, metric string, p StatsPoint) error {
+	if format == "csv" {
+		_, err := fmt.Fprintf(c.Writer, "%s,%s,%s\n", metric, p.Timestamp.Format(time.RFC3339), strconv.FormatFloat(p.Value, 'f', -1, 64))
+		return err
+	}
+	data, err := json.Marshal(struct {
+		Metric string `json:"metric"`
+		StatsPoint
+	}{metric, p})
+	if err != nil {
+		return err
+	}
+	_, err = c.Writer.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,182 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+)
+
+// 分片与压缩的默认值
+const (
+	DefaultMaxChunkedMessageSize = 1 << 20
+	DefaultChunkTimeout          = 30 * time.Second
+	DefaultCompressionThreshold  = 256
+	// 握手时声明支持下行分片的查询参数，值为客户端可接收的最大帧字节数，1 表示与 MaxMessageSize 相同
+	QueryParamChunked = "chunked"
+
+	// 单个连接同时在重组的消息数
+	maxPendingChunks = 4
+	// 单条消息最多的分片数
+	maxChunkCount = 4096
+	// chunk 消息除 payload 外的字段预留的字节数
+	chunkEnvelopeOverhead = 160
+	// 每片至少携带的原始字节数，避免帧上限过小时切出大量分片
+	minChunkPayload = 64
+)
+
+// MessageChunk chunk 消息的数据。发送方把按连接编码后超过帧上限的消息切成 total 片，payload 为原始字节
+// （JSON 中为 base64），接收方按 index 拼接全部分片后按同一编码解析出原消息
+type MessageChunk struct {
+	ID      string `json:"id"`
+	Index   int    `json:"index"`
+	Total   int    `json:"total"`
+	Payload []byte `json:"payload"`
+}
+
+// chunkAssembly 一条上行分片消息的重组状态
+type chunkAssembly struct {
+	parts     [][]byte
+	received  int
+	size      int
+	frameType int
+	started   time.Time
+}
+
+// negotiateChunkLimit 解析客户端声明的下行帧上限，未声明时返回 0 表示不拆分
+func negotiateChunkLimit(cfg *Config, r *http.Request) int {
+	v := r.URL.Query().Get(QueryParamChunked)
+	if v == "" {
+		return 0
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 1 {
+		return cfg.MaxMessageSize
+	}
+	return max(limit, chunkEnvelopeOverhead+minChunkPayload)
+}
+
+// offersDeflate 客户端是否在握手时提供了 permessage-deflate 扩展
+func offersDeflate(r *http.Request) bool {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, ext := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(ext, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setWriteCompression 协商了压缩时只压缩不小于阈值的消息，小消息压缩收益低于开销
+func (c *Connection) setWriteCompression(size int) {
+	if c.compress {
+		c.Conn.EnableWriteCompression(size >= c.Hub.config.CompressionThreshold)
+	}
+}
+
+// outboundFrames 客户端支持分片且消息超过其帧上限时拆成 chunk 消息，否则原样返回
+func (c *Connection) outboundFrames(data []byte) [][]byte {
+	if c.chunkLimit <= 0 || len(data) <= c.chunkLimit {
+		return [][]byte{data}
+	}
+	size := max((c.chunkLimit-chunkEnvelopeOverhead)*3/4, minChunkPayload)
+	total := (len(data) + size - 1) / size
+	c.chunkSeq++
+	id := fmt.Sprintf("%s_%d", c.ID, c.chunkSeq)
+	frames := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		end := min((i+1)*size, len(data))
+		out, err := c.encode(&Message{
+			Type: MessageTypeChunk,
+			Data: MessageChunk{ID: id, Index: i, Total: total, Payload: data[i*size : end]},
+		})
+		if err != nil {
+			logrus.Errorf("分片消息序列化失败: %v", err)
+			return nil
+		}
+		frames = append(frames, out)
+	}
+	return frames
+}
+
+// handleChunk 重组上行分片消息，收齐后按原消息处理。重组状态只在读协程中访问
+func (c *Connection) handleChunk(frameType int, msg Message) {
+	limit := c.Hub.config.MaxChunkedMessageSize
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok || limit <= 0 {
+		c.sendError("", ErrInvalidChunk)
+		return
+	}
+	chunk := MessageChunk{
+		ID:    cast.ToString(data["id"]),
+		Index: cast.ToInt(data["index"]),
+		Total: cast.ToInt(data["total"]),
+	}
+	payload, err := base64.StdEncoding.DecodeString(cast.ToString(data["payload"]))
+	if err != nil || chunk.ID == "" || chunk.Total <= 0 || chunk.Total > maxChunkCount || chunk.Index < 0 || chunk.Index >= chunk.Total {
+		c.sendError("", ErrInvalidChunk)
+		return
+	}
+
+	now := time.Now()
+	c.expireChunks(now)
+	if c.chunks == nil {
+		c.chunks = make(map[string]*chunkAssembly)
+	}
+	a := c.chunks[chunk.ID]
+	if a == nil {
+		if len(c.chunks) >= maxPendingChunks {
+			c.sendError("", ErrInvalidChunk)
+			return
+		}
+		a = &chunkAssembly{parts: make([][]byte, chunk.Total), frameType: frameType, started: now}
+		c.chunks[chunk.ID] = a
+	}
+	if len(a.parts) != chunk.Total {
+		delete(c.chunks, chunk.ID)
+		c.sendError("", ErrInvalidChunk)
+		return
+	}
+	if a.parts[chunk.Index] == nil {
+		a.parts[chunk.Index] = payload
+		a.received++
+		a.size += len(payload)
+	}
+	if a.size > limit {
+		delete(c.chunks, chunk.ID)
+		c.sendError("", ErrChunkedMessageTooLarge)
+		return
+	}
+	if a.received < chunk.Total {
+		return
+	}
+	delete(c.chunks, chunk.ID)
+
+	var inner Message
+	if err := c.decode(a.frameType, bytes.Join(a.parts, nil), &inner); err != nil || inner.Type == MessageTypeChunk {
+		c.sendError("", ErrInvalidChunk)
+		return
+	}
+	c.dispatch(a.frameType, inner)
+}
+
+// expireChunks 丢弃超时未收齐的分片消息
+func (c *Connection) expireChunks(now time.Time) {
+	timeout := c.Hub.config.ChunkTimeout
+	if timeout <= 0 {
+		timeout = DefaultChunkTimeout
+	}
+	for id, a := range c.chunks {
+		if now.Sub(a.started) > timeout {
+			delete(c.chunks, id)
+		}
+	}
+}
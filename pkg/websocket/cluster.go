@@ -0,0 +1,435 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultClusterChannel 节点间转发消息的默认频道
+	DefaultClusterChannel = "ws:cluster"
+	// clusterHeartbeatInterval 节点心跳间隔，超过 clusterPeerTimeoutFactor 个间隔未收到心跳的节点视为下线
+	clusterHeartbeatInterval = 10 * time.Second
+	clusterPeerTimeoutFactor = 3
+	// clusterOutboundSize 待转发消息缓冲区大小，满时丢弃并计数
+	clusterOutboundSize = 4096
+	// clusterDedupSize 记录最近收到的消息ID数量，用于过滤重复投递
+	clusterDedupSize = 8192
+)
+
+// 集群信封类型
+const (
+	clusterKindMessage   = "message"
+	clusterKindHeartbeat = "heartbeat"
+	clusterKindLeave     = "leave"
+)
+
+var clusterEventsCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "websocket_cluster_events_total",
+		Help: "WebSocket cluster bridge events by node and kind (published, received, echo, duplicate, publish_error, dropped)",
+	},
+	[]string{"node", "event"},
+)
+
+var clusterPeersGauge = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "websocket_cluster_peers",
+		Help: "Number of live peer nodes seen by this WebSocket hub",
+	},
+	[]string{"node"},
+)
+
+// ErrClusterAlreadyStarted Hub 已经连接了集群传输
+var ErrClusterAlreadyStarted = errors.New("websocket: cluster transport already started")
+
+// ClusterTransport 节点间转发消息的发布订阅通道
+type ClusterTransport interface {
+	// Publish 向频道发布消息
+	Publish(ctx context.Context, channel string, payload []byte) error
+
+	// Subscribe 订阅频道，ctx 取消后返回的通道关闭
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+}
+
+// redisClusterTransport 基于 Redis pub/sub 的集群传输
+type redisClusterTransport struct {
+	client *redis.Client
+}
+
+// NewRedisClusterTransport 创建基于 Redis pub/sub 的集群传输
+func NewRedisClusterTransport(client *redis.Client) ClusterTransport {
+	return &redisClusterTransport{client: client}
+}
+
+// Publish 发布消息
+func (t *redisClusterTransport) Publish(ctx context.Context, channel string, payload []byte) error {
+	return t.client.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe 订阅频道，连接断开时由 go-redis 自动重连
+func (t *redisClusterTransport) Subscribe(ctx context.Context, channel string) (<-chan []byte, error) {
+	pubsub := t.client.Subscribe(ctx, channel)
+	// 等待订阅确认，确保返回后不会漏掉消息
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	out := make(chan []byte, 256)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- []byte(msg.Payload):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// clusterEnvelope 节点间传递的信封，Message 为原消息的 JSON
+type clusterEnvelope struct {
+	Node        string          `json:"node"`
+	ID          string          `json:"id"`
+	Kind        string          `json:"kind"`
+	Region      string          `json:"region,omitempty"`
+	Connections int64           `json:"connections,omitempty"`
+	Message     json.RawMessage `json:"message,omitempty"`
+	SentAt      int64           `json:"sent_at"`
+}
+
+// ClusterPeer 通过心跳发现的其他节点
+type ClusterPeer struct {
+	Node        string    `json:"node"`
+	Region      string    `json:"region,omitempty"`
+	Connections int64     `json:"connections"`
+	Received    int64     `json:"received"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// ClusterStats 本节点的集群转发统计
+type ClusterStats struct {
+	Node    string `json:"node"`
+	Channel string `json:"channel"`
+	// 发布到其他节点的消息数
+	Published int64 `json:"published"`
+	// 从其他节点收到并投递的消息数
+	Received int64 `json:"received"`
+	// 忽略的本节点回显与重复投递
+	Echoes     int64 `json:"echoes"`
+	Duplicates int64 `json:"duplicates"`
+	// 发布失败与转发缓冲区满丢弃的消息数
+	PublishErrors int64         `json:"publish_errors"`
+	Dropped       int64         `json:"dropped"`
+	Peers         []ClusterPeer `json:"peers"`
+}
+
+// clusterBridge 把本节点的广播、单用户与组消息发布到集群频道，并把其他节点的消息注入本地 Hub 投递
+type clusterBridge struct {
+	hub       *Hub
+	transport ClusterTransport
+	channel   string
+	node      string
+	seq       atomic.Uint64
+	out       chan []byte
+	// 停止订阅与转发，Hub 关闭时随之取消
+	cancel context.CancelFunc
+
+	published     atomic.Int64
+	received      atomic.Int64
+	echoes        atomic.Int64
+	duplicates    atomic.Int64
+	publishErrors atomic.Int64
+	dropped       atomic.Int64
+
+	mu    sync.Mutex
+	peers map[string]*ClusterPeer
+	seen  map[string]struct{}
+	ring  []string
+	pos   int
+}
+
+// StartCluster 连接集群传输：订阅频道、开始发送心跳并转发本节点的消息，Hub 关闭时停止。
+// 在线状态、输入状态、已读回执等只发给本节点连接的临时通知不跨节点转发
+func (h *Hub) StartCluster(t ClusterTransport) error {
+	channel := h.config.ClusterChannel
+	if channel == "" {
+		channel = DefaultClusterChannel
+	}
+	ctx, cancel := context.WithCancel(h.ctx)
+	b := &clusterBridge{
+		hub:       h,
+		transport: t,
+		channel:   channel,
+		node:      h.clusterNodeID(),
+		out:       make(chan []byte, clusterOutboundSize),
+		cancel:    cancel,
+		peers:     make(map[string]*ClusterPeer),
+		seen:      make(map[string]struct{}, clusterDedupSize),
+		ring:      make([]string, clusterDedupSize),
+	}
+	if !h.cluster.CompareAndSwap(nil, b) {
+		cancel()
+		return ErrClusterAlreadyStarted
+	}
+	in, err := t.Subscribe(ctx, channel)
+	if err != nil {
+		h.cluster.Store(nil)
+		cancel()
+		return err
+	}
+	go b.receiveLoop(in)
+	go b.publishLoop(ctx)
+	logrus.Infof("WebSocket集群已启用: node=%s channel=%s", b.node, channel)
+	return nil
+}
+
+// ClusterStats 返回集群转发统计，未启用集群时返回 nil
+func (h *Hub) ClusterStats() *ClusterStats {
+	b := h.cluster.Load()
+	if b == nil {
+		return nil
+	}
+	return b.stats()
+}
+
+// StopCluster 停止集群转发并通知其他节点本节点下线，之后的消息只在本节点投递
+func (h *Hub) StopCluster() {
+	if b := h.cluster.Swap(nil); b != nil {
+		b.cancel()
+	}
+}
+
+// clusterNodeID 集群中的节点ID，未配置 ClusterNodeID 时使用主机名加随机后缀，避免多个节点互相视为回显
+func (h *Hub) clusterNodeID() string {
+	if h.config.ClusterNodeID != "" {
+		return h.config.ClusterNodeID
+	}
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return host + "-" + hex.EncodeToString(buf)
+}
+
+// forward 把本地产生的消息放入转发缓冲区，缓冲区满时丢弃，不阻塞 Hub 主循环
+func (b *clusterBridge) forward(em *encodedMessage) {
+	env := clusterEnvelope{
+		Node:    b.node,
+		ID:      b.node + ":" + strconv.FormatUint(b.seq.Add(1), 10),
+		Kind:    clusterKindMessage,
+		Message: em.json,
+		SentAt:  time.Now().UnixMilli(),
+	}
+	payload, err := json.Marshal(env)
+	if err != nil {
+		logrus.Errorf("集群消息序列化失败: %v", err)
+		return
+	}
+	select {
+	case b.out <- payload:
+	default:
+		b.dropped.Add(1)
+		clusterEventsCounter.WithLabelValues(b.node, "dropped").Inc()
+	}
+}
+
+// publishLoop 依次发布待转发消息并定时发送心跳，退出时通知其他节点本节点下线
+func (b *clusterBridge) publishLoop(ctx context.Context) {
+	ticker := time.NewTicker(clusterHeartbeatInterval)
+	defer ticker.Stop()
+	b.heartbeat(ctx, clusterKindHeartbeat)
+	for {
+		select {
+		case <-ctx.Done():
+			leaveCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+			b.heartbeat(leaveCtx, clusterKindLeave)
+			cancel()
+			return
+		case payload := <-b.out:
+			if err := b.transport.Publish(ctx, b.channel, payload); err != nil {
+				b.publishErrors.Add(1)
+				clusterEventsCounter.WithLabelValues(b.node, "publish_error").Inc()
+				logrus.Warnf("集群消息发布失败: %v", err)
+				continue
+			}
+			b.published.Add(1)
+			clusterEventsCounter.WithLabelValues(b.node, "published").Inc()
+		case <-ticker.C:
+			b.heartbeat(ctx, clusterKindHeartbeat)
+			b.expirePeers(time.Now())
+		}
+	}
+}
+
+// heartbeat 发布节点注册心跳或下线通知
+func (b *clusterBridge) heartbeat(ctx context.Context, kind string) {
+	payload, _ := json.Marshal(clusterEnvelope{
+		Node:        b.node,
+		Kind:        kind,
+		Region:      b.hub.config.Region,
+		Connections: b.hub.GetConnectionCount(),
+		SentAt:      time.Now().UnixMilli(),
+	})
+	if err := b.transport.Publish(ctx, b.channel, payload); err != nil && ctx.Err() == nil {
+		logrus.Warnf("集群心跳发布失败: %v", err)
+	}
+}
+
+// receiveLoop 处理其他节点发来的信封，订阅通道关闭时退出
+func (b *clusterBridge) receiveLoop(in <-chan []byte) {
+	for payload := range in {
+		b.handle(payload, time.Now())
+	}
+}
+
+func (b *clusterBridge) handle(payload []byte, now time.Time) {
+	var env clusterEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		logrus.Warnf("集群消息解析失败: %v", err)
+		return
+	}
+	if env.Node == b.node {
+		if env.Kind == clusterKindMessage {
+			b.echoes.Add(1)
+			clusterEventsCounter.WithLabelValues(b.node, "echo").Inc()
+		}
+		return
+	}
+
+	switch env.Kind {
+	case clusterKindHeartbeat:
+		b.touchPeer(env, now)
+	case clusterKindLeave:
+		b.mu.Lock()
+		delete(b.peers, env.Node)
+		clusterPeersGauge.WithLabelValues(b.node).Set(float64(len(b.peers)))
+		b.mu.Unlock()
+	case clusterKindMessage:
+		if b.markSeen(env.ID) {
+			b.duplicates.Add(1)
+			clusterEventsCounter.WithLabelValues(b.node, "duplicate").Inc()
+			return
+		}
+		var msg Message
+		if err := json.Unmarshal(env.Message, &msg); err != nil {
+			logrus.Warnf("集群消息解析失败: %v", err)
+			return
+		}
+		msg.remote = true
+		b.received.Add(1)
+		clusterEventsCounter.WithLabelValues(b.node, "received").Inc()
+		b.mu.Lock()
+		if p, ok := b.peers[env.Node]; ok {
+			p.Received++
+			p.LastSeen = now
+		}
+		b.mu.Unlock()
+		select {
+		case b.hub.broadcast <- &msg:
+		default:
+			// 单用户消息可以重放，广播与组消息只计数
+			if msg.To != "" {
+				b.hub.recordDrop(DropReasonBroadcastQueueFull, DeadLetterTargetUser, msg.To, msg.To, env.Message)
+			} else {
+				b.dropped.Add(1)
+				clusterEventsCounter.WithLabelValues(b.node, "dropped").Inc()
+			}
+		}
+	}
+}
+
+// markSeen 记录消息ID，已经见过时返回 true；只保留最近 clusterDedupSize 个ID
+func (b *clusterBridge) markSeen(id string) bool {
+	if id == "" {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.seen[id]; ok {
+		return true
+	}
+	if old := b.ring[b.pos]; old != "" {
+		delete(b.seen, old)
+	}
+	b.ring[b.pos] = id
+	b.pos = (b.pos + 1) % len(b.ring)
+	b.seen[id] = struct{}{}
+	return false
+}
+
+// touchPeer 按心跳注册或刷新节点
+func (b *clusterBridge) touchPeer(env clusterEnvelope, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.peers[env.Node]
+	if !ok {
+		p = &ClusterPeer{Node: env.Node}
+		b.peers[env.Node] = p
+		logrus.Infof("WebSocket集群节点加入: %s", env.Node)
+	}
+	p.Region = env.Region
+	p.Connections = env.Connections
+	p.LastSeen = now
+	clusterPeersGauge.WithLabelValues(b.node).Set(float64(len(b.peers)))
+}
+
+// expirePeers 移除长时间没有心跳的节点
+func (b *clusterBridge) expirePeers(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, p := range b.peers {
+		if now.Sub(p.LastSeen) > clusterPeerTimeoutFactor*clusterHeartbeatInterval {
+			delete(b.peers, id)
+			logrus.Warnf("WebSocket集群节点心跳超时: %s", id)
+		}
+	}
+	clusterPeersGauge.WithLabelValues(b.node).Set(float64(len(b.peers)))
+}
+
+func (b *clusterBridge) stats() *ClusterStats {
+	s := &ClusterStats{
+		Node:          b.node,
+		Channel:       b.channel,
+		Published:     b.published.Load(),
+		Received:      b.received.Load(),
+		Echoes:        b.echoes.Load(),
+		Duplicates:    b.duplicates.Load(),
+		PublishErrors: b.publishErrors.Load(),
+		Dropped:       b.dropped.Load(),
+	}
+	b.mu.Lock()
+	s.Peers = make([]ClusterPeer, 0, len(b.peers))
+	for _, p := range b.peers {
+		s.Peers = append(s.Peers, *p)
+	}
+	b.mu.Unlock()
+	sort.Slice(s.Peers, func(i, j int) bool { return s.Peers[i].Node < s.Peers[j].Node })
+	return s
+}
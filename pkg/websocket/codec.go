@@ -0,0 +1,359 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// 内置编解码器名称，同时也是握手时协商使用的子协议名
+const (
+	CodecJSON     = "json"
+	CodecMsgpack  = "msgpack"
+	CodecProtobuf = "protobuf"
+)
+
+// Codec 消息编解码器，客户端通过 Sec-WebSocket-Protocol 声明，未协商时使用 JSON
+type Codec interface {
+	// Name 编解码器名称，即客户端声明的子协议
+	Name() string
+	// FrameType 写出时使用的帧类型，websocket.TextMessage 或 websocket.BinaryMessage
+	FrameType() int
+	Marshal(msg *Message) ([]byte, error)
+	Unmarshal(data []byte, msg *Message) error
+}
+
+// RegisterCodec 注册自定义编解码器，同名时覆盖；JSON 为默认编码，不可替换
+func (h *Hub) RegisterCodec(c Codec) error {
+	if c == nil || c.Name() == "" {
+		return errors.New("codec name is required")
+	}
+	if c.Name() == CodecJSON || c.Name() == AuthSubprotocol {
+		return fmt.Errorf("codec name %q is reserved", c.Name())
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.codecs[c.Name()] = c
+	return nil
+}
+
+// negotiateCodec 按客户端声明的子协议顺序选择第一个已注册的编解码器，未声明时返回 nil
+func (h *Hub) negotiateCodec(r *http.Request) Codec {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, p := range websocket.Subprotocols(r) {
+		if c, ok := h.codecs[p]; ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// defaultCodecs 内置编解码器
+func defaultCodecs() map[string]Codec {
+	return map[string]Codec{
+		CodecJSON:     JSONCodec,
+		CodecMsgpack:  NewMsgpackCodec(),
+		CodecProtobuf: ProtobufCodec,
+	}
+}
+
+// Codec 返回连接协商的编解码器
+func (c *Connection) Codec() Codec {
+	if c.codec == nil {
+		return JSONCodec
+	}
+	return c.codec
+}
+
+// encode 按连接的编解码器序列化消息
+func (c *Connection) encode(msg *Message) ([]byte, error) {
+	checkOutbound(msg)
+	return c.Codec().Marshal(msg)
+}
+
+// decode 按连接的编解码器解析消息；二进制编码的连接仍接受 JSON 文本帧，便于调试。
+// 非 JSON 编码解析出的 Data 统一转换为与 JSON 一致的类型，处理逻辑无需区分编码
+func (c *Connection) decode(frameType int, data []byte, msg *Message) error {
+	codec := c.Codec()
+	if codec.Name() == CodecJSON || (frameType == websocket.TextMessage && codec.FrameType() != websocket.TextMessage) {
+		return json.Unmarshal(data, msg)
+	}
+	if err := codec.Unmarshal(data, msg); err != nil {
+		return err
+	}
+	normalized, err := normalizeData(msg.Data)
+	if err != nil {
+		return err
+	}
+	msg.Data = normalized
+	return nil
+}
+
+// normalizeData 经 JSON 往返转换为 map[string]interface{}、[]interface{}、float64 等通用类型
+func normalizeData(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// encodedMessage 待投递的消息，扇出时每种编码只序列化一次。
+// JSON 编码总是预先生成，死信等内部流程统一使用 JSON 载荷
+type encodedMessage struct {
+	msg  *Message
+	json []byte
+
+	mu     sync.Mutex
+	frames map[string][]byte
+}
+
+// newEncodedMessage 序列化消息的 JSON 编码
+func newEncodedMessage(msg *Message) (*encodedMessage, error) {
+	checkOutbound(msg)
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedMessage{msg: msg, json: data}, nil
+}
+
+// rawEncodedMessage 由已序列化的 JSON 载荷构造，如死信重放
+func rawEncodedMessage(data []byte) *encodedMessage {
+	return &encodedMessage{json: data}
+}
+
+// bytesFor 返回指定编解码器的编码结果并缓存，编码失败时返回 nil
+func (e *encodedMessage) bytesFor(c Codec) []byte {
+	if c == nil || c.Name() == CodecJSON {
+		return e.json
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if data, ok := e.frames[c.Name()]; ok {
+		return data
+	}
+	if e.msg == nil {
+		var msg Message
+		if err := json.Unmarshal(e.json, &msg); err != nil {
+			logrus.Errorf("消息解析失败: %v", err)
+			return nil
+		}
+		e.msg = &msg
+	}
+	data, err := c.Marshal(e.msg)
+	if err != nil {
+		logrus.Errorf("消息 %s 编码失败: %v", c.Name(), err)
+		data = nil
+	}
+	if e.frames == nil {
+		e.frames = make(map[string][]byte)
+	}
+	e.frames[c.Name()] = data
+	return data
+}
+
+type jsonCodec struct{}
+
+// JSONCodec 默认的 JSON 文本编码
+var JSONCodec Codec = jsonCodec{}
+
+func (jsonCodec) Name() string   { return CodecJSON }
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Marshal(msg *Message) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (jsonCodec) Unmarshal(data []byte, msg *Message) error {
+	return json.Unmarshal(data, msg)
+}
+
+// msgpackCodec MessagePack 二进制编码，字段名沿用 json 标签
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+// NewMsgpackCodec 创建 MessagePack 编解码器
+func NewMsgpackCodec() Codec {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.WriteExt = true
+	return &msgpackCodec{handle: h}
+}
+
+func (m *msgpackCodec) Name() string   { return CodecMsgpack }
+func (m *msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (m *msgpackCodec) Marshal(msg *Message) ([]byte, error) {
+	var out []byte
+	if err := codec.NewEncoderBytes(&out, m.handle).Encode(msg); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (m *msgpackCodec) Unmarshal(data []byte, msg *Message) error {
+	return codec.NewDecoderBytes(data, m.handle).Decode(msg)
+}
+
+// protobufCodec Protobuf 二进制编码，对应的消息定义：
+//
+//	message Envelope {
+//	  string type = 1;
+//	  google.protobuf.Value data = 2;
+//	  int64 timestamp = 3;
+//	  string from = 4;
+//	  string to = 5;
+//	  string group = 6;
+//	  int64 id = 7;
+//	  string conversation = 8;
+//	  int64 ttl = 9;
+//	  int64 expires_at = 10;
+//	}
+type protobufCodec struct{}
+
+// ProtobufCodec Protobuf 编解码器
+var ProtobufCodec Codec = protobufCodec{}
+
+const (
+	pbFieldType protowire.Number = iota + 1
+	pbFieldData
+	pbFieldTimestamp
+	pbFieldFrom
+	pbFieldTo
+	pbFieldGroup
+	pbFieldID
+	pbFieldConversation
+	pbFieldTTL
+	pbFieldExpiresAt
+)
+
+func (protobufCodec) Name() string   { return CodecProtobuf }
+func (protobufCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (protobufCodec) Marshal(msg *Message) ([]byte, error) {
+	out := make([]byte, 0, 64)
+	out = appendPBString(out, pbFieldType, msg.Type)
+	if msg.Data != nil {
+		plain, err := normalizeData(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+		value, err := structpb.NewValue(plain)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := proto.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		out = protowire.AppendTag(out, pbFieldData, protowire.BytesType)
+		out = protowire.AppendBytes(out, raw)
+	}
+	out = appendPBInt(out, pbFieldTimestamp, msg.Timestamp)
+	out = appendPBString(out, pbFieldFrom, msg.From)
+	out = appendPBString(out, pbFieldTo, msg.To)
+	out = appendPBString(out, pbFieldGroup, msg.Group)
+	out = appendPBInt(out, pbFieldID, msg.ID)
+	out = appendPBString(out, pbFieldConversation, msg.Conversation)
+	out = appendPBInt(out, pbFieldTTL, msg.TTL)
+	out = appendPBInt(out, pbFieldExpiresAt, msg.ExpiresAt)
+	return out, nil
+}
+
+func (protobufCodec) Unmarshal(data []byte, msg *Message) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			switch num {
+			case pbFieldType:
+				msg.Type = string(v)
+			case pbFieldData:
+				var value structpb.Value
+				if err := proto.Unmarshal(v, &value); err != nil {
+					return err
+				}
+				msg.Data = value.AsInterface()
+			case pbFieldFrom:
+				msg.From = string(v)
+			case pbFieldTo:
+				msg.To = string(v)
+			case pbFieldGroup:
+				msg.Group = string(v)
+			case pbFieldConversation:
+				msg.Conversation = string(v)
+			}
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			switch num {
+			case pbFieldTimestamp:
+				msg.Timestamp = int64(v)
+			case pbFieldID:
+				msg.ID = int64(v)
+			case pbFieldTTL:
+				msg.TTL = int64(v)
+			case pbFieldExpiresAt:
+				msg.ExpiresAt = int64(v)
+			}
+		default:
+			// 跳过未知字段，兼容后续新增字段
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
+
+func appendPBString(out []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return out
+	}
+	out = protowire.AppendTag(out, num, protowire.BytesType)
+	return protowire.AppendString(out, v)
+}
+
+func appendPBInt(out []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return out
+	}
+	out = protowire.AppendTag(out, num, protowire.VarintType)
+	return protowire.AppendVarint(out, uint64(v))
+}
@@ -3,7 +3,10 @@ package websocket
 import (
 	"HibiscusIM/pkg/util"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // LoadConfigFromEnv 从环境变量加载WebSocket配置
@@ -55,6 +58,22 @@ func LoadConfigFromEnv() *Config {
 		config.ClusterNodeID = clusterNodeID
 	}
 
+	if redisAddr := util.GetEnv(EnvWebSocketClusterRedisAddr); redisAddr != "" {
+		config.ClusterRedisAddr = redisAddr
+	}
+
+	if redisPass := util.GetEnv(EnvWebSocketClusterRedisPass); redisPass != "" {
+		config.ClusterRedisPassword = redisPass
+	}
+
+	if redisDB := util.GetIntEnv(EnvWebSocketClusterRedisDB); redisDB > 0 {
+		config.ClusterRedisDB = int(redisDB)
+	}
+
+	if channel := util.GetEnv(EnvWebSocketClusterChannel); channel != "" {
+		config.ClusterChannel = channel
+	}
+
 	if dropOnFull := util.GetEnv(EnvWebSocketDropOnFull); dropOnFull != "" {
 		config.DropOnFull = dropOnFull == "true" || dropOnFull == "1"
 	}
@@ -91,6 +110,91 @@ func LoadConfigFromEnv() *Config {
 		config.PingWorkerCount = int(pingWorkers)
 	}
 
+	if region := util.GetEnv(EnvWebSocketRegion); region != "" {
+		config.Region = region
+	}
+
+	if endpoints := util.GetEnv(EnvWebSocketEndpoints); endpoints != "" {
+		parsed, err := ParseEndpoints(endpoints)
+		if err != nil {
+			logrus.Warnf("解析 %s 失败: %v", EnvWebSocketEndpoints, err)
+		} else {
+			config.Endpoints = parsed
+		}
+	}
+
+	if sink := util.GetEnv(EnvWebSocketDeadLetterSink); sink != "" {
+		config.DeadLetterSink = sink
+	}
+
+	if capacity := util.GetIntEnv(EnvWebSocketDeadLetterCapacity); capacity > 0 {
+		config.DeadLetterCapacity = int(capacity)
+	}
+
+	if awaySeconds := util.GetEnv(EnvWebSocketPresenceAwaySeconds); awaySeconds != "" {
+		config.PresenceAwayAfter = time.Duration(util.GetIntEnv(EnvWebSocketPresenceAwaySeconds)) * time.Second
+	}
+
+	if threshold := util.GetEnv(EnvWebSocketDropAlertThreshold); threshold != "" {
+		config.DropAlertThreshold = int(util.GetIntEnv(EnvWebSocketDropAlertThreshold))
+	}
+
+	if origins := util.GetEnv(EnvWebSocketAllowedOrigins); origins != "" {
+		config.AllowedOrigins = nil
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				config.AllowedOrigins = append(config.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	if secret := util.GetEnv(EnvWebSocketJWTSecret); secret != "" {
+		config.JWTSecret = secret
+	}
+
+	if issuer := util.GetEnv(EnvWebSocketJWTIssuer); issuer != "" {
+		config.JWTIssuer = issuer
+	}
+	if limit := util.GetEnv(EnvWebSocketOfflineMessageLimit); limit != "" {
+		config.OfflineMessageLimit = int(util.GetIntEnv(EnvWebSocketOfflineMessageLimit))
+	}
+
+	if violations := util.GetEnv(EnvWebSocketMaxViolations); violations != "" {
+		config.MaxViolations = int(util.GetIntEnv(EnvWebSocketMaxViolations))
+	}
+
+	if window := util.GetIntEnv(EnvWebSocketViolationWindowSec); window > 0 {
+		config.ViolationWindow = time.Duration(window) * time.Second
+	}
+
+	if adaptive := util.GetEnv(EnvWebSocketAdaptiveHeartbeat); adaptive != "" {
+		config.AdaptiveHeartbeat = adaptive == "true" || adaptive == "1"
+	}
+
+	if batchMs := util.GetEnv(EnvWebSocketPoorLinkBatchMs); batchMs != "" {
+		config.PoorLinkBatchDelay = time.Duration(util.GetIntEnv(EnvWebSocketPoorLinkBatchMs)) * time.Millisecond
+	}
+
+	if drain := util.GetIntEnv(EnvWebSocketShutdownDrainSec); drain > 0 {
+		config.ShutdownDrainTimeout = time.Duration(drain) * time.Second
+	}
+
+	if reconnect := util.GetIntEnv(EnvWebSocketShutdownReconnect); reconnect > 0 {
+		config.ShutdownReconnectAfter = time.Duration(reconnect) * time.Second
+	}
+
+	if chunked := util.GetEnv(EnvWebSocketMaxChunkedSize); chunked != "" {
+		config.MaxChunkedMessageSize = int(util.GetIntEnv(EnvWebSocketMaxChunkedSize))
+	}
+
+	if chunkTimeout := util.GetIntEnv(EnvWebSocketChunkTimeoutSec); chunkTimeout > 0 {
+		config.ChunkTimeout = time.Duration(chunkTimeout) * time.Second
+	}
+
+	if threshold := util.GetEnv(EnvWebSocketCompressThreshold); threshold != "" {
+		config.CompressionThreshold = int(util.GetIntEnv(EnvWebSocketCompressThreshold))
+	}
+
 	return config
 }
 
@@ -140,6 +244,10 @@ func ValidateConfig(config *Config) error {
 		return fmt.Errorf("最大消息大小必须大于0")
 	}
 
+	if config.EnableCluster && config.ClusterRedisAddr == "" {
+		return fmt.Errorf("启用集群时必须设置 ClusterRedisAddr")
+	}
+
 	// 心跳间隔应该小于连接超时时间
 	if config.HeartbeatInterval >= config.ConnectionTimeout {
 		return fmt.Errorf("心跳间隔必须小于连接超时时间")
@@ -159,26 +267,44 @@ func ValidateConfig(config *Config) error {
 // GetConfigSummary 获取配置摘要
 func GetConfigSummary(config *Config) map[string]interface{} {
 	return map[string]interface{}{
-		"max_connections":       config.MaxConnections,
-		"heartbeat_interval":    config.HeartbeatInterval.String(),
-		"connection_timeout":    config.ConnectionTimeout.String(),
-		"message_buffer_size":   config.MessageBufferSize,
-		"message_queue_size":    config.MessageQueueSize,
-		"read_buffer_size":      config.ReadBufferSize,
-		"write_buffer_size":     config.WriteBufferSize,
-		"max_message_size":      config.MaxMessageSize,
-		"enable_compression":    config.EnableCompression,
-		"enable_message_queue":  config.EnableMessageQueue,
-		"enable_cluster":        config.EnableCluster,
-		"cluster_node_id":       config.ClusterNodeID,
-		"shard_count":           config.ShardCount,
-		"broadcast_workers":     config.BroadcastWorkerCount,
-		"drop_on_full":          config.DropOnFull,
-		"compression_level":     config.CompressionLevel,
-		"close_on_backpressure": config.CloseOnBackpressure,
-		"send_timeout":          config.SendTimeout.String(),
-		"enable_global_ping":    config.EnableGlobalPing,
-		"ping_workers":          config.PingWorkerCount,
+		"max_connections":          config.MaxConnections,
+		"heartbeat_interval":       config.HeartbeatInterval.String(),
+		"connection_timeout":       config.ConnectionTimeout.String(),
+		"message_buffer_size":      config.MessageBufferSize,
+		"message_queue_size":       config.MessageQueueSize,
+		"read_buffer_size":         config.ReadBufferSize,
+		"write_buffer_size":        config.WriteBufferSize,
+		"max_message_size":         config.MaxMessageSize,
+		"enable_compression":       config.EnableCompression,
+		"enable_message_queue":     config.EnableMessageQueue,
+		"enable_cluster":           config.EnableCluster,
+		"cluster_node_id":          config.ClusterNodeID,
+		"cluster_redis_addr":       config.ClusterRedisAddr,
+		"cluster_channel":          config.ClusterChannel,
+		"shard_count":              config.ShardCount,
+		"broadcast_workers":        config.BroadcastWorkerCount,
+		"drop_on_full":             config.DropOnFull,
+		"compression_level":        config.CompressionLevel,
+		"close_on_backpressure":    config.CloseOnBackpressure,
+		"send_timeout":             config.SendTimeout.String(),
+		"enable_global_ping":       config.EnableGlobalPing,
+		"ping_workers":             config.PingWorkerCount,
+		"region":                   config.Region,
+		"endpoints":                len(config.Endpoints),
+		"dead_letter_sink":         config.DeadLetterSink,
+		"dead_letter_capacity":     config.DeadLetterCapacity,
+		"drop_alert_threshold":     config.DropAlertThreshold,
+		"presence_away_after":      config.PresenceAwayAfter.String(),
+		"offline_message_limit":    config.OfflineMessageLimit,
+		"max_violations":           config.MaxViolations,
+		"violation_window":         config.ViolationWindow.String(),
+		"adaptive_heartbeat":       config.AdaptiveHeartbeat,
+		"poor_link_batch_delay":    config.PoorLinkBatchDelay.String(),
+		"shutdown_drain_timeout":   config.ShutdownDrainTimeout.String(),
+		"shutdown_reconnect_after": config.ShutdownReconnectAfter.String(),
+		"max_chunked_message_size": config.MaxChunkedMessageSize,
+		"chunk_timeout":            config.ChunkTimeout.String(),
+		"compression_threshold":    config.CompressionThreshold,
 	}
 }
 
@@ -189,26 +315,46 @@ func CloneConfig(config *Config) *Config {
 	}
 
 	return &Config{
-		MaxConnections:       config.MaxConnections,
-		HeartbeatInterval:    config.HeartbeatInterval,
-		ConnectionTimeout:    config.ConnectionTimeout,
-		MessageBufferSize:    config.MessageBufferSize,
-		ReadBufferSize:       config.ReadBufferSize,
-		WriteBufferSize:      config.WriteBufferSize,
-		MaxMessageSize:       config.MaxMessageSize,
-		EnableCompression:    config.EnableCompression,
-		EnableMessageQueue:   config.EnableMessageQueue,
-		MessageQueueSize:     config.MessageQueueSize,
-		EnableCluster:        config.EnableCluster,
-		ClusterNodeID:        config.ClusterNodeID,
-		ShardCount:           config.ShardCount,
-		BroadcastWorkerCount: config.BroadcastWorkerCount,
-		DropOnFull:           config.DropOnFull,
-		CompressionLevel:     config.CompressionLevel,
-		CloseOnBackpressure:  config.CloseOnBackpressure,
-		SendTimeout:          config.SendTimeout,
-		EnableGlobalPing:     config.EnableGlobalPing,
-		PingWorkerCount:      config.PingWorkerCount,
+		MaxConnections:         config.MaxConnections,
+		HeartbeatInterval:      config.HeartbeatInterval,
+		ConnectionTimeout:      config.ConnectionTimeout,
+		MessageBufferSize:      config.MessageBufferSize,
+		ReadBufferSize:         config.ReadBufferSize,
+		WriteBufferSize:        config.WriteBufferSize,
+		MaxMessageSize:         config.MaxMessageSize,
+		EnableCompression:      config.EnableCompression,
+		EnableMessageQueue:     config.EnableMessageQueue,
+		MessageQueueSize:       config.MessageQueueSize,
+		EnableCluster:          config.EnableCluster,
+		ClusterNodeID:          config.ClusterNodeID,
+		ClusterRedisAddr:       config.ClusterRedisAddr,
+		ClusterRedisPassword:   config.ClusterRedisPassword,
+		ClusterRedisDB:         config.ClusterRedisDB,
+		ClusterChannel:         config.ClusterChannel,
+		ShardCount:             config.ShardCount,
+		BroadcastWorkerCount:   config.BroadcastWorkerCount,
+		DropOnFull:             config.DropOnFull,
+		CompressionLevel:       config.CompressionLevel,
+		CloseOnBackpressure:    config.CloseOnBackpressure,
+		SendTimeout:            config.SendTimeout,
+		EnableGlobalPing:       config.EnableGlobalPing,
+		PingWorkerCount:        config.PingWorkerCount,
+		Region:                 config.Region,
+		Endpoints:              append([]Endpoint(nil), config.Endpoints...),
+		DeadLetterSink:         config.DeadLetterSink,
+		DeadLetterCapacity:     config.DeadLetterCapacity,
+		DropAlertThreshold:     config.DropAlertThreshold,
+		PresenceAwayAfter:      config.PresenceAwayAfter,
+		OfflineMessageLimit:    config.OfflineMessageLimit,
+		MaxViolations:          config.MaxViolations,
+		ViolationWindow:        config.ViolationWindow,
+		AdaptiveHeartbeat:      config.AdaptiveHeartbeat,
+		PoorLinkBatchDelay:     config.PoorLinkBatchDelay,
+		ShutdownDrainTimeout:   config.ShutdownDrainTimeout,
+		ShutdownReconnectAfter: config.ShutdownReconnectAfter,
+		MaxChunkedMessageSize:  config.MaxChunkedMessageSize,
+		ChunkTimeout:           config.ChunkTimeout,
+		CompressionThreshold:   config.CompressionThreshold,
 	}
 }
 
@@ -257,6 +403,14 @@ func MergeConfig(configs ...*Config) *Config {
 		if config.ClusterNodeID != "" {
 			result.ClusterNodeID = config.ClusterNodeID
 		}
+		if config.ClusterRedisAddr != "" {
+			result.ClusterRedisAddr = config.ClusterRedisAddr
+			result.ClusterRedisPassword = config.ClusterRedisPassword
+			result.ClusterRedisDB = config.ClusterRedisDB
+		}
+		if config.ClusterChannel != "" {
+			result.ClusterChannel = config.ClusterChannel
+		}
 
 		// 布尔值直接覆盖
 		result.EnableCompression = config.EnableCompression
@@ -265,6 +419,7 @@ func MergeConfig(configs ...*Config) *Config {
 		result.DropOnFull = config.DropOnFull
 		result.CloseOnBackpressure = config.CloseOnBackpressure
 		result.EnableGlobalPing = config.EnableGlobalPing
+		result.AdaptiveHeartbeat = config.AdaptiveHeartbeat
 
 		if config.ShardCount > 0 {
 			result.ShardCount = config.ShardCount
@@ -281,6 +436,60 @@ func MergeConfig(configs ...*Config) *Config {
 		if config.PingWorkerCount > 0 {
 			result.PingWorkerCount = config.PingWorkerCount
 		}
+		if config.Region != "" {
+			result.Region = config.Region
+		}
+		if len(config.Endpoints) > 0 {
+			result.Endpoints = append([]Endpoint(nil), config.Endpoints...)
+		}
+		if config.DeadLetterSink != "" {
+			result.DeadLetterSink = config.DeadLetterSink
+		}
+		if config.DeadLetterCapacity > 0 {
+			result.DeadLetterCapacity = config.DeadLetterCapacity
+		}
+		if config.DropAlertThreshold > 0 {
+			result.DropAlertThreshold = config.DropAlertThreshold
+		}
+		if config.PresenceAwayAfter > 0 {
+			result.PresenceAwayAfter = config.PresenceAwayAfter
+		}
+		if len(config.AllowedOrigins) > 0 {
+			result.AllowedOrigins = append([]string(nil), config.AllowedOrigins...)
+		}
+		if config.JWTSecret != "" {
+			result.JWTSecret = config.JWTSecret
+		}
+		if config.JWTIssuer != "" {
+			result.JWTIssuer = config.JWTIssuer
+		}
+		if config.OfflineMessageLimit > 0 {
+			result.OfflineMessageLimit = config.OfflineMessageLimit
+		}
+		if config.MaxViolations > 0 {
+			result.MaxViolations = config.MaxViolations
+		}
+		if config.ViolationWindow > 0 {
+			result.ViolationWindow = config.ViolationWindow
+		}
+		if config.PoorLinkBatchDelay > 0 {
+			result.PoorLinkBatchDelay = config.PoorLinkBatchDelay
+		}
+		if config.ShutdownDrainTimeout > 0 {
+			result.ShutdownDrainTimeout = config.ShutdownDrainTimeout
+		}
+		if config.ShutdownReconnectAfter > 0 {
+			result.ShutdownReconnectAfter = config.ShutdownReconnectAfter
+		}
+		if config.MaxChunkedMessageSize > 0 {
+			result.MaxChunkedMessageSize = config.MaxChunkedMessageSize
+		}
+		if config.ChunkTimeout > 0 {
+			result.ChunkTimeout = config.ChunkTimeout
+		}
+		if config.CompressionThreshold > 0 {
+			result.CompressionThreshold = config.CompressionThreshold
+		}
 	}
 
 	return result
@@ -1,7 +1,6 @@
 package websocket
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -16,8 +15,7 @@ func newUpgrader(cfg *Config) websocket.Upgrader {
 		ReadBufferSize:  cfg.ReadBufferSize,
 		WriteBufferSize: cfg.WriteBufferSize,
 		CheckOrigin: func(r *http.Request) bool {
-			// 在生产环境中应该检查Origin
-			return true
+			return originAllowed(cfg, r)
 		},
 		EnableCompression: cfg.EnableCompression,
 	}
@@ -26,20 +24,50 @@ func newUpgrader(cfg *Config) websocket.Upgrader {
 
 // HandleWebSocket 处理WebSocket连接
 func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, userID string) {
+	serveWebSocket(hub, w, r, userID, "")
+}
+
+// serveWebSocket 升级连接并注册到Hub，subprotocol 非空时在握手响应中回显；
+// 客户端声明了编解码器子协议时优先回显编解码器
+func serveWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, userID, subprotocol string) {
+	if hub.draining.Load() {
+		hub.recordHandshake(r, HandshakeShuttingDown, ErrServerShuttingDown, userID)
+		rejectUpgrade(hub.config, w, r, websocket.CloseServiceRestart, ErrServerShuttingDown)
+		return
+	}
+	if hub.atCapacity() {
+		logrus.Warnf("达到最大连接数限制: %d", hub.config.MaxConnections)
+		hub.recordHandshake(r, HandshakeLimitReached, ErrConnectionLimitExceeded, userID)
+		rejectUpgrade(hub.config, w, r, websocket.CloseTryAgainLater, ErrConnectionLimitExceeded)
+		return
+	}
+
+	codec := hub.negotiateCodec(r)
+	if codec != nil {
+		subprotocol = codec.Name()
+	}
+	var header http.Header
+	if subprotocol != "" {
+		header = http.Header{"Sec-WebSocket-Protocol": []string{subprotocol}}
+	}
+
 	// 升级HTTP连接为WebSocket
 	upgrader := newUpgrader(hub.config)
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := upgrader.Upgrade(w, r, header)
 	if err != nil {
 		logrus.Errorf("WebSocket升级失败: %v", err)
+		outcome := HandshakeProtocolMismatch
+		if !originAllowed(hub.config, r) {
+			outcome = HandshakeOriginRejected
+		}
+		hub.recordHandshake(r, outcome, err.Error(), userID)
 		return
 	}
 
-	// 压缩设置
-	if hub.config.EnableCompression {
-		conn.EnableWriteCompression(true)
-		if hub.config.CompressionLevel != 0 {
-			_ = conn.SetCompressionLevel(hub.config.CompressionLevel)
-		}
+	// 压缩设置：仅在客户端提供 permessage-deflate 时生效，逐条消息按大小决定是否压缩
+	compress := hub.config.EnableCompression && offersDeflate(r)
+	if compress && hub.config.CompressionLevel != 0 {
+		_ = conn.SetCompressionLevel(hub.config.CompressionLevel)
 	}
 
 	// 创建连接实例
@@ -53,8 +81,22 @@ func HandleWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request, userID st
 		IsAlive:  true,
 		Groups:   make(map[string]bool),
 		Metadata: make(map[string]interface{}),
+		kick:     make(chan struct{}),
+		codec:    codec,
+		drain:    make(chan struct{}),
+		drained:  make(chan struct{}),
+		compress: compress,
+
+		chunkLimit: negotiateChunkLimit(hub.config, r),
+
+		ConnectedAt: time.Now(),
+		RemoteAddr:  r.RemoteAddr,
+		UserAgent:   r.UserAgent(),
 	}
 
+	// 注册前恢复持久化的组，注册时一并建立组连接映射
+	connection.restoreGroups()
+
 	// 注册连接到Hub
 	hub.register <- connection
 
@@ -78,15 +120,17 @@ func (c *Connection) readPump() {
 	c.Conn.SetReadLimit(int64(c.Hub.config.MaxMessageSize))
 	c.Conn.SetReadDeadline(time.Now().Add(c.Hub.config.ConnectionTimeout))
 	c.Conn.SetPongHandler(func(string) error {
+		now := time.Now()
 		c.mu.Lock()
-		c.LastPing = time.Now()
+		c.LastPing = now
 		c.mu.Unlock()
+		c.quality.pongReceived(now)
 		c.Conn.SetReadDeadline(time.Now().Add(c.Hub.config.ConnectionTimeout))
 		return nil
 	})
 
 	for {
-		_, message, err := c.Conn.ReadMessage()
+		frameType, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				logrus.Errorf("WebSocket读取错误: %v", err)
@@ -95,21 +139,22 @@ func (c *Connection) readPump() {
 		}
 
 		// 处理接收到的消息
-		c.handleMessage(message)
+		c.handleMessage(frameType, message)
 	}
 }
 
 // writePump 发送消息的协程
 func (c *Connection) writePump() {
 	var ticker *time.Ticker
+	// 心跳间隔随连接质量调整
+	grade := c.quality.gradeOf()
+	pingEvery := func() time.Duration {
+		return time.Duration(float64(c.Hub.heartbeatFor(grade)) * 0.9)
+	}
 	if !c.Hub.config.EnableGlobalPing {
-		interval := c.Hub.config.HeartbeatInterval
-		if interval <= 0 {
-			interval = 30 * time.Second
-		}
-		pingEvery := time.Duration(float64(interval) * 0.9)
-		ticker = time.NewTicker(pingEvery)
+		ticker = time.NewTicker(pingEvery())
 	}
+	qualityChanged := c.quality.notify()
 	defer func() {
 		if ticker != nil {
 			ticker.Stop()
@@ -126,22 +171,52 @@ func (c *Connection) writePump() {
 				return
 			}
 
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
+			frameType := c.Codec().FrameType()
+			// 弱网连接稍作等待，让随后到达的消息合并进同一帧
+			if delay := c.Hub.batchDelayFor(grade); delay > 0 && frameType == websocket.TextMessage && len(c.Send) < cap(c.Send)/2 {
+				time.Sleep(delay)
+			}
+			frames := c.outboundFrames(message)
+			if len(frames) == 0 {
+				continue
+			}
+			c.setWriteCompression(len(message))
+			w, err := c.Conn.NextWriter(frameType)
 			if err != nil {
 				return
 			}
-			_, _ = w.Write(message)
+			_, _ = w.Write(frames[0])
 
-			// 将队列中的其他消息也一起发送
+			// 文本帧将分片与队列中的其他消息以换行分隔一起发送
 			n := len(c.Send)
-			for i := 0; i < n; i++ {
-				_, _ = w.Write([]byte{'\n'})
-				_, _ = w.Write(<-c.Send)
+			if frameType == websocket.TextMessage {
+				for _, frame := range frames[1:] {
+					_, _ = w.Write([]byte{'\n'})
+					_, _ = w.Write(frame)
+				}
+				for i := 0; i < n; i++ {
+					for _, frame := range c.outboundFrames(<-c.Send) {
+						_, _ = w.Write([]byte{'\n'})
+						_, _ = w.Write(frame)
+					}
+				}
 			}
 
 			if err := w.Close(); err != nil {
 				return
 			}
+
+			// 二进制帧无法分隔，分片与队列中的其他消息逐帧发送
+			if frameType != websocket.TextMessage {
+				if !c.writeFrames(frameType, frames[1:]) {
+					return
+				}
+				for i := 0; i < n; i++ {
+					if !c.writeFrames(frameType, c.outboundFrames(<-c.Send)) {
+						return
+					}
+				}
+			}
 		case <-func() <-chan time.Time {
 			if ticker != nil {
 				return ticker.C
@@ -149,23 +224,80 @@ func (c *Connection) writePump() {
 			return make(chan time.Time)
 		}():
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.quality.pingSent(time.Now())
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+		case <-qualityChanged:
+			grade = c.quality.gradeOf()
+			if ticker != nil {
+				ticker.Reset(pingEvery())
+			}
+		case <-c.drain:
+			c.finishDrain()
+			return
+		case <-c.kick:
+			c.flushAndClose(websocket.ClosePolicyViolation, ErrTooManyViolations)
+			return
+		}
+	}
+}
+
+// finishDrain 优雅关闭时逐帧发完队列中的消息，再发送 1012（服务重启）关闭帧
+func (c *Connection) finishDrain() {
+	defer close(c.drained)
+	c.flushAndClose(websocket.CloseServiceRestart, c.Hub.shutdownReason)
+}
+
+// flushAndClose 逐帧发完队列中的消息后发送关闭帧，只在写协程中调用
+func (c *Connection) flushAndClose(code int, reason string) {
+	c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	frameType := c.Codec().FrameType()
+	for n := len(c.Send); n > 0; n-- {
+		if !c.writeFrames(frameType, c.outboundFrames(<-c.Send)) {
+			return
 		}
 	}
+	_ = c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+}
+
+// writeFrames 逐帧写出，写入失败时返回 false
+func (c *Connection) writeFrames(frameType int, frames [][]byte) bool {
+	for _, frame := range frames {
+		c.setWriteCompression(len(frame))
+		if err := c.Conn.WriteMessage(frameType, frame); err != nil {
+			return false
+		}
+	}
+	return true
 }
 
 // handleMessage 处理接收到的消息
-func (c *Connection) handleMessage(message []byte) {
+func (c *Connection) handleMessage(frameType int, message []byte) {
+	// 违规过多等待写协程关闭连接，不再处理后续消息
+	if c.kicked {
+		return
+	}
 	var msg Message
-	if err := json.Unmarshal(message, &msg); err != nil {
+	if err := c.decode(frameType, message, &msg); err != nil {
 		logrus.Errorf("消息解析失败: %v", err)
+		c.rejectMessage("", err)
 		return
 	}
+	c.Hub.counters.messagesIn.Add(1)
+	c.dispatch(frameType, msg)
+}
 
+// dispatch 按类型处理解析后的消息，分片消息收齐后同样经由此处处理
+func (c *Connection) dispatch(frameType int, msg Message) {
+	if !c.validateInbound(&msg) {
+		return
+	}
 	// 设置发送者ID
 	msg.From = c.UserID
+	if msg.Type != MessageTypePing {
+		c.Hub.touchPresence(c.UserID)
+	}
 
 	// 根据消息类型处理
 	switch msg.Type {
@@ -181,6 +313,20 @@ func (c *Connection) handleMessage(message []byte) {
 		c.handleNotification(msg)
 	case "status":
 		c.handleStatus(msg)
+	case MessageTypeTyping:
+		c.handleTyping(msg)
+	case MessageTypePresence:
+		c.handlePresence(msg)
+	case MessageTypeRead:
+		c.handleRead(msg)
+	case MessageTypeReact:
+		c.handleReaction(msg, true)
+	case MessageTypeUnreact:
+		c.handleReaction(msg, false)
+	case MessageTypeQuality:
+		c.handleQuality(msg)
+	case MessageTypeChunk:
+		c.handleChunk(frameType, msg)
 	default:
 		logrus.Warnf("未知的消息类型: %s", msg.Type)
 	}
@@ -198,7 +344,7 @@ func (c *Connection) handlePing() {
 		Timestamp: time.Now().Unix(),
 	}
 
-	data, _ := json.Marshal(response)
+	data, _ := c.encode(&response)
 	select {
 	case c.Send <- data:
 	default:
@@ -214,6 +360,14 @@ func (c *Connection) handleJoinGroup(msg Message) {
 		return
 	}
 
+	if !c.checkGroupPermission(PermissionActionJoin, groupName) {
+		return
+	}
+	if err := c.authorizeJoin(groupName); err != nil {
+		c.sendError(groupName, err.Error())
+		return
+	}
+
 	c.mu.Lock()
 	c.Groups[groupName] = true
 	c.mu.Unlock()
@@ -225,6 +379,7 @@ func (c *Connection) handleJoinGroup(msg Message) {
 	}
 	c.Hub.groupConnections[groupName][c.ID] = true
 	c.Hub.mu.Unlock()
+	c.trackJoin(groupName)
 
 	// 发送确认消息
 	response := Message{
@@ -233,7 +388,7 @@ func (c *Connection) handleJoinGroup(msg Message) {
 		Timestamp: time.Now().Unix(),
 	}
 
-	data, _ := json.Marshal(response)
+	data, _ := c.encode(&response)
 	select {
 	case c.Send <- data:
 	default:
@@ -272,7 +427,7 @@ func (c *Connection) handleLeaveGroup(msg Message) {
 		Timestamp: time.Now().Unix(),
 	}
 
-	data, _ := json.Marshal(response)
+	data, _ := c.encode(&response)
 	select {
 	case c.Send <- data:
 	default:
@@ -296,6 +451,31 @@ func (c *Connection) handleChat(msg Message) {
 		return
 	}
 
+	// 组消息需通过禁言/封禁检查
+	if msg.Group != "" {
+		if !c.IsInGroup(msg.Group) {
+			c.sendError(msg.Group, ErrNotInGroup)
+			return
+		}
+		if !c.checkGroupPermission(PermissionActionPublish, msg.Group) {
+			return
+		}
+		if m := c.Hub.getModerator(); m != nil {
+			if err := m.CheckSend(msg.Group, c.UserID); err != nil {
+				c.sendError(msg.Group, err.Error())
+				return
+			}
+		}
+	}
+
+	// 分配会话内消息ID，供客户端上报已读回执
+	if !c.applyExpiry(&msg) {
+		c.sendError(msg.Group, ErrInvalidMessageTTL)
+		return
+	}
+	c.assignMessageID(&msg)
+	c.archiveMessage(msg)
+
 	// 广播消息
 	c.Hub.broadcast <- &msg
 }
@@ -308,6 +488,11 @@ func (c *Connection) handleNotification(msg Message) {
 		return
 	}
 
+	// 组通知与聊天消息一样需要组权限
+	if msg.Group != "" && !c.checkGroupPermission(PermissionActionPublish, msg.Group) {
+		return
+	}
+
 	// 广播通知
 	c.Hub.broadcast <- &msg
 }
@@ -329,7 +514,7 @@ func (c *Connection) handleStatus(msg Message) {
 		Timestamp: time.Now().Unix(),
 	}
 
-	data, _ := json.Marshal(response)
+	data, _ := c.encode(&response)
 	select {
 	case c.Send <- data:
 	default:
@@ -339,7 +524,7 @@ func (c *Connection) handleStatus(msg Message) {
 
 // SendMessage 发送消息给当前连接
 func (c *Connection) SendMessage(message *Message) error {
-	data, err := json.Marshal(message)
+	data, err := c.encode(message)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,149 @@
+package websocket
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 连接列表分页默认值
+const (
+	defaultConnectionPageSize = 50
+	maxConnectionPageSize     = 500
+)
+
+// ConnectionFilter 活跃连接的查询条件，条件之间为且关系
+type ConnectionFilter struct {
+	UserID string
+	Group  string
+	// 只列出该时间之后建立的连接
+	Since time.Time
+	// 连接元数据需包含的键值，值按字符串形式比较
+	Metadata map[string]string
+	Offset   int
+	// 默认 50，最大 500
+	Limit int
+}
+
+func (f *ConnectionFilter) applyDefaults() {
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultConnectionPageSize
+	}
+	if f.Limit > maxConnectionPageSize {
+		f.Limit = maxConnectionPageSize
+	}
+}
+
+// ConnectionInfo 活跃连接的客户端信息与状态
+type ConnectionInfo struct {
+	ConnectionID string                 `json:"connectionId"`
+	UserID       string                 `json:"userId"`
+	Groups       []string               `json:"groups"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"` // 客户端通过 status 消息上报的设备信息
+	ConnectedAt  time.Time              `json:"connectedAt"`
+	LastPing     time.Time              `json:"lastPing"`
+	RemoteAddr   string                 `json:"remoteAddr,omitempty"`
+	UserAgent    string                 `json:"userAgent,omitempty"`
+	Quality      string                 `json:"quality"`
+	QueueDepth   int                    `json:"queueDepth"`
+}
+
+// ConnectionPage 一页连接列表，Total 为满足条件的连接总数
+type ConnectionPage struct {
+	Items  []ConnectionInfo `json:"items"`
+	Total  int              `json:"total"`
+	Offset int              `json:"offset"`
+	Limit  int              `json:"limit"`
+}
+
+// ListConnections 按条件列出活跃连接，最近建立的排在前面
+func (h *Hub) ListConnections(f ConnectionFilter) ConnectionPage {
+	f.applyDefaults()
+	h.mu.RLock()
+	var conns []*Connection
+	switch {
+	case f.UserID != "":
+		for id := range h.userConnections[f.UserID] {
+			if conn, ok := h.connections[id]; ok {
+				conns = append(conns, conn)
+			}
+		}
+	case f.Group != "":
+		for id := range h.groupConnections[f.Group] {
+			if conn, ok := h.connections[id]; ok {
+				conns = append(conns, conn)
+			}
+		}
+	default:
+		conns = make([]*Connection, 0, len(h.connections))
+		for _, conn := range h.connections {
+			conns = append(conns, conn)
+		}
+	}
+	h.mu.RUnlock()
+
+	matched := make([]ConnectionInfo, 0, len(conns))
+	for _, conn := range conns {
+		if info, ok := conn.info(f); ok {
+			matched = append(matched, info)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].ConnectedAt.Equal(matched[j].ConnectedAt) {
+			return matched[i].ConnectedAt.After(matched[j].ConnectedAt)
+		}
+		return matched[i].ConnectionID < matched[j].ConnectionID
+	})
+
+	page := ConnectionPage{Items: []ConnectionInfo{}, Total: len(matched), Offset: f.Offset, Limit: f.Limit}
+	if f.Offset < len(matched) {
+		end := min(f.Offset+f.Limit, len(matched))
+		page.Items = matched[f.Offset:end]
+	}
+	return page
+}
+
+// info 连接满足条件时返回其信息
+func (c *Connection) info(f ConnectionFilter) (ConnectionInfo, bool) {
+	if f.UserID != "" && c.UserID != f.UserID {
+		return ConnectionInfo{}, false
+	}
+	if !f.Since.IsZero() && c.ConnectedAt.Before(f.Since) {
+		return ConnectionInfo{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if f.Group != "" && !c.Groups[f.Group] {
+		return ConnectionInfo{}, false
+	}
+	for k, want := range f.Metadata {
+		v, ok := c.Metadata[k]
+		if !ok || fmt.Sprint(v) != want {
+			return ConnectionInfo{}, false
+		}
+	}
+	groups := make([]string, 0, len(c.Groups))
+	for g := range c.Groups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	metadata := make(map[string]interface{}, len(c.Metadata))
+	for k, v := range c.Metadata {
+		metadata[k] = v
+	}
+	return ConnectionInfo{
+		ConnectionID: c.ID,
+		UserID:       c.UserID,
+		Groups:       groups,
+		Metadata:     metadata,
+		ConnectedAt:  c.ConnectedAt,
+		LastPing:     c.LastPing,
+		RemoteAddr:   c.RemoteAddr,
+		UserAgent:    c.UserAgent,
+		Quality:      c.quality.gradeOf(),
+		QueueDepth:   len(c.Send),
+	}, true
+}
@@ -3,14 +3,15 @@ package websocket
 // WebSocket消息类型常量
 const (
 	// 系统消息类型
-	MessageTypePing          = "ping"
-	MessageTypePong          = "pong"
-	MessageTypeJoinGroup     = "join_group"
-	MessageTypeLeaveGroup    = "leave_group"
-	MessageTypeGroupJoined   = "group_joined"
-	MessageTypeGroupLeft     = "group_left"
-	MessageTypeStatus        = "status"
-	MessageTypeStatusUpdated = "status_updated"
+	MessageTypePing           = "ping"
+	MessageTypePong           = "pong"
+	MessageTypeJoinGroup      = "join_group"
+	MessageTypeLeaveGroup     = "leave_group"
+	MessageTypeGroupJoined    = "group_joined"
+	MessageTypeGroupLeft      = "group_left"
+	MessageTypeGroupsRestored = "groups_restored"
+	MessageTypeStatus         = "status"
+	MessageTypeStatusUpdated  = "status_updated"
 
 	// 业务消息类型
 	MessageTypeChat         = "chat"
@@ -18,6 +19,41 @@ const (
 	MessageTypeSystem       = "system"
 	MessageTypeError        = "error"
 	MessageTypeSuccess      = "success"
+	MessageTypeModeration   = "moderation"
+	MessageTypeUnreadCount  = "notification_unread"
+	MessageTypePresence     = "presence"
+	MessageTypeTyping       = "typing"
+	MessageTypeRead         = "read"
+	MessageTypeReact        = "react"
+	MessageTypeUnreact      = "unreact"
+	// 表情回应变更，由服务端广播
+	MessageTypeReactionChanged = "reaction_changed"
+	// 阅后即焚消息被清理，由服务端下发
+	MessageTypeMessagesExpired = "messages_expired"
+	// 会话默认消息存活时间变更，由服务端下发
+	MessageTypeConversationTTL = "conversation_ttl_changed"
+	// 客户端上报连接质量，服务端在等级变化时以同类型下发心跳间隔
+	MessageTypeQuality = "quality"
+	// 系统公告，由服务端下发
+	MessageTypeAnnouncement = "announcement"
+	// 服务端即将关闭，由服务端下发，data 为 ShutdownNotice
+	MessageTypeServerShutdown = "server_shutdown"
+	// 离线消息补发完成，由服务端下发，data 为 OfflineSync
+	MessageTypeOfflineSync = "offline_sync"
+	// 超过帧上限的消息分片，双向使用，data 为 MessageChunk
+	MessageTypeChunk = "chunk"
+	// 会话中的新附件，扫描通过后由服务端下发，data 为附件信息
+	MessageTypeAttachment = "attachment"
+
+	// 认证失效时使用的关闭码
+	CloseCodeAuthRevoked = 4401
+	// 握手时令牌缺失或无效
+	CloseCodeUnauthorized = 4401
+	// 握手时 Origin 不在白名单内
+	CloseCodeForbiddenOrigin = 4403
+
+	// 通过 Sec-WebSocket-Protocol 传递令牌时使用的子协议名
+	AuthSubprotocol = "access_token"
 
 	// 连接状态
 	ConnectionStatusConnected    = "connected"
@@ -45,6 +81,10 @@ const (
 	EnvWebSocketEnableMessageQueue  = "WEBSOCKET_ENABLE_MESSAGE_QUEUE"
 	EnvWebSocketEnableCluster       = "WEBSOCKET_ENABLE_CLUSTER"
 	EnvWebSocketClusterNodeID       = "WEBSOCKET_CLUSTER_NODE_ID"
+	EnvWebSocketClusterRedisAddr    = "WEBSOCKET_CLUSTER_REDIS_ADDR"
+	EnvWebSocketClusterRedisPass    = "WEBSOCKET_CLUSTER_REDIS_PASSWORD"
+	EnvWebSocketClusterRedisDB      = "WEBSOCKET_CLUSTER_REDIS_DB"
+	EnvWebSocketClusterChannel      = "WEBSOCKET_CLUSTER_CHANNEL"
 	EnvWebSocketShardCount          = "WEBSOCKET_SHARD_COUNT"
 	EnvWebSocketBroadcastWorkers    = "WEBSOCKET_BROADCAST_WORKERS"
 	EnvWebSocketDropOnFull          = "WEBSOCKET_DROP_ON_FULL"
@@ -56,9 +96,30 @@ const (
 	EnvWebSocketSendTimeoutMs       = "WEBSOCKET_SEND_TIMEOUT_MS"
 	EnvWebSocketEnableGlobalPing    = "WEBSOCKET_ENABLE_GLOBAL_PING"
 	EnvWebSocketPingWorkers         = "WEBSOCKET_PING_WORKERS"
+	EnvWebSocketRegion              = "WEBSOCKET_REGION"
+	EnvWebSocketEndpoints           = "WEBSOCKET_ENDPOINTS"
+	EnvWebSocketDeadLetterSink      = "WEBSOCKET_DEAD_LETTER_SINK"
+	EnvWebSocketDeadLetterCapacity  = "WEBSOCKET_DEAD_LETTER_CAPACITY"
+	EnvWebSocketDropAlertThreshold  = "WEBSOCKET_DROP_ALERT_THRESHOLD"
+	EnvWebSocketPresenceAwaySeconds = "WEBSOCKET_PRESENCE_AWAY_SECONDS"
+	EnvWebSocketAllowedOrigins      = "WEBSOCKET_ALLOWED_ORIGINS"
+	EnvWebSocketJWTSecret           = "WEBSOCKET_JWT_SECRET"
+	EnvWebSocketJWTIssuer           = "WEBSOCKET_JWT_ISSUER"
+	EnvWebSocketAllowAdhocGroups    = "WEBSOCKET_ALLOW_ADHOC_GROUPS"
+	EnvWebSocketAdaptiveHeartbeat   = "WEBSOCKET_ADAPTIVE_HEARTBEAT"
+	EnvWebSocketPoorLinkBatchMs     = "WEBSOCKET_POOR_LINK_BATCH_MS"
+	EnvWebSocketShutdownDrainSec    = "WEBSOCKET_SHUTDOWN_DRAIN_SECONDS"
+	EnvWebSocketShutdownReconnect   = "WEBSOCKET_SHUTDOWN_RECONNECT_SECONDS"
+	EnvWebSocketMaxChunkedSize      = "WEBSOCKET_MAX_CHUNKED_MESSAGE_SIZE"
+	EnvWebSocketChunkTimeoutSec     = "WEBSOCKET_CHUNK_TIMEOUT_SECONDS"
+	EnvWebSocketCompressThreshold   = "WEBSOCKET_COMPRESSION_THRESHOLD"
+	EnvWebSocketOfflineMessageLimit = "WEBSOCKET_OFFLINE_MESSAGE_LIMIT"
+	EnvWebSocketMaxViolations       = "WEBSOCKET_MAX_VIOLATIONS"
+	EnvWebSocketViolationWindowSec  = "WEBSOCKET_VIOLATION_WINDOW_SECONDS"
 
 	// 错误消息
 	ErrConnectionLimitExceeded = "连接数已达到上限"
+	ErrServerShuttingDown      = "服务器正在关闭"
 	ErrInvalidMessageType      = "无效的消息类型"
 	ErrInvalidMessageData      = "无效的消息数据"
 	ErrUserNotFound            = "用户不存在"
@@ -67,6 +128,15 @@ const (
 	ErrSendBufferFull          = "发送缓冲区已满"
 	ErrReadTimeout             = "读取超时"
 	ErrWriteTimeout            = "写入超时"
+	ErrNotInGroup              = "您不在该组中"
+	ErrInvalidPresence         = "无效的在线状态"
+	ErrInvalidReadReceipt      = "无效的已读回执"
+	ErrInvalidReaction         = "无效的表情回应"
+	ErrInvalidQuality          = "无效的连接质量上报"
+	ErrInvalidMessageTTL       = "无效的消息存活时间"
+	ErrInvalidChunk            = "无效的分片消息"
+	ErrChunkedMessageTooLarge  = "分片消息超过大小限制"
+	ErrTooManyViolations       = "违规消息过多"
 
 	// 成功消息
 	MsgConnectionEstablished = "连接已建立"
@@ -76,11 +146,17 @@ const (
 	MsgStatusUpdated         = "状态已更新"
 
 	// 路由路径
-	RouteWebSocket          = "/ws"
-	RouteWebSocketStats     = "/ws/stats"
-	RouteWebSocketHealth    = "/ws/health"
-	RouteWebSocketMessage   = "/ws/message"
-	RouteWebSocketBroadcast = "/ws/broadcast"
-	RouteWebSocketUser      = "/ws/user/:user_id"
-	RouteWebSocketGroup     = "/ws/group/:group"
+	RouteWebSocket              = "/ws"
+	RouteWebSocketStats         = "/ws/stats"
+	RouteWebSocketHealth        = "/ws/health"
+	RouteWebSocketMessage       = "/ws/message"
+	RouteWebSocketBroadcast     = "/ws/broadcast"
+	RouteWebSocketUser          = "/ws/user/:user_id"
+	RouteWebSocketGroup         = "/ws/group/:group"
+	RouteWebSocketEndpoints     = "/ws/endpoints"
+	RouteWebSocketDeadLetters   = "/ws/deadletters"
+	RouteWebSocketPresence      = "/ws/presence"
+	RouteWebSocketSlowConsumers = "/ws/slow-consumers"
+	RouteWebSocketHandshakes    = "/ws/handshakes"
+	RouteWebSocketConnections   = "/ws/connections"
 )
@@ -0,0 +1,260 @@
+package websocket
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+)
+
+// 会话ID前缀
+const (
+	ConversationGroupPrefix  = "group:"
+	ConversationDirectPrefix = "dm:"
+)
+
+// ConversationTracker 会话消息序号与已读游标的存储，用于统计每个会话的未读数
+type ConversationTracker interface {
+	// OnMessage 为会话分配下一条消息ID，并将发送者的已读游标推进到该消息；recipientID 为私聊对象，组消息为空
+	OnMessage(conversation, senderID, recipientID string) (int64, error)
+	// OnJoin 用户加入组会话时初始化已读游标，加入前的消息不计入未读
+	OnJoin(conversation, userID string) error
+	// MarkRead 推进用户在会话中的已读游标，只前进不后退
+	MarkRead(conversation, userID string, messageID int64) error
+}
+
+// MessageArchiver 接收已分配会话消息ID的聊天消息，用于建立会话内搜索索引；实现不应阻塞
+type MessageArchiver interface {
+	ArchiveMessage(msg Message)
+}
+
+// MessagePersister 持久化已分配会话消息ID的聊天消息，用于历史消息查询；实现不应阻塞
+type MessagePersister interface {
+	PersistMessage(msg Message)
+}
+
+// ReadReceipt 已读回执，客户端上报后转发给会话中的其他成员
+type ReadReceipt struct {
+	Conversation string `json:"conversation"`
+	MessageID    int64  `json:"message_id"`
+	UserID       string `json:"user_id,omitempty"`
+}
+
+// GroupConversation 组会话ID
+func GroupConversation(group string) string {
+	return ConversationGroupPrefix + group
+}
+
+// DirectConversation 私聊会话ID，与参与者顺序无关
+func DirectConversation(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return ConversationDirectPrefix + a + ":" + b
+}
+
+// ParseConversation 解析会话ID，私聊返回对方用户ID，组会话返回组名
+func ParseConversation(conversation, self string) (group, peer string, ok bool) {
+	if g, found := strings.CutPrefix(conversation, ConversationGroupPrefix); found && g != "" {
+		return g, "", true
+	}
+	if pair, found := strings.CutPrefix(conversation, ConversationDirectPrefix); found {
+		a, b, cut := strings.Cut(pair, ":")
+		if !cut || a == "" || b == "" {
+			return "", "", false
+		}
+		switch self {
+		case a:
+			return "", b, true
+		case b:
+			return "", a, true
+		}
+	}
+	return "", "", false
+}
+
+// SetConversationTracker 设置会话未读统计存储
+func (h *Hub) SetConversationTracker(t ConversationTracker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conversations = t
+}
+
+// getConversationTracker 获取会话未读统计存储
+func (h *Hub) getConversationTracker() ConversationTracker {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.conversations
+}
+
+// SetMessageArchiver 设置聊天消息归档，仅归档已分配会话消息ID的消息
+func (h *Hub) SetMessageArchiver(a MessageArchiver) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.archiver = a
+}
+
+// MessageArchiver 当前的聊天消息归档，未设置时为 nil
+func (h *Hub) MessageArchiver() MessageArchiver {
+	return h.getMessageArchiver()
+}
+
+// getMessageArchiver 获取聊天消息归档
+func (h *Hub) getMessageArchiver() MessageArchiver {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.archiver
+}
+
+// SetMessagePersister 设置聊天消息持久化，仅持久化已分配会话消息ID的消息
+func (h *Hub) SetMessagePersister(p MessagePersister) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.persister = p
+}
+
+// getMessagePersister 获取聊天消息持久化
+func (h *Hub) getMessagePersister() MessagePersister {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.persister
+}
+
+// archiveMessage 将已分配ID的聊天消息交给持久化与归档
+func (c *Connection) archiveMessage(msg Message) {
+	if msg.ID <= 0 {
+		return
+	}
+	if p := c.Hub.getMessagePersister(); p != nil {
+		p.PersistMessage(msg)
+	}
+	if a := c.Hub.getMessageArchiver(); a != nil {
+		a.ArchiveMessage(msg)
+	}
+}
+
+// assignMessageID 为聊天消息分配会话内的消息ID
+func (c *Connection) assignMessageID(msg *Message) {
+	t := c.Hub.getConversationTracker()
+	if t == nil {
+		return
+	}
+	if msg.Group != "" {
+		msg.Conversation = GroupConversation(msg.Group)
+	} else {
+		msg.Conversation = DirectConversation(c.UserID, msg.To)
+	}
+	id, err := t.OnMessage(msg.Conversation, c.UserID, msg.To)
+	if err != nil {
+		logrus.Errorf("分配会话消息ID失败: %v", err)
+		return
+	}
+	msg.ID = id
+}
+
+// trackJoin 加入组后初始化会话游标
+func (c *Connection) trackJoin(group string) {
+	t := c.Hub.getConversationTracker()
+	if t == nil || c.UserID == "" {
+		return
+	}
+	if err := t.OnJoin(GroupConversation(group), c.UserID); err != nil {
+		logrus.Errorf("初始化会话游标失败: %v", err)
+	}
+}
+
+// handleRead 处理已读回执，data 为 {"conversation": "...", "message_id": 1}
+func (c *Connection) handleRead(msg Message) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		c.sendError("", ErrInvalidReadReceipt)
+		return
+	}
+	receipt := ReadReceipt{
+		Conversation: cast.ToString(data["conversation"]),
+		MessageID:    cast.ToInt64(data["message_id"]),
+		UserID:       c.UserID,
+	}
+	group, peer, ok := ParseConversation(receipt.Conversation, c.UserID)
+	if !ok || receipt.MessageID <= 0 {
+		c.sendError("", ErrInvalidReadReceipt)
+		return
+	}
+	if group != "" && !c.IsInGroup(group) {
+		c.sendError(group, ErrNotInGroup)
+		return
+	}
+	if err := c.Hub.markRead(receipt, group, peer, c); err != nil {
+		logrus.Errorf("更新已读游标失败: %v", err)
+	}
+}
+
+// NotifyAttachment 向会话的全部在线成员（含上传者的其他设备）推送新附件，from 为上传者
+func (h *Hub) NotifyAttachment(conversation, from string, attachment interface{}) {
+	h.sendConversationNotice(conversation, &Message{
+		Type:         MessageTypeAttachment,
+		Data:         attachment,
+		From:         from,
+		Conversation: conversation,
+		Timestamp:    time.Now().Unix(),
+	})
+}
+
+// MarkRead 代替用户提交已读回执（如移动端离线后批量上报），更新已读游标并向会话其他成员以及用户的全部设备广播。
+// 调用方负责校验用户有权访问该会话
+func (h *Hub) MarkRead(conversation, userID string, messageID int64) (*ReadReceipt, error) {
+	group, peer, ok := ParseConversation(conversation, userID)
+	if !ok || messageID <= 0 {
+		return nil, errors.New(ErrInvalidReadReceipt)
+	}
+	receipt := ReadReceipt{Conversation: conversation, MessageID: messageID, UserID: userID}
+	if err := h.markRead(receipt, group, peer, nil); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// markRead 更新已读游标并广播回执，from 为发起回执的连接，私聊时不回发给它
+func (h *Hub) markRead(receipt ReadReceipt, group, peer string, from *Connection) error {
+	if t := h.getConversationTracker(); t != nil {
+		if err := t.MarkRead(receipt.Conversation, receipt.UserID, receipt.MessageID); err != nil {
+			return err
+		}
+	}
+
+	em, err := newEncodedMessage(&Message{
+		Type:         MessageTypeRead,
+		Data:         receipt,
+		From:         receipt.UserID,
+		Group:        group,
+		Conversation: receipt.Conversation,
+		Timestamp:    time.Now().Unix(),
+	})
+	if err != nil {
+		logrus.Errorf("消息序列化失败: %v", err)
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if group != "" {
+		h.sendEphemeralLocked(h.groupConnections[group], receipt.UserID, em)
+		return nil
+	}
+	// 私聊回执同时同步给自己的其他设备
+	h.sendEphemeralLocked(h.userConnections[peer], receipt.UserID, em)
+	for connID := range h.userConnections[receipt.UserID] {
+		if conn, ok := h.connections[connID]; ok && conn != from && conn.IsAlive {
+			out := em.bytesFor(conn.codec)
+			if out == nil {
+				continue
+			}
+			select {
+			case conn.Send <- out:
+			default:
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// 消息丢弃原因
+const (
+	// DropReasonBroadcastQueueFull Hub 广播队列已满
+	DropReasonBroadcastQueueFull = "broadcast_queue_full"
+	// DropReasonBroadcastJobsFull 分片广播作业队列已满
+	DropReasonBroadcastJobsFull = "broadcast_jobs_full"
+	// DropReasonSendBufferFull 连接发送缓冲区已满或发送超时
+	DropReasonSendBufferFull = "send_buffer_full"
+)
+
+// 死信投递目标类型
+const (
+	DeadLetterTargetUser       = "user"
+	DeadLetterTargetConnection = "connection"
+	DeadLetterTargetShard      = "shard"
+)
+
+// 死信存储类型
+const (
+	DeadLetterSinkMemory = "memory"
+	DeadLetterSinkDB     = "db"
+)
+
+const (
+	// deadLetterBufferSize 待写入死信的缓冲，写满后只计数不再记录
+	deadLetterBufferSize = 1024
+	// deadLetterFlushInterval 死信批量写入间隔
+	deadLetterFlushInterval = time.Second
+	// deadLetterFlushBatch 单批最多写入的死信数
+	deadLetterFlushBatch = 100
+	// dropAlertWindow 丢弃率统计窗口
+	dropAlertWindow = time.Minute
+)
+
+var (
+	// ErrDeadLetterNotFound 死信不存在
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrDeadLetterDisabled 未配置死信存储
+	ErrDeadLetterDisabled = errors.New("dead letter sink is not configured")
+	// ErrDeadLetterTargetOffline 重放目标当前不在线
+	ErrDeadLetterTargetOffline = errors.New("dead letter target is offline")
+)
+
+var (
+	droppedMessagesCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_dropped_messages_total",
+			Help: "Total number of WebSocket messages dropped by reason",
+		},
+		[]string{"reason"},
+	)
+	dropRateGauge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "websocket_dropped_messages_per_minute",
+			Help: "Number of WebSocket messages dropped during the last alert window",
+		},
+	)
+)
+
+// DeadLetter 被丢弃的消息及其丢弃原因与投递目标
+type DeadLetter struct {
+	ID         string          `json:"id"`
+	Reason     string          `json:"reason"`
+	TargetType string          `json:"targetType"`
+	Target     string          `json:"target"`
+	UserID     string          `json:"userId,omitempty"`
+	NodeID     string          `json:"nodeId"`
+	Payload    json.RawMessage `json:"payload"`
+	DroppedAt  time.Time       `json:"droppedAt"`
+	ReplayedAt *time.Time      `json:"replayedAt,omitempty"`
+}
+
+// DeadLetterFilter 死信查询条件
+type DeadLetterFilter struct {
+	Reason     string
+	TargetType string
+	Target     string
+	Since      time.Time
+	// 是否包含已重放的死信
+	IncludeReplayed bool
+	Limit           int
+}
+
+// Match 判断死信是否满足查询条件
+func (f DeadLetterFilter) Match(dl *DeadLetter) bool {
+	if f.Reason != "" && dl.Reason != f.Reason {
+		return false
+	}
+	if f.TargetType != "" && dl.TargetType != f.TargetType {
+		return false
+	}
+	if f.Target != "" && dl.Target != f.Target && dl.UserID != f.Target {
+		return false
+	}
+	if !f.Since.IsZero() && dl.DroppedAt.Before(f.Since) {
+		return false
+	}
+	return f.IncludeReplayed || dl.ReplayedAt == nil
+}
+
+// DeadLetterSink 死信存储，可基于缓存或数据库实现
+type DeadLetterSink interface {
+	// Save 批量保存死信
+	Save(ctx context.Context, letters []DeadLetter) error
+	// List 按丢弃时间倒序查询死信
+	List(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error)
+	// Get 获取死信，不存在时返回 ErrDeadLetterNotFound
+	Get(ctx context.Context, id string) (*DeadLetter, error)
+	// MarkReplayed 标记死信已重放
+	MarkReplayed(ctx context.Context, id string, at time.Time) error
+	// Delete 删除死信
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryDeadLetterSink 进程内环形缓冲死信存储，超出容量时淘汰最早的死信
+type MemoryDeadLetterSink struct {
+	mu       sync.RWMutex
+	capacity int
+	letters  []DeadLetter
+}
+
+// NewMemoryDeadLetterSink 创建进程内死信存储
+func NewMemoryDeadLetterSink(capacity int) *MemoryDeadLetterSink {
+	if capacity <= 0 {
+		capacity = 10000
+	}
+	return &MemoryDeadLetterSink{capacity: capacity}
+}
+
+func (s *MemoryDeadLetterSink) Save(_ context.Context, letters []DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(s.letters, letters...)
+	if over := len(s.letters) - s.capacity; over > 0 {
+		s.letters = append([]DeadLetter(nil), s.letters[over:]...)
+	}
+	return nil
+}
+
+func (s *MemoryDeadLetterSink) List(_ context.Context, filter DeadLetterFilter) ([]DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []DeadLetter
+	for i := len(s.letters) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(out) >= filter.Limit {
+			break
+		}
+		if filter.Match(&s.letters[i]) {
+			out = append(out, s.letters[i])
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryDeadLetterSink) Get(_ context.Context, id string) (*DeadLetter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for i := range s.letters {
+		if s.letters[i].ID == id {
+			dl := s.letters[i]
+			return &dl, nil
+		}
+	}
+	return nil, ErrDeadLetterNotFound
+}
+
+func (s *MemoryDeadLetterSink) MarkReplayed(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.letters {
+		if s.letters[i].ID == id {
+			s.letters[i].ReplayedAt = &at
+			return nil
+		}
+	}
+	return ErrDeadLetterNotFound
+}
+
+func (s *MemoryDeadLetterSink) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.letters {
+		if s.letters[i].ID == id {
+			s.letters = append(s.letters[:i], s.letters[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDeadLetterNotFound
+}
+
+// DropAlert 丢弃率告警
+type DropAlert struct {
+	NodeID    string           `json:"nodeId"`
+	Region    string           `json:"region"`
+	Drops     int64            `json:"drops"`
+	Threshold int              `json:"threshold"`
+	Window    time.Duration    `json:"window"`
+	ByReason  map[string]int64 `json:"byReason"`
+	At        time.Time        `json:"at"`
+}
+
+// dropTracker 统计窗口内各原因的丢弃数
+type dropTracker struct {
+	mu       sync.Mutex
+	byReason map[string]int64
+	// 死信缓冲写满而未记录的数量
+	lost int64
+}
+
+func (t *dropTracker) add(reason string) {
+	t.mu.Lock()
+	if t.byReason == nil {
+		t.byReason = make(map[string]int64)
+	}
+	t.byReason[reason]++
+	t.mu.Unlock()
+}
+
+// reset 返回窗口内的统计并清零
+func (t *dropTracker) reset() (int64, map[string]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total int64
+	for _, n := range t.byReason {
+		total += n
+	}
+	byReason := t.byReason
+	t.byReason = nil
+	return total, byReason
+}
+
+// SetDeadLetterSink 设置死信存储，为空时仅统计丢弃数
+func (h *Hub) SetDeadLetterSink(sink DeadLetterSink) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.deadLetterSink = sink
+	h.deadLetterEnabled.Store(sink != nil)
+}
+
+// DeadLetters 获取死信存储
+func (h *Hub) DeadLetters() DeadLetterSink {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.deadLetterSink
+}
+
+// SetDropAlertHandler 设置丢弃率告警回调，未设置时仅记录日志
+func (h *Hub) SetDropAlertHandler(fn func(DropAlert)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dropAlertHandler = fn
+}
+
+// recordDrop 记录被丢弃的消息，在持有 h.mu 读锁时也可调用，不会阻塞发送路径
+func (h *Hub) recordDrop(reason, targetType, target, userID string, data []byte) {
+	droppedMessagesCounter.WithLabelValues(reason).Inc()
+	h.drops.add(reason)
+	h.counters.drops.Add(1)
+	if !h.deadLetterEnabled.Load() {
+		return
+	}
+
+	dl := DeadLetter{
+		ID:         uuid.NewString(),
+		Reason:     reason,
+		TargetType: targetType,
+		Target:     target,
+		UserID:     userID,
+		NodeID:     h.nodeID(),
+		Payload:    json.RawMessage(data),
+		DroppedAt:  time.Now(),
+	}
+	select {
+	case h.deadLetterCh <- dl:
+	default:
+		atomic.AddInt64(&h.drops.lost, 1)
+	}
+}
+
+// deadLetterLoop 批量写入死信并按窗口检查丢弃率
+func (h *Hub) deadLetterLoop() {
+	flush := time.NewTicker(deadLetterFlushInterval)
+	defer flush.Stop()
+	alert := time.NewTicker(dropAlertWindow)
+	defer alert.Stop()
+
+	batch := make([]DeadLetter, 0, deadLetterFlushBatch)
+	save := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if sink := h.DeadLetters(); sink != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := sink.Save(ctx, batch); err != nil {
+				logrus.Errorf("保存WS死信失败, 数量: %d, 错误: %v", len(batch), err)
+			}
+			cancel()
+		}
+		batch = make([]DeadLetter, 0, deadLetterFlushBatch)
+	}
+	for {
+		select {
+		case <-h.ctx.Done():
+			save()
+			return
+		case dl := <-h.deadLetterCh:
+			batch = append(batch, dl)
+			if len(batch) >= deadLetterFlushBatch {
+				save()
+			}
+		case <-flush.C:
+			save()
+		case now := <-alert.C:
+			h.checkDropRate(now)
+		}
+	}
+}
+
+// checkDropRate 统计上一窗口的丢弃数，超过阈值时触发告警
+func (h *Hub) checkDropRate(now time.Time) {
+	drops, byReason := h.drops.reset()
+	dropRateGauge.Set(float64(drops))
+	if lost := atomic.SwapInt64(&h.drops.lost, 0); lost > 0 {
+		logrus.Warnf("WS死信缓冲已满, %d 条丢弃消息未记录", lost)
+	}
+	threshold := h.config.DropAlertThreshold
+	if threshold <= 0 || drops < int64(threshold) {
+		return
+	}
+
+	alert := DropAlert{
+		NodeID:    h.nodeID(),
+		Region:    h.config.Region,
+		Drops:     drops,
+		Threshold: threshold,
+		Window:    dropAlertWindow,
+		ByReason:  byReason,
+		At:        now,
+	}
+	h.mu.RLock()
+	fn := h.dropAlertHandler
+	h.mu.RUnlock()
+	if fn != nil {
+		fn(alert)
+		return
+	}
+	logrus.Errorf("WS消息丢弃率过高: 节点 %s 最近 %s 丢弃 %d 条 (阈值 %d), 原因: %v",
+		alert.NodeID, alert.Window, drops, threshold, byReason)
+}
+
+// ReplayDeadLetter 重新投递单条死信，成功后标记为已重放；消息已过期时同样标记并返回 ErrMessageExpired
+func (h *Hub) ReplayDeadLetter(ctx context.Context, id string) error {
+	sink := h.DeadLetters()
+	if sink == nil {
+		return ErrDeadLetterDisabled
+	}
+	dl, err := sink.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	deliverErr := h.redeliver(dl)
+	if deliverErr != nil && !errors.Is(deliverErr, ErrMessageExpired) {
+		return deliverErr
+	}
+	if err := sink.MarkReplayed(ctx, id, time.Now()); err != nil {
+		return err
+	}
+	return deliverErr
+}
+
+// ReplayResult 批量重放结果
+type ReplayResult struct {
+	Replayed int `json:"replayed"`
+	// 已过期的消息不再投递，直接标记为已重放
+	Expired int               `json:"expired,omitempty"`
+	Failed  map[string]string `json:"failed,omitempty"`
+}
+
+// ReplayDeadLetters 按条件批量重放未重放过的死信，按丢弃时间先后投递
+func (h *Hub) ReplayDeadLetters(ctx context.Context, filter DeadLetterFilter) (ReplayResult, error) {
+	result := ReplayResult{}
+	sink := h.DeadLetters()
+	if sink == nil {
+		return result, ErrDeadLetterDisabled
+	}
+	filter.IncludeReplayed = false
+	letters, err := sink.List(ctx, filter)
+	if err != nil {
+		return result, err
+	}
+	sort.SliceStable(letters, func(i, j int) bool { return letters[i].DroppedAt.Before(letters[j].DroppedAt) })
+	for i := range letters {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		dl := &letters[i]
+		err := h.redeliver(dl)
+		if errors.Is(err, ErrMessageExpired) {
+			if err := sink.MarkReplayed(ctx, dl.ID, time.Now()); err != nil {
+				return result, err
+			}
+			result.Expired++
+			continue
+		}
+		if err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[dl.ID] = err.Error()
+			continue
+		}
+		if err := sink.MarkReplayed(ctx, dl.ID, time.Now()); err != nil {
+			return result, err
+		}
+		result.Replayed++
+	}
+	return result, nil
+}
+
+// redeliver 按死信目标重新投递；原连接已断开时改投该用户的其他连接
+func (h *Hub) redeliver(dl *DeadLetter) error {
+	if payloadExpired(dl.Payload, time.Now()) {
+		return ErrMessageExpired
+	}
+	em := rawEncodedMessage([]byte(dl.Payload))
+	switch dl.TargetType {
+	case DeadLetterTargetShard:
+		shard, err := strconv.Atoi(dl.Target)
+		if err != nil || shard < 0 || shard >= h.shardCount {
+			return fmt.Errorf("invalid shard %q", dl.Target)
+		}
+		select {
+		case h.broadcastJobs <- broadcastJob{kind: _broadcastAll, shard: shard, msg: em}:
+			return nil
+		default:
+			return errors.New(ErrSendBufferFull)
+		}
+	case DeadLetterTargetConnection, DeadLetterTargetUser:
+		// 持有读锁投递，避免连接注销时关闭发送通道
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		var conns []*Connection
+		if dl.TargetType == DeadLetterTargetConnection {
+			if conn, ok := h.connections[dl.Target]; ok && conn.IsAlive {
+				conns = append(conns, conn)
+			}
+		}
+		if len(conns) == 0 {
+			userID := dl.UserID
+			if dl.TargetType == DeadLetterTargetUser {
+				userID = dl.Target
+			}
+			for connID := range h.userConnections[userID] {
+				if conn, ok := h.connections[connID]; ok && conn.IsAlive {
+					conns = append(conns, conn)
+				}
+			}
+		}
+		return deliverTo(conns, em)
+	}
+	return fmt.Errorf("unknown dead letter target type %q", dl.TargetType)
+}
+
+// deliverTo 非阻塞投递，至少一个连接接收成功即视为成功，重放时不再产生新的死信
+func deliverTo(conns []*Connection, em *encodedMessage) error {
+	if len(conns) == 0 {
+		return ErrDeadLetterTargetOffline
+	}
+	delivered := false
+	for _, conn := range conns {
+		data := em.bytesFor(conn.codec)
+		if data == nil {
+			continue
+		}
+		select {
+		case conn.Send <- data:
+			delivered = true
+		default:
+		}
+	}
+	if !delivered {
+		return errors.New(ErrSendBufferFull)
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// 节点健康状态
+const (
+	EndpointHealthy   = "healthy"
+	EndpointUnhealthy = "unhealthy"
+	EndpointUnknown   = "unknown"
+)
+
+const (
+	// endpointProbeInterval 远端节点健康探测间隔
+	endpointProbeInterval = 30 * time.Second
+	// endpointLatencyAlpha 客户端延迟上报的指数平滑系数
+	endpointLatencyAlpha = 0.3
+	// maxReportedLatency 超过该值的延迟上报视为无效
+	maxReportedLatency = 60000
+	// endpointReportInterval 同一客户端两次测速上报的最小间隔
+	endpointReportInterval = 10 * time.Second
+	// endpointLatencyTTL 客户端测速结果的保留时长，超时未上报视为未测速
+	endpointLatencyTTL = 30 * time.Minute
+	// maxLatencyClients 保留测速结果的客户端数上限，超出时淘汰最早上报的客户端
+	maxLatencyClients = 10000
+)
+
+// ErrLatencyReportTooFrequent 客户端测速上报过于频繁
+var ErrLatencyReportTooFrequent = errors.New("latency report too frequent")
+
+var (
+	hubConnectionsGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "websocket_hub_connections",
+			Help: "Current number of WebSocket connections on this hub",
+		},
+		[]string{"region", "node"},
+	)
+	endpointHealthyGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "websocket_endpoint_healthy",
+			Help: "Whether a WebSocket endpoint passed its last health probe (1 healthy, 0 unhealthy)",
+		},
+		[]string{"endpoint", "region"},
+	)
+)
+
+// Endpoint 可供客户端连接的WS节点
+type Endpoint struct {
+	ID     string `json:"id"`
+	Region string `json:"region"`
+	// WS 连接地址，本节点为空时由请求地址推导
+	URL string `json:"url"`
+	// 健康检查与客户端测速地址，为空时由 URL 推导
+	ProbeURL string `json:"probeUrl"`
+}
+
+// EndpointStatus 节点及其健康状态与请求方客户端测得的延迟
+type EndpointStatus struct {
+	Endpoint
+	Local     bool      `json:"local"`
+	Health    string    `json:"health"`
+	LatencyMs float64   `json:"latencyMs,omitempty"`
+	Samples   int64     `json:"samples"`
+	CheckedAt time.Time `json:"checkedAt,omitempty"`
+}
+
+// ParseEndpoints 解析节点列表，逗号分隔，每项格式为 id|region|url[|probeUrl]
+func ParseEndpoints(s string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, "|")
+		if len(parts) < 3 || len(parts) > 4 {
+			return nil, fmt.Errorf("invalid endpoint %q, expected id|region|url[|probeUrl]", item)
+		}
+		ep := Endpoint{ID: strings.TrimSpace(parts[0]), Region: strings.TrimSpace(parts[1]), URL: strings.TrimSpace(parts[2])}
+		if len(parts) == 4 {
+			ep.ProbeURL = strings.TrimSpace(parts[3])
+		}
+		if ep.ID == "" || ep.URL == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, id and url are required", item)
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+// probeURLFor 将 ws(s) 地址转换为 http(s) 测速地址
+func probeURLFor(wsURL string) string {
+	u := wsURL
+	switch {
+	case strings.HasPrefix(u, "wss://"):
+		u = "https://" + strings.TrimPrefix(u, "wss://")
+	case strings.HasPrefix(u, "ws://"):
+		u = "http://" + strings.TrimPrefix(u, "ws://")
+	}
+	return strings.TrimSuffix(u, "/") + "/endpoints/ping"
+}
+
+// endpointState 单个节点的运行时状态
+type endpointState struct {
+	ep        Endpoint
+	local     bool
+	health    string
+	checkedAt time.Time
+}
+
+// latencySample 单个客户端到某个节点的平滑延迟
+type latencySample struct {
+	latency float64
+	samples int64
+}
+
+// clientLatency 单个客户端上报的各节点延迟，延迟只反映该客户端所在网络，不在客户端之间共享
+type clientLatency struct {
+	endpoints  map[string]*latencySample
+	reportedAt time.Time
+}
+
+// EndpointRegistry 维护多区域WS节点的健康状态与各客户端上报的延迟
+type EndpointRegistry struct {
+	hub       *Hub
+	mu        sync.RWMutex
+	states    []*endpointState
+	latencies map[string]*clientLatency
+	client    *http.Client
+	// reportInterval 同一客户端两次上报的最小间隔
+	reportInterval time.Duration
+}
+
+// newEndpointRegistry 根据Hub配置创建节点注册表，与 ClusterNodeID 相同的节点视为本节点
+func newEndpointRegistry(hub *Hub) *EndpointRegistry {
+	r := &EndpointRegistry{
+		hub:            hub,
+		latencies:      make(map[string]*clientLatency),
+		client:         &http.Client{Timeout: 3 * time.Second},
+		reportInterval: endpointReportInterval,
+	}
+	localID := hub.nodeID()
+	hasLocal := false
+	for _, ep := range hub.config.Endpoints {
+		st := &endpointState{ep: ep, health: EndpointUnknown}
+		if ep.ProbeURL == "" {
+			st.ep.ProbeURL = probeURLFor(ep.URL)
+		}
+		if ep.ID == localID {
+			st.local, hasLocal = true, true
+		}
+		r.states = append(r.states, st)
+	}
+	if !hasLocal {
+		r.states = append(r.states, &endpointState{
+			ep:    Endpoint{ID: localID, Region: hub.config.Region},
+			local: true,
+		})
+	}
+	if len(r.states) > 1 {
+		go r.probeLoop(hub.ctx)
+	}
+	return r
+}
+
+// probeLoop 定期探测远端节点健康状态
+func (r *EndpointRegistry) probeLoop(ctx context.Context) {
+	r.Probe(ctx)
+	ticker := time.NewTicker(endpointProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Probe(ctx)
+		}
+	}
+}
+
+// Probe 探测所有远端节点，测速地址返回 2xx 视为健康
+func (r *EndpointRegistry) Probe(ctx context.Context) {
+	r.mu.RLock()
+	targets := make([]Endpoint, 0, len(r.states))
+	for _, st := range r.states {
+		if !st.local {
+			targets = append(targets, st.ep)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, ep := range targets {
+		health := EndpointUnhealthy
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.ProbeURL, nil)
+		if err == nil {
+			resp, err := r.client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+					health = EndpointHealthy
+				}
+			} else if ctx.Err() == nil {
+				logrus.Debugf("WS节点探测失败 %s: %v", ep.ID, err)
+			}
+		}
+		r.setHealth(ep.ID, health)
+	}
+}
+
+// setHealth 更新节点健康状态
+func (r *EndpointRegistry) setHealth(id, health string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, st := range r.states {
+		if st.ep.ID == id {
+			st.health = health
+			st.checkedAt = time.Now()
+			v := 0.0
+			if health == EndpointHealthy {
+				v = 1
+			}
+			endpointHealthyGauge.WithLabelValues(st.ep.ID, st.ep.Region).Set(v)
+			return
+		}
+	}
+}
+
+// ReportLatency 记录客户端测得的各节点延迟（毫秒），按客户端分别指数平滑，返回接受的样本数；
+// 同一客户端上报间隔小于 reportInterval 时返回 ErrLatencyReportTooFrequent
+func (r *EndpointRegistry) ReportLatency(client string, samples map[string]float64) (int, error) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cl := r.latencies[client]
+	if cl != nil && now.Sub(cl.reportedAt) < r.reportInterval {
+		return 0, ErrLatencyReportTooFrequent
+	}
+	if cl == nil || now.Sub(cl.reportedAt) >= endpointLatencyTTL {
+		if cl == nil && len(r.latencies) >= maxLatencyClients {
+			r.evictLatencies(now)
+		}
+		cl = &clientLatency{endpoints: make(map[string]*latencySample)}
+		r.latencies[client] = cl
+	}
+	cl.reportedAt = now
+
+	accepted := 0
+	for id, ms := range samples {
+		if ms <= 0 || ms > maxReportedLatency || !r.hasEndpoint(id) {
+			continue
+		}
+		sample := cl.endpoints[id]
+		if sample == nil {
+			sample = &latencySample{latency: ms}
+			cl.endpoints[id] = sample
+		} else {
+			sample.latency = endpointLatencyAlpha*ms + (1-endpointLatencyAlpha)*sample.latency
+		}
+		sample.samples++
+		accepted++
+	}
+	return accepted, nil
+}
+
+// hasEndpoint 节点是否存在，调用方需持有锁
+func (r *EndpointRegistry) hasEndpoint(id string) bool {
+	for _, st := range r.states {
+		if st.ep.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// evictLatencies 删除过期的客户端测速结果，仍达到上限时淘汰最早上报的客户端，调用方需持有锁
+func (r *EndpointRegistry) evictLatencies(now time.Time) {
+	oldest := ""
+	for client, cl := range r.latencies {
+		if now.Sub(cl.reportedAt) >= endpointLatencyTTL {
+			delete(r.latencies, client)
+		} else if oldest == "" || cl.reportedAt.Before(r.latencies[oldest].reportedAt) {
+			oldest = client
+		}
+	}
+	if len(r.latencies) >= maxLatencyClients && oldest != "" {
+		delete(r.latencies, oldest)
+	}
+}
+
+// Candidates 返回 client 的候选节点：健康优先，其次优先指定区域，再按该客户端测得的延迟升序，未测速的排在最后
+func (r *EndpointRegistry) Candidates(client, preferRegion string) []EndpointStatus {
+	r.mu.RLock()
+	var measured map[string]*latencySample
+	if cl := r.latencies[client]; cl != nil && time.Since(cl.reportedAt) < endpointLatencyTTL {
+		measured = cl.endpoints
+	}
+	list := make([]EndpointStatus, 0, len(r.states))
+	for _, st := range r.states {
+		status := EndpointStatus{
+			Endpoint:  st.ep,
+			Local:     st.local,
+			Health:    st.health,
+			CheckedAt: st.checkedAt,
+		}
+		if sample := measured[st.ep.ID]; sample != nil {
+			status.LatencyMs, status.Samples = sample.latency, sample.samples
+		}
+		if st.local {
+			status.Health = r.hub.localHealth()
+			status.CheckedAt = time.Now()
+		}
+		list = append(list, status)
+	}
+	r.mu.RUnlock()
+
+	rank := func(health string) int {
+		switch health {
+		case EndpointHealthy:
+			return 0
+		case EndpointUnknown:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if rank(a.Health) != rank(b.Health) {
+			return rank(a.Health) < rank(b.Health)
+		}
+		if preferRegion != "" && (a.Region == preferRegion) != (b.Region == preferRegion) {
+			return a.Region == preferRegion
+		}
+		if (a.Samples > 0) != (b.Samples > 0) {
+			return a.Samples > 0
+		}
+		return a.LatencyMs < b.LatencyMs
+	})
+	return list
+}
+
+// Endpoints 获取节点注册表
+func (h *Hub) Endpoints() *EndpointRegistry {
+	return h.endpoints
+}
+
+// Region 本节点所在区域
+func (h *Hub) Region() string {
+	return h.config.Region
+}
+
+// nodeID 本节点ID，未配置 ClusterNodeID 时为 local
+func (h *Hub) nodeID() string {
+	if h.config.ClusterNodeID != "" {
+		return h.config.ClusterNodeID
+	}
+	return "local"
+}
+
+// localHealth 本节点健康状态：Hub 已关闭或连接数达到上限时不健康
+func (h *Hub) localHealth() string {
+	if h.ctx.Err() != nil || h.GetConnectionCount() >= h.config.MaxConnections {
+		return EndpointUnhealthy
+	}
+	return EndpointHealthy
+}
+
+// observeConnections 按区域与节点标签记录连接数
+func (h *Hub) observeConnections(count int64) {
+	hubConnectionsGauge.WithLabelValues(h.config.Region, h.nodeID()).Set(float64(count))
+}
@@ -0,0 +1,153 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxMessageTTL 单条消息允许的最长存活时间
+const MaxMessageTTL = 30 * 24 * time.Hour
+
+// ErrMessageExpired 消息已过期，不再投递
+var ErrMessageExpired = errors.New("message expired")
+
+// MessageExpiryPolicy 会话级消息存活时间设置，客户端未指定 ttl 时使用
+type MessageExpiryPolicy interface {
+	// MessageTTL 会话默认的消息存活时间，为 0 时消息不过期
+	MessageTTL(conversation string) time.Duration
+}
+
+// MessagesExpired 消息过期通知，存储中的消息被清理后下发给会话成员
+type MessagesExpired struct {
+	Conversation string  `json:"conversation"`
+	MessageIDs   []int64 `json:"message_ids"`
+}
+
+// ConversationTTLChanged 会话默认消息存活时间变更通知
+type ConversationTTLChanged struct {
+	Conversation string `json:"conversation"`
+	// 新的默认存活秒数，0 表示关闭
+	TTL    int64  `json:"ttl"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Expired 消息是否已过期，未设置过期时间的消息永不过期
+func (m *Message) Expired(now time.Time) bool {
+	return m.ExpiresAt > 0 && now.Unix() >= m.ExpiresAt
+}
+
+// SetExpiryPolicy 设置会话默认消息存活时间
+func (h *Hub) SetExpiryPolicy(p MessageExpiryPolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.expiry = p
+}
+
+// getExpiryPolicy 获取会话默认消息存活时间
+func (h *Hub) getExpiryPolicy() MessageExpiryPolicy {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.expiry
+}
+
+// applyExpiry 计算聊天消息的过期时间：优先使用客户端指定的 ttl，否则使用会话默认值；
+// ttl 为负数或超过 MaxMessageTTL 时返回 false
+func (c *Connection) applyExpiry(msg *Message) bool {
+	if msg.TTL < 0 || time.Duration(msg.TTL)*time.Second > MaxMessageTTL {
+		return false
+	}
+	msg.ExpiresAt = 0
+	ttl := time.Duration(msg.TTL) * time.Second
+	if ttl == 0 {
+		if p := c.Hub.getExpiryPolicy(); p != nil {
+			conversation := msg.Conversation
+			if conversation == "" {
+				if msg.Group != "" {
+					conversation = GroupConversation(msg.Group)
+				} else {
+					conversation = DirectConversation(c.UserID, msg.To)
+				}
+			}
+			ttl = min(p.MessageTTL(conversation), MaxMessageTTL)
+		}
+	}
+	if ttl <= 0 {
+		msg.TTL = 0
+		return true
+	}
+	msg.TTL = int64(ttl / time.Second)
+	msg.ExpiresAt = time.Now().Add(ttl).Unix()
+	return true
+}
+
+// payloadExpired 解析 JSON 载荷中的过期时间，用于死信等已序列化的消息
+func payloadExpired(data []byte, now time.Time) bool {
+	var meta struct {
+		ExpiresAt int64 `json:"expires_at"`
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return false
+	}
+	return meta.ExpiresAt > 0 && now.Unix() >= meta.ExpiresAt
+}
+
+// NotifyMessagesExpired 通知会话成员消息已过期，客户端应删除本地副本；
+// 组会话发送给组内所有连接，私聊发送给双方的所有设备
+func (h *Hub) NotifyMessagesExpired(conversation string, messageIDs []int64) {
+	if len(messageIDs) == 0 {
+		return
+	}
+	h.sendConversationNotice(conversation, &Message{
+		Type:         MessageTypeMessagesExpired,
+		Data:         MessagesExpired{Conversation: conversation, MessageIDs: messageIDs},
+		Conversation: conversation,
+		Timestamp:    time.Now().Unix(),
+	})
+}
+
+// NotifyConversationTTL 通知会话成员默认消息存活时间已变更
+func (h *Hub) NotifyConversationTTL(conversation string, ttl time.Duration, userID string) {
+	h.sendConversationNotice(conversation, &Message{
+		Type:         MessageTypeConversationTTL,
+		Data:         ConversationTTLChanged{Conversation: conversation, TTL: int64(ttl / time.Second), UserID: userID},
+		From:         userID,
+		Conversation: conversation,
+		Timestamp:    time.Now().Unix(),
+	})
+}
+
+// sendConversationNotice 向会话的全部在线成员发送服务端通知
+func (h *Hub) sendConversationNotice(conversation string, msg *Message) {
+	var group string
+	var users []string
+	if g, ok := strings.CutPrefix(conversation, ConversationGroupPrefix); ok && g != "" {
+		group = g
+	} else if pair, ok := strings.CutPrefix(conversation, ConversationDirectPrefix); ok {
+		a, b, cut := strings.Cut(pair, ":")
+		if !cut || a == "" || b == "" {
+			return
+		}
+		users = []string{a, b}
+	} else {
+		return
+	}
+	msg.Group = group
+	em, err := newEncodedMessage(msg)
+	if err != nil {
+		logrus.Errorf("消息序列化失败: %v", err)
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if group != "" {
+		h.sendEphemeralLocked(h.groupConnections[group], "", em)
+		return
+	}
+	for _, u := range users {
+		h.sendEphemeralLocked(h.userConnections[u], "", em)
+	}
+}
@@ -2,23 +2,67 @@ package websocket
 
 import (
 	constants "HibiscusIM/pkg/constant"
-	"HibiscusIM/pkg/logger"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // Handler WebSocket HTTP处理器
 type Handler struct {
 	hub *Hub
+	// adminAuth 判断当前请求是否具备管理员权限（死信查询与重放），未设置时一律拒绝
+	adminAuth func(c *gin.Context) bool
+	// sessionUser 从会话等已有登录态解析用户ID，优先于令牌认证
+	sessionUser func(c *gin.Context) string
+	// authenticator 校验握手令牌
+	authenticator Authenticator
+	// statsHistory Hub 指标历史，用于容量报告与导出
+	statsHistory StatsHistory
 }
 
-// NewHandler 创建新的WebSocket处理器
+// NewHandler 创建新的WebSocket处理器，配置了 JWTSecret 时默认启用 JWT 握手认证
 func NewHandler(hub *Hub) *Handler {
-	return &Handler{
+	h := &Handler{
 		hub: hub,
 	}
+	if hub.config.JWTSecret != "" {
+		h.authenticator = NewJWTAuthenticator(hub.config.JWTSecret, hub.config.JWTIssuer)
+	}
+	return h
+}
+
+// SetSessionResolver 设置会话用户解析，返回空字符串表示未登录
+func (h *Handler) SetSessionResolver(fn func(c *gin.Context) string) {
+	h.sessionUser = fn
+}
+
+// SetAuthenticator 设置握手令牌认证器
+func (h *Handler) SetAuthenticator(a Authenticator) {
+	h.authenticator = a
+}
+
+// Authenticator 当前握手令牌认证器
+func (h *Handler) Authenticator() Authenticator {
+	return h.authenticator
+}
+
+// SetAdminAuthorizer 设置管理员权限检查
+func (h *Handler) SetAdminAuthorizer(fn func(c *gin.Context) bool) {
+	h.adminAuth = fn
+}
+
+// RequireAdmin 管理员权限中间件
+func (h *Handler) RequireAdmin(c *gin.Context) {
+	if h.adminAuth == nil || !h.adminAuth(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+		return
+	}
+	c.Next()
 }
 
 // RegisterRoutes 统一注册路由
@@ -28,27 +72,76 @@ func RegisterRoutes(r *gin.Engine, handler *Handler) {
 	r.GET(RouteWebSocketHealth, handler.HealthCheck)
 	r.POST(RouteWebSocketMessage, handler.SendMessage)
 	r.POST(RouteWebSocketBroadcast, handler.BroadcastMessage)
+	r.GET(RouteWebSocketEndpoints, handler.GetEndpoints)
+	r.GET(RouteWebSocketEndpoints+"/ping", handler.PingEndpoint)
+	r.POST(RouteWebSocketEndpoints+"/latency", handler.ReportEndpointLatency)
+
+	r.GET(RouteWebSocketPresence+"/:user_id", handler.GetPresence)
+	r.GET(RouteWebSocketPresence+"/group/:group", handler.GetGroupPresence)
+	r.POST(RouteWebSocketPresence+"/query", handler.QueryPresence)
+
+	slow := r.Group(RouteWebSocketSlowConsumers, handler.RequireAdmin)
+	slow.GET("", handler.ListSlowConsumers)
+	slow.POST("/close", handler.CloseSlowConsumers)
+	slow.DELETE("/:id", handler.CloseSlowConsumer)
+
+	r.GET(RouteWebSocketHandshakes, handler.RequireAdmin, handler.GetHandshakeStats)
+	r.GET(RouteWebSocketConnections, handler.RequireAdmin, handler.ListConnections)
+
+	deadLetters := r.Group(RouteWebSocketDeadLetters, handler.RequireAdmin)
+	deadLetters.GET("", handler.ListDeadLetters)
+	deadLetters.POST("/replay", handler.ReplayDeadLetters)
+	deadLetters.POST("/:id/replay", handler.ReplayDeadLetter)
+	deadLetters.DELETE("/:id", handler.DeleteDeadLetter)
 }
 
-// HandleWebSocket 处理WebSocket连接请求
+// HandleWebSocket 处理WebSocket连接请求：校验 Origin 后依次尝试会话登录态与握手令牌，
+// 失败时完成升级并以 4403/4401 关闭码断开，便于浏览器客户端区分原因
 func (h *Handler) HandleWebSocket(c *gin.Context) {
-	// 获取用户ID（从认证中间件中获取）
-	userID, exists := c.Get(constants.UserField)
-	if !exists {
-		logger.Error("未认证的用户")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证的用户"})
+	if !originAllowed(h.hub.config, c.Request) {
+		logrus.Warnf("WebSocket来源不在白名单内: %s", c.Request.Header.Get("Origin"))
+		h.hub.recordHandshake(c.Request, HandshakeOriginRejected, ErrOriginNotAllowed.Error(), "")
+		rejectUpgrade(h.hub.config, c.Writer, c.Request, CloseCodeForbiddenOrigin, ErrOriginNotAllowed.Error())
 		return
 	}
 
-	userIDStr, ok := userID.(string)
-	if !ok {
-		logger.Error("无效的用户ID")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID"})
+	userID, subprotocol, err := h.authenticate(c)
+	if err != nil {
+		logrus.Warnf("WebSocket握手认证失败: %v", err)
+		h.hub.recordHandshake(c.Request, HandshakeAuthFailed, err.Error(), "")
+		rejectUpgrade(h.hub.config, c.Writer, c.Request, CloseCodeUnauthorized, err.Error())
 		return
 	}
 
 	// 处理WebSocket升级
-	HandleWebSocket(h.hub, c.Writer, c.Request, userIDStr)
+	serveWebSocket(h.hub, c.Writer, c.Request, userID, subprotocol)
+}
+
+// authenticate 解析握手用户：会话登录态优先，其次为认证中间件写入的用户ID，最后校验令牌
+func (h *Handler) authenticate(c *gin.Context) (userID, subprotocol string, err error) {
+	if h.sessionUser != nil {
+		if userID = h.sessionUser(c); userID != "" {
+			return userID, "", nil
+		}
+	}
+	if v, ok := c.Get(constants.UserField); ok {
+		if s, ok := v.(string); ok && s != "" {
+			return s, "", nil
+		}
+	}
+
+	token, subprotocol := extractToken(c.Request)
+	if token == "" {
+		return "", "", ErrMissingToken
+	}
+	if h.authenticator == nil {
+		return "", "", ErrInvalidToken
+	}
+	userID, err = h.authenticator.Authenticate(c.Request, token)
+	if err != nil {
+		return "", "", err
+	}
+	return userID, subprotocol, nil
 }
 
 // HandleAnonymousWebSocket 处理匿名WebSocket连接（可选）
@@ -66,23 +159,30 @@ func (h *Handler) HandleAnonymousWebSocket(c *gin.Context) {
 // GetStats 获取WebSocket统计信息
 func (h *Handler) GetStats(c *gin.Context) {
 	stats := gin.H{
-		"total_connections":    h.hub.GetConnectionCount(),
-		"max_connections":      h.hub.config.MaxConnections,
-		"heartbeat_interval":   h.hub.config.HeartbeatInterval.String(),
-		"connection_timeout":   h.hub.config.ConnectionTimeout.String(),
-		"message_buffer_size":  h.hub.config.MessageBufferSize,
-		"enable_compression":   h.hub.config.EnableCompression,
-		"enable_message_queue": h.hub.config.EnableMessageQueue,
-		"message_queue_size":   h.hub.config.MessageQueueSize,
-		"enable_cluster":       h.hub.config.EnableCluster,
-		"cluster_node_id":      h.hub.config.ClusterNodeID,
-		"read_buffer_size":     h.hub.config.ReadBufferSize,
-		"write_buffer_size":    h.hub.config.WriteBufferSize,
-		"max_message_size":     h.hub.config.MaxMessageSize,
-		"shard_count":          h.hub.config.ShardCount,
-		"broadcast_workers":    h.hub.config.BroadcastWorkerCount,
-		"drop_on_full":         h.hub.config.DropOnFull,
-		"compression_level":    h.hub.config.CompressionLevel,
+		"total_connections":        h.hub.GetConnectionCount(),
+		"max_connections":          h.hub.config.MaxConnections,
+		"heartbeat_interval":       h.hub.config.HeartbeatInterval.String(),
+		"connection_timeout":       h.hub.config.ConnectionTimeout.String(),
+		"message_buffer_size":      h.hub.config.MessageBufferSize,
+		"enable_compression":       h.hub.config.EnableCompression,
+		"enable_message_queue":     h.hub.config.EnableMessageQueue,
+		"message_queue_size":       h.hub.config.MessageQueueSize,
+		"enable_cluster":           h.hub.config.EnableCluster,
+		"cluster_node_id":          h.hub.config.ClusterNodeID,
+		"read_buffer_size":         h.hub.config.ReadBufferSize,
+		"write_buffer_size":        h.hub.config.WriteBufferSize,
+		"max_message_size":         h.hub.config.MaxMessageSize,
+		"shard_count":              h.hub.config.ShardCount,
+		"broadcast_workers":        h.hub.config.BroadcastWorkerCount,
+		"drop_on_full":             h.hub.config.DropOnFull,
+		"compression_level":        h.hub.config.CompressionLevel,
+		"region":                   h.hub.config.Region,
+		"node_id":                  h.hub.nodeID(),
+		"cluster":                  h.hub.ClusterStats(),
+		"adaptive_heartbeat":       h.hub.config.AdaptiveHeartbeat,
+		"quality":                  h.hub.QualityStats(),
+		"compression_threshold":    h.hub.config.CompressionThreshold,
+		"max_chunked_message_size": h.hub.config.MaxChunkedMessageSize,
 	}
 
 	c.JSON(http.StatusOK, stats)
@@ -280,3 +380,303 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 		"timestamp":         time.Now().Unix(),
 	})
 }
+
+// GetEndpoints 返回候选WS节点及健康状态与当前客户端测得的延迟，客户端可通过 region 参数指定偏好区域
+func (h *Handler) GetEndpoints(c *gin.Context) {
+	candidates := h.hub.Endpoints().Candidates(h.latencyClient(c), c.Query("region"))
+	for i := range candidates {
+		if candidates[i].Local && candidates[i].URL == "" {
+			candidates[i].URL = localWebSocketURL(c)
+			candidates[i].ProbeURL = probeURLFor(candidates[i].URL)
+		}
+	}
+	recommended := ""
+	if len(candidates) > 0 {
+		recommended = candidates[0].ID
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"region":      h.hub.Region(),
+		"node":        h.hub.nodeID(),
+		"recommended": recommended,
+		"endpoints":   candidates,
+	})
+}
+
+// PingEndpoint 轻量测速与健康检查接口，供客户端测延迟及节点间探测
+func (h *Handler) PingEndpoint(c *gin.Context) {
+	status := http.StatusOK
+	if h.hub.localHealth() != EndpointHealthy {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{
+		"node":      h.hub.nodeID(),
+		"region":    h.hub.Region(),
+		"timestamp": time.Now().UnixMilli(),
+	})
+}
+
+// ReportEndpointLatency 客户端上报到各节点的测速结果（毫秒），结果只用于该客户端的节点排序
+func (h *Handler) ReportEndpointLatency(c *gin.Context) {
+	var request struct {
+		Samples map[string]float64 `json:"samples" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	accepted, err := h.hub.Endpoints().ReportLatency(h.latencyClient(c), request.Samples)
+	if errors.Is(err, ErrLatencyReportTooFrequent) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": accepted})
+}
+
+// latencyClient 测速结果的归属客户端，已登录时按用户，否则按客户端IP
+func (h *Handler) latencyClient(c *gin.Context) string {
+	if h.sessionUser != nil {
+		if userID := h.sessionUser(c); userID != "" {
+			return "user:" + userID
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// GetPresence 查询用户在线状态
+func (h *Handler) GetPresence(c *gin.Context) {
+	userID := c.Param("user_id")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户ID不能为空"})
+		return
+	}
+	c.JSON(http.StatusOK, h.hub.GetPresence(userID))
+}
+
+// GetGroupPresence 查询组内已连接成员的在线状态，设置了组成员关系检查时仅组成员与管理员可查询
+func (h *Handler) GetGroupPresence(c *gin.Context) {
+	group := c.Param("group")
+	if group == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "组名不能为空"})
+		return
+	}
+	if a := h.hub.getGroupAuthorizer(); a != nil && (h.adminAuth == nil || !h.adminAuth(c)) {
+		userID := ""
+		if h.sessionUser != nil {
+			userID = h.sessionUser(c)
+		}
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "需要登录"})
+			return
+		}
+		if err := a.AuthorizeJoin(group, userID); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	members := h.hub.GroupPresence(group)
+	c.JSON(http.StatusOK, gin.H{"group": group, "members": members, "count": len(members)})
+}
+
+// QueryPresence 批量查询用户在线状态，单次最多 500 个用户
+func (h *Handler) QueryPresence(c *gin.Context) {
+	var request struct {
+		UserIDs []string `json:"user_ids" binding:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"presence": h.hub.QueryPresence(request.UserIDs)})
+}
+
+// localWebSocketURL 根据当前请求推导本节点的WS地址
+func localWebSocketURL(c *gin.Context) string {
+	scheme := "ws"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "wss"
+	}
+	path := strings.TrimSuffix(c.Request.URL.Path, "/endpoints")
+	return scheme + "://" + c.Request.Host + path
+}
+
+// deadLetterRequest 死信查询与批量重放条件
+type deadLetterRequest struct {
+	Reason          string    `json:"reason" form:"reason"`
+	TargetType      string    `json:"target_type" form:"target_type"`
+	Target          string    `json:"target" form:"target"`
+	Since           time.Time `json:"since" form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	IncludeReplayed bool      `json:"include_replayed" form:"include_replayed"`
+	Limit           int       `json:"limit" form:"limit"`
+}
+
+func (r deadLetterRequest) filter() DeadLetterFilter {
+	limit := r.Limit
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	return DeadLetterFilter{
+		Reason:          r.Reason,
+		TargetType:      r.TargetType,
+		Target:          r.Target,
+		Since:           r.Since,
+		IncludeReplayed: r.IncludeReplayed,
+		Limit:           limit,
+	}
+}
+
+// deadLetterSink 获取死信存储，未配置时返回 503
+func (h *Handler) deadLetterSink(c *gin.Context) DeadLetterSink {
+	sink := h.hub.DeadLetters()
+	if sink == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrDeadLetterDisabled.Error()})
+	}
+	return sink
+}
+
+// ListDeadLetters 查询被丢弃的消息
+func (h *Handler) ListDeadLetters(c *gin.Context) {
+	sink := h.deadLetterSink(c)
+	if sink == nil {
+		return
+	}
+	var request deadLetterRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	letters, err := sink.List(c.Request.Context(), request.filter())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": letters, "count": len(letters)})
+}
+
+// ReplayDeadLetter 重放单条死信
+func (h *Handler) ReplayDeadLetter(c *gin.Context) {
+	if h.deadLetterSink(c) == nil {
+		return
+	}
+	err := h.hub.ReplayDeadLetter(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrDeadLetterTargetOffline):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrMessageExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "消息已过期，不再投递", "id": c.Param("id")})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "死信已重放", "id": c.Param("id")})
+	}
+}
+
+// ReplayDeadLetters 按条件批量重放未重放过的死信
+func (h *Handler) ReplayDeadLetters(c *gin.Context) {
+	if h.deadLetterSink(c) == nil {
+		return
+	}
+	var request deadLetterRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+			return
+		}
+	}
+	result, err := h.hub.ReplayDeadLetters(c.Request.Context(), request.filter())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteDeadLetter 删除死信
+func (h *Handler) DeleteDeadLetter(c *gin.Context) {
+	sink := h.deadLetterSink(c)
+	if sink == nil {
+		return
+	}
+	err := sink.Delete(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "死信已删除", "id": c.Param("id")})
+	}
+}
+
+// GetHandshakeStats 握手结果计数与最近的失败记录，query: outcome 按结果过滤，limit 为返回的失败记录数（默认 50）
+func (h *Handler) GetHandshakeStats(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 limit"})
+		return
+	}
+	c.JSON(http.StatusOK, h.hub.HandshakeStats(c.Query("outcome"), limit))
+}
+
+type connectionsRequest struct {
+	UserID string    `form:"user_id"`
+	Group  string    `form:"group"`
+	Since  time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Offset int       `form:"offset"`
+	Limit  int       `form:"limit"`
+}
+
+// ListConnections 分页列出活跃连接，query: user_id、group、since（RFC3339）、offset、limit，
+// 以及按元数据过滤的 meta[key]=value，可指定多个
+func (h *Handler) ListConnections(c *gin.Context) {
+	var request connectionsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.hub.ListConnections(ConnectionFilter{
+		UserID:   request.UserID,
+		Group:    request.Group,
+		Since:    request.Since,
+		Metadata: c.QueryMap("meta"),
+		Offset:   request.Offset,
+		Limit:    request.Limit,
+	}))
+}
+
+// ListSlowConsumers 列出发送队列积压或出现丢弃的连接，query: queue_ratio 为队列占用比例阈值（默认 0.5），
+// min_drops 为丢弃数阈值（默认 1）
+func (h *Handler) ListSlowConsumers(c *gin.Context) {
+	var filter SlowConsumerFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+		return
+	}
+	consumers := h.hub.SlowConsumers(filter)
+	c.JSON(http.StatusOK, gin.H{"items": consumers, "count": len(consumers)})
+}
+
+// CloseSlowConsumer 强制断开指定的慢消费者连接
+func (h *Handler) CloseSlowConsumer(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.hub.CloseConnection(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "连接已断开", "connection_id": id})
+}
+
+// CloseSlowConsumers 按阈值批量断开慢消费者，请求体与查询接口的参数相同
+func (h *Handler) CloseSlowConsumers(c *gin.Context) {
+	var filter SlowConsumerFilter
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据: " + err.Error()})
+			return
+		}
+	}
+	closed := h.hub.CloseSlowConsumers(filter)
+	c.JSON(http.StatusOK, gin.H{"message": "慢消费者连接已断开", "disconnected_count": closed})
+}
@@ -0,0 +1,158 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 握手结果
+const (
+	HandshakeSuccess          = "success"
+	HandshakeOriginRejected   = "origin_rejected"
+	HandshakeAuthFailed       = "auth_failed"
+	HandshakeLimitReached     = "limit_reached"
+	HandshakeProtocolMismatch = "protocol_mismatch" // 非 WebSocket 请求、版本不支持或缺少握手头
+	HandshakeShuttingDown     = "shutting_down"     // 服务端正在优雅关闭
+)
+
+// recentHandshakeFailures 保留的最近握手失败记录数
+const recentHandshakeFailures = 200
+
+var handshakeCounter = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "websocket_handshakes_total",
+		Help: "Total number of WebSocket upgrade attempts by outcome",
+	},
+	[]string{"outcome"},
+)
+
+// HandshakeFailure 一次失败的握手及客户端信息，用于排查客户端无法连接的问题
+type HandshakeFailure struct {
+	Outcome      string    `json:"outcome"`
+	Reason       string    `json:"reason"`
+	At           time.Time `json:"at"`
+	UserID       string    `json:"userId,omitempty"`
+	ClientIP     string    `json:"clientIp"`
+	RemoteAddr   string    `json:"remoteAddr"`
+	Origin       string    `json:"origin,omitempty"`
+	UserAgent    string    `json:"userAgent,omitempty"`
+	Path         string    `json:"path"`
+	Version      string    `json:"version,omitempty"`      // Sec-WebSocket-Version
+	Subprotocols []string  `json:"subprotocols,omitempty"` // 不含令牌
+}
+
+// HandshakeStats 各结果的握手次数与最近的失败记录
+type HandshakeStats struct {
+	Counts   map[string]int64   `json:"counts"`
+	Failures []HandshakeFailure `json:"failures"`
+}
+
+// handshakeTracker 握手结果计数与最近失败记录的环形缓冲，零值可用
+type handshakeTracker struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	recent []HandshakeFailure
+	next   int
+}
+
+func (t *handshakeTracker) add(outcome string, failure *HandshakeFailure) {
+	handshakeCounter.WithLabelValues(outcome).Inc()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts == nil {
+		t.counts = make(map[string]int64)
+	}
+	t.counts[outcome]++
+	if failure == nil {
+		return
+	}
+	if len(t.recent) < recentHandshakeFailures {
+		t.recent = append(t.recent, *failure)
+		return
+	}
+	t.recent[t.next] = *failure
+	t.next = (t.next + 1) % recentHandshakeFailures
+}
+
+// snapshot 按时间倒序返回最近的失败记录，outcome 非空时只返回该结果
+func (t *handshakeTracker) snapshot(outcome string, limit int) HandshakeStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := HandshakeStats{Counts: make(map[string]int64, len(t.counts)), Failures: []HandshakeFailure{}}
+	for k, v := range t.counts {
+		st.Counts[k] = v
+	}
+	for i := len(t.recent) - 1; i >= 0; i-- {
+		f := t.recent[(t.next+i)%len(t.recent)]
+		if outcome != "" && f.Outcome != outcome {
+			continue
+		}
+		st.Failures = append(st.Failures, f)
+		if limit > 0 && len(st.Failures) >= limit {
+			break
+		}
+	}
+	return st
+}
+
+// recordHandshake 记录握手失败及请求中的客户端信息
+func (h *Hub) recordHandshake(r *http.Request, outcome, reason, userID string) {
+	var subprotocols []string
+	protocols := websocket.Subprotocols(r)
+	for i := 0; i < len(protocols); i++ {
+		subprotocols = append(subprotocols, protocols[i])
+		// access_token 之后的子协议是握手令牌，不记录
+		if protocols[i] == AuthSubprotocol {
+			i++
+		}
+	}
+	h.handshakes.add(outcome, &HandshakeFailure{
+		Outcome:      outcome,
+		Reason:       reason,
+		At:           time.Now(),
+		UserID:       userID,
+		ClientIP:     handshakeClientIP(r),
+		RemoteAddr:   r.RemoteAddr,
+		Origin:       r.Header.Get("Origin"),
+		UserAgent:    r.UserAgent(),
+		Path:         r.URL.Path,
+		Version:      r.Header.Get("Sec-WebSocket-Version"),
+		Subprotocols: subprotocols,
+	})
+}
+
+// HandshakeStats 握手结果计数与最近的失败记录，outcome 非空时只返回该结果的失败记录
+func (h *Hub) HandshakeStats(outcome string, limit int) HandshakeStats {
+	return h.handshakes.snapshot(outcome, limit)
+}
+
+// atCapacity 连接数是否已达到上限
+func (h *Hub) atCapacity() bool {
+	return h.config.MaxConnections > 0 && h.GetConnectionCount() >= h.config.MaxConnections
+}
+
+// handshakeClientIP 优先取代理转发的客户端地址
+func handshakeClientIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// remoteHost 去掉地址中的端口
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,91 @@
+package websocket
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotGroupMember 用户不是组成员
+var ErrNotGroupMember = errors.New("您不是该组成员")
+
+// GroupAuthorizer 组成员关系检查，未设置时任何人都可以加入任意组
+type GroupAuthorizer interface {
+	// AuthorizeJoin 检查用户是否允许加入组，返回的错误信息会直接下发给客户端
+	AuthorizeJoin(group, userID string) error
+	// UserGroups 用户持久化的组，连接建立时自动加入
+	UserGroups(userID string) ([]string, error)
+}
+
+// SetGroupAuthorizer 设置组成员关系检查器
+func (h *Hub) SetGroupAuthorizer(a GroupAuthorizer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.groupAuthorizer = a
+}
+
+// getGroupAuthorizer 获取组成员关系检查器
+func (h *Hub) getGroupAuthorizer() GroupAuthorizer {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.groupAuthorizer
+}
+
+// authorizeJoin 依次检查成员关系与组管理状态
+func (c *Connection) authorizeJoin(group string) error {
+	if a := c.Hub.getGroupAuthorizer(); a != nil {
+		if err := a.AuthorizeJoin(group, c.UserID); err != nil {
+			return err
+		}
+	}
+	if m := c.Hub.getModerator(); m != nil {
+		if err := m.CheckJoin(group, c.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreGroups 连接注册前加入用户持久化的组，注册时统一建立组连接映射，
+// 被封禁等无法加入的组跳过，返回实际加入的组
+func (c *Connection) restoreGroups() []string {
+	a := c.Hub.getGroupAuthorizer()
+	if a == nil || c.UserID == "" {
+		return nil
+	}
+	groups, err := a.UserGroups(c.UserID)
+	if err != nil {
+		logrus.Warnf("加载用户 %s 的组失败: %v", c.UserID, err)
+		return nil
+	}
+
+	permit := c.Hub.getGroupPermission()
+	var joined []string
+	for _, group := range groups {
+		if permit != nil && permit(c.UserID, group) != nil {
+			continue
+		}
+		if m := c.Hub.getModerator(); m != nil {
+			if err := m.CheckJoin(group, c.UserID); err != nil {
+				continue
+			}
+		}
+		c.mu.Lock()
+		c.Groups[group] = true
+		c.mu.Unlock()
+		c.trackJoin(group)
+		joined = append(joined, group)
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+
+	_ = c.SendMessage(&Message{
+		Type:      MessageTypeGroupsRestored,
+		Data:      joined,
+		Timestamp: time.Now().Unix(),
+	})
+	logrus.Infof("用户 %s 自动加入 %d 个组", c.UserID, len(joined))
+	return joined
+}
@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Moderator 组内发言/加入的权限检查器，返回的错误信息会直接下发给客户端
+type Moderator interface {
+	// CheckSend 检查用户是否允许在组内发言
+	CheckSend(group, userID string) error
+	// CheckJoin 检查用户是否允许加入组
+	CheckJoin(group, userID string) error
+}
+
+// ModerationEvent 组管理事件，下发给被操作用户以及组内其他成员
+type ModerationEvent struct {
+	Action    string `json:"action"`
+	Group     string `json:"group"`
+	UserID    string `json:"user_id"`
+	Operator  string `json:"operator,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// SetModerator 设置组管理检查器
+func (h *Hub) SetModerator(m Moderator) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.moderator = m
+}
+
+// getModerator 获取组管理检查器
+func (h *Hub) getModerator() Moderator {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.moderator
+}
+
+// KickFromGroup 将用户的所有连接移出组，并通知用户与组内成员
+func (h *Hub) KickFromGroup(group, userID string, event ModerationEvent) int {
+	h.mu.RLock()
+	var targets []*Connection
+	for connID := range h.userConnections[userID] {
+		if conn, ok := h.connections[connID]; ok && conn.IsInGroup(group) {
+			targets = append(targets, conn)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, conn := range targets {
+		conn.LeaveGroup(group)
+		_ = conn.SendMessage(&Message{
+			Type:      MessageTypeModeration,
+			Data:      event,
+			Group:     group,
+			Timestamp: time.Now().Unix(),
+		})
+	}
+
+	h.NotifyModeration(event)
+	logrus.Infof("用户 %s 已被移出组 %s, 动作: %s", userID, group, event.Action)
+	return len(targets)
+}
+
+// NotifyModeration 向组内广播管理事件
+func (h *Hub) NotifyModeration(event ModerationEvent) {
+	em, err := newEncodedMessage(&Message{
+		Type:      MessageTypeModeration,
+		Data:      event,
+		Group:     event.Group,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		logrus.Errorf("消息序列化失败: %v", err)
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	h.sendToGroup(event.Group, em)
+}
+
+// sendError 向当前连接发送错误消息
+func (c *Connection) sendError(group, reason string) {
+	_ = c.SendMessage(&Message{
+		Type:      MessageTypeError,
+		Data:      reason,
+		Group:     group,
+		Timestamp: time.Now().Unix(),
+	})
+}
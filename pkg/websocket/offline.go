@@ -0,0 +1,60 @@
+package websocket
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultOfflineMessageLimit 连接注册时最多补发的离线消息数，应小于连接发送缓冲区
+const DefaultOfflineMessageLimit = 100
+
+// MessageStore 在持久化聊天消息的基础上，为重新上线的用户提供离线期间错过的消息
+type MessageStore interface {
+	MessagePersister
+	// MissedMessages 返回用户各会话已读游标之后的消息，同一会话内按消息ID升序，最多 limit 条
+	MissedMessages(userID string, limit int) ([]Message, error)
+}
+
+// OfflineSync 离线消息补发完成的通知，Truncated 为 true 时还有更多未读消息，客户端应通过历史消息接口分页拉取
+type OfflineSync struct {
+	Count     int  `json:"count"`
+	Truncated bool `json:"truncated"`
+}
+
+// SetMessageStore 设置消息存储，同时作为聊天消息持久化；连接注册时补发离线消息
+func (h *Hub) SetMessageStore(s MessageStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.persister = s
+	h.messageStore = s
+}
+
+// deliverMissed 向新注册的连接补发离线消息，最后下发 offline_sync 通知
+func (h *Hub) deliverMissed(conn *Connection, s MessageStore) {
+	limit := h.config.OfflineMessageLimit
+	if limit <= 0 {
+		return
+	}
+	msgs, err := s.MissedMessages(conn.UserID, limit+1)
+	if err != nil {
+		logrus.Warnf("加载用户 %s 的离线消息失败: %v", conn.UserID, err)
+		return
+	}
+	truncated := len(msgs) > limit
+	if truncated {
+		msgs = msgs[:limit]
+	}
+	for i := range msgs {
+		em, err := newEncodedMessage(&msgs[i])
+		if err != nil {
+			logrus.Errorf("消息序列化失败: %v", err)
+			continue
+		}
+		h.trySend(conn, em, func() {
+			h.recordDrop(DropReasonSendBufferFull, DeadLetterTargetConnection, conn.ID, conn.UserID, em.json)
+		})
+	}
+	_ = conn.SendMessage(&Message{
+		Type: MessageTypeOfflineSync,
+		Data: OfflineSync{Count: len(msgs), Truncated: truncated},
+	})
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"time"
+)
+
+// 权限拒绝时下发的动作
+const (
+	PermissionActionJoin    = "join"
+	PermissionActionPublish = "publish"
+)
+
+// PermissionDeniedCode 权限拒绝错误帧的错误码
+const PermissionDeniedCode = "permission_denied"
+
+// GroupPermission 组权限钩子，加入组与向组发布消息时都会调用，
+// 返回错误即拒绝，错误信息作为原因下发给客户端
+type GroupPermission func(userID, group string) error
+
+// PermissionDenied 权限拒绝错误帧
+type PermissionDenied struct {
+	Code   string `json:"code"`
+	Action string `json:"action"`
+	Group  string `json:"group"`
+	Reason string `json:"reason"`
+}
+
+// SetGroupPermission 设置组权限钩子，传nil取消
+func (h *Hub) SetGroupPermission(fn GroupPermission) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.groupPermission = fn
+}
+
+// getGroupPermission 获取组权限钩子
+func (h *Hub) getGroupPermission() GroupPermission {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.groupPermission
+}
+
+// checkGroupPermission 调用组权限钩子，拒绝时向连接下发结构化错误帧
+func (c *Connection) checkGroupPermission(action, group string) bool {
+	fn := c.Hub.getGroupPermission()
+	if fn == nil {
+		return true
+	}
+	err := fn(c.UserID, group)
+	if err == nil {
+		return true
+	}
+	c.sendPermissionDenied(action, group, err.Error())
+	return false
+}
+
+// sendPermissionDenied 向当前连接发送权限拒绝错误帧
+func (c *Connection) sendPermissionDenied(action, group, reason string) {
+	_ = c.SendMessage(&Message{
+		Type: MessageTypeError,
+		Data: PermissionDenied{
+			Code:   PermissionDeniedCode,
+			Action: action,
+			Group:  group,
+			Reason: reason,
+		},
+		Group:     group,
+		Timestamp: time.Now().Unix(),
+	})
+}
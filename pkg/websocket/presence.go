@@ -0,0 +1,326 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 用户在线状态
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// presenceRetention 离线用户的最后在线时间保留时长，过期后清除
+const presenceRetention = 24 * time.Hour
+
+// PresenceEvent 用户在线状态变化，下发给用户所在组的成员
+type PresenceEvent struct {
+	UserID string `json:"user_id"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Presence 用户当前在线状态
+type Presence struct {
+	UserID      string    `json:"user_id"`
+	Status      string    `json:"status"`
+	Connections int       `json:"connections"`
+	LastActive  time.Time `json:"last_active,omitempty"`
+	LastSeen    time.Time `json:"last_seen,omitempty"`
+}
+
+// TypingEvent 正在输入事件，发送给会话中的其他成员
+type TypingEvent struct {
+	UserID string `json:"user_id"`
+	Group  string `json:"group,omitempty"`
+	To     string `json:"to,omitempty"`
+	Typing bool   `json:"typing"`
+}
+
+// presenceEntry 单个用户的在线状态记录
+type presenceEntry struct {
+	// 用户主动设置为离开
+	away       bool
+	lastActive time.Time
+	lastSeen   time.Time
+	// 最近一次通知出去的状态
+	status string
+}
+
+// presenceTracker 在线状态记录，锁顺序为先 Hub.mu 后 presenceTracker.mu
+type presenceTracker struct {
+	mu      sync.Mutex
+	entries map[string]*presenceEntry
+}
+
+// entry 获取或创建用户记录，调用方需持有 t.mu
+func (t *presenceTracker) entry(userID string) *presenceEntry {
+	if t.entries == nil {
+		t.entries = make(map[string]*presenceEntry)
+	}
+	e, ok := t.entries[userID]
+	if !ok {
+		e = &presenceEntry{status: PresenceOffline}
+		t.entries[userID] = e
+	}
+	return e
+}
+
+// presenceLocked 计算用户在线状态，心跳超时的连接不计入，调用方需持有 h.mu
+func (h *Hub) presenceLocked(userID string, now time.Time) Presence {
+	p := Presence{UserID: userID, Status: PresenceOffline}
+	for connID := range h.userConnections[userID] {
+		conn, ok := h.connections[connID]
+		if !ok || !conn.IsAlive {
+			continue
+		}
+		conn.mu.RLock()
+		lastPing := conn.LastPing
+		conn.mu.RUnlock()
+		if now.Sub(lastPing) > h.config.ConnectionTimeout {
+			continue
+		}
+		p.Connections++
+	}
+
+	h.presence.mu.Lock()
+	away := false
+	if e, ok := h.presence.entries[userID]; ok {
+		p.LastActive, p.LastSeen, away = e.lastActive, e.lastSeen, e.away
+	}
+	h.presence.mu.Unlock()
+
+	if p.Connections > 0 {
+		p.Status = PresenceOnline
+		p.LastSeen = now
+		idle := h.config.PresenceAwayAfter > 0 && !p.LastActive.IsZero() && now.Sub(p.LastActive) > h.config.PresenceAwayAfter
+		if away || idle {
+			p.Status = PresenceAway
+		}
+	}
+	return p
+}
+
+// userGroupsLocked 用户所有连接加入的组，调用方需持有 h.mu
+func (h *Hub) userGroupsLocked(userID string) map[string]bool {
+	groups := make(map[string]bool)
+	for connID := range h.userConnections[userID] {
+		if conn, ok := h.connections[connID]; ok {
+			for _, g := range conn.GetGroups() {
+				groups[g] = true
+			}
+		}
+	}
+	return groups
+}
+
+// publishPresenceLocked 重新计算用户状态，发生变化时通知所在组的其他成员，调用方需持有 h.mu
+func (h *Hub) publishPresenceLocked(userID string, groups map[string]bool, now time.Time, reason string) {
+	if userID == "" {
+		return
+	}
+	p := h.presenceLocked(userID, now)
+
+	h.presence.mu.Lock()
+	e := h.presence.entry(userID)
+	changed := e.status != p.Status
+	e.status = p.Status
+	if p.Status != PresenceOffline {
+		e.lastSeen = now
+	} else if changed {
+		e.lastSeen = now
+		e.away = false
+	}
+	h.presence.mu.Unlock()
+
+	if !changed || len(groups) == 0 {
+		return
+	}
+	em, err := newEncodedMessage(&Message{
+		Type:      MessageTypePresence,
+		Data:      PresenceEvent{UserID: userID, Status: p.Status, Reason: reason},
+		Timestamp: now.Unix(),
+	})
+	if err != nil {
+		logrus.Errorf("消息序列化失败: %v", err)
+		return
+	}
+	for group := range groups {
+		h.sendEphemeralLocked(h.groupConnections[group], userID, em)
+	}
+}
+
+// sendEphemeralLocked 非阻塞发送状态类消息，跳过 exceptUser 的连接，缓冲区满时直接丢弃且不记录死信，调用方需持有 h.mu
+func (h *Hub) sendEphemeralLocked(connIDs map[string]bool, exceptUser string, em *encodedMessage) {
+	for connID := range connIDs {
+		conn, ok := h.connections[connID]
+		if !ok || !conn.IsAlive || conn.UserID == exceptUser {
+			continue
+		}
+		data := em.bytesFor(conn.codec)
+		if data == nil {
+			continue
+		}
+		select {
+		case conn.Send <- data:
+		default:
+		}
+	}
+}
+
+// touchPresence 记录用户活跃，从自动离开恢复在线时通知组成员
+func (h *Hub) touchPresence(userID string) {
+	if userID == "" {
+		return
+	}
+	now := time.Now()
+	h.presence.mu.Lock()
+	e := h.presence.entry(userID)
+	e.lastActive = now
+	resumed := e.status == PresenceAway && !e.away
+	h.presence.mu.Unlock()
+
+	if resumed {
+		h.mu.RLock()
+		h.publishPresenceLocked(userID, h.userGroupsLocked(userID), now, "")
+		h.mu.RUnlock()
+	}
+}
+
+// SetPresence 用户主动设置状态，仅支持 online 与 away
+func (h *Hub) SetPresence(userID, status string) bool {
+	if status != PresenceOnline && status != PresenceAway {
+		return false
+	}
+	now := time.Now()
+	h.presence.mu.Lock()
+	e := h.presence.entry(userID)
+	e.away = status == PresenceAway
+	e.lastActive = now
+	h.presence.mu.Unlock()
+
+	h.mu.RLock()
+	h.publishPresenceLocked(userID, h.userGroupsLocked(userID), now, "")
+	h.mu.RUnlock()
+	return true
+}
+
+// GetPresence 查询用户在线状态
+func (h *Hub) GetPresence(userID string) Presence {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.presenceLocked(userID, time.Now())
+}
+
+// QueryPresence 批量查询用户在线状态
+func (h *Hub) QueryPresence(userIDs []string) map[string]Presence {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	now := time.Now()
+	result := make(map[string]Presence, len(userIDs))
+	for _, id := range userIDs {
+		result[id] = h.presenceLocked(id, now)
+	}
+	return result
+}
+
+// GroupPresence 查询组内已连接成员的在线状态
+func (h *Hub) GroupPresence(group string) []Presence {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	now := time.Now()
+	seen := make(map[string]bool)
+	var list []Presence
+	for connID := range h.groupConnections[group] {
+		conn, ok := h.connections[connID]
+		if !ok || conn.UserID == "" || seen[conn.UserID] {
+			continue
+		}
+		seen[conn.UserID] = true
+		list = append(list, h.presenceLocked(conn.UserID, now))
+	}
+	return list
+}
+
+// sweepPresence 定期检查：心跳超时的用户转为离线，长时间无活动的转为离开，并清理过期的离线记录
+func (h *Hub) sweepPresence(now time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	h.presence.mu.Lock()
+	users := make([]string, 0, len(h.presence.entries))
+	for id, e := range h.presence.entries {
+		if e.status == PresenceOffline && len(h.userConnections[id]) == 0 {
+			if now.Sub(e.lastSeen) > presenceRetention {
+				delete(h.presence.entries, id)
+			}
+			continue
+		}
+		users = append(users, id)
+	}
+	h.presence.mu.Unlock()
+
+	for _, id := range users {
+		h.publishPresenceLocked(id, h.userGroupsLocked(id), now, "")
+	}
+}
+
+// sendTyping 将正在输入事件发送给组内其他成员或私聊对象
+func (h *Hub) sendTyping(event TypingEvent) {
+	em, err := newEncodedMessage(&Message{
+		Type:      MessageTypeTyping,
+		Data:      event,
+		From:      event.UserID,
+		To:        event.To,
+		Group:     event.Group,
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		logrus.Errorf("消息序列化失败: %v", err)
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if event.Group != "" {
+		h.sendEphemeralLocked(h.groupConnections[event.Group], event.UserID, em)
+		return
+	}
+	h.sendEphemeralLocked(h.userConnections[event.To], event.UserID, em)
+}
+
+// handleTyping 处理正在输入消息，data 为 bool 或 {"typing": bool}，缺省为 true
+func (c *Connection) handleTyping(msg Message) {
+	typing := true
+	switch v := msg.Data.(type) {
+	case bool:
+		typing = v
+	case map[string]interface{}:
+		if b, ok := v["typing"].(bool); ok {
+			typing = b
+		}
+	}
+
+	switch {
+	case msg.Group != "":
+		if !c.IsInGroup(msg.Group) {
+			c.sendError(msg.Group, ErrNotInGroup)
+			return
+		}
+	case msg.To == "":
+		logrus.Warnf("输入状态消息缺少目标")
+		return
+	}
+	c.Hub.sendTyping(TypingEvent{UserID: c.UserID, Group: msg.Group, To: msg.To, Typing: typing})
+}
+
+// handlePresence 处理客户端主动设置的在线状态
+func (c *Connection) handlePresence(msg Message) {
+	status, _ := msg.Data.(string)
+	if !c.Hub.SetPresence(c.UserID, status) {
+		c.sendError("", ErrInvalidPresence)
+	}
+}
@@ -0,0 +1,312 @@
+package websocket
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// 连接质量等级
+const (
+	QualityUnknown = "unknown"
+	QualityGood    = "good"
+	QualityFair    = "fair"
+	QualityPoor    = "poor"
+)
+
+const (
+	// 低于该延迟且丢包率低于 qualityGoodLoss 视为稳定链路
+	qualityGoodRTT  = 150 * time.Millisecond
+	qualityGoodLoss = 0.01
+	// 高于该延迟或丢包率高于 qualityPoorLoss 视为弱网
+	qualityPoorRTT  = 500 * time.Millisecond
+	qualityPoorLoss = 0.05
+	// 指数加权平均中新样本的权重
+	qualityEWMAWeight = 0.3
+	// 分级前至少需要的样本数，避免单次抖动切换心跳
+	qualityMinSamples = 3
+	// 稳定链路心跳间隔为基础间隔的倍数
+	qualityStableFactor = 2
+	// 弱网心跳间隔为基础间隔的几分之一
+	qualityFlakyDivisor = 3
+	// 自适应心跳的最短间隔
+	minAdaptiveHeartbeat = 5 * time.Second
+	// 客户端上报的延迟上限，超出视为无效
+	maxReportedRTT = 60 * time.Second
+)
+
+// qualityRTTBuckets 统计中的延迟分布区间上界
+var qualityRTTBuckets = []time.Duration{50 * time.Millisecond, qualityGoodRTT, 300 * time.Millisecond, qualityPoorRTT, time.Second}
+
+// ConnectionQuality 连接质量快照，下发给客户端并用于统计
+type ConnectionQuality struct {
+	Grade             string  `json:"grade"`
+	RTTMs             float64 `json:"rtt_ms"`
+	Loss              float64 `json:"loss"`
+	JitterMs          float64 `json:"jitter_ms,omitempty"`
+	Samples           int     `json:"samples"`
+	HeartbeatInterval int64   `json:"heartbeat_interval"`
+	Batching          bool    `json:"batching"`
+}
+
+// QualityStats 当前所有连接的质量分布
+type QualityStats struct {
+	Grades   map[string]int `json:"grades"`
+	RTT      map[string]int `json:"rtt"`
+	AvgRTTMs float64        `json:"avg_rtt_ms"`
+	AvgLoss  float64        `json:"avg_loss"`
+	// 当前使用各心跳间隔（秒）的连接数
+	Heartbeats map[int64]int `json:"heartbeats"`
+}
+
+// connQuality 单个连接的质量观测，客户端上报与服务端 ping/pong 测量共用
+type connQuality struct {
+	mu      sync.Mutex
+	rtt     float64 // 毫秒
+	loss    float64
+	jitter  float64
+	samples int
+	grade   string
+	// 最近一次服务端 ping 的发送时间，收到 pong 后清零
+	pingSentAt time.Time
+	// 等级变化时通知 writePump 调整心跳
+	changed chan struct{}
+}
+
+// notify 返回等级变化通知通道
+func (q *connQuality) notify() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.changed == nil {
+		q.changed = make(chan struct{}, 1)
+	}
+	return q.changed
+}
+
+// observe 记录一次观测，loss 为负时不更新丢包率，返回等级是否变化
+func (q *connQuality) observe(rtt, loss, jitter float64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.samples == 0 {
+		q.rtt, q.jitter = rtt, jitter
+		if loss >= 0 {
+			q.loss = loss
+		}
+	} else {
+		q.rtt = ewma(q.rtt, rtt)
+		if jitter > 0 {
+			q.jitter = ewma(q.jitter, jitter)
+		}
+		if loss >= 0 {
+			q.loss = ewma(q.loss, loss)
+		}
+	}
+	q.samples++
+	return q.regradeLocked()
+}
+
+// observeLoss 只记录一次丢包观测（服务端 ping 超时未收到 pong）
+func (q *connQuality) observeLoss(lost bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	sample := 0.0
+	if lost {
+		sample = 1
+	}
+	q.loss = ewma(q.loss, sample)
+	return q.regradeLocked()
+}
+
+func (q *connQuality) regradeLocked() bool {
+	grade := QualityUnknown
+	if q.samples >= qualityMinSamples {
+		rtt := time.Duration(q.rtt * float64(time.Millisecond))
+		switch {
+		case rtt >= qualityPoorRTT || q.loss >= qualityPoorLoss:
+			grade = QualityPoor
+		case rtt < qualityGoodRTT && q.loss < qualityGoodLoss:
+			grade = QualityGood
+		default:
+			grade = QualityFair
+		}
+	}
+	if grade == q.currentGradeLocked() {
+		return false
+	}
+	q.grade = grade
+	if q.changed != nil {
+		select {
+		case q.changed <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (q *connQuality) currentGradeLocked() string {
+	if q.grade == "" {
+		return QualityUnknown
+	}
+	return q.grade
+}
+
+// pingSent 记录服务端发送 ping，上一次 ping 仍未收到 pong 时计为丢包
+func (q *connQuality) pingSent(now time.Time) {
+	q.mu.Lock()
+	pending := !q.pingSentAt.IsZero()
+	q.pingSentAt = now
+	q.mu.Unlock()
+	if pending {
+		q.observeLoss(true)
+	}
+}
+
+// pongReceived 收到 pong 时按对应 ping 计算往返延迟
+func (q *connQuality) pongReceived(now time.Time) {
+	q.mu.Lock()
+	sent := q.pingSentAt
+	q.pingSentAt = time.Time{}
+	q.mu.Unlock()
+	if sent.IsZero() {
+		return
+	}
+	q.observe(float64(now.Sub(sent))/float64(time.Millisecond), 0, 0)
+}
+
+func (q *connQuality) gradeOf() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.currentGradeLocked()
+}
+
+func ewma(old, sample float64) float64 {
+	return old + qualityEWMAWeight*(sample-old)
+}
+
+// heartbeatFor 按连接质量计算心跳间隔：稳定链路放大、弱网缩短，上限保证在连接超时前至少发出一次 ping
+func (h *Hub) heartbeatFor(grade string) time.Duration {
+	base := h.config.HeartbeatInterval
+	if base <= 0 {
+		base = 30 * time.Second
+	}
+	if !h.config.AdaptiveHeartbeat {
+		return base
+	}
+	interval := base
+	switch grade {
+	case QualityGood:
+		interval = base * qualityStableFactor
+		// 放大后仍需在连接超时前收到 pong
+		if limit := h.config.ConnectionTimeout * 3 / 4; interval > limit {
+			interval = max(base, limit)
+		}
+	case QualityPoor:
+		interval = base / qualityFlakyDivisor
+		if interval < minAdaptiveHeartbeat {
+			interval = min(base, minAdaptiveHeartbeat)
+		}
+	}
+	return interval
+}
+
+// batchDelayFor 弱网连接在发送前等待一小段时间，把多条消息合并为一帧
+func (h *Hub) batchDelayFor(grade string) time.Duration {
+	if grade != QualityPoor {
+		return 0
+	}
+	return h.config.PoorLinkBatchDelay
+}
+
+// Quality 返回连接当前的质量快照
+func (c *Connection) Quality() ConnectionQuality {
+	c.quality.mu.Lock()
+	snap := ConnectionQuality{
+		Grade:    c.quality.currentGradeLocked(),
+		RTTMs:    c.quality.rtt,
+		Loss:     c.quality.loss,
+		JitterMs: c.quality.jitter,
+		Samples:  c.quality.samples,
+	}
+	c.quality.mu.Unlock()
+	snap.HeartbeatInterval = int64(c.Hub.heartbeatFor(snap.Grade) / time.Second)
+	snap.Batching = c.Hub.batchDelayFor(snap.Grade) > 0
+	return snap
+}
+
+// handleQuality 处理客户端上报的连接质量，data 为 {"rtt": 120, "loss": 0.02, "jitter": 15}，
+// rtt 与 jitter 单位为毫秒，loss 为 0~1 的丢包率；等级变化时下发新的心跳间隔与批量策略
+func (c *Connection) handleQuality(msg Message) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		c.sendError("", ErrInvalidQuality)
+		return
+	}
+	rtt := cast.ToFloat64(data["rtt"])
+	jitter := cast.ToFloat64(data["jitter"])
+	loss := -1.0
+	if v, ok := data["loss"]; ok {
+		loss = cast.ToFloat64(v)
+	}
+	if rtt < 0 || rtt > float64(maxReportedRTT/time.Millisecond) || jitter < 0 || loss > 1 {
+		c.sendError("", ErrInvalidQuality)
+		return
+	}
+	if c.quality.observe(rtt, loss, jitter) {
+		c.sendQuality()
+	}
+}
+
+// sendQuality 向客户端下发当前质量等级与服务端采用的心跳间隔
+func (c *Connection) sendQuality() {
+	_ = c.SendMessage(&Message{
+		Type:      MessageTypeQuality,
+		Data:      c.Quality(),
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// QualityStats 汇总当前所有连接的质量分布
+func (h *Hub) QualityStats() QualityStats {
+	stats := QualityStats{
+		Grades:     map[string]int{QualityUnknown: 0, QualityGood: 0, QualityFair: 0, QualityPoor: 0},
+		RTT:        make(map[string]int, len(qualityRTTBuckets)+1),
+		Heartbeats: make(map[int64]int),
+	}
+	h.mu.RLock()
+	conns := make([]*Connection, 0, len(h.connections))
+	for _, conn := range h.connections {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	measured := 0
+	for _, conn := range conns {
+		q := conn.Quality()
+		stats.Grades[q.Grade]++
+		stats.Heartbeats[q.HeartbeatInterval]++
+		if q.Samples == 0 {
+			continue
+		}
+		measured++
+		stats.AvgRTTMs += q.RTTMs
+		stats.AvgLoss += q.Loss
+		stats.RTT[rttBucket(q.RTTMs)]++
+	}
+	if measured > 0 {
+		stats.AvgRTTMs /= float64(measured)
+		stats.AvgLoss /= float64(measured)
+	}
+	return stats
+}
+
+// rttBucket 返回延迟所属区间的标签，例如 "<150ms"、">=1s"
+func rttBucket(rttMs float64) string {
+	rtt := time.Duration(rttMs * float64(time.Millisecond))
+	i := sort.Search(len(qualityRTTBuckets), func(i int) bool { return rtt < qualityRTTBuckets[i] })
+	if i == len(qualityRTTBuckets) {
+		return ">=" + qualityRTTBuckets[i-1].String()
+	}
+	return "<" + qualityRTTBuckets[i].String()
+}
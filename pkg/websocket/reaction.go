@@ -0,0 +1,166 @@
+package websocket
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+)
+
+// 表情回应变更动作
+const (
+	ReactionActionAdd    = "add"
+	ReactionActionRemove = "remove"
+)
+
+// MaxReactionEmojiLength 表情最大字节数，允许组合表情与肤色修饰
+const MaxReactionEmojiLength = 64
+
+// ErrReactionUnavailable 未配置表情回应存储
+var ErrReactionUnavailable = errors.New("表情回应不可用")
+
+// ReactionAggregate 单个表情在某条消息上的聚合，Users 为最近回应的部分用户
+type ReactionAggregate struct {
+	Emoji string   `json:"emoji"`
+	Count int64    `json:"count"`
+	Users []string `json:"users"`
+}
+
+// ReactionChanged 表情回应变更事件，Reactions 为变更后该消息的全部聚合
+type ReactionChanged struct {
+	Conversation string              `json:"conversation"`
+	MessageID    int64               `json:"message_id"`
+	UserID       string              `json:"user_id"`
+	Emoji        string              `json:"emoji"`
+	Action       string              `json:"action"`
+	Reactions    []ReactionAggregate `json:"reactions"`
+}
+
+// ReactionStore 表情回应存储
+type ReactionStore interface {
+	// AddReaction 添加回应，重复回应同一表情不重复计数；返回变更后消息的聚合及是否发生变化
+	AddReaction(conversation string, messageID int64, userID, emoji string) ([]ReactionAggregate, bool, error)
+	// RemoveReaction 取消回应；返回变更后消息的聚合及是否发生变化
+	RemoveReaction(conversation string, messageID int64, userID, emoji string) ([]ReactionAggregate, bool, error)
+}
+
+// SetReactionStore 设置表情回应存储
+func (h *Hub) SetReactionStore(s ReactionStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reactions = s
+}
+
+// getReactionStore 获取表情回应存储
+func (h *Hub) getReactionStore() ReactionStore {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.reactions
+}
+
+// ValidReactionEmoji 表情是否合法：非空、不含空白且长度受限
+func ValidReactionEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > MaxReactionEmojiLength || !utf8.ValidString(emoji) {
+		return false
+	}
+	return !strings.ContainsAny(emoji, " \t\r\n")
+}
+
+// React 添加或取消表情回应，发生变化时向会话成员广播 reaction_changed；
+// 调用方需自行确认用户属于该会话
+func (h *Hub) React(conversation string, messageID int64, userID, emoji string, add bool) (*ReactionChanged, error) {
+	store := h.getReactionStore()
+	if store == nil {
+		return nil, ErrReactionUnavailable
+	}
+	group, peer, ok := ParseConversation(conversation, userID)
+	if !ok || messageID <= 0 || !ValidReactionEmoji(emoji) {
+		return nil, errors.New(ErrInvalidReaction)
+	}
+
+	var (
+		reactions []ReactionAggregate
+		changed   bool
+		err       error
+	)
+	event := &ReactionChanged{
+		Conversation: conversation,
+		MessageID:    messageID,
+		UserID:       userID,
+		Emoji:        emoji,
+		Action:       ReactionActionAdd,
+	}
+	if add {
+		reactions, changed, err = store.AddReaction(conversation, messageID, userID, emoji)
+	} else {
+		event.Action = ReactionActionRemove
+		reactions, changed, err = store.RemoveReaction(conversation, messageID, userID, emoji)
+	}
+	if err != nil {
+		return nil, err
+	}
+	event.Reactions = reactions
+	if changed {
+		h.sendReactionChanged(event, group, peer)
+	}
+	return event, nil
+}
+
+// sendReactionChanged 组会话发送给组内所有连接，私聊发送给双方的所有设备
+func (h *Hub) sendReactionChanged(event *ReactionChanged, group, peer string) {
+	em, err := newEncodedMessage(&Message{
+		Type:         MessageTypeReactionChanged,
+		Data:         event,
+		From:         event.UserID,
+		Group:        group,
+		Conversation: event.Conversation,
+		ID:           event.MessageID,
+		Timestamp:    time.Now().Unix(),
+	})
+	if err != nil {
+		logrus.Errorf("消息序列化失败: %v", err)
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if group != "" {
+		h.sendEphemeralLocked(h.groupConnections[group], "", em)
+		return
+	}
+	h.sendEphemeralLocked(h.userConnections[peer], "", em)
+	h.sendEphemeralLocked(h.userConnections[event.UserID], "", em)
+}
+
+// handleReaction 处理表情回应，data 为 {"conversation": "...", "message_id": 1, "emoji": "👍"}
+func (c *Connection) handleReaction(msg Message, add bool) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		c.sendError("", ErrInvalidReaction)
+		return
+	}
+	conversation := cast.ToString(data["conversation"])
+	group, _, ok := ParseConversation(conversation, c.UserID)
+	if !ok {
+		c.sendError("", ErrInvalidReaction)
+		return
+	}
+	if group != "" {
+		if !c.IsInGroup(group) {
+			c.sendError(group, ErrNotInGroup)
+			return
+		}
+		// 禁言用户不能回应
+		if m := c.Hub.getModerator(); m != nil {
+			if err := m.CheckSend(group, c.UserID); err != nil {
+				c.sendError(group, err.Error())
+				return
+			}
+		}
+	}
+	if _, err := c.Hub.React(conversation, cast.ToInt64(data["message_id"]), c.UserID, cast.ToString(data["emoji"]), add); err != nil {
+		c.sendError(group, err.Error())
+	}
+}
@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// RevokeUser 用户认证失效（登出、被禁用等）时立即断开其所有连接，
+// 以 4401 关闭码通知客户端，并清除其在线状态，返回断开的连接数
+func (h *Hub) RevokeUser(userID, reason string) int {
+	h.mu.RLock()
+	var targets []*Connection
+	for connID := range h.userConnections[userID] {
+		if conn, ok := h.connections[connID]; ok {
+			targets = append(targets, conn)
+		}
+	}
+	h.mu.RUnlock()
+	if len(targets) == 0 {
+		return 0
+	}
+
+	groups := make(map[string]bool)
+	closeMsg := websocket.FormatCloseMessage(CloseCodeAuthRevoked, reason)
+	for _, conn := range targets {
+		for _, group := range conn.GetGroups() {
+			groups[group] = true
+		}
+		conn.IsAlive = false
+		if conn.Conn != nil {
+			_ = conn.Conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+		}
+		// 立即注销，避免在连接真正关闭前继续接收推送，最后一个连接注销时向所有相关组通知离线
+		h.removeConnection(conn, reason, groups)
+		if conn.Conn != nil {
+			conn.Conn.Close()
+		}
+	}
+
+	logrus.Infof("用户 %s 认证已失效, 断开连接数: %d, 原因: %s", userID, len(targets), reason)
+	return len(targets)
+}
@@ -0,0 +1,331 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// InvalidMessageCode 消息结构校验失败错误帧的错误码
+const InvalidMessageCode = "invalid_message"
+
+// 违规关闭的默认阈值：一分钟内超过 10 次
+const (
+	DefaultMaxViolations   = 10
+	DefaultViolationWindow = time.Minute
+)
+
+// FieldType 字段类型，与 JSON 解析后的类型对应
+type FieldType string
+
+const (
+	FieldString FieldType = "string"
+	FieldNumber FieldType = "number"
+	FieldBool   FieldType = "boolean"
+	FieldObject FieldType = "object"
+	FieldArray  FieldType = "array"
+	FieldAny    FieldType = "any"
+)
+
+// Validator 校验一条消息的结构，返回的错误作为原因下发给客户端
+type Validator interface {
+	Validate(msg *Message) error
+}
+
+// ValidatorFunc 函数形式的 Validator
+type ValidatorFunc func(msg *Message) error
+
+// Validate 实现 Validator
+func (f ValidatorFunc) Validate(msg *Message) error {
+	return f(msg)
+}
+
+// SchemaError 结构校验失败，Field 为出错的字段，如 data.message_id
+type SchemaError struct {
+	Field  string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+// Schema 声明式的消息结构约束，Data 按 JSON 解析后的类型检查
+type Schema struct {
+	// Data 的类型，为空时不检查
+	Data FieldType
+	// 允许不带 Data
+	DataOptional bool
+	// Data 为对象时必须存在的字段及其类型
+	Required map[string]FieldType
+	// Data 为对象时可选字段的类型，出现时检查
+	Optional map[string]FieldType
+	// 需要指定 to 或 group
+	RequireTarget bool
+}
+
+// Validate 实现 Validator
+func (s Schema) Validate(msg *Message) error {
+	if s.RequireTarget && msg.To == "" && msg.Group == "" {
+		return &SchemaError{Field: "to", Reason: "to or group is required"}
+	}
+	if msg.Data == nil {
+		if s.Data != "" && !s.DataOptional {
+			return &SchemaError{Field: "data", Reason: "is required"}
+		}
+		return nil
+	}
+	if s.Data != "" && !isFieldType(msg.Data, s.Data) {
+		return &SchemaError{Field: "data", Reason: "must be " + string(s.Data)}
+	}
+	obj, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	// 按字段名顺序检查，同一消息总是报告同一个字段
+	for _, name := range sortedFields(s.Required) {
+		v, ok := obj[name]
+		if !ok || v == nil {
+			return &SchemaError{Field: "data." + name, Reason: "is required"}
+		}
+		if !isFieldType(v, s.Required[name]) {
+			return &SchemaError{Field: "data." + name, Reason: "must be " + string(s.Required[name])}
+		}
+	}
+	for _, name := range sortedFields(s.Optional) {
+		if v, ok := obj[name]; ok && v != nil && !isFieldType(v, s.Optional[name]) {
+			return &SchemaError{Field: "data." + name, Reason: "must be " + string(s.Optional[name])}
+		}
+	}
+	return nil
+}
+
+func sortedFields(fields map[string]FieldType) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isFieldType 判断 JSON 解析后的值是否为指定类型，字符串不允许为空
+func isFieldType(v interface{}, t FieldType) bool {
+	switch t {
+	case FieldString:
+		s, ok := v.(string)
+		return ok && s != ""
+	case FieldNumber:
+		_, ok := v.(float64)
+		return ok
+	case FieldBool:
+		_, ok := v.(bool)
+		return ok
+	case FieldObject:
+		_, ok := v.(map[string]interface{})
+		return ok
+	case FieldArray:
+		_, ok := v.([]interface{})
+		return ok
+	default:
+		return true
+	}
+}
+
+// SchemaRegistry 按消息类型登记上行与下行消息的结构约束，未登记的类型不校验
+type SchemaRegistry struct {
+	mu       sync.RWMutex
+	inbound  map[string]Validator
+	outbound map[string]Validator
+}
+
+// NewSchemaRegistry 创建包含内置消息类型约束的注册表
+func NewSchemaRegistry() *SchemaRegistry {
+	r := &SchemaRegistry{inbound: make(map[string]Validator), outbound: make(map[string]Validator)}
+	for msgType, v := range builtinInboundSchemas() {
+		r.inbound[msgType] = v
+	}
+	for msgType, v := range builtinOutboundSchemas() {
+		r.outbound[msgType] = v
+	}
+	return r
+}
+
+// RegisterInbound 登记客户端发送的消息类型的约束，v 为 nil 时取消
+func (r *SchemaRegistry) RegisterInbound(msgType string, v Validator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v == nil {
+		delete(r.inbound, msgType)
+		return
+	}
+	r.inbound[msgType] = v
+}
+
+// RegisterOutbound 登记服务端下发的消息类型的约束，v 为 nil 时取消
+func (r *SchemaRegistry) RegisterOutbound(msgType string, v Validator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v == nil {
+		delete(r.outbound, msgType)
+		return
+	}
+	r.outbound[msgType] = v
+}
+
+// ValidateInbound 校验客户端发送的消息
+func (r *SchemaRegistry) ValidateInbound(msg *Message) error {
+	r.mu.RLock()
+	v := r.inbound[msg.Type]
+	r.mu.RUnlock()
+	if v == nil {
+		return nil
+	}
+	return v.Validate(msg)
+}
+
+// ValidateOutbound 校验服务端下发的消息，Data 需为 JSON 解析后的通用类型
+func (r *SchemaRegistry) ValidateOutbound(msg *Message) error {
+	r.mu.RLock()
+	v := r.outbound[msg.Type]
+	r.mu.RUnlock()
+	if v == nil {
+		return nil
+	}
+	return v.Validate(msg)
+}
+
+// DefaultSchemas 全部 Hub 共用的消息结构注册表，业务自定义的消息类型在此登记
+var DefaultSchemas = NewSchemaRegistry()
+
+// builtinInboundSchemas 内置上行消息的约束，与各处理函数读取的字段一致
+func builtinInboundSchemas() map[string]Validator {
+	conversationRef := map[string]FieldType{"conversation": FieldString, "message_id": FieldNumber}
+	return map[string]Validator{
+		MessageTypeJoinGroup:    Schema{Data: FieldString},
+		MessageTypeLeaveGroup:   Schema{Data: FieldString},
+		MessageTypeChat:         Schema{Data: FieldObject, RequireTarget: true},
+		MessageTypeNotification: Schema{Data: FieldObject},
+		MessageTypeStatus:       Schema{Data: FieldObject, DataOptional: true},
+		MessageTypeTyping: ValidatorFunc(func(msg *Message) error {
+			if _, ok := msg.Data.(bool); ok {
+				return Schema{RequireTarget: true}.Validate(msg)
+			}
+			return Schema{Data: FieldObject, DataOptional: true, Optional: map[string]FieldType{"typing": FieldBool}, RequireTarget: true}.Validate(msg)
+		}),
+		MessageTypePresence: Schema{Data: FieldString},
+		MessageTypeRead:     Schema{Data: FieldObject, Required: conversationRef},
+		MessageTypeReact: Schema{Data: FieldObject, Required: map[string]FieldType{
+			"conversation": FieldString, "message_id": FieldNumber, "emoji": FieldString,
+		}},
+		MessageTypeUnreact: Schema{Data: FieldObject, Required: map[string]FieldType{
+			"conversation": FieldString, "message_id": FieldNumber, "emoji": FieldString,
+		}},
+		MessageTypeQuality: Schema{Data: FieldObject, Required: map[string]FieldType{"rtt": FieldNumber},
+			Optional: map[string]FieldType{"loss": FieldNumber, "jitter": FieldNumber}},
+		MessageTypeChunk: Schema{Data: FieldObject, Required: map[string]FieldType{
+			"id": FieldString, "index": FieldNumber, "total": FieldNumber, "payload": FieldString,
+		}},
+	}
+}
+
+// builtinOutboundSchemas 内置下行消息的约束，只在调试构建中校验
+func builtinOutboundSchemas() map[string]Validator {
+	return map[string]Validator{
+		MessageTypeError:       Schema{Data: FieldAny},
+		MessageTypeChat:        Schema{Data: FieldObject, RequireTarget: true},
+		MessageTypeGroupJoined: Schema{Data: FieldString},
+		MessageTypeGroupLeft:   Schema{Data: FieldString},
+		MessageTypeTyping:      Schema{Data: FieldObject, Required: map[string]FieldType{"user_id": FieldString}},
+		MessageTypeRead:        Schema{Data: FieldObject, Required: map[string]FieldType{"conversation": FieldString, "message_id": FieldNumber}},
+		MessageTypeModeration: Schema{Data: FieldObject, Required: map[string]FieldType{
+			"action": FieldString, "group": FieldString, "user_id": FieldString,
+		}},
+		MessageTypeReactionChanged: Schema{Data: FieldObject, Required: map[string]FieldType{
+			"conversation": FieldString, "message_id": FieldNumber,
+		}, Optional: map[string]FieldType{"reactions": FieldArray}},
+	}
+}
+
+// SchemaViolation 上行消息结构校验失败时下发的错误帧
+type SchemaViolation struct {
+	Code        string `json:"code"`
+	MessageType string `json:"message_type"`
+	Field       string `json:"field,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+// validateInbound 校验上行消息，失败时下发错误帧并计入违规
+func (c *Connection) validateInbound(msg *Message) bool {
+	err := DefaultSchemas.ValidateInbound(msg)
+	if err == nil {
+		return true
+	}
+	c.rejectMessage(msg.Type, err)
+	return false
+}
+
+// rejectMessage 下发结构化错误帧并计入违规
+func (c *Connection) rejectMessage(msgType string, err error) {
+	violation := SchemaViolation{Code: InvalidMessageCode, MessageType: msgType, Reason: err.Error()}
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		violation.Field = schemaErr.Field
+		violation.Reason = schemaErr.Reason
+	}
+	_ = c.SendMessage(&Message{
+		Type:      MessageTypeError,
+		Data:      violation,
+		Timestamp: time.Now().Unix(),
+	})
+	c.recordViolation(fmt.Sprintf("%s: %v", msgType, err))
+}
+
+// recordViolation 累计违规分数，ViolationWindow 内超过 MaxViolations 时通知写协程发完错误帧后以 1008 关闭连接；
+// 只在读协程中调用
+func (c *Connection) recordViolation(reason string) {
+	c.Hub.counters.violations.Add(1)
+	limit := c.Hub.config.MaxViolations
+	if limit <= 0 {
+		return
+	}
+	now := time.Now()
+	if c.violationScore == 0 || now.Sub(c.violationsSince) > c.Hub.config.ViolationWindow {
+		c.violationScore = 0
+		c.violationsSince = now
+	}
+	c.violationScore++
+	if c.violationScore <= limit {
+		return
+	}
+	if c.kicked || c.kick == nil {
+		return
+	}
+	logrus.Warnf("连接 %s (用户 %s) 违规次数过多，关闭连接，最后一次: %s", c.ID, c.UserID, reason)
+	c.kicked = true
+	close(c.kick)
+}
+
+// checkOutbound 调试构建中校验下行消息，不符合约束时只记录日志，不影响发送
+func checkOutbound(msg *Message) {
+	if !validateOutbound || msg == nil {
+		return
+	}
+	data, err := normalizeData(msg.Data)
+	if err != nil {
+		logrus.Errorf("下行消息 %s 无法序列化: %v", msg.Type, err)
+		return
+	}
+	normalized := *msg
+	normalized.Data = data
+	if err := DefaultSchemas.ValidateOutbound(&normalized); err != nil {
+		logrus.Errorf("下行消息 %s 不符合结构约束: %v", msg.Type, err)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build debug
+
+package websocket
+
+// validateOutbound 调试构建（-tags debug）校验下行消息的结构
+const validateOutbound = true
@@ -0,0 +1,6 @@
+//go:build !debug
+
+package websocket
+
+// validateOutbound 非调试构建不校验下行消息，避免热路径上的额外序列化
+const validateOutbound = false
@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultShutdownReconnectAfter 关闭通知中建议的最短重连等待时间
+const DefaultShutdownReconnectAfter = 5 * time.Second
+
+// ShutdownNotice server_shutdown 消息的数据
+type ShutdownNotice struct {
+	Reason string `json:"reason"`
+	// 建议客户端等待该时长后重连，每个连接在基础时长上加随机抖动，避免同时重连
+	ReconnectAfterMs int64 `json:"reconnectAfterMs"`
+}
+
+// Shutdown 优雅关闭：不再接受新连接，向所有连接下发 server_shutdown 通知，由写协程发完发送缓冲区中的消息后
+// 以 1012（服务重启）关闭码断开，最后关闭 Hub。等待受 ShutdownDrainTimeout 与 ctx 限制，超时后仍会断开全部连接，
+// 并返回超时错误
+func (h *Hub) Shutdown(ctx context.Context, reason string) error {
+	if !h.draining.CompareAndSwap(false, true) {
+		return nil
+	}
+	h.shutdownReason = reason
+	reconnectAfter := h.config.ShutdownReconnectAfter
+	if reconnectAfter <= 0 {
+		reconnectAfter = DefaultShutdownReconnectAfter
+	}
+	if h.config.ShutdownDrainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.config.ShutdownDrainTimeout)
+		defer cancel()
+	}
+
+	h.mu.RLock()
+	conns := make([]*Connection, 0, len(h.connections))
+	for _, conn := range h.connections {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	now := time.Now()
+	for _, conn := range conns {
+		delay := reconnectAfter + rand.N(reconnectAfter)
+		em, err := newEncodedMessage(&Message{
+			Type:      MessageTypeServerShutdown,
+			Data:      ShutdownNotice{Reason: reason, ReconnectAfterMs: delay.Milliseconds()},
+			Timestamp: now.Unix(),
+		})
+		if err != nil {
+			continue
+		}
+		h.trySend(conn, em, func() {})
+	}
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, reason)
+	for _, conn := range conns {
+		conn.IsAlive = false
+		if conn.drain != nil {
+			close(conn.drain)
+		} else if conn.Conn != nil {
+			_ = conn.Conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+		}
+	}
+
+	var err error
+	for _, conn := range conns {
+		if conn.drained == nil {
+			continue
+		}
+		select {
+		case <-conn.drained:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			logrus.Warnf("WebSocket发送缓冲区未能在关闭前清空: %v", err)
+			break
+		}
+	}
+	logrus.Infof("WebSocket Hub优雅关闭, 通知连接数: %d", len(conns))
+	h.Close()
+	return err
+}
+
+// Draining 是否正在优雅关闭
+func (h *Hub) Draining() bool {
+	return h.draining.Load()
+}
+
+// rejectDraining 断开关闭开始后才完成注册的连接，调用方需持有 h.mu
+func (h *Hub) rejectDraining(conn *Connection) {
+	h.handshakes.add(HandshakeShuttingDown, &HandshakeFailure{
+		Outcome:    HandshakeShuttingDown,
+		Reason:     ErrServerShuttingDown,
+		At:         time.Now(),
+		UserID:     conn.UserID,
+		ClientIP:   remoteHost(conn.RemoteAddr),
+		RemoteAddr: conn.RemoteAddr,
+		UserAgent:  conn.UserAgent,
+	})
+	if conn.Conn != nil {
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, ErrServerShuttingDown)
+		_ = conn.Conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+		conn.Conn.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 连接级别的丢弃原因
+const (
+	// ConnDropBufferFull 丢弃模式下发送缓冲区已满
+	ConnDropBufferFull = "buffer_full"
+	// ConnDropSendTimeout 非丢弃模式下等待缓冲区超时
+	ConnDropSendTimeout = "send_timeout"
+)
+
+const (
+	// connRecentDrops 每个连接保留的最近丢弃记录数
+	connRecentDrops = 10
+	// defaultSlowQueueRatio 发送队列占用达到容量的该比例视为慢消费者
+	defaultSlowQueueRatio = 0.5
+)
+
+// ErrConnectionNotFound 连接不存在或已断开
+var ErrConnectionNotFound = errors.New("connection not found")
+
+// ConnDrop 一次发往该连接的消息被丢弃
+type ConnDrop struct {
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// connSendStats 连接的丢弃统计，发送路径上持有 Hub 读锁时也可调用
+type connSendStats struct {
+	mu     sync.Mutex
+	drops  int64
+	recent []ConnDrop
+	next   int
+}
+
+func (s *connSendStats) add(reason string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops++
+	d := ConnDrop{Reason: reason, At: at}
+	if len(s.recent) < connRecentDrops {
+		s.recent = append(s.recent, d)
+		return
+	}
+	s.recent[s.next] = d
+	s.next = (s.next + 1) % connRecentDrops
+}
+
+// snapshot 丢弃总数与按时间倒序的最近丢弃记录
+func (s *connSendStats) snapshot() (int64, []ConnDrop) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := make([]ConnDrop, 0, len(s.recent))
+	for i := len(s.recent) - 1; i >= 0; i-- {
+		recent = append(recent, s.recent[(s.next+i)%len(s.recent)])
+	}
+	return s.drops, recent
+}
+
+// SlowConsumerFilter 慢消费者的判定阈值，任一条件满足即列出
+type SlowConsumerFilter struct {
+	// 发送队列占用容量的比例，默认 0.5
+	QueueRatio float64 `form:"queue_ratio" json:"queue_ratio"`
+	// 连接建立以来的丢弃数，默认 1
+	MinDrops int64 `form:"min_drops" json:"min_drops"`
+}
+
+func (f *SlowConsumerFilter) applyDefaults() {
+	if f.QueueRatio <= 0 || f.QueueRatio > 1 {
+		f.QueueRatio = defaultSlowQueueRatio
+	}
+	if f.MinDrops <= 0 {
+		f.MinDrops = 1
+	}
+}
+
+// SlowConsumer 发送队列积压或出现丢弃的连接
+type SlowConsumer struct {
+	ConnectionID string                 `json:"connectionId"`
+	UserID       string                 `json:"userId"`
+	RemoteAddr   string                 `json:"remoteAddr,omitempty"`
+	UserAgent    string                 `json:"userAgent,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"` // 客户端通过 status 消息上报的设备信息
+	ConnectedAt  time.Time              `json:"connectedAt"`
+	QueueDepth   int                    `json:"queueDepth"`
+	QueueCap     int                    `json:"queueCap"`
+	Drops        int64                  `json:"drops"`
+	RecentDrops  []ConnDrop             `json:"recentDrops"`
+	Quality      string                 `json:"quality"`
+}
+
+// SlowConsumers 列出发送队列积压或丢弃数超过阈值的连接，丢弃多、积压深的排在前面
+func (h *Hub) SlowConsumers(f SlowConsumerFilter) []SlowConsumer {
+	f.applyDefaults()
+	h.mu.RLock()
+	conns := make([]*Connection, 0, len(h.connections))
+	for _, conn := range h.connections {
+		conns = append(conns, conn)
+	}
+	h.mu.RUnlock()
+
+	list := []SlowConsumer{}
+	for _, conn := range conns {
+		depth, capacity := len(conn.Send), cap(conn.Send)
+		drops, recent := conn.sendStats.snapshot()
+		backlogged := capacity > 0 && float64(depth)/float64(capacity) >= f.QueueRatio
+		if !backlogged && drops < f.MinDrops {
+			continue
+		}
+		conn.mu.RLock()
+		metadata := make(map[string]interface{}, len(conn.Metadata))
+		for k, v := range conn.Metadata {
+			metadata[k] = v
+		}
+		conn.mu.RUnlock()
+		list = append(list, SlowConsumer{
+			ConnectionID: conn.ID,
+			UserID:       conn.UserID,
+			RemoteAddr:   conn.RemoteAddr,
+			UserAgent:    conn.UserAgent,
+			Metadata:     metadata,
+			ConnectedAt:  conn.ConnectedAt,
+			QueueDepth:   depth,
+			QueueCap:     capacity,
+			Drops:        drops,
+			RecentDrops:  recent,
+			Quality:      conn.quality.gradeOf(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Drops != list[j].Drops {
+			return list[i].Drops > list[j].Drops
+		}
+		return list[i].QueueDepth > list[j].QueueDepth
+	})
+	return list
+}
+
+// CloseConnection 强制断开指定连接，客户端按正常断线流程重连
+func (h *Hub) CloseConnection(connID string) error {
+	h.mu.RLock()
+	conn, ok := h.connections[connID]
+	h.mu.RUnlock()
+	if !ok {
+		return ErrConnectionNotFound
+	}
+	conn.IsAlive = false
+	if conn.Conn != nil {
+		// 读协程退出后自行注销
+		return conn.Conn.Close()
+	}
+	h.unregister <- conn
+	return nil
+}
+
+// CloseSlowConsumers 强制断开所有满足阈值的慢消费者，返回断开的连接数
+func (h *Hub) CloseSlowConsumers(f SlowConsumerFilter) int {
+	closed := 0
+	for _, sc := range h.SlowConsumers(f) {
+		if h.CloseConnection(sc.ConnectionID) == nil {
+			closed++
+		}
+	}
+	return closed
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"hash/fnv"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,6 +21,14 @@ type Message struct {
 	From      string      `json:"from,omitempty"`
 	To        string      `json:"to,omitempty"`
 	Group     string      `json:"group,omitempty"`
+	// 会话内单调递增的消息ID及会话ID，仅在配置了会话统计时填充
+	ID           int64  `json:"id,omitempty"`
+	Conversation string `json:"conversation,omitempty"`
+	// 阅后即焚：客户端指定的存活秒数，为 0 时使用会话默认值；ExpiresAt 为服务端计算的过期时间（Unix 秒）
+	TTL       int64 `json:"ttl,omitempty"`
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// 从其他集群节点转发而来，本地投递后不再发布
+	remote bool
 }
 
 // Connection 表示一个WebSocket连接
@@ -34,6 +43,33 @@ type Connection struct {
 	mu       sync.RWMutex
 	Groups   map[string]bool
 	Metadata map[string]interface{}
+
+	// 违规分数及本轮计数开始的时间，只在读协程中访问；超过阈值时关闭 kick，写协程发完队列后以 1008 关闭连接
+	violationScore  int
+	violationsSince time.Time
+	kick            chan struct{}
+	kicked          bool
+
+	// 握手时协商的编解码器，为空时使用 JSON
+	codec Codec
+	// 连接质量观测，决定心跳间隔与批量发送策略
+	quality connQuality
+	// 握手时的客户端信息，用于慢消费者排查
+	ConnectedAt time.Time
+	RemoteAddr  string
+	UserAgent   string
+	// 发往该连接的消息丢弃统计
+	sendStats connSendStats
+	// 优雅关闭：drain 关闭后写协程发完队列中的消息并发送关闭帧，完成后关闭 drained
+	drain   chan struct{}
+	drained chan struct{}
+	// 协商了 permessage-deflate，按消息大小决定是否压缩
+	compress bool
+	// 客户端声明的下行帧上限，超过时拆分为 chunk 消息，0 表示不拆分；chunkSeq 只在写协程中访问
+	chunkLimit int
+	chunkSeq   uint64
+	// 上行分片消息的重组状态，只在读协程中访问
+	chunks map[string]*chunkAssembly
 }
 
 // Hub 管理所有WebSocket连接
@@ -70,6 +106,61 @@ type Hub struct {
 
 	// global ping
 	pingJobs chan int
+
+	// 组管理检查器
+	moderator Moderator
+
+	// 组成员关系检查器
+	groupAuthorizer GroupAuthorizer
+
+	// 会话已读游标
+	conversations ConversationTracker
+	// 组权限钩子
+	groupPermission GroupPermission
+
+	// 聊天消息归档，用于会话内搜索
+	archiver MessageArchiver
+
+	// 聊天消息持久化，用于历史消息查询
+	persister MessagePersister
+
+	// 会话默认消息存活时间
+	expiry MessageExpiryPolicy
+
+	// 表情回应存储
+	reactions ReactionStore
+
+	// 多区域节点发现
+	endpoints *EndpointRegistry
+
+	// 跨节点消息转发，未启用集群时为 nil
+	cluster atomic.Pointer[clusterBridge]
+
+	// 消息存储，连接注册时补发离线消息
+	messageStore MessageStore
+	// 丢弃消息的死信存储与丢弃率统计
+	deadLetterSink    DeadLetterSink
+	deadLetterEnabled atomic.Bool
+	deadLetterCh      chan DeadLetter
+	drops             dropTracker
+	dropAlertHandler  func(DropAlert)
+
+	// 用户在线状态
+	presence presenceTracker
+
+	// 可协商的编解码器，按名称索引
+	codecs map[string]Codec
+
+	// 累计收发与丢弃计数，用于容量统计
+	counters hubCounters
+
+	// 握手结果计数与最近失败记录
+	handshakes handshakeTracker
+
+	// 正在优雅关闭，不再接受新连接
+	draining atomic.Bool
+	// 优雅关闭时关闭帧携带的原因
+	shutdownReason string
 }
 
 const (
@@ -79,7 +170,7 @@ const (
 type broadcastJob struct {
 	kind  int
 	shard int
-	data  []byte
+	msg   *encodedMessage
 }
 
 // Config WebSocket配置
@@ -106,8 +197,13 @@ type Config struct {
 	MessageQueueSize int
 	// 是否启用集群模式
 	EnableCluster bool
-	// 集群节点ID
+	// 集群节点ID，为空时使用主机名加随机后缀
 	ClusterNodeID string
+	// 集群转发使用的 Redis 地址、密码、库与频道
+	ClusterRedisAddr     string
+	ClusterRedisPassword string
+	ClusterRedisDB       int
+	ClusterChannel       string
 	// 分片数量
 	ShardCount int
 	// 广播worker数量
@@ -124,31 +220,82 @@ type Config struct {
 	EnableGlobalPing bool
 	// 全局心跳workers
 	PingWorkerCount int
+	// 本节点所在区域，用于指标标签与就近路由
+	Region string
+	// 多区域部署时可供客户端选择的WS节点
+	Endpoints []Endpoint
+	// 死信存储类型：memory/db，为空时不记录死信
+	DeadLetterSink string
+	// 内存死信存储容量
+	DeadLetterCapacity int
+	// 每分钟丢弃消息数告警阈值，0 表示不告警
+	DropAlertThreshold int
+	// 无活动超过该时长的在线用户显示为离开，0 表示不自动离开
+	PresenceAwayAfter time.Duration
+	// 允许的握手来源，为空时不校验
+	AllowedOrigins []string
+	// 握手令牌 JWT(HS256) 签名密钥，为空时不启用内置 JWT 认证
+	JWTSecret string
+	// JWT 签发方，非空时校验 iss
+	JWTIssuer string
+	// 连接注册时最多补发的离线消息数，0 表示不补发
+	OfflineMessageLimit int
+	// ViolationWindow 内结构校验失败等违规超过该次数时以 1008 关闭连接，0 表示不关闭
+	MaxViolations   int
+	ViolationWindow time.Duration
+	// 按连接质量调整心跳间隔：稳定链路放大、弱网缩短，仅对每连接心跳生效
+	AdaptiveHeartbeat bool
+	// 弱网连接发送前等待合并消息的时长，0 表示不合并
+	PoorLinkBatchDelay time.Duration
+	// 优雅关闭时等待发送缓冲区清空的最长时间
+	ShutdownDrainTimeout time.Duration
+	// 关闭通知中建议客户端重连前等待的基础时长
+	ShutdownReconnectAfter time.Duration
+	// 上行分片消息重组后的最大字节数，0 表示不接受分片
+	MaxChunkedMessageSize int
+	// 分片消息从第一片起收齐的最长时间
+	ChunkTimeout time.Duration
+	// 协商了 permessage-deflate 时只压缩不小于该字节数的消息
+	CompressionThreshold int
 }
 
 // DefaultConfig 默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		MaxConnections:       100000, // 10万连接
-		HeartbeatInterval:    30 * time.Second,
-		ConnectionTimeout:    60 * time.Second,
-		MessageBufferSize:    256,
-		ReadBufferSize:       1024,
-		WriteBufferSize:      1024,
-		MaxMessageSize:       512,
-		EnableCompression:    true,
-		EnableMessageQueue:   true,
-		MessageQueueSize:     1000,
-		EnableCluster:        false,
-		ClusterNodeID:        "",
-		ShardCount:           16,
-		BroadcastWorkerCount: 32,
-		DropOnFull:           true,
-		CompressionLevel:     -2,
-		CloseOnBackpressure:  false,
-		SendTimeout:          50 * time.Millisecond,
-		EnableGlobalPing:     false,
-		PingWorkerCount:      8,
+		MaxConnections:         100000, // 10万连接
+		HeartbeatInterval:      30 * time.Second,
+		ConnectionTimeout:      60 * time.Second,
+		MessageBufferSize:      256,
+		ReadBufferSize:         1024,
+		WriteBufferSize:        1024,
+		MaxMessageSize:         512,
+		EnableCompression:      true,
+		EnableMessageQueue:     true,
+		MessageQueueSize:       1000,
+		EnableCluster:          false,
+		ClusterNodeID:          "",
+		ClusterChannel:         DefaultClusterChannel,
+		ShardCount:             16,
+		BroadcastWorkerCount:   32,
+		DropOnFull:             true,
+		CompressionLevel:       -2,
+		CloseOnBackpressure:    false,
+		SendTimeout:            50 * time.Millisecond,
+		EnableGlobalPing:       false,
+		PingWorkerCount:        8,
+		DeadLetterCapacity:     10000,
+		DropAlertThreshold:     1000,
+		PresenceAwayAfter:      5 * time.Minute,
+		OfflineMessageLimit:    DefaultOfflineMessageLimit,
+		MaxViolations:          DefaultMaxViolations,
+		ViolationWindow:        DefaultViolationWindow,
+		AdaptiveHeartbeat:      true,
+		PoorLinkBatchDelay:     50 * time.Millisecond,
+		ShutdownDrainTimeout:   10 * time.Second,
+		ShutdownReconnectAfter: DefaultShutdownReconnectAfter,
+		MaxChunkedMessageSize:  DefaultMaxChunkedMessageSize,
+		ChunkTimeout:           DefaultChunkTimeout,
+		CompressionThreshold:   DefaultCompressionThreshold,
 	}
 }
 
@@ -170,6 +317,11 @@ func NewHub(config *Config) *Hub {
 		config:           config,
 		ctx:              ctx,
 		cancel:           cancel,
+		deadLetterCh:     make(chan DeadLetter, deadLetterBufferSize),
+		codecs:           defaultCodecs(),
+	}
+	if config.DeadLetterSink == DeadLetterSinkMemory {
+		hub.SetDeadLetterSink(NewMemoryDeadLetterSink(config.DeadLetterCapacity))
 	}
 
 	// init shards
@@ -203,6 +355,8 @@ func NewHub(config *Config) *Hub {
 		}
 	}
 
+	hub.endpoints = newEndpointRegistry(hub)
+	go hub.deadLetterLoop()
 	go hub.run()
 	return hub
 }
@@ -221,22 +375,25 @@ func (h *Hub) run() {
 		case conn := <-h.unregister:
 			h.unregisterConnection(conn)
 		case message := <-h.broadcast:
-			// 单次序列化减少重复开销
+			// 每种编码只序列化一次，减少重复开销
 			if message.Timestamp == 0 {
 				message.Timestamp = time.Now().Unix()
 			}
-			data, err := json.Marshal(message)
+			em, err := newEncodedMessage(message)
 			if err != nil {
 				logrus.Errorf("消息序列化失败: %v", err)
 				continue
 			}
 			switch {
 			case message.To != "":
-				h.sendToUser(message.To, data)
+				h.sendToUser(message.To, em)
 			case message.Group != "":
-				h.sendToGroup(message.Group, data)
+				h.sendToGroup(message.Group, em)
 			default:
-				h.enqueueBroadcastAll(data)
+				h.enqueueBroadcastAll(em)
+			}
+			if b := h.cluster.Load(); b != nil && !message.remote {
+				b.forward(em)
 			}
 		case <-ticker.C:
 			if h.config.EnableGlobalPing {
@@ -249,6 +406,7 @@ func (h *Hub) run() {
 				}
 			}
 			h.checkHeartbeats()
+			h.sweepPresence(time.Now())
 		}
 	}
 }
@@ -259,6 +417,7 @@ func (h *Hub) pingWorker() {
 		h.shardLocks[shard].RLock()
 		for _, conn := range h.shardConns[shard] {
 			if conn.IsAlive {
+				conn.quality.pingSent(time.Now())
 				_ = conn.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
 			}
 		}
@@ -271,15 +430,32 @@ func (h *Hub) registerConnection(conn *Connection) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	// 升级完成前开始关闭的连接直接断开
+	if h.draining.Load() {
+		h.rejectDraining(conn)
+		return
+	}
+
 	// 检查最大连接数
 	if atomic.LoadInt64(&h.connectionCount) >= h.config.MaxConnections {
 		conn.Conn.Close()
 		logrus.Warnf("达到最大连接数限制: %d", h.config.MaxConnections)
+		h.handshakes.add(HandshakeLimitReached, &HandshakeFailure{
+			Outcome:    HandshakeLimitReached,
+			Reason:     ErrConnectionLimitExceeded,
+			At:         time.Now(),
+			UserID:     conn.UserID,
+			ClientIP:   remoteHost(conn.RemoteAddr),
+			RemoteAddr: conn.RemoteAddr,
+			UserAgent:  conn.UserAgent,
+		})
 		return
 	}
+	// 握手在注册成功后才计为成功，升级后因并发触及上限被断开的计为 limit_reached
+	h.handshakes.add(HandshakeSuccess, nil)
 
 	h.connections[conn.ID] = conn
-	atomic.AddInt64(&h.connectionCount, 1)
+	h.observeConnections(atomic.AddInt64(&h.connectionCount, 1))
 
 	// 放入分片
 	sh := h.shardIndex(conn.ID)
@@ -303,18 +479,28 @@ func (h *Hub) registerConnection(conn *Connection) {
 		h.groupConnections[group][conn.ID] = true
 	}
 
+	h.publishPresenceLocked(conn.UserID, h.userGroupsLocked(conn.UserID), time.Now(), "")
+	if h.messageStore != nil && conn.UserID != "" {
+		go h.deliverMissed(conn, h.messageStore)
+	}
+
 	logrus.Infof("WebSocket连接已注册: %s, 用户: %s, 当前连接数: %d",
 		conn.ID, conn.UserID, atomic.LoadInt64(&h.connectionCount))
 }
 
 // unregisterConnection 注销连接
 func (h *Hub) unregisterConnection(conn *Connection) {
+	h.removeConnection(conn, "", nil)
+}
+
+// removeConnection 注销连接，用户因此离线时以 reason 通知其所在组以及 notifyGroups 中的成员
+func (h *Hub) removeConnection(conn *Connection, reason string, notifyGroups map[string]bool) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	if _, exists := h.connections[conn.ID]; exists {
 		delete(h.connections, conn.ID)
-		atomic.AddInt64(&h.connectionCount, -1)
+		h.observeConnections(atomic.AddInt64(&h.connectionCount, -1))
 
 		// 从分片移除
 		sh := h.shardIndex(conn.ID)
@@ -340,6 +526,15 @@ func (h *Hub) unregisterConnection(conn *Connection) {
 			}
 		}
 
+		groups := h.userGroupsLocked(conn.UserID)
+		for group := range conn.Groups {
+			groups[group] = true
+		}
+		for group := range notifyGroups {
+			groups[group] = true
+		}
+		h.publishPresenceLocked(conn.UserID, groups, time.Now(), reason)
+
 		close(conn.Send)
 		logrus.Infof("WebSocket连接已注销: %s, 当前连接数: %d",
 			conn.ID, atomic.LoadInt64(&h.connectionCount))
@@ -357,7 +552,7 @@ func (h *Hub) broadcastMessage(message *Message) {
 	}
 
 	// 序列化消息
-	data, err := json.Marshal(message)
+	em, err := newEncodedMessage(message)
 	if err != nil {
 		logrus.Errorf("消息序列化失败: %v", err)
 		return
@@ -367,45 +562,52 @@ func (h *Hub) broadcastMessage(message *Message) {
 	switch {
 	case message.To != "":
 		// 发送给特定用户
-		h.sendToUser(message.To, data)
+		h.sendToUser(message.To, em)
 	case message.Group != "":
 		// 发送给特定组
-		h.sendToGroup(message.Group, data)
+		h.sendToGroup(message.Group, em)
 	default:
 		// 广播给所有连接
-		h.sendToAll(data)
+		h.sendToAll(em)
 	}
 }
 
 // sendToUser 发送消息给特定用户
-func (h *Hub) sendToUser(userID string, data []byte) {
+func (h *Hub) sendToUser(userID string, em *encodedMessage) {
 	if connections, exists := h.userConnections[userID]; exists {
 		for connID := range connections {
 			if conn, ok := h.connections[connID]; ok && conn.IsAlive {
-				h.trySend(conn, data, func() { logrus.Warnf("用户 %s 的连接 %s 发送缓冲区已满", userID, connID) })
+				h.trySend(conn, em, func() {
+					logrus.Warnf("用户 %s 的连接 %s 发送缓冲区已满", userID, connID)
+					h.recordDrop(DropReasonSendBufferFull, DeadLetterTargetConnection, connID, userID, em.json)
+				})
 			}
 		}
 	}
 }
 
 // sendToGroup 发送消息给特定组
-func (h *Hub) sendToGroup(group string, data []byte) {
+func (h *Hub) sendToGroup(group string, em *encodedMessage) {
 	if connections, exists := h.groupConnections[group]; exists {
 		for connID := range connections {
 			if conn, ok := h.connections[connID]; ok && conn.IsAlive {
-				h.trySend(conn, data, func() { logrus.Warnf("组 %s 的连接 %s 发送缓冲区已满", group, connID) })
+				h.trySend(conn, em, func() {
+					logrus.Warnf("组 %s 的连接 %s 发送缓冲区已满", group, connID)
+					h.recordDrop(DropReasonSendBufferFull, DeadLetterTargetConnection, connID, conn.UserID, em.json)
+				})
 			}
 		}
 	}
 }
 
 // sendToAll 发送消息给所有连接
-func (h *Hub) sendToAll(data []byte) {
+func (h *Hub) sendToAll(em *encodedMessage) {
 	for i := 0; i < h.shardCount; i++ {
 		select {
-		case h.broadcastJobs <- broadcastJob{kind: _broadcastAll, shard: i, data: data}:
+		case h.broadcastJobs <- broadcastJob{kind: _broadcastAll, shard: i, msg: em}:
 		default:
 			logrus.Warnf("广播作业队列已满，消息被丢弃")
+			h.recordDrop(DropReasonBroadcastJobsFull, DeadLetterTargetShard, strconv.Itoa(i), "", em.json)
 		}
 	}
 }
@@ -441,6 +643,22 @@ func (h *Hub) GetUserConnections(userID string) int {
 	return 0
 }
 
+// SendToUser 向指定用户的所有连接推送消息
+func (h *Hub) SendToUser(userID string, message *Message) {
+	message.To = userID
+	select {
+	case h.broadcast <- message:
+	default:
+		logrus.Warnf("广播队列已满，发送给用户 %s 的消息被丢弃", userID)
+		if message.Timestamp == 0 {
+			message.Timestamp = time.Now().Unix()
+		}
+		if data, err := json.Marshal(message); err == nil {
+			h.recordDrop(DropReasonBroadcastQueueFull, DeadLetterTargetUser, userID, userID, data)
+		}
+	}
+}
+
 // GetGroupConnections 获取组的连接数
 func (h *Hub) GetGroupConnections(group string) int {
 	h.mu.RLock()
@@ -459,7 +677,9 @@ func (h *Hub) Close() {
 	// 关闭所有连接
 	h.mu.Lock()
 	for _, conn := range h.connections {
-		conn.Conn.Close()
+		if conn.Conn != nil {
+			conn.Conn.Close()
+		}
 	}
 	h.mu.Unlock()
 
@@ -477,12 +697,13 @@ func (h *Hub) shardIndex(id string) int {
 }
 
 // enqueueBroadcastAll 将广播任务按分片入队
-func (h *Hub) enqueueBroadcastAll(data []byte) {
+func (h *Hub) enqueueBroadcastAll(em *encodedMessage) {
 	for i := 0; i < h.shardCount; i++ {
 		select {
-		case h.broadcastJobs <- broadcastJob{kind: _broadcastAll, shard: i, data: data}:
+		case h.broadcastJobs <- broadcastJob{kind: _broadcastAll, shard: i, msg: em}:
 		default:
 			logrus.Warnf("广播作业队列已满，消息被丢弃")
+			h.recordDrop(DropReasonBroadcastJobsFull, DeadLetterTargetShard, strconv.Itoa(i), "", em.json)
 		}
 	}
 }
@@ -495,7 +716,10 @@ func (h *Hub) broadcastWorker() {
 			h.shardLocks[job.shard].RLock()
 			for _, conn := range h.shardConns[job.shard] {
 				if conn.IsAlive {
-					h.trySend(conn, job.data, func() { logrus.Debugf("连接 %s 发送缓冲区满，已按策略处理", conn.ID) })
+					h.trySend(conn, job.msg, func() {
+						logrus.Debugf("连接 %s 发送缓冲区满，已按策略处理", conn.ID)
+						h.recordDrop(DropReasonSendBufferFull, DeadLetterTargetConnection, conn.ID, conn.UserID, job.msg.json)
+					})
 				}
 			}
 			h.shardLocks[job.shard].RUnlock()
@@ -503,12 +727,22 @@ func (h *Hub) broadcastWorker() {
 	}
 }
 
-// trySend 背压策略
-func (h *Hub) trySend(conn *Connection, data []byte, onDrop func()) {
+// trySend 背压策略，按连接协商的编码投递
+func (h *Hub) trySend(conn *Connection, em *encodedMessage, onDrop func()) {
+	// 已过期的消息不再投递
+	if em.msg != nil && em.msg.Expired(time.Now()) {
+		return
+	}
+	data := em.bytesFor(conn.codec)
+	if data == nil {
+		return
+	}
 	if h.config.DropOnFull {
 		select {
 		case conn.Send <- data:
+			h.counters.messagesOut.Add(1)
 		default:
+			conn.sendStats.add(ConnDropBufferFull, time.Now())
 			onDrop()
 			if h.config.CloseOnBackpressure {
 				conn.Conn.Close()
@@ -523,7 +757,9 @@ func (h *Hub) trySend(conn *Connection, data []byte, onDrop func()) {
 	}
 	select {
 	case conn.Send <- data:
+		h.counters.messagesOut.Add(1)
 	case <-time.After(timeout):
+		conn.sendStats.add(ConnDropSendTimeout, time.Now())
 		onDrop()
 		if h.config.CloseOnBackpressure {
 			conn.Conn.Close()
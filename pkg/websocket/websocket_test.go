@@ -1,14 +1,21 @@
 package websocket
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestNewHub(t *testing.T) {
@@ -289,3 +296,1965 @@ func TestMessageSerialization(t *testing.T) {
 	assert.Equal(t, message.To, decodedMessage.To)
 	assert.Equal(t, message.Group, decodedMessage.Group)
 }
+
+type stubModerator struct {
+	muted  map[string]bool
+	banned map[string]bool
+}
+
+func (m *stubModerator) CheckSend(group, userID string) error {
+	if m.muted[userID] {
+		return errors.New("您已被禁言")
+	}
+	return nil
+}
+
+func (m *stubModerator) CheckJoin(group, userID string) error {
+	if m.banned[userID] {
+		return errors.New("您已被禁止进入该群组")
+	}
+	return nil
+}
+
+func TestHubModeration(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetModerator(&stubModerator{
+		muted:  map[string]bool{"muted_user": true},
+		banned: map[string]bool{"banned_user": true},
+	})
+
+	newConn := func(id, userID string) *Connection {
+		return &Connection{
+			ID:       id,
+			UserID:   userID,
+			Send:     make(chan []byte, 16),
+			Hub:      hub,
+			IsAlive:  true,
+			Groups:   make(map[string]bool),
+			Metadata: make(map[string]interface{}),
+		}
+	}
+	readMsg := func(c *Connection) Message {
+		var msg Message
+		select {
+		case data := <-c.Send:
+			require.NoError(t, json.Unmarshal(data, &msg))
+		case <-time.After(time.Second):
+			t.Fatal("未收到消息")
+		}
+		return msg
+	}
+
+	// 被封禁用户无法加入组
+	banned := newConn("conn_banned", "banned_user")
+	banned.handleJoinGroup(Message{Type: MessageTypeJoinGroup, Data: "1"})
+	assert.False(t, banned.IsInGroup("1"))
+	assert.Equal(t, MessageTypeError, readMsg(banned).Type)
+
+	// 被禁言用户无法在组内发言
+	muted := newConn("conn_muted", "muted_user")
+	muted.JoinGroup("1")
+	muted.handleChat(Message{Type: MessageTypeChat, Group: "1", From: "muted_user", Data: map[string]interface{}{"text": "hi"}})
+	msg := readMsg(muted)
+	assert.Equal(t, MessageTypeError, msg.Type)
+	assert.Equal(t, "您已被禁言", msg.Data)
+
+	// 不在组内的用户无法向组发消息
+	outsider := newConn("conn_outsider", "outsider")
+	outsider.handleChat(Message{Type: MessageTypeChat, Group: "1", From: "outsider", Data: map[string]interface{}{"text": "hi"}})
+	assert.Equal(t, ErrNotInGroup, readMsg(outsider).Data)
+
+	// 踢出后连接离开组并收到通知
+	member := newConn("conn_member", "member")
+	hub.register <- member
+	time.Sleep(100 * time.Millisecond)
+	member.JoinGroup("1")
+	n := hub.KickFromGroup("1", "member", ModerationEvent{Action: "kick", Group: "1", UserID: "member", Reason: "spam"})
+	assert.Equal(t, 1, n)
+	assert.False(t, member.IsInGroup("1"))
+	assert.Equal(t, MessageTypeModeration, readMsg(member).Type)
+
+	hub.unregister <- member
+	time.Sleep(100 * time.Millisecond)
+	hub.Close()
+}
+
+func TestHubRevokeUser(t *testing.T) {
+	hub := NewHub(nil)
+	defer hub.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(hub, w, r, r.URL.Query().Get("user"))
+	}))
+	defer srv.Close()
+
+	dial := func(user string) *websocket.Conn {
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/?user=" + user
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		return conn
+	}
+	revoked := dial("revoked")
+	defer revoked.Close()
+	other := dial("other")
+	defer other.Close()
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	n := hub.RevokeUser("revoked", "logout")
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 0, hub.GetUserConnections("revoked"))
+	assert.Equal(t, 1, hub.GetUserConnections("other"))
+
+	_ = revoked.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := revoked.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseCodeAuthRevoked, closeErr.Code)
+	assert.Equal(t, "logout", closeErr.Text)
+
+	assert.Equal(t, 0, hub.RevokeUser("revoked", "logout"))
+}
+
+func TestParseEndpoints(t *testing.T) {
+	eps, err := ParseEndpoints("sh-1|cn-east|wss://sh.example.com/api/ws, sg-1|ap-southeast|wss://sg.example.com/api/ws|https://sg.example.com/ping")
+	require.NoError(t, err)
+	require.Len(t, eps, 2)
+	assert.Equal(t, "cn-east", eps[0].Region)
+	assert.Equal(t, "https://sg.example.com/ping", eps[1].ProbeURL)
+
+	_, err = ParseEndpoints("broken|cn-east")
+	assert.Error(t, err)
+
+	assert.Equal(t, "https://sh.example.com/api/ws/endpoints/ping", probeURLFor("wss://sh.example.com/api/ws"))
+	assert.Equal(t, "http://127.0.0.1/ws/endpoints/ping", probeURLFor("ws://127.0.0.1/ws/"))
+}
+
+func TestEndpointRegistry(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	config := DefaultConfig()
+	config.Region = "cn-east"
+	config.ClusterNodeID = "sh-1"
+	config.Endpoints = []Endpoint{
+		{ID: "sh-1", Region: "cn-east", URL: "wss://sh.example.com/ws"},
+		{ID: "sg-1", Region: "ap-southeast", URL: "wss://sg.example.com/ws", ProbeURL: healthy.URL},
+		{ID: "us-1", Region: "us-west", URL: "wss://us.example.com/ws", ProbeURL: down.URL},
+	}
+	hub := NewHub(config)
+	defer hub.Close()
+
+	reg := hub.Endpoints()
+	reg.Probe(context.Background())
+
+	reg.reportInterval = 0
+
+	n, err := reg.ReportLatency("a", map[string]float64{"sg-1": 20, "sh-1": 80, "missing": 10, "us-1": -1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, _ = reg.ReportLatency("b", map[string]float64{"sg-1": 0})
+	assert.Zero(t, n, "zero latency is rejected")
+
+	list := reg.Candidates("a", "")
+	require.Len(t, list, 3)
+	assert.Equal(t, "sg-1", list[0].ID)
+	assert.Equal(t, "sh-1", list[1].ID)
+	assert.True(t, list[1].Local)
+	assert.Equal(t, EndpointUnhealthy, list[2].Health)
+
+	// 偏好区域优先于延迟
+	list = reg.Candidates("a", "cn-east")
+	assert.Equal(t, "sh-1", list[0].ID)
+
+	// 其他客户端的测速不影响排序
+	_, err = reg.ReportLatency("b", map[string]float64{"sh-1": 1, "sg-1": 300})
+	require.NoError(t, err)
+	assert.Equal(t, "sg-1", reg.Candidates("a", "")[0].ID)
+	assert.Equal(t, "sh-1", reg.Candidates("b", "")[0].ID)
+	for _, ep := range reg.Candidates("c", "") {
+		assert.Zero(t, ep.Samples, "unmeasured client sees no latency")
+	}
+
+	// 指数平滑
+	_, err = reg.ReportLatency("a", map[string]float64{"sg-1": 120})
+	require.NoError(t, err)
+	for _, ep := range reg.Candidates("a", "") {
+		if ep.ID == "sg-1" {
+			assert.InDelta(t, 50, ep.LatencyMs, 0.001)
+			assert.Equal(t, int64(2), ep.Samples)
+		}
+	}
+
+	// 同一客户端上报限频
+	reg.reportInterval = time.Minute
+	_, err = reg.ReportLatency("a", map[string]float64{"sg-1": 1})
+	assert.ErrorIs(t, err, ErrLatencyReportTooFrequent)
+}
+
+func TestEndpointsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := NewHub(nil)
+	defer hub.Close()
+
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(hub))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ws/endpoints", nil)
+	req.Host = "im.example.com"
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Recommended string           `json:"recommended"`
+		Endpoints   []EndpointStatus `json:"endpoints"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Endpoints, 1)
+	assert.Equal(t, "local", body.Recommended)
+	assert.Equal(t, "ws://im.example.com/ws", body.Endpoints[0].URL)
+	assert.Equal(t, "http://im.example.com/ws/endpoints/ping", body.Endpoints[0].ProbeURL)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ws/endpoints/latency", strings.NewReader(`{"samples":{"local":12,"other":5}}`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"accepted":1}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ws/endpoints/latency", strings.NewReader(`{"samples":{"local":0.5}}`)))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws/endpoints/ping", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// memoryClusterBus 进程内的集群传输，把发布的消息投递给所有订阅者
+type memoryClusterBus struct {
+	mu   sync.Mutex
+	subs []chan []byte
+}
+
+func (b *memoryClusterBus) Publish(_ context.Context, _ string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *memoryClusterBus) Subscribe(_ context.Context, _ string) (<-chan []byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan []byte, 64)
+	b.subs = append(b.subs, ch)
+	return ch, nil
+}
+
+func TestHubCluster(t *testing.T) {
+	bus := &memoryClusterBus{}
+	newClusterHub := func(node string) *Hub {
+		cfg := DefaultConfig()
+		cfg.ClusterNodeID = node
+		hub := NewHub(cfg)
+		require.NoError(t, hub.StartCluster(bus))
+		return hub
+	}
+	a, b := newClusterHub("node-a"), newClusterHub("node-b")
+	defer a.Close()
+	defer b.Close()
+	assert.ErrorIs(t, a.StartCluster(bus), ErrClusterAlreadyStarted)
+	assert.Nil(t, NewHub(DefaultConfig()).ClusterStats())
+
+	// 两个节点通过心跳互相注册
+	require.Eventually(t, func() bool {
+		return len(a.ClusterStats().Peers) == 1 && len(b.ClusterStats().Peers) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "node-b", a.ClusterStats().Peers[0].Node)
+
+	bob := &Connection{ID: "conn_bob", UserID: "bob", Send: make(chan []byte, 16), Hub: b, IsAlive: true,
+		Groups: make(map[string]bool), Metadata: make(map[string]interface{})}
+	b.register <- bob
+	require.Eventually(t, func() bool { return b.GetUserConnections("bob") == 1 }, time.Second, 5*time.Millisecond)
+	for len(bob.Send) > 0 {
+		<-bob.Send
+	}
+
+	// 节点 A 发给 bob 的消息经集群转发到节点 B 投递
+	a.SendToUser("bob", &Message{Type: MessageTypeChat, From: "alice", Data: "hi"})
+	var msg Message
+	select {
+	case data := <-bob.Send:
+		require.NoError(t, json.Unmarshal(data, &msg))
+	case <-time.After(time.Second):
+		t.Fatal("未收到跨节点消息")
+	}
+	assert.Equal(t, "alice", msg.From)
+	assert.Equal(t, "hi", msg.Data)
+
+	require.Eventually(t, func() bool {
+		sa, sb := a.ClusterStats(), b.ClusterStats()
+		// A 收到自己发布的消息作为回显忽略，B 投递后不再转发
+		return sa.Published == 1 && sa.Echoes == 1 && sb.Received == 1 && sb.Published == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int64(1), b.ClusterStats().Peers[0].Received)
+
+	// 重复投递的消息只处理一次
+	bridge := b.cluster.Load()
+	env, err := json.Marshal(clusterEnvelope{Node: "node-c", ID: "node-c:1", Kind: clusterKindMessage,
+		Message: json.RawMessage(`{"type":"chat","data":"dup","to":"bob"}`)})
+	require.NoError(t, err)
+	bridge.handle(env, time.Now())
+	bridge.handle(env, time.Now())
+	assert.Equal(t, int64(1), b.ClusterStats().Duplicates)
+
+	// 心跳超时的节点被移除，下线通知立即移除
+	bridge.expirePeers(time.Now().Add(time.Hour))
+	assert.Empty(t, b.ClusterStats().Peers)
+	a.StopCluster()
+	assert.Nil(t, a.ClusterStats())
+}
+
+func TestHubDeadLetters(t *testing.T) {
+	config := DefaultConfig()
+	config.DeadLetterSink = DeadLetterSinkMemory
+	config.DropAlertThreshold = 1
+	hub := NewHub(config)
+	defer hub.Close()
+
+	conn := &Connection{
+		ID:       "conn_slow",
+		UserID:   "slow",
+		Send:     make(chan []byte, 1),
+		Hub:      hub,
+		IsAlive:  true,
+		Groups:   make(map[string]bool),
+		Metadata: make(map[string]interface{}),
+	}
+	hub.register <- conn
+	require.Eventually(t, func() bool { return hub.GetUserConnections("slow") == 1 }, time.Second, 10*time.Millisecond)
+
+	// 缓冲区满后第二条消息被丢弃并进入死信
+	hub.SendToUser("slow", &Message{Type: MessageTypeNotification, Data: "first"})
+	hub.SendToUser("slow", &Message{Type: MessageTypeNotification, Data: "second"})
+
+	sink := hub.DeadLetters()
+	var letters []DeadLetter
+	require.Eventually(t, func() bool {
+		letters, _ = sink.List(context.Background(), DeadLetterFilter{})
+		return len(letters) == 1
+	}, 3*time.Second, 20*time.Millisecond)
+	assert.Equal(t, DropReasonSendBufferFull, letters[0].Reason)
+	assert.Equal(t, DeadLetterTargetConnection, letters[0].TargetType)
+	assert.Equal(t, "slow", letters[0].UserID)
+
+	var alert DropAlert
+	hub.SetDropAlertHandler(func(a DropAlert) { alert = a })
+	hub.checkDropRate(time.Now())
+	assert.Equal(t, int64(1), alert.Drops)
+	assert.Equal(t, int64(1), alert.ByReason[DropReasonSendBufferFull])
+
+	// 缓冲区仍满时重放失败，腾出空间后重放成功
+	assert.Error(t, hub.ReplayDeadLetter(context.Background(), letters[0].ID))
+	<-conn.Send
+	require.NoError(t, hub.ReplayDeadLetter(context.Background(), letters[0].ID))
+	var msg Message
+	require.NoError(t, json.Unmarshal(<-conn.Send, &msg))
+	assert.Equal(t, "second", msg.Data)
+
+	pending, _ := sink.List(context.Background(), DeadLetterFilter{})
+	assert.Empty(t, pending)
+	assert.ErrorIs(t, hub.ReplayDeadLetter(context.Background(), "missing"), ErrDeadLetterNotFound)
+
+	hub.unregister <- conn
+	require.Eventually(t, func() bool { return hub.GetUserConnections("slow") == 0 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, sink.Save(context.Background(), []DeadLetter{{ID: "offline", TargetType: DeadLetterTargetUser, Target: "slow", DroppedAt: time.Now()}}))
+	result, err := hub.ReplayDeadLetters(context.Background(), DeadLetterFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Replayed)
+	assert.Contains(t, result.Failed["offline"], "offline")
+}
+
+func TestMemoryDeadLetterSinkCapacity(t *testing.T) {
+	sink := NewMemoryDeadLetterSink(2)
+	ctx := context.Background()
+	require.NoError(t, sink.Save(ctx, []DeadLetter{{ID: "1"}, {ID: "2"}, {ID: "3"}}))
+	letters, err := sink.List(ctx, DeadLetterFilter{})
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "3", letters[0].ID)
+	_, err = sink.Get(ctx, "1")
+	assert.ErrorIs(t, err, ErrDeadLetterNotFound)
+	require.NoError(t, sink.Delete(ctx, "2"))
+	assert.ErrorIs(t, sink.Delete(ctx, "2"), ErrDeadLetterNotFound)
+}
+
+func TestHubPresenceAndTyping(t *testing.T) {
+	config := DefaultConfig()
+	config.PresenceAwayAfter = time.Minute
+	hub := NewHub(config)
+	defer hub.Close()
+
+	newConn := func(id, userID string) *Connection {
+		c := &Connection{
+			ID:       id,
+			UserID:   userID,
+			Send:     make(chan []byte, 16),
+			Hub:      hub,
+			LastPing: time.Now(),
+			IsAlive:  true,
+			Groups:   map[string]bool{"room": true},
+			Metadata: make(map[string]interface{}),
+		}
+		hub.register <- c
+		return c
+	}
+	readMsg := func(c *Connection) Message {
+		var msg Message
+		select {
+		case data := <-c.Send:
+			require.NoError(t, json.Unmarshal(data, &msg))
+		case <-time.After(time.Second):
+			t.Fatal("未收到消息")
+		}
+		return msg
+	}
+
+	alice := newConn("conn_alice", "alice")
+	require.Eventually(t, func() bool { return hub.GetPresence("alice").Status == PresenceOnline }, time.Second, 10*time.Millisecond)
+	bob := newConn("conn_bob", "bob")
+
+	// alice 收到 bob 上线通知
+	msg := readMsg(alice)
+	assert.Equal(t, MessageTypePresence, msg.Type)
+	assert.Equal(t, map[string]interface{}{"user_id": "bob", "status": PresenceOnline}, msg.Data)
+
+	// 正在输入只发给会话中的其他成员
+	bob.handleTyping(Message{Type: MessageTypeTyping, Group: "room"})
+	msg = readMsg(alice)
+	assert.Equal(t, MessageTypeTyping, msg.Type)
+	assert.Equal(t, "bob", msg.From)
+	assert.Equal(t, true, msg.Data.(map[string]interface{})["typing"])
+	assert.Empty(t, bob.Send)
+
+	// 主动设置离开
+	assert.True(t, hub.SetPresence("bob", PresenceAway))
+	assert.Equal(t, PresenceAway, readMsg(alice).Data.(map[string]interface{})["status"])
+	assert.False(t, hub.SetPresence("bob", "busy"))
+
+	group := hub.GroupPresence("room")
+	assert.Len(t, group, 2)
+	bulk := hub.QueryPresence([]string{"alice", "bob", "carol"})
+	assert.Equal(t, PresenceOnline, bulk["alice"].Status)
+	assert.Equal(t, PresenceAway, bulk["bob"].Status)
+	assert.Equal(t, PresenceOffline, bulk["carol"].Status)
+
+	// 心跳超时后自动离线
+	bob.mu.Lock()
+	bob.LastPing = time.Now().Add(-2 * config.ConnectionTimeout)
+	bob.mu.Unlock()
+	hub.sweepPresence(time.Now())
+	msg = readMsg(alice)
+	assert.Equal(t, PresenceOffline, msg.Data.(map[string]interface{})["status"])
+	p := hub.GetPresence("bob")
+	assert.Equal(t, PresenceOffline, p.Status)
+	assert.False(t, p.LastSeen.IsZero())
+
+	// 长时间无活动显示为离开，再次活动后恢复在线
+	hub.presence.mu.Lock()
+	hub.presence.entries["alice"].lastActive = time.Now().Add(-2 * time.Minute)
+	hub.presence.mu.Unlock()
+	assert.Equal(t, PresenceAway, hub.GetPresence("alice").Status)
+	hub.touchPresence("alice")
+	assert.Equal(t, PresenceOnline, hub.GetPresence("alice").Status)
+
+	hub.unregister <- alice
+	hub.unregister <- bob
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestPresenceRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := NewHub(nil)
+	defer hub.Close()
+	r := gin.New()
+	RegisterRoutes(r, NewHandler(hub))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws/presence/nobody", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var p Presence
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, PresenceOffline, p.Status)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws/presence/group/room", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ws/presence/query", strings.NewReader(`{"user_ids":["a","b"]}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"b":{"user_id":"b","status":"offline"`)
+}
+
+func TestGroupPresenceRequiresMembership(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := NewHub(nil)
+	defer hub.Close()
+	hub.SetGroupAuthorizer(&fakeGroupAuthorizer{members: map[string][]string{"alice": {"1"}}})
+	handler := NewHandler(hub)
+	handler.SetSessionResolver(func(c *gin.Context) string { return c.GetHeader("X-User") })
+	handler.SetAdminAuthorizer(func(c *gin.Context) bool { return c.GetHeader("X-User") == "admin" })
+	r := gin.New()
+	RegisterRoutes(r, handler)
+
+	get := func(user string) int {
+		req := httptest.NewRequest(http.MethodGet, "/ws/presence/group/1", nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, get("alice"))
+	assert.Equal(t, http.StatusForbidden, get("mallory"), "non-member")
+	assert.Equal(t, http.StatusUnauthorized, get(""))
+	assert.Equal(t, http.StatusOK, get("admin"))
+}
+
+type fakeConversationTracker struct {
+	mu    sync.Mutex
+	seq   map[string]int64
+	reads map[string]int64
+}
+
+func (f *fakeConversationTracker) OnMessage(conversation, senderID, recipientID string) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq[conversation]++
+	f.reads[senderID+"|"+conversation] = f.seq[conversation]
+	return f.seq[conversation], nil
+}
+
+func (f *fakeConversationTracker) OnJoin(conversation, userID string) error {
+	return nil
+}
+
+func (f *fakeConversationTracker) MarkRead(conversation, userID string, messageID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reads[userID+"|"+conversation] = messageID
+	return nil
+}
+
+func TestHubReadReceipts(t *testing.T) {
+	assert.Equal(t, "dm:alice:bob", DirectConversation("bob", "alice"))
+	group, peer, ok := ParseConversation("dm:alice:bob", "bob")
+	assert.True(t, ok)
+	assert.Equal(t, "", group)
+	assert.Equal(t, "alice", peer)
+	_, _, ok = ParseConversation("dm:alice:bob", "carol")
+	assert.False(t, ok)
+
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+	tracker := &fakeConversationTracker{seq: map[string]int64{}, reads: map[string]int64{}}
+	hub.SetConversationTracker(tracker)
+
+	newConn := func(id, userID string) *Connection {
+		c := &Connection{
+			ID:       id,
+			UserID:   userID,
+			Send:     make(chan []byte, 16),
+			Hub:      hub,
+			LastPing: time.Now(),
+			IsAlive:  true,
+			Groups:   map[string]bool{"room": true},
+			Metadata: make(map[string]interface{}),
+		}
+		hub.register <- c
+		return c
+	}
+	readType := func(c *Connection, typ string) Message {
+		deadline := time.After(time.Second)
+		for {
+			select {
+			case data := <-c.Send:
+				var msg Message
+				require.NoError(t, json.Unmarshal(data, &msg))
+				if msg.Type == typ {
+					return msg
+				}
+			case <-deadline:
+				t.Fatalf("未收到 %s 消息", typ)
+			}
+		}
+	}
+
+	alice := newConn("conn_alice", "alice")
+	bob := newConn("conn_bob", "bob")
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	// 聊天消息分配会话内ID
+	bob.handleChat(Message{Type: MessageTypeChat, Group: "room", From: "bob", Data: map[string]interface{}{"text": "hi"}})
+	msg := readType(alice, MessageTypeChat)
+	assert.Equal(t, int64(1), msg.ID)
+	assert.Equal(t, "group:room", msg.Conversation)
+
+	// 已读回执更新游标并转发给其他成员
+	alice.handleRead(Message{Type: MessageTypeRead, Data: map[string]interface{}{"conversation": "group:room", "message_id": float64(1)}})
+	msg = readType(bob, MessageTypeRead)
+	assert.Equal(t, "alice", msg.From)
+	assert.Equal(t, float64(1), msg.Data.(map[string]interface{})["message_id"])
+	tracker.mu.Lock()
+	assert.Equal(t, int64(1), tracker.reads["alice|group:room"])
+	assert.Equal(t, int64(1), tracker.reads["bob|group:room"])
+	tracker.mu.Unlock()
+
+	// 不在组内或格式错误的回执被拒绝
+	alice.handleRead(Message{Type: MessageTypeRead, Data: map[string]interface{}{"conversation": "group:other", "message_id": 1}})
+	assert.Equal(t, ErrNotInGroup, readType(alice, MessageTypeError).Data)
+	alice.handleRead(Message{Type: MessageTypeRead, Data: map[string]interface{}{"conversation": "dm:bob:carol", "message_id": 1}})
+	assert.Equal(t, ErrInvalidReadReceipt, readType(alice, MessageTypeError).Data)
+
+	// 不经连接提交的回执同样更新游标并广播
+	receipt, err := hub.MarkRead("group:room", "bob", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", receipt.UserID)
+	assert.Equal(t, "bob", readType(alice, MessageTypeRead).From)
+	_, err = hub.MarkRead("group:room", "bob", 0)
+	assert.EqualError(t, err, ErrInvalidReadReceipt)
+
+	hub.unregister <- alice
+	hub.unregister <- bob
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+type fakeMessageStore struct {
+	fakeArchiver
+	missed []Message
+}
+
+func (f *fakeMessageStore) MissedMessages(userID string, limit int) ([]Message, error) {
+	if len(f.missed) > limit {
+		return f.missed[:limit], nil
+	}
+	return f.missed, nil
+}
+
+func TestHubOfflineDelivery(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OfflineMessageLimit = 2
+	hub := NewHub(cfg)
+	defer hub.Close()
+	store := &fakeMessageStore{missed: []Message{
+		{Type: MessageTypeChat, From: "alice", To: "bob", ID: 1, Conversation: "dm:alice:bob", Data: "one"},
+		{Type: MessageTypeChat, From: "alice", To: "bob", ID: 2, Conversation: "dm:alice:bob", Data: "two"},
+		{Type: MessageTypeChat, From: "carol", Group: "room", ID: 7, Conversation: "group:room", Data: "three"},
+	}}
+	hub.SetMessageStore(store)
+	assert.Equal(t, MessagePersister(store), hub.getMessagePersister())
+
+	bob := &Connection{ID: "conn_bob", UserID: "bob", Send: make(chan []byte, 16), Hub: hub, IsAlive: true,
+		Groups: make(map[string]bool), Metadata: make(map[string]interface{})}
+	hub.register <- bob
+
+	var got []Message
+	for len(got) < 3 {
+		select {
+		case data := <-bob.Send:
+			var msg Message
+			require.NoError(t, json.Unmarshal(data, &msg))
+			if msg.Type == MessageTypePresence {
+				continue
+			}
+			got = append(got, msg)
+		case <-time.After(time.Second):
+			t.Fatal("未收到离线消息")
+		}
+	}
+	assert.Equal(t, int64(1), got[0].ID)
+	assert.Equal(t, "two", got[1].Data)
+	// 超过补发上限时提示客户端分页拉取
+	assert.Equal(t, MessageTypeOfflineSync, got[2].Type)
+	assert.Equal(t, map[string]interface{}{"count": 2.0, "truncated": true}, got[2].Data)
+}
+
+func TestHubGroupPermission(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+	hub.SetGroupAuthorizer(&fakeGroupAuthorizer{members: map[string][]string{"alice": {"1", "2"}}})
+	var mu sync.Mutex
+	denied := map[string]bool{"2": true}
+	hub.SetGroupPermission(func(userID, group string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if denied[group] {
+			return errors.New("只读频道")
+		}
+		return nil
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(hub, w, r, r.URL.Query().Get("user"))
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?user=alice", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	readType := func(typ string) Message {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			var msg Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			if msg.Type == typ {
+				return msg
+			}
+		}
+	}
+	denial := func(msg Message) map[string]interface{} {
+		data, ok := msg.Data.(map[string]interface{})
+		require.True(t, ok, "expected structured error, got %v", msg.Data)
+		return data
+	}
+
+	// 被钩子拒绝的持久化组不会自动加入
+	assert.Equal(t, []interface{}{"1"}, readType(MessageTypeGroupsRestored).Data)
+
+	// 加入被拒绝时下发结构化错误帧
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeJoinGroup, Data: "2"}))
+	data := denial(readType(MessageTypeError))
+	assert.Equal(t, PermissionDeniedCode, data["code"])
+	assert.Equal(t, PermissionActionJoin, data["action"])
+	assert.Equal(t, "2", data["group"])
+	assert.Equal(t, "只读频道", data["reason"])
+	assert.Equal(t, 0, hub.GetGroupConnections("2"))
+
+	// 加入后权限被收回，发布聊天与通知都被拒绝
+	mu.Lock()
+	denied["1"] = true
+	mu.Unlock()
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeChat, Group: "1", Data: map[string]interface{}{"text": "hi"}}))
+	data = denial(readType(MessageTypeError))
+	assert.Equal(t, PermissionActionPublish, data["action"])
+	assert.Equal(t, "1", data["group"])
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeNotification, Group: "1", Data: map[string]interface{}{"text": "hi"}}))
+	assert.Equal(t, PermissionActionPublish, denial(readType(MessageTypeError))["action"])
+
+	// 恢复权限后可以正常发布
+	mu.Lock()
+	delete(denied, "1")
+	mu.Unlock()
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeChat, Group: "1", Data: map[string]interface{}{"text": "hi"}}))
+	assert.Equal(t, "1", readType(MessageTypeChat).Group)
+}
+
+type fakeArchiver struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+func (f *fakeArchiver) ArchiveMessage(msg Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.msgs = append(f.msgs, msg)
+}
+
+func TestHubMessageArchiver(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+	archiver := &fakeArchiver{}
+	hub.SetMessageArchiver(archiver)
+	bob := &Connection{ID: "conn_bob", UserID: "bob", Send: make(chan []byte, 16), Hub: hub,
+		Groups: map[string]bool{"room": true}, Metadata: make(map[string]interface{})}
+
+	// 未配置会话统计时消息没有ID，不归档
+	bob.handleChat(Message{Type: MessageTypeChat, Group: "room", From: "bob", Data: map[string]interface{}{"text": "hi"}})
+	archiver.mu.Lock()
+	assert.Empty(t, archiver.msgs)
+	archiver.mu.Unlock()
+
+	hub.SetConversationTracker(&fakeConversationTracker{seq: map[string]int64{}, reads: map[string]int64{}})
+	bob.handleChat(Message{Type: MessageTypeChat, Group: "room", From: "bob", Data: map[string]interface{}{"text": "hello"}})
+	bob.handleChat(Message{Type: MessageTypeChat, To: "alice", From: "bob", Data: map[string]interface{}{"text": "psst"}})
+	archiver.mu.Lock()
+	defer archiver.mu.Unlock()
+	require.Len(t, archiver.msgs, 2)
+	assert.Equal(t, "group:room", archiver.msgs[0].Conversation)
+	assert.Equal(t, int64(1), archiver.msgs[0].ID)
+	assert.Equal(t, "dm:alice:bob", archiver.msgs[1].Conversation)
+}
+
+func (f *fakeArchiver) PersistMessage(msg Message) {
+	f.ArchiveMessage(msg)
+}
+
+func TestHubMessagePersister(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+	persister := &fakeArchiver{}
+	hub.SetMessagePersister(persister)
+	hub.SetConversationTracker(&fakeConversationTracker{seq: map[string]int64{}, reads: map[string]int64{}})
+	bob := &Connection{ID: "conn_bob", UserID: "bob", Send: make(chan []byte, 16), Hub: hub,
+		Groups: map[string]bool{"room": true}, Metadata: make(map[string]interface{})}
+
+	// 未配置归档时仍然持久化，不要求消息包含文本
+	bob.handleChat(Message{Type: MessageTypeChat, Group: "room", From: "bob", Data: map[string]interface{}{"image": "a.png"}})
+	persister.mu.Lock()
+	defer persister.mu.Unlock()
+	require.Len(t, persister.msgs, 1)
+	assert.Equal(t, "group:room", persister.msgs[0].Conversation)
+	assert.Equal(t, int64(1), persister.msgs[0].ID)
+}
+
+type fixedExpiryPolicy map[string]time.Duration
+
+func (p fixedExpiryPolicy) MessageTTL(conversation string) time.Duration {
+	return p[conversation]
+}
+
+func TestHubMessageExpiry(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+	persister := &fakeArchiver{}
+	hub.SetMessagePersister(persister)
+	hub.SetConversationTracker(&fakeConversationTracker{seq: map[string]int64{}, reads: map[string]int64{}})
+	hub.SetExpiryPolicy(fixedExpiryPolicy{"group:room": time.Minute})
+
+	newConn := func(id, userID string) *Connection {
+		c := &Connection{ID: id, UserID: userID, Send: make(chan []byte, 16), Hub: hub, LastPing: time.Now(),
+			IsAlive: true, Groups: map[string]bool{"room": true}, Metadata: make(map[string]interface{})}
+		hub.register <- c
+		return c
+	}
+	alice := newConn("conn_alice", "alice")
+	bob := newConn("conn_bob", "bob")
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 2 }, time.Second, 10*time.Millisecond)
+	readType := func(c *Connection, typ string) Message {
+		deadline := time.After(time.Second)
+		for {
+			select {
+			case data := <-c.Send:
+				var msg Message
+				require.NoError(t, json.Unmarshal(data, &msg))
+				if msg.Type == typ {
+					return msg
+				}
+			case <-deadline:
+				t.Fatalf("未收到 %s 消息", typ)
+			}
+		}
+	}
+
+	// 未指定 ttl 时使用会话默认值，指定时优先
+	now := time.Now().Unix()
+	bob.handleChat(Message{Type: MessageTypeChat, Group: "room", From: "bob", Data: map[string]interface{}{"text": "a"}})
+	bob.handleChat(Message{Type: MessageTypeChat, Group: "room", From: "bob", TTL: 5, Data: map[string]interface{}{"text": "b"}})
+	bob.handleChat(Message{Type: MessageTypeChat, To: "alice", From: "bob", Data: map[string]interface{}{"text": "c"}})
+	persister.mu.Lock()
+	require.Len(t, persister.msgs, 3)
+	assert.Equal(t, int64(60), persister.msgs[0].TTL)
+	assert.InDelta(t, now+60, persister.msgs[0].ExpiresAt, 1)
+	assert.Equal(t, int64(5), persister.msgs[1].TTL)
+	assert.InDelta(t, now+5, persister.msgs[1].ExpiresAt, 1)
+	assert.Zero(t, persister.msgs[2].ExpiresAt)
+	persister.mu.Unlock()
+
+	// 无效的 ttl 被拒绝
+	bob.handleChat(Message{Type: MessageTypeChat, Group: "room", From: "bob", TTL: -1, Data: map[string]interface{}{}})
+	assert.Equal(t, ErrInvalidMessageTTL, readType(bob, MessageTypeError).Data)
+
+	// 已过期的消息不再投递
+	readType(alice, MessageTypeChat)
+	readType(alice, MessageTypeChat)
+	readType(alice, MessageTypeChat)
+	expired, err := newEncodedMessage(&Message{Type: MessageTypeChat, ExpiresAt: now - 1})
+	require.NoError(t, err)
+	hub.trySend(alice, expired, func() {})
+	select {
+	case data := <-alice.Send:
+		t.Fatalf("不应投递已过期的消息: %s", data)
+	default:
+	}
+
+	hub.NotifyMessagesExpired("group:room", []int64{1, 2})
+	notice := readType(alice, MessageTypeMessagesExpired)
+	assert.Equal(t, "group:room", notice.Conversation)
+	assert.Equal(t, []interface{}{float64(1), float64(2)}, notice.Data.(map[string]interface{})["message_ids"])
+	hub.NotifyConversationTTL("dm:alice:bob", time.Hour, "alice")
+	assert.Equal(t, float64(3600), readType(bob, MessageTypeConversationTTL).Data.(map[string]interface{})["ttl"])
+
+	hub.unregister <- alice
+	hub.unregister <- bob
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+type fakeReactionStore struct {
+	mu    sync.Mutex
+	users map[string][]string // emoji -> users
+	order []string
+}
+
+func (f *fakeReactionStore) aggregates() []ReactionAggregate {
+	out := []ReactionAggregate{}
+	for _, emoji := range f.order {
+		if users := f.users[emoji]; len(users) > 0 {
+			out = append(out, ReactionAggregate{Emoji: emoji, Count: int64(len(users)), Users: users})
+		}
+	}
+	return out
+}
+
+func (f *fakeReactionStore) AddReaction(conversation string, messageID int64, userID, emoji string) ([]ReactionAggregate, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range f.users[emoji] {
+		if u == userID {
+			return f.aggregates(), false, nil
+		}
+	}
+	if _, ok := f.users[emoji]; !ok {
+		f.order = append(f.order, emoji)
+	}
+	f.users[emoji] = append(f.users[emoji], userID)
+	return f.aggregates(), true, nil
+}
+
+func (f *fakeReactionStore) RemoveReaction(conversation string, messageID int64, userID, emoji string) ([]ReactionAggregate, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, u := range f.users[emoji] {
+		if u == userID {
+			f.users[emoji] = append(f.users[emoji][:i], f.users[emoji][i+1:]...)
+			return f.aggregates(), true, nil
+		}
+	}
+	return f.aggregates(), false, nil
+}
+
+func TestHubReactions(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+
+	newConn := func(id, userID string) *Connection {
+		c := &Connection{
+			ID:       id,
+			UserID:   userID,
+			Send:     make(chan []byte, 16),
+			Hub:      hub,
+			LastPing: time.Now(),
+			IsAlive:  true,
+			Groups:   map[string]bool{"room": true},
+			Metadata: make(map[string]interface{}),
+		}
+		hub.register <- c
+		return c
+	}
+	readType := func(c *Connection, typ string) Message {
+		deadline := time.After(time.Second)
+		for {
+			select {
+			case data := <-c.Send:
+				var msg Message
+				require.NoError(t, json.Unmarshal(data, &msg))
+				if msg.Type == typ {
+					return msg
+				}
+			case <-deadline:
+				t.Fatalf("未收到 %s 消息", typ)
+			}
+		}
+	}
+	react := func(c *Connection, typ, conversation, emoji string) {
+		c.handleMessage(websocket.TextMessage, []byte(`{"type":"`+typ+`","data":{"conversation":"`+conversation+`","message_id":7,"emoji":"`+emoji+`"}}`))
+	}
+
+	alice := newConn("conn_alice", "alice")
+	bob := newConn("conn_bob", "bob")
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	// 未配置存储时返回错误
+	react(alice, MessageTypeReact, "group:room", "👍")
+	assert.Equal(t, ErrReactionUnavailable.Error(), readType(alice, MessageTypeError).Data)
+
+	store := &fakeReactionStore{users: map[string][]string{}}
+	hub.SetReactionStore(store)
+
+	// 组会话中的回应广播给所有成员，包括回应者自己
+	react(alice, MessageTypeReact, "group:room", "👍")
+	react(bob, MessageTypeReact, "group:room", "👍")
+	for _, c := range []*Connection{alice, bob} {
+		msg := readType(c, MessageTypeReactionChanged)
+		assert.Equal(t, int64(7), msg.ID)
+		assert.Equal(t, "alice", msg.From)
+	}
+	msg := readType(alice, MessageTypeReactionChanged)
+	data := msg.Data.(map[string]interface{})
+	assert.Equal(t, ReactionActionAdd, data["action"])
+	reactions := data["reactions"].([]interface{})
+	require.Len(t, reactions, 1)
+	assert.Equal(t, float64(2), reactions[0].(map[string]interface{})["count"])
+	readType(bob, MessageTypeReactionChanged)
+
+	// 重复回应不再广播，取消回应广播 remove
+	react(alice, MessageTypeReact, "group:room", "👍")
+	react(alice, MessageTypeUnreact, "group:room", "👍")
+	msg = readType(bob, MessageTypeReactionChanged)
+	data = msg.Data.(map[string]interface{})
+	assert.Equal(t, ReactionActionRemove, data["action"])
+	assert.Equal(t, float64(1), data["reactions"].([]interface{})[0].(map[string]interface{})["count"])
+
+	// 私聊回应发送给双方
+	react(alice, MessageTypeReact, DirectConversation("alice", "bob"), "🎉")
+	assert.Equal(t, "🎉", readType(bob, MessageTypeReactionChanged).Data.(map[string]interface{})["emoji"])
+
+	// 非成员、非法表情与禁言用户被拒绝
+	react(alice, MessageTypeReact, "group:other", "👍")
+	assert.Equal(t, ErrNotInGroup, readType(alice, MessageTypeError).Data)
+	react(alice, MessageTypeReact, "group:room", "a b")
+	assert.Equal(t, ErrInvalidReaction, readType(alice, MessageTypeError).Data)
+	hub.SetModerator(&stubModerator{muted: map[string]bool{"alice": true}})
+	react(alice, MessageTypeReact, "group:room", "❤️")
+	assert.Equal(t, "您已被禁言", readType(alice, MessageTypeError).Data)
+
+	hub.unregister <- alice
+	hub.unregister <- bob
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	auth := NewJWTAuthenticator("secret", "hibiscus")
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+
+	token, err := SignJWT("secret", map[string]interface{}{"sub": 42, "iss": "hibiscus", "exp": now.Add(time.Minute).Unix()})
+	require.NoError(t, err)
+	userID, err := auth.Authenticate(nil, token)
+	require.NoError(t, err)
+	assert.Equal(t, "42", userID)
+
+	forged, _ := SignJWT("other", map[string]interface{}{"sub": "42", "iss": "hibiscus"})
+	_, err = auth.Authenticate(nil, forged)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	expired, _ := SignJWT("secret", map[string]interface{}{"sub": "42", "iss": "hibiscus", "exp": now.Add(-time.Hour).Unix()})
+	_, err = auth.Authenticate(nil, expired)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+
+	wrongIssuer, _ := SignJWT("secret", map[string]interface{}{"sub": "42", "iss": "someone"})
+	_, err = auth.Authenticate(nil, wrongIssuer)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = auth.Authenticate(nil, "not-a-jwt")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestHandshakeAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.JWTSecret = "secret"
+	cfg.AllowedOrigins = []string{"https://app.example.com", "*.example.org"}
+	hub := NewHub(cfg)
+	defer hub.Close()
+
+	r := gin.New()
+	r.GET("/ws", NewHandler(hub).HandleWebSocket)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	base := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	token, err := SignJWT("secret", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Minute).Unix()})
+	require.NoError(t, err)
+
+	expectClose := func(conn *websocket.Conn, code int) {
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err := conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, code, closeErr.Code)
+	}
+
+	// 缺少令牌
+	conn, _, err := websocket.DefaultDialer.Dial(base, nil)
+	require.NoError(t, err)
+	expectClose(conn, CloseCodeUnauthorized)
+
+	// 无效令牌
+	conn, _, err = websocket.DefaultDialer.Dial(base+"?token=bad", nil)
+	require.NoError(t, err)
+	expectClose(conn, CloseCodeUnauthorized)
+
+	// 来源不在白名单
+	conn, _, err = websocket.DefaultDialer.Dial(base+"?token="+token, http.Header{"Origin": []string{"https://evil.com"}})
+	require.NoError(t, err)
+	expectClose(conn, CloseCodeForbiddenOrigin)
+
+	// 查询参数携带令牌
+	conn, _, err = websocket.DefaultDialer.Dial(base+"?access_token="+token, http.Header{"Origin": []string{"https://app.example.com"}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return hub.GetUserConnections("alice") == 1 }, time.Second, 10*time.Millisecond)
+	conn.Close()
+	require.Eventually(t, func() bool { return hub.GetUserConnections("alice") == 0 }, time.Second, 10*time.Millisecond)
+
+	// 子协议携带令牌，服务端只回显子协议名
+	dialer := websocket.Dialer{Subprotocols: []string{AuthSubprotocol, token}}
+	conn, resp, err := dialer.Dial(base, http.Header{"Origin": []string{"https://chat.example.org"}})
+	require.NoError(t, err)
+	assert.Equal(t, AuthSubprotocol, resp.Header.Get("Sec-WebSocket-Protocol"))
+	require.Eventually(t, func() bool { return hub.GetUserConnections("alice") == 1 }, time.Second, 10*time.Millisecond)
+	conn.Close()
+	require.Eventually(t, func() bool { return hub.GetUserConnections("alice") == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := Schema{Data: FieldObject, Required: map[string]FieldType{"conversation": FieldString, "message_id": FieldNumber},
+		Optional: map[string]FieldType{"note": FieldString}, RequireTarget: true}
+
+	err := schema.Validate(&Message{Data: map[string]interface{}{}})
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, "to", schemaErr.Field)
+
+	err = schema.Validate(&Message{Group: "g", Data: "text"})
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, "data", schemaErr.Field)
+
+	err = schema.Validate(&Message{Group: "g", Data: map[string]interface{}{"conversation": "group:g"}})
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, "data.message_id", schemaErr.Field)
+	assert.Equal(t, "is required", schemaErr.Reason)
+
+	err = schema.Validate(&Message{Group: "g", Data: map[string]interface{}{"conversation": "group:g", "message_id": 1.0, "note": 2.0}})
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, "data.note", schemaErr.Field)
+
+	assert.NoError(t, schema.Validate(&Message{Group: "g", Data: map[string]interface{}{"conversation": "group:g", "message_id": 1.0}}))
+
+	// 未登记的类型不校验，登记后按约束校验
+	registry := NewSchemaRegistry()
+	assert.NoError(t, registry.ValidateInbound(&Message{Type: "custom", Data: 1.0}))
+	registry.RegisterInbound("custom", Schema{Data: FieldString})
+	assert.Error(t, registry.ValidateInbound(&Message{Type: "custom", Data: 1.0}))
+	registry.RegisterInbound("custom", nil)
+	assert.NoError(t, registry.ValidateInbound(&Message{Type: "custom", Data: 1.0}))
+}
+
+func TestHubSchemaViolations(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxViolations = 2
+	hub := NewHub(cfg)
+	defer hub.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(hub, w, r, "alice")
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	readError := func() map[string]interface{} {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			var msg Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			if msg.Type == MessageTypeError {
+				data, ok := msg.Data.(map[string]interface{})
+				require.True(t, ok, "expected structured error, got %v", msg.Data)
+				return data
+			}
+		}
+	}
+
+	// 不符合约束的消息下发结构化错误帧
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeRead, Data: map[string]interface{}{"conversation": "group:g"}}))
+	data := readError()
+	assert.Equal(t, InvalidMessageCode, data["code"])
+	assert.Equal(t, MessageTypeRead, data["message_type"])
+	assert.Equal(t, "data.message_id", data["field"])
+
+	// 无法解析的帧同样计入违规
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{not json")))
+	assert.Equal(t, InvalidMessageCode, readError()["code"])
+
+	// 超过阈值后以 1008 关闭连接
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeJoinGroup, Data: 1}))
+	assert.Equal(t, "data", readError()["field"])
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "unexpected error: %v", err)
+	assert.Equal(t, int64(3), hub.Counters().Violations)
+}
+
+func TestHandshakeDiagnostics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.JWTSecret = "secret"
+	cfg.AllowedOrigins = []string{"https://app.example.com"}
+	cfg.MaxConnections = 1
+	hub := NewHub(cfg)
+	defer hub.Close()
+
+	r := gin.New()
+	r.GET("/ws", NewHandler(hub).HandleWebSocket)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	base := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	token, err := SignJWT("secret", map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Minute).Unix()})
+	require.NoError(t, err)
+
+	// 认证失败，令牌子协议不记录
+	dialer := websocket.Dialer{Subprotocols: []string{AuthSubprotocol, "bad-token"}}
+	conn, _, err := dialer.Dial(base, http.Header{"User-Agent": []string{"test-client/1.0"}})
+	require.NoError(t, err)
+	conn.Close()
+
+	// 来源被拒绝
+	conn, _, err = websocket.DefaultDialer.Dial(base+"?token="+token, http.Header{"Origin": []string{"https://evil.com"}})
+	require.NoError(t, err)
+	conn.Close()
+
+	// 非 WebSocket 请求
+	resp, err := http.Get(srv.URL + "/ws?token=" + token)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	conn, _, err = websocket.DefaultDialer.Dial(base+"?token="+token, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// 达到连接上限
+	second, _, err := websocket.DefaultDialer.Dial(base+"?token="+token, nil)
+	require.NoError(t, err)
+	_ = second.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = second.ReadMessage()
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, websocket.CloseTryAgainLater, closeErr.Code)
+	second.Close()
+
+	stats := hub.HandshakeStats("", 0)
+	assert.Equal(t, map[string]int64{
+		HandshakeSuccess:          1,
+		HandshakeAuthFailed:       1,
+		HandshakeOriginRejected:   1,
+		HandshakeProtocolMismatch: 1,
+		HandshakeLimitReached:     1,
+	}, stats.Counts)
+	require.Len(t, stats.Failures, 4)
+	assert.Equal(t, HandshakeLimitReached, stats.Failures[0].Outcome)
+	assert.Equal(t, "127.0.0.1", stats.Failures[0].ClientIP)
+
+	auth := hub.HandshakeStats(HandshakeAuthFailed, 10).Failures
+	require.Len(t, auth, 1)
+	assert.Equal(t, []string{AuthSubprotocol}, auth[0].Subprotocols)
+	assert.Equal(t, "test-client/1.0", auth[0].UserAgent)
+	assert.Equal(t, "13", auth[0].Version)
+	assert.Equal(t, "/ws", auth[0].Path)
+
+	origin := hub.HandshakeStats(HandshakeOriginRejected, 10).Failures
+	require.Len(t, origin, 1)
+	assert.Equal(t, "https://evil.com", origin[0].Origin)
+}
+
+type fakeGroupAuthorizer struct {
+	members map[string][]string
+}
+
+func (f *fakeGroupAuthorizer) AuthorizeJoin(group, userID string) error {
+	for _, g := range f.members[userID] {
+		if g == group {
+			return nil
+		}
+	}
+	return ErrNotGroupMember
+}
+
+func (f *fakeGroupAuthorizer) UserGroups(userID string) ([]string, error) {
+	return f.members[userID], nil
+}
+
+// bannedGroups 按组封禁的组管理检查器
+type bannedGroups map[string]bool
+
+func (b bannedGroups) CheckSend(group, userID string) error { return nil }
+
+func (b bannedGroups) CheckJoin(group, userID string) error {
+	if b[group] {
+		return errors.New("您已被禁止进入该群组")
+	}
+	return nil
+}
+
+func TestHubGroupAuthorizer(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+	hub.SetGroupAuthorizer(&fakeGroupAuthorizer{members: map[string][]string{"alice": {"1", "2", "3"}}})
+	hub.SetModerator(bannedGroups{"3": true})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(hub, w, r, r.URL.Query().Get("user"))
+	}))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?user=alice", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	readType := func(typ string) Message {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			var msg Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			if msg.Type == typ {
+				return msg
+			}
+		}
+	}
+
+	// 连接建立时自动加入持久化的组，被封禁的组跳过
+	msg := readType(MessageTypeGroupsRestored)
+	assert.Equal(t, []interface{}{"1", "2"}, msg.Data)
+	require.Eventually(t, func() bool { return hub.GetGroupConnections("1") == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, hub.GetGroupConnections("2"))
+	assert.Equal(t, 0, hub.GetGroupConnections("3"))
+
+	// 非成员不能加入
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeJoinGroup, Data: "9"}))
+	assert.Equal(t, ErrNotGroupMember.Error(), readType(MessageTypeError).Data)
+	assert.Equal(t, 0, hub.GetGroupConnections("9"))
+
+	// 离开后可以重新加入所属的组
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeLeaveGroup, Data: "2"}))
+	readType(MessageTypeGroupLeft)
+	require.NoError(t, conn.WriteJSON(Message{Type: MessageTypeJoinGroup, Data: "2"}))
+	assert.Equal(t, "2", readType(MessageTypeGroupJoined).Data)
+
+	conn.Close()
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestCodecs(t *testing.T) {
+	msg := &Message{
+		Type:         MessageTypeChat,
+		Data:         map[string]interface{}{"text": "hi", "n": 1, "tags": []string{"a"}},
+		Timestamp:    1700000000,
+		From:         "alice",
+		Group:        "room",
+		ID:           42,
+		Conversation: "group:room",
+		TTL:          60,
+		ExpiresAt:    1700000060,
+	}
+	for _, c := range []Codec{JSONCodec, NewMsgpackCodec(), ProtobufCodec} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(msg)
+			require.NoError(t, err)
+			var got Message
+			require.NoError(t, c.Unmarshal(data, &got))
+			got.Data, err = normalizeData(got.Data)
+			require.NoError(t, err)
+			assert.Equal(t, msg.Type, got.Type)
+			assert.Equal(t, msg.Timestamp, got.Timestamp)
+			assert.Equal(t, msg.From, got.From)
+			assert.Equal(t, msg.Group, got.Group)
+			assert.Equal(t, msg.ID, got.ID)
+			assert.Equal(t, msg.Conversation, got.Conversation)
+			assert.Equal(t, msg.TTL, got.TTL)
+			assert.Equal(t, msg.ExpiresAt, got.ExpiresAt)
+			assert.Equal(t, map[string]interface{}{"text": "hi", "n": float64(1), "tags": []interface{}{"a"}}, got.Data)
+		})
+	}
+
+	hub := NewHub(nil)
+	defer hub.Close()
+	assert.Error(t, hub.RegisterCodec(JSONCodec))
+
+	// 同一编码只序列化一次
+	em, err := newEncodedMessage(msg)
+	require.NoError(t, err)
+	mp := hub.codecs[CodecMsgpack]
+	first := em.bytesFor(mp)
+	require.NotEmpty(t, first)
+	assert.Same(t, &first[0], &em.bytesFor(mp)[0])
+	assert.Equal(t, em.json, em.bytesFor(nil))
+}
+
+func TestCodecNegotiation(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(hub, w, r, r.URL.Query().Get("user"))
+	}))
+	defer srv.Close()
+	base := "ws" + strings.TrimPrefix(srv.URL, "http") + "/"
+
+	dial := func(user, codec string) *websocket.Conn {
+		dialer := websocket.Dialer{Subprotocols: []string{codec}}
+		conn, resp, err := dialer.Dial(base+"?user="+user, nil)
+		require.NoError(t, err)
+		assert.Equal(t, codec, resp.Header.Get("Sec-WebSocket-Protocol"))
+		return conn
+	}
+	bob := dial("bob", CodecMsgpack)
+	defer bob.Close()
+	carol := dial("carol", CodecProtobuf)
+	defer carol.Close()
+
+	read := func(conn *websocket.Conn, c Codec, typ string) Message {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			frameType, data, err := conn.ReadMessage()
+			require.NoError(t, err)
+			assert.Equal(t, websocket.BinaryMessage, frameType)
+			var msg Message
+			require.NoError(t, c.Unmarshal(data, &msg))
+			if msg.Type == typ {
+				return msg
+			}
+		}
+	}
+	write := func(conn *websocket.Conn, c Codec, msg *Message) {
+		data, err := c.Marshal(msg)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, data))
+	}
+
+	write(bob, hub.codecs[CodecMsgpack], &Message{Type: MessageTypeJoinGroup, Data: "room"})
+	assert.Equal(t, "room", read(bob, hub.codecs[CodecMsgpack], MessageTypeGroupJoined).Data)
+	write(carol, ProtobufCodec, &Message{Type: MessageTypeJoinGroup, Data: "room"})
+	assert.Equal(t, "room", read(carol, ProtobufCodec, MessageTypeGroupJoined).Data)
+
+	// 同一条组消息按各自协商的编码下发
+	write(bob, hub.codecs[CodecMsgpack], &Message{Type: MessageTypeChat, Group: "room", Data: map[string]interface{}{"text": "hello"}})
+	got := read(carol, ProtobufCodec, MessageTypeChat)
+	assert.Equal(t, "bob", got.From)
+	assert.Equal(t, map[string]interface{}{"text": "hello"}, got.Data)
+	got = read(bob, hub.codecs[CodecMsgpack], MessageTypeChat)
+	assert.Equal(t, "room", got.Group)
+
+	bob.Close()
+	carol.Close()
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestConnectionQuality(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HeartbeatInterval = 30 * time.Second
+	cfg.ConnectionTimeout = 60 * time.Second
+	hub := NewHub(cfg)
+	defer hub.Close()
+
+	newConn := func(id string) *Connection {
+		c := &Connection{
+			ID:       id,
+			UserID:   id,
+			Send:     make(chan []byte, 16),
+			Hub:      hub,
+			LastPing: time.Now(),
+			IsAlive:  true,
+			Groups:   make(map[string]bool),
+			Metadata: make(map[string]interface{}),
+		}
+		hub.register <- c
+		return c
+	}
+	report := func(c *Connection, data string) {
+		c.handleMessage(websocket.TextMessage, []byte(`{"type":"quality","data":`+data+`}`))
+	}
+	readQuality := func(c *Connection) map[string]interface{} {
+		select {
+		case data := <-c.Send:
+			var msg Message
+			require.NoError(t, json.Unmarshal(data, &msg))
+			require.Equal(t, MessageTypeQuality, msg.Type)
+			return msg.Data.(map[string]interface{})
+		case <-time.After(time.Second):
+			t.Fatal("未收到 quality 消息")
+			return nil
+		}
+	}
+
+	stable := newConn("stable")
+	flaky := newConn("flaky")
+	idle := newConn("idle")
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 3 }, time.Second, 10*time.Millisecond)
+
+	// 样本不足时保持未知等级，不下发
+	report(stable, `{"rtt": 40, "loss": 0}`)
+	report(stable, `{"rtt": 60, "loss": 0}`)
+	assert.Len(t, stable.Send, 0)
+	report(stable, `{"rtt": 50, "loss": 0, "jitter": 5}`)
+	q := readQuality(stable)
+	assert.Equal(t, QualityGood, q["grade"])
+	// 稳定链路放大心跳，但不超过连接超时的 3/4
+	assert.Equal(t, float64(45), q["heartbeat_interval"])
+	assert.Equal(t, false, q["batching"])
+
+	for i := 0; i < 3; i++ {
+		report(flaky, `{"rtt": 800, "loss": 0.2}`)
+	}
+	q = readQuality(flaky)
+	assert.Equal(t, QualityPoor, q["grade"])
+	assert.Equal(t, float64(10), q["heartbeat_interval"])
+	assert.Equal(t, true, q["batching"])
+
+	// 等级不变时不重复下发
+	report(flaky, `{"rtt": 900}`)
+	assert.Len(t, flaky.Send, 0)
+
+	report(idle, `{"rtt": -1}`)
+	var msg Message
+	require.NoError(t, json.Unmarshal(<-idle.Send, &msg))
+	assert.Equal(t, MessageTypeError, msg.Type)
+	assert.Equal(t, ErrInvalidQuality, msg.Data)
+
+	stats := hub.QualityStats()
+	assert.Equal(t, 1, stats.Grades[QualityGood]+stats.Grades[QualityFair])
+	assert.Equal(t, 1, stats.Grades[QualityPoor])
+	assert.Equal(t, 1, stats.Grades[QualityUnknown])
+	assert.Equal(t, 1, stats.RTT["<150ms"]+stats.RTT["<50ms"])
+	assert.Equal(t, 1, stats.RTT["<1s"])
+	assert.Equal(t, 1, stats.Heartbeats[30])
+
+	// 服务端 ping 未收到 pong 计为丢包，丢包率过高降为弱网
+	stable.quality.pingSent(time.Now())
+	stable.quality.pingSent(time.Now())
+	stable.quality.pongReceived(time.Now())
+	assert.Greater(t, stable.Quality().Loss, 0.0)
+	assert.Equal(t, QualityPoor, stable.Quality().Grade)
+
+	// 关闭自适应后统一使用基础间隔
+	hub.config.AdaptiveHeartbeat = false
+	assert.Equal(t, 30*time.Second, hub.heartbeatFor(QualityGood))
+	assert.Equal(t, 30*time.Second, hub.heartbeatFor(QualityPoor))
+
+	for _, c := range []*Connection{stable, flaky, idle} {
+		hub.unregister <- c
+	}
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+type gaugeRecorder struct {
+	mu     sync.Mutex
+	gauges map[string]float64
+}
+
+func (r *gaugeRecorder) SetGauge(name string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges[name] = value
+}
+
+func (r *gaugeRecorder) get(name string) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.gauges[name]
+	return v, ok
+}
+
+func TestCapacityPlanning(t *testing.T) {
+	// 每小时增长 100 连接，即每日 2400
+	start := time.Now().Add(-10 * time.Hour)
+	var samples []StatsPoint
+	for i := 0; i <= 10; i++ {
+		samples = append(samples, StatsPoint{Timestamp: start.Add(time.Duration(i) * time.Hour), Value: float64(40000 + i*100)})
+	}
+	report := PlanCapacity(samples, 41000, 60000, 4, 30*24*time.Hour)
+	assert.InDelta(t, 2400, report.GrowthPerDay, 0.01)
+	assert.InDelta(t, 41000+2400*30, report.ProjectedPeak, 0.01)
+	require.NotNil(t, report.DaysToWarning)
+	require.NotNil(t, report.DaysToLimit)
+	assert.InDelta(t, 7000.0/2400, *report.DaysToWarning, 0.01)
+	assert.InDelta(t, 19000.0/2400, *report.DaysToLimit, 0.01)
+	// 113000 / (60000*0.7) 向上取整为 3 个节点，每节点约 37667 连接需 8 个分片
+	assert.Equal(t, 3, report.RecommendedNodes)
+	assert.Equal(t, 8, report.RecommendedShards)
+
+	// 已超过告警线时只给出触顶时间
+	report = PlanCapacity(samples, 41000, 50000, 8, 30*24*time.Hour)
+	assert.Nil(t, report.DaysToWarning)
+	assert.InDelta(t, 9000.0/2400, *report.DaysToLimit, 0.01)
+
+	// 样本不足时不预测增长，按窗口峰值给出建议，分片数不低于当前值
+	flat := PlanCapacity(samples[:1], 100, 50000, 16, time.Hour)
+	assert.Zero(t, flat.GrowthPerDay)
+	assert.Nil(t, flat.DaysToLimit)
+	assert.Equal(t, 40000.0, flat.Peak)
+	assert.Equal(t, 2, flat.RecommendedNodes)
+	assert.Equal(t, 16, flat.RecommendedShards)
+}
+
+func TestHubStatsRecording(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+
+	conn := &Connection{ID: "c1", UserID: "u1", Send: make(chan []byte, 4), Hub: hub, Groups: map[string]bool{}, Metadata: map[string]interface{}{}}
+	em, err := newEncodedMessage(&Message{Type: MessageTypeChat, Data: "hi"})
+	require.NoError(t, err)
+	hub.trySend(conn, em, func() {})
+	conn.handleMessage(websocket.TextMessage, []byte(`{"type":"ping"}`))
+	hub.recordDrop(DropReasonSendBufferFull, DeadLetterTargetConnection, conn.ID, conn.UserID, []byte("x"))
+
+	counters := hub.Counters()
+	assert.Equal(t, int64(1), counters.MessagesIn)
+	assert.Equal(t, int64(1), counters.MessagesOut)
+	assert.Equal(t, int64(1), counters.Drops)
+
+	rec := &gaugeRecorder{gauges: map[string]float64{}}
+	hub.RecordStats(rec, 20*time.Millisecond)
+	hub.trySend(conn, em, func() {})
+	require.Eventually(t, func() bool {
+		_, ok := rec.get(MetricWSMessagesOut)
+		return ok
+	}, time.Second, 5*time.Millisecond)
+	v, _ := rec.get(MetricWSConnections)
+	assert.Equal(t, 0.0, v)
+
+	// 导出历史与容量报告
+	handler := NewHandler(hub)
+	handler.SetStatsHistory(func(metric string, since time.Time) []StatsPoint {
+		return []StatsPoint{{Timestamp: time.Unix(1700000000, 0).UTC(), Value: 3}, {Timestamp: time.Unix(1700000010, 0).UTC(), Value: 5}}
+	})
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/history", handler.ExportStatsHistory)
+	r.GET("/capacity", handler.GetCapacity)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/history?format=csv&metric=ws.connections", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "metric,timestamp,value\nws.connections,2023-11-14T22:13:20Z,3\nws.connections,2023-11-14T22:13:30Z,5\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/history", nil))
+	assert.Equal(t, 2*len(StatsMetrics), strings.Count(w.Body.String(), "\n"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/capacity?horizon=24h", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Capacity CapacityReport `json:"capacity"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.InDelta(t, 2*8640, body.Capacity.GrowthPerDay, 0.01)
+}
+
+func TestHubSlowConsumers(t *testing.T) {
+	config := DefaultConfig()
+	config.DropOnFull = true
+	hub := NewHub(config)
+	defer hub.Close()
+
+	newConn := func(id, user string) *Connection {
+		return &Connection{
+			ID:          id,
+			UserID:      user,
+			Send:        make(chan []byte, 2),
+			Hub:         hub,
+			IsAlive:     true,
+			Groups:      make(map[string]bool),
+			Metadata:    map[string]interface{}{"device": "ios"},
+			ConnectedAt: time.Now(),
+			UserAgent:   "test-agent",
+		}
+	}
+	slow, fast := newConn("conn_slow", "slow"), newConn("conn_fast", "fast")
+	hub.register <- slow
+	hub.register <- fast
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 2 }, time.Second, 10*time.Millisecond)
+
+	// 慢连接缓冲区容量为 2，第三条消息被丢弃
+	for i := 0; i < 3; i++ {
+		hub.SendToUser("slow", &Message{Type: MessageTypeNotification, Data: i})
+	}
+	require.Eventually(t, func() bool {
+		drops, _ := slow.sendStats.snapshot()
+		return drops == 1
+	}, time.Second, 10*time.Millisecond)
+
+	consumers := hub.SlowConsumers(SlowConsumerFilter{})
+	require.Len(t, consumers, 1)
+	sc := consumers[0]
+	assert.Equal(t, "conn_slow", sc.ConnectionID)
+	assert.Equal(t, "slow", sc.UserID)
+	assert.Equal(t, 2, sc.QueueDepth)
+	assert.Equal(t, int64(1), sc.Drops)
+	assert.Equal(t, "ios", sc.Metadata["device"])
+	require.Len(t, sc.RecentDrops, 1)
+	assert.Equal(t, ConnDropBufferFull, sc.RecentDrops[0].Reason)
+
+	// 消费一条后队列未满，提高阈值后不再列出
+	<-slow.Send
+	assert.Empty(t, hub.SlowConsumers(SlowConsumerFilter{QueueRatio: 1, MinDrops: 5}))
+
+	assert.ErrorIs(t, hub.CloseConnection("missing"), ErrConnectionNotFound)
+	assert.Equal(t, 1, hub.CloseSlowConsumers(SlowConsumerFilter{}))
+	require.Eventually(t, func() bool { return hub.GetUserConnections("slow") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, hub.GetUserConnections("fast"))
+	hub.unregister <- fast
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestConnSendStatsRecent(t *testing.T) {
+	var s connSendStats
+	start := time.Now()
+	for i := 0; i < connRecentDrops+3; i++ {
+		s.add(ConnDropSendTimeout, start.Add(time.Duration(i)*time.Second))
+	}
+	drops, recent := s.snapshot()
+	assert.Equal(t, int64(connRecentDrops+3), drops)
+	require.Len(t, recent, connRecentDrops)
+	// 按时间倒序，只保留最近的记录
+	assert.Equal(t, start.Add(time.Duration(connRecentDrops+2)*time.Second), recent[0].At)
+	assert.Equal(t, start.Add(3*time.Second), recent[connRecentDrops-1].At)
+}
+
+func TestHubListConnections(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+
+	start := time.Now()
+	newConn := func(id, user string, at time.Time, groups []string, platform string) *Connection {
+		conn := &Connection{
+			ID:          id,
+			UserID:      user,
+			Send:        make(chan []byte, 4),
+			Hub:         hub,
+			IsAlive:     true,
+			Groups:      make(map[string]bool),
+			Metadata:    map[string]interface{}{"platform": platform, "build": 42},
+			ConnectedAt: at,
+		}
+		for _, g := range groups {
+			conn.Groups[g] = true
+		}
+		return conn
+	}
+	conns := []*Connection{
+		newConn("c1", "alice", start, []string{"ops"}, "ios"),
+		newConn("c2", "alice", start.Add(time.Second), nil, "web"),
+		newConn("c3", "bob", start.Add(2*time.Second), []string{"ops", "dev"}, "ios"),
+	}
+	for _, c := range conns {
+		hub.register <- c
+	}
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 3 }, time.Second, 10*time.Millisecond)
+
+	// 最近建立的排在前面
+	page := hub.ListConnections(ConnectionFilter{})
+	assert.Equal(t, 3, page.Total)
+	require.Len(t, page.Items, 3)
+	assert.Equal(t, "c3", page.Items[0].ConnectionID)
+	assert.Equal(t, []string{"dev", "ops"}, page.Items[0].Groups)
+
+	page = hub.ListConnections(ConnectionFilter{UserID: "alice", Limit: 1, Offset: 1})
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "c1", page.Items[0].ConnectionID)
+
+	page = hub.ListConnections(ConnectionFilter{Group: "ops", Metadata: map[string]string{"platform": "ios", "build": "42"}})
+	assert.Equal(t, 2, page.Total)
+	page = hub.ListConnections(ConnectionFilter{Since: start.Add(time.Second), Metadata: map[string]string{"platform": "ios"}})
+	require.Equal(t, 1, page.Total)
+	assert.Equal(t, "c3", page.Items[0].ConnectionID)
+
+	page = hub.ListConnections(ConnectionFilter{Offset: 10})
+	assert.Equal(t, 3, page.Total)
+	assert.Empty(t, page.Items)
+
+	for _, c := range conns {
+		hub.unregister <- c
+	}
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestHubGracefulShutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := DefaultConfig()
+	cfg.ShutdownReconnectAfter = time.Second
+	hub := NewHub(cfg)
+
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) { HandleWebSocket(hub, c.Writer, c.Request, "alice") })
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, hub.Shutdown(context.Background(), "maintenance"))
+	assert.True(t, hub.Draining())
+
+	// 先收到关闭通知，再收到 1012 关闭帧
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var notice *Message
+	for notice == nil {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		for _, line := range strings.Split(string(data), "\n") {
+			var msg Message
+			if json.Unmarshal([]byte(line), &msg) == nil && msg.Type == MessageTypeServerShutdown {
+				notice = &msg
+			}
+		}
+	}
+	data := notice.Data.(map[string]interface{})
+	assert.Equal(t, "maintenance", data["reason"])
+	assert.GreaterOrEqual(t, data["reconnectAfterMs"], float64(1000))
+	assert.Less(t, data["reconnectAfterMs"], float64(2000))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseServiceRestart), err)
+
+	// 关闭后不再接受新连接
+	second, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer second.Close()
+	_, _, err = second.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseServiceRestart), err)
+	assert.EqualValues(t, 1, hub.HandshakeStats(HandshakeShuttingDown, 0).Counts[HandshakeShuttingDown])
+}
+
+func TestConnectionChunking(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := NewHub(DefaultConfig())
+	defer hub.Close()
+
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) { HandleWebSocket(hub, c.Writer, c.Request, "alice") })
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?" + QueryParamChunked + "=1"
+
+	dialer := websocket.Dialer{EnableCompression: true}
+	conn, _, err := dialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.GetConnectionCount() == 1 }, time.Second, 10*time.Millisecond)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	readMessages := func() []Message {
+		_, data, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var out []Message
+		for _, line := range strings.Split(string(data), "\n") {
+			var msg Message
+			require.NoError(t, json.Unmarshal([]byte(line), &msg))
+			out = append(out, msg)
+		}
+		return out
+	}
+
+	// 上行：超过 MaxMessageSize 的消息拆片发送，收齐后按原消息处理
+	big, err := json.Marshal(Message{Type: MessageTypePing, Data: strings.Repeat("x", 2000)})
+	require.NoError(t, err)
+	for i, total := 0, (len(big)+199)/200; i < total; i++ {
+		frame, err := json.Marshal(Message{Type: MessageTypeChunk, Data: MessageChunk{
+			ID: "c1", Index: i, Total: total, Payload: big[i*200 : min((i+1)*200, len(big))],
+		}})
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(frame), hub.config.MaxMessageSize)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+	}
+	assert.Equal(t, MessageTypePong, readMessages()[0].Type)
+
+	// 下行：超过客户端帧上限的消息以 chunk 消息下发
+	text := strings.Repeat("hello ", 300)
+	hub.SendToUser("alice", &Message{Type: MessageTypeChat, Data: text})
+	var parts [][]byte
+	for {
+		var total int
+		for _, msg := range readMessages() {
+			require.Equal(t, MessageTypeChunk, msg.Type)
+			raw, err := json.Marshal(msg.Data)
+			require.NoError(t, err)
+			var chunk MessageChunk
+			require.NoError(t, json.Unmarshal(raw, &chunk))
+			if parts == nil {
+				parts = make([][]byte, chunk.Total)
+			}
+			parts[chunk.Index] = chunk.Payload
+			total = chunk.Total
+		}
+		received := 0
+		for _, p := range parts {
+			if p != nil {
+				received++
+			}
+		}
+		if received == total {
+			break
+		}
+	}
+	var msg Message
+	require.NoError(t, json.Unmarshal(bytes.Join(parts, nil), &msg))
+	assert.Equal(t, MessageTypeChat, msg.Type)
+	assert.Equal(t, text, msg.Data)
+}
+
+func TestHandleChunkLimits(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxChunkedMessageSize = 100
+	hub := NewHub(cfg)
+	defer hub.Close()
+	c := &Connection{ID: "conn_chunks", UserID: "alice", Send: make(chan []byte, 16), Hub: hub, IsAlive: true,
+		Groups: map[string]bool{}, Metadata: map[string]interface{}{}}
+	lastError := func() interface{} {
+		var msg Message
+		require.NoError(t, json.Unmarshal(<-c.Send, &msg))
+		require.Equal(t, MessageTypeError, msg.Type)
+		return msg.Data
+	}
+	chunk := func(id string, index, total int, payload []byte) {
+		data, err := json.Marshal(Message{Type: MessageTypeChunk, Data: MessageChunk{ID: id, Index: index, Total: total, Payload: payload}})
+		require.NoError(t, err)
+		c.handleMessage(websocket.TextMessage, data)
+	}
+
+	chunk("a", 2, 2, []byte("x"))
+	assert.Equal(t, ErrInvalidChunk, lastError())
+	chunk("b", 0, 2, make([]byte, 101))
+	assert.Equal(t, ErrChunkedMessageTooLarge, lastError())
+	assert.Empty(t, c.chunks)
+
+	// 分片内不能再嵌套分片
+	nested, _ := json.Marshal(Message{Type: MessageTypeChunk, Data: MessageChunk{ID: "x", Total: 1}})
+	chunk("c", 0, 1, nested)
+	assert.Equal(t, ErrInvalidChunk, lastError())
+
+	// 超时未收齐的分片被丢弃
+	chunk("d", 0, 2, []byte("{"))
+	c.chunks["d"].started = time.Now().Add(-time.Hour)
+	c.expireChunks(time.Now())
+	assert.Empty(t, c.chunks)
+
+	req := httptest.NewRequest(http.MethodGet, "/ws?chunked=4096", nil)
+	req.Header.Set("Sec-WebSocket-Extensions", "x-foo, permessage-deflate; client_max_window_bits")
+	assert.True(t, offersDeflate(req))
+	assert.Equal(t, 4096, negotiateChunkLimit(cfg, req))
+	assert.Equal(t, 0, negotiateChunkLimit(cfg, httptest.NewRequest(http.MethodGet, "/ws", nil)))
+	assert.False(t, offersDeflate(httptest.NewRequest(http.MethodGet, "/ws", nil)))
+}
@@ -0,0 +1,93 @@
+#!/bin/bash
+
+# 热点路径基准测试：Hub 广播扇出、trySend 背压、查询构建与搜索
+#
+#   scripts/bench.sh run        运行基准测试，结果写入 bench_output.txt
+#   scripts/bench.sh baseline   运行并记录为基线 scripts/bench_baseline.txt
+#   scripts/bench.sh check      运行并与基线比较，ns/op 或 allocs/op 退化超过阈值时失败
+#
+# 环境变量：
+#   BENCH_PKGS       基准测试包，默认 ./pkg/websocket/ ./pkg/search/
+#   BENCH_PATTERN    -bench 过滤表达式，默认 .
+#   BENCH_COUNT      每项运行次数，取最小值比较以降低噪声，默认 5
+#   BENCH_TIME       -benchtime，默认 1s
+#   BENCH_THRESHOLD  允许的退化百分比，默认 15
+#
+# 基线与机器相关，更换 CI 机器或有意的性能变化后需重新执行 baseline
+
+set -o pipefail
+export LC_ALL=C
+
+MODE="${1:-run}"
+BENCH_PKGS="${BENCH_PKGS:-./pkg/websocket/ ./pkg/search/}"
+BENCH_PATTERN="${BENCH_PATTERN:-.}"
+BENCH_COUNT="${BENCH_COUNT:-5}"
+BENCH_TIME="${BENCH_TIME:-1s}"
+BENCH_THRESHOLD="${BENCH_THRESHOLD:-15}"
+
+ROOT="$(cd "$(dirname "$0")/.." && pwd)"
+BASELINE="$ROOT/scripts/bench_baseline.txt"
+OUTPUT="$ROOT/bench_output.txt"
+
+run_bench() {
+  cd "$ROOT" || exit 1
+  # shellcheck disable=SC2086
+  go test $BENCH_PKGS -run '^$' -bench "$BENCH_PATTERN" -benchmem -count "$BENCH_COUNT" -benchtime "$BENCH_TIME" | tee "$1"
+}
+
+# summarize 汇总每项基准的最小 ns/op 与 allocs/op，输出：名称 ns/op allocs/op
+summarize() {
+  awk '
+    /^pkg: / { pkg = $2; next }
+    /^Benchmark/ {
+      name = pkg "." $1
+      sub(/-[0-9]+$/, "", name)
+      for (i = 2; i < NF; i++) {
+        if ($(i+1) == "ns/op" && (!(name in ns) || $i + 0 < ns[name])) ns[name] = $i + 0
+        if ($(i+1) == "allocs/op" && (!(name in allocs) || $i + 0 < allocs[name])) allocs[name] = $i + 0
+      }
+    }
+    END { for (n in ns) printf "%s %s %s\n", n, ns[n], (n in allocs ? allocs[n] : 0) }
+  ' "$1" | sort
+}
+
+case "$MODE" in
+  run)
+    run_bench "$OUTPUT"
+    ;;
+  baseline)
+    run_bench "$OUTPUT" || exit 1
+    {
+      echo "# go test -bench 基线，由 scripts/bench.sh baseline 生成"
+      echo "# $(go version)"
+      grep -E '^(goos|goarch|pkg|cpu|Benchmark)' "$OUTPUT"
+    } > "$BASELINE"
+    echo "基线已写入 $BASELINE"
+    ;;
+  check)
+    if [ ! -f "$BASELINE" ]; then
+      echo "缺少基线文件 $BASELINE，请先执行 scripts/bench.sh baseline"
+      exit 1
+    fi
+    run_bench "$OUTPUT" || exit 1
+    echo
+    echo "与基线比较（阈值 ${BENCH_THRESHOLD}%）："
+    join <(summarize "$BASELINE") <(summarize "$OUTPUT") | awk -v threshold="$BENCH_THRESHOLD" '
+      function pct(old, new) { return old > 0 ? (new - old) * 100 / old : 0 }
+      {
+        dns = pct($2, $4); dallocs = pct($3, $5)
+        status = "ok"
+        if (dns > threshold || dallocs > threshold) { status = "REGRESSION"; failed++ }
+        printf "%-70s %14.1f -> %14.1f ns/op (%+6.1f%%) %8d -> %8d allocs/op (%+6.1f%%) %s\n", $1, $2, $4, dns, $3, $5, dallocs, status
+      }
+      END {
+        if (failed > 0) { printf "\n%d 项基准退化超过 %s%%\n", failed, threshold; exit 1 }
+        print "\n未发现超过阈值的退化"
+      }
+    '
+    ;;
+  *)
+    echo "未知参数: $MODE（可选 run、baseline、check）"
+    exit 1
+    ;;
+esac